@@ -1,12 +1,29 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/samber/lo"
 	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 )
 
+const (
+	// Don't send the same certificate notification more often
+	certificateNotificationInterval = 24 * time.Hour
+	// Autocert renews certificates 30 days before they expire, warn when that didn't work for more than a week
+	certificateExpiryWarning = 21 * 24 * time.Hour
+)
+
 func (a *goBlog) getAutocertManager() *autocert.Manager {
 	if !a.cfg.Server.PublicHTTPS {
 		return nil
@@ -16,14 +33,6 @@ func (a *goBlog) getAutocertManager() *autocert.Manager {
 	}
 	// Not initialized yet
 	a.autocertInit.Do(func() {
-		// Create hosts whitelist
-		hosts := []string{a.cfg.Server.publicHostname}
-		if shn := a.cfg.Server.shortPublicHostname; shn != "" {
-			hosts = append(hosts, shn)
-		}
-		if mhn := a.cfg.Server.mediaHostname; mhn != "" {
-			hosts = append(hosts, mhn)
-		}
 		// Create autocert manager
 		acmeDir := acme.LetsEncryptURL
 		if a.cfg.Server.AcmeDir != "" {
@@ -31,7 +40,7 @@ func (a *goBlog) getAutocertManager() *autocert.Manager {
 		}
 		m := &autocert.Manager{
 			Prompt:     autocert.AcceptTOS,
-			HostPolicy: autocert.HostWhitelist(hosts...),
+			HostPolicy: autocert.HostWhitelist(a.autocertHosts()...),
 			Cache:      &httpsCache{db: a.db},
 			Client:     &acme.Client{DirectoryURL: acmeDir, HTTPClient: a.httpClient},
 		}
@@ -52,3 +61,108 @@ func (a *goBlog) getAutocertManager() *autocert.Manager {
 	// Return
 	return a.autocertManager
 }
+
+// Hostnames to get certificates for
+func (a *goBlog) autocertHosts() []string {
+	hosts := []string{a.cfg.Server.publicHostname}
+	if shn := a.cfg.Server.shortPublicHostname; shn != "" {
+		hosts = append(hosts, shn)
+	}
+	if mhn := a.cfg.Server.mediaHostname; mhn != "" {
+		hosts = append(hosts, mhn)
+	}
+	for _, cd := range a.cfg.Server.CustomDomains {
+		hosts = append(hosts, cd.hostname)
+	}
+	return hosts
+}
+
+// TLS listener like the one of autocert, but failures to obtain or renew certificates send a notification
+func (a *goBlog) autocertListener(addr string) (net.Listener, error) {
+	m := a.getAutocertManager()
+	tlsConfig := m.TLSConfig()
+	tlsConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := m.GetCertificate(hello)
+		a.reportCertificateResult(hello.ServerName, err)
+		return cert, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(ln, tlsConfig), nil
+}
+
+func (a *goBlog) reportCertificateResult(host string, err error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if err == nil {
+		// Notify again when it fails the next time
+		a.certNotifiedMu.Lock()
+		delete(a.certNotified, host)
+		a.certNotifiedMu.Unlock()
+		return
+	}
+	// Ignore requests for unknown hosts or without SNI
+	if !lo.Contains(a.autocertHosts(), host) {
+		return
+	}
+	log.Printf("HTTPS: failed to get certificate for %s: %s", host, err.Error())
+	if a.certificateNotificationDue(host) {
+		a.sendNotification(notificationTypeCertificate, fmt.Sprintf("Failed to obtain or renew the certificate for %s: %s", host, err.Error()))
+	}
+}
+
+func (a *goBlog) certificateNotificationDue(key string) bool {
+	a.certNotifiedMu.Lock()
+	defer a.certNotifiedMu.Unlock()
+	if last, ok := a.certNotified[key]; ok && time.Since(last) < certificateNotificationInterval {
+		return false
+	}
+	if a.certNotified == nil {
+		a.certNotified = map[string]time.Time{}
+	}
+	a.certNotified[key] = time.Now()
+	return true
+}
+
+func (a *goBlog) initCertificateNotifications() {
+	if !a.cfg.Server.PublicHTTPS {
+		return
+	}
+	a.hourlyHooks = append(a.hourlyHooks, a.checkCertificateExpiry)
+}
+
+// Renewals happen in the background without reporting errors, so check the expiry of the cached certificates
+func (a *goBlog) checkCertificateExpiry() {
+	cache := &httpsCache{db: a.db}
+	for _, host := range a.autocertHosts() {
+		data, err := cache.Get(context.Background(), host)
+		if err != nil {
+			continue
+		}
+		notAfter, ok := certificateNotAfter(data)
+		if !ok || time.Until(notAfter) > certificateExpiryWarning {
+			continue
+		}
+		text := fmt.Sprintf("The certificate for %s expires on %s and wasn't renewed yet", host, notAfter.UTC().Format(time.RFC1123))
+		log.Println("HTTPS:", text)
+		if a.certificateNotificationDue("expiry:" + host) {
+			a.sendNotification(notificationTypeCertificate, text)
+		}
+	}
+}
+
+// Expiry of the leaf certificate of the PEM data cached by autocert (private key followed by the chain)
+func certificateNotAfter(data []byte) (time.Time, bool) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return cert.NotAfter, true
+	}
+	return time.Time{}, false
+}
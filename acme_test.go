@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_certificateNotifications(t *testing.T) {
+	h := newTestHarness(t)
+	a := h.app
+
+	notifications := func() int {
+		count, err := a.db.countNotifications(&notificationsRequestConfig{})
+		require.NoError(t, err)
+		return count
+	}
+
+	// Unknown hosts are ignored
+	a.reportCertificateResult("unknown.example", errors.New("not allowed"))
+	assert.Equal(t, 0, notifications())
+
+	// Only one notification until it worked again
+	a.reportCertificateResult("example.com", errors.New("rate limited"))
+	a.reportCertificateResult("Example.com.", errors.New("rate limited"))
+	assert.Equal(t, 1, notifications())
+	a.reportCertificateResult("example.com", nil)
+	a.reportCertificateResult("example.com", errors.New("dns error"))
+	assert.Equal(t, 2, notifications())
+
+	// Certificate that should have been renewed already
+	createCert := func(notAfter time.Time) []byte {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			DNSNames:     []string{"example.com"},
+			NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+			NotAfter:     notAfter,
+		}, &x509.Certificate{SerialNumber: big.NewInt(1)}, &key.PublicKey, key)
+		require.NoError(t, err)
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+		return append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	cache := &httpsCache{db: a.db}
+
+	notAfter := time.Now().Add(60 * 24 * time.Hour).Truncate(time.Second)
+	require.NoError(t, cache.Put(context.Background(), "example.com", createCert(notAfter)))
+	parsed, ok := certificateNotAfter(createCert(notAfter))
+	require.True(t, ok)
+	assert.True(t, notAfter.Equal(parsed))
+	a.checkCertificateExpiry()
+	assert.Equal(t, 2, notifications())
+
+	require.NoError(t, cache.Put(context.Background(), "example.com", createCert(time.Now().Add(10*24*time.Hour))))
+	a.checkCertificateExpiry()
+	a.checkCertificateExpiry()
+	assert.Equal(t, 3, notifications())
+}
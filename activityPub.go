@@ -3,18 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
 	"database/sql"
 	"encoding/json"
-	"encoding/pem"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
@@ -35,35 +32,41 @@ func (a *goBlog) initActivityPub() error {
 	}
 	// Add hooks
 	a.pPostHooks = append(a.pPostHooks, func(p *post) {
-		if p.isPublishedSectionPost() && (p.Visibility == visibilityPublic || p.Visibility == visibilityUnlisted) {
+		if p.isPublishedSectionPost() && (p.Visibility == visibilityPublic || p.Visibility == visibilityUnlisted) && !a.isProtectedBlog(p.Blog) {
 			a.apCheckMentions(p)
 			a.apCheckActivityPubReply(p)
+			a.apCheckActivityPubInteraction(p)
 			a.apPost(p)
 		}
 	})
 	a.pUpdateHooks = append(a.pUpdateHooks, func(p *post) {
-		if p.isPublishedSectionPost() && (p.Visibility == visibilityPublic || p.Visibility == visibilityUnlisted) {
+		if p.isPublishedSectionPost() && (p.Visibility == visibilityPublic || p.Visibility == visibilityUnlisted) && !a.isProtectedBlog(p.Blog) {
 			a.apCheckMentions(p)
 			a.apCheckActivityPubReply(p)
+			a.apCheckActivityPubInteraction(p)
 			a.apUpdate(p)
 		}
 	})
 	a.pDeleteHooks = append(a.pDeleteHooks, func(p *post) {
-		a.apDelete(p)
+		if !a.isProtectedBlog(p.Blog) {
+			a.apDelete(p)
+		}
 	})
 	a.pUndeleteHooks = append(a.pUndeleteHooks, func(p *post) {
-		if p.isPublishedSectionPost() && (p.Visibility == visibilityPublic || p.Visibility == visibilityUnlisted) {
+		if p.isPublishedSectionPost() && (p.Visibility == visibilityPublic || p.Visibility == visibilityUnlisted) && !a.isProtectedBlog(p.Blog) {
 			a.apUndelete(p)
 		}
 	})
+	// Cache for rendered ActivityStreams objects
+	a.asObjectCache = newCacheStore(asObjectCacheSize)
 	// Prepare webfinger
 	a.prepareWebfinger()
 	// Read key and prepare signing
-	err := a.loadActivityPubPrivateKey()
+	err := a.loadActivityPubPrivateKeys()
 	if err != nil {
 		return err
 	}
-	a.apSigner, _, err = httpsig.NewSigner(
+	a.apKeys.signer, _, err = httpsig.NewSigner(
 		[]httpsig.Algorithm{httpsig.RSA_SHA256},
 		httpsig.DigestSha256,
 		[]string{httpsig.RequestTarget, "date", "host", "digest"},
@@ -85,6 +88,8 @@ func (a *goBlog) initActivityPub() error {
 	}
 	// Init send queue
 	a.initAPSendQueue()
+	// Trim the inbox log
+	a.initAPInboxLog()
 	// Send profile updates
 	go func() {
 		// First wait a bit
@@ -109,37 +114,104 @@ func (a *goBlog) apEnabled() bool {
 
 func (a *goBlog) prepareWebfinger() {
 	a.webfingerResources = map[string]*configBlog{}
-	a.webfingerAccts = map[string]string{}
+	a.webfingerAccts = map[string][]string{}
+	host := a.cfg.Server.publicHostname
 	for name, blog := range a.cfg.Blogs {
-		acct := "acct:" + name + "@" + a.cfg.Server.publicHostname
-		a.webfingerResources[acct] = blog
-		a.webfingerResources[a.apIri(blog)] = blog
-		a.webfingerAccts[a.apIri(blog)] = acct
+		apIri := a.apIri(blog)
+		// Blogs with their own domain use it for the handle, the handle on the public hostname still works
+		blogHost := a.getBlogHostname(blog)
+		accts := []string{"acct:" + name + "@" + blogHost, "acct:" + name + "@" + host}
+		if blog.ActivityPub != nil {
+			for _, alias := range blog.ActivityPub.Aliases {
+				// Aliases are user names on the hostname of the blog or email-style accounts on other domains
+				if !strings.Contains(alias, "@") {
+					alias += "@" + blogHost
+				}
+				accts = append(accts, normalizeWebfingerResource(alias))
+			}
+		}
+		if blog.domain != nil {
+			// The bare domain of the blog (like @blog.example.com@blog.example.com)
+			accts = append(accts, "acct:"+blogHost+"@"+blogHost)
+		}
+		for _, acct := range accts {
+			a.webfingerResources[normalizeWebfingerResource(acct)] = blog
+		}
+		a.webfingerResources[normalizeWebfingerResource(apIri)] = blog
+		a.webfingerAccts[apIri] = lo.Uniq(accts)
+	}
+	// Account for the bare domain (like @example.com@example.com or https://example.com)
+	defaultBlog := a.cfg.DefaultBlog
+	if apc := a.cfg.ActivityPub; apc != nil && apc.DefaultBlog != "" {
+		defaultBlog = apc.DefaultBlog
+	}
+	if blog, ok := a.cfg.Blogs[defaultBlog]; ok {
+		for _, resource := range []string{"acct:" + host + "@" + host, a.cfg.Server.PublicAddress} {
+			if _, exists := a.webfingerResources[normalizeWebfingerResource(resource)]; !exists {
+				a.webfingerResources[normalizeWebfingerResource(resource)] = blog
+			}
+		}
+	}
+}
+
+// Lowercase acct URIs (also without the "acct:" scheme or with a leading "@") and URLs without trailing slash
+func normalizeWebfingerResource(resource string) string {
+	resource = strings.TrimSpace(resource)
+	if acct, ok := strings.CutPrefix(resource, "acct:"); ok {
+		return "acct:" + strings.ToLower(strings.TrimPrefix(acct, "@"))
 	}
+	if !strings.Contains(resource, ":") && strings.Contains(resource, "@") {
+		return "acct:" + strings.ToLower(strings.TrimPrefix(resource, "@"))
+	}
+	return strings.TrimSuffix(resource, "/")
 }
 
 func (a *goBlog) apHandleWebfinger(w http.ResponseWriter, r *http.Request) {
-	blog, ok := a.webfingerResources[r.URL.Query().Get("resource")]
-	if !ok {
+	// WebFinger clients in browsers need CORS (RFC 7033 section 5)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		a.serveError(w, r, "Missing resource parameter", http.StatusBadRequest)
+		return
+	}
+	resource = normalizeWebfingerResource(resource)
+	if u, err := url.Parse(resource); err != nil || u.Scheme == "" {
+		a.serveError(w, r, "Invalid resource parameter", http.StatusBadRequest)
+		return
+	}
+	blog, ok := a.webfingerResources[resource]
+	if cd := a.blogDomainForHost(stripPort(r.Host)); ok && cd != nil {
+		// The domain of a blog only knows the resources of the blog
+		ok = blog.name == cd.Blog
+	}
+	if !ok || a.isProtectedBlog(blog.name) {
 		a.serveError(w, r, "Resource not found", http.StatusNotFound)
 		return
 	}
 	apIri := a.apIri(blog)
+	accts := a.webfingerAccts[apIri]
+	links := []map[string]string{
+		{
+			"rel": "self", "type": contenttype.AS, "href": apIri,
+		},
+		{
+			"rel":  "http://webfinger.net/rel/profile-page",
+			"type": "text/html", "href": apIri,
+		},
+	}
+	// Only return the requested link relations
+	if rels := r.URL.Query()["rel"]; len(rels) > 0 {
+		links = lo.Filter(links, func(link map[string]string, _ int) bool {
+			return lo.Contains(rels, link["rel"])
+		})
+	}
 	// Encode
 	pr, pw := io.Pipe()
 	go func() {
 		_ = pw.CloseWithError(json.NewEncoder(pw).Encode(map[string]any{
-			"subject": a.webfingerAccts[apIri],
-			"aliases": []string{a.webfingerAccts[apIri], apIri},
-			"links": []map[string]string{
-				{
-					"rel": "self", "type": contenttype.AS, "href": apIri,
-				},
-				{
-					"rel":  "http://webfinger.net/rel/profile-page",
-					"type": "text/html", "href": apIri,
-				},
-			},
+			"subject": accts[0],
+			"aliases": append(append([]string{}, accts...), apIri),
+			"links":   links,
 		}))
 	}()
 	w.Header().Set(contentType, "application/jrd+json"+contenttype.CharsetUtf8Suffix)
@@ -148,6 +220,10 @@ func (a *goBlog) apHandleWebfinger(w http.ResponseWriter, r *http.Request) {
 
 const activityPubMentionsParameter = "activitypubmentions"
 
+// Fediverse handles like @user@example.org in the post content
+var apMentionHandleRegex = regexp.MustCompile(`(?:^|[^\w@/.])@([\w.-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// Check the links and the fediverse handles in the post for ActivityPub actors to mention
 func (a *goBlog) apCheckMentions(p *post) {
 	pr, pw := io.Pipe()
 	go func() {
@@ -157,7 +233,7 @@ func (a *goBlog) apCheckMentions(p *post) {
 	links, err := allLinksFromHTML(pr, a.fullPostURL(p))
 	_ = pr.CloseWithError(err)
 	if err != nil {
-		log.Println("Failed to extract links from post: " + err.Error())
+		a.logger("activitypub").Error("Failed to extract links from post", "path", p.Path, "err", err)
 		return
 	}
 	apc := a.apHttpClients[p.Blog]
@@ -169,6 +245,15 @@ func (a *goBlog) apCheckMentions(p *post) {
 		}
 		mentions = append(mentions, link)
 	}
+	for _, match := range apMentionHandleRegex.FindAllStringSubmatch(p.Content, -1) {
+		actor, err := a.apResolveHandle(context.Background(), match[1])
+		if err != nil {
+			a.logger("activitypub").Warn("Failed to resolve mention", "mention", match[1], "err", err)
+			continue
+		}
+		mentions = append(mentions, actor)
+	}
+	mentions = lo.Uniq(mentions)
 	if p.Parameters == nil {
 		p.Parameters = map[string][]string{}
 	}
@@ -176,8 +261,12 @@ func (a *goBlog) apCheckMentions(p *post) {
 	_ = a.db.replacePostParam(p.Path, activityPubMentionsParameter, mentions)
 }
 
-const activityPubReplyActorParameter = "activitypubreplyactor"
+const (
+	activityPubReplyActorParameter  = "activitypubreplyactor"
+	activityPubReplyObjectParameter = "activitypubreplyobject"
+)
 
+// Check if a reply targets an ActivityPub object, so the reply can be threaded and sent to its author
 func (a *goBlog) apCheckActivityPubReply(p *post) {
 	replyLink := a.replyLink(p)
 	if replyLink == "" {
@@ -193,98 +282,158 @@ func (a *goBlog) apCheckActivityPubReply(p *post) {
 		return
 	}
 	replyLinkActor := []string{obj.AttributedTo.GetLink().String()}
+	replyObject := []string{obj.GetLink().String()}
 	if p.Parameters == nil {
 		p.Parameters = map[string][]string{}
 	}
 	p.Parameters[activityPubReplyActorParameter] = replyLinkActor
+	p.Parameters[activityPubReplyObjectParameter] = replyObject
 	_ = a.db.replacePostParam(p.Path, activityPubReplyActorParameter, replyLinkActor)
+	_ = a.db.replacePostParam(p.Path, activityPubReplyObjectParameter, replyObject)
+}
+
+// Actors mentioned in the post and the author of the replied object, they get the activities in their inboxes
+func (*goBlog) apMentionedActors(p *post) []string {
+	return lo.Uniq(lo.Compact(append(
+		[]string{p.firstParameter(activityPubReplyActorParameter)},
+		p.Parameters[activityPubMentionsParameter]...,
+	)))
+}
+
+const (
+	activityPubInteractionObjectParameter = "activitypubinteractionobject"
+	activityPubInteractionActorParameter  = "activitypubinteractionactor"
+)
+
+// Check if a like or repost targets an ActivityPub object, so it can be federated as Like or Announce
+func (a *goBlog) apCheckActivityPubInteraction(p *post) {
+	var object, actor []string
+	if link := defaultIfEmpty(a.likeLink(p), a.repostLink(p)); link != "" {
+		apc := a.apHttpClients[p.Blog]
+		if item, err := apc.LoadIRI(ap.IRI(link)); err == nil && item != nil && ap.IsObject(item) {
+			if obj, err := ap.ToObject(item); err == nil && obj != nil && obj.GetLink() != "" && obj.AttributedTo != nil && obj.AttributedTo.GetLink() != "" {
+				object = []string{obj.GetLink().String()}
+				actor = []string{obj.AttributedTo.GetLink().String()}
+			}
+		}
+	}
+	if p.Parameters == nil {
+		p.Parameters = map[string][]string{}
+	}
+	p.Parameters[activityPubInteractionObjectParameter] = object
+	p.Parameters[activityPubInteractionActorParameter] = actor
+	_ = a.db.replacePostParam(p.Path, activityPubInteractionObjectParameter, object)
+	_ = a.db.replacePostParam(p.Path, activityPubInteractionActorParameter, actor)
 }
 
 func (a *goBlog) apHandleInbox(w http.ResponseWriter, r *http.Request) {
 	// Get blog
 	blogName := chi.URLParam(r, "blog")
 	blog, ok := a.cfg.Blogs[blogName]
-	if !ok || blog == nil {
+	if !ok || blog == nil || blog.isProtected() {
 		a.serveError(w, r, "Inbox not found", http.StatusNotFound)
 		return
 	}
-	// Verify request
-	requestActor, err := a.apVerifySignature(r, blogName)
-	if err != nil {
-		// Send 401 because signature could not be verified
-		a.serveError(w, r, err.Error(), http.StatusUnauthorized)
-		return
+	// Audit log of the received activity and what happened with it
+	entry := &apInboxLogEntry{blog: blogName}
+	defer a.db.apLogInboxActivity(entry)
+	reject := func(message string, status int) {
+		entry.outcome = fmt.Sprintf("rejected (%d): %s", status, message)
+		a.serveError(w, r, message, status)
 	}
 	// Parse activity
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		a.serveError(w, r, "Failed to read body", http.StatusBadRequest)
+		reject("Failed to read body", http.StatusBadRequest)
 		return
 	}
-	apItem, err := ap.UnmarshalJSON(body)
+	apItem, decodeErr := ap.UnmarshalJSON(body)
+	var activity *ap.Activity
+	if decodeErr == nil {
+		if activity, err = ap.ToActivity(apItem); err == nil {
+			entry.fill(activity)
+		}
+	}
+	// Verify request
+	requestActor, err := a.apVerifySignature(r, blogName)
 	if err != nil {
-		a.serveError(w, r, "Failed to decode body", http.StatusBadRequest)
+		// Send 401 because signature could not be verified
+		a.logger("activitypub").WarnCtx(r.Context(), "Failed to verify inbox request", "blog", blogName, "err", err)
+		reject(err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if decodeErr != nil {
+		reject("Failed to decode body", http.StatusBadRequest)
 		return
 	}
 	// Check if it's an activity
-	activity, err := ap.ToActivity(apItem)
-	if err != nil {
-		a.serveError(w, r, "No activity", http.StatusBadRequest)
+	if activity == nil {
+		reject("No activity", http.StatusBadRequest)
 		return
 	}
 	// Check actor
 	activityActor := activity.Actor.GetLink()
 	if activity.Actor == nil || (!activity.Actor.IsLink() && !activity.Actor.IsObject()) {
-		a.serveError(w, r, "Activity has no actor", http.StatusBadRequest)
+		reject("Activity has no actor", http.StatusBadRequest)
 		return
 	}
 	if activityActor != requestActor.GetLink() {
-		a.serveError(w, r, "Request actor isn't activity actor", http.StatusForbidden)
+		reject("Request actor isn't activity actor", http.StatusForbidden)
 		return
 	}
+	a.logger("activitypub").DebugCtx(r.Context(), "Received activity", "blog", blogName, "type", activity.GetType(), "actor", activityActor.String())
 	// Handle activity
+	entry.outcome = "ignored: unsupported activity"
 	switch activity.GetType() {
 	case ap.FollowType:
-		a.apAccept(blogName, blog, activity)
+		entry.outcome = a.apAccept(blogName, blog, activity)
 	case ap.UndoType:
 		if activity.Object.IsObject() {
 			objectActivity, err := ap.ToActivity(activity.Object)
 			if err == nil && objectActivity.GetType() == ap.FollowType && objectActivity.Actor.GetLink() == activityActor {
 				_ = a.db.apRemoveFollower(blogName, activityActor.String())
+				entry.outcome = "follower removed"
 			}
 		}
 	case ap.CreateType, ap.UpdateType:
 		if activity.Object.IsObject() {
-			a.apOnCreateUpdate(blog, requestActor, activity)
+			entry.outcome = a.apOnCreateUpdate(blog, requestActor, activity)
 		}
 	case ap.DeleteType, ap.BlockType:
 		if activity.Object.GetLink() == activityActor {
 			_ = a.db.apRemoveFollower(blogName, activityActor.String())
+			entry.outcome = "follower removed"
 		} else {
 			// Check if comment exists
 			exists, commentId, err := a.db.commentIdByOriginal(activity.Object.GetLink().String())
 			if err == nil && exists {
 				_ = a.db.deleteComment(commentId)
 				_ = a.db.deleteWebmentionUUrl(activity.Object.GetLink().String())
+				entry.outcome = "comment deleted"
+			} else {
+				entry.outcome = "ignored: unknown object"
 			}
 		}
 	case ap.AnnounceType:
-		a.sendNotification(fmt.Sprintf("%s announced %s", activityActor, activity.Object.GetLink()))
+		a.sendNotification(notificationTypeInteraction, fmt.Sprintf("%s announced %s", activityActor, activity.Object.GetLink()))
+		entry.outcome = "notification sent"
 	case ap.LikeType:
-		a.sendNotification(fmt.Sprintf("%s liked %s", activityActor, activity.Object.GetLink()))
+		a.sendNotification(notificationTypeInteraction, fmt.Sprintf("%s liked %s", activityActor, activity.Object.GetLink()))
+		entry.outcome = "notification sent"
 	}
 	// Return 200
 	w.WriteHeader(http.StatusOK)
 }
 
-func (a *goBlog) apOnCreateUpdate(blog *configBlog, requestActor *ap.Actor, activity *ap.Activity) {
+// Returns the outcome for the inbox log
+func (a *goBlog) apOnCreateUpdate(blog *configBlog, requestActor *ap.Actor, activity *ap.Activity) string {
 	object, err := ap.ToObject(activity.Object)
 	if err != nil {
-		return
+		return "ignored: invalid object"
 	}
 	if object.GetType() != ap.NoteType && object.GetType() != ap.ArticleType {
 		// ignore other objects for now
-		return
+		return "ignored: unsupported object type " + string(object.GetType())
 	}
 	visible := true
 	if !object.To.Contains(ap.PublicNS) && !object.CC.Contains(ap.PublicNS) {
@@ -304,8 +453,10 @@ func (a *goBlog) apOnCreateUpdate(blog *configBlog, requestActor *ap.Actor, acti
 			}
 			content := object.Content.First().Value.String()
 			if visible {
-				_, _, _ = a.createComment(blog, replyTarget, content, name, website, original)
-				return
+				if _, _, err := a.createComment(blog, replyTarget, content, name, website, original); err != nil {
+					return "failed to create comment: " + err.Error()
+				}
+				return "comment created"
 			} else {
 				buf := bufferpool.Get()
 				defer bufferpool.Put(buf)
@@ -313,13 +464,14 @@ func (a *goBlog) apOnCreateUpdate(blog *configBlog, requestActor *ap.Actor, acti
 				fmt.Fprintf(buf, "Author: %s (%s)", cleanHTMLText(name), cleanHTMLText(website))
 				buf.WriteString("\n\n")
 				buf.WriteString(cleanHTMLText(content))
-				a.sendNotification(buf.String())
-				return
+				a.sendNotification(notificationTypeComment, buf.String())
+				return "notification sent: private reply"
 			}
 		}
 	}
 	// Might be a private reply or mention etc.
 	// TODO: handle them
+	return "ignored: not a reply to this blog"
 }
 
 func (a *goBlog) apVerifySignature(r *http.Request, blog string) (*ap.Actor, error) {
@@ -328,21 +480,14 @@ func (a *goBlog) apVerifySignature(r *http.Request, blog string) (*ap.Actor, err
 		// Error with signature header etc.
 		return nil, err
 	}
-	actor, err := a.apGetRemoteActor(ap.IRI(verifier.KeyId()), blog)
+	actor, keys, err := a.apGetRemoteActorKeys(ap.IRI(verifier.KeyId()), blog)
 	if err != nil || actor == nil {
 		// Actor not found or something else bad
 		return nil, errors.New("failed to get actor")
 	}
-	if actor.PublicKey.PublicKeyPem == "" {
-		return nil, errors.New("actor has no public key")
-	}
-	block, _ := pem.Decode([]byte(actor.PublicKey.PublicKeyPem))
-	if block == nil {
-		return nil, errors.New("public key invalid")
-	}
-	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	pubKey, err := apSelectPublicKey(keys, verifier.KeyId())
 	if err != nil {
-		// Unable to parse public key
+		// No usable public key
 		return nil, err
 	}
 	return actor, verifier.Verify(pubKey, httpsig.RSA_SHA256)
@@ -361,7 +506,7 @@ func (a *goBlog) apGetFollowersCollectionId(blogName string, blog *configBlog) a
 func (a *goBlog) apShowFollowers(w http.ResponseWriter, r *http.Request) {
 	blogName := chi.URLParam(r, "blog")
 	blog, ok := a.cfg.Blogs[blogName]
-	if !ok || blog == nil {
+	if !ok || blog == nil || blog.isProtected() {
 		a.serveError(w, r, "Blog not found", http.StatusNotFound)
 		return
 	}
@@ -382,7 +527,7 @@ func (a *goBlog) apShowFollowers(w http.ResponseWriter, r *http.Request) {
 	a.render(w, r, a.renderActivityPubFollowers, &renderData{
 		BlogString: blogName,
 		Data: &activityPubFollowersRenderData{
-			apUser:    fmt.Sprintf("@%s@%s", blogName, a.cfg.Server.publicHostname),
+			apUser:    fmt.Sprintf("@%s@%s", blogName, a.getBlogHostname(a.cfg.Blogs[blogName])),
 			followers: followers,
 		},
 	})
@@ -447,27 +592,46 @@ func (db *database) apRemoveInbox(inbox string) error {
 }
 
 func (a *goBlog) apPost(p *post) {
+	if interaction := a.toAPInteraction(p); interaction != nil {
+		a.apSendInteraction(p, interaction)
+		return
+	}
 	blogConfig := a.getBlogFromPost(p)
 	c := ap.CreateNew(a.apNewID(blogConfig), a.toAPNote(p))
 	c.Actor = a.apAPIri(blogConfig)
 	c.Published = time.Now()
-	a.apSendToAllFollowers(p.Blog, c, append(p.Parameters[activityPubMentionsParameter], p.firstParameter(activityPubReplyActorParameter))...)
+	a.apSendToAllFollowers(p.Blog, c, a.apMentionedActors(p)...)
 }
 
 func (a *goBlog) apUpdate(p *post) {
+	if a.toAPInteraction(p) != nil {
+		// Likes and announces can't be updated
+		return
+	}
+	a.apSendToAllFollowers(p.Blog, a.apPostUpdate(p), a.apMentionedActors(p)...)
+}
+
+func (a *goBlog) apPostUpdate(p *post) *ap.Activity {
 	blogConfig := a.getBlogFromPost(p)
 	u := ap.UpdateNew(a.apNewID(blogConfig), a.toAPNote(p))
 	u.Actor = a.apAPIri(blogConfig)
 	u.Published = time.Now()
-	a.apSendToAllFollowers(p.Blog, u, append(p.Parameters[activityPubMentionsParameter], p.firstParameter(activityPubReplyActorParameter))...)
+	return u
 }
 
 func (a *goBlog) apDelete(p *post) {
 	blogConfig := a.getBlogFromPost(p)
+	if interaction := a.toAPInteraction(p); interaction != nil {
+		u := ap.UndoNew(a.apNewID(blogConfig), interaction)
+		u.Actor = a.apAPIri(blogConfig)
+		u.Published = time.Now()
+		a.apSendInteraction(p, u)
+		return
+	}
 	d := ap.DeleteNew(a.apNewID(blogConfig), a.activityPubId(p))
 	d.Actor = a.apAPIri(blogConfig)
 	d.Published = time.Now()
-	a.apSendToAllFollowers(p.Blog, d, append(p.Parameters[activityPubMentionsParameter], p.firstParameter(activityPubReplyActorParameter))...)
+	a.apSendToAllFollowers(p.Blog, d, a.apMentionedActors(p)...)
 }
 
 func (a *goBlog) apUndelete(p *post) {
@@ -480,19 +644,38 @@ func (a *goBlog) apUndelete(p *post) {
 	// Update "activityPubVersion" parameter to current timestamp in nanoseconds
 	p.Parameters[activityPubVersionParam] = []string{fmt.Sprintf("%d", utcNowNanos())}
 	_ = a.db.replacePostParam(p.Path, activityPubVersionParam, p.Parameters[activityPubVersionParam])
+	// The new object gets the current URL as ID
+	delete(p.Parameters, activityPubIdParam)
+	_ = a.db.replacePostParam(p.Path, activityPubIdParam, nil)
 	// Post as new post
 	a.apPost(p)
 }
 
-func (a *goBlog) apAccept(blogName string, blog *configBlog, follow *ap.Activity) {
+// Keep the ID of an already federated post when its path changes. Other servers can't change the ID
+// of an object, but with the old ID they apply the Update activity (with the new URL) to the known post.
+func (a *goBlog) apKeepIdOnPathChange(p *post, oldPath string, oldStatus postStatus, oldVisibility postVisibility) {
+	if !a.apEnabled() || oldPath == "" || oldPath == p.Path || p.firstParameter(activityPubIdParam) != "" {
+		return
+	}
+	if p.Section == "" || oldStatus != statusPublished || (oldVisibility != visibilityPublic && oldVisibility != visibilityUnlisted) {
+		// Wasn't federated
+		return
+	}
+	oldPost := *p
+	oldPost.Path = oldPath
+	p.Parameters[activityPubIdParam] = []string{string(a.activityPubId(&oldPost))}
+}
+
+// Returns the outcome for the inbox log
+func (a *goBlog) apAccept(blogName string, blog *configBlog, follow *ap.Activity) string {
 	newFollower := follow.Actor.GetLink()
-	log.Println("New follow request from follower id:", newFollower.String())
+	a.logger("activitypub").Info("New follow request", "blog", blogName, "follower", newFollower.String())
 	// Get remote actor
 	follower, err := a.apGetRemoteActor(newFollower, blogName)
 	if err != nil || follower == nil {
 		// Couldn't retrieve remote actor info
-		log.Println("Failed to retrieve remote actor info:", newFollower)
-		return
+		a.logger("activitypub").Warn("Failed to retrieve remote actor info", "follower", newFollower.String())
+		return "failed to retrieve actor"
 	}
 	// Add or update follower
 	inbox := follower.Inbox.GetLink()
@@ -500,11 +683,11 @@ func (a *goBlog) apAccept(blogName string, blog *configBlog, follow *ap.Activity
 		inbox = endpoints.SharedInbox.GetLink()
 	}
 	if inbox == "" {
-		return
+		return "failed: actor has no inbox"
 	}
 	username := apUsername(follower)
 	if err = a.db.apAddFollower(blogName, follower.GetLink().String(), inbox.String(), username); err != nil {
-		return
+		return "failed to add follower: " + err.Error()
 	}
 	// Send accept response to the new follower
 	accept := ap.AcceptNew(a.apNewID(blog), follow)
@@ -512,27 +695,76 @@ func (a *goBlog) apAccept(blogName string, blog *configBlog, follow *ap.Activity
 	accept.Actor = a.apAPIri(blog)
 	_ = a.apQueueSendSigned(a.apIri(blog), inbox.String(), accept)
 	// Notification
-	a.sendNotification(fmt.Sprintf("%s (%s) started following %s", username, follower.GetLink().String(), a.apIri(blog)))
+	a.sendNotification(notificationTypeFollower, fmt.Sprintf("%s (%s) started following %s", username, follower.GetLink().String(), a.apIri(blog)))
+	return "follower added"
 }
 
 func (a *goBlog) apSendProfileUpdates() {
-	for blog, config := range a.cfg.Blogs {
-		person := a.toApPerson(blog)
-		update := ap.UpdateNew(a.apNewID(config), person)
-		update.Actor = a.apAPIri(config)
-		update.Published = time.Now()
-		update.To.Append(ap.PublicNS, a.apGetFollowersCollectionId(blog, config))
-		a.apSendToAllFollowers(blog, update)
+	for blog := range a.cfg.Blogs {
+		a.apSendProfileUpdate(blog)
 	}
 }
 
+func (a *goBlog) apSendProfileUpdate(blog string) {
+	a.apSendToAllFollowers(blog, a.apProfileUpdate(blog))
+}
+
+func (a *goBlog) apProfileUpdate(blog string) *ap.Activity {
+	config := a.cfg.Blogs[blog]
+	update := ap.UpdateNew(a.apNewID(config), a.toApPerson(blog))
+	update.Actor = a.apAPIri(config)
+	update.Published = time.Now()
+	update.To.Append(ap.PublicNS, a.apGetFollowersCollectionId(blog, config))
+	return update
+}
+
+// Send likes only to the author of the liked object, announces also to the followers
+func (a *goBlog) apSendInteraction(p *post, activity *ap.Activity) {
+	actor := p.firstParameter(activityPubInteractionActorParameter)
+	if a.likeLink(p) != "" {
+		a.apSendToActors(p.Blog, activity, actor)
+		return
+	}
+	a.apSendToAllFollowers(p.Blog, activity, actor)
+}
+
 func (a *goBlog) apSendToAllFollowers(blog string, activity *ap.Activity, mentions ...string) {
+	if a.isProtectedBlog(blog) {
+		return
+	}
+	inboxes, err := a.db.apGetAllInboxes(blog)
+	if err != nil {
+		a.logger("activitypub").Error("Failed to retrieve follower inboxes", "blog", blog, "err", err)
+		return
+	}
+	a.apSendToActors(blog, activity, mentions...)
+	a.apSendTo(a.apIri(a.cfg.Blogs[blog]), activity, inboxes...)
+}
+
+// Queue the activity for all follower inboxes before returning, e.g. for commands that exit afterwards
+func (a *goBlog) apQueueToAllFollowers(blog string, activity *ap.Activity) (int, error) {
+	if a.isProtectedBlog(blog) {
+		return 0, fmt.Errorf("blog %s is protected and isn't federated", blog)
+	}
 	inboxes, err := a.db.apGetAllInboxes(blog)
 	if err != nil {
-		log.Println("Failed to retrieve follower inboxes:", err.Error())
+		return 0, err
+	}
+	inboxes = lo.Uniq(inboxes)
+	for _, inbox := range inboxes {
+		if err = a.apQueueSendSigned(a.apIri(a.cfg.Blogs[blog]), inbox, activity); err != nil {
+			return 0, err
+		}
+	}
+	return len(inboxes), nil
+}
+
+// Send to the inboxes of the actors
+func (a *goBlog) apSendToActors(blog string, activity *ap.Activity, actors ...string) {
+	if a.isProtectedBlog(blog) {
 		return
 	}
-	for _, m := range mentions {
+	for _, m := range actors {
 		go func(m string) {
 			if m == "" {
 				return
@@ -546,7 +778,6 @@ func (a *goBlog) apSendToAllFollowers(blog string, activity *ap.Activity, mentio
 			a.apSendTo(a.apIri(a.cfg.Blogs[blog]), activity, inbox)
 		}(m)
 	}
-	a.apSendTo(a.apIri(a.cfg.Blogs[blog]), activity, inboxes...)
 }
 
 func (a *goBlog) apSendTo(blogIri string, activity *ap.Activity, inboxes ...string) {
@@ -562,6 +793,9 @@ func (a *goBlog) apNewID(blog *configBlog) ap.ID {
 }
 
 func (a *goBlog) apIri(b *configBlog) string {
+	if b.domain != nil {
+		return b.domain.Address
+	}
 	return a.getFullAddress(b.getRelativePath(""))
 }
 
@@ -573,50 +807,17 @@ func apRequestIsSuccess(code int) bool {
 	return code == http.StatusOK || code == http.StatusCreated || code == http.StatusAccepted || code == http.StatusNoContent
 }
 
-// Load or generate key for ActivityPub communication
-func (a *goBlog) loadActivityPubPrivateKey() error {
-	// Check if already loaded
-	if a.apPrivateKey != nil {
-		return nil
-	}
-	// Check if already generated
-	if keyData, err := a.db.retrievePersistentCache("activitypub_key"); err == nil && keyData != nil {
-		privateKeyDecoded, _ := pem.Decode(keyData)
-		if privateKeyDecoded == nil {
-			log.Println("failed to decode cached private key")
-			// continue
-		} else {
-			key, err := x509.ParsePKCS1PrivateKey(privateKeyDecoded.Bytes)
-			if err != nil {
-				return err
-			}
-			pubKeyBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
-			if err != nil {
-				return err
-			}
-			a.apPrivateKey = key
-			a.apPubKeyBytes = pubKeyBytes
-			return nil
-		}
-	}
-	// Generate and cache key
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
+// Load or generate the keys of all blogs for ActivityPub communication
+func (a *goBlog) loadActivityPubPrivateKeys() error {
+	if err := a.migrateActivityPubKey(); err != nil {
 		return err
 	}
-	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
-	if err != nil {
-		return err
+	for blog := range a.cfg.Blogs {
+		if _, err := a.apKey(blog); err != nil {
+			return err
+		}
 	}
-	a.apPrivateKey = key
-	a.apPubKeyBytes = pubKeyBytes
-	return a.db.cachePersistently(
-		"activitypub_key",
-		pem.EncodeToMemory(&pem.Block{
-			Type:  "PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(a.apPrivateKey),
-		}),
-	)
+	return nil
 }
 
 func (a *goBlog) signRequest(r *http.Request, blogIri string) error {
@@ -632,7 +833,24 @@ func (a *goBlog) signRequest(r *http.Request, blogIri string) error {
 			r.Body = io.NopCloser(bodyBuf)
 		}
 	}
-	a.apSignMutex.Lock()
-	defer a.apSignMutex.Unlock()
-	return a.apSigner.SignRequest(a.apPrivateKey, blogIri+"#main-key", r, bodyBuf.Bytes())
+	blog, ok := a.apBlogFromIri(blogIri)
+	if !ok {
+		return errors.New("no blog with IRI " + blogIri)
+	}
+	a.apKeys.mutex.Lock()
+	defer a.apKeys.mutex.Unlock()
+	key, err := a.apKeyLocked(blog)
+	if err != nil {
+		return err
+	}
+	return a.apKeys.signer.SignRequest(key.privateKey, blogIri+"#"+key.keyId, r, bodyBuf.Bytes())
+}
+
+func (a *goBlog) apBlogFromIri(blogIri string) (string, bool) {
+	for blog, bc := range a.cfg.Blogs {
+		if a.apIri(bc) == blogIri {
+			return blog, true
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/go-chi/chi/v5"
+	"github.com/samber/lo"
+)
+
+// Deliver the latest posts to new followers, so a newly federated blog doesn't look empty
+
+const (
+	apBackfillPath         = "/backfill"
+	defaultApBackfillCount = 10
+	maxApBackfillCount     = 50
+)
+
+// Queue a create activity of the last public posts of the blog for each inbox, oldest first
+func (a *goBlog) apBackfill(blog string, count int, inboxes ...string) (int, error) {
+	bc, ok := a.cfg.Blogs[blog]
+	if !ok {
+		return 0, errors.New("blog not found")
+	}
+	inboxes = lo.Uniq(lo.Compact(inboxes))
+	if len(inboxes) == 0 {
+		return 0, nil
+	}
+	posts, err := a.getPosts(&postsRequestConfig{
+		blog:                   blog,
+		status:                 []postStatus{statusPublished},
+		visibility:             []postVisibility{visibilityPublic},
+		withinVisibilityWindow: true,
+		limit:                  count,
+	})
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for i := len(posts) - 1; i >= 0; i-- {
+		p := posts[i]
+		if a.toAPInteraction(p) != nil {
+			// Likes and reposts aren't backfilled
+			continue
+		}
+		c := ap.CreateNew(a.apNewID(bc), a.toAPNote(p))
+		c.Actor = a.apAPIri(bc)
+		c.Published = time.Now()
+		for _, inbox := range inboxes {
+			if err = a.apQueueSendSigned(a.apIri(bc), inbox, c); err != nil {
+				return sent, err
+			}
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (a *goBlog) apBackfillHandler(w http.ResponseWriter, r *http.Request) {
+	blog := chi.URLParam(r, "blog")
+	if _, ok := a.cfg.Blogs[blog]; !ok || a.isProtectedBlog(blog) {
+		a.serve404(w, r)
+		return
+	}
+	count := stringToInt(r.FormValue("count"))
+	if count <= 0 {
+		count = defaultApBackfillCount
+	}
+	count = lo.Min([]int{count, maxApBackfillCount})
+	followers, err := a.db.apGetAllFollowers(blog)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if follower := r.FormValue("follower"); follower != "" {
+		// Only a specific follower
+		followers = lo.Filter(followers, func(f *apFollower, _ int) bool { return f.follower == follower })
+		if len(followers) == 0 {
+			a.serveError(w, r, "Follower not found", http.StatusBadRequest)
+			return
+		}
+	}
+	sent, err := a.apBackfill(blog, count, lo.Map(followers, func(f *apFollower, _ int) string { return f.inbox })...)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.logger("activitypub").Info("Backfilled posts", "blog", blog, "posts", sent, "followers", len(followers))
+	http.Redirect(w, r, "/activitypub/followers/"+blog, http.StatusFound)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apBackfill(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.ActivityPub.Enabled = true
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/one", Published: "2023-01-01T10:00:00Z", Content: "One"},
+		{Path: "/two", Published: "2023-01-02T10:00:00Z", Content: "Two"},
+		{Path: "/three", Published: "2023-01-03T10:00:00Z", Content: "Three"},
+		{Path: "/private", Published: "2023-01-04T10:00:00Z", Content: "Private", Visibility: visibilityPrivate},
+	} {
+		p.Section, p.Status = "posts", statusPublished
+		if p.Visibility == "" {
+			p.Visibility = visibilityPublic
+		}
+		require.NoError(t, app.createPost(p))
+	}
+	// Followers of a newly federated blog
+	require.NoError(t, app.db.apAddFollower("default", "https://a.example/users/1", "https://a.example/inbox", "@1@a.example"))
+	require.NoError(t, app.db.apAddFollower("default", "https://b.example/users/2", "https://b.example/users/2/inbox", "@2@b.example"))
+
+	queued := func() (items []*apRequest) {
+		rows, err := app.db.Query("select content from queue where name = 'ap' order by id")
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var content []byte
+			require.NoError(t, rows.Scan(&content))
+			r := &apRequest{}
+			require.NoError(t, gob.NewDecoder(bytes.NewReader(content)).Decode(r))
+			items = append(items, r)
+		}
+		_, err = app.db.Exec("delete from queue where name = 'ap'")
+		require.NoError(t, err)
+		return
+	}
+	_ = queued()
+
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	backfill := func(form url.Values) int {
+		var status int
+		_ = requests.URL("https://example.com/activitypub/backfill/default").Client(client).
+			BasicAuth("test", "test").
+			BodyForm(form).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			Fetch(context.Background())
+		return status
+	}
+
+	// The followers page has the backfill forms when logged in
+	var body string
+	require.NoError(t, requests.URL("https://example.com/activitypub/followers/default").Client(client).BasicAuth("test", "test").ToString(&body).Fetch(context.Background()))
+	assert.Contains(t, body, "action=/activitypub/backfill/default")
+	assert.Contains(t, body, "value=https://b.example/users/2")
+
+	// Last two public posts to one follower, oldest first
+	assert.Equal(t, http.StatusFound, backfill(url.Values{"follower": {"https://b.example/users/2"}, "count": {"2"}}))
+	sent := queued()
+	require.Len(t, sent, 2)
+	for _, r := range sent {
+		assert.Equal(t, "https://b.example/users/2/inbox", r.To)
+		assert.Equal(t, "https://example.com", r.BlogIri)
+		assert.Contains(t, string(r.Activity), `"type":"Create"`)
+	}
+	assert.Contains(t, string(sent[0].Activity), "https://example.com/two")
+	assert.Contains(t, string(sent[1].Activity), "https://example.com/three")
+
+	// All public posts to all followers
+	assert.Equal(t, http.StatusFound, backfill(url.Values{}))
+	sent = queued()
+	require.Len(t, sent, 6)
+	for _, r := range sent {
+		assert.NotContains(t, string(r.Activity), "https://example.com/private")
+	}
+
+	// Unknown follower
+	assert.Equal(t, http.StatusBadRequest, backfill(url.Values{"follower": {"https://c.example/users/3"}}))
+	assert.Empty(t, queued())
+}
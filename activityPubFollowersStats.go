@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Follows and unfollows are recorded by database triggers in activitypub_followers_history
+
+type apFollowersMonth struct {
+	month          string
+	added, removed int
+	total          int
+}
+
+type apFollowersInstance struct {
+	domain string
+	count  int
+}
+
+// Followers gained and lost per month and the total at the end of each month
+func (db *database) apFollowersHistory(blog string) ([]*apFollowersMonth, error) {
+	rows, err := db.Query(`
+		select substr(time, 1, 7) as month, sum(action = 'add'), sum(action = 'remove')
+		from activitypub_followers_history
+		where blog = @blog
+		group by month
+		order by month
+	`, sql.Named("blog", blog))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var months []*apFollowersMonth
+	total := 0
+	for rows.Next() {
+		m := &apFollowersMonth{}
+		if err = rows.Scan(&m.month, &m.added, &m.removed); err != nil {
+			return nil, err
+		}
+		total += m.added - m.removed
+		m.total = total
+		months = append(months, m)
+	}
+	return months, rows.Err()
+}
+
+// Current followers grouped by the domain of their instance, sorted by count
+func (db *database) apFollowersInstances(blog string) ([]*apFollowersInstance, error) {
+	followers, err := db.apGetAllFollowers(blog)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, f := range followers {
+		if u, err := url.Parse(f.follower); err == nil && u.Hostname() != "" {
+			counts[strings.ToLower(u.Hostname())]++
+		}
+	}
+	instances := make([]*apFollowersInstance, 0, len(counts))
+	for domain, count := range counts {
+		instances = append(instances, &apFollowersInstance{domain: domain, count: count})
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].count != instances[j].count {
+			return instances[i].count > instances[j].count
+		}
+		return instances[i].domain < instances[j].domain
+	})
+	return instances, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apFollowersStats(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.ActivityPub.Enabled = true
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang: "en",
+			BlogStats: &configBlogStats{
+				Enabled: true,
+				Path:    "/stats",
+			},
+		},
+	}
+	app.cfg.DefaultBlog = "en"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	app.initSessions()
+
+	require.NoError(t, app.db.apAddFollower("en", "https://a.example/users/1", "https://a.example/inbox", "@1@a.example"))
+	require.NoError(t, app.db.apAddFollower("en", "https://a.example/users/2", "https://a.example/inbox", "@2@a.example"))
+	require.NoError(t, app.db.apAddFollower("en", "https://b.example/users/3", "https://b.example/inbox", "@3@b.example"))
+	// Updating a follower doesn't count as a new follow
+	require.NoError(t, app.db.apAddFollower("en", "https://a.example/users/1", "https://a.example/inbox", "@one@a.example"))
+	require.NoError(t, app.db.apRemoveFollower("en", "https://a.example/users/2"))
+
+	history, err := app.db.apFollowersHistory("en")
+	require.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, 3, history[0].added)
+		assert.Equal(t, 1, history[0].removed)
+		assert.Equal(t, 2, history[0].total)
+	}
+
+	instances, err := app.db.apFollowersInstances("en")
+	require.NoError(t, err)
+	if assert.Len(t, instances, 2) {
+		assert.Equal(t, "a.example", instances[0].domain)
+		assert.Equal(t, 1, instances[0].count)
+	}
+
+	// Removing an inbox removes its followers
+	require.NoError(t, app.db.apRemoveInbox("https://b.example/inbox"))
+	history, err = app.db.apFollowersHistory("en")
+	require.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, 2, history[0].removed)
+		assert.Equal(t, 1, history[0].total)
+	}
+
+	// Stats page
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req = req.WithContext(context.WithValue(req.Context(), blogKey, "en"))
+	app.serveBlogStats(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "followerschart")
+	assert.Contains(t, body, "<td class=tal>a.example<td class=tar>1")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit log of all activities received in the inboxes with the outcome, to debug missing replies or follows
+
+const (
+	apInboxLogPath             = "/inboxlog"
+	defaultApInboxLogRetention = 30   // days
+	defaultApInboxLogLimit     = 1000 // entries per blog
+	apInboxLogPageSize         = 100
+	apInboxLogMaxFieldLength   = 500
+)
+
+type apInboxLogEntry struct {
+	id                                   int
+	blog, time                           string
+	activityType, actor, object, outcome string
+}
+
+func (e *apInboxLogEntry) fill(activity *ap.Activity) {
+	e.activityType = string(activity.GetType())
+	if activity.Actor != nil {
+		e.actor = activity.Actor.GetLink().String()
+	}
+	if activity.Object != nil {
+		e.object = activity.Object.GetLink().String()
+	}
+}
+
+func (a *goBlog) apInboxLogRetention() (days, limit int) {
+	days, limit = defaultApInboxLogRetention, defaultApInboxLogLimit
+	if apc := a.cfg.ActivityPub; apc != nil {
+		if apc.InboxLogRetention > 0 {
+			days = apc.InboxLogRetention
+		}
+		if apc.InboxLogLimit > 0 {
+			limit = apc.InboxLogLimit
+		}
+	}
+	return
+}
+
+func (a *goBlog) initAPInboxLog() {
+	a.hourlyHooks = append(a.hourlyHooks, func() {
+		days, limit := a.apInboxLogRetention()
+		if err := a.db.apTrimInboxLog(days, limit); err != nil {
+			a.logger("activitypub").Error("Failed to trim inbox log", "err", err)
+		}
+	})
+}
+
+func (db *database) apLogInboxActivity(e *apInboxLogEntry) {
+	if e.outcome == "" {
+		e.outcome = "unknown"
+	}
+	trim := func(s string) string { return truncateStringWithEllipsis(s, apInboxLogMaxFieldLength) }
+	_, _ = db.Exec(
+		"insert into activitypub_inbox_log (blog, time, type, actor, object, outcome) values (@blog, @time, @type, @actor, @object, @outcome)",
+		sql.Named("blog", e.blog), sql.Named("time", utcNowString()), sql.Named("type", trim(e.activityType)),
+		sql.Named("actor", trim(e.actor)), sql.Named("object", trim(e.object)), sql.Named("outcome", trim(e.outcome)),
+	)
+}
+
+// Delete entries older than the retention days and all but the newest entries of each blog
+func (db *database) apTrimInboxLog(days, limit int) error {
+	_, err := db.Exec(
+		"delete from activitypub_inbox_log where time < @since",
+		sql.Named("since", time.Now().UTC().AddDate(0, 0, -days).Format(time.RFC3339)),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		"delete from activitypub_inbox_log where id in (select id from (select id, row_number() over (partition by blog order by id desc) as n from activitypub_inbox_log) where n > @limit)",
+		sql.Named("limit", limit),
+	)
+	return err
+}
+
+// Newest entries first, optionally only with the actor or object containing the filter
+func (db *database) apGetInboxLog(blog, filter string, limit int) ([]*apInboxLogEntry, error) {
+	rows, err := db.Query(
+		"select id, blog, time, type, actor, object, outcome from activitypub_inbox_log where blog = @blog and (@filter = '' or instr(actor, @filter) > 0 or instr(object, @filter) > 0) order by id desc limit @limit",
+		sql.Named("blog", blog), sql.Named("filter", filter), sql.Named("limit", limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []*apInboxLogEntry
+	for rows.Next() {
+		e := &apInboxLogEntry{}
+		if err = rows.Scan(&e.id, &e.blog, &e.time, &e.activityType, &e.actor, &e.object, &e.outcome); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+type activityPubInboxLogRenderData struct {
+	filter  string
+	entries []*apInboxLogEntry
+}
+
+func (a *goBlog) apShowInboxLog(w http.ResponseWriter, r *http.Request) {
+	blogName := chi.URLParam(r, "blog")
+	if _, ok := a.cfg.Blogs[blogName]; !ok || a.isProtectedBlog(blogName) {
+		a.serve404(w, r)
+		return
+	}
+	filter := r.URL.Query().Get("filter")
+	entries, err := a.db.apGetInboxLog(blogName, filter, apInboxLogPageSize)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.render(w, r, a.renderActivityPubInboxLog, &renderData{
+		BlogString: blogName,
+		Data: &activityPubInboxLogRenderData{
+			filter:  filter,
+			entries: entries,
+		},
+	})
+}
+
+func (e *apInboxLogEntry) localTime() string {
+	if t := toLocalTime(e.time); !t.IsZero() {
+		return t.Format(time.DateTime)
+	}
+	return e.time
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apInboxLog(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.ActivityPub.Enabled = true
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	require.NoError(t, app.initActivityPub())
+	app.d = app.buildRouter()
+
+	// The blog itself is the remote actor
+	rec := httptest.NewRecorder()
+	app.serveActivityStreams(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "default")
+	actor := rec.Body.Bytes()
+	fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(actor)
+	}))
+
+	postInbox := func(activity string, sign bool) int {
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/activitypub/inbox/default", strings.NewReader(activity))
+		if sign {
+			require.NoError(t, app.signRequest(req, "https://example.com"))
+		}
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, postInbox(`{"type":"Like","actor":"https://example.com","object":"https://example.com/liked"}`, true))
+	assert.Equal(t, http.StatusUnauthorized, postInbox(`{"type":"Create","actor":"https://other.example/users/1","object":"https://other.example/notes/1"}`, false))
+	assert.Equal(t, http.StatusOK, postInbox(`{"type":"Create","actor":"https://example.com","object":{"type":"Note","id":"https://example.com/notes/2","content":"Hi"}}`, true))
+
+	entries, err := app.db.apGetInboxLog("default", "", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	// Newest first
+	assert.Equal(t, "Create", entries[0].activityType)
+	assert.Equal(t, "ignored: not a reply to this blog", entries[0].outcome)
+	assert.Equal(t, "https://other.example/users/1", entries[1].actor)
+	assert.Equal(t, "https://other.example/notes/1", entries[1].object)
+	assert.True(t, strings.HasPrefix(entries[1].outcome, "rejected (401)"))
+	assert.Equal(t, "Like", entries[2].activityType)
+	assert.Equal(t, "notification sent", entries[2].outcome)
+
+	entries, err = app.db.apGetInboxLog("default", "other.example", 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// The viewer requires a login
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	var body string
+	_ = requests.URL("https://example.com/activitypub/inboxlog/default").Client(client).ToString(&body).Fetch(context.Background())
+	assert.NotContains(t, body, "notification sent")
+	require.NoError(t, requests.URL("https://example.com/activitypub/inboxlog/default?filter=liked").Client(client).BasicAuth("test", "test").ToString(&body).Fetch(context.Background()))
+	assert.Contains(t, body, "Inbox log")
+	assert.Contains(t, body, "notification sent")
+	assert.NotContains(t, body, "other.example")
+
+	// Only the newest entries are kept
+	require.NoError(t, app.db.apTrimInboxLog(30, 2))
+	entries, err = app.db.apGetInboxLog("default", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	_, err = app.db.Exec("update activitypub_inbox_log set time = '2020-01-01T00:00:00Z' where type = 'Create' and actor = 'https://example.com'")
+	require.NoError(t, err)
+	require.NoError(t, app.db.apTrimInboxLog(30, 2))
+	entries, err = app.db.apGetInboxLog("default", "", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "https://other.example/users/1", entries[0].actor)
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-fed/httpsig"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bodylimit"
+)
+
+// Every blog has its own key, generated on first use and stored in the database.
+// A key can be rotated: a new key is generated and used to sign all following requests,
+// the previous public key is still served for a grace period and followers get the updated profile
+
+const (
+	// Key shared by all blogs before every blog got its own key, migrated on start
+	apLegacyKeyCacheKey         = "activitypub_key"
+	apLegacyKeyRotationCacheKey = "activitypub_key_rotation"
+
+	apKeyCacheKeyPrefix         = "activitypub_key:"
+	apKeyRotationCacheKeyPrefix = "activitypub_key_rotation:"
+
+	apDefaultKeyId          = "main-key"
+	defaultApKeyGracePeriod = 7 // days
+
+	apRotateKeyPath = "/rotatekey"
+)
+
+// Loaded keys of the blogs and the signer, shared with the instances of reloaded configs
+type apKeyStore struct {
+	mutex  sync.Mutex
+	keys   map[string]*apBlogKey
+	signer httpsig.Signer
+}
+
+// Key of a blog
+type apBlogKey struct {
+	privateKey  *rsa.PrivateKey
+	pubKeyBytes []byte
+	keyId       string
+	rotation    *apKeyRotation
+}
+
+// Persisted state of the last key rotation
+type apKeyRotation struct {
+	KeyId             string    `json:"keyId"`
+	PreviousKeyId     string    `json:"previousKeyId"`
+	PreviousPublicKey []byte    `json:"previousPublicKey"`
+	Rotated           time.Time `json:"rotated"`
+}
+
+func apKeyCacheKey(blog string) string {
+	return apKeyCacheKeyPrefix + blog
+}
+
+func apKeyRotationCacheKey(blog string) string {
+	return apKeyRotationCacheKeyPrefix + blog
+}
+
+// Copy the key shared by all blogs to every blog that has no own key yet, so the followers keep working
+func (a *goBlog) migrateActivityPubKey() error {
+	keyData, err := a.db.retrievePersistentCache(apLegacyKeyCacheKey)
+	if err != nil || keyData == nil {
+		return err
+	}
+	rotationData, err := a.db.retrievePersistentCache(apLegacyKeyRotationCacheKey)
+	if err != nil {
+		return err
+	}
+	for _, blog := range sortedStrings(lo.Keys(a.cfg.Blogs)) {
+		existing, err := a.db.retrievePersistentCache(apKeyCacheKey(blog))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+		if err = a.db.cachePersistently(apKeyCacheKey(blog), keyData); err != nil {
+			return err
+		}
+		if rotationData != nil {
+			if err = a.db.cachePersistently(apKeyRotationCacheKey(blog), rotationData); err != nil {
+				return err
+			}
+		}
+	}
+	if err = a.db.clearPersistentCache(apLegacyKeyRotationCacheKey); err != nil {
+		return err
+	}
+	a.logger("activitypub").Info("Migrated shared key to the blogs")
+	return a.db.clearPersistentCache(apLegacyKeyCacheKey)
+}
+
+// Get the key of the blog, load it from the database or generate it on first use
+func (a *goBlog) apKey(blog string) (*apBlogKey, error) {
+	a.apKeys.mutex.Lock()
+	defer a.apKeys.mutex.Unlock()
+	return a.apKeyLocked(blog)
+}
+
+func (a *goBlog) apKeyLocked(blog string) (*apBlogKey, error) {
+	if key, ok := a.apKeys.keys[blog]; ok {
+		return key, nil
+	}
+	if _, ok := a.cfg.Blogs[blog]; !ok {
+		return nil, fmt.Errorf("unknown blog %s", blog)
+	}
+	key, err := a.loadActivityPubKey(blog)
+	if err != nil {
+		return nil, err
+	}
+	if a.apKeys.keys == nil {
+		a.apKeys.keys = map[string]*apBlogKey{}
+	}
+	a.apKeys.keys[blog] = key
+	return key, nil
+}
+
+func (a *goBlog) loadActivityPubKey(blog string) (*apBlogKey, error) {
+	// Check if already generated
+	if keyData, err := a.db.retrievePersistentCache(apKeyCacheKey(blog)); err == nil && keyData != nil {
+		privateKeyDecoded, _ := pem.Decode(keyData)
+		if privateKeyDecoded == nil {
+			a.logger("activitypub").Warn("Failed to decode cached private key", "blog", blog)
+			// continue
+		} else {
+			privateKey, err := x509.ParsePKCS1PrivateKey(privateKeyDecoded.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			pubKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			key := &apBlogKey{privateKey: privateKey, pubKeyBytes: pubKeyBytes, keyId: apDefaultKeyId}
+			return key, a.loadActivityPubKeyRotation(blog, key)
+		}
+	}
+	// Generate and cache key
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	a.logger("activitypub").Info("Generated key", "blog", blog)
+	return &apBlogKey{privateKey: privateKey, pubKeyBytes: pubKeyBytes, keyId: apDefaultKeyId}, a.db.cachePersistently(
+		apKeyCacheKey(blog),
+		pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}),
+	)
+}
+
+func (a *goBlog) loadActivityPubKeyRotation(blog string, key *apBlogKey) error {
+	data, err := a.db.retrievePersistentCache(apKeyRotationCacheKey(blog))
+	if err != nil || data == nil {
+		return err
+	}
+	rotation := &apKeyRotation{}
+	if err = json.Unmarshal(data, rotation); err != nil {
+		return err
+	}
+	key.keyId = rotation.KeyId
+	key.rotation = rotation
+	return nil
+}
+
+func (a *goBlog) apKeyGracePeriod() time.Duration {
+	days := defaultApKeyGracePeriod
+	if apc := a.cfg.ActivityPub; apc != nil && apc.KeyGracePeriod > 0 {
+		days = apc.KeyGracePeriod
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Generate a new key for the blog, sign all following requests with it and send the new key to the followers
+func (a *goBlog) apRotateKey(blog string) error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	a.apKeys.mutex.Lock()
+	oldKey, err := a.apKeyLocked(blog)
+	if err != nil {
+		a.apKeys.mutex.Unlock()
+		return err
+	}
+	rotation := &apKeyRotation{
+		KeyId:             "key-" + strconv.FormatInt(now.Unix(), 10),
+		PreviousKeyId:     oldKey.keyId,
+		PreviousPublicKey: oldKey.pubKeyBytes,
+		Rotated:           now,
+	}
+	rotationData, err := json.Marshal(rotation)
+	if err == nil {
+		// Save the key first, so a failure afterwards only keeps the old key id
+		err = a.db.cachePersistently(apKeyCacheKey(blog), pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}))
+	}
+	if err == nil {
+		err = a.db.cachePersistently(apKeyRotationCacheKey(blog), rotationData)
+	}
+	if err == nil {
+		a.apKeys.keys[blog] = &apBlogKey{privateKey: privateKey, pubKeyBytes: pubKeyBytes, keyId: rotation.KeyId, rotation: rotation}
+	}
+	a.apKeys.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	a.logger("activitypub").Info("Rotated key", "blog", blog, "key", rotation.KeyId, "previous", rotation.PreviousKeyId)
+	// Remove the cached profiles and send the updated profile with the new key
+	a.cache.purge()
+	_, err = a.apQueueToAllFollowers(blog, a.apProfileUpdate(blog))
+	return err
+}
+
+func (a *goBlog) apRotateKeyHandler(w http.ResponseWriter, r *http.Request) {
+	blog := chi.URLParam(r, "blog")
+	if _, ok := a.cfg.Blogs[blog]; !ok || a.isProtectedBlog(blog) {
+		a.serve404(w, r)
+		return
+	}
+	if err := a.apRotateKey(blog); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The public keys of the blog actor, the current key first and the previous key during the grace period
+func (a *goBlog) apPublicKeys(blogName string) []ap.PublicKey {
+	key, err := a.apKey(blogName)
+	if err != nil {
+		a.logger("activitypub").Error("Failed to load key", "blog", blogName, "err", err)
+		return nil
+	}
+	blog := a.cfg.Blogs[blogName]
+	apIri := a.apAPIri(blog)
+	keys := []ap.PublicKey{{
+		ID:           ap.IRI(a.apIri(blog) + "#" + key.keyId),
+		Owner:        apIri,
+		PublicKeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: key.pubKeyBytes})),
+	}}
+	if kr := key.rotation; kr != nil && len(kr.PreviousPublicKey) > 0 && time.Since(kr.Rotated) < a.apKeyGracePeriod() {
+		keys = append(keys, ap.PublicKey{
+			ID:           ap.IRI(a.apIri(blog) + "#" + kr.PreviousKeyId),
+			Owner:        apIri,
+			PublicKeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: kr.PreviousPublicKey})),
+		})
+	}
+	return keys
+}
+
+// Actor with multiple public keys, encoded as an array (most implementations only use the first key)
+type apMultiKeyPerson struct {
+	*ap.Person
+	keys []ap.PublicKey
+}
+
+func (p *apMultiKeyPerson) MarshalJSON() ([]byte, error) {
+	data, err := p.Person.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m["publicKey"], err = json.Marshal(p.keys); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// Public key of a remote actor, the actor document can contain one or multiple keys
+type apRemotePublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Get the remote actor and all of its public keys
+func (a *goBlog) apGetRemoteActorKeys(iri ap.IRI, blog string) (*ap.Actor, []*apRemotePublicKey, error) {
+	resp, err := a.apHttpClients[blog].CtxGet(context.Background(), iri.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*bodylimit.MB))
+	if err != nil {
+		return nil, nil, err
+	}
+	item, err := ap.UnmarshalJSON(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	actor, err := ap.ToActor(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc struct {
+		PublicKey json.RawMessage `json:"publicKey"`
+	}
+	if err = json.Unmarshal(body, &doc); err != nil || len(doc.PublicKey) == 0 {
+		return actor, nil, err
+	}
+	var keys []*apRemotePublicKey
+	if err = json.Unmarshal(doc.PublicKey, &keys); err != nil {
+		key := &apRemotePublicKey{}
+		if err = json.Unmarshal(doc.PublicKey, key); err != nil {
+			return actor, nil, err
+		}
+		keys = []*apRemotePublicKey{key}
+	}
+	return actor, keys, nil
+}
+
+// Select the key with the ID used for the signature, or the first key
+func apSelectPublicKey(keys []*apRemotePublicKey, keyId string) (*rsa.PublicKey, error) {
+	var selected *apRemotePublicKey
+	for _, key := range keys {
+		if key == nil || key.PublicKeyPem == "" {
+			continue
+		}
+		if key.ID == keyId {
+			selected = key
+			break
+		}
+		if selected == nil {
+			selected = key
+		}
+	}
+	if selected == nil {
+		return nil, errors.New("actor has no public key")
+	}
+	block, _ := pem.Decode([]byte(selected.PublicKeyPem))
+	if block == nil {
+		return nil, errors.New("public key invalid")
+	}
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := pubKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key isn't an RSA key")
+	}
+	return rsaKey, nil
+}
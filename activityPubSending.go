@@ -6,7 +6,6 @@ import (
 	"encoding/gob"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"time"
 
@@ -23,14 +22,19 @@ type apRequest struct {
 }
 
 func (a *goBlog) initAPSendQueue() {
-	a.listenOnQueue("ap", 30*time.Second, func(qi *queueItem, dequeue func(), reschedule func(time.Duration)) {
+	a.listenOnQueue("ap", 30*time.Second, func(ctx context.Context, qi *queueItem, dequeue func(), reschedule func(time.Duration)) {
 		var r apRequest
 		if err := gob.NewDecoder(bytes.NewReader(qi.content)).Decode(&r); err != nil {
-			log.Println("activitypub queue:", err.Error())
+			a.logger("activitypub").Error("Failed to decode queue item", "err", err)
 			dequeue()
 			return
 		}
-		if err := a.apSendSigned(r.BlogIri, r.To, r.Activity); err != nil {
+		if err := a.apSendSigned(ctx, r.BlogIri, r.To, r.Activity); err != nil {
+			if ctx.Err() != nil {
+				// Shutting down, keep request in the queue
+				return
+			}
+			a.getMetrics().apFailures.Add(1)
 			if r.Try++; r.Try < 20 {
 				// Try it again
 				buf := bufferpool.Get()
@@ -40,8 +44,10 @@ func (a *goBlog) initAPSendQueue() {
 				bufferpool.Put(buf)
 				return
 			}
-			log.Println("AP request failed for the 20th time:", r.To)
+			a.logger("activitypub").Warn("Request failed for the 20th time, removing inbox", "inbox", r.To)
+			a.sendNotification(notificationTypeError, fmt.Sprintf("ActivityPub request to %s failed for the 20th time, removed inbox", r.To))
 			_ = a.db.apRemoveInbox(r.To)
+			a.getMetrics().apRemovedInbox.Add(1)
 		}
 		dequeue()
 	})
@@ -68,9 +74,9 @@ func (r *apRequest) encode(w io.Writer) error {
 	return gob.NewEncoder(w).Encode(r)
 }
 
-func (a *goBlog) apSendSigned(blogIri, to string, activity []byte) error {
+func (a *goBlog) apSendSigned(ctx context.Context, blogIri, to string, activity []byte) error {
 	// Create request context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
 	// Create request
 	r, err := http.NewRequestWithContext(ctx, http.MethodPost, to, bytes.NewReader(activity))
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,49 +13,25 @@ import (
 	"github.com/carlmjohnson/requests"
 	"github.com/go-chi/chi/v5"
 	"go.goblog.app/app/pkgs/bodylimit"
+	"go.goblog.app/app/pkgs/contenttype"
 )
 
+const apRemoteFollowPath = "/activitypub/remote_follow"
+
 func (a *goBlog) apRemoteFollow(w http.ResponseWriter, r *http.Request) {
 	blogName := chi.URLParam(r, "blog")
 	blog, ok := a.cfg.Blogs[blogName]
-	if !ok || blog == nil {
+	if !ok || blog == nil || blog.isProtected() {
 		a.serveError(w, r, "Blog not found", http.StatusNotFound)
 		return
 	}
 	if user := r.FormValue("user"); user != "" {
-		// Parse instance
-		userParts := strings.Split(user, "@")
-		if len(userParts) < 2 {
+		// Get webfinger
+		webfinger, err := a.apWebfinger(r.Context(), user)
+		if errors.Is(err, errInvalidFediverseHandle) {
 			a.serveError(w, r, "User must be of the form user@example.org or @user@example.org", http.StatusBadRequest)
 			return
-		}
-		user = userParts[len(userParts)-2]
-		instance := userParts[len(userParts)-1]
-		if user == "" || instance == "" {
-			a.serveError(w, r, "User or instance are empty", http.StatusBadRequest)
-			return
-		}
-		// Get webfinger
-		type webfingerLinkType struct {
-			Rel      string `json:"rel"`
-			Template string `json:"template"`
-		}
-		type webfingerType struct {
-			Links []*webfingerLinkType `json:"links"`
-		}
-		webfinger := &webfingerType{}
-		pr, pw := io.Pipe()
-		go func() {
-			err := requests.
-				URL(fmt.Sprintf("https://%s/.well-known/webfinger?resource=acct:%s@%s", instance, user, instance)).
-				Client(a.httpClient).
-				ToWriter(pw).
-				Fetch(r.Context())
-			_ = pw.CloseWithError(err)
-		}()
-		err := json.NewDecoder(io.LimitReader(pr, 100*bodylimit.KB)).Decode(webfinger)
-		_ = pr.CloseWithError(err)
-		if err != nil {
+		} else if err != nil {
 			a.serveError(w, r, "Failed to query webfinger", http.StatusInternalServerError)
 			return
 		}
@@ -79,3 +57,67 @@ func (a *goBlog) apRemoteFollow(w http.ResponseWriter, r *http.Request) {
 		BlogString: blogName,
 	})
 }
+
+type apWebfingerLink struct {
+	Rel      string `json:"rel"`
+	Type     string `json:"type"`
+	Href     string `json:"href"`
+	Template string `json:"template"`
+}
+
+type apWebfingerResult struct {
+	Links []*apWebfingerLink `json:"links"`
+}
+
+var errInvalidFediverseHandle = errors.New("invalid fediverse handle")
+
+// Split a handle like user@example.org or @user@example.org
+func splitFediverseHandle(handle string) (user, instance string, err error) {
+	parts := strings.Split(handle, "@")
+	if len(parts) < 2 {
+		return "", "", errInvalidFediverseHandle
+	}
+	user, instance = parts[len(parts)-2], parts[len(parts)-1]
+	if user == "" || instance == "" {
+		return "", "", errInvalidFediverseHandle
+	}
+	return user, instance, nil
+}
+
+// Query the webfinger of a fediverse handle
+func (a *goBlog) apWebfinger(ctx context.Context, handle string) (*apWebfingerResult, error) {
+	user, instance, err := splitFediverseHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	webfinger := &apWebfingerResult{}
+	pr, pw := io.Pipe()
+	go func() {
+		err := requests.
+			URL(fmt.Sprintf("https://%s/.well-known/webfinger?resource=acct:%s@%s", instance, user, instance)).
+			Client(a.httpClient).
+			ToWriter(pw).
+			Fetch(ctx)
+		_ = pw.CloseWithError(err)
+	}()
+	err = json.NewDecoder(io.LimitReader(pr, 100*bodylimit.KB)).Decode(webfinger)
+	_ = pr.CloseWithError(err)
+	if err != nil {
+		return nil, err
+	}
+	return webfinger, nil
+}
+
+// Resolve a fediverse handle to the IRI of the actor
+func (a *goBlog) apResolveHandle(ctx context.Context, handle string) (string, error) {
+	webfinger, err := a.apWebfinger(ctx, handle)
+	if err != nil {
+		return "", err
+	}
+	for _, link := range webfinger.Links {
+		if link.Rel == "self" && link.Href != "" && (link.Type == contenttype.AS || strings.HasPrefix(link.Type, contenttype.LDJSON)) {
+			return link.Href, nil
+		}
+	}
+	return "", errors.New("no ActivityPub actor found for " + handle)
+}
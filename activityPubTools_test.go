@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apRemoteFollow(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.ActivityPub.Enabled = true
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{
+		Path:    "/testpost",
+		Section: "posts",
+		Status:  statusPublished,
+		Content: "Test",
+	}))
+
+	fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "mastodon.example" && r.URL.Path == "/.well-known/webfinger" && r.URL.Query().Get("resource") == "acct:alice@mastodon.example" {
+			_, _ = w.Write([]byte(`{"links":[{"rel":"http://ostatus.org/schema/1.0/subscribe","template":"https://mastodon.example/authorize_interaction?uri={uri}"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	get := func(path string) (int, string) {
+		var body string
+		var status int
+		_ = requests.URL("https://example.com" + path).Client(client).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			ToString(&body).
+			Fetch(context.Background())
+		return status, body
+	}
+
+	// Follow button on blog pages
+	for _, path := range []string{"/", "/testpost"} {
+		_, body := get(path)
+		assert.Contains(t, body, "href=/activitypub/remote_follow/default", path)
+		assert.Contains(t, body, "Follow on the Fediverse", path)
+	}
+
+	// Form
+	status, body := get("/activitypub/remote_follow/default")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "name=user")
+	status, _ = get("/activitypub/remote_follow/unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Redirect to the subscribe template of the instance
+	var location string
+	err := requests.URL("https://example.com/activitypub/remote_follow/default").Client(client).
+		BodyForm(url.Values{"user": {"@alice@mastodon.example"}}).
+		AddValidator(func(r *http.Response) error {
+			assert.Equal(t, http.StatusFound, r.StatusCode)
+			location = r.Header.Get("Location")
+			return nil
+		}).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "https://mastodon.example/authorize_interaction?uri="+url.PathEscape("https://example.com"), location)
+
+	// Invalid handle
+	err = requests.URL("https://example.com/activitypub/remote_follow/default").Client(client).
+		BodyForm(url.Values{"user": {"alice"}}).
+		CheckStatus(http.StatusBadRequest).
+		Fetch(context.Background())
+	assert.NoError(t, err)
+}
@@ -1,47 +1,89 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/go-fed/httpsig"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.goblog.app/app/pkgs/contenttype"
 )
 
-func Test_loadActivityPubPrivateKey(t *testing.T) {
+func Test_loadActivityPubPrivateKeys(t *testing.T) {
 
 	app := &goBlog{
 		cfg: createDefaultTestConfig(t),
 	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {Path: "/en"},
+		"de": {Path: "/de"},
+	}
+	app.cfg.DefaultBlog = "en"
 	err := app.initConfig(false)
 	require.NoError(t, err)
 	require.NotNil(t, app.db)
 
-	// Generate
-	err = app.loadActivityPubPrivateKey()
+	// Key shared by all blogs, created by an older version
+	legacyKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	legacyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(legacyKey)})
+	require.NoError(t, app.db.cachePersistently(apLegacyKeyCacheKey, legacyPem))
+	// The German blog already has its own key
+	deKey, err := app.loadActivityPubKey("de")
 	require.NoError(t, err)
 
-	assert.NotNil(t, app.apPrivateKey)
-	assert.NotEmpty(t, app.apPubKeyBytes)
-
-	oldEncodedKey := x509.MarshalPKCS1PrivateKey(app.apPrivateKey)
-	oldPemEncoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: oldEncodedKey})
+	err = app.loadActivityPubPrivateKeys()
+	require.NoError(t, err)
 
-	// Reset and reload
-	err = app.loadActivityPubPrivateKey()
+	enKey, err := app.apKey("en")
+	require.NoError(t, err)
+	assert.True(t, legacyKey.Equal(enKey.privateKey))
+	assert.Equal(t, apDefaultKeyId, enKey.keyId)
+	loadedDeKey, err := app.apKey("de")
 	require.NoError(t, err)
+	assert.True(t, deKey.privateKey.Equal(loadedDeKey.privateKey))
+	assert.False(t, legacyKey.Equal(loadedDeKey.privateKey))
 
-	assert.NotNil(t, app.apPrivateKey)
-	assert.NotEmpty(t, app.apPubKeyBytes)
+	// The shared key is removed after the migration
+	legacyData, err := app.db.retrievePersistentCache(apLegacyKeyCacheKey)
+	require.NoError(t, err)
+	assert.Nil(t, legacyData)
 
-	newEncodedKey := x509.MarshalPKCS1PrivateKey(app.apPrivateKey)
-	newPemEncoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: newEncodedKey})
+	// Reset and reload
+	app.apKeys = &apKeyStore{}
+	require.NoError(t, app.loadActivityPubPrivateKeys())
+	reloadedEnKey, err := app.apKey("en")
+	require.NoError(t, err)
+	assert.True(t, legacyKey.Equal(reloadedEnKey.privateKey))
 
-	assert.Equal(t, string(oldPemEncoded), string(newPemEncoded))
+	// Requests are signed with the key of the blog
+	app.apKeys.signer, _, err = httpsig.NewSigner([]httpsig.Algorithm{httpsig.RSA_SHA256}, httpsig.DigestSha256, []string{httpsig.RequestTarget, "date", "host", "digest"}, httpsig.Signature, 0)
+	require.NoError(t, err)
+	for blog, key := range map[string]*rsa.PrivateKey{"en": legacyKey, "de": deKey.privateKey} {
+		req := httptest.NewRequest(http.MethodPost, "https://remote.example/inbox", strings.NewReader("{}"))
+		require.NoError(t, app.signRequest(req, app.apIri(app.cfg.Blogs[blog])))
+		verifier, err := httpsig.NewVerifier(req)
+		require.NoError(t, err)
+		assert.Equal(t, app.apIri(app.cfg.Blogs[blog])+"#main-key", verifier.KeyId())
+		assert.NoError(t, verifier.Verify(&key.PublicKey, httpsig.RSA_SHA256), blog)
+	}
 
+	// Unknown blogs have no key
+	_, err = app.apKey("fr")
+	assert.Error(t, err)
+	assert.Error(t, app.signRequest(httptest.NewRequest(http.MethodPost, "https://remote.example/inbox", nil), "https://example.com/fr"))
 }
 
 func Test_webfinger(t *testing.T) {
@@ -49,15 +91,155 @@ func Test_webfinger(t *testing.T) {
 		cfg: createDefaultTestConfig(t),
 	}
 	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.Blogs = map[string]*configBlog{
+		"default": {Path: "/", Lang: "en"},
+		"en": {
+			Path: "/en",
+			Lang: "en",
+			ActivityPub: &configBlogActivityPub{
+				Aliases: []string{"Jane", "jane@example.org"},
+			},
+		},
+	}
+	app.cfg.DefaultBlog = "default"
+	app.cfg.ActivityPub.DefaultBlog = "en"
 
 	_ = app.initConfig(false)
+	app.initMarkdown()
 
 	app.prepareWebfinger()
 
-	req := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:default@example.com", nil)
-	rec := httptest.NewRecorder()
+	webfinger := func(query string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?"+query, nil)
+		req.Header.Set("Accept", contenttype.JSON)
+		rec := httptest.NewRecorder()
+		app.apHandleWebfinger(rec, req)
+		assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+		var jrd map[string]any
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jrd))
+		}
+		return rec, jrd
+	}
 
-	app.apHandleWebfinger(rec, req)
+	rec, jrd := webfinger("resource=acct:default@example.com")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "acct:default@example.com", jrd["subject"])
+	assert.Len(t, jrd["links"], 2)
+
+	// Aliases
+	for _, resource := range []string{"acct:jane@example.com", "acct:JANE@example.org", "@jane@example.org", "https://example.com/en", "acct:en@example.com"} {
+		rec, jrd = webfinger("resource=" + url.QueryEscape(resource))
+		assert.Equal(t, http.StatusOK, rec.Code, resource)
+		assert.Equal(t, "acct:en@example.com", jrd["subject"], resource)
+	}
+	assert.Equal(t, []any{"acct:en@example.com", "acct:jane@example.com", "acct:jane@example.org", "https://example.com/en"}, jrd["aliases"])
 
+	// Bare domain
+	rec, jrd = webfinger("resource=acct:example.com@example.com")
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "acct:en@example.com", jrd["subject"])
+
+	// Link relations
+	_, jrd = webfinger("resource=acct:default@example.com&rel=self")
+	assert.Len(t, jrd["links"], 1)
+
+	// Errors
+	rec, _ = webfinger("")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec, _ = webfinger("resource=invalid")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec, _ = webfinger("resource=acct:unknown@example.com")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func Test_apKeyRotation(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.ActivityPub.Enabled = true
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	require.NoError(t, app.initActivityPub())
+
+	blog := app.cfg.Blogs["default"]
+	key, err := app.apKey("default")
+	require.NoError(t, err)
+	assert.Equal(t, apDefaultKeyId, key.keyId)
+	assert.Len(t, app.apPublicKeys("default"), 1)
+	oldPubKey := key.pubKeyBytes
+
+	require.NoError(t, app.apRotateKey("default"))
+	key, err = app.apKey("default")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key.keyId, "key-"))
+	assert.NotEqual(t, oldPubKey, key.pubKeyBytes)
+
+	// Outgoing requests are signed with the new key
+	req := httptest.NewRequest(http.MethodPost, "https://remote.example/inbox", strings.NewReader("{}"))
+	require.NoError(t, app.signRequest(req, app.apIri(blog)))
+	assert.Contains(t, req.Header.Get("Signature"), `keyId="https://example.com#`+key.keyId+`"`)
+
+	// The actor has both keys
+	rec := httptest.NewRecorder()
+	app.serveActivityStreams(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "default")
+	var actor struct {
+		ID        string `json:"id"`
+		PublicKey []struct {
+			ID           string `json:"id"`
+			Owner        string `json:"owner"`
+			PublicKeyPem string `json:"publicKeyPem"`
+		} `json:"publicKey"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &actor))
+	assert.Equal(t, "https://example.com", actor.ID)
+	require.Len(t, actor.PublicKey, 2)
+	assert.Equal(t, "https://example.com#"+key.keyId, actor.PublicKey[0].ID)
+	assert.Equal(t, "https://example.com#main-key", actor.PublicKey[1].ID)
+	assert.Equal(t, "https://example.com", actor.PublicKey[1].Owner)
+	block, _ := pem.Decode([]byte(actor.PublicKey[1].PublicKeyPem))
+	require.NotNil(t, block)
+	assert.Equal(t, oldPubKey, block.Bytes)
+
+	// Remote actors with multiple keys
+	body := rec.Body.Bytes()
+	fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	remoteActor, keys, err := app.apGetRemoteActorKeys(ap.IRI("https://example.com#main-key"), "default")
+	require.NoError(t, err)
+	assert.Equal(t, ap.IRI("https://example.com"), remoteActor.GetLink())
+	require.Len(t, keys, 2)
+	selected, err := apSelectPublicKey(keys, "https://example.com#main-key")
+	require.NoError(t, err)
+	selectedBytes, _ := x509.MarshalPKIXPublicKey(selected)
+	assert.Equal(t, oldPubKey, selectedBytes)
+	selected, err = apSelectPublicKey(keys, "https://example.com#unknown")
+	require.NoError(t, err)
+	selectedBytes, _ = x509.MarshalPKIXPublicKey(selected)
+	assert.Equal(t, key.pubKeyBytes, selectedBytes)
+	_, err = apSelectPublicKey(nil, "https://example.com#main-key")
+	assert.Error(t, err)
+
+	// The rotation is persisted
+	keyId, pubKey := key.keyId, key.pubKeyBytes
+	app.apKeys = &apKeyStore{signer: app.apKeys.signer}
+	require.NoError(t, app.loadActivityPubPrivateKeys())
+	key, err = app.apKey("default")
+	require.NoError(t, err)
+	assert.Equal(t, keyId, key.keyId)
+	assert.Equal(t, pubKey, key.pubKeyBytes)
+	assert.Len(t, app.apPublicKeys("default"), 2)
+
+	// Only the new key after the grace period
+	key.rotation.Rotated = time.Now().Add(-8 * 24 * time.Hour)
+	assert.Len(t, app.apPublicKeys("default"), 1)
+	rec = httptest.NewRecorder()
+	app.serveActivityStreams(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "default")
+	assert.Contains(t, rec.Body.String(), `"publicKey":{"id":"https://example.com#`+keyId+`"`)
 }
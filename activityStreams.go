@@ -3,16 +3,25 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/pem"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/araddon/dateparse"
 	ct "github.com/elnormous/contenttype"
 	ap "github.com/go-ap/activitypub"
 	"github.com/go-ap/jsonld"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
 	"go.goblog.app/app/pkgs/contenttype"
+	"go.goblog.app/app/pkgs/htmlbuilder"
 )
 
 const asRequestKey contextKey = "asRequest"
@@ -39,7 +48,35 @@ func (a *goBlog) checkActivityStreamsRequest(next http.Handler) http.Handler {
 }
 
 func (a *goBlog) serveActivityStreamsPost(w http.ResponseWriter, r *http.Request, status int, p *post) {
-	a.serveAPItem(w, r, status, a.toAPNote(p))
+	if a.asObjectCache == nil {
+		a.serveAPItem(w, r, status, a.toAPItem(p))
+		return
+	}
+	// Fediverse instances fetch new posts many times right after publishing, so serve them from cache
+	key := asObjectCacheKey(p)
+	itemInterface, err, _ := a.asObjectGroup.Do(key, func() (any, error) {
+		if item, ok := a.asObjectCache.get(key); ok {
+			return item, nil
+		}
+		item, err := a.renderAPItem(a.toAPItem(p))
+		if err != nil {
+			return nil, err
+		}
+		a.asObjectCache.set(key, item, int64(item.cost()))
+		return item, nil
+	})
+	if err != nil {
+		a.serveError(w, r, "Encoding failed", http.StatusInternalServerError)
+		return
+	}
+	item := itemInterface.(*cacheItem)
+	w.Header().Set(contentType, contenttype.ASUTF8)
+	w.Header().Set(cacheControl, "public,max-age=60")
+	if writeNotModified(w, r, item.eTag, item.lastModified) {
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(item.body)
 }
 
 func (a *goBlog) toAPNote(p *post) *ap.Note {
@@ -48,6 +85,10 @@ func (a *goBlog) toAPNote(p *post) *ap.Note {
 	note.ID = a.activityPubId(p)
 	note.URL = ap.IRI(a.fullPostURL(p))
 	note.AttributedTo = a.apAPIri(a.getBlogFromPost(p))
+	if author := a.postAuthor(p); author != nil {
+		// The blog actor stays first, it's the one that signs and sends the activities
+		note.AttributedTo = ap.ItemCollection{note.AttributedTo, a.toAPAuthor(author)}
+	}
 	// Audience
 	switch p.Visibility {
 	case visibilityPublic:
@@ -56,7 +97,8 @@ func (a *goBlog) toAPNote(p *post) *ap.Note {
 		note.To.Append(a.apGetFollowersCollectionId(p.Blog, a.getBlogFromPost(p)))
 		note.CC.Append(ap.PublicNS)
 	}
-	for _, m := range p.Parameters[activityPubMentionsParameter] {
+	mentions := a.apMentionedActors(p)
+	for _, m := range mentions {
 		note.CC.Append(ap.IRI(m))
 	}
 	// Name and Type
@@ -64,6 +106,10 @@ func (a *goBlog) toAPNote(p *post) *ap.Note {
 		note.Type = ap.ArticleType
 		note.Name.Add(ap.DefaultLangRef(title))
 	}
+	// Content warning
+	if cw := a.contentWarning(p); cw != "" {
+		note.Summary.Add(ap.DefaultLangRef(cw))
+	}
 	// Content
 	note.MediaType = ap.MimeType(contenttype.HTML)
 	note.Content.Add(ap.DefaultLangRef(a.postHtml(&postHtmlOptions{p: p, absolute: true, activityPub: true})))
@@ -87,16 +133,11 @@ func (a *goBlog) toAPNote(p *post) *ap.Note {
 		}
 	}
 	// Mentions
-	for _, mention := range p.Parameters[activityPubMentionsParameter] {
+	for _, mention := range mentions {
 		apMention := ap.MentionNew(ap.IRI(mention))
 		apMention.Href = ap.IRI(mention)
 		note.Tag.Append(apMention)
 	}
-	if replyLinkActor := p.firstParameter(activityPubReplyActorParameter); replyLinkActor != "" {
-		apMention := ap.MentionNew(ap.IRI(replyLinkActor))
-		apMention.Href = ap.IRI(replyLinkActor)
-		note.Tag.Append(apMention)
-	}
 	// Dates
 	if p.Published != "" {
 		if t, err := dateparse.ParseLocal(p.Published); err == nil {
@@ -108,16 +149,84 @@ func (a *goBlog) toAPNote(p *post) *ap.Note {
 			note.Updated = t
 		}
 	}
-	// Reply
-	if replyLink := p.firstParameter(a.cfg.Micropub.ReplyParam); replyLink != "" {
+	// Reply (the ID of the replied ActivityPub object, which can differ from the URL)
+	if replyLink := defaultIfEmpty(p.firstParameter(activityPubReplyObjectParameter), p.firstParameter(a.cfg.Micropub.ReplyParam)); replyLink != "" {
 		note.InReplyTo = ap.IRI(replyLink)
 	}
 	return note
 }
 
+// Likes and reposts of ActivityPub objects are Like and Announce activities, other posts are notes
+func (a *goBlog) toAPItem(p *post) any {
+	if interaction := a.toAPInteraction(p); interaction != nil {
+		return interaction
+	}
+	return a.toAPNote(p)
+}
+
+// Like or Announce activity for likes and reposts of ActivityPub objects, nil for other posts
+func (a *goBlog) toAPInteraction(p *post) *ap.Activity {
+	object := p.firstParameter(activityPubInteractionObjectParameter)
+	if object == "" {
+		return nil
+	}
+	var typ ap.ActivityVocabularyType
+	switch {
+	case a.likeLink(p) != "":
+		typ = ap.LikeType
+	case a.repostLink(p) != "":
+		typ = ap.AnnounceType
+	default:
+		return nil
+	}
+	blogConfig := a.getBlogFromPost(p)
+	activity := ap.ActivityNew(a.activityPubId(p), typ, ap.IRI(object))
+	activity.Actor = a.apAPIri(blogConfig)
+	activity.URL = ap.IRI(a.fullPostURL(p))
+	actor := ap.IRI(p.firstParameter(activityPubInteractionActorParameter))
+	if typ == ap.LikeType {
+		activity.To.Append(actor)
+	} else {
+		activity.CC.Append(actor)
+		// Audience
+		switch p.Visibility {
+		case visibilityPublic:
+			activity.To.Append(ap.PublicNS, a.apGetFollowersCollectionId(p.Blog, blogConfig))
+		case visibilityUnlisted:
+			activity.To.Append(a.apGetFollowersCollectionId(p.Blog, blogConfig))
+			activity.CC.Append(ap.PublicNS)
+		}
+	}
+	if p.Published != "" {
+		if t, err := dateparse.ParseLocal(p.Published); err == nil {
+			activity.Published = t
+		}
+	}
+	return activity
+}
+
+// Post parameter to set the content warning, overrides the one of the section, "none" to disable it
+const contentWarningParameter = "contentwarning"
+
+func (a *goBlog) contentWarning(p *post) string {
+	if cw := strings.TrimSpace(p.firstParameter(contentWarningParameter)); cw != "" {
+		return lo.If(strings.EqualFold(cw, "none"), "").Else(cw)
+	}
+	if section, ok := a.getBlogFromPost(p).Sections[p.Section]; ok {
+		return section.ContentWarning
+	}
+	return ""
+}
+
 const activityPubVersionParam = "activitypubversion"
 
+// Post parameter with the ID the post was federated with, if the path changed afterwards
+const activityPubIdParam = "activitypubid"
+
 func (a *goBlog) activityPubId(p *post) ap.IRI {
+	if id := p.firstParameter(activityPubIdParam); id != "" {
+		return ap.IRI(id)
+	}
 	fu := a.fullPostURL(p)
 	if version := p.firstParameter(activityPubVersionParam); version != "" {
 		return ap.IRI(fu + "?activitypubversion=" + version)
@@ -137,16 +246,12 @@ func (a *goBlog) toApPerson(blog string) *ap.Person {
 	apBlog.Summary.Set(ap.DefaultLang, ap.Content(b.Description))
 	apBlog.PreferredUsername.Set(ap.DefaultLang, ap.Content(blog))
 
-	apBlog.Inbox = ap.IRI(a.getFullAddress("/activitypub/inbox/" + blog))
-	apBlog.Followers = ap.IRI(a.getFullAddress("/activitypub/followers/" + blog))
+	apBlog.Inbox = ap.IRI(a.getBlogDomainAddress(b, "/activitypub/inbox/"+blog))
+	apBlog.Followers = ap.IRI(a.getBlogDomainAddress(b, "/activitypub/followers/"+blog))
 
-	apBlog.PublicKey.Owner = apIri
-	apBlog.PublicKey.ID = ap.IRI(a.apIri(b) + "#main-key")
-	apBlog.PublicKey.PublicKeyPem = string(pem.EncodeToMemory(&pem.Block{
-		Type:    "PUBLIC KEY",
-		Headers: nil,
-		Bytes:   a.apPubKeyBytes,
-	}))
+	if keys := a.apPublicKeys(blog); len(keys) > 0 {
+		apBlog.PublicKey = keys[0]
+	}
 
 	if a.hasProfileImage() {
 		icon := &ap.Image{}
@@ -156,11 +261,128 @@ func (a *goBlog) toApPerson(blog string) *ap.Person {
 		apBlog.Icon = icon
 	}
 
+	// Profile customization
+	if pc := b.ActivityPub; pc != nil {
+		if pc.Name != "" {
+			apBlog.Name.Set(ap.DefaultLang, ap.Content(a.renderMdTitle(pc.Name)))
+		}
+		if pc.Summary != "" {
+			buf := bufferpool.Get()
+			if err := a.renderMarkdownToWriter(buf, pc.Summary, true); err == nil {
+				apBlog.Summary.Set(ap.DefaultLang, ap.Content(strings.TrimSpace(buf.String())))
+			}
+			bufferpool.Put(buf)
+		}
+		if pc.Icon != "" {
+			icon := &ap.Image{}
+			icon.Type = ap.ImageType
+			icon.URL = ap.IRI(a.getFullAddress(pc.Icon))
+			apBlog.Icon = icon
+		}
+		if pc.Image != "" {
+			image := &ap.Image{}
+			image.Type = ap.ImageType
+			image.URL = ap.IRI(a.getFullAddress(pc.Image))
+			apBlog.Image = image
+		}
+	}
+
+	// Profile metadata: the configured attachments, the blog URL and the identities (rel=me links),
+	// so Mastodon can verify the links and shows them with a check mark
+	var attachments ap.ItemCollection
+	var links []string
+	addAttachment := func(pa *configBlogActivityPubAttachment) {
+		if pa.Name == "" || pa.Value == "" {
+			return
+		}
+		if isAbsoluteURL(pa.Value) {
+			link := normalizeRelMeURL(pa.Value)
+			if lo.Contains(links, link) {
+				return
+			}
+			links = append(links, link)
+		}
+		attachments = append(attachments, a.toApPropertyValue(pa))
+	}
+	if pc := b.ActivityPub; pc != nil {
+		for _, pa := range pc.Attachments {
+			addAttachment(pa)
+		}
+	}
+	addAttachment(&configBlogActivityPubAttachment{Name: "Blog", Value: a.apIri(b)})
+	if user := a.cfg.User; user != nil {
+		for _, identity := range user.Identities {
+			addAttachment(&configBlogActivityPubAttachment{Name: identityName(identity), Value: identity})
+		}
+	}
+	if len(attachments) > 0 {
+		apBlog.Attachment = attachments
+	}
+
 	return apBlog
 }
 
+// Profile metadata like on Mastodon, shown as a table on the profile
+type apPropertyValue struct {
+	ap.Object
+	name, value string
+}
+
+func (pv *apPropertyValue) MarshalJSON() ([]byte, error) {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(map[string]string{
+		"type":  "PropertyValue",
+		"name":  pv.name,
+		"value": pv.value,
+	}); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(bytes.Clone(buf.Bytes())), nil
+}
+
+func (a *goBlog) toApPropertyValue(pa *configBlogActivityPubAttachment) *apPropertyValue {
+	value := html.EscapeString(pa.Value)
+	if isAbsoluteURL(pa.Value) {
+		// Links are marked with rel="me", so they can be verified
+		buf := bufferpool.Get()
+		defer bufferpool.Put(buf)
+		hb := htmlbuilder.NewHtmlBuilder(buf)
+		hb.WriteElementOpen("a", "href", pa.Value, "rel", "me nofollow noopener noreferrer", "target", "_blank")
+		hb.WriteEscaped(strings.TrimPrefix(strings.TrimPrefix(pa.Value, "https://"), "http://"))
+		hb.WriteElementClose("a")
+		value = buf.String()
+	}
+	return &apPropertyValue{name: pa.Name, value: value}
+}
+
 func (a *goBlog) serveActivityStreams(w http.ResponseWriter, r *http.Request, status int, blog string) {
-	a.serveAPItem(w, r, status, a.toApPerson(blog))
+	person := a.toApPerson(blog)
+	if keys := a.apPublicKeys(blog); len(keys) > 1 {
+		// Also serve the previous key after a rotation
+		a.serveAPItem(w, r, status, &apMultiKeyPerson{Person: person, keys: keys})
+		return
+	}
+	a.serveAPItem(w, r, status, person)
+}
+
+// Tombstone for deleted posts, with the ID of the federated object if the post still exists
+func (a *goBlog) serveActivityStreamsTombstone(w http.ResponseWriter, r *http.Request) {
+	tombstone := &ap.Tombstone{
+		Type:       ap.TombstoneType,
+		ID:         ap.IRI(a.getFullAddress(r.URL.Path)),
+		FormerType: ap.NoteType,
+	}
+	if p, err := a.getPost(r.URL.Path); err == nil {
+		tombstone.ID = a.activityPubId(p)
+		tombstone.Deleted = toLocalTime(p.firstParameter("deleted"))
+		if p.RenderedTitle != "" {
+			tombstone.FormerType = ap.ArticleType
+		}
+	}
+	a.serveAPItem(w, r, http.StatusGone, tombstone)
 }
 
 func (a *goBlog) serveAPItem(w http.ResponseWriter, r *http.Request, status int, item any) {
@@ -171,11 +393,55 @@ func (a *goBlog) serveAPItem(w http.ResponseWriter, r *http.Request, status int,
 		return
 	}
 	// Send response
-	w.WriteHeader(status)
 	w.Header().Set(contentType, contenttype.ASUTF8)
+	w.WriteHeader(status)
 	_ = a.min.Get().Minify(contenttype.AS, w, bytes.NewReader(binary))
 }
 
+// Encode and minify the item for the ActivityStreams object cache
+func (a *goBlog) renderAPItem(item any) (*cacheItem, error) {
+	binary, err := jsonld.WithContext(jsonld.IRI(ap.ActivityBaseURI), jsonld.IRI(ap.SecurityContextURI)).Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	if err = a.min.Get().Minify(contenttype.AS, buf, bytes.NewReader(binary)); err != nil {
+		return nil, err
+	}
+	body := bytes.Clone(buf.Bytes())
+	now := time.Now()
+	return &cacheItem{
+		created:      now,
+		lastModified: now,
+		eTag:         contentETag(body),
+		header:       http.Header{},
+		body:         body,
+	}, nil
+}
+
+const asObjectCacheSize = 5 * 1000 * 1000 // 5 MB
+
+// The key changes whenever the post is updated, so outdated objects are never served
+func asObjectCacheKey(p *post) string {
+	h := sha256.New()
+	for _, v := range []string{p.Path, p.Published, p.Updated, string(p.Status), string(p.Visibility), p.Content} {
+		_, _ = io.WriteString(h, v)
+		_, _ = h.Write([]byte{0})
+	}
+	params := lo.Keys(p.Parameters)
+	sort.Strings(params)
+	for _, param := range params {
+		_, _ = io.WriteString(h, param)
+		for _, v := range p.Parameters[param] {
+			_, _ = h.Write([]byte{0})
+			_, _ = io.WriteString(h, v)
+		}
+		_, _ = h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
 func apUsername(person *ap.Person) string {
 	preferredUsername := person.PreferredUsername.First().Value.String()
 	u, err := url.Parse(person.GetLink().String())
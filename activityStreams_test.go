@@ -1,11 +1,17 @@
 package main
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	ap "github.com/go-ap/activitypub"
+	apc "github.com/go-ap/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.goblog.app/app/pkgs/contenttype"
 )
 
 func Test_apUsername(t *testing.T) {
@@ -30,3 +36,300 @@ func Test_apUsername(t *testing.T) {
 	username := apUsername(actor)
 	assert.Equal(t, "@user@example.org", username)
 }
+
+func Test_asObjectCache(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	app.asObjectCache = newCacheStore(asObjectCacheSize)
+
+	p := &post{
+		Path:       "/posts/test",
+		Section:    "posts",
+		Published:  "2020-01-01T00:00:00Z",
+		Parameters: map[string][]string{"title": {"Test"}},
+		Content:    "Test content",
+		Status:     statusPublished,
+		Visibility: visibilityPublic,
+	}
+	require.NoError(t, app.createPost(p))
+	p, err := app.getPost("/posts/test")
+	require.NoError(t, err)
+
+	serve := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/posts/test", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		app.serveActivityStreamsPost(rec, req, http.StatusOK, p)
+		return rec
+	}
+
+	rec := serve("")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/activity+json")
+	assert.Contains(t, rec.Body.String(), "Test content")
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "public,max-age=60", rec.Header().Get("Cache-Control"))
+
+	// Served from cache and conditional
+	rec = serve(etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, 0, rec.Body.Len())
+
+	// Updated post gets a new object
+	p.Content = "Updated content"
+	p.Updated = "2020-01-02T00:00:00Z"
+	rec = serve(etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Updated content")
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+}
+
+func Test_apPersonProfile(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+
+	app.cfg.Blogs["default"].ActivityPub = &configBlogActivityPub{
+		Name:    "Display Name",
+		Summary: "My *bio*",
+		Icon:    "/avatar.png",
+		Image:   "https://cdn.example.com/header.jpg",
+		Attachments: []*configBlogActivityPubAttachment{
+			{Name: "Website", Value: "https://example.org"},
+			{Name: "Pronouns", Value: "<they/them>"},
+			{Name: "Empty"},
+		},
+	}
+	app.cfg.User.Identities = []string{"https://github.com/user", "https://example.org/"}
+
+	item, err := app.renderAPItem(app.toApPerson("default"))
+	require.NoError(t, err)
+
+	parsed, err := ap.UnmarshalJSON(item.body)
+	require.NoError(t, err)
+	person, err := ap.ToActor(parsed)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Display Name", person.Name.First().Value.String())
+	assert.Equal(t, "<p>My <em>bio</em></p>", person.Summary.First().Value.String())
+	assert.Equal(t, "https://example.com/avatar.png", person.Icon.(*ap.Object).URL.GetLink().String())
+	assert.Equal(t, "https://cdn.example.com/header.jpg", person.Image.(*ap.Object).URL.GetLink().String())
+
+	body := string(item.body)
+	assert.Contains(t, body, `"type":"PropertyValue"`)
+	assert.Contains(t, body, `"name":"Website"`)
+	assert.Contains(t, body, `rel=\"me nofollow noopener noreferrer\"`)
+	assert.Contains(t, body, `>example.org</a>`)
+	assert.Contains(t, body, `&lt;they/them&gt;`)
+	assert.NotContains(t, body, `"name":"Empty"`)
+
+	// Blog URL and identities
+	assert.Contains(t, body, `"name":"Blog"`)
+	assert.Contains(t, body, `href=\"https://example.com\"`)
+	assert.Contains(t, body, `"name":"github.com"`)
+	assert.Contains(t, body, `>github.com/user</a>`)
+	assert.Equal(t, 1, strings.Count(body, `>example.org`), "identity already in the attachments")
+}
+
+func Test_apContentWarning(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+
+	bc := app.cfg.Blogs[app.cfg.DefaultBlog]
+	bc.Sections["politics"] = &configSection{Name: "politics", ContentWarning: "Politics"}
+
+	summary := func(p *post) string {
+		p.Blog = app.cfg.DefaultBlog
+		return app.toAPNote(p).Summary.First().Value.String()
+	}
+
+	// No content warning
+	assert.Equal(t, "", summary(&post{Path: "/posts/1", Section: "posts", Content: "Test"}))
+	// Section content warning
+	assert.Equal(t, "Politics", summary(&post{Path: "/politics/1", Section: "politics", Content: "Test"}))
+	// Post overrides section
+	assert.Equal(t, "Elections", summary(&post{Path: "/politics/2", Section: "politics", Content: "Test", Parameters: map[string][]string{contentWarningParameter: {"Elections"}}}))
+	assert.Equal(t, "", summary(&post{Path: "/politics/3", Section: "politics", Content: "Test", Parameters: map[string][]string{contentWarningParameter: {"none"}}}))
+	// Post without section
+	assert.Equal(t, "Spoiler", summary(&post{Path: "/posts/2", Section: "posts", Content: "Test", Parameters: map[string][]string{contentWarningParameter: {"Spoiler"}}}))
+}
+
+func Test_apIdOnPathChange(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.ActivityPub = &configActivityPub{Enabled: true}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+
+	require.NoError(t, app.createPost(&post{
+		Path:      "/posts/old",
+		Section:   "posts",
+		Published: "2020-01-01T00:00:00Z",
+		Content:   "Test content",
+	}))
+	require.NoError(t, app.createPost(&post{
+		Path:    "/posts/draft",
+		Section: "posts",
+		Status:  statusDraft,
+		Content: "Draft content",
+	}))
+
+	// Federated post keeps the ID, but gets the new URL
+	p, err := app.getPost("/posts/old")
+	require.NoError(t, err)
+	p.Path = "/posts/new"
+	require.NoError(t, app.replacePost(p, "/posts/old", p.Status, p.Visibility))
+	p, err = app.getPost("/posts/new")
+	require.NoError(t, err)
+	note := app.toAPNote(p)
+	assert.Equal(t, "https://example.com/posts/old", note.ID.String())
+	assert.Equal(t, "https://example.com/posts/new", note.URL.GetLink().String())
+
+	// Changing the path again keeps the first ID
+	p.Path = "/posts/newer"
+	require.NoError(t, app.replacePost(p, "/posts/new", p.Status, p.Visibility))
+	p, err = app.getPost("/posts/newer")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/posts/old", app.activityPubId(p).String())
+
+	// Draft wasn't federated, so it gets the new ID
+	p, err = app.getPost("/posts/draft")
+	require.NoError(t, err)
+	p.Path = "/posts/draft-new"
+	require.NoError(t, app.replacePost(p, "/posts/draft", p.Status, p.Visibility))
+	p, err = app.getPost("/posts/draft-new")
+	require.NoError(t, err)
+	assert.Empty(t, p.Parameters[activityPubIdParam])
+	assert.Equal(t, "https://example.com/posts/draft-new", app.activityPubId(p).String())
+}
+
+func Test_apInteractions(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+
+	interactionPost := func(param, link string, federated bool) *post {
+		p := &post{
+			Path: "/posts/1", Blog: app.cfg.DefaultBlog, Section: "posts", Visibility: visibilityPublic,
+			Parameters: map[string][]string{param: {link}},
+		}
+		if federated {
+			p.Parameters[activityPubInteractionObjectParameter] = []string{"https://social.example.org/users/alice/statuses/1"}
+			p.Parameters[activityPubInteractionActorParameter] = []string{"https://social.example.org/users/alice"}
+		}
+		return p
+	}
+
+	// Like of ActivityPub object
+	like := app.toAPInteraction(interactionPost(app.cfg.Micropub.LikeParam, "https://social.example.org/@alice/1", true))
+	require.NotNil(t, like)
+	assert.Equal(t, ap.LikeType, like.Type)
+	assert.Equal(t, "https://example.com/posts/1", like.ID.String())
+	assert.Equal(t, "https://social.example.org/users/alice/statuses/1", like.Object.GetLink().String())
+	assert.True(t, like.To.Contains(ap.IRI("https://social.example.org/users/alice")))
+	assert.False(t, like.To.Contains(ap.PublicNS))
+
+	// Repost of ActivityPub object
+	announce := app.toAPInteraction(interactionPost(app.cfg.Micropub.RepostParam, "https://social.example.org/@alice/1", true))
+	require.NotNil(t, announce)
+	assert.Equal(t, ap.AnnounceType, announce.Type)
+	assert.True(t, announce.To.Contains(ap.PublicNS))
+	assert.True(t, announce.CC.Contains(ap.IRI("https://social.example.org/users/alice")))
+
+	// Like of a website is a note
+	p := interactionPost(app.cfg.Micropub.LikeParam, "https://example.net/article", false)
+	assert.Nil(t, app.toAPInteraction(p))
+	_, isNote := app.toAPItem(p).(*ap.Note)
+	assert.True(t, isNote)
+
+	// Repost context is rendered with microformats
+	p = interactionPost(app.cfg.Micropub.RepostParam, "https://example.net/article", false)
+	p.Parameters[app.cfg.Micropub.RepostTitleParam] = []string{"Article"}
+	html := app.postHtml(&postHtmlOptions{p: p})
+	assert.Contains(t, html, `class="h-cite u-repost-of"`)
+	assert.Contains(t, html, "Article")
+}
+
+func Test_apReplyAddressing(t *testing.T) {
+	fc := newFakeHttpClient()
+	fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "https://social.example.org/.well-known/webfinger?resource=acct:bob@social.example.org":
+			w.Header().Set(contentType, contenttype.JSON)
+			_, _ = io.WriteString(w, `{"subject":"acct:bob@social.example.org","links":[{"rel":"self","type":"application/activity+json","href":"https://social.example.org/users/bob"}]}`)
+		case "https://social.example.org/@alice/1":
+			w.Header().Set(contentType, contenttype.AS)
+			_, _ = io.WriteString(w, `{"@context":"https://www.w3.org/ns/activitystreams","id":"https://social.example.org/users/alice/statuses/1","type":"Note","attributedTo":"https://social.example.org/users/alice","content":"Hello"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	app.apHttpClients = map[string]*apc.C{
+		app.cfg.DefaultBlog: apc.New(apc.WithHTTPClient(app.httpClient)),
+	}
+
+	p := &post{
+		Path: "/posts/reply", Blog: app.cfg.DefaultBlog, Section: "posts", Status: statusPublished, Visibility: visibilityPublic,
+		Content:    "Great post! Also cc @bob@social.example.org, but not mail@example.net",
+		Parameters: map[string][]string{app.cfg.Micropub.ReplyParam: {"https://social.example.org/@alice/1"}},
+	}
+	require.NoError(t, app.createPost(p))
+
+	app.apCheckMentions(p)
+	app.apCheckActivityPubReply(p)
+
+	assert.Equal(t, []string{"https://social.example.org/users/bob"}, p.Parameters[activityPubMentionsParameter])
+	assert.Equal(t, "https://social.example.org/users/alice", p.firstParameter(activityPubReplyActorParameter))
+	assert.Equal(t, []string{"https://social.example.org/users/alice", "https://social.example.org/users/bob"}, app.apMentionedActors(p))
+
+	// The parameters are saved
+	saved, err := app.getPost("/posts/reply")
+	require.NoError(t, err)
+	assert.Equal(t, "https://social.example.org/users/alice/statuses/1", saved.firstParameter(activityPubReplyObjectParameter))
+
+	note := app.toAPNote(p)
+	// Threaded with the ID of the replied object
+	assert.Equal(t, "https://social.example.org/users/alice/statuses/1", note.InReplyTo.GetLink().String())
+	// Addressed to the author of the replied object and the mentioned actor
+	assert.True(t, note.To.Contains(ap.PublicNS))
+	assert.True(t, note.CC.Contains(ap.IRI("https://social.example.org/users/alice")))
+	assert.True(t, note.CC.Contains(ap.IRI("https://social.example.org/users/bob")))
+	// With mention tags
+	var mentionTags []string
+	for _, tag := range note.Tag {
+		if tag.GetType() == ap.MentionType {
+			mentionTags = append(mentionTags, tag.GetLink().String())
+		}
+	}
+	assert.ElementsMatch(t, []string{"https://social.example.org/users/alice", "https://social.example.org/users/bob"}, mentionTags)
+}
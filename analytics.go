@@ -0,0 +1,287 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+// Privacy-friendly analytics: page views are counted per day and path and referrers per day and host.
+// No IP addresses, user agents or cookies are stored and requests with "Do Not Track" or
+// "Global Privacy Control" are ignored, as well as bots and logged-in requests.
+
+const (
+	analyticsPath = "/analytics"
+
+	defaultAnalyticsRetention = 365 // Days
+	defaultAnalyticsDays      = 30
+	analyticsTopLimit         = 20
+	analyticsFlushInterval    = time.Minute
+
+	analyticsDayFormat = "2006-01-02"
+)
+
+type analyticsKey struct {
+	day, value string
+}
+
+// Counts in memory, regularly written to the database
+type analyticsBuffer struct {
+	mu        sync.Mutex
+	hits      map[analyticsKey]int
+	referrers map[analyticsKey]int
+}
+
+type analyticsCount struct {
+	Key  string `json:"key"`
+	Hits int    `json:"hits"`
+}
+
+type analyticsData struct {
+	Days      []*analyticsCount `json:"days"`
+	Paths     []*analyticsCount `json:"paths"`
+	Referrers []*analyticsCount `json:"referrers"`
+}
+
+func (a *goBlog) analyticsEnabled() bool {
+	return a.cfg.Analytics != nil && a.cfg.Analytics.Enabled
+}
+
+func (a *goBlog) initAnalytics() {
+	if !a.analyticsEnabled() {
+		return
+	}
+	a.analytics = &analyticsBuffer{
+		hits:      map[analyticsKey]int{},
+		referrers: map[analyticsKey]int{},
+	}
+	// Write to database regularly and on shutdown
+	ticker := time.NewTicker(analyticsFlushInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.flushAnalytics()
+			}
+		}
+	}()
+	a.addShutdownBeforeDatabase(func() {
+		ticker.Stop()
+		close(done)
+		a.flushAnalytics()
+	})
+	// Delete old data
+	a.hourlyHooks = append(a.hourlyHooks, func() {
+		if err := a.db.deleteOldAnalytics(a.analyticsRetention()); err != nil {
+			log.Println("Failed to delete old analytics:", err.Error())
+		}
+	})
+}
+
+func (a *goBlog) analyticsRetention() int {
+	if r := a.cfg.Analytics.Retention; r > 0 {
+		return r
+	}
+	return defaultAnalyticsRetention
+}
+
+func (a *goBlog) analyticsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.analytics == nil || r.Method != http.MethodGet || !countAnalytics(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		// Only count successfully served pages
+		if status := ww.Status(); status != http.StatusOK && status != 0 {
+			return
+		}
+		if !strings.HasPrefix(ww.Header().Get(contentType), contenttype.HTML) {
+			return
+		}
+		if a.isLoggedIn(r) {
+			return
+		}
+		a.analytics.count(time.Now().UTC().Format(analyticsDayFormat), r.URL.Path, analyticsReferrer(r))
+	})
+}
+
+// Check privacy headers and ignore bots
+func countAnalytics(r *http.Request) bool {
+	if r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1" {
+		return false
+	}
+	ua := strings.ToLower(r.UserAgent())
+	if ua == "" {
+		return false
+	}
+	for _, bot := range []string{"bot", "crawl", "spider", "slurp", "curl", "wget", "python", "go-http-client", "feed"} {
+		if strings.Contains(ua, bot) {
+			return false
+		}
+	}
+	return true
+}
+
+// The host of an external referrer, empty for direct visits and internal navigation
+func analyticsReferrer(r *http.Request) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || ref.Hostname() == "" {
+		return ""
+	}
+	host := strings.ToLower(ref.Hostname())
+	if strings.EqualFold(host, stripPort(r.Host)) {
+		return ""
+	}
+	return host
+}
+
+func stripPort(host string) string {
+	if u, err := url.Parse("//" + host); err == nil {
+		return u.Hostname()
+	}
+	return host
+}
+
+func (b *analyticsBuffer) count(day, path, referrer string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hits[analyticsKey{day: day, value: path}]++
+	if referrer != "" {
+		b.referrers[analyticsKey{day: day, value: referrer}]++
+	}
+}
+
+// Get and reset the counts
+func (b *analyticsBuffer) take() (hits, referrers map[analyticsKey]int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hits, referrers = b.hits, b.referrers
+	b.hits, b.referrers = map[analyticsKey]int{}, map[analyticsKey]int{}
+	return
+}
+
+func (a *goBlog) flushAnalytics() {
+	if a.analytics == nil {
+		return
+	}
+	hits, referrers := a.analytics.take()
+	if err := a.db.saveAnalytics(hits, referrers); err != nil {
+		log.Println("Failed to save analytics:", err.Error())
+	}
+}
+
+func (db *database) saveAnalytics(hits, referrers map[analyticsKey]int) error {
+	for k, v := range hits {
+		if _, err := db.Exec(
+			"insert into analytics_hits (day, path, hits) values (@day, @path, @hits) on conflict (day, path) do update set hits = hits + excluded.hits",
+			sql.Named("day", k.day), sql.Named("path", k.value), sql.Named("hits", v),
+		); err != nil {
+			return err
+		}
+	}
+	for k, v := range referrers {
+		if _, err := db.Exec(
+			"insert into analytics_referrers (day, referrer, hits) values (@day, @referrer, @hits) on conflict (day, referrer) do update set hits = hits + excluded.hits",
+			sql.Named("day", k.day), sql.Named("referrer", k.value), sql.Named("hits", v),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *database) deleteOldAnalytics(retention int) error {
+	since := sql.Named("since", time.Now().UTC().AddDate(0, 0, -retention).Format(analyticsDayFormat))
+	if _, err := db.Exec("delete from analytics_hits where day < @since", since); err != nil {
+		return err
+	}
+	_, err := db.Exec("delete from analytics_referrers where day < @since", since)
+	return err
+}
+
+// Daily totals, top paths and top referrers of the last days
+func (db *database) getAnalytics(days int) (*analyticsData, error) {
+	since := sql.Named("since", time.Now().UTC().AddDate(0, 0, -days+1).Format(analyticsDayFormat))
+	limit := sql.Named("limit", analyticsTopLimit)
+	data := &analyticsData{}
+	var err error
+	if data.Days, err = db.getAnalyticsCounts("select day, sum(hits) from analytics_hits where day >= @since group by day order by day desc", since); err != nil {
+		return nil, err
+	}
+	if data.Paths, err = db.getAnalyticsCounts("select path, sum(hits) as s from analytics_hits where day >= @since group by path order by s desc, path limit @limit", since, limit); err != nil {
+		return nil, err
+	}
+	if data.Referrers, err = db.getAnalyticsCounts("select referrer, sum(hits) as s from analytics_referrers where day >= @since group by referrer order by s desc, referrer limit @limit", since, limit); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (db *database) getAnalyticsCounts(query string, args ...any) ([]*analyticsCount, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []*analyticsCount{}
+	for rows.Next() {
+		c := &analyticsCount{}
+		if err = rows.Scan(&c.Key, &c.Hits); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func (a *goBlog) getAnalyticsData(r *http.Request) (*analyticsData, error) {
+	// Include the latest counts
+	a.flushAnalytics()
+	days := stringToInt(r.FormValue("days"))
+	if days <= 0 {
+		days = defaultAnalyticsDays
+	}
+	if retention := a.analyticsRetention(); days > retention {
+		days = retention
+	}
+	return a.db.getAnalytics(days)
+}
+
+func (a *goBlog) serveAnalytics(w http.ResponseWriter, r *http.Request) {
+	data, err := a.getAnalyticsData(r)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.render(w, r, a.renderAnalytics, &renderData{
+		Data: data,
+	})
+}
+
+func (a *goBlog) serveAnalyticsJSON(w http.ResponseWriter, r *http.Request) {
+	data, err := a.getAnalyticsData(r)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(json.NewEncoder(pw).Encode(data))
+	}()
+	w.Header().Set(contentType, contenttype.JSONUTF8)
+	_ = pr.CloseWithError(a.min.Get().Minify(contenttype.JSON, w, pr))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_analytics(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+	app.cfg.Analytics = &configAnalytics{Enabled: true}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+	app.initAnalytics()
+
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{
+		Path:      "/testpost",
+		Section:   "posts",
+		Status:    statusPublished,
+		Published: "2020-10-15T10:00:00Z",
+		Content:   "Test Content",
+	}))
+
+	client := newHandlerClient(app.d)
+
+	visit := func(path string, headers map[string]string, loggedIn bool) {
+		rb := requests.URL("http://localhost:8080" + path).Client(client)
+		for k, v := range headers {
+			rb.Header(k, v)
+		}
+		if loggedIn {
+			rb.BasicAuth("test", "test")
+		}
+		_ = rb.Fetch(context.Background())
+	}
+
+	browser := "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/115.0"
+
+	// Counted
+	visit("/testpost", map[string]string{"User-Agent": browser, "Referer": "https://search.example/?q=test"}, false)
+	visit("/testpost", map[string]string{"User-Agent": browser, "Referer": "http://localhost:8080/"}, false)
+	visit("/", map[string]string{"User-Agent": browser}, false)
+	// Not counted
+	visit("/testpost", map[string]string{"User-Agent": browser, "DNT": "1"}, false)
+	visit("/testpost", map[string]string{"User-Agent": browser, "Sec-GPC": "1"}, false)
+	visit("/testpost", map[string]string{"User-Agent": "Googlebot/2.1"}, false)
+	visit("/testpost", map[string]string{"User-Agent": browser}, true)
+	visit("/notfound", map[string]string{"User-Agent": browser}, false)
+	visit("/testpost.rss", map[string]string{"User-Agent": browser}, false)
+
+	var data analyticsData
+	err := requests.URL("http://localhost:8080/analytics.json").Client(client).
+		BasicAuth("test", "test").
+		ToJSON(&data).
+		Fetch(context.Background())
+	require.NoError(t, err)
+
+	if assert.Len(t, data.Days, 1) {
+		assert.Equal(t, 3, data.Days[0].Hits)
+	}
+	if assert.Len(t, data.Paths, 2) {
+		assert.Equal(t, "/testpost", data.Paths[0].Key)
+		assert.Equal(t, 2, data.Paths[0].Hits)
+	}
+	if assert.Len(t, data.Referrers, 1) {
+		assert.Equal(t, "search.example", data.Referrers[0].Key)
+	}
+
+	// Stats page requires login
+	var page string
+	err = requests.URL("http://localhost:8080/analytics").Client(client).
+		BasicAuth("test", "test").
+		CheckStatus(http.StatusOK).
+		ToString(&page).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, page, "search.example")
+
+	err = requests.URL("http://localhost:8080/analytics.json").Client(client).
+		ToString(&page).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, page, "search.example")
+
+	// Old data is deleted
+	require.NoError(t, app.db.saveAnalytics(map[analyticsKey]int{{day: "2000-01-01", value: "/old"}: 1}, nil))
+	require.NoError(t, app.db.deleteOldAnalytics(defaultAnalyticsRetention))
+	row, err := app.db.QueryRow("select count(*) from analytics_hits where day = '2000-01-01'")
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, row.Scan(&count))
+	assert.Equal(t, 0, count)
+}
@@ -1,42 +1,45 @@
 package main
 
 import (
-	"crypto/rsa"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	shutdowner "git.jlel.se/jlelse/go-shutdowner"
 	ts "git.jlel.se/jlelse/template-strings"
 	"github.com/dgraph-io/ristretto"
 	ct "github.com/elnormous/contenttype"
 	apc "github.com/go-ap/client"
-	"github.com/go-fed/httpsig"
 	"github.com/hacdias/indieauth/v3"
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
 	"github.com/yuin/goldmark"
 	"go.goblog.app/app/pkgs/minify"
 	"go.goblog.app/app/pkgs/plugins"
 	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/exp/slog"
 	"golang.org/x/sync/singleflight"
 )
 
 type goBlog struct {
 	// ActivityPub
-	apPrivateKey       *rsa.PrivateKey
-	apPubKeyBytes      []byte
-	apSigner           httpsig.Signer
-	apSignMutex        sync.Mutex
+	apKeys             *apKeyStore
 	apHttpClients      map[string]*apc.C
 	webfingerResources map[string]*configBlog
-	webfingerAccts     map[string]string
+	webfingerAccts     map[string][]string // ActivityPub IRI to the accounts, the first one is the primary
 	// ActivityStreams
 	asCheckMediaTypes []ct.MediaType
+	asObjectCache     *cacheStore
+	asObjectGroup     singleflight.Group
+	// Analytics
+	analytics *analyticsBuffer
 	// Assets
-	assetFileNames map[string]string
-	assetFiles     map[string]*assetFile
+	assets atomic.Pointer[assetStore]
 	// Autocert
 	autocertManager *autocert.Manager
 	autocertInit    sync.Once
+	certNotified    map[string]time.Time
+	certNotifiedMu  sync.Mutex
 	// Blogroll
 	blogrollCacheGroup singleflight.Group
 	// Blogstats
@@ -44,30 +47,58 @@ type goBlog struct {
 	// Cache
 	cache *cache
 	// Config
-	cfg *config
+	cfg        *config
+	cfgFile    string
+	cfgReloads int // Number of config reloads before this instance was created
 	// Database
-	db *database
+	db        *database
+	dbUsersWg sync.WaitGroup
 	// Errors
 	errorCheckMediaTypes []ct.MediaType
+	// Form spam protection
+	formSpam     *formSpamProtection
+	formSpamInit sync.Once
+	// Metrics
+	metrics     *metricsCollector
+	metricsInit sync.Once
+	// Rate limiting
+	rateLimiter     *rateLimiter
+	rateLimiterInit sync.Once
+	// Login brute-force protection
+	loginProtection     *loginProtection
+	loginProtectionInit sync.Once
 	// Geo
 	photonMutex sync.Mutex
+	// Page data
+	pageData *pageDataStore
 	// Hooks
 	pPostHooks     []postHookFunc
 	pUpdateHooks   []postHookFunc
 	pDeleteHooks   []postHookFunc
 	pUndeleteHooks []postHookFunc
 	hourlyHooks    []hourlyHookFunc
-	// HTTP Client
-	httpClient *http.Client
+	// HTTP Clients
+	httpClient       *http.Client
+	publicHttpClient *http.Client // Only public addresses
 	// HTTP Routers
-	d http.Handler
+	d  http.Handler
+	dh *dynamicHandler // Served router, switched on config reloads
 	// IndexNow
 	inKey  []byte
 	inLoad sync.Once
 	// IndieAuth
 	ias *indieauth.Server
 	// Logs
-	logf *rotatelogs.RotateLogs
+	logf           *rotatelogs.RotateLogs
+	logFile        *rotatelogs.RotateLogs
+	logHandler     slog.Handler
+	logLevel       slog.Level
+	logLevels      map[string]slog.Level
+	loggers        map[string]*slog.Logger
+	loggersMutex   sync.Mutex
+	logIPSalt      []byte
+	logIPSaltDay   string
+	logIPSaltMutex sync.Mutex
 	// Markdown
 	md, absoluteMd, titleMd goldmark.Markdown
 	// Media
@@ -82,6 +113,9 @@ type goBlog struct {
 	min minify.Minifier
 	// Plugins
 	pluginHost *plugins.PluginHost
+	// Preview links
+	previewKey      []byte
+	previewKeyMutex sync.Mutex
 	// Profile image
 	profileImageHashString string
 	profileImageHashGroup  singleflight.Group
@@ -89,12 +123,16 @@ type goBlog struct {
 	reactionsInit  sync.Once
 	reactionsCache *ristretto.Cache
 	reactionsSfg   singleflight.Group
+	// Related posts
+	relatedPostsMutex sync.Mutex
 	// Regex Redirects
 	regexRedirects []*regexRedirect
 	// Sessions
 	loginSessions, captchaSessions *dbSessionStore
 	// Shutdown
 	shutdown shutdowner.Shutdowner
+	// Sitemap ping
+	sitemapPingMutex sync.Mutex
 	// Template strings
 	ts *ts.TemplateStrings
 	// Tor
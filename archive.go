@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+const defaultArchivePath = "/archive"
+
+type archiveYear struct {
+	year   string
+	months [12]int
+	total  int
+}
+
+type archiveRenderData struct {
+	years []*archiveYear
+}
+
+func (a *goBlog) serveArchive(w http.ResponseWriter, r *http.Request) {
+	blog, bc := a.getBlog(r)
+	years, err := a.db.getArchiveCounts(blog)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.render(w, r, a.renderArchive, &renderData{
+		Canonical: a.getFullAddress(bc.getRelativePath(defaultIfEmpty(bc.Archive.Path, defaultArchivePath))),
+		Data: &archiveRenderData{
+			years: years,
+		},
+	})
+}
+
+const archiveCountsSql = `
+select substr(tolocal(published), 1, 4) as year, substr(tolocal(published), 6, 2) as month, count(path)
+from posts
+where status = @status and visibility = @visibility and blog = @blog and coalesce(published, '') != ''
+` + visibilityWindowsQuery + `
+group by year, month
+order by year desc, month asc
+`
+
+// Number of published posts per month, grouped by year (newest year first)
+func (db *database) getArchiveCounts(blog string) ([]*archiveYear, error) {
+	args := append([]any{sql.Named("status", statusPublished), sql.Named("visibility", visibilityPublic), sql.Named("blog", blog)}, visibilityWindowsQueryArgs(time.Now())...)
+	rows, err := db.Query(archiveCountsSql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var years []*archiveYear
+	var year string
+	var month, count int
+	for rows.Next() {
+		if err = rows.Scan(&year, &month, &count); err != nil {
+			return nil, err
+		}
+		if month < 1 || month > 12 {
+			continue
+		}
+		if len(years) == 0 || years[len(years)-1].year != year {
+			years = append(years, &archiveYear{year: year})
+		}
+		current := years[len(years)-1]
+		current.months[month-1] = count
+		current.total += count
+	}
+	return years, rows.Err()
+}
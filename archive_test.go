@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_archive(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.cfg.Blogs[app.cfg.DefaultBlog].Archive = &configArchive{
+		Enabled: true,
+		Title:   "Archive",
+	}
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/1", Published: "2020-10-15T10:00:00Z"},
+		{Path: "/2", Published: "2020-10-20T10:00:00Z"},
+		{Path: "/3", Published: "2021-01-01T10:00:00Z"},
+		{Path: "/4", Published: "2021-02-01T10:00:00Z", Visibility: visibilityPrivate},
+		{Path: "/5", Status: statusDraft},
+	} {
+		p.Section = "posts"
+		p.Content = "Test"
+		require.NoError(t, app.createPost(p))
+	}
+
+	years, err := app.db.getArchiveCounts(app.cfg.DefaultBlog)
+	require.NoError(t, err)
+	if assert.Len(t, years, 2) {
+		assert.Equal(t, "2021", years[0].year)
+		assert.Equal(t, 1, years[0].total)
+		assert.Equal(t, 1, years[0].months[0])
+		assert.Equal(t, 0, years[0].months[1])
+		assert.Equal(t, "2020", years[1].year)
+		assert.Equal(t, 2, years[1].total)
+		assert.Equal(t, 2, years[1].months[9])
+	}
+
+	var body string
+	err = requests.URL("http://localhost:8080/archive").
+		CheckStatus(http.StatusOK).
+		ToString(&body).
+		Client(newHandlerClient(app.d)).Fetch(context.Background())
+	require.NoError(t, err)
+
+	assert.Contains(t, body, "<h1>Archive</h1>")
+	assert.Contains(t, body, "<a href=/2020/10>2</a>")
+	assert.Contains(t, body, "<a href=/2021>2021</a>")
+}
@@ -19,14 +19,14 @@ const loggedInKey contextKey = "loggedIn"
 // Check if credentials are correct
 func (a *goBlog) checkCredentials(username, password, totpPasscode string) bool {
 	return username == a.cfg.User.Nick &&
-		password == a.cfg.User.Password &&
+		checkPassword(a.cfg.User.Password, password) &&
 		(a.cfg.User.TOTP == "" || totp.Validate(totpPasscode, a.cfg.User.TOTP))
 }
 
 // Check if app passwords are correct
 func (a *goBlog) checkAppPasswords(username, password string) bool {
 	for _, apw := range a.cfg.User.AppPasswords {
-		if apw.Username == username && apw.Password == password {
+		if apw.Username == username && checkPassword(apw.Password, password) {
 			return true
 		}
 	}
@@ -52,6 +52,11 @@ func (a *goBlog) authMiddleware(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
+		// Database users are logged in, but only have access to their blogs
+		if a.currentUser(r) != nil {
+			a.serveError(w, r, "No permission", http.StatusForbidden)
+			return
+		}
 		// Encode original request
 		headerBuffer, bodyBuffer := bufferpool.Get(), bufferpool.Get()
 		defer bufferpool.Put(headerBuffer, bodyBuffer)
@@ -80,10 +85,11 @@ func (a *goBlog) authMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("X-Robots-Tag", "noindex")
 		a.render(w, r, a.renderLogin, &renderData{
 			Data: &loginRenderData{
-				loginMethod:  r.Method,
-				loginHeaders: headerBuffer.String(),
-				loginBody:    bodyBuffer.String(),
-				totp:         a.cfg.User.TOTP != "",
+				loginMethod:   r.Method,
+				loginHeaders:  headerBuffer.String(),
+				loginBody:     bodyBuffer.String(),
+				loginRedirect: r.URL.RequestURI(),
+				totp:          a.cfg.User.TOTP != "",
 			},
 		})
 	})
@@ -109,11 +115,19 @@ func (a *goBlog) checkLogin(w http.ResponseWriter, r *http.Request) bool {
 	if r.FormValue("loginaction") != "login" {
 		return false
 	}
-	// Check credential
-	if !a.checkCredentials(r.FormValue("username"), r.FormValue("password"), r.FormValue("token")) {
-		a.serveError(w, r, "Incorrect credentials", http.StatusUnauthorized)
+	if a.serveLoginLockedOut(w, r) {
 		return true
 	}
+	// Check credential, first the config user, then the database users
+	var user *appUser
+	if !a.checkCredentials(r.FormValue("username"), r.FormValue("password"), r.FormValue("token")) {
+		if user = a.db.checkUserCredentials(r.FormValue("username"), r.FormValue("password")); user == nil {
+			a.loginFailed(r, "form")
+			a.serveError(w, r, "Incorrect credentials", http.StatusUnauthorized)
+			return true
+		}
+	}
+	a.loginSucceeded(r)
 	// Prepare original request
 	bodyDecoder := base64.NewDecoder(base64.StdEncoding, strings.NewReader(r.FormValue("loginbody")))
 	origReq, _ := http.NewRequestWithContext(r.Context(), r.FormValue("loginmethod"), r.URL.RequestURI(), bodyDecoder)
@@ -125,14 +139,23 @@ func (a *goBlog) checkLogin(w http.ResponseWriter, r *http.Request) bool {
 		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 		return true
 	}
-	ses.Values["login"] = true
+	if user != nil {
+		ses.Values[sessionUserKey] = user.Username
+	} else {
+		ses.Values["login"] = true
+	}
 	err = a.loginSessions.Save(r, w, ses)
 	if err != nil {
 		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 		return true
 	}
 	// Serve original request
-	setLoggedIn(origReq, true)
+	if user != nil {
+		setLoggedIn(origReq, false)
+		setLoggedInUser(origReq, user)
+	} else {
+		setLoggedIn(origReq, true)
+	}
 	a.d.ServeHTTP(w, origReq)
 	return true
 }
@@ -184,5 +207,8 @@ func (a *goBlog) getDefaultPostStates(r *http.Request) (status []postStatus, vis
 		status = []postStatus{statusPublished}
 		visibility = []postVisibility{visibilityPublic}
 	}
+	if pf := getPreviewFlags(r); pf != nil && pf.drafts {
+		status = append(status, statusDraft)
+	}
 	return
 }
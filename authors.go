@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/htmlbuilder"
+)
+
+// Posts can be attributed to other authors than the user with the "author" parameter,
+// which references one of the configured author profiles (for guest posts or multi-author blogs)
+
+const authorParameter = "author"
+
+func (a *goBlog) initAuthors() error {
+	for key, author := range a.cfg.Authors {
+		if author == nil || author.Name == "" {
+			return errors.New("author " + key + " needs a name")
+		}
+	}
+	return nil
+}
+
+// Get the configured author of the post, nil if it's by the user
+func (a *goBlog) postAuthor(p *post) *configAuthor {
+	if p == nil {
+		return nil
+	}
+	if key := p.firstParameter(authorParameter); key != "" {
+		return a.cfg.Authors[key]
+	}
+	return nil
+}
+
+// h-card of the post author
+func (a *goBlog) renderPostAuthor(hb *htmlbuilder.HtmlBuilder, author *configAuthor, b *configBlog) {
+	hb.WriteElementOpen("div")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "postauthor"))
+	hb.WriteUnescaped(" ")
+	hb.WriteElementOpen("span", "class", "p-author h-card")
+	if author.Photo != "" {
+		hb.WriteElementOpen("data", "class", "u-photo", "value", a.getFullAddress(author.Photo))
+		hb.WriteElementClose("data")
+	}
+	if author.URL != "" {
+		hb.WriteElementOpen("a", "class", "p-name u-url", "href", author.URL)
+	} else {
+		hb.WriteElementOpen("span", "class", "p-name")
+	}
+	hb.WriteEscaped(author.Name)
+	hb.WriteElementClose(lo.If(author.URL != "", "a").Else("span"))
+	hb.WriteElementsClose("span", "div")
+}
+
+// ActivityStreams representation of the post author, attributed in addition to the blog actor
+func (a *goBlog) toAPAuthor(author *configAuthor) *ap.Person {
+	person := ap.PersonNew("")
+	person.Name.Set(ap.DefaultLang, ap.Content(author.Name))
+	if author.URL != "" {
+		person.ID = ap.IRI(author.URL)
+		person.URL = ap.IRI(author.URL)
+	}
+	if author.Photo != "" {
+		icon := &ap.Image{}
+		icon.Type = ap.ImageType
+		icon.URL = ap.IRI(a.getFullAddress(author.Photo))
+		person.Icon = icon
+	}
+	return person
+}
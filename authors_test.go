@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authors(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.Name = "Owner"
+	app.cfg.Authors = map[string]*configAuthor{
+		"jane": {Name: "Jane Doe", URL: "https://jane.example.com/", Photo: "/m/jane.jpg"},
+		"john": {Name: "John"},
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{Path: "/posts/guest", Section: "posts", Content: "Guest post", Parameters: map[string][]string{authorParameter: {"jane"}}}))
+	require.NoError(t, app.createPost(&post{Path: "/posts/john", Section: "posts", Content: "John's post", Parameters: map[string][]string{authorParameter: {"john"}}}))
+	require.NoError(t, app.createPost(&post{Path: "/posts/own", Section: "posts", Content: "Own post"}))
+	require.NoError(t, app.createPost(&post{Path: "/posts/unknown", Section: "posts", Content: "Unknown author", Parameters: map[string][]string{authorParameter: {"unknown"}}}))
+
+	getHTML := func(path string) string {
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil))
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode, path)
+		return string(body)
+	}
+
+	// Post pages
+	html := getHTML("/posts/guest")
+	assert.Contains(t, html, `By <span class="p-author h-card"><data class=u-photo value=http://localhost:8080/m/jane.jpg></data><a class="p-name u-url" href=https://jane.example.com/>Jane Doe</a></span>`)
+	assert.NotContains(t, html, `<div class="p-author h-card hide">`)
+
+	html = getHTML("/posts/john")
+	assert.Contains(t, html, `By <span class="p-author h-card"><span class=p-name>John</span></span>`)
+
+	for _, path := range []string{"/posts/own", "/posts/unknown"} {
+		html = getHTML(path)
+		assert.Contains(t, html, `<div class="p-author h-card hide">`, path)
+		assert.NotContains(t, html, "By <span", path)
+	}
+
+	// Index
+	html = getHTML("/posts")
+	assert.Contains(t, html, `By <span class="p-author h-card"><span class=p-name>John</span></span>`)
+
+	// ActivityStreams
+	p, err := app.getPost("/posts/guest")
+	require.NoError(t, err)
+	note := app.toAPNote(p)
+	attributedTo, ok := note.AttributedTo.(ap.ItemCollection)
+	require.True(t, ok)
+	require.Len(t, attributedTo, 2)
+	assert.Equal(t, app.apAPIri(app.cfg.Blogs["default"]), attributedTo[0].GetLink())
+	person, err := ap.ToActor(attributedTo[1])
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", person.Name.First().Value.String())
+	assert.Equal(t, ap.IRI("https://jane.example.com/"), person.URL)
+
+	p, err = app.getPost("/posts/own")
+	require.NoError(t, err)
+	assert.Equal(t, app.apAPIri(app.cfg.Blogs["default"]), app.toAPNote(p).AttributedTo)
+
+	// Feed
+	rec := httptest.NewRecorder()
+	app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/posts.rss", nil))
+	assert.Contains(t, rec.Body.String(), "<author>Jane Doe</author>")
+
+	// Authors need a name
+	app.cfg.Authors["invalid"] = &configAuthor{URL: "https://example.net/"}
+	assert.Error(t, app.initAuthors())
+}
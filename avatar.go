@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	wellKnownAvatarPath = "/.well-known/avatar"
+
+	libravatarPath        = "/avatar"
+	libravatarFallback    = "https://seccdn.libravatar.org/avatar/"
+	libravatarDefaultSize = 80
+)
+
+// Hashes (MD5 and SHA256) of the normalized email addresses, like used by Libravatar and Gravatar
+func libravatarHashes(emails []string) map[string]bool {
+	hashes := map[string]bool{}
+	for _, email := range emails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if email == "" {
+			continue
+		}
+		hashes[fmt.Sprintf("%x", md5.Sum([]byte(email)))] = true
+		hashes[fmt.Sprintf("%x", sha256.Sum256([]byte(email)))] = true
+	}
+	return hashes
+}
+
+// Serve the profile image for own email hashes, otherwise redirect to the default Libravatar server
+// See https://wiki.libravatar.org/api/
+func (a *goBlog) serveLibravatar(w http.ResponseWriter, r *http.Request) {
+	hash := strings.ToLower(chi.URLParam(r, "hash"))
+	// Size
+	size, _ := strconv.Atoi(defaultIfEmpty(r.FormValue("s"), r.FormValue("size")))
+	if size <= 0 {
+		size = libravatarDefaultSize
+	}
+	if lc := a.cfg.Libravatar; lc == nil || !lc.hashes[hash] {
+		// Unknown email address
+		defaultImage := defaultIfEmpty(r.FormValue("d"), r.FormValue("default"))
+		if defaultImage == "404" {
+			a.serve404(w, r)
+			return
+		}
+		query := url.Values{}
+		query.Set("s", strconv.Itoa(size))
+		if defaultImage != "" {
+			query.Set("d", defaultImage)
+		}
+		http.Redirect(w, r, libravatarFallback+url.PathEscape(hash)+"?"+query.Encode(), http.StatusFound)
+		return
+	}
+	// Serve square profile image in the requested size
+	r.URL.RawQuery = url.Values{"s": []string{fmt.Sprintf("%dx%d", size, size)}}.Encode()
+	r.Form = nil
+	a.serveProfileImage(profileImageFormatJPEG)(w, r)
+}
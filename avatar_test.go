@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"image/jpeg"
+	"net/http"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_libravatar(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.Email = "Me@Example.com "
+	app.cfg.Libravatar = &configLibravatar{
+		Enabled: true,
+		Emails:  []string{"other@example.org"},
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	md5Hash := fmt.Sprintf("%x", md5.Sum([]byte("me@example.com")))
+	sha256Hash := fmt.Sprintf("%x", sha256.Sum256([]byte("other@example.org")))
+
+	// Own email addresses get the profile image in the requested size
+	for _, path := range []string{"/avatar/" + md5Hash + "?s=100", "/avatar/" + sha256Hash + "?size=100"} {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost:8080"+path, nil)
+		rec, err := doHandlerRequest(req, app.d)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.StatusCode, path)
+		img, err := jpeg.Decode(rec.Body)
+		_ = rec.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, 100, img.Bounds().Dx())
+		assert.Equal(t, 100, img.Bounds().Dy())
+	}
+
+	// Other email addresses are redirected to Libravatar
+	otherHash := fmt.Sprintf("%x", md5.Sum([]byte("someone@example.net")))
+	var location string
+	err := requests.URL("http://localhost:8080/avatar/" + otherHash + "?d=mm").Client(client).
+		CheckStatus(http.StatusFound).
+		Handle(func(r *http.Response) error {
+			location = r.Header.Get("Location")
+			return nil
+		}).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, libravatarFallback+otherHash+"?d=mm&s=80", location)
+
+	err = requests.URL("http://localhost:8080/avatar/" + otherHash + "?d=404").Client(client).
+		CheckStatus(http.StatusNotFound).
+		Fetch(context.Background())
+	require.NoError(t, err)
+
+	// Stable avatar URL
+	err = requests.URL("http://localhost:8080/.well-known/avatar?s=64").Client(client).
+		CheckStatus(http.StatusOK).
+		CheckContentType("image/jpeg").
+		Fetch(context.Background())
+	require.NoError(t, err)
+}
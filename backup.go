@@ -0,0 +1,291 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// Backups are gzipped tar archives with a consistent snapshot of the database,
+// the locally stored media files and the profile image.
+
+const (
+	backupPath = "/api/backup"
+
+	backupDbName           = "db.sqlite"
+	backupMediaDir         = "media"
+	backupProfileImageName = "profileImage"
+
+	backupFilePrefix     = "goblog-backup-"
+	backupFileSuffix     = ".tar.gz"
+	backupFileTimeFormat = "20060102-150405"
+
+	defaultBackupInterval = 24 // Hours
+	defaultBackupKeep     = 7
+)
+
+// Write a backup to the writer
+func (a *goBlog) writeBackup(w io.Writer) error {
+	// Snapshot the database
+	tmpDir, err := os.MkdirTemp("", "goblog-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	dbSnapshot := filepath.Join(tmpDir, backupDbName)
+	if _, err = a.db.Exec("vacuum into @file", dbNoCache, sql.Named("file", dbSnapshot)); err != nil {
+		return err
+	}
+	// Create archive
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	if err = addFileToTar(tw, dbSnapshot, backupDbName); err != nil {
+		return err
+	}
+	if _, err = os.Stat(profileImageFile); err == nil {
+		if err = addFileToTar(tw, profileImageFile, backupProfileImageName); err != nil {
+			return err
+		}
+	}
+	if err = filepath.WalkDir(mediaFilePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(mediaFilePath, p)
+		if err != nil {
+			return err
+		}
+		return addFileToTar(tw, p, path.Join(backupMediaDir, filepath.ToSlash(rel)))
+	}); err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+func addFileToTar(tw *tar.Writer, file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Restore a backup, the database must not be in use. The files are extracted next to their targets first
+// and only replace them after the whole archive was read, so a broken archive doesn't change anything.
+func (a *goBlog) restoreBackup(r io.Reader) (err error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	extracted := map[string]string{} // target -> temporary file
+	defer func() {
+		if err != nil {
+			for _, tmpFile := range extracted {
+				_ = os.Remove(tmpFile)
+			}
+		}
+	}()
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		var target string
+		switch {
+		case name == backupDbName:
+			target = a.cfg.Db.File
+		case name == backupProfileImageName:
+			target = profileImageFile
+		case strings.HasPrefix(name, backupMediaDir+"/") && !strings.Contains(name, ".."):
+			target = filepath.Join(mediaFilePath, filepath.FromSlash(strings.TrimPrefix(name, backupMediaDir+"/")))
+		default:
+			continue
+		}
+		tmpFile := target + ".restore"
+		extracted[target] = tmpFile
+		if err = saveToFile(tr, tmpFile); err != nil {
+			return err
+		}
+	}
+	if _, ok := extracted[a.cfg.Db.File]; !ok {
+		return errors.New("backup contains no database")
+	}
+	// Remove the write-ahead log of the old database
+	_ = os.Remove(a.cfg.Db.File + "-wal")
+	_ = os.Remove(a.cfg.Db.File + "-shm")
+	for target, tmpFile := range extracted {
+		if err = os.Rename(tmpFile, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Authenticated endpoint to download a backup
+func (a *goBlog) serveBackup(w http.ResponseWriter, r *http.Request) {
+	a.serveArchiveDownload(w, r, backupFileName(time.Now()), a.writeBackup)
+}
+
+// Serve a gzipped archive as download, it's written to a temporary file first,
+// so errors are sent as error response instead of a truncated download
+func (a *goBlog) serveArchiveDownload(w http.ResponseWriter, r *http.Request, fileName string, write func(io.Writer) error) {
+	f, err := os.CreateTemp("", "goblog-download-*")
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+	if err = write(f); err != nil {
+		log.Println("Failed to create", fileName+":", err.Error())
+		a.serveError(w, r, "Failed to create archive", http.StatusInternalServerError)
+		return
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(contentType, "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set(cacheControl, "no-store")
+	_, _ = io.Copy(w, f)
+}
+
+func backupFileName(t time.Time) string {
+	return backupFilePrefix + t.UTC().Format(backupFileTimeFormat) + backupFileSuffix
+}
+
+// Create a backup file in the directory
+func (a *goBlog) createBackupFile(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, backupFileName(time.Now()))
+	tmpFile := file + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return "", err
+	}
+	if err = a.writeBackup(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpFile)
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	return file, os.Rename(tmpFile, file)
+}
+
+func (a *goBlog) initScheduledBackups() {
+	bc := a.cfg.Backup
+	if bc == nil || !bc.Enabled || bc.Directory == "" {
+		return
+	}
+	a.hourlyHooks = append(a.hourlyHooks, func() {
+		if err := a.runScheduledBackup(); err != nil {
+			log.Println("Failed to create scheduled backup:", err.Error())
+		}
+	})
+}
+
+// Create a backup if the last one is older than the interval and delete old backups
+func (a *goBlog) runScheduledBackup() error {
+	bc := a.cfg.Backup
+	backups, err := listBackupFiles(bc.Directory)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(lo.If(bc.Interval > 0, bc.Interval).Else(defaultBackupInterval)) * time.Hour
+	if len(backups) > 0 {
+		if stat, err := os.Stat(backups[len(backups)-1]); err == nil && time.Since(stat.ModTime()) < interval-time.Minute {
+			return nil
+		}
+	}
+	file, err := a.createBackupFile(bc.Directory)
+	if err != nil {
+		return err
+	}
+	log.Println("Created backup", file)
+	backups = append(backups, file)
+	// Delete old backups
+	keep := lo.If(bc.Keep > 0, bc.Keep).Else(defaultBackupKeep)
+	for len(backups) > keep {
+		if err = os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Backup files in the directory, oldest first
+func listBackupFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if name := e.Name(); e.Type().IsRegular() && strings.HasPrefix(name, backupFilePrefix) && strings.HasSuffix(name, backupFileSuffix) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_backup(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{
+		Path:    "/backuppost",
+		Section: "posts",
+		Status:  statusPublished,
+		Content: "Backup Content",
+	}))
+
+	archiveEntries := func(b []byte) []string {
+		gzr, err := gzip.NewReader(bytes.NewReader(b))
+		require.NoError(t, err)
+		tr := tar.NewReader(gzr)
+		var names []string
+		for {
+			h, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			names = append(names, h.Name)
+		}
+		return names
+	}
+
+	t.Run("Write and restore", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, app.writeBackup(&buf))
+		assert.Contains(t, archiveEntries(buf.Bytes()), backupDbName)
+
+		// Restore into a new database
+		restored := &goBlog{
+			cfg: createDefaultTestConfig(t),
+		}
+		require.NoError(t, restored.restoreBackup(bytes.NewReader(buf.Bytes())))
+		require.NoError(t, restored.initConfig(false))
+
+		p, err := restored.getPost("/backuppost")
+		require.NoError(t, err)
+		assert.Equal(t, "Backup Content", p.Content)
+	})
+
+	t.Run("Restore without database", func(t *testing.T) {
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		require.NoError(t, tar.NewWriter(gzw).Close())
+		require.NoError(t, gzw.Close())
+
+		restored := &goBlog{
+			cfg: createDefaultTestConfig(t),
+		}
+		assert.Error(t, restored.restoreBackup(&buf))
+	})
+
+	t.Run("Truncated archive", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, app.writeBackup(&buf))
+
+		// The existing database stays unchanged
+		restored := &goBlog{
+			cfg: createDefaultTestConfig(t),
+		}
+		require.NoError(t, os.WriteFile(restored.cfg.Db.File, []byte("old"), 0644))
+		assert.Error(t, restored.restoreBackup(bytes.NewReader(buf.Bytes()[:buf.Len()/2])))
+		content, err := os.ReadFile(restored.cfg.Db.File)
+		require.NoError(t, err)
+		assert.Equal(t, "old", string(content))
+		_, err = os.Stat(restored.cfg.Db.File + ".restore")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("API", func(t *testing.T) {
+		client := newHandlerClient(app.d)
+
+		// Unauthenticated, shows login form
+		err := requests.URL("http://localhost:8080" + backupPath).Client(client).
+			CheckContentType("application/gzip").
+			Fetch(context.Background())
+		assert.Error(t, err)
+
+		// Authenticated
+		var body bytes.Buffer
+		var headers http.Header
+		err = requests.URL("http://localhost:8080"+backupPath).Client(client).
+			BasicAuth("test", "test").
+			CheckStatus(http.StatusOK).
+			AddValidator(func(r *http.Response) error {
+				headers = r.Header
+				return nil
+			}).
+			ToBytesBuffer(&body).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "application/gzip", headers.Get(contentType))
+		assert.Contains(t, headers.Get("Content-Disposition"), backupFilePrefix)
+		assert.Contains(t, archiveEntries(body.Bytes()), backupDbName)
+	})
+
+	t.Run("Scheduled", func(t *testing.T) {
+		dir := t.TempDir()
+		app.cfg.Backup = &configBackup{Enabled: true, Directory: dir, Keep: 2}
+
+		// Old backups
+		for _, name := range []string{"goblog-backup-20200101-000000.tar.gz", "goblog-backup-20200102-000000.tar.gz", "other.txt"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("old"), 0644))
+		}
+		oldest := filepath.Join(dir, "goblog-backup-20200101-000000.tar.gz")
+		old := filepath.Join(dir, "goblog-backup-20200102-000000.tar.gz")
+		require.NoError(t, os.Chtimes(oldest, time.Now().Add(-72*time.Hour), time.Now().Add(-72*time.Hour)))
+		require.NoError(t, os.Chtimes(old, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)))
+
+		require.NoError(t, app.runScheduledBackup())
+
+		backups, err := listBackupFiles(dir)
+		require.NoError(t, err)
+		require.Len(t, backups, 2)
+		assert.Equal(t, old, backups[0])
+		assert.FileExists(t, filepath.Join(dir, "other.txt"))
+
+		// Latest backup is recent, no new backup
+		require.NoError(t, app.runScheduledBackup())
+		backups2, err := listBackupFiles(dir)
+		require.NoError(t, err)
+		assert.Equal(t, backups, backups2)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -64,24 +65,72 @@ func (a *goBlog) serveBlogrollExport(w http.ResponseWriter, r *http.Request) {
 	_ = pr.CloseWithError(a.min.Get().Minify(contenttype.XML, w, pr))
 }
 
+const defaultBlogrollRefresh = 60 // Minutes
+
+func (a *goBlog) initBlogrollRefresh() {
+	a.hourlyHooks = append(a.hourlyHooks, a.refreshBlogrolls)
+}
+
+// Refresh the cached outlines of all blogrolls in the background, so visitors don't have to wait for the source
+func (a *goBlog) refreshBlogrolls() {
+	for blog, bc := range a.cfg.Blogs {
+		if bc.Blogroll == nil || !bc.Blogroll.Enabled {
+			continue
+		}
+		if _, err, _ := a.blogrollCacheGroup.Do(blog, func() (any, error) {
+			return a.fetchBlogrollOutlines(blog)
+		}); err != nil {
+			log.Printf("Failed to refresh blogroll of %s: %v", blog, err)
+			continue
+		}
+		brPath := bc.getRelativePath(defaultIfEmpty(bc.Blogroll.Path, defaultBlogrollPath))
+		a.cache.purgePaths(brPath, brPath+".opml")
+	}
+}
+
 func (a *goBlog) getBlogrollOutlines(blog string) ([]*opml.Outline, error) {
-	// Get config
-	config := a.cfg.Blogs[blog].Blogroll
 	// Check cache
-	if cache := a.db.loadOutlineCache(blog); cache != nil {
-		return cache, nil
+	cached, created := a.db.loadOutlineCache(blog)
+	if cached != nil && time.Since(created) < time.Duration(a.cfg.Blogs[blog].Blogroll.Refresh)*time.Minute {
+		return cached, nil
 	}
-	// Make request and parse OPML
-	pr, pw := io.Pipe()
-	rb := requests.URL(config.Opml).Client(a.httpClient).ToWriter(pw)
-	if config.AuthHeader != "" && config.AuthValue != "" {
-		rb.Header(config.AuthHeader, config.AuthValue)
+	outlines, err := a.fetchBlogrollOutlines(blog)
+	if err != nil {
+		if cached != nil {
+			// Better serve outdated outlines than nothing
+			log.Printf("Failed to fetch blogroll of %s, using cached version: %v", blog, err)
+			return cached, nil
+		}
+		return nil, err
 	}
-	go func() {
-		_ = pw.CloseWithError(rb.Fetch(context.Background()))
-	}()
-	o, err := opml.Parse(pr)
-	_ = pr.CloseWithError(err)
+	return outlines, nil
+}
+
+func (a *goBlog) fetchBlogrollOutlines(blog string) ([]*opml.Outline, error) {
+	// Get config
+	config := a.cfg.Blogs[blog].Blogroll
+	// Open source and parse OPML
+	var source io.ReadCloser
+	if isAbsoluteURL(config.Opml) {
+		pr, pw := io.Pipe()
+		rb := requests.URL(config.Opml).Client(a.httpClient).ToWriter(pw)
+		if config.AuthHeader != "" && config.AuthValue != "" {
+			rb.Header(config.AuthHeader, config.AuthValue)
+		}
+		go func() {
+			_ = pw.CloseWithError(rb.Fetch(context.Background()))
+		}()
+		source = pr
+	} else {
+		// Local file
+		f, err := os.Open(config.Opml)
+		if err != nil {
+			return nil, err
+		}
+		source = f
+	}
+	o, err := opml.Parse(source)
+	_ = source.Close()
 	if err != nil {
 		return nil, err
 	}
@@ -117,19 +166,16 @@ func (db *database) cacheOutlines(blog string, outlines []*opml.Outline) {
 	bufferpool.Put(opmlBuffer)
 }
 
-func (db *database) loadOutlineCache(blog string) []*opml.Outline {
+func (db *database) loadOutlineCache(blog string) ([]*opml.Outline, time.Time) {
 	data, err := db.retrievePersistentCache("blogroll_" + blog)
 	if err != nil || data == nil {
-		return nil
+		return nil, time.Time{}
 	}
 	o, err := opml.Parse(bytes.NewReader(data))
 	if err != nil {
-		return nil
-	}
-	if time.Since(o.DateCreated).Minutes() > 60 {
-		return nil
+		return nil, time.Time{}
 	}
-	return o.Outlines
+	return o.Outlines, o.DateCreated
 }
 
 func sortOutlines(outlines []*opml.Outline) []*opml.Outline {
@@ -4,7 +4,10 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -83,4 +86,51 @@ func Test_blogroll(t *testing.T) {
 
 	assert.Equal(t, 200, rec.Code)
 
+	// Test using the cache when fetching fails
+
+	fc.setFakeResponse(http.StatusInternalServerError, "")
+	app.cfg.Blogs["en"].Blogroll.Refresh = -1
+
+	outlines, err = app.getBlogrollOutlines("en")
+	require.NoError(t, err)
+	assert.Len(t, outlines, 2)
+
+}
+
+func Test_blogrollLocalFile(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	opmlFile := filepath.Join(t.TempDir(), "blogroll.opml")
+	require.NoError(t, os.WriteFile(opmlFile, []byte(`
+	<opml version="2.0">
+		<body>
+		<outline text="Z text" htmlUrl="https://z.example.com"/>
+		<outline text="Y text" htmlUrl="https://y.example.com"/>
+		</body>
+	</opml>
+	`), 0o600))
+
+	app.cfg.DefaultBlog = "en"
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang: "en",
+			Blogroll: &configBlogroll{
+				Enabled: true,
+				Opml:    opmlFile,
+			},
+		},
+	}
+
+	require.NoError(t, app.initConfig(false))
+	assert.Equal(t, defaultBlogrollRefresh, app.cfg.Blogs["en"].Blogroll.Refresh)
+
+	app.refreshBlogrolls()
+
+	outlines, created := app.db.loadOutlineCache("en")
+	if assert.Len(t, outlines, 2) {
+		assert.Equal(t, "Y text", outlines[0].Text)
+	}
+	assert.WithinDuration(t, time.Now(), created, time.Minute)
 }
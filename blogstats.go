@@ -25,13 +25,26 @@ func (a *goBlog) initBlogStats() {
 }
 
 func (a *goBlog) serveBlogStats(w http.ResponseWriter, r *http.Request) {
-	_, bc := a.getBlog(r)
+	blog, bc := a.getBlog(r)
 	canonical := bc.getRelativePath(defaultIfEmpty(bc.BlogStats.Path, defaultBlogStatsPath))
+	data := &blogStatsRenderData{
+		tableUrl: canonical + blogStatsTablePath,
+	}
+	if a.apEnabled() {
+		// ActivityPub followers
+		var err error
+		if data.followersHistory, err = a.db.apFollowersHistory(blog); err != nil {
+			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data.followersInstances, err = a.db.apFollowersInstances(blog); err != nil {
+			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 	a.render(w, r, a.renderBlogStats, &renderData{
 		Canonical: a.getFullAddress(canonical),
-		Data: &blogStatsRenderData{
-			tableUrl: canonical + blogStatsTablePath,
-		},
+		Data:      data,
 	})
 }
 
@@ -1,28 +1,47 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"log"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/dgraph-io/ristretto"
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5"
+	"github.com/samber/lo"
 	"go.goblog.app/app/pkgs/bufferpool"
+	"go.goblog.app/app/pkgs/httpcompress"
 	"golang.org/x/sync/singleflight"
 )
 
 const (
 	cacheLoggedInKey   contextKey = "cacheLoggedIn"
 	cacheExpirationKey contextKey = "cacheExpiration"
+	cachePostKey       contextKey = "cachePost"
 
 	cacheControl = "Cache-Control"
+
+	cachePath = "/cache"
+
+	// Smaller bodies aren't worth compressing
+	cachePrecompressMinSize = 1024
 )
 
 type cache struct {
-	g singleflight.Group
-	c *ristretto.Cache
+	g  singleflight.Group // rendering
+	rg singleflight.Group // background revalidation
+	c  *cacheStore
+	// Feeds have their own store and expiration, so they aren't evicted by the pages
+	f              *cacheStore
+	feedExpiration int
+	// Counters for the metrics
+	hits, staleHits, misses atomic.Uint64
 }
 
 func (a *goBlog) initCache() (err error) {
@@ -31,17 +50,24 @@ func (a *goBlog) initCache() (err error) {
 		// Cache disabled
 		return nil
 	}
-	a.cache.c, err = ristretto.NewCache(&ristretto.Config{
-		NumCounters: 40 * 1000,        // 4000 items when full with 5 KB items -> x10 = 40.000
-		MaxCost:     20 * 1000 * 1000, // 20 MB
-		BufferItems: 64,               // recommended
-		Metrics:     true,
-	})
+	maxSize := 20 // MB
+	if a.cfg.Cache != nil && a.cfg.Cache.MaxSize > 0 {
+		maxSize = a.cfg.Cache.MaxSize
+	}
+	a.cache.c = newCacheStore(int64(maxSize) * 1000 * 1000)
+	feedMaxSize := 10 // MB
+	if fc := a.cfg.Feeds; fc != nil {
+		if fc.MaxSize > 0 {
+			feedMaxSize = fc.MaxSize
+		}
+		a.cache.feedExpiration = fc.Expiration
+	}
+	a.cache.f = newCacheStore(int64(feedMaxSize) * 1000 * 1000)
 	go func() {
 		ticker := time.NewTicker(15 * time.Minute)
 		for range ticker.C {
-			met := a.cache.c.Metrics
-			log.Println("\nCache:", met.String())
+			log.Println("Cache:", a.cache.c.metrics())
+			log.Println("Feed cache:", a.cache.f.metrics())
 		}
 	}()
 	return
@@ -53,6 +79,13 @@ func cacheLoggedIn(next http.Handler) http.Handler {
 	})
 }
 
+// Mark cache items as single post pages, they are only purged when the post itself changes
+func cachePostPage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cachePostKey, true)))
+	})
+}
+
 func (a *goBlog) cacheMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Do checks
@@ -71,15 +104,34 @@ func (a *goBlog) cacheMiddleware(next http.Handler) http.Handler {
 		}
 		// Search and serve cache
 		key := cacheKey(r)
+		if a.cfgReloads > 0 {
+			// Renders of requests still using the old config don't end up in the cache of the new one
+			key = strconv.Itoa(a.cfgReloads) + "-" + key
+		}
 		// Get cache or render it
 		cacheInterface, _, _ := a.cache.g.Do(key, func() (any, error) {
 			return a.cache.getCache(key, next, r), nil
 		})
 		ci := cacheInterface.(*cacheItem)
+		if ci.cspNonce != "" && ci.cspNonce != cspNonce(r) {
+			// Rendered for a concurrent request, the body contains the CSP nonce of that request
+			next.ServeHTTP(w, r)
+			return
+		}
 		// copy and set headers
 		a.setCacheHeaders(w, ci)
+		// serve the precompressed body if the client accepts it, each encoding has its own ETag
+		body, eTag := ci.body, ci.eTag
+		if ci.brotli != nil || ci.gzip != nil {
+			httpcompress.AddVary(w.Header())
+			if encoding, compressed := ci.compressed(httpcompress.AcceptedEncodings(r)); encoding != "" {
+				body, eTag = compressed, strings.TrimSuffix(eTag, `"`)+"-"+encoding+`"`
+				w.Header().Set("Content-Encoding", encoding)
+				w.Header().Set("ETag", eTag)
+			}
+		}
 		// check conditional request
-		if ifNoneMatchHeader := r.Header.Get("If-None-Match"); ifNoneMatchHeader != "" && ifNoneMatchHeader == ci.eTag {
+		if notModified(r, eTag, ci.lastModified) {
 			// send 304
 			w.WriteHeader(http.StatusNotModified)
 			return
@@ -87,7 +139,7 @@ func (a *goBlog) cacheMiddleware(next http.Handler) http.Handler {
 		// set status code
 		w.WriteHeader(ci.code)
 		// write cached body
-		_, _ = w.Write(ci.body)
+		_, _ = w.Write(body)
 	})
 }
 
@@ -98,6 +150,10 @@ func cacheable(r *http.Request) bool {
 	if r.URL.Query().Get("cache") == "0" || r.URL.Query().Get("cache") == "false" {
 		return false
 	}
+	if getPreviewFlags(r) != nil {
+		// Signed previews never use or fill the cache
+		return false
+	}
 	return true
 }
 
@@ -144,16 +200,31 @@ func (a *goBlog) setCacheHeaders(w http.ResponseWriter, cache *cacheItem) {
 		w.Header()[k] = v
 	}
 	// Set cache headers
-	w.Header().Set("ETag", cache.eTag)
-	w.Header().Set(cacheControl, "public,no-cache")
+	setConditionalHeaders(w, cache.eTag, cache.lastModified)
+	if !cache.immutable() {
+		w.Header().Set(cacheControl, "public,no-cache")
+	}
 }
 
 type cacheItem struct {
 	expiration int
-	eTag       string
-	code       int
-	header     http.Header
-	body       []byte
+	created    time.Time
+	// Set by the handler (like for feeds) or the time of rendering
+	lastModified time.Time
+	path         string
+	post         bool
+	eTag         string
+	code         int
+	header       http.Header
+	body         []byte
+	cspNonce     string
+	// Precompressed bodies, nil if not compressible or not smaller
+	brotli, gzip []byte
+}
+
+// Items that the handler marked as immutable (like feeds of past years) keep their Cache-Control header
+func (ci *cacheItem) immutable() bool {
+	return strings.Contains(ci.header.Get(cacheControl), "immutable")
 }
 
 // Calculate byte size of cache item using size of header, body and etag
@@ -162,15 +233,97 @@ func (ci *cacheItem) cost() int {
 	_ = ci.header.Write(headerBuf)
 	headerSize := len(headerBuf.Bytes())
 	bufferpool.Put(headerBuf)
-	return headerSize + len(ci.body) + len(ci.eTag)
+	return headerSize + len(ci.body) + len(ci.brotli) + len(ci.gzip) + len(ci.eTag)
+}
+
+// Body for the first supported of the accepted encodings
+func (ci *cacheItem) compressed(accepted []string) (encoding string, body []byte) {
+	if ci.brotli != nil && lo.Contains(accepted, "br") {
+		return "br", ci.brotli
+	} else if ci.gzip != nil && lo.Contains(accepted, "gzip") {
+		return "gzip", ci.gzip
+	}
+	return "", nil
+}
+
+// Compress cacheable bodies once, so they don't need to be compressed with every hit
+func (ci *cacheItem) precompress() {
+	if len(ci.body) < cachePrecompressMinSize || ci.header.Get("Content-Encoding") != "" || !httpcompress.IsCompressible(ci.header.Get(contentType)) {
+		return
+	}
+	var err error
+	if ci.brotli, ci.gzip, err = precompress(ci.body, brotli.DefaultCompression); err != nil {
+		log.Println("Cache: failed to compress", ci.path+":", err.Error())
+		ci.brotli, ci.gzip = nil, nil
+	}
+}
+
+// Items without expiration stay fresh until they get purged
+func (ci *cacheItem) fresh(now time.Time) bool {
+	return ci.expiration == 0 || now.Before(ci.created.Add(time.Duration(ci.expiration)*time.Second))
+}
+
+// Expired items are served for the same duration again while they are revalidated in the background
+func (ci *cacheItem) stale(now time.Time) bool {
+	return ci.expiration != 0 && now.Before(ci.created.Add(2*time.Duration(ci.expiration)*time.Second))
+}
+
+// Feeds (routes with the feed parameter) are cached in the feed store
+func (c *cache) store(r *http.Request) (store *cacheStore, feed bool) {
+	if c.f != nil && chi.URLParam(r, "feed") != "" {
+		return c.f, true
+	}
+	return c.c, false
+}
+
+func (c *cache) stores() []*cacheStore {
+	if c.f == nil {
+		return []*cacheStore{c.c}
+	}
+	return []*cacheStore{c.c, c.f}
 }
 
 func (c *cache) getCache(key string, next http.Handler, r *http.Request) *cacheItem {
-	if rItem, ok := c.c.Get(key); ok {
-		return rItem.(*cacheItem)
+	store, _ := c.store(r)
+	item, ok := store.get(key)
+	if ok {
+		now := time.Now()
+		if item.fresh(now) {
+			c.hits.Add(1)
+			return item
+		}
+		if item.stale(now) {
+			c.staleHits.Add(1)
+			// Serve stale item and revalidate in the background
+			cr := cacheRequest(r)
+			go func() {
+				_, _, _ = c.rg.Do(key, func() (any, error) {
+					return c.render(key, next, cr), nil
+				})
+			}()
+			return item
+		}
+	}
+	// No (usable) cache available
+	c.misses.Add(1)
+	rendered := c.render(key, next, cacheRequest(r))
+	if ok && rendered.code >= http.StatusInternalServerError {
+		// Rendering failed (e.g. because the database is locked during a backup), serve the old item instead
+		return item.staleCopy()
 	}
-	// No cache available
-	// Make and use copy of r
+	return rendered
+}
+
+// Copy of the item with a warning header, for serving it after it expired
+func (ci *cacheItem) staleCopy() *cacheItem {
+	stale := *ci
+	stale.header = ci.header.Clone()
+	stale.header.Set("Warning", `110 - "Response is Stale"`)
+	return &stale
+}
+
+// Make a copy of r for rendering the cache, that isn't canceled with the original request
+func cacheRequest(r *http.Request) *http.Request {
 	cr := r.Clone(valueOnlyContext{r.Context()})
 	// Remove problematic headers
 	cr.Header.Del("If-Modified-Since")
@@ -179,33 +332,117 @@ func (c *cache) getCache(key string, next http.Handler, r *http.Request) *cacheI
 	cr.Header.Del("If-Match")
 	cr.Header.Del("If-Range")
 	cr.Header.Del("Range")
+	// Items are cached uncompressed, the cache chooses the encoding for each request
+	cr.Header.Del("Accept-Encoding")
+	return cr
+}
+
+func (c *cache) render(key string, next http.Handler, cr *http.Request) *cacheItem {
 	// Record request
 	rec := newCacheRecorder()
 	next.ServeHTTP(rec, cr)
 	item := rec.finish()
+	item.created = time.Now()
+	item.lastModified = item.created
+	if lm, err := http.ParseTime(item.header.Get("Last-Modified")); err == nil && !lm.IsZero() {
+		item.lastModified = lm
+	}
+	item.path = cr.URL.Path
 	// Set expiration
-	item.expiration, _ = cr.Context().Value(cacheExpirationKey).(int)
-	// Remove problematic headers
+	store, feed := c.store(cr)
+	if item.immutable() {
+		// Only purged when posts change
+		item.expiration = 0
+	} else if feed {
+		item.expiration = c.feedExpiration
+	} else {
+		item.expiration, _ = cr.Context().Value(cacheExpirationKey).(int)
+	}
+	item.post, _ = cr.Context().Value(cachePostKey).(bool)
+	// Remember the CSP nonce if the body uses it, nonces must not be reused for other requests
+	if nonce := cspNonce(cr); nonce != "" && bytes.Contains(item.body, []byte(nonce)) {
+		item.cspNonce = nonce
+	}
+	// Remove problematic headers, the validators are set from the item
 	item.header.Del("Accept-Ranges")
 	item.header.Del("ETag")
 	item.header.Del("Last-Modified")
-	// Save cache
-	if cch := item.header.Get(cacheControl); !containsStrings(cch, "no-store", "private", "no-cache") {
-		cost := int64(item.cost())
-		if item.expiration == 0 {
-			c.c.Set(key, item, cost)
-		} else {
-			c.c.SetWithTTL(key, item, cost, time.Duration(item.expiration)*time.Second)
-		}
+	// Save cache, but keep the previous item on server errors
+	if item.code >= http.StatusInternalServerError {
+		return item
+	}
+	if cch := item.header.Get(cacheControl); item.cspNonce == "" && !containsStrings(cch, "no-store", "private", "no-cache") {
+		item.precompress()
+		store.set(key, item, int64(item.cost()))
+	} else {
+		store.delete(key)
 	}
 	return item
 }
 
+// Purge the complete cache
 func (c *cache) purge() {
-	if c == nil {
+	if c == nil || c.c == nil {
+		return
+	}
+	for _, store := range c.stores() {
+		store.clear()
+	}
+}
+
+// Purge the cache for the paths, including their feeds and pagination
+func (c *cache) purgePaths(paths ...string) {
+	if c == nil || c.c == nil {
 		return
 	}
-	c.c.Clear()
+	for _, store := range c.stores() {
+		store.deleteFunc(func(_ string, item *cacheItem) bool {
+			return cachePathMatches(item.path, paths)
+		})
+	}
+}
+
+// Purge the cache for a changed post: the post itself and all pages that aren't single posts,
+// like the home, section and taxonomy indexes, feeds and sitemaps
+func (c *cache) purgePost(paths ...string) {
+	if c == nil || c.c == nil {
+		return
+	}
+	for _, store := range c.stores() {
+		store.deleteFunc(func(_ string, item *cacheItem) bool {
+			return !item.post || cachePathMatches(item.path, paths)
+		})
+	}
+}
+
+func cachePathMatches(itemPath string, paths []string) bool {
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if itemPath == path {
+			return true
+		}
+		// Feeds (e.g. /posts.rss or /.rss) and pagination (e.g. /posts/page/2)
+		if strings.HasPrefix(itemPath, path+".") || strings.HasPrefix(itemPath, strings.TrimSuffix(path, "/")+"/page/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Manually purge the cache for the given paths or completely
+func (a *goBlog) serveCachePurge(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if paths := r.Form["path"]; len(paths) > 0 {
+		a.cache.purgePaths(paths...)
+	} else {
+		a.cache.purge()
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (a *goBlog) defaultCacheExpiration() int {
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"crypto/sha256"
-	"fmt"
 	"net/http"
 )
 
@@ -25,7 +23,7 @@ func (c *cacheRecorder) finish() *cacheItem {
 	c.done = true
 	c.item.eTag = c.item.header.Get("ETag")
 	if c.item.eTag == "" {
-		c.item.eTag = fmt.Sprintf("%x", sha256.Sum256(c.item.body))
+		c.item.eTag = contentETag(c.item.body)
 	}
 	return &c.item
 }
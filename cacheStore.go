@@ -0,0 +1,107 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// Size-bounded LRU store for the HTTP cache
+type cacheStore struct {
+	mu      sync.Mutex
+	maxCost int64
+	cost    int64
+	ll      *list.List
+	items   map[string]*list.Element
+	// Metrics
+	hits, misses, evictions uint64
+}
+
+type cacheStoreEntry struct {
+	key  string
+	item *cacheItem
+	cost int64
+}
+
+func newCacheStore(maxCost int64) *cacheStore {
+	return &cacheStore{
+		maxCost: maxCost,
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+func (s *cacheStore) get(key string) (*cacheItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		s.misses++
+		return nil, false
+	}
+	s.hits++
+	s.ll.MoveToFront(e)
+	return e.Value.(*cacheStoreEntry).item, true
+}
+
+func (s *cacheStore) set(key string, item *cacheItem, cost int64) {
+	if cost > s.maxCost {
+		// Too large to cache
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.removeElement(e)
+	}
+	s.items[key] = s.ll.PushFront(&cacheStoreEntry{key: key, item: item, cost: cost})
+	s.cost += cost
+	// Evict least recently used items
+	for s.cost > s.maxCost {
+		s.removeElement(s.ll.Back())
+		s.evictions++
+	}
+}
+
+func (s *cacheStore) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.removeElement(e)
+	}
+}
+
+// Delete all items for which the function returns true, returns the number of deleted items
+func (s *cacheStore) deleteFunc(f func(key string, item *cacheItem) bool) (deleted int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for e := s.ll.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*cacheStoreEntry); f(entry.key, entry.item) {
+			s.removeElement(e)
+			deleted++
+		}
+		e = next
+	}
+	return deleted
+}
+
+func (s *cacheStore) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ll.Init()
+	s.items = map[string]*list.Element{}
+	s.cost = 0
+}
+
+func (s *cacheStore) removeElement(e *list.Element) {
+	entry := s.ll.Remove(e).(*cacheStoreEntry)
+	delete(s.items, entry.key)
+	s.cost -= entry.cost
+}
+
+func (s *cacheStore) metrics() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("items: %d, size: %d bytes, hits: %d, misses: %d, evictions: %d", len(s.items), s.cost, s.hits, s.misses, s.evictions)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/samber/lo"
+)
+
+var cacheWarmFeedTypes = []feedType{rssFeed, atomFeed, jsonFeed}
+
+// Render the most requested pages in the background, so they are already cached for the first visitors
+func (a *goBlog) warmCache(handler http.Handler) {
+	if a.cache.c == nil || a.cfg.Cache == nil || !a.cfg.Cache.Warm || a.isPrivate() {
+		return
+	}
+	go func() {
+		paths := a.cacheWarmPaths()
+		warmed := a.warmPaths(handler, paths)
+		log.Printf("Warmed cache for %d of %d pages", warmed, len(paths))
+	}()
+}
+
+// Request the paths and return the number of successfully rendered ones
+func (a *goBlog) warmPaths(handler http.Handler, paths []string) (warmed int) {
+	for _, path := range paths {
+		req, err := http.NewRequest(http.MethodGet, a.getFullAddress(path), nil)
+		if err != nil {
+			continue
+		}
+		res, err := doHandlerRequest(req, handler)
+		if err != nil {
+			log.Println("Failed to warm cache for", path, err.Error())
+			continue
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			warmed++
+		}
+	}
+	return warmed
+}
+
+// Home pages, first index pages of the sections and their feeds
+func (a *goBlog) cacheWarmPaths() (paths []string) {
+	withFeeds := func(path string) {
+		paths = append(paths, path)
+		for _, f := range cacheWarmFeedTypes {
+			paths = append(paths, path+"."+string(f))
+		}
+	}
+	blogs := lo.Keys(a.cfg.Blogs)
+	sort.Strings(blogs)
+	for _, blog := range blogs {
+		bc := a.cfg.Blogs[blog]
+		if bc.isProtected() {
+			continue
+		}
+		if bc.PostAsHome {
+			paths = append(paths, bc.getRelativePath(""))
+		} else {
+			withFeeds(bc.getRelativePath(""))
+		}
+		sections := lo.Keys(bc.Sections)
+		sort.Strings(sections)
+		for _, section := range sections {
+			withFeeds(bc.getRelativePath(section))
+		}
+	}
+	return paths
+}
+
+// Feeds are the most requested paths, so render the feeds of the blog and the section again
+// right after a post changed instead of waiting for the next feed reader
+func (a *goBlog) initFeedRegeneration() {
+	regenerate := func(p *post) {
+		if a.d == nil || a.cache.f == nil || a.isPrivate() {
+			return
+		}
+		a.warmPaths(a.d, a.feedRegenerationPaths(p))
+	}
+	a.pPostHooks = append(a.pPostHooks, regenerate)
+	a.pUpdateHooks = append(a.pUpdateHooks, regenerate)
+	a.pDeleteHooks = append(a.pDeleteHooks, regenerate)
+	a.pUndeleteHooks = append(a.pUndeleteHooks, regenerate)
+}
+
+func (a *goBlog) feedRegenerationPaths(p *post) (paths []string) {
+	bc, ok := a.cfg.Blogs[p.Blog]
+	if !ok || bc.isProtected() {
+		return nil
+	}
+	bases := []string{}
+	if !bc.PostAsHome {
+		bases = append(bases, bc.getRelativePath(""))
+	}
+	if _, ok := bc.Sections[p.Section]; ok {
+		bases = append(bases, bc.getRelativePath(p.Section))
+	}
+	for _, base := range bases {
+		for _, f := range cacheWarmFeedTypes {
+			paths = append(paths, base+"."+string(f))
+		}
+	}
+	return paths
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_warmCache(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Cache.Warm = true
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang: "en",
+			Sections: map[string]*configSection{
+				"posts": {Name: "posts"},
+			},
+		},
+		"de": {
+			Lang: "de",
+			Path: "/de",
+			Sections: map[string]*configSection{
+				"posts": {Name: "posts"},
+			},
+			Protection: &configBlogProtection{Enabled: true, Passphrase: "secret"},
+		},
+	}
+	app.cfg.DefaultBlog = "en"
+
+	require.NoError(t, app.initConfig(false))
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initMarkdown()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	assert.Equal(t, []string{
+		"/", "/.rss", "/.atom", "/.json",
+		"/posts", "/posts.rss", "/posts.atom", "/posts.json",
+	}, app.cacheWarmPaths())
+
+	app.warmCache(app.d)
+
+	cached := func(store *cacheStore, path string) bool {
+		_, ok := store.get(cacheKey(httptest.NewRequest(http.MethodGet, path, nil)))
+		return ok
+	}
+	require.Eventually(t, func() bool {
+		return cached(app.cache.f, "/posts.json")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, cached(app.cache.c, "/"))
+	assert.True(t, cached(app.cache.f, "/.rss"))
+	assert.True(t, cached(app.cache.c, "/posts"))
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/dgraph-io/ristretto"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Benchmark_cacheItem_cost(b *testing.B) {
@@ -52,12 +58,7 @@ func Benchmark_cacheKey(b *testing.B) {
 }
 
 func Benchmark_cache_getCache(b *testing.B) {
-	c := &cache{}
-	c.c, _ = ristretto.NewCache(&ristretto.Config{
-		NumCounters: 40 * 1000,
-		MaxCost:     20 * 1000 * 1000,
-		BufferItems: 64,
-	})
+	c := &cache{c: newCacheStore(20 * 1000 * 1000)}
 	req := httptest.NewRequest(http.MethodGet, "/abc?abc=def&hij=klm", nil)
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, _ = io.WriteString(w, "abcdefghijklmnopqrstuvwxyz")
@@ -67,3 +68,302 @@ func Benchmark_cache_getCache(b *testing.B) {
 		c.getCache(strconv.Itoa(i), handler, req)
 	}
 }
+
+func Test_cacheStore(t *testing.T) {
+	s := newCacheStore(100)
+
+	s.set("a", &cacheItem{}, 40)
+	s.set("b", &cacheItem{}, 40)
+	_, ok := s.get("a")
+	assert.True(t, ok)
+
+	// Evicts the least recently used item "b"
+	s.set("c", &cacheItem{}, 40)
+	_, ok = s.get("b")
+	assert.False(t, ok)
+	_, ok = s.get("a")
+	assert.True(t, ok)
+	_, ok = s.get("c")
+	assert.True(t, ok)
+
+	// Too large
+	s.set("d", &cacheItem{}, 101)
+	_, ok = s.get("d")
+	assert.False(t, ok)
+
+	// Replace
+	s.set("a", &cacheItem{code: 201}, 10)
+	item, ok := s.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 201, item.code)
+	assert.Equal(t, int64(50), s.cost)
+
+	assert.Equal(t, 1, s.deleteFunc(func(key string, _ *cacheItem) bool { return key == "c" }))
+	assert.Equal(t, int64(10), s.cost)
+
+	s.clear()
+	_, ok = s.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), s.cost)
+}
+
+func Test_cacheStaleWhileRevalidate(t *testing.T) {
+	c := &cache{c: newCacheStore(20 * 1000 * 1000)}
+
+	var renders atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strconv.Itoa(int(renders.Add(1))))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req = req.WithContext(context.WithValue(req.Context(), cacheExpirationKey, 60))
+
+	item := c.getCache("abc", handler, req)
+	assert.Equal(t, "1", string(item.body))
+
+	// Fresh
+	item = c.getCache("abc", handler, req)
+	assert.Equal(t, "1", string(item.body))
+
+	// Stale, serve old item and revalidate in the background
+	item.created = time.Now().Add(-90 * time.Second)
+	item = c.getCache("abc", handler, req)
+	assert.Equal(t, "1", string(item.body))
+	require.Eventually(t, func() bool {
+		item, _ := c.c.get("abc")
+		return string(item.body) == "2"
+	}, time.Second, 10*time.Millisecond)
+
+	// Too old, render synchronously
+	item, _ = c.c.get("abc")
+	item.created = time.Now().Add(-150 * time.Second)
+	item = c.getCache("abc", handler, req)
+	assert.Equal(t, "3", string(item.body))
+}
+
+func Test_cacheServeStaleOnError(t *testing.T) {
+	c := &cache{c: newCacheStore(20 * 1000 * 1000)}
+
+	var fail atomic.Bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "database is locked", http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, "page")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req = req.WithContext(context.WithValue(req.Context(), cacheExpirationKey, 60))
+
+	item := c.getCache("abc", handler, req)
+	assert.Equal(t, "page", string(item.body))
+
+	// Rendering fails, serve the old item with a warning
+	fail.Store(true)
+	item.created = time.Now().Add(-150 * time.Second)
+	item = c.getCache("abc", handler, req)
+	assert.Equal(t, http.StatusOK, item.code)
+	assert.Equal(t, "page", string(item.body))
+	assert.NotEmpty(t, item.header.Get("Warning"))
+
+	// The old item stays cached without the warning
+	cached, ok := c.c.get("abc")
+	require.True(t, ok)
+	assert.Equal(t, "page", string(cached.body))
+	assert.Empty(t, cached.header.Get("Warning"))
+
+	// Errors without an old item are served, but not cached
+	item = c.getCache("def", handler, req)
+	assert.Equal(t, http.StatusInternalServerError, item.code)
+	_, ok = c.c.get("def")
+	assert.False(t, ok)
+}
+
+func Test_cachePurge(t *testing.T) {
+	c := &cache{c: newCacheStore(20 * 1000 * 1000)}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	render := func(path string, post bool) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if post {
+			req = req.WithContext(context.WithValue(req.Context(), cachePostKey, true))
+		}
+		c.getCache(cacheKey(req), handler, req)
+	}
+	cached := func(path string) bool {
+		_, ok := c.c.get(cacheKey(httptest.NewRequest(http.MethodGet, path, nil)))
+		return ok
+	}
+	renderAll := func() {
+		for _, path := range []string{"/", "/.rss", "/page/2", "/posts", "/posts.atom", "/tags/test", "/robots.txt"} {
+			render(path, false)
+		}
+		render("/posts/a", true)
+		render("/posts/b", true)
+	}
+
+	t.Run("Paths", func(t *testing.T) {
+		renderAll()
+		c.purgePaths("/")
+		assert.False(t, cached("/"))
+		assert.False(t, cached("/.rss"))
+		assert.False(t, cached("/page/2"))
+		assert.True(t, cached("/posts"))
+		assert.True(t, cached("/posts/a"))
+
+		c.purgePaths("/posts")
+		assert.False(t, cached("/posts"))
+		assert.False(t, cached("/posts.atom"))
+		assert.True(t, cached("/posts/a"))
+	})
+
+	t.Run("Post", func(t *testing.T) {
+		renderAll()
+		c.purgePost("/posts/a")
+		assert.False(t, cached("/posts/a"))
+		assert.True(t, cached("/posts/b"))
+		for _, path := range []string{"/", "/.rss", "/page/2", "/posts", "/posts.atom", "/tags/test", "/robots.txt"} {
+			assert.False(t, cached(path), path)
+		}
+	})
+
+	t.Run("All", func(t *testing.T) {
+		renderAll()
+		c.purge()
+		assert.False(t, cached("/posts/b"))
+		assert.False(t, cached("/"))
+	})
+}
+
+func Test_serveCachePurge(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	_ = app.initConfig(false)
+	_ = app.initCache()
+
+	app.cache.c.set("/a", &cacheItem{path: "/a"}, 1)
+	app.cache.c.set("/b", &cacheItem{path: "/b"}, 1)
+
+	req := httptest.NewRequest(http.MethodPost, cachePath+"/purge?path=/a", nil)
+	rec := httptest.NewRecorder()
+	app.serveCachePurge(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	_, ok := app.cache.c.get("/a")
+	assert.False(t, ok)
+	_, ok = app.cache.c.get("/b")
+	assert.True(t, ok)
+
+	req = httptest.NewRequest(http.MethodPost, cachePath+"/purge", nil)
+	rec = httptest.NewRecorder()
+	app.serveCachePurge(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	_, ok = app.cache.c.get("/b")
+	assert.False(t, ok)
+}
+
+func Test_cachePrecompressed(t *testing.T) {
+	h := newTestHarness(t)
+	h.createPost(&post{Path: "/long", Content: strings.Repeat("A long post that is worth compressing. ", 100)})
+
+	get := func(acceptEncoding, ifNoneMatch string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/long", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		h.app.d.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+	read := func(res *http.Response) string {
+		defer res.Body.Close()
+		var r io.Reader = res.Body
+		switch res.Header.Get("Content-Encoding") {
+		case "br":
+			r = brotli.NewReader(res.Body)
+		case "gzip":
+			gr, err := gzip.NewReader(res.Body)
+			require.NoError(t, err)
+			r = gr
+		}
+		body, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	plainRes := get("", "")
+	assert.Empty(t, plainRes.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", plainRes.Header.Get("Vary"))
+	plain := read(plainRes)
+	assert.Contains(t, plain, "A long post that is worth compressing.")
+
+	ci, ok := h.app.cache.c.get("/long")
+	require.True(t, ok)
+	require.NotNil(t, ci.brotli)
+	require.NotNil(t, ci.gzip)
+
+	brRes := get("gzip, deflate, br", "")
+	assert.Equal(t, "br", brRes.Header.Get("Content-Encoding"))
+	assert.NotEqual(t, plainRes.Header.Get("ETag"), brRes.Header.Get("ETag"))
+	assert.Equal(t, plain, read(brRes))
+
+	gzRes := get("gzip, br;q=0", "")
+	assert.Equal(t, "gzip", gzRes.Header.Get("Content-Encoding"))
+	assert.Equal(t, plain, read(gzRes))
+
+	// Each encoding has its own ETag
+	res := get("br", brRes.Header.Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	_ = res.Body.Close()
+	res = get("gzip", brRes.Header.Get("ETag"))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	_ = res.Body.Close()
+	// Handlers render the cached items without encoding
+	req := httptest.NewRequest(http.MethodGet, "/long", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	assert.Empty(t, cacheRequest(req).Header.Get("Accept-Encoding"))
+}
+
+func Test_cacheCSPNonce(t *testing.T) {
+	h := newTestHarness(t)
+	h.app.cfg.Server.CSP = &configCSP{Nonce: true}
+	rendered := 0
+	handler := h.app.securityHeaders(h.app.cacheMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rendered++
+		if r.URL.Path == "/plain" {
+			_, _ = io.WriteString(w, "<p>Without inline scripts</p>")
+			return
+		}
+		_, _ = io.WriteString(w, `<script nonce="`+cspNonce(r)+`">alert(1)</script>`)
+	})))
+
+	get := func(path string) (nonce, body string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		res := rec.Result()
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		csp := res.Header.Get("Content-Security-Policy")
+		_, nonce, _ = strings.Cut(csp, "'nonce-")
+		nonce, _, _ = strings.Cut(nonce, "'")
+		return nonce, string(b)
+	}
+
+	// Each response uses its own nonce in the header and the body, so it's not cached
+	nonce1, body1 := get("/nonce")
+	nonce2, body2 := get("/nonce")
+	assert.NotEqual(t, nonce1, nonce2)
+	assert.Contains(t, body1, `nonce="`+nonce1+`"`)
+	assert.Contains(t, body2, `nonce="`+nonce2+`"`)
+	assert.Equal(t, 2, rendered)
+	_, ok := h.app.cache.c.get("/nonce")
+	assert.False(t, ok)
+
+	// Responses without the nonce are cached
+	get("/plain")
+	get("/plain")
+	assert.Equal(t, 3, rendered)
+	_, ok = h.app.cache.c.get("/plain")
+	assert.True(t, ok)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem found when checking the config
+type configIssue struct {
+	key     string
+	line    int
+	message string
+}
+
+// Checks templates and settings that only fail at runtime and prints the issues with the line in the config file.
+// Returns the number of issues.
+func (a *goBlog) checkConfig(w io.Writer) (int, error) {
+	lines, err := configFileLines(a.cfgFile)
+	if err != nil {
+		return 0, err
+	}
+	var issues []*configIssue
+	add := func(key string, err error) {
+		issues = append(issues, &configIssue{key: key, line: lines.lookup(key), message: err.Error()})
+	}
+	// Regex redirects
+	for i, rr := range a.cfg.PathRedirects {
+		key := "pathredirects." + strconv.Itoa(i)
+		if _, err := regexp.Compile(rr.From); err != nil {
+			add(key+".from", err)
+		}
+		if rr.Type != 0 && (rr.Type < 300 || rr.Type > 399) {
+			add(key+".type", fmt.Errorf("invalid redirect status code %d", rr.Type))
+		}
+	}
+	// Path templates of sections
+	for blog, bc := range a.cfg.Blogs {
+		for name, section := range bc.Sections {
+			if section.PathTemplate == "" {
+				continue
+			}
+			if err := a.checkPathTemplate(blog, section); err != nil {
+				add("blogs."+strings.ToLower(blog)+".sections."+strings.ToLower(name)+".pathtemplate", err)
+			}
+		}
+	}
+	// Custom pages
+	for blog, bc := range a.cfg.Blogs {
+		for i, page := range bc.Pages {
+			for key, err := range checkPageConfig(bc, page) {
+				add("blogs."+strings.ToLower(blog)+".pages."+strconv.Itoa(i)+"."+strings.ToLower(key), err)
+			}
+		}
+	}
+	// Related posts
+	for blog, bc := range a.cfg.Blogs {
+		if rc := bc.RelatedPosts; rc.enabled() {
+			switch rc.Method {
+			case "", relatedPostsMethodTaxonomies, relatedPostsMethodContent:
+			default:
+				add("blogs."+strings.ToLower(blog)+".relatedposts.method", fmt.Errorf("unknown method %q", rc.Method))
+			}
+		}
+	}
+	// Hook templates
+	if hc := a.cfg.Hooks; hc != nil {
+		for hookType, cmds := range map[string][]string{
+			"postpost": hc.PostPost, "postupdate": hc.PostUpdate, "postdelete": hc.PostDelete, "postundelete": hc.PostUndelete,
+		} {
+			for i, cmd := range cmds {
+				if err := checkHookTemplate(cmd); err != nil {
+					add("hooks."+hookType+"."+strconv.Itoa(i), err)
+				}
+			}
+		}
+	}
+	// Header overrides
+	for i, ho := range a.cfg.Server.HeaderOverrides {
+		if !strings.HasPrefix(ho.PathPrefix, "/") {
+			add("server.headeroverrides."+strconv.Itoa(i)+".pathprefix", fmt.Errorf("path prefix %q must start with a slash", ho.PathPrefix))
+		}
+	}
+	// Page data
+	for i, pd := range a.cfg.PageData {
+		key := "pagedata." + strconv.Itoa(i)
+		if !strings.HasPrefix(pd.Path, "/") {
+			add(key+".path", fmt.Errorf("path %q must start with a slash", pd.Path))
+		}
+		if pd.Source == "" {
+			add(key+".source", errors.New("source is missing"))
+		}
+	}
+	// Print issues sorted by line
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].line < issues[j].line })
+	for _, issue := range issues {
+		if issue.line > 0 {
+			fmt.Fprintf(w, "%s:%d: %s: %s\n", a.cfgFile, issue.line, issue.key, issue.message)
+		} else {
+			fmt.Fprintf(w, "%s: %s: %s\n", a.cfgFile, issue.key, issue.message)
+		}
+	}
+	if len(issues) == 0 {
+		fmt.Fprintln(w, "No issues found in", a.cfgFile)
+	}
+	return len(issues), nil
+}
+
+// Execute the command template with sample data, like executeTemplateCommand does
+func checkHookTemplate(tmpl string) error {
+	cmdTmpl, err := htmlTemplate.New("cmd").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	return cmdTmpl.Execute(io.Discard, map[string]any{
+		"URL": "https://example.com/posts/slug",
+		"Post": &post{
+			Path:       "/posts/slug",
+			Parameters: map[string][]string{"title": {"Title"}},
+			Status:     statusPublished,
+			Visibility: visibilityPublic,
+		},
+	})
+}
+
+// Line numbers of the config keys (lowercase, list items with index)
+type configLines map[string]int
+
+func configFileLines(file string) (configLines, error) {
+	lines := configLines{}
+	if file == "" {
+		return lines, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err = yaml.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	var walk func(n *yaml.Node, key string)
+	walk = func(n *yaml.Node, key string) {
+		if key != "" {
+			lines[key] = n.Line
+		}
+		prefix := key
+		if prefix != "" {
+			prefix += "."
+		}
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, key)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := prefix + strings.ToLower(n.Content[i].Value)
+				walk(n.Content[i+1], k)
+				// Prefer the line of the key
+				lines[k] = n.Content[i].Line
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, prefix+strconv.Itoa(i))
+			}
+		}
+	}
+	walk(&root, "")
+	return lines, nil
+}
+
+// Line of the key or the closest parent key
+func (l configLines) lookup(key string) int {
+	for key != "" {
+		if line, ok := l[key]; ok {
+			return line
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yml")
+	err := os.WriteFile(cfgFile, []byte(`database:
+  file: `+filepath.Join(dir, "blog.db")+`
+blogs:
+  en:
+    sections:
+      posts:
+        pathtemplate: "{{.Section}}/{{.Slug}}"
+      notes:
+        pathtemplate: "/{{.Section}}/{{.Slug}}"
+hooks:
+  postpost:
+    - echo {{.URL}}
+    - echo {{.Post.Unknown}}
+pathRedirects:
+  - from: "^/old$"
+    to: "/new"
+  - from: "^/(old"
+    to: "/new"
+    type: 200
+`), 0600)
+	require.NoError(t, err)
+
+	app := &goBlog{}
+	require.NoError(t, app.loadConfigFile(cfgFile))
+	require.NoError(t, app.initConfig(false))
+
+	var out strings.Builder
+	issues, err := app.checkConfig(&out)
+	require.NoError(t, err)
+	assert.Equal(t, 4, issues)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], cfgFile+":7: blogs.en.sections.posts.pathtemplate: template results in a path without leading slash")
+	assert.Contains(t, lines[1], cfgFile+":13: hooks.postpost.1:")
+	assert.Contains(t, lines[2], cfgFile+":17: pathredirects.1.from: error parsing regexp")
+	assert.Contains(t, lines[3], cfgFile+":19: pathredirects.1.type: invalid redirect status code 200")
+
+	t.Run("No issues", func(t *testing.T) {
+		app.cfg.PathRedirects = nil
+		app.cfg.Hooks = nil
+		app.cfg.Blogs["en"].Sections["posts"].PathTemplate = ""
+		var out strings.Builder
+		issues, err := app.checkConfig(&out)
+		require.NoError(t, err)
+		assert.Equal(t, 0, issues)
+		assert.Contains(t, out.String(), "No issues found")
+	})
+}
+
+func Test_checkConfigPages(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yml")
+	err := os.WriteFile(cfgFile, []byte(`database:
+  file: `+filepath.Join(dir, "blog.db")+`
+blogs:
+  en:
+    pages:
+      - path: /photos
+        sections: [posts]
+        template: photosummary
+      - path: list
+        sections: [unknown]
+        sort: title
+`), 0600)
+	require.NoError(t, err)
+
+	app := &goBlog{}
+	require.NoError(t, app.loadConfigFile(cfgFile))
+	require.NoError(t, app.initConfig(false))
+
+	var out strings.Builder
+	issues, err := app.checkConfig(&out)
+	require.NoError(t, err)
+	assert.Equal(t, 3, issues)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], cfgFile+":9: blogs.en.pages.1.path: path \"list\" must start with a slash")
+	assert.Contains(t, lines[1], cfgFile+":10: blogs.en.pages.1.sections: unknown section \"unknown\"")
+	assert.Contains(t, lines[2], cfgFile+":11: blogs.en.pages.1.sort: invalid sort \"title\"")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/samber/lo"
+)
+
+// Maintenance commands of the binary, so housekeeping doesn't need requests to the authenticated endpoints
+
+const cliUsage = `Usage: GoBlog [--config <file>] [command]
+
+Without command, GoBlog starts the server. Commands:
+
+  check-config                         Check the config file and exit with 1 on issues
+  check                                Check all external links in posts
+  doctor                               Run self-tests of the configured services
+  generate-key totp                    Generate a TOTP secret for the config
+  generate-key activitypub <blog>      Generate a new ActivityPub key for the blog and send it to the followers
+  hash-password <password>             Hash a password for the config
+  user <list|add|password|role|delete> Manage the database users
+  import <hugo|jekyll> <dir> [blog]    Import posts from Hugo or Jekyll
+  export [dir]                         Export all posts as Markdown
+  export hugo [dir]                    Export all posts as Hugo site
+  backup <file>                        Create a backup
+  restore <file>                       Restore a backup (GoBlog must not be running)
+  rebuild-search-index                 Rebuild the full-text search index of the posts
+  resend-activities <path>...          Send the posts to the ActivityPub followers again
+  resend-activities profile [blog]...  Send the profile of the blogs (or all blogs) to the followers again
+  healthcheck                          Check if the server responds
+  help                                 Show this help
+`
+
+func printCliUsage(w io.Writer) {
+	_, _ = io.WriteString(w, cliUsage)
+}
+
+// Tool to generate keys and secrets
+func (a *goBlog) generateKeyTool(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing key type, use totp or activitypub")
+	}
+	switch args[0] {
+	case "totp":
+		key, err := a.generateTOTPKey()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(w, "TOTP-Secret:", key.Secret())
+		return nil
+	case "activitypub":
+		if len(args) < 2 {
+			return errors.New("usage: generate-key activitypub <blog>")
+		}
+		if !a.apEnabled() {
+			return errors.New("ActivityPub isn't enabled")
+		}
+		if _, ok := a.cfg.Blogs[args[1]]; !ok {
+			return errors.New("blog not found")
+		}
+		if err := a.apRotateKey(args[1]); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(w, "Generated new ActivityPub key for", args[1])
+		return nil
+	default:
+		return errors.New("unknown key type, use totp or activitypub")
+	}
+}
+
+// Rebuild the full-text search index and print the number of indexed posts
+func (a *goBlog) rebuildSearchIndex(w io.Writer) error {
+	if err := a.db.rebuildFTSIndex(); err != nil {
+		return err
+	}
+	count, err := a.db.countPosts(&postsRequestConfig{})
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(w, "Rebuilt search index with", count, "posts")
+	return nil
+}
+
+// Queue the activities of posts or profiles again, e.g. after problems with the delivery,
+// the running instance sends them to the followers
+func (a *goBlog) resendActivities(w io.Writer, args []string) error {
+	if !a.apEnabled() {
+		return errors.New("ActivityPub isn't enabled")
+	}
+	if len(args) == 0 {
+		return errors.New("usage: resend-activities <path>... or resend-activities profile [blog]")
+	}
+	if args[0] == "profile" {
+		blogs := args[1:]
+		if len(blogs) == 0 {
+			blogs = lo.Keys(a.cfg.Blogs)
+			sort.Strings(blogs)
+		}
+		for _, blog := range blogs {
+			if _, ok := a.cfg.Blogs[blog]; !ok {
+				return fmt.Errorf("%s: blog not found", blog)
+			}
+			inboxes, err := a.apQueueToAllFollowers(blog, a.apProfileUpdate(blog))
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(w, "Queued profile update of", blog, "for", inboxes, "inboxes")
+		}
+		return nil
+	}
+	for _, path := range args {
+		p, err := a.getPost(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		// Only posts the update hook sends
+		if !p.isPublishedSectionPost() || (p.Visibility != visibilityPublic && p.Visibility != visibilityUnlisted) || a.toAPInteraction(p) != nil {
+			return fmt.Errorf("%s: post can't be sent again", path)
+		}
+		inboxes, err := a.apQueueToAllFollowers(p.Blog, a.apPostUpdate(p))
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(w, "Queued update of", path, "for", inboxes, "inboxes")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_generateKeyTool(t *testing.T) {
+	h := newTestHarness(t, withActivityPub)
+	blog := h.app.cfg.DefaultBlog
+	var out bytes.Buffer
+
+	require.NoError(t, h.app.generateKeyTool(&out, []string{"totp"}))
+	assert.Contains(t, out.String(), "TOTP-Secret: ")
+
+	oldKey, err := h.app.apKey(blog)
+	require.NoError(t, err)
+	require.NoError(t, h.app.generateKeyTool(&out, []string{"activitypub", blog}))
+	newKey, err := h.app.apKey(blog)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldKey.keyId, newKey.keyId)
+
+	assert.Error(t, h.app.generateKeyTool(&out, nil))
+	assert.Error(t, h.app.generateKeyTool(&out, []string{"activitypub"}))
+	assert.Error(t, h.app.generateKeyTool(&out, []string{"activitypub", "unknown"}))
+	assert.Error(t, h.app.generateKeyTool(&out, []string{"other"}))
+}
+
+func Test_rebuildSearchIndex(t *testing.T) {
+	h := newTestHarness(t)
+	h.createPost(&post{Path: "/searchable", Content: "Unique searchable content"})
+
+	var out bytes.Buffer
+	require.NoError(t, h.app.rebuildSearchIndex(&out))
+	assert.Equal(t, "Rebuilt search index with 1 posts\n", out.String())
+
+	posts, err := h.app.getPosts(&postsRequestConfig{search: "searchable"})
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
+
+func Test_resendActivities(t *testing.T) {
+	var out bytes.Buffer
+
+	h := newTestHarness(t, withActivityPub)
+	blog := h.app.cfg.DefaultBlog
+	h.createPost(&post{Path: "/one", Content: "One"})
+	h.createPost(&post{Path: "/private", Content: "Private", Visibility: visibilityPrivate})
+	require.NoError(t, h.app.db.apAddFollower(blog, "https://a.example/users/1", "https://a.example/inbox", "@1@a.example"))
+
+	// Updates that are queued or already sent
+	var sentMutex sync.Mutex
+	var sent []string
+	h.fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &bytes.Buffer{}
+		_, _ = buf.ReadFrom(r.Body)
+		sentMutex.Lock()
+		sent = append(sent, buf.String())
+		sentMutex.Unlock()
+	}))
+	updates := func() []string {
+		activities := []string{}
+		for _, content := range h.dequeueAll("ap") {
+			r := &apRequest{}
+			require.NoError(t, gob.NewDecoder(bytes.NewReader(content)).Decode(r))
+			assert.Equal(t, "https://a.example/inbox", r.To)
+			activities = append(activities, string(r.Activity))
+		}
+		sentMutex.Lock()
+		activities, sent = append(activities, sent...), nil
+		sentMutex.Unlock()
+		// Ignore the create activity of the post hook
+		return lo.Filter(activities, func(activity string, _ int) bool {
+			return strings.Contains(activity, `"type":"Update"`)
+		})
+	}
+
+	require.NoError(t, h.app.resendActivities(&out, []string{"/one"}))
+	activities := updates()
+	require.Len(t, activities, 1)
+	assert.Contains(t, activities[0], "https://example.com/one")
+	assert.Contains(t, out.String(), "Queued update of /one for 1 inboxes")
+
+	require.NoError(t, h.app.resendActivities(&out, []string{"profile", blog}))
+	activities = updates()
+	require.Len(t, activities, 1)
+	assert.Contains(t, activities[0], `"type":"Person"`)
+
+	// Posts that aren't federated and unknown posts
+	assert.Error(t, h.app.resendActivities(&out, []string{"/private"}))
+	assert.Error(t, h.app.resendActivities(&out, []string{"/unknown"}))
+	assert.Error(t, h.app.resendActivities(&out, []string{"profile", "unknown"}))
+	assert.Error(t, h.app.resendActivities(&out, nil))
+	assert.Empty(t, updates())
+
+	// ActivityPub has to be enabled
+	assert.Error(t, newTestHarness(t).app.resendActivities(&out, []string{"/one"}))
+}
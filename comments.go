@@ -9,6 +9,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.goblog.app/app/pkgs/builderpool"
@@ -16,8 +17,20 @@ import (
 
 const commentPath = "/comment"
 
+// All comments and interactions are stored in the comments table,
+// the type tells where they come from
+type commentType string
+
+const (
+	commentTypeWebmention  commentType = "webmention"
+	commentTypeActivityPub commentType = "activitypub"
+	commentTypeForm        commentType = "form"
+	commentTypeEmail       commentType = "email"
+)
+
 type comment struct {
 	ID       int
+	Type     commentType
 	Target   string
 	Name     string
 	Website  string
@@ -54,7 +67,21 @@ func (a *goBlog) createCommentFromRequest(w http.ResponseWriter, r *http.Request
 	comment := r.FormValue("comment")
 	name := r.FormValue("name")
 	website := r.FormValue("website")
-	_, bc := a.getBlog(r)
+	blog, bc := a.getBlog(r)
+	// Honeypot, pretend success to not give bots a hint
+	if spamHoneypotFilled(r) {
+		if targetPath, status, err := a.checkCommentTarget(target); err != nil {
+			a.serveError(w, r, err.Error(), status)
+		} else {
+			http.Redirect(w, r, targetPath, http.StatusFound)
+		}
+		return
+	}
+	// Proof of work and rate limit
+	if status, err := a.checkFormSpam(r, &bc.Comments.configSpamProtection, "comment "+blog); err != nil {
+		a.serveError(w, r, err.Error(), status)
+		return
+	}
 	// Create comment
 	result, errStatus, err := a.createComment(bc, target, comment, name, website, "")
 	if err != nil {
@@ -72,9 +99,9 @@ func (a *goBlog) createComment(bc *configBlog, target, comment, name, website, o
 	if err != nil {
 		return "", status, err
 	}
-	// Check and clean comment
-	comment = cleanHTMLText(comment)
-	if comment == "" {
+	// Check and sanitize comment
+	comment = strings.TrimSpace(a.sanitizeRemoteHTML(comment))
+	if htmlText(comment) == "" {
 		return "", http.StatusBadRequest, errors.New("comment is empty")
 	}
 	name = defaultIfEmpty(cleanHTMLText(name), "Anonymous")
@@ -92,9 +119,14 @@ func (a *goBlog) createComment(bc *configBlog, target, comment, name, website, o
 	}
 	// Insert
 	if updateId == -1 {
+		typ := commentTypeForm
+		if original != "" {
+			typ = commentTypeActivityPub
+		}
 		result, err := a.db.Exec(
-			"insert into comments (target, comment, name, website, original) values (@target, @comment, @name, @website, @original)",
-			sql.Named("target", target), sql.Named("comment", comment), sql.Named("name", name), sql.Named("website", website), sql.Named("original", original),
+			"insert into comments (type, target, comment, name, website, original, created, status) values (@type, @target, @comment, @name, @website, @original, @created, @status)",
+			sql.Named("type", typ), sql.Named("target", a.getFullAddress(target)), sql.Named("comment", comment), sql.Named("name", name),
+			sql.Named("website", website), sql.Named("original", original), sql.Named("created", time.Now().Unix()), sql.Named("status", webmentionStatusNew),
 		)
 		if err != nil {
 			return "", http.StatusInternalServerError, errors.New("failed to save comment to database")
@@ -103,6 +135,14 @@ func (a *goBlog) createComment(bc *configBlog, target, comment, name, website, o
 			return "", http.StatusInternalServerError, errors.New("failed to save comment to database")
 		} else {
 			commentAddress := bc.getRelativePath(fmt.Sprintf("%s/%d", commentPath, commentID))
+			// The comment is also the source of its own webmention, so it gets verified and moderated like all interactions
+			if _, err := a.db.Exec(
+				"update comments set source = @source where id = @id",
+				sql.Named("source", a.getFullAddress(commentAddress)), sql.Named("id", commentID),
+			); err != nil {
+				return "", http.StatusInternalServerError, errors.New("failed to save comment to database")
+			}
+			go a.sendNotification(notificationTypeComment, fmt.Sprintf("New comment from %s to %s", name, a.getFullAddress(target)))
 			// Send webmention
 			_ = a.createWebmention(a.getFullAddress(commentAddress), a.getFullAddress(target))
 			// Return comment path
@@ -140,9 +180,11 @@ type commentsRequestConfig struct {
 func buildCommentsQuery(config *commentsRequestConfig) (query string, args []any) {
 	queryBuilder := builderpool.Get()
 	defer builderpool.Put(queryBuilder)
-	queryBuilder.WriteString("select id, target, name, website, comment, original from comments")
+	// Only comments created on the blog, received webmentions don't have a comment page
+	queryBuilder.WriteString("select id, type, target, name, website, comment, original from comments where type != @webmention")
+	args = append(args, sql.Named("webmention", commentTypeWebmention))
 	if config.id != 0 {
-		queryBuilder.WriteString(" where id = @id")
+		queryBuilder.WriteString(" and id = @id")
 		args = append(args, sql.Named("id", config.id))
 	}
 	queryBuilder.WriteString(" order by id desc")
@@ -162,10 +204,14 @@ func (db *database) getComments(config *commentsRequestConfig) ([]*comment, erro
 	}
 	for rows.Next() {
 		c := &comment{}
-		err = rows.Scan(&c.ID, &c.Target, &c.Name, &c.Website, &c.Comment, &c.Original)
+		err = rows.Scan(&c.ID, &c.Type, &c.Target, &c.Name, &c.Website, &c.Comment, &c.Original)
 		if err != nil {
 			return nil, err
 		}
+		// The target is saved as absolute URL
+		if targetURL, err := url.Parse(c.Target); err == nil {
+			c.Target = targetURL.Path
+		}
 		comments = append(comments, c)
 	}
 	return comments, nil
@@ -197,7 +243,7 @@ func (db *database) deleteComment(id int) error {
 
 func (db *database) commentIdByOriginal(original string) (bool, int, error) {
 	var id int
-	row, err := db.QueryRow("select id from comments where original = @original", sql.Named("original", original))
+	row, err := db.QueryRow("select id from comments where original != '' and original = @original", sql.Named("original", original))
 	if err != nil {
 		return false, 0, err
 	}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,6 +16,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.goblog.app/app/pkgs/contenttype"
+	"go.goblog.app/app/pkgs/htmlbuilder"
 )
 
 func Test_comments(t *testing.T) {
@@ -248,3 +251,132 @@ func Test_commentsUpdateByOriginal(t *testing.T) {
 	assert.Equal(t, "", comment.Website)
 
 }
+
+func Test_commentsUnifiedStorage(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+
+	bc := app.cfg.Blogs[app.cfg.DefaultBlog]
+
+	// Comment from the form is also a mention of the post
+	addr, _, err := app.createComment(bc, "https://example.com/Post", "Test", "Name", "", "")
+	require.NoError(t, err)
+
+	mentions, err := app.db.getWebmentions(&webmentionsRequestConfig{})
+	require.NoError(t, err)
+	require.Len(t, mentions, 1)
+	comment := mentions[0]
+	assert.Equal(t, commentTypeForm, comment.Type)
+	assert.Equal(t, webmentionStatusNew, comment.Status)
+	assert.Equal(t, "https://example.com"+addr, comment.Source)
+	assert.True(t, app.db.webmentionExists(&mention{Source: comment.Source, Target: "https://example.com/post"}))
+
+	// Reply to the comment
+	require.NoError(t, app.db.insertWebmention(&mention{
+		Source:  "https://example.net/reply",
+		Target:  "https://example.com" + addr,
+		Created: time.Now().Unix(),
+		Content: "Reply",
+	}, webmentionStatusVerified))
+
+	// Received webmentions aren't comments with their own page
+	comments, err := app.db.getComments(&commentsRequestConfig{})
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, "/Post", comments[0].Target)
+
+	// Moderation
+	assert.Len(t, app.db.getWebmentionsByAddress("https://example.com/Post"), 0)
+	for _, m := range noError(app.db.getWebmentions(&webmentionsRequestConfig{})) {
+		require.NoError(t, app.db.approveWebmentionId(m.ID))
+	}
+	mentions = app.db.getWebmentionsByAddress("https://example.com/Post")
+	require.Len(t, mentions, 1)
+	assert.Equal(t, comment.ID, mentions[0].ID)
+	if assert.Len(t, mentions[0].Submentions, 1) {
+		assert.Equal(t, "Reply", mentions[0].Submentions[0].Content)
+		assert.Equal(t, commentTypeWebmention, mentions[0].Submentions[0].Type)
+	}
+
+	// Failed verifications only delete received webmentions
+	require.NoError(t, app.db.deleteWebmention(&mention{Source: comment.Source, Target: "https://example.com/Post"}))
+	count, err := app.db.countComments(&commentsRequestConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Deleting removes the comment everywhere
+	require.NoError(t, app.db.deleteWebmentionId(comment.ID))
+	count, err = app.db.countComments(&commentsRequestConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func Test_commentsSpamProtection(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang: "en",
+			Comments: &configComments{
+				Enabled: true,
+				configSpamProtection: configSpamProtection{
+					SpamProtection: spamProtectionNone,
+					RateLimit:      1,
+				},
+			},
+		},
+	}
+	app.cfg.DefaultBlog = "en"
+
+	require.NoError(t, app.initConfig(false))
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initMarkdown()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	submit := func(data url.Values) *http.Response {
+		data.Set("target", "http://localhost:8080/test")
+		data.Set("comment", "This is just a test")
+		req := httptest.NewRequest(http.MethodPost, commentPath, strings.NewReader(data.Encode()))
+		req.Header.Add(contentType, contenttype.WWWForm)
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	// Honeypot filled, redirect without comment
+	res := submit(url.Values{spamHoneypotField: {"https://spam.example"}})
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "/test", res.Header.Get("Location"))
+	count, err := app.db.countComments(&commentsRequestConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// No captcha needed
+	res = submit(url.Values{})
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "/comment/1", res.Header.Get("Location"))
+
+	// Rate limit
+	res = submit(url.Values{})
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	count, err = app.db.countComments(&commentsRequestConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Form disabled
+	app.cfg.Blogs["en"].Comments.DisableForm = true
+	app.d = app.buildRouter()
+	res = submit(url.Values{})
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	buf := &bytes.Buffer{}
+	app.renderInteractions(htmlbuilder.NewHtmlBuilder(buf), &renderData{Blog: app.cfg.Blogs["en"], Canonical: "http://localhost:8080/test"})
+	assert.NotContains(t, buf.String(), `name="comment"`)
+}
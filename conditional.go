@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Conditional requests (RFC 9110): responses get an ETag and Last-Modified header, so clients
+// like browsers and feed readers can revalidate them and get a 304 if nothing changed
+
+// Strong ETag for the content
+func contentETag(body []byte) string {
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+}
+
+// Set the validators, a zero time omits the Last-Modified header
+func setConditionalHeaders(w http.ResponseWriter, eTag string, lastModified time.Time) {
+	if eTag != "" {
+		w.Header().Set("ETag", eTag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Check if the client already has the current version, If-Modified-Since is only used without If-None-Match
+func notModified(r *http.Request, eTag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return eTagListMatches(inm, eTag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// Weak comparison of the ETag with a comma separated list of ETags (or "*")
+func eTagListMatches(list, eTag string) bool {
+	if eTag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || weakETag(candidate) == weakETag(eTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Opaque tag without the weak prefix and quotes
+func weakETag(eTag string) string {
+	return strings.Trim(strings.TrimPrefix(eTag, "W/"), `"`)
+}
+
+// Write the validators and a 304 response if the client has the current version
+func writeNotModified(w http.ResponseWriter, r *http.Request, eTag string, lastModified time.Time) bool {
+	setConditionalHeaders(w, eTag, lastModified)
+	if !notModified(r, eTag, lastModified) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
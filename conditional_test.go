@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_notModified(t *testing.T) {
+	lastModified := time.Date(2023, 5, 1, 12, 0, 0, 500, time.UTC)
+	check := func(header, value string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(header, value)
+		return notModified(req, `"abc"`, lastModified)
+	}
+
+	assert.True(t, check("If-None-Match", `"abc"`))
+	assert.True(t, check("If-None-Match", `W/"abc"`))
+	assert.True(t, check("If-None-Match", `"def", "abc"`))
+	assert.True(t, check("If-None-Match", "*"))
+	assert.False(t, check("If-None-Match", `"def"`))
+
+	assert.True(t, check("If-Modified-Since", lastModified.Format(http.TimeFormat)))
+	assert.True(t, check("If-Modified-Since", lastModified.Add(time.Hour).Format(http.TimeFormat)))
+	assert.False(t, check("If-Modified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat)))
+	assert.False(t, check("If-Modified-Since", "invalid"))
+
+	// If-None-Match takes precedence
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"def"`)
+	req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+	assert.False(t, notModified(req, `"abc"`, lastModified))
+
+	// Only for GET and HEAD
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("If-None-Match", `"abc"`)
+	assert.False(t, notModified(req, `"abc"`, lastModified))
+}
+
+func Test_conditionalRequests(t *testing.T) {
+	h := newTestHarness(t)
+	require.NoError(t, h.app.initTemplateAssets())
+	h.app.d = h.app.buildRouter()
+
+	h.createPost(&post{Path: "/a", Content: "Post /a", Published: "2023-01-01T00:00:00Z", Updated: "2023-01-02T00:00:00Z"})
+
+	serve := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		return h.serve(req)
+	}
+
+	// Feeds use the time of the newest post, so re-rendering doesn't change them
+	rec := serve("/.rss")
+	require.Equal(t, http.StatusOK, rec.Code)
+	eTag := rec.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(eTag, `"`))
+	lastModified := rec.Header().Get("Last-Modified")
+	assert.Equal(t, "Mon, 02 Jan 2023 00:00:00 GMT", lastModified)
+
+	h.app.cache.purge()
+	rec = serve("/.rss", "If-None-Match", eTag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, 0, rec.Body.Len())
+	assert.Equal(t, eTag, rec.Header().Get("ETag"))
+
+	rec = serve("/.rss", "If-Modified-Since", lastModified)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	// New posts change the feed
+	h.createPost(&post{Path: "/b", Content: "Post /b", Published: "2023-02-01T00:00:00Z"})
+	h.app.cache.purge()
+	rec = serve("/.rss", "If-None-Match", eTag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, eTag, rec.Header().Get("ETag"))
+
+	// Posts
+	rec = serve("/a")
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = serve("/a", "If-None-Match", rec.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Assets
+	cssPath := h.app.assetFileName("css/styles.css")
+	rec = serve(cssPath, "Accept-Encoding", "br")
+	require.Equal(t, http.StatusOK, rec.Code)
+	brETag := rec.Header().Get("ETag")
+	assert.True(t, strings.HasSuffix(brETag, `-br"`))
+	assert.NotEmpty(t, rec.Header().Get("Last-Modified"))
+	rec = serve(cssPath, "Accept-Encoding", "br", "If-None-Match", brETag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, 0, rec.Body.Len())
+	rec = serve(cssPath, "If-None-Match", brETag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -3,64 +3,147 @@ package main
 import (
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/microcosm-cc/bluemonday"
 	"github.com/samber/lo"
 	"github.com/spf13/viper"
 	"maunium.net/go/mautrix"
 )
 
 type config struct {
-	Server        *configServer          `mapstructure:"server"`
-	Db            *configDb              `mapstructure:"database"`
-	Cache         *configCache           `mapstructure:"cache"`
-	DefaultBlog   string                 `mapstructure:"defaultblog"`
-	Blogs         map[string]*configBlog `mapstructure:"blogs"`
-	User          *configUser            `mapstructure:"user"`
-	Hooks         *configHooks           `mapstructure:"hooks"`
-	Plugins       []*configPlugin        `mapstructure:"plugins"`
-	Micropub      *configMicropub        `mapstructure:"micropub"`
-	PathRedirects []*configRegexRedirect `mapstructure:"pathRedirects"`
-	ActivityPub   *configActivityPub     `mapstructure:"activityPub"`
-	Webmention    *configWebmention      `mapstructure:"webmention"`
-	Notifications *configNotifications   `mapstructure:"notifications"`
-	PrivateMode   *configPrivateMode     `mapstructure:"privateMode"`
-	IndexNow      *configIndexNow        `mapstructure:"indexNow"`
-	EasterEgg     *configEasterEgg       `mapstructure:"easterEgg"`
-	MapTiles      *configMapTiles        `mapstructure:"mapTiles"`
-	TTS           *configTTS             `mapstructure:"tts"`
-	Reactions     *configReactions       `mapstructure:"reactions"`
-	Pprof         *configPprof           `mapstructure:"pprof"`
-	Debug         bool                   `mapstructure:"debug"`
+	Server        *configServer            `mapstructure:"server"`
+	Db            *configDb                `mapstructure:"database"`
+	Cache         *configCache             `mapstructure:"cache"`
+	Feeds         *configFeeds             `mapstructure:"feeds"`
+	Links         *configLinks             `mapstructure:"links"`
+	Sanitization  *configSanitization      `mapstructure:"sanitization"`
+	DefaultBlog   string                   `mapstructure:"defaultblog"`
+	Blogs         map[string]*configBlog   `mapstructure:"blogs"`
+	User          *configUser              `mapstructure:"user"`
+	Authors       map[string]*configAuthor `mapstructure:"authors"`
+	Hooks         *configHooks             `mapstructure:"hooks"`
+	Plugins       []*configPlugin          `mapstructure:"plugins"`
+	Micropub      *configMicropub          `mapstructure:"micropub"`
+	PathRedirects []*configRegexRedirect   `mapstructure:"pathRedirects"`
+	ActivityPub   *configActivityPub       `mapstructure:"activityPub"`
+	Webmention    *configWebmention        `mapstructure:"webmention"`
+	Notifications *configNotifications     `mapstructure:"notifications"`
+	Syndication   *configSyndication       `mapstructure:"syndication"`
+	PrivateMode   *configPrivateMode       `mapstructure:"privateMode"`
+	IndexNow      *configIndexNow          `mapstructure:"indexNow"`
+	SitemapPing   *configSitemapPing       `mapstructure:"sitemapPing"`
+	LinkArchive   *configLinkArchive       `mapstructure:"linkArchive"`
+	Libravatar    *configLibravatar        `mapstructure:"libravatar"`
+	Analytics     *configAnalytics         `mapstructure:"analytics"`
+	Snapshots     *configSnapshots         `mapstructure:"snapshots"`
+	PageData      []*configPageData        `mapstructure:"pageData"`
+	Backup        *configBackup            `mapstructure:"backup"`
+	EasterEgg     *configEasterEgg         `mapstructure:"easterEgg"`
+	MapTiles      *configMapTiles          `mapstructure:"mapTiles"`
+	TTS           *configTTS               `mapstructure:"tts"`
+	Reactions     *configReactions         `mapstructure:"reactions"`
+	Pprof         *configPprof             `mapstructure:"pprof"`
+	Monitoring    *configMonitoring        `mapstructure:"monitoring"`
+	Metrics       *configMetrics           `mapstructure:"metrics"`
+	FeedReader    *configFeedReader        `mapstructure:"feedReader"`
+	MastodonAPI   *configMastodonAPI       `mapstructure:"mastodonApi"`
+	Robots        *configRobots            `mapstructure:"robots"`
+	Theme         string                   `mapstructure:"theme"`
+	PreviewThemes map[string]string        `mapstructure:"previewThemes"`
+	Log           *configLog               `mapstructure:"log"`
+	Debug         bool                     `mapstructure:"debug"`
 	initialized   bool
 }
 
 type configServer struct {
-	Logging             bool     `mapstructure:"logging"`
-	LogFile             string   `mapstructure:"logFile"`
-	Port                int      `mapstructure:"port"`
-	PublicAddress       string   `mapstructure:"publicAddress"`
-	ShortPublicAddress  string   `mapstructure:"shortPublicAddress"`
-	MediaAddress        string   `mapstructure:"mediaAddress"`
-	PublicHTTPS         bool     `mapstructure:"publicHttps"`
-	AcmeDir             string   `mapstructure:"acmeDir"`
-	AcmeEabKid          string   `mapstructure:"acmeEabKid"`
-	AcmeEabKey          string   `mapstructure:"acmeEabKey"`
-	HttpsCert           string   `mapstructure:"httpsCert"`
-	HttpsKey            string   `mapstructure:"httpsKey"`
-	HttpsRedirect       bool     `mapstructure:"httpsRedirect"`
-	Tor                 bool     `mapstructure:"tor"`
-	TorSingleHop        bool     `mapstructure:"torSingleHop"`
-	SecurityHeaders     bool     `mapstructure:"securityHeaders"`
-	CSPDomains          []string `mapstructure:"cspDomains"`
+	Logging             bool                    `mapstructure:"logging"`
+	LogFile             string                  `mapstructure:"logFile"`
+	LogRetention        int                     `mapstructure:"logRetention"`
+	LogIPs              string                  `mapstructure:"logIPs"`
+	Port                int                     `mapstructure:"port"`
+	ListenAddress       string                  `mapstructure:"listenAddress"`
+	UnixSocket          string                  `mapstructure:"unixSocket"`
+	PublicAddress       string                  `mapstructure:"publicAddress"`
+	ShortPublicAddress  string                  `mapstructure:"shortPublicAddress"`
+	ShortPath           string                  `mapstructure:"shortPath"`
+	LowercasePaths      bool                    `mapstructure:"lowercasePaths"`
+	MediaAddress        string                  `mapstructure:"mediaAddress"`
+	PublicHTTPS         bool                    `mapstructure:"publicHttps"`
+	AcmeDir             string                  `mapstructure:"acmeDir"`
+	AcmeEabKid          string                  `mapstructure:"acmeEabKid"`
+	AcmeEabKey          string                  `mapstructure:"acmeEabKey"`
+	HttpsCert           string                  `mapstructure:"httpsCert"`
+	HttpsKey            string                  `mapstructure:"httpsKey"`
+	HttpsRedirect       bool                    `mapstructure:"httpsRedirect"`
+	Tor                 bool                    `mapstructure:"tor"`
+	TorSingleHop        bool                    `mapstructure:"torSingleHop"`
+	SecurityHeaders     bool                    `mapstructure:"securityHeaders"`
+	AssetIntegrity      bool                    `mapstructure:"assetIntegrity"`
+	CSPDomains          []string                `mapstructure:"cspDomains"`
+	CSP                 *configCSP              `mapstructure:"csp"`
+	HeaderOverrides     []*configHeaderOverride `mapstructure:"headerOverrides"`
+	CustomDomains       []*configCustomDomain   `mapstructure:"customDomains"`
+	ShutdownTimeout     int                     `mapstructure:"shutdownTimeout"`
+	TrustedProxies      []string                `mapstructure:"trustedProxies"`
+	RateLimit           *configRateLimit        `mapstructure:"rateLimit"`
+	LoginProtection     *configLoginProtection  `mapstructure:"loginProtection"`
 	publicHostname      string
 	shortPublicHostname string
 	mediaHostname       string
 	manualHttps         bool
+	trustedProxies      []*net.IPNet
+}
+
+type configCSP struct {
+	ScriptSources []string `mapstructure:"scriptSources"`
+	StyleSources  []string `mapstructure:"styleSources"`
+	ImageSources  []string `mapstructure:"imageSources"`
+	Nonce         bool     `mapstructure:"nonce"`
+}
+
+type configHeaderOverride struct {
+	PathPrefix string            `mapstructure:"pathPrefix"`
+	Headers    map[string]string `mapstructure:"headers"`
+}
+
+type configRateLimit struct {
+	Enabled     bool                    `mapstructure:"enabled"`
+	Requests    int                     `mapstructure:"requests"`
+	Burst       int                     `mapstructure:"burst"`
+	Routes      []*configRateLimitRoute `mapstructure:"routes"`
+	ExemptIPs   []string                `mapstructure:"exemptIps"`
+	ExemptPaths []string                `mapstructure:"exemptPaths"`
+	exemptIPs   []*net.IPNet
+}
+
+type configLoginProtection struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MaxFailures int  `mapstructure:"maxFailures"`
+	Lockout     int  `mapstructure:"lockout"` // minutes
+}
+
+type configRateLimitRoute struct {
+	PathPrefix string `mapstructure:"pathPrefix"`
+	Requests   int    `mapstructure:"requests"`
+	Burst      int    `mapstructure:"burst"`
+}
+
+type configCustomDomain struct {
+	Address    string `mapstructure:"address"`
+	Blog       string `mapstructure:"blog"`
+	Section    string `mapstructure:"section"`
+	Path       string `mapstructure:"path"`
+	hostname   string
+	targetPath string
+	// Domain of a whole blog (from the blog config)
+	blogDomain bool
 }
 
 type configDb struct {
@@ -72,31 +155,69 @@ type configDb struct {
 type configCache struct {
 	Enable     bool `mapstructure:"enable"`
 	Expiration int  `mapstructure:"expiration"`
+	MaxSize    int  `mapstructure:"maxSize"`
+	Warm       bool `mapstructure:"warm"`
+}
+
+type configFeeds struct {
+	Items      int `mapstructure:"items"`      // Posts per feed, defaults to the blog pagination
+	Expiration int `mapstructure:"expiration"` // Seconds, 0 keeps feeds until they get purged
+	MaxSize    int `mapstructure:"maxSize"`    // MB
+}
+
+type configLinks struct {
+	NewTab        bool     `mapstructure:"newTab"`
+	Nofollow      bool     `mapstructure:"nofollow"`
+	UgcNofollow   bool     `mapstructure:"ugcNofollow"`
+	FollowDomains []string `mapstructure:"followDomains"`
+}
+
+type configSanitization struct {
+	AllowCode   bool `mapstructure:"allowCode"`
+	AllowImages bool `mapstructure:"allowImages"`
+	policy      *bluemonday.Policy
 }
 
 type configBlog struct {
-	Path           string                    `mapstructure:"path"`
-	Lang           string                    `mapstructure:"lang"`
-	Title          string                    `mapstructure:"title"`
-	Description    string                    `mapstructure:"description"`
-	Pagination     int                       `mapstructure:"pagination"`
-	DefaultSection string                    `mapstructure:"defaultsection"`
-	Sections       map[string]*configSection `mapstructure:"sections"`
-	Taxonomies     []*configTaxonomy         `mapstructure:"taxonomies"`
-	Menus          map[string]*configMenu    `mapstructure:"menus"`
-	Photos         *configPhotos             `mapstructure:"photos"`
-	Search         *configSearch             `mapstructure:"search"`
-	BlogStats      *configBlogStats          `mapstructure:"blogStats"`
-	Blogroll       *configBlogroll           `mapstructure:"blogroll"`
-	Telegram       *configTelegram           `mapstructure:"telegram"`
-	PostAsHome     bool                      `mapstructure:"postAsHome"`
-	RandomPost     *configRandomPost         `mapstructure:"randomPost"`
-	OnThisDay      *configOnThisDay          `mapstructure:"onThisDay"`
-	Comments       *configComments           `mapstructure:"comments"`
-	Map            *configGeoMap             `mapstructure:"map"`
-	Contact        *configContact            `mapstructure:"contact"`
-	Announcement   *configAnnouncement       `mapstructure:"announcement"`
+	Path           string                         `mapstructure:"path"`
+	Lang           string                         `mapstructure:"lang"`
+	Title          string                         `mapstructure:"title"`
+	Description    string                         `mapstructure:"description"`
+	Pagination     int                            `mapstructure:"pagination"`
+	DefaultSection string                         `mapstructure:"defaultsection"`
+	Sections       map[string]*configSection      `mapstructure:"sections"`
+	Taxonomies     []*configTaxonomy              `mapstructure:"taxonomies"`
+	Menus          map[string]*configMenu         `mapstructure:"menus"`
+	Photos         *configPhotos                  `mapstructure:"photos"`
+	Pages          []*configPage                  `mapstructure:"pages"`
+	Search         *configSearch                  `mapstructure:"search"`
+	BlogStats      *configBlogStats               `mapstructure:"blogStats"`
+	Archive        *configArchive                 `mapstructure:"archive"`
+	Blogroll       *configBlogroll                `mapstructure:"blogroll"`
+	Telegram       *configTelegram                `mapstructure:"telegram"`
+	PostAsHome     bool                           `mapstructure:"postAsHome"`
+	RandomPost     *configRandomPost              `mapstructure:"randomPost"`
+	OnThisDay      *configOnThisDay               `mapstructure:"onThisDay"`
+	Comments       *configComments                `mapstructure:"comments"`
+	Map            *configGeoMap                  `mapstructure:"map"`
+	Contact        *configContact                 `mapstructure:"contact"`
+	Podcast        *configPodcast                 `mapstructure:"podcast"`
+	PostTemplates  map[string]*configPostTemplate `mapstructure:"postTemplates"`
+	Newsletter     *configNewsletter              `mapstructure:"newsletter"`
+	Weeknotes      *configWeeknotes               `mapstructure:"weeknotes"`
+	YearInReview   *configYearInReview            `mapstructure:"yearInReview"`
+	ReadingTime    bool                           `mapstructure:"readingTime"`
+	TOC            *configTOC                     `mapstructure:"tableOfContents"`
+	RelatedPosts   *configRelatedPosts            `mapstructure:"relatedPosts"`
+	Nostr          *configNostr                   `mapstructure:"nostr"`
+	Announcement   *configAnnouncement            `mapstructure:"announcement"`
+	Protection     *configBlogProtection          `mapstructure:"protection"`
+	ActivityPub    *configBlogActivityPub         `mapstructure:"activityPub"`
+	CustomAssets   *configCustomAssets            `mapstructure:"customAssets"`
+	Theme          string                         `mapstructure:"theme"`
+	Domain         string                         `mapstructure:"domain"`
 	name           string
+	domain         *configCustomDomain
 	// Configs read from database
 	hideOldContentWarning bool
 	hideShareButton       bool
@@ -105,6 +226,8 @@ type configBlog struct {
 	addReplyContext       bool
 	addLikeTitle          bool
 	addLikeContext        bool
+	addRepostTitle        bool
+	addRepostContext      bool
 	// Editor state WebSockets
 	esws sync.Map
 	esm  sync.Mutex
@@ -116,7 +239,9 @@ type configSection struct {
 	PathTemplate string `mapstructure:"pathtemplate"`
 	ShowFull     bool   `mapstructure:"showFull"`
 	HideOnStart  bool   `mapstructure:"hideOnStart"`
-	Name         string
+	// Content warning for the posts when sent via ActivityPub
+	ContentWarning string `mapstructure:"contentWarning"`
+	Name           string
 }
 
 type configTaxonomy struct {
@@ -141,6 +266,19 @@ type configPhotos struct {
 	Description string `mapstructure:"description"`
 }
 
+type configPage struct {
+	Path           string   `mapstructure:"path"`
+	Title          string   `mapstructure:"title"`
+	Description    string   `mapstructure:"description"`
+	Sections       []string `mapstructure:"sections"`
+	Taxonomy       string   `mapstructure:"taxonomy"`
+	TaxonomyValue  string   `mapstructure:"taxonomyValue"`
+	Parameter      string   `mapstructure:"parameter"`
+	ParameterValue string   `mapstructure:"parameterValue"`
+	Sort           string   `mapstructure:"sort"`     // newest (default), oldest or updated
+	Template       string   `mapstructure:"template"` // summary (default) or photosummary
+}
+
 type configSearch struct {
 	Enabled     bool   `mapstructure:"enabled"`
 	Path        string `mapstructure:"path"`
@@ -156,10 +294,18 @@ type configBlogStats struct {
 	Description string `mapstructure:"description"`
 }
 
+type configArchive struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Path        string `mapstructure:"path"`
+	Title       string `mapstructure:"title"`
+	Description string `mapstructure:"description"`
+}
+
 type configBlogroll struct {
 	Enabled     bool     `mapstructure:"enabled"`
 	Path        string   `mapstructure:"path"`
 	Opml        string   `mapstructure:"opml"`
+	Refresh     int      `mapstructure:"refresh"`
 	AuthHeader  string   `mapstructure:"authHeader"`
 	AuthValue   string   `mapstructure:"authValue"`
 	Categories  []string `mapstructure:"categories"`
@@ -178,42 +324,66 @@ type configOnThisDay struct {
 }
 
 type configComments struct {
-	Enabled bool `mapstructure:"enabled"`
+	Enabled              bool `mapstructure:"enabled"`
+	DisableForm          bool `mapstructure:"disableForm"`
+	configSpamProtection `mapstructure:",squash"`
 }
 
 type configGeoMap struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Path    string `mapstructure:"path"`
+	Enabled  bool   `mapstructure:"enabled"`
+	Path     string `mapstructure:"path"`
+	PostMaps bool   `mapstructure:"postMaps"`
 }
 
 type configContact struct {
-	Enabled       bool   `mapstructure:"enabled"`
-	Path          string `mapstructure:"path"`
-	Title         string `mapstructure:"title"`
-	Description   string `mapstructure:"description"`
-	PrivacyPolicy string `mapstructure:"privacyPolicy"`
-	SMTPHost      string `mapstructure:"smtpHost"`
-	SMTPPort      int    `mapstructure:"smtpPort"`
-	SMTPUser      string `mapstructure:"smtpUser"`
-	SMTPPassword  string `mapstructure:"smtpPassword"`
-	EmailFrom     string `mapstructure:"emailFrom"`
-	EmailTo       string `mapstructure:"emailTo"`
-	EmailSubject  string `mapstructure:"emailSubject"`
+	Enabled              bool   `mapstructure:"enabled"`
+	Path                 string `mapstructure:"path"`
+	Title                string `mapstructure:"title"`
+	Description          string `mapstructure:"description"`
+	PrivacyPolicy        string `mapstructure:"privacyPolicy"`
+	SMTPHost             string `mapstructure:"smtpHost"`
+	SMTPPort             int    `mapstructure:"smtpPort"`
+	SMTPUser             string `mapstructure:"smtpUser"`
+	SMTPPassword         string `mapstructure:"smtpPassword"`
+	EmailFrom            string `mapstructure:"emailFrom"`
+	EmailTo              string `mapstructure:"emailTo"`
+	EmailSubject         string `mapstructure:"emailSubject"`
+	configSpamProtection `mapstructure:",squash"`
+}
+
+type configSpamProtection struct {
+	SpamProtection string `mapstructure:"spamProtection"`
+	PoWDifficulty  int    `mapstructure:"powDifficulty"`
+	RateLimit      int    `mapstructure:"rateLimit"`
 }
 
 type configAnnouncement struct {
 	Text string `mapstructure:"text"`
 }
 
+type configCustomAssets struct {
+	CSS     string `mapstructure:"css"`
+	CSSFile string `mapstructure:"cssFile"`
+	JS      string `mapstructure:"js"`
+	JSFile  string `mapstructure:"jsFile"`
+}
+
 type configUser struct {
-	Nick         string               `mapstructure:"nick"`
-	Name         string               `mapstructure:"name"`
-	Password     string               `mapstructure:"password"`
-	TOTP         string               `mapstructure:"totp"`
-	AppPasswords []*configAppPassword `mapstructure:"appPasswords"`
-	Email        string               `mapstructure:"email"`
-	Link         string               `mapstructure:"link"`
-	Identities   []string             `mapstructure:"identities"`
+	Nick           string               `mapstructure:"nick"`
+	Name           string               `mapstructure:"name"`
+	Password       string               `mapstructure:"password"`
+	TOTP           string               `mapstructure:"totp"`
+	AppPasswords   []*configAppPassword `mapstructure:"appPasswords"`
+	Email          string               `mapstructure:"email"`
+	Link           string               `mapstructure:"link"`
+	Identities     []string             `mapstructure:"identities"`
+	IndieAuthLogin []string             `mapstructure:"indieAuthLogin"`
+}
+
+type configAuthor struct {
+	Name  string `mapstructure:"name"`
+	URL   string `mapstructure:"url"`
+	Photo string `mapstructure:"photo"`
 }
 
 type configAppPassword struct {
@@ -240,6 +410,9 @@ type configMicropub struct {
 	LikeParam             string               `mapstructure:"likeParam"`
 	LikeTitleParam        string               `mapstructure:"likeTitleParam"`
 	LikeContextParam      string               `mapstructure:"likeContextParam"`
+	RepostParam           string               `mapstructure:"repostParam"`
+	RepostTitleParam      string               `mapstructure:"repostTitleParam"`
+	RepostContextParam    string               `mapstructure:"repostContextParam"`
 	BookmarkParam         string               `mapstructure:"bookmarkParam"`
 	AudioParam            string               `mapstructure:"audioParam"`
 	PhotoParam            string               `mapstructure:"photoParam"`
@@ -275,50 +448,158 @@ type configRegexRedirect struct {
 type configActivityPub struct {
 	Enabled        bool     `mapstructure:"enabled"`
 	TagsTaxonomies []string `mapstructure:"tagsTaxonomies"`
+	KeyGracePeriod int      `mapstructure:"keyGracePeriod"`
+	// Audit log of the received activities
+	InboxLogRetention int `mapstructure:"inboxLogRetention"` // days, default 30
+	InboxLogLimit     int `mapstructure:"inboxLogLimit"`     // entries per blog, default 1000
+	// Blog for WebFinger lookups of the bare domain, default is the default blog
+	DefaultBlog string `mapstructure:"defaultBlog"`
+}
+
+type configBlogActivityPub struct {
+	Name        string                             `mapstructure:"name"`
+	Summary     string                             `mapstructure:"summary"`
+	Icon        string                             `mapstructure:"icon"`
+	Image       string                             `mapstructure:"image"`
+	Attachments []*configBlogActivityPubAttachment `mapstructure:"attachments"`
+	// Additional WebFinger accounts, like "me" or "me@example.org"
+	Aliases []string `mapstructure:"aliases"`
+}
+
+type configBlogActivityPubAttachment struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
 }
 
 type configNotifications struct {
-	Ntfy     *configNtfy     `mapstructure:"ntfy"`
-	Telegram *configTelegram `mapstructure:"telegram"`
-	Matrix   *configMatrix   `mapstructure:"matrix"`
+	Ntfy     *configNtfy              `mapstructure:"ntfy"`
+	Telegram *configTelegram          `mapstructure:"telegram"`
+	Matrix   *configMatrix            `mapstructure:"matrix"`
+	Email    *configNotificationEmail `mapstructure:"email"`
+}
+
+type configSyndication struct {
+	Targets []*configSyndicationTarget `mapstructure:"targets"`
+}
+
+type configSyndicationTarget struct {
+	UID      string `mapstructure:"uid"`
+	Name     string `mapstructure:"name"`
+	Type     string `mapstructure:"type"`
+	Instance string `mapstructure:"instance"`
+	Token    string `mapstructure:"token"`
+	Handle   string `mapstructure:"handle"`
+	Password string `mapstructure:"password"`
+	Default  bool   `mapstructure:"default"`
 }
 
 type configNtfy struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Topic   string `mapstructure:"topic"`
-	Server  string `mapstructure:"server"`
-	User    string `mapstructure:"user"`
-	Pass    string `mapstructure:"pass"`
-	Email   string `mapstructure:"email"`
+	Enabled bool     `mapstructure:"enabled"`
+	Topic   string   `mapstructure:"topic"`
+	Server  string   `mapstructure:"server"`
+	User    string   `mapstructure:"user"`
+	Pass    string   `mapstructure:"pass"`
+	Email   string   `mapstructure:"email"`
+	Events  []string `mapstructure:"events"`
 }
 
 type configTelegram struct {
-	Enabled         bool   `mapstructure:"enabled"`
-	ChatID          string `mapstructure:"chatId"`
-	BotToken        string `mapstructure:"botToken"`
-	InstantViewHash string `mapstructure:"instantViewHash"`
+	Enabled         bool     `mapstructure:"enabled"`
+	ChatID          string   `mapstructure:"chatId"`
+	BotToken        string   `mapstructure:"botToken"`
+	InstantViewHash string   `mapstructure:"instantViewHash"`
+	Events          []string `mapstructure:"events"`
 }
 
 type configMatrix struct {
-	Enabled    bool   `mapstructure:"enabled"`
-	HomeServer string `mapstructure:"homeserver"`
-	Username   string `mapstructure:"username"`
-	Password   string `mapstructure:"password"`
-	Room       string `mapstructure:"room"`
-	DeviceId   string `mapstructure:"deviceid"`
+	Enabled    bool     `mapstructure:"enabled"`
+	HomeServer string   `mapstructure:"homeserver"`
+	Username   string   `mapstructure:"username"`
+	Password   string   `mapstructure:"password"`
+	Room       string   `mapstructure:"room"`
+	DeviceId   string   `mapstructure:"deviceid"`
+	Events     []string `mapstructure:"events"`
 	client     *mautrix.Client
 	err        error
 	clientInit sync.Once
 }
 
+type configNotificationEmail struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	SMTPHost     string   `mapstructure:"smtpHost"`
+	SMTPPort     int      `mapstructure:"smtpPort"`
+	SMTPUser     string   `mapstructure:"smtpUser"`
+	SMTPPassword string   `mapstructure:"smtpPassword"`
+	EmailFrom    string   `mapstructure:"emailFrom"`
+	EmailTo      string   `mapstructure:"emailTo"`
+	EmailSubject string   `mapstructure:"emailSubject"`
+	Events       []string `mapstructure:"events"`
+}
+
+type configRobots struct {
+	BlockAI bool                `mapstructure:"blockAI"` // disallow known AI training crawlers
+	Rules   []*configRobotsRule `mapstructure:"rules"`
+}
+
+type configRobotsRule struct {
+	UserAgents []string `mapstructure:"userAgents"`
+	Allow      []string `mapstructure:"allow"`
+	Disallow   []string `mapstructure:"disallow"`
+}
+
 type configPrivateMode struct {
 	Enabled bool `mapstructure:"enabled"`
 }
 
+type configBlogProtection struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Passphrase string `mapstructure:"passphrase"`
+	FeedToken  string `mapstructure:"feedToken"`
+}
+
 type configIndexNow struct {
 	Enabled bool `mapstructure:"enabled"`
 }
 
+type configSitemapPing struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Endpoints []string `mapstructure:"endpoints"` // The sitemap URL is added as "sitemap" query parameter
+}
+
+type configLinkArchive struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Local       bool   `mapstructure:"local"`       // Save copies in the media storage instead of using the Wayback Machine
+	BrokenLinks string `mapstructure:"brokenLinks"` // "annotate" (default), "rewrite" or "none"
+}
+
+type configLibravatar struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Emails  []string `mapstructure:"emails"`
+	hashes  map[string]bool
+}
+
+type configPageData struct {
+	Path    string `mapstructure:"path"`
+	Source  string `mapstructure:"source"`
+	Refresh int    `mapstructure:"refresh"`
+}
+
+type configSnapshots struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+type configAnalytics struct {
+	Enabled   bool `mapstructure:"enabled"`
+	Retention int  `mapstructure:"retention"`
+}
+
+type configBackup struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Directory string `mapstructure:"directory"`
+	Interval  int    `mapstructure:"interval"`
+	Keep      int    `mapstructure:"keep"`
+}
+
 type configEasterEgg struct {
 	Enabled bool `mapstructure:"enabled"`
 }
@@ -349,6 +630,105 @@ type configPprof struct {
 	Address string `mapstructure:"address"`
 }
 
+type configMonitoring struct {
+	Enabled       bool `mapstructure:"enabled"`
+	Interval      int  `mapstructure:"interval"`
+	MaxGoroutines int  `mapstructure:"maxGoroutines"`
+	MaxHeap       int  `mapstructure:"maxHeap"`
+	MaxOpenFiles  int  `mapstructure:"maxOpenFiles"`
+}
+
+type configLog struct {
+	Level     string            `mapstructure:"level"`
+	Levels    map[string]string `mapstructure:"levels"`
+	File      string            `mapstructure:"file"`
+	Format    string            `mapstructure:"format"`
+	Retention int               `mapstructure:"retention"`
+}
+
+type configMetrics struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	AllowedIPs []string `mapstructure:"allowedIps"`
+	allowedIPs []*net.IPNet
+}
+
+type configPostTemplate struct {
+	Title      string              `mapstructure:"title"`
+	Section    string              `mapstructure:"section"`
+	Content    string              `mapstructure:"content"`
+	Parameters map[string][]string `mapstructure:"parameters"`
+}
+
+type configNewsletter struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	Path          string `mapstructure:"path"`
+	Title         string `mapstructure:"title"`
+	Description   string `mapstructure:"description"`
+	PrivacyPolicy string `mapstructure:"privacyPolicy"`
+	Digest        bool   `mapstructure:"digest"` // weekly digest instead of an email per post
+	SMTPHost      string `mapstructure:"smtpHost"`
+	SMTPPort      int    `mapstructure:"smtpPort"`
+	SMTPUser      string `mapstructure:"smtpUser"`
+	SMTPPassword  string `mapstructure:"smtpPassword"`
+	EmailFrom     string `mapstructure:"emailFrom"`
+	EmailSubject  string `mapstructure:"emailSubject"` // subject of the digest
+}
+
+type configWeeknotes struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Section string `mapstructure:"section"` // section of the drafts, default is the default section
+	Title   string `mapstructure:"title"`   // title prefix, the week is appended
+}
+
+type configYearInReview struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Section string `mapstructure:"section"` // section of the drafts, default is the default section
+	Title   string `mapstructure:"title"`   // title prefix, the year is appended
+}
+
+type configTOC struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MinHeadings int  `mapstructure:"minHeadings"` // default is 3
+}
+
+type configRelatedPosts struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Count   int    `mapstructure:"count"`  // number of related posts, default is 3
+	Method  string `mapstructure:"method"` // taxonomies, content or both (default)
+}
+
+type configNostr struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	PrivateKey string   `mapstructure:"privateKey"` // hex or nsec
+	Relays     []string `mapstructure:"relays"`
+	Name       string   `mapstructure:"name"` // NIP-05 name, default is "_" for the default blog and the blog name otherwise
+	key        *btcec.PrivateKey
+}
+
+type configPodcast struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Title       string `mapstructure:"title"`
+	Author      string `mapstructure:"author"`
+	Email       string `mapstructure:"email"`
+	Image       string `mapstructure:"image"`
+	Category    string `mapstructure:"category"`
+	Subcategory string `mapstructure:"subcategory"`
+	Explicit    bool   `mapstructure:"explicit"`
+}
+
+type configFeedReader struct {
+	Enabled  bool     `mapstructure:"enabled"`
+	Interval int      `mapstructure:"interval"`
+	Blog     string   `mapstructure:"blog"`
+	Feeds    []string `mapstructure:"feeds"`
+}
+
+type configMastodonAPI struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Blog    string `mapstructure:"blog"`
+	Section string `mapstructure:"section"`
+}
+
 type configPlugin struct {
 	Path   string         `mapstructure:"path"`
 	Import string         `mapstructure:"import"`
@@ -370,6 +750,8 @@ func (a *goBlog) loadConfigFile(file string) error {
 	if err := v.ReadInConfig(); err != nil {
 		return err
 	}
+	// Remember file for reloading
+	a.cfgFile = v.ConfigFileUsed()
 	// Unmarshal config
 	a.cfg = createDefaultConfig()
 	return v.Unmarshal(a.cfg)
@@ -386,6 +768,10 @@ func (a *goBlog) initConfig(logging bool) error {
 	if err := a.initDatabase(logging); err != nil {
 		return err
 	}
+	// ActivityPub keys are loaded on first use
+	if a.apKeys == nil {
+		a.apKeys = &apKeyStore{}
+	}
 	// Parse addresses and hostnames
 	if a.cfg.Server.PublicAddress == "" {
 		return errors.New("no public address configured")
@@ -402,6 +788,13 @@ func (a *goBlog) initConfig(logging bool) error {
 		}
 		a.cfg.Server.shortPublicHostname = shortPublicURL.Hostname()
 	}
+	if sp := a.cfg.Server.ShortPath; sp != "" {
+		sp = "/" + strings.Trim(sp, "/")
+		if sp == "/" {
+			return errors.New("invalid short path")
+		}
+		a.cfg.Server.ShortPath = sp
+	}
 	if ma := a.cfg.Server.MediaAddress; ma != "" {
 		mediaUrl, err := url.Parse(ma)
 		if err != nil {
@@ -430,6 +823,9 @@ func (a *goBlog) initConfig(logging bool) error {
 		a.cfg.Server.SecurityHeaders = true
 	}
 	if a.cfg.Server.PublicHTTPS {
+		if a.cfg.Server.UnixSocket != "" {
+			return errors.New("publicHttps can't be used with a unix socket")
+		}
 		a.cfg.Server.HttpsRedirect = true
 		a.cfg.Server.Port = 443
 	}
@@ -502,6 +898,14 @@ func (a *goBlog) initConfig(logging bool) error {
 	} else {
 		a.cfg.User.Name = userName
 	}
+	// User identities
+	if err = a.loadIdentities(); err != nil {
+		return err
+	}
+	// TOTP secret
+	if err = a.loadTOTP(); err != nil {
+		return err
+	}
 	// Check config for each blog
 	for blog, bc := range a.cfg.Blogs {
 		// Check pagination
@@ -540,18 +944,24 @@ func (a *goBlog) initConfig(logging bool) error {
 		if br := bc.Blogroll; br != nil && br.Enabled && br.Opml == "" {
 			br.Enabled = false
 		}
+		if br := bc.Blogroll; br != nil && br.Refresh <= 0 {
+			br.Refresh = defaultBlogrollRefresh
+		}
 		// Load other settings from database
 		configs := []*bool{
 			&bc.hideOldContentWarning, &bc.hideShareButton, &bc.hideTranslateButton,
 			&bc.addReplyTitle, &bc.addReplyContext, &bc.addLikeTitle, &bc.addLikeContext,
+			&bc.addRepostTitle, &bc.addRepostContext,
 		}
 		settings := []string{
 			hideOldContentWarningSetting, hideShareButtonSetting, hideTranslateButtonSetting,
 			addReplyTitleSetting, addReplyContextSetting, addLikeTitleSetting, addLikeContextSetting,
+			addRepostTitleSetting, addRepostContextSetting,
 		}
 		defaults := []bool{
 			false, false, false,
 			false, false, false, false,
+			false, false,
 		}
 		for i := range configs {
 			*configs[i], err = a.getBooleanSettingValue(settingNameWithBlog(blog, settings[i]), defaults[i])
@@ -560,6 +970,31 @@ func (a *goBlog) initConfig(logging bool) error {
 			}
 		}
 	}
+	// Check custom domains (needs sections)
+	if err = a.initCustomDomains(); err != nil {
+		return err
+	}
+	// Check authors
+	if err = a.initAuthors(); err != nil {
+		return err
+	}
+	// Trusted proxies and rate limit exemptions
+	if err = a.initRateLimitConfig(); err != nil {
+		return err
+	}
+	// Metrics allowlist
+	if err = a.initMetricsConfig(); err != nil {
+		return err
+	}
+	// Sanitization policy for remote content
+	if a.cfg.Sanitization == nil {
+		a.cfg.Sanitization = &configSanitization{}
+	}
+	a.cfg.Sanitization.policy = remoteContentPolicy(a.cfg.Sanitization, a.cfg.Links)
+	// Libravatar hashes of own email addresses
+	if lc := a.cfg.Libravatar; lc != nil && lc.Enabled {
+		lc.hashes = libravatarHashes(append([]string{a.cfg.User.Email}, lc.Emails...))
+	}
 	// Log success
 	a.cfg.initialized = true
 	log.Println("Initialized configuration")
@@ -569,8 +1004,9 @@ func (a *goBlog) initConfig(logging bool) error {
 func createDefaultConfig() *config {
 	return &config{
 		Server: &configServer{
-			PublicAddress: "http://localhost:8080",
-			LogFile:       "data/access.log",
+			PublicAddress:   "http://localhost:8080",
+			LogFile:         "data/access.log",
+			ShutdownTimeout: 5,
 		},
 		Db: &configDb{
 			File: "data/db.sqlite",
@@ -579,6 +1015,10 @@ func createDefaultConfig() *config {
 			Enable:     true,
 			Expiration: 600,
 		},
+		Links: &configLinks{
+			NewTab:      true,
+			UgcNofollow: true,
+		},
 		User: &configUser{
 			Nick:     "admin",
 			Password: "secret",
@@ -594,6 +1034,9 @@ func createDefaultConfig() *config {
 			LikeParam:             "likelink",
 			LikeTitleParam:        "liketitle",
 			LikeContextParam:      "likecontext",
+			RepostParam:           "repostlink",
+			RepostTitleParam:      "reposttitle",
+			RepostContextParam:    "repostcontext",
 			BookmarkParam:         "link",
 			AudioParam:            "audio",
 			PhotoParam:            "images",
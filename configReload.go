@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Reload the config file on SIGHUP without restarting the server
+func (a *goBlog) initConfigReload() {
+	if a.cfgFile == "" {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		current := a
+		for range c {
+			log.Println("Reloading configuration...")
+			na, err := current.reloadConfig()
+			if err != nil {
+				log.Println("Failed to reload configuration:", err.Error())
+				continue
+			}
+			current = na
+			log.Println("Reloaded configuration")
+		}
+	}()
+	a.shutdown.Add(func() {
+		signal.Stop(c)
+		close(c)
+	})
+}
+
+// Settings for the listeners (ports, HTTPS, Tor), the database and
+// components that are initialized on startup (like ActivityPub) still need a restart.
+// The current instance isn't changed, because requests and background tasks still use it,
+// instead a new instance with the new config serves all following requests and is returned.
+func (a *goBlog) reloadConfig() (*goBlog, error) {
+	if a.cfgFile == "" {
+		return nil, errors.New("no config file loaded")
+	}
+	if a.dh == nil {
+		return nil, errors.New("server not started yet")
+	}
+	na := a.newReloadInstance()
+	if err := na.loadConfigFile(a.cfgFile); err != nil {
+		return nil, err
+	}
+	if err := na.initConfig(false); err != nil {
+		return nil, err
+	}
+	if err := na.initRegexRedirects(); err != nil {
+		return nil, err
+	}
+	if err := na.initTemplateStrings(); err != nil {
+		return nil, err
+	}
+	na.initMarkdown()
+	// Recompile the assets to include changed custom assets
+	if err := na.initTemplateAssets(); err != nil {
+		return nil, err
+	}
+	// Switch to the router of the new instance
+	na.reloadRouter()
+	a.cache.purge()
+	if a.asObjectCache != nil {
+		a.asObjectCache.clear()
+	}
+	return na, nil
+}
+
+// New instance without config, sharing the state of the components that are initialized on startup
+func (a *goBlog) newReloadInstance() *goBlog {
+	na := &goBlog{
+		cfgFile:            a.cfgFile,
+		cfgReloads:         a.cfgReloads + 1,
+		db:                 a.db,
+		dh:                 a.dh,
+		cache:              a.cache,
+		httpClient:         a.httpClient,
+		publicHttpClient:   a.publicHttpClient,
+		ias:                a.ias,
+		loginSessions:      a.loginSessions,
+		captchaSessions:    a.captchaSessions,
+		pluginHost:         a.pluginHost,
+		analytics:          a.analytics,
+		pageData:           a.pageData,
+		apKeys:             a.apKeys,
+		apHttpClients:      a.apHttpClients,
+		webfingerResources: a.webfingerResources,
+		webfingerAccts:     a.webfingerAccts,
+		asObjectCache:      a.asObjectCache,
+		autocertManager:    a.autocertManager,
+		pPostHooks:         a.pPostHooks,
+		pUpdateHooks:       a.pUpdateHooks,
+		pDeleteHooks:       a.pDeleteHooks,
+		pUndeleteHooks:     a.pUndeleteHooks,
+		hourlyHooks:        a.hourlyHooks,
+		logf:               a.logf,
+		logFile:            a.logFile,
+		logHandler:         a.logHandler,
+		logLevel:           a.logLevel,
+		logLevels:          a.logLevels,
+		torAddress:         a.torAddress,
+		torHostname:        a.torHostname,
+	}
+	// Keep the brute-force and spam protection, the metrics and cached reactions
+	na.formSpamInit.Do(func() { na.formSpam = a.getFormSpamProtection() })
+	na.loginProtectionInit.Do(func() { na.loginProtection = a.getLoginProtection() })
+	na.rateLimiterInit.Do(func() { na.rateLimiter = a.getRateLimiter() })
+	na.metricsInit.Do(func() { na.metrics = a.getMetrics() })
+	a.initReactions()
+	if a.reactionsCache != nil {
+		na.reactionsInit.Do(func() { na.reactionsCache = a.reactionsCache })
+	}
+	// Count the visitors of the day with the same salt
+	a.logIPSaltMutex.Lock()
+	na.logIPSalt, na.logIPSaltDay = a.logIPSalt, a.logIPSaltDay
+	a.logIPSaltMutex.Unlock()
+	return na
+}
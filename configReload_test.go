@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_configReload(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yml")
+	writeConfig := func(title, redirect string) {
+		err := os.WriteFile(cfgFile, []byte(`
+database:
+  file: `+filepath.Join(dir, "blog.db")+`
+server:
+  publicAddress: http://localhost:8080
+blogs:
+  en:
+    title: `+title+`
+pathRedirects:
+  - from: "^/old$"
+    to: "`+redirect+`"
+`), 0600)
+		require.NoError(t, err)
+	}
+
+	writeConfig("Old title", "/new")
+
+	app := &goBlog{}
+	require.NoError(t, app.loadConfigFile(cfgFile))
+	require.NoError(t, app.initConfig(false))
+	require.NoError(t, app.initTemplateStrings())
+	require.NoError(t, app.initRegexRedirects())
+	_ = app.initCache()
+	app.initSessions()
+	app.reloadRouter()
+
+	assert.Equal(t, cfgFile, app.cfgFile)
+	assert.Equal(t, "Old title", app.cfg.Blogs["en"].Title)
+
+	checkRedirect := func(expected string) {
+		req := httptest.NewRequest(http.MethodGet, "/old", nil)
+		rec := httptest.NewRecorder()
+		app.dh.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, expected, rec.Header().Get("Location"))
+	}
+	checkRedirect("/new")
+
+	// Requests and background tasks keep running during the reload
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				app.dh.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/old", nil))
+				_ = app.cfg.Blogs["en"].Title
+			}
+		}
+	}()
+
+	writeConfig("New title", "/newer")
+	na, err := app.reloadConfig()
+	close(done)
+	wg.Wait()
+	require.NoError(t, err)
+
+	// The new instance serves the requests, the old one isn't changed
+	assert.Equal(t, "New title", na.cfg.Blogs["en"].Title)
+	assert.Equal(t, "Old title", app.cfg.Blogs["en"].Title)
+	assert.Same(t, app.db, na.db)
+	assert.Same(t, app.dh, na.dh)
+	checkRedirect("/newer")
+
+	// Invalid config keeps the old one
+	require.NoError(t, os.WriteFile(cfgFile, []byte("server:\n  publicAddress: \"\"\n"), 0600))
+	_, err = na.reloadConfig()
+	assert.Error(t, err)
+	checkRedirect("/newer")
+
+	// Reloading the reloaded instance
+	writeConfig("Newest title", "/newest")
+	nna, err := na.reloadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "Newest title", nna.cfg.Blogs["en"].Title)
+	checkRedirect("/newest")
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"time"
 
-	mail "github.com/xhit/go-simple-mail/v2"
 	"go.goblog.app/app/pkgs/bufferpool"
 )
 
@@ -15,18 +14,29 @@ const defaultContactPath = "/contact"
 func (a *goBlog) serveContactForm(w http.ResponseWriter, r *http.Request) {
 	_, bc := a.getBlog(r)
 	cc := bc.Contact
+	powPath := ""
+	if cc.mode() == spamProtectionPoW {
+		powPath = bc.getRelativePath(defaultIfEmpty(cc.Path, defaultContactPath)) + spamPoWPath
+	}
 	a.render(w, r, a.renderContact, &renderData{
 		Data: &contactRenderData{
 			title:       cc.Title,
 			description: cc.Description,
 			privacy:     cc.PrivacyPolicy,
+			powPath:     powPath,
 		},
 	})
 }
 
 func (a *goBlog) sendContactSubmission(w http.ResponseWriter, r *http.Request) {
 	// Get blog
-	_, bc := a.getBlog(r)
+	blog, bc := a.getBlog(r)
+	cc := bc.Contact
+	// Honeypot, pretend success to not give bots a hint
+	if spamHoneypotFilled(r) {
+		a.render(w, r, a.renderContactSent, &renderData{})
+		return
+	}
 	// Get form values and build message
 	message := bufferpool.Get()
 	defer bufferpool.Put(message)
@@ -36,6 +46,11 @@ func (a *goBlog) sendContactSubmission(w http.ResponseWriter, r *http.Request) {
 		a.serveError(w, r, "Message is empty", http.StatusBadRequest)
 		return
 	}
+	// Proof of work and rate limit
+	if status, err := a.checkFormSpam(r, &cc.configSpamProtection, "contact "+blog); err != nil {
+		a.serveError(w, r, err.Error(), status)
+		return
+	}
 	// Name
 	if formName := cleanHTMLText(r.FormValue("name")); formName != "" {
 		_, _ = fmt.Fprintf(message, "Name: %s\n", formName)
@@ -57,50 +72,29 @@ func (a *goBlog) sendContactSubmission(w http.ResponseWriter, r *http.Request) {
 	_, _ = message.WriteString(formMessage)
 	// Send submission
 	go func() {
-		if err := a.sendContactEmail(bc.Contact, message.String(), formEmail); err != nil {
+		if err := a.sendContactEmail(cc, message.String(), formEmail); err != nil {
 			log.Println(err.Error())
 		}
 	}()
 	// Send notification
-	go a.sendNotification(message.String())
+	go a.sendNotification(notificationTypeContact, message.String())
 	// Give feedback
 	a.render(w, r, a.renderContactSent, &renderData{})
 }
 
 func (*goBlog) sendContactEmail(cc *configContact, body, replyTo string) error {
-	// Check required config
-	if cc == nil || cc.SMTPHost == "" || cc.EmailFrom == "" || cc.EmailTo == "" {
-		return fmt.Errorf("email not send as config is missing")
-	}
-	// Connect to SMTP
-	smtpServer := mail.NewSMTPClient()
-	smtpServer.Host = cc.SMTPHost
-	port := cc.SMTPPort
-	if port == 0 {
-		port = 587
-	}
-	smtpServer.Port = port
-	smtpServer.Username = cc.SMTPUser
-	smtpServer.Password = cc.SMTPPassword
-	smtpServer.KeepAlive = false
-	smtpClient, err := smtpServer.Connect()
-	if err != nil {
-		return err
-	}
-	// Build email
-	msg := mail.NewMSG()
-	msg.AddTo(cc.EmailTo)
-	msg.SetFrom(cc.EmailFrom)
-	if replyTo != "" {
-		msg.SetReplyTo(replyTo)
-	}
-	msg.SetDate(time.Now().UTC().Format("2006-01-02 15:04:05 MST"))
-	subject := cc.EmailSubject
-	if subject == "" {
-		subject = "New contact message"
+	if cc == nil {
+		return errors.New("email not send as config is missing")
 	}
-	msg.SetSubject(subject)
-	msg.SetBody(mail.TextPlain, body)
-	// Send mail
-	return msg.Send(smtpClient)
+	return (&smtpEmail{
+		host:     cc.SMTPHost,
+		port:     cc.SMTPPort,
+		user:     cc.SMTPUser,
+		password: cc.SMTPPassword,
+		from:     cc.EmailFrom,
+		to:       cc.EmailTo,
+		replyTo:  replyTo,
+		subject:  defaultIfEmpty(cc.EmailSubject, "New contact message"),
+		body:     body,
+	}).send()
 }
@@ -5,10 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/carlmjohnson/requests"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.goblog.app/app/pkgs/contenttype"
@@ -76,3 +78,89 @@ func Test_contact(t *testing.T) {
 	}
 
 }
+
+func Test_contactSpamProtection(t *testing.T) {
+	// Start the SMTP server
+	port, rd, cancel, err := mocksmtp.StartMockSMTPServer()
+	require.NoError(t, err)
+	defer cancel()
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	app.cfg.Blogs["default"].Contact = &configContact{
+		Enabled:   true,
+		SMTPPort:  port,
+		SMTPHost:  "127.0.0.1",
+		EmailTo:   "to@example.org",
+		EmailFrom: "from@example.org",
+		configSpamProtection: configSpamProtection{
+			SpamProtection: spamProtectionPoW,
+			PoWDifficulty:  8,
+			RateLimit:      2,
+		},
+	}
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+	app.d = app.buildRouter()
+
+	client := newHandlerClient(app.d)
+
+	// Form
+	var form string
+	err = requests.URL("http://localhost:8080/contact").Client(client).ToString(&form).Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, form, "data-pow=/contact/pow")
+	assert.Contains(t, form, "name="+spamHoneypotField)
+	assert.Contains(t, form, "name=powchallenge")
+
+	solve := func() (challenge, nonce string) {
+		var pow struct {
+			Challenge  string `json:"challenge"`
+			Difficulty int    `json:"difficulty"`
+		}
+		err := requests.URL("http://localhost:8080/contact/pow").Client(client).ToJSON(&pow).Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 8, pow.Difficulty)
+		for i := 0; ; i++ {
+			if checkPoWSolution(pow.Challenge, strconv.Itoa(i), pow.Difficulty) {
+				return pow.Challenge, strconv.Itoa(i)
+			}
+		}
+	}
+	submit := func(values url.Values) int {
+		values.Set("message", "Hello")
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/contact", strings.NewReader(values.Encode()))
+		req.Header.Set(contentType, contenttype.WWWForm)
+		app.d.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Missing proof of work
+	assert.Equal(t, http.StatusBadRequest, submit(url.Values{}))
+
+	// Honeypot filled, looks like success but isn't sent
+	challenge, nonce := solve()
+	assert.Equal(t, http.StatusOK, submit(url.Values{spamHoneypotField: {"https://spam.example"}, "powchallenge": {challenge}, "pownonce": {nonce}}))
+
+	// Valid submission, replay is rejected
+	assert.Equal(t, http.StatusOK, submit(url.Values{"powchallenge": {challenge}, "pownonce": {nonce}}))
+	assert.Equal(t, http.StatusBadRequest, submit(url.Values{"powchallenge": {challenge}, "pownonce": {nonce}}))
+
+	// Manipulated challenge
+	assert.Equal(t, http.StatusBadRequest, submit(url.Values{"powchallenge": {"1" + challenge}, "pownonce": {nonce}}))
+
+	// Rate limit
+	challenge, nonce = solve()
+	assert.Equal(t, http.StatusOK, submit(url.Values{"powchallenge": {challenge}, "pownonce": {nonce}}))
+	challenge, nonce = solve()
+	assert.Equal(t, http.StatusTooManyRequests, submit(url.Values{"powchallenge": {challenge}, "pownonce": {nonce}}))
+
+	require.Eventually(t, func() bool { return len(rd.Datas) == 2 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, rd.Datas, 2)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/samber/lo"
+)
+
+// Custom domains map a section, a single page or a whole blog to a dedicated domain.
+// The domain serves the default router, but "/" shows the section, page or blog.
+
+func (a *goBlog) initCustomDomains() error {
+	// Blog domains are added below
+	a.cfg.Server.CustomDomains = lo.Filter(a.cfg.Server.CustomDomains, func(cd *configCustomDomain, _ int) bool {
+		return !cd.blogDomain
+	})
+	for _, cd := range a.cfg.Server.CustomDomains {
+		u, err := url.Parse(cd.Address)
+		if err != nil || u.Hostname() == "" {
+			return errors.New("invalid custom domain address: " + cd.Address)
+		}
+		cd.Address = strings.TrimSuffix(cd.Address, "/")
+		cd.hostname = u.Hostname()
+		cd.Blog = defaultIfEmpty(cd.Blog, a.cfg.DefaultBlog)
+		bc, ok := a.cfg.Blogs[cd.Blog]
+		if !ok {
+			return errors.New("blog for custom domain " + cd.hostname + " does not exist")
+		}
+		switch {
+		case cd.Section != "":
+			if _, ok := bc.Sections[cd.Section]; !ok {
+				return errors.New("section for custom domain " + cd.hostname + " does not exist")
+			}
+			cd.targetPath = bc.getRelativePath(cd.Section)
+		case cd.Path != "":
+			cd.targetPath = cd.Path
+		default:
+			return errors.New("custom domain " + cd.hostname + " needs a section or path")
+		}
+	}
+	// Blogs with their own domain, after the section and page domains, so those take precedence
+	blogs := lo.Keys(a.cfg.Blogs)
+	sort.Strings(blogs)
+	for _, blog := range blogs {
+		bc := a.cfg.Blogs[blog]
+		bc.domain = nil
+		if bc.Domain == "" {
+			continue
+		}
+		u, err := url.Parse(bc.Domain)
+		if err != nil || u.Hostname() == "" {
+			return errors.New("invalid domain of blog " + blog + ": " + bc.Domain)
+		}
+		if bc.getRelativePath("") == "/" {
+			// The blog paths are still used internally, so they have to be unique
+			return errors.New("blog " + blog + " with own domain needs a path")
+		}
+		if lo.ContainsBy(a.cfg.Server.CustomDomains, func(cd *configCustomDomain) bool { return cd.hostname == u.Hostname() }) {
+			return errors.New("domain of blog " + blog + " is already used")
+		}
+		bc.domain = &configCustomDomain{
+			Address:    strings.TrimSuffix(bc.Domain, "/"),
+			Blog:       blog,
+			hostname:   u.Hostname(),
+			targetPath: bc.getRelativePath(""),
+			blogDomain: true,
+		}
+		a.cfg.Server.CustomDomains = append(a.cfg.Server.CustomDomains, bc.domain)
+	}
+	return nil
+}
+
+func (a *goBlog) customDomainHandler(cd *configCustomDomain, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		switch {
+		case cd.blogDomain:
+			path = blogDomainPath(cd, next, r.Method, path)
+		case path == "" || path == "/":
+			path = cd.targetPath
+		case cd.Section != "" && strings.HasPrefix(path, "/."):
+			// Feeds of the section
+			path = cd.targetPath + strings.TrimPrefix(path, "/")
+		case cd.Section != "" && strings.HasPrefix(path, "/page/"):
+			// Pagination of the section
+			path = cd.targetPath + path
+		}
+		if !cd.blogDomain && (r.Method == http.MethodGet || r.Method == http.MethodHead) && !a.belongsToCustomDomain(cd, path) {
+			// Other content is only available on the main domain
+			http.Redirect(w, r, a.getFullAddress(r.URL.RequestURI()), http.StatusMovedPermanently)
+			return
+		}
+		if path != r.URL.Path {
+			newURL := *r.URL
+			newURL.Path = path
+			newURL.RawPath = ""
+			r = r.Clone(r.Context())
+			r.URL = &newURL
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check if a path (after rewriting) is the section or page of the custom domain, a post in the section
+// or a resource the pages load, like assets and media files
+func (a *goBlog) belongsToCustomDomain(cd *configCustomDomain, path string) bool {
+	switch {
+	case path == cd.targetPath:
+		return true
+	case cd.Section != "" && (strings.HasPrefix(path, cd.targetPath+"/") || strings.HasPrefix(path, cd.targetPath+".")):
+		return true
+	case a.loadedAssets().files[strings.TrimPrefix(path, "/")] != nil, hasStaticPath(path):
+		return true
+	case lo.SomeBy([]string{"/m/", "/-/", "/captcha/", "/.well-known/", profileImagePath + "."}, func(prefix string) bool {
+		return strings.HasPrefix(path, prefix)
+	}):
+		return true
+	case path == robotsTXTPath || path == "/favicon.ico":
+		return true
+	}
+	if cd.Section == "" {
+		return false
+	}
+	// Posts of the section can have paths outside of the section path
+	p, err := a.getPost(path)
+	return err == nil && a.customDomainForPost(p) == cd
+}
+
+// Paths on a blog domain are relative to the blog. Global paths (like media files or ActivityPub endpoints)
+// and paths that already start with the blog path stay the same.
+func blogDomainPath(cd *configCustomDomain, next http.Handler, method, path string) string {
+	switch {
+	case path == "" || path == "/":
+		return cd.targetPath
+	case path == cd.targetPath || strings.HasPrefix(path, cd.targetPath+"/") || strings.HasPrefix(path, cd.targetPath+"."):
+		return path
+	}
+	blogPath := cd.targetPath + path
+	if strings.HasPrefix(path, "/.") {
+		// Feeds of the blog
+		blogPath = cd.targetPath + strings.TrimPrefix(path, "/")
+	}
+	// Posts aren't routes, so only use the path as is if it's a route and the blog path isn't
+	if routes, ok := next.(chi.Routes); ok &&
+		!routes.Match(chi.NewRouteContext(), method, blogPath) && routes.Match(chi.NewRouteContext(), method, path) {
+		return path
+	}
+	return blogPath
+}
+
+// Get the domain of a host, if it's the domain of a blog
+func (a *goBlog) blogDomainForHost(host string) *configCustomDomain {
+	cd, _ := lo.Find(a.cfg.Server.CustomDomains, func(cd *configCustomDomain) bool {
+		return cd.blogDomain && cd.hostname == host
+	})
+	return cd
+}
+
+// Full address of a global path (like the ActivityPub inbox) on the domain of the blog, if it has one
+func (a *goBlog) getBlogDomainAddress(bc *configBlog, path string) string {
+	if bc.domain != nil {
+		return bc.domain.Address + path
+	}
+	return a.getFullAddress(path)
+}
+
+// Hostname of the blog, for example for the fediverse handles
+func (a *goBlog) getBlogHostname(bc *configBlog) string {
+	if bc.domain != nil {
+		return bc.domain.hostname
+	}
+	return a.cfg.Server.publicHostname
+}
+
+// Get the custom domain for a path, if the path belongs to the section or page of a custom domain
+func (a *goBlog) customDomainForPath(path string) *configCustomDomain {
+	for _, cd := range a.cfg.Server.CustomDomains {
+		if cd.targetPath == "" {
+			continue
+		}
+		if path == cd.targetPath {
+			return cd
+		}
+		if (cd.Section != "" || cd.blogDomain) && (strings.HasPrefix(path, cd.targetPath+"/") || strings.HasPrefix(path, cd.targetPath+".")) {
+			return cd
+		}
+	}
+	return nil
+}
+
+// Get the custom domain for a post, if it's in a section or is a page with a custom domain
+func (a *goBlog) customDomainForPost(p *post) *configCustomDomain {
+	for _, cd := range a.cfg.Server.CustomDomains {
+		if cd.targetPath == "" {
+			continue
+		}
+		if cd.Path != "" && p.Path == cd.Path {
+			return cd
+		}
+		if cd.Section != "" && p.Section == cd.Section && defaultIfEmpty(p.Blog, a.cfg.DefaultBlog) == cd.Blog {
+			return cd
+		}
+		if cd.blogDomain && defaultIfEmpty(p.Blog, a.cfg.DefaultBlog) == cd.Blog {
+			return cd
+		}
+	}
+	return nil
+}
+
+func (cd *configCustomDomain) getFullAddress(path string) string {
+	switch {
+	case cd.blogDomain:
+		if rest, ok := strings.CutPrefix(path, cd.targetPath); ok && (rest == "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, ".")) {
+			if strings.HasPrefix(rest, ".") {
+				// Feeds of the blog
+				rest = "/" + rest
+			}
+			return cd.Address + rest
+		}
+		return cd.Address + path
+	case path == cd.targetPath:
+		return cd.Address
+	case cd.Section != "" && strings.HasPrefix(path, cd.targetPath+"."):
+		// Feeds of the section
+		return cd.Address + "/" + strings.TrimPrefix(path, cd.targetPath)
+	case cd.Section != "" && strings.HasPrefix(path, cd.targetPath+"/page/"):
+		// Pagination of the section
+		return cd.Address + strings.TrimPrefix(path, cd.targetPath)
+	default:
+		return cd.Address + path
+	}
+}
+
+// Like getFullAddress, but uses the custom domain if the path belongs to one
+func (a *goBlog) getCanonicalAddress(path string) string {
+	if cd := a.customDomainForPath(path); cd != nil {
+		return cd.getFullAddress(path)
+	}
+	return a.getFullAddress(path)
+}
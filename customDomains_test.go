@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_customDomains(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.CustomDomains = []*configCustomDomain{
+		{Address: "https://posts.example.com/", Section: "posts"},
+		{Address: "https://about.example.com", Path: "/about"},
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+
+	app.d = app.buildRouter()
+	handlerClient := newHandlerClient(app.d)
+
+	err := app.createPost(&post{
+		Path:       "/posts/test",
+		Section:    "posts",
+		Published:  "2020-01-01T00:00:00Z",
+		Parameters: map[string][]string{"title": {"Test Post"}},
+		Content:    "Test Content",
+	})
+	require.NoError(t, err)
+	err = app.createPost(&post{
+		Path:    "/about",
+		Content: "About me",
+	})
+	require.NoError(t, err)
+
+	t.Run("URLs", func(t *testing.T) {
+		p, err := app.getPost("/posts/test")
+		require.NoError(t, err)
+		assert.Equal(t, "https://posts.example.com/posts/test", app.fullPostURL(p))
+
+		p, err = app.getPost("/about")
+		require.NoError(t, err)
+		assert.Equal(t, "https://about.example.com", app.fullPostURL(p))
+
+		assert.Equal(t, "https://posts.example.com", app.getCanonicalAddress("/posts"))
+		assert.Equal(t, "https://posts.example.com/page/2", app.getCanonicalAddress("/posts/page/2"))
+		assert.Equal(t, "https://posts.example.com/.rss", app.getCanonicalAddress("/posts.rss"))
+		assert.Equal(t, "http://localhost:8080/other", app.getCanonicalAddress("/other"))
+	})
+
+	t.Run("Section domain", func(t *testing.T) {
+		var body string
+		err := requests.URL("http://posts.example.com/").Client(handlerClient).ToString(&body).Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, body, "Test Post")
+		assert.Contains(t, body, `<link rel=canonical href=https://posts.example.com>`)
+
+		var feed *gofeed.Feed
+		err = requests.URL("http://posts.example.com/.rss").Client(handlerClient).
+			Handle(func(r *http.Response) (err error) {
+				defer r.Body.Close()
+				feed, err = gofeed.NewParser().Parse(r.Body)
+				return
+			}).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "https://posts.example.com", feed.Link)
+		if assert.Len(t, feed.Items, 1) {
+			assert.Equal(t, "https://posts.example.com/posts/test", feed.Items[0].Link)
+		}
+	})
+
+	t.Run("Page domain", func(t *testing.T) {
+		var body string
+		err := requests.URL("http://about.example.com/").Client(handlerClient).ToString(&body).Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, body, "About me")
+	})
+
+	t.Run("Other paths", func(t *testing.T) {
+		get := func(url string) (int, string) {
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			rec := httptest.NewRecorder()
+			app.d.ServeHTTP(rec, req)
+			return rec.Code, rec.Header().Get("Location")
+		}
+
+		// Content of the section and resources stay on the custom domain
+		status, _ := get("http://posts.example.com/posts/test")
+		assert.Equal(t, http.StatusOK, status)
+		status, _ = get("http://posts.example.com/page/1")
+		assert.NotEqual(t, http.StatusMovedPermanently, status)
+		for name := range app.loadedAssets().files {
+			status, _ = get("http://posts.example.com/" + name)
+			assert.Equal(t, http.StatusOK, status)
+			break
+		}
+
+		// Other content redirects to the main domain
+		status, location := get("http://posts.example.com/about?x=1")
+		assert.Equal(t, http.StatusMovedPermanently, status)
+		assert.Equal(t, "http://localhost:8080/about?x=1", location)
+		status, location = get("http://about.example.com/posts/test")
+		assert.Equal(t, http.StatusMovedPermanently, status)
+		assert.Equal(t, "http://localhost:8080/posts/test", location)
+		status, location = get("http://about.example.com/search")
+		assert.Equal(t, http.StatusMovedPermanently, status)
+		assert.Equal(t, "http://localhost:8080/search", location)
+	})
+
+	t.Run("Invalid config", func(t *testing.T) {
+		app := &goBlog{
+			cfg: createDefaultTestConfig(t),
+		}
+		app.cfg.Server.CustomDomains = []*configCustomDomain{
+			{Address: "https://invalid.example.com", Section: "invalid"},
+		}
+		assert.Error(t, app.initConfig(false))
+	})
+}
+
+func Test_blogDomains(t *testing.T) {
+	h := newTestHarness(t, withActivityPub, func(c *config) {
+		c.Blogs = map[string]*configBlog{
+			"en": {
+				Lang:     "en",
+				Sections: map[string]*configSection{"posts": {Name: "posts"}},
+			},
+			"de": {
+				Lang:     "de",
+				Path:     "/de",
+				Domain:   "https://de.example.com/",
+				Sections: map[string]*configSection{"posts": {Name: "posts"}},
+			},
+		}
+		c.DefaultBlog = "en"
+	})
+	h.createPost(&post{Path: "/en-post", Blog: "en", Content: "English post"})
+	p := h.createPost(&post{Path: "/de/posts/hallo", Blog: "de", Section: "posts", Content: "Deutscher Beitrag"})
+
+	t.Run("URLs", func(t *testing.T) {
+		assert.Equal(t, "https://de.example.com/posts/hallo", h.app.fullPostURL(p))
+		en, err := h.app.getPost("/en-post")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/en-post", h.app.fullPostURL(en))
+
+		assert.Equal(t, "https://de.example.com", h.app.getCanonicalAddress("/de"))
+		assert.Equal(t, "https://de.example.com/posts", h.app.getCanonicalAddress("/de/posts"))
+		assert.Equal(t, "https://de.example.com/.rss", h.app.getCanonicalAddress("/de.rss"))
+		assert.Equal(t, "https://example.com/development", h.app.getCanonicalAddress("/development"))
+
+		assert.Contains(t, h.app.autocertHosts(), "de.example.com")
+	})
+
+	get := func(url string) (status int, body string) {
+		require.NoError(t, requests.URL(url).Client(h.client).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			ToString(&body).
+			Fetch(context.Background()))
+		return
+	}
+
+	t.Run("Routing", func(t *testing.T) {
+		status, body := get("https://de.example.com/")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "Deutscher Beitrag")
+		assert.Contains(t, body, "<link rel=canonical href=https://de.example.com>")
+		assert.NotContains(t, body, "English post")
+
+		status, body = get("https://de.example.com/posts/hallo")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "Deutscher Beitrag")
+
+		// Paths with the blog path and global paths still work
+		status, _ = get("https://de.example.com/de/posts/hallo")
+		assert.Equal(t, http.StatusOK, status)
+		status, _ = get("https://de.example.com/robots.txt")
+		assert.Equal(t, http.StatusOK, status)
+
+		// The main domain is unchanged
+		status, body = get("https://example.com/")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "English post")
+	})
+
+	t.Run("ActivityPub", func(t *testing.T) {
+		de := h.app.cfg.Blogs["de"]
+		assert.Equal(t, "https://de.example.com", h.app.apIri(de))
+		person := h.app.toApPerson("de")
+		assert.Equal(t, "https://de.example.com/activitypub/inbox/de", person.Inbox.GetLink().String())
+		assert.Equal(t, "https://example.com", h.app.apIri(h.app.cfg.Blogs["en"]))
+
+		webfinger := func(host, resource string) (status int, body string) {
+			return get("https://" + host + "/.well-known/webfinger?resource=" + resource)
+		}
+		status, body := webfinger("de.example.com", "acct:de@de.example.com")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, `"subject":"acct:de@de.example.com"`)
+		assert.Contains(t, body, `"href":"https://de.example.com"`)
+		status, _ = webfinger("de.example.com", "acct:de.example.com@de.example.com")
+		assert.Equal(t, http.StatusOK, status)
+
+		// The handle on the public hostname still works, but only on the public hostname
+		status, body = webfinger("example.com", "acct:de@example.com")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, `"subject":"acct:de@de.example.com"`)
+		status, _ = webfinger("de.example.com", "acct:en@example.com")
+		assert.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("Invalid config", func(t *testing.T) {
+		app := &goBlog{
+			cfg: createDefaultTestConfig(t),
+		}
+		app.cfg.Blogs = map[string]*configBlog{
+			"en": {Domain: "https://blog.example.com"},
+		}
+		assert.ErrorContains(t, app.initConfig(false), "needs a path")
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// Custom pages list the posts matching a query (sections, taxonomy value, parameter) like the other index pages,
+// for example a portfolio page with all posts of a "projects" section using the photo summary.
+
+func (a *goBlog) pageIndexConfig(bc *configBlog, page *configPage) *indexConfig {
+	ic := &indexConfig{
+		path:            bc.getRelativePath(page.Path),
+		title:           page.Title,
+		description:     page.Description,
+		parameter:       page.Parameter,
+		parameterValue:  page.ParameterValue,
+		sort:            postsSort(strings.ToLower(page.Sort)),
+		summaryTemplate: summaryTyp(strings.ToLower(page.Template)),
+	}
+	for _, name := range page.Sections {
+		if section, ok := bc.Sections[name]; ok {
+			ic.sections = append(ic.sections, section)
+		}
+	}
+	if page.Taxonomy != "" && page.TaxonomyValue != "" {
+		ic.tax, _ = lo.Find(bc.Taxonomies, func(t *configTaxonomy) bool { return t.Name == page.Taxonomy })
+		ic.taxValue = page.TaxonomyValue
+	}
+	return ic
+}
+
+// Check the query of a custom page, returns the config key and the error for each issue
+func checkPageConfig(bc *configBlog, page *configPage) map[string]error {
+	issues := map[string]error{}
+	if !strings.HasPrefix(page.Path, "/") {
+		issues["path"] = fmt.Errorf("path %q must start with a slash", page.Path)
+	}
+	for _, name := range page.Sections {
+		if _, ok := bc.Sections[name]; !ok {
+			issues["sections"] = fmt.Errorf("unknown section %q", name)
+		}
+	}
+	if page.Taxonomy != "" && !lo.ContainsBy(bc.Taxonomies, func(t *configTaxonomy) bool { return t.Name == page.Taxonomy }) {
+		issues["taxonomy"] = fmt.Errorf("unknown taxonomy %q", page.Taxonomy)
+	}
+	if s := postsSort(strings.ToLower(page.Sort)); s != "" && !lo.Contains([]postsSort{postsSortNewest, postsSortOldest, postsSortUpdated}, s) {
+		issues["sort"] = fmt.Errorf("invalid sort %q, use newest, oldest or updated", page.Sort)
+	}
+	if t := summaryTyp(strings.ToLower(page.Template)); t != "" && t != defaultSummary && t != photoSummary {
+		issues["template"] = fmt.Errorf("invalid template %q, use summary or photosummary", page.Template)
+	}
+	return issues
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_customPages(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	bc := &configBlog{
+		Path:       "/",
+		Lang:       "en",
+		Pagination: 1,
+		Sections: map[string]*configSection{
+			"posts":    {},
+			"projects": {},
+		},
+		DefaultSection: "posts",
+		Taxonomies:     []*configTaxonomy{{Name: "tags", Title: "Tags"}},
+		Pages: []*configPage{
+			{Path: "/portfolio", Title: "Portfolio", Sections: []string{"projects"}, Sort: "oldest"},
+			{Path: "/go", Title: "Go", Taxonomy: "tags", TaxonomyValue: "go"},
+			{Path: "/featured", Title: "Featured", Parameter: "featured", ParameterValue: "yes", Template: "photosummary"},
+		},
+	}
+	app.cfg.Blogs = map[string]*configBlog{"default": bc}
+	app.cfg.DefaultBlog = "default"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/first-project", Section: "projects", Published: "2023-01-01T10:00:00Z", Content: "First project", Parameters: map[string][]string{"tags": {"go"}}},
+		{Path: "/second-project", Section: "projects", Published: "2023-02-01T10:00:00Z", Content: "Second project", Parameters: map[string][]string{"featured": {"yes"}}},
+		{Path: "/blogpost", Section: "posts", Published: "2023-03-01T10:00:00Z", Content: "Blog post", Parameters: map[string][]string{"tags": {"go"}}},
+	} {
+		p.Status, p.Visibility = statusPublished, visibilityPublic
+		require.NoError(t, app.createPost(p))
+	}
+
+	client := newHandlerClient(app.d)
+	get := func(path string) string {
+		var body string
+		require.NoError(t, requests.URL("http://localhost:8080"+path).Client(client).ToString(&body).Fetch(context.Background()))
+		return body
+	}
+
+	// Sections, sorted with the oldest first and paginated
+	body := get("/portfolio")
+	assert.Contains(t, body, "Portfolio")
+	assert.Contains(t, body, "First project")
+	assert.NotContains(t, body, "Second project")
+	assert.Contains(t, body, "href=/portfolio/page/2")
+	body = get("/portfolio/page/2")
+	assert.Contains(t, body, "Second project")
+	assert.NotContains(t, body, "Blog post")
+
+	// Taxonomy value, newest first
+	body = get("/go")
+	assert.Contains(t, body, "Blog post")
+	assert.Contains(t, get("/go/page/2"), "First project")
+
+	// Parameter
+	body = get("/featured")
+	assert.Contains(t, body, "Second project")
+	assert.NotContains(t, body, "href=/featured/page/2")
+
+	// Feed
+	assert.Contains(t, get("/portfolio.rss"), "First project")
+
+	t.Run("Check config", func(t *testing.T) {
+		assert.Empty(t, checkPageConfig(bc, bc.Pages[0]))
+		issues := checkPageConfig(bc, &configPage{Path: "page", Sections: []string{"unknown"}, Taxonomy: "categories", Sort: "title", Template: "list"})
+		assert.Len(t, issues, 5)
+		assert.Contains(t, issues["sort"].Error(), "invalid sort")
+	})
+}
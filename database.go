@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dgraph-io/ristretto"
 	"github.com/google/uuid"
@@ -25,6 +26,7 @@ type database struct {
 	// Other things
 	pc    singleflight.Group // persistant cache
 	pcm   sync.Mutex         // post creation
+	pum   sync.Mutex         // post updates
 	sp    singleflight.Group // singleflight group for short path requests
 	spc   *ristretto.Cache   // shortpath cache
 	debug bool
@@ -45,6 +47,8 @@ func (a *goBlog) initDatabase(logging bool) (err error) {
 	// Create appDB
 	a.db = db
 	a.shutdown.Add(func() {
+		// Wait for servers and workers that still need the database
+		a.dbUsersWg.Wait()
 		if err := db.close(); err != nil {
 			log.Printf("Failed to close database: %v", err)
 		} else {
@@ -63,28 +67,39 @@ func (a *goBlog) initDatabase(logging bool) (err error) {
 	return nil
 }
 
+// Add a shutdown function that has to finish before the database gets closed
+func (a *goBlog) addShutdownBeforeDatabase(f func()) {
+	a.dbUsersWg.Add(1)
+	a.shutdown.Add(func() {
+		defer a.dbUsersWg.Done()
+		f()
+	})
+}
+
+// Register the custom functions used in queries, views and triggers
+func (a *goBlog) registerDatabaseFunctions(c *sqlite.SQLiteConn) error {
+	for n, f := range map[string]any{
+		"mdtext":         a.renderTextSafe,
+		"tolocal":        toLocalSafe,
+		"toutc":          toUTCSafe,
+		"wordcount":      wordCount,
+		"charcount":      charCount,
+		"urlize":         urlize,
+		"lowerx":         strings.ToLower,
+		"lowerunescaped": lowerUnescapedPath,
+	} {
+		if err := c.RegisterFunc(n, f, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (a *goBlog) openDatabase(file string, logging bool) (*database, error) {
 	// Register driver
 	dbDriverName := "goblog_db_" + uuid.NewString()
 	sql.Register(dbDriverName, &sqlite.SQLiteDriver{
-		ConnectHook: func(c *sqlite.SQLiteConn) error {
-			// Register functions
-			for n, f := range map[string]any{
-				"mdtext":         a.renderTextSafe,
-				"tolocal":        toLocalSafe,
-				"toutc":          toUTCSafe,
-				"wordcount":      wordCount,
-				"charcount":      charCount,
-				"urlize":         urlize,
-				"lowerx":         strings.ToLower,
-				"lowerunescaped": lowerUnescapedPath,
-			} {
-				if err := c.RegisterFunc(n, f, true); err != nil {
-					return err
-				}
-			}
-			return nil
-		},
+		ConnectHook: a.registerDatabaseFunctions,
 	})
 	// Open db
 	db, err := sql.Open(dbDriverName, file+"?mode=rwc&_journal=WAL&_timeout=100&cache=shared&_fk=1")
@@ -228,10 +243,38 @@ func (db *database) ExecContext(c context.Context, query string, args ...any) (s
 	ctx := db.dbBefore(c, query, args...)
 	defer db.dbAfter(ctx, query, args...)
 	// Execute
-	if st != nil {
-		return st.ExecContext(ctx, args...)
+	var res sql.Result
+	err := dbRetry(ctx, func() (err error) {
+		if st != nil {
+			res, err = st.ExecContext(ctx, args...)
+		} else {
+			res, err = db.db.ExecContext(ctx, query, args...)
+		}
+		return err
+	})
+	return res, err
+}
+
+// Run f in a transaction, which is rolled back if f returns an error.
+// The whole transaction is retried while the database is busy or locked.
+func (db *database) transaction(c context.Context, f func(tx *sql.Tx) error) error {
+	if db == nil || db.db == nil {
+		return errors.New("database not initialized")
 	}
-	return db.db.ExecContext(ctx, query, args...)
+	// Lock execution
+	db.em.Lock()
+	defer db.em.Unlock()
+	return dbRetry(c, func() error {
+		tx, err := db.db.BeginTx(c, nil)
+		if err != nil {
+			return err
+		}
+		if err = f(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
 }
 
 func (db *database) Query(query string, args ...any) (*sql.Rows, error) {
@@ -247,11 +290,14 @@ func (db *database) QueryContext(c context.Context, query string, args ...any) (
 	// Prepare context, call hook
 	ctx := db.dbBefore(c, query, args...)
 	// Query
-	if st != nil {
-		rows, err = st.QueryContext(ctx, args...)
-	} else {
-		rows, err = db.db.QueryContext(ctx, query, args...)
-	}
+	err = dbRetry(ctx, func() (err error) {
+		if st != nil {
+			rows, err = st.QueryContext(ctx, args...)
+		} else {
+			rows, err = db.db.QueryContext(ctx, query, args...)
+		}
+		return err
+	})
 	// Call hook
 	db.dbAfter(ctx, query, args...)
 	return
@@ -270,18 +316,51 @@ func (db *database) QueryRowContext(c context.Context, query string, args ...any
 	// Prepare context, call hook
 	ctx := db.dbBefore(c, query, args...)
 	// Query
-	if st != nil {
-		row = st.QueryRowContext(ctx, args...)
-	} else {
-		row = db.db.QueryRowContext(ctx, query, args...)
-	}
+	_ = dbRetry(ctx, func() error {
+		if st != nil {
+			row = st.QueryRowContext(ctx, args...)
+		} else {
+			row = db.db.QueryRowContext(ctx, query, args...)
+		}
+		// Errors of the query are returned when scanning the row
+		return row.Err()
+	})
 	// Call hook
 	db.dbAfter(ctx, query, args...)
 	return
 }
 
+const (
+	dbBusyRetries = 5
+	dbBusyBackoff = 50 * time.Millisecond
+)
+
+// Retry f with exponential backoff while the database is busy or locked (e.g. during backups),
+// so short locks don't result in errors for visitors
+func dbRetry(ctx context.Context, f func() error) error {
+	backoff := dbBusyBackoff
+	for i := 0; ; i++ {
+		err := f()
+		if i >= dbBusyRetries || !dbBusy(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func dbBusy(err error) bool {
+	var sqliteErr sqlite.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite.ErrBusy || sqliteErr.Code == sqlite.ErrLocked)
+}
+
 // Other things
 
-func (d *database) rebuildFTSIndex() {
-	_, _ = d.Exec("insert into posts_fts(posts_fts) values ('rebuild')")
+func (d *database) rebuildFTSIndex() error {
+	_, err := d.Exec("insert into posts_fts(posts_fts) values ('rebuild')")
+	return err
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"embed"
+	"fmt"
 	"io/fs"
 	"log"
 	"strings"
@@ -10,10 +11,40 @@ import (
 	"github.com/lopezator/migrator"
 )
 
+// Schema changes are SQL files in dbmigrations, named with a sequential number (like 00001.sql).
+// They are applied in order at startup, each in a transaction, and recorded in the migrations table.
+// Never change or rename an existing migration, always add a new one.
+
 //go:embed dbmigrations/*
 var dbMigrations embed.FS
 
+const dbMigrationsTable = "migrations"
+
 func migrateDb(db *sql.DB, logging bool) error {
+	sqlMigrations, err := dbMigrationList()
+	if err != nil {
+		return err
+	}
+	if err = checkAppliedMigrations(db, sqlMigrations); err != nil {
+		return err
+	}
+	m, err := migrator.New(
+		migrator.TableName(dbMigrationsTable),
+		migrator.WithLogger(migrator.LoggerFunc(func(s string, i ...any) {
+			if logging {
+				log.Printf(s, i...)
+			}
+		})),
+		migrator.Migrations(sqlMigrations...),
+	)
+	if err != nil {
+		return err
+	}
+	return m.Migrate(db)
+}
+
+// The embedded migrations in order
+func dbMigrationList() ([]any, error) {
 	var sqlMigrations []any
 	err := fs.WalkDir(dbMigrations, "dbmigrations", func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.Type().IsDir() {
@@ -35,19 +66,37 @@ func migrateDb(db *sql.DB, logging bool) error {
 		sqlMigrations = append(sqlMigrations, mig)
 		return nil
 	})
-	if err != nil {
+	return sqlMigrations, err
+}
+
+// The migrator only counts the applied migrations, so check that they match the embedded ones
+// to not silently skip a migration that was renamed or added in between
+func checkAppliedMigrations(db *sql.DB, migrations []any) error {
+	var tables int
+	if err := db.QueryRow("select count(*) from sqlite_master where type = 'table' and name = @name", sql.Named("name", dbMigrationsTable)).Scan(&tables); err != nil {
 		return err
 	}
-	m, err := migrator.New(
-		migrator.WithLogger(migrator.LoggerFunc(func(s string, i ...any) {
-			if logging {
-				log.Printf(s, i)
-			}
-		})),
-		migrator.Migrations(sqlMigrations...),
-	)
+	if tables == 0 {
+		// New database
+		return nil
+	}
+	rows, err := db.Query("select id, version from " + dbMigrationsTable + " order by id")
 	if err != nil {
 		return err
 	}
-	return m.Migrate(db)
+	defer rows.Close()
+	var id int
+	var version string
+	for rows.Next() {
+		if err = rows.Scan(&id, &version); err != nil {
+			return err
+		}
+		if id >= len(migrations) {
+			return fmt.Errorf("database has unknown migration %s applied, is this an older version of GoBlog?", version)
+		}
+		if expected := migrations[id].(fmt.Stringer).String(); version != expected {
+			return fmt.Errorf("applied database migration %s doesn't match expected migration %s", version, expected)
+		}
+	}
+	return rows.Err()
 }
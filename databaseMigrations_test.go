@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	sqlite "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_databaseMigrations(t *testing.T) {
+	entries, err := fs.ReadDir(dbMigrations, "dbmigrations")
+	require.NoError(t, err)
+
+	t.Run("Sequential names", func(t *testing.T) {
+		for i, e := range entries {
+			assert.Equal(t, fmt.Sprintf("%05d.sql", i+1), e.Name())
+		}
+	})
+
+	app := &goBlog{
+		cfg: &config{},
+	}
+	file := filepath.Join(t.TempDir(), "blog.db")
+
+	t.Run("Apply all migrations", func(t *testing.T) {
+		db, err := app.openDatabase(file, false)
+		require.NoError(t, err)
+
+		var count int
+		require.NoError(t, db.db.QueryRow("select count(*) from migrations").Scan(&count))
+		assert.Equal(t, len(entries), count)
+
+		var last string
+		require.NoError(t, db.db.QueryRow("select version from migrations order by id desc limit 1").Scan(&last))
+		assert.Equal(t, strings.TrimSuffix(entries[len(entries)-1].Name(), ".sql"), last)
+
+		require.NoError(t, db.close())
+
+		// Opening again doesn't apply anything
+		db, err = app.openDatabase(file, false)
+		require.NoError(t, err)
+		require.NoError(t, db.db.QueryRow("select count(*) from migrations").Scan(&count))
+		assert.Equal(t, len(entries), count)
+		require.NoError(t, db.close())
+	})
+
+	t.Run("Mismatching migration", func(t *testing.T) {
+		db, err := app.openDatabase(file, false)
+		require.NoError(t, err)
+		_, err = db.db.Exec("update migrations set version = 'other' where id = 1")
+		require.NoError(t, err)
+		require.NoError(t, db.close())
+
+		_, err = app.openDatabase(file, false)
+		assert.ErrorContains(t, err, "doesn't match")
+	})
+
+	t.Run("Unknown migration", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "blog.db")
+		db, err := app.openDatabase(file, false)
+		require.NoError(t, err)
+		_, err = db.db.Exec(fmt.Sprintf("insert into migrations (id, version) values (%d, '99999')", len(entries)))
+		require.NoError(t, err)
+		require.NoError(t, db.close())
+
+		_, err = app.openDatabase(file, false)
+		assert.ErrorContains(t, err, "unknown migration")
+	})
+}
+
+func Test_databaseMigrationComments(t *testing.T) {
+	driverName := "goblog_db_" + uuid.NewString()
+	sql.Register(driverName, &sqlite.SQLiteDriver{
+		ConnectHook: (&goBlog{cfg: &config{}}).registerDatabaseFunctions,
+	})
+	db, err := sql.Open(driverName, filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	apply := func(name string) {
+		fd, err := dbMigrations.ReadFile("dbmigrations/" + name + ".sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(fd))
+		require.NoError(t, err)
+	}
+	for i := 1; i < 43; i++ {
+		apply(fmt.Sprintf("%05d", i))
+	}
+
+	// Old schema: comments and webmentions in separate tables, comments send webmentions to themselves
+	_, err = db.Exec(`
+		insert into comments (id, target, name, website, comment, original) values (1, '/Post', 'Name', '', 'Hi', ''), (2, '/Post', 'Actor', '', 'Hey', 'https://social.example/1');
+		insert into webmentions (source, target, url, created, status, title, content, author) values
+			('https://example.com/comment/1', 'https://example.com/post', '', 100, 'approved', '', 'Hi', 'Name'),
+			('https://example.com/comment/2', 'https://example.com/post', '', 200, 'verified', '', 'Hey', 'Actor'),
+			('https://example.net/reply', 'https://example.com/comment/1', 'https://example.net/reply', 300, 'approved', 'Reply', '', 'Other'),
+			('https://example.net/like', 'https://example.com/post', '', 400, 'new', null, null, null);
+	`)
+	require.NoError(t, err)
+
+	apply("00043")
+
+	type row struct {
+		typ, target, source, status string
+		created                     int64
+		parent                      sql.NullInt64
+	}
+	rows := map[string]*row{}
+	res, err := db.Query("select type, target, source, status, created, parent from comments")
+	require.NoError(t, err)
+	for res.Next() {
+		r := &row{}
+		require.NoError(t, res.Scan(&r.typ, &r.target, &r.source, &r.status, &r.created, &r.parent))
+		rows[r.source] = r
+	}
+	require.NoError(t, res.Err())
+	require.Len(t, rows, 4)
+
+	if r := rows["https://example.com/comment/1"]; assert.NotNil(t, r) {
+		assert.Equal(t, "form", r.typ)
+		assert.Equal(t, "https://example.com/Post", r.target)
+		assert.Equal(t, "approved", r.status)
+		assert.Equal(t, int64(100), r.created)
+	}
+	if r := rows["https://example.com/comment/2"]; assert.NotNil(t, r) {
+		assert.Equal(t, "activitypub", r.typ)
+		assert.Equal(t, "verified", r.status)
+	}
+	if r := rows["https://example.net/reply"]; assert.NotNil(t, r) {
+		assert.Equal(t, "webmention", r.typ)
+		assert.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, r.parent)
+	}
+	if r := rows["https://example.net/like"]; assert.NotNil(t, r) {
+		assert.Equal(t, "webmention", r.typ)
+		assert.False(t, r.parent.Valid)
+	}
+
+	// The webmentions table is gone
+	_, err = db.Exec("select 1 from webmentions")
+	assert.Error(t, err)
+
+	// New replies get their parent from the trigger
+	_, err = db.Exec("insert into comments (type, target, source, name, website, comment) values ('webmention', 'https://example.com/Comment/2', 'https://example.net/reply2', '', '', '')")
+	require.NoError(t, err)
+	var parent int
+	require.NoError(t, db.QueryRow("select parent from comments where source = 'https://example.net/reply2'").Scan(&parent))
+	assert.Equal(t, 2, parent)
+}
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
 	"testing"
+
+	sqlite "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_database(t *testing.T) {
@@ -61,3 +69,74 @@ func Test_database(t *testing.T) {
 		}
 	})
 }
+
+func Test_dbRetry(t *testing.T) {
+	busyErr := sqlite.Error{Code: sqlite.ErrBusy}
+
+	// Succeeds after the database is no longer busy
+	attempts := 0
+	err := dbRetry(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return busyErr
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// Other errors aren't retried
+	attempts = 0
+	err = dbRetry(context.Background(), func() error {
+		attempts++
+		return errors.New("other error")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// Gives up when the context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	err = dbRetry(ctx, func() error {
+		attempts++
+		return busyErr
+	})
+	assert.True(t, dbBusy(err))
+	assert.Equal(t, 1, attempts)
+}
+
+func Test_dbTransaction(t *testing.T) {
+	app := &goBlog{
+		cfg: &config{},
+	}
+	db, err := app.openDatabase(filepath.Join(t.TempDir(), "test.db"), false)
+	require.NoError(t, err)
+	defer db.close()
+	_, err = db.Exec("create table test(test text)")
+	require.NoError(t, err)
+	count := func() (count int) {
+		row, err := db.QueryRow("select count(*) from test")
+		require.NoError(t, err)
+		require.NoError(t, row.Scan(&count))
+		return count
+	}
+
+	// Rolled back on errors
+	err = db.transaction(context.Background(), func(tx *sql.Tx) error {
+		if _, err := tx.Exec("insert into test (test) values ('a')"); err != nil {
+			return err
+		}
+		_, err := tx.Exec("insert into unknown (test) values ('b')")
+		return err
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 0, count())
+
+	// Committed otherwise, the connection isn't left in a transaction
+	require.NoError(t, db.transaction(context.Background(), func(tx *sql.Tx) error {
+		_, err := tx.Exec("insert into test (test) values ('a')")
+		return err
+	}))
+	assert.Equal(t, 1, count())
+}
@@ -0,0 +1,5 @@
+create table activitypub_followers_history (id integer primary key autoincrement, blog text not null, follower text not null, action text not null, time text not null);
+create index index_apfh_blog_time on activitypub_followers_history (blog, time);
+insert into activitypub_followers_history (blog, follower, action, time) select blog, follower, 'add', strftime('%Y-%m-%dT%H:%M:%SZ', 'now') from activitypub_followers;
+create trigger trigger_apfh_add before insert on activitypub_followers when not exists (select 1 from activitypub_followers where blog = new.blog and follower = new.follower) begin insert into activitypub_followers_history (blog, follower, action, time) values (new.blog, new.follower, 'add', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); end;
+create trigger trigger_apfh_remove after delete on activitypub_followers begin insert into activitypub_followers_history (blog, follower, action, time) values (old.blog, old.follower, 'remove', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')); end;
\ No newline at end of file
@@ -0,0 +1,6 @@
+create table relme (
+    identity text primary key,
+    verified int not null default 0,
+    checked text not null default '',
+    message text not null default ''
+);
\ No newline at end of file
@@ -0,0 +1,12 @@
+create table analytics_hits (
+    day text not null,
+    path text not null,
+    hits integer not null default 0,
+    primary key (day, path)
+);
+create table analytics_referrers (
+    day text not null,
+    referrer text not null,
+    hits integer not null default 0,
+    primary key (day, referrer)
+);
\ No newline at end of file
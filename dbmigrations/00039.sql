@@ -0,0 +1 @@
+alter table sections add contentwarning text not null default '';
\ No newline at end of file
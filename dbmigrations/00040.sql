@@ -0,0 +1,3 @@
+alter table shortpath add code text;
+alter table shortpath add clicks integer not null default 0;
+create unique index shortpath_code on shortpath (code);
\ No newline at end of file
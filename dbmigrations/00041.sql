@@ -0,0 +1,2 @@
+create table feedreader (feed text not null, id text not null, title text not null default '', link text not null default '', summary text not null default '', published text not null default '', fetched text not null default '', dismissed integer not null default 0, primary key (feed, id));
+create index index_feedreader_published on feedreader (dismissed, published);
\ No newline at end of file
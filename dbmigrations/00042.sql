@@ -0,0 +1,2 @@
+create table newsletter_subscribers (blog text not null, email text not null, token text not null, confirmed integer not null default 0, created text not null default '', primary key (blog, email));
+create unique index index_newsletter_subscribers_token on newsletter_subscribers (token);
\ No newline at end of file
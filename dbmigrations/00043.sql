@@ -0,0 +1,20 @@
+alter table comments add type text not null default "form";
+alter table comments add source text not null default "";
+alter table comments add url text not null default "";
+alter table comments add created integer not null default 0;
+alter table comments add status text not null default "new";
+alter table comments add title text not null default "";
+alter table comments add content text not null default "";
+alter table comments add author text not null default "";
+alter table comments add parent integer;
+update comments set type = "activitypub" where original != "";
+update comments set (target, source, url, created, status, title, content, author) = (select case when lower(w.target) like "%" || lower(comments.target) then substr(w.target, 1, length(w.target) - length(comments.target)) || comments.target else w.target end, w.source, w.url, w.created, w.status, coalesce(w.title, ""), coalesce(w.content, ""), coalesce(w.author, "") from webmentions w where w.source like "%/comment/" || comments.id order by w.created desc limit 1) where exists (select 1 from webmentions w where w.source like "%/comment/" || comments.id);
+delete from webmentions where source in (select source from comments where source != "");
+insert into comments (type, target, source, url, created, status, title, content, author, name, website, comment, original) select "webmention", target, source, url, created, status, coalesce(title, ""), coalesce(content, ""), coalesce(author, ""), "", "", "", "" from webmentions;
+drop table webmentions;
+update comments set parent = (select p.id from comments p where p.source != "" and p.id != comments.id and lower(p.source) = lower(comments.target) limit 1);
+create index index_comments_target on comments (target);
+create index index_comments_source on comments (source);
+create index index_comments_parent on comments (parent);
+create trigger trigger_comments_parent_insert after insert on comments begin update comments set parent = (select p.id from comments p where p.source != "" and p.id != new.id and lowerunescaped(p.source) = lowerunescaped(new.target) limit 1) where id = new.id; update comments set parent = new.id where new.source != "" and id != new.id and lowerunescaped(target) = lowerunescaped(new.source); end;
+create trigger trigger_comments_parent_update after update of source, target on comments begin update comments set parent = (select p.id from comments p where p.source != "" and p.id != new.id and lowerunescaped(p.source) = lowerunescaped(new.target) limit 1) where id = new.id; update comments set parent = new.id where new.source != "" and id != new.id and lowerunescaped(target) = lowerunescaped(new.source); end;
\ No newline at end of file
@@ -0,0 +1,2 @@
+create table syndication_status (path text not null, target text not null, status text not null, error text not null default "", url text not null default "", attempts integer not null default 0, updated text not null default "", primary key (path, target));
+create index index_syndication_status_updated on syndication_status (updated);
\ No newline at end of file
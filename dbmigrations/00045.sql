@@ -0,0 +1,8 @@
+create table post_snapshots (
+    path text not null,
+    version integer not null,
+    date text not null default "",
+    html blob not null,
+    primary key (path, version),
+    foreign key (path) references posts(path) on update cascade on delete cascade
+);
@@ -0,0 +1,8 @@
+create table related_posts (
+    path text not null,
+    related text not null,
+    score real not null,
+    primary key (path, related),
+    foreign key (path) references posts(path) on update cascade on delete cascade,
+    foreign key (related) references posts(path) on update cascade on delete cascade
+);
@@ -0,0 +1,3 @@
+create table activitypub_inbox_log (id integer primary key autoincrement, blog text not null, time text not null, type text not null default "", actor text not null default "", object text not null default "", outcome text not null default "");
+create index index_activitypub_inbox_log_blog on activitypub_inbox_log (blog, id);
+create index index_activitypub_inbox_log_time on activitypub_inbox_log (time);
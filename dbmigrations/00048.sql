@@ -0,0 +1,3 @@
+create table users (username text primary key, password text not null, name text not null default "", created text not null default "");
+create table user_blogs (username text not null, blog text not null, role text not null, primary key (username, blog), foreign key (username) references users(username) on update cascade on delete cascade);
+alter table posts add owner text not null default "";
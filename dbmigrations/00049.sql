@@ -0,0 +1 @@
+create table media_files (name text primary key, type text not null default "", size integer not null default 0, created text not null default "");
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/samber/lo"
+)
+
+// Self-test with "goblog doctor", checks the things that are needed for federation and prints a checklist
+
+const (
+	doctorConnectivityURL = "https://example.com/"
+	doctorCertWarnDays    = 14
+	doctorTimeout         = 10 * time.Second
+)
+
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarning
+	doctorFailed
+)
+
+func (s doctorStatus) String() string {
+	switch s {
+	case doctorWarning:
+		return "WARN"
+	case doctorFailed:
+		return "FAIL"
+	default:
+		return " OK "
+	}
+}
+
+type doctorResult struct {
+	name    string
+	status  doctorStatus
+	message string
+}
+
+func doctorOk(name, format string, args ...any) *doctorResult {
+	return &doctorResult{name: name, status: doctorOK, message: fmt.Sprintf(format, args...)}
+}
+
+func doctorWarn(name, format string, args ...any) *doctorResult {
+	return &doctorResult{name: name, status: doctorWarning, message: fmt.Sprintf(format, args...)}
+}
+
+func doctorFail(name string, err error) *doctorResult {
+	return &doctorResult{name: name, status: doctorFailed, message: err.Error()}
+}
+
+// Run all checks and print the results, returns the number of failed checks
+func (a *goBlog) doctor(w io.Writer) int {
+	var results []*doctorResult
+	results = append(results, a.doctorConfig()...)
+	results = append(results, a.doctorDatabase())
+	results = append(results, a.doctorActivityPubKeys()...)
+	results = append(results, a.doctorDNS()...)
+	results = append(results, a.doctorWebfinger()...)
+	results = append(results, a.doctorTLS()...)
+	results = append(results, a.doctorConnectivity())
+	failed, warnings := 0, 0
+	for _, r := range results {
+		fmt.Fprintf(w, "[%s] %s: %s\n", r.status, r.name, r.message)
+		switch r.status {
+		case doctorFailed:
+			failed++
+		case doctorWarning:
+			warnings++
+		}
+	}
+	fmt.Fprintf(w, "%d checks, %d warnings, %d failed\n", len(results), warnings, failed)
+	return failed
+}
+
+func (a *goBlog) doctorConfig() (results []*doctorResult) {
+	const name = "Config"
+	issues, err := a.checkConfig(io.Discard)
+	if err != nil {
+		return []*doctorResult{doctorFail(name, err)}
+	}
+	if issues > 0 {
+		results = append(results, doctorFail(name, fmt.Errorf("%d issues found, run \"goblog check config\" for details", issues)))
+	} else {
+		results = append(results, doctorOk(name, "no issues found"))
+	}
+	if a.apEnabled() {
+		if !strings.HasPrefix(a.cfg.Server.PublicAddress, "https://") {
+			results = append(results, doctorWarn(name, "ActivityPub is enabled, but the public address %s doesn't use HTTPS, other servers will likely reject it", a.cfg.Server.PublicAddress))
+		}
+		if ip := net.ParseIP(a.cfg.Server.publicHostname); a.cfg.Server.publicHostname == "localhost" || ip != nil && (ip.IsLoopback() || ip.IsPrivate()) {
+			results = append(results, doctorWarn(name, "ActivityPub is enabled, but the public hostname %s isn't reachable from other servers", a.cfg.Server.publicHostname))
+		}
+	}
+	return results
+}
+
+// Check that all migrations are applied and the database isn't corrupted
+func (a *goBlog) doctorDatabase() *doctorResult {
+	const name = "Database"
+	migrations, err := dbMigrationList()
+	if err != nil {
+		return doctorFail(name, err)
+	}
+	if err = checkAppliedMigrations(a.db.db, migrations); err != nil {
+		return doctorFail(name, err)
+	}
+	var applied int
+	if err = a.db.db.QueryRow("select count(*) from " + dbMigrationsTable).Scan(&applied); err != nil {
+		return doctorFail(name, err)
+	}
+	if applied != len(migrations) {
+		return doctorFail(name, fmt.Errorf("%d of %d schema migrations applied", applied, len(migrations)))
+	}
+	var check string
+	if err = a.db.db.QueryRow("pragma quick_check").Scan(&check); err != nil {
+		return doctorFail(name, err)
+	}
+	if check != "ok" {
+		return doctorFail(name, errors.New("integrity check failed: "+check))
+	}
+	return doctorOk(name, "schema up to date with %d migrations, integrity check passed", applied)
+}
+
+// Check the stored keys without generating new ones
+func (a *goBlog) doctorActivityPubKeys() (results []*doctorResult) {
+	if !a.apEnabled() {
+		return []*doctorResult{doctorOk("ActivityPub keys", "ActivityPub disabled")}
+	}
+	for _, blog := range sortedStrings(lo.Keys(a.cfg.Blogs)) {
+		results = append(results, a.doctorActivityPubKey(blog))
+	}
+	return results
+}
+
+func (a *goBlog) doctorActivityPubKey(blog string) *doctorResult {
+	name := "ActivityPub key " + blog
+	keyData, err := a.db.retrievePersistentCache(apKeyCacheKey(blog))
+	if err != nil {
+		return doctorFail(name, err)
+	}
+	if keyData == nil {
+		// Not yet migrated
+		keyData, err = a.db.retrievePersistentCache(apLegacyKeyCacheKey)
+		if err != nil {
+			return doctorFail(name, err)
+		}
+	}
+	if keyData == nil {
+		return doctorWarn(name, "no key generated yet, it's created on the next start")
+	}
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return doctorFail(name, errors.New("failed to decode stored key"))
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return doctorFail(name, err)
+	}
+	if err = key.Validate(); err != nil {
+		return doctorFail(name, err)
+	}
+	if bits := key.N.BitLen(); bits < 2048 {
+		return doctorWarn(name, "RSA key with only %d bits", bits)
+	}
+	return doctorOk(name, "valid RSA key with %d bits", key.N.BitLen())
+}
+
+// Configured addresses (public, short, media and custom domains)
+func (a *goBlog) doctorAddresses() []*url.URL {
+	addresses := []string{a.cfg.Server.PublicAddress, a.cfg.Server.ShortPublicAddress, a.cfg.Server.MediaAddress}
+	for _, cd := range a.cfg.Server.CustomDomains {
+		addresses = append(addresses, cd.Address)
+	}
+	var urls []*url.URL
+	for _, address := range lo.Uniq(lo.Compact(addresses)) {
+		if u, err := url.Parse(address); err == nil && u.Hostname() != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func (a *goBlog) doctorDNS() (results []*doctorResult) {
+	for _, hostname := range lo.Uniq(lo.Map(a.doctorAddresses(), func(u *url.URL, _ int) string { return u.Hostname() })) {
+		name := "DNS " + hostname
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		addrs, err := net.DefaultResolver.LookupHost(ctx, hostname)
+		cancel()
+		if err != nil {
+			results = append(results, doctorFail(name, err))
+			continue
+		}
+		results = append(results, doctorOk(name, "resolves to %s", strings.Join(addrs, ", ")))
+	}
+	return results
+}
+
+// Request the WebFinger resource of every blog like other servers do to find the actor
+func (a *goBlog) doctorWebfinger() (results []*doctorResult) {
+	if !a.apEnabled() {
+		return nil
+	}
+	blogs := lo.Keys(a.cfg.Blogs)
+	sort.Strings(blogs)
+	for _, blog := range blogs {
+		acct := "acct:" + blog + "@" + a.getBlogHostname(a.cfg.Blogs[blog])
+		name := "WebFinger " + acct
+		var res struct {
+			Subject string                 `json:"subject"`
+			Links   []*doctorWebfingerLink `json:"links"`
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		err := requests.URL(a.getFullAddress("/.well-known/webfinger")).
+			Param("resource", acct).
+			Client(a.httpClient).
+			ToJSON(&res).
+			Fetch(ctx)
+		cancel()
+		if err != nil {
+			results = append(results, doctorFail(name, err))
+			continue
+		}
+		actor := a.apIri(a.cfg.Blogs[blog])
+		if res.Subject != acct || !lo.ContainsBy(res.Links, func(l *doctorWebfingerLink) bool {
+			return l.Rel == "self" && l.Href == actor
+		}) {
+			results = append(results, doctorFail(name, errors.New("response doesn't link to the actor "+actor)))
+			continue
+		}
+		results = append(results, doctorOk(name, "links to %s", actor))
+	}
+	return results
+}
+
+type doctorWebfingerLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// Check the certificates of all HTTPS addresses
+func (a *goBlog) doctorTLS() (results []*doctorResult) {
+	for _, u := range a.doctorAddresses() {
+		if u.Scheme != "https" {
+			continue
+		}
+		name := "TLS " + u.Hostname()
+		port := defaultIfEmpty(u.Port(), "443")
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+		cancel()
+		if err != nil {
+			results = append(results, doctorFail(name, err))
+			continue
+		}
+		certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+		_ = conn.Close()
+		if len(certs) == 0 {
+			results = append(results, doctorFail(name, errors.New("no certificate")))
+			continue
+		}
+		results = append(results, doctorCertResult(name, certs[0], time.Now()))
+	}
+	return results
+}
+
+func doctorCertResult(name string, cert *x509.Certificate, now time.Time) *doctorResult {
+	days := int(cert.NotAfter.Sub(now).Hours() / 24)
+	if days < doctorCertWarnDays {
+		return doctorWarn(name, "certificate expires in %d days (%s)", days, cert.NotAfter.Format(time.RFC3339))
+	}
+	return doctorOk(name, "certificate valid until %s", cert.NotAfter.Format(time.RFC3339))
+}
+
+// Check that outgoing requests work (needed to deliver activities and webmentions)
+func (a *goBlog) doctorConnectivity() *doctorResult {
+	const name = "Outbound connectivity"
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	if err := requests.URL(doctorConnectivityURL).Client(a.httpClient).Head().Fetch(ctx); err != nil {
+		return doctorFail(name, err)
+	}
+	return doctorOk(name, "%s reachable", doctorConnectivityURL)
+}
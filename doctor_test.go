@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_doctor(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.ActivityPub.Enabled = true
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+
+	// Key isn't generated yet
+	assert.Equal(t, doctorWarning, app.doctorActivityPubKey("default").status)
+
+	require.NoError(t, app.initActivityPub())
+	app.d = app.buildRouter()
+
+	// Serve requests to the public address with the router
+	fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Hostname() == "localhost" {
+			app.d.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var out strings.Builder
+	failed := app.doctor(&out)
+	assert.Equal(t, 0, failed, out.String())
+	output := out.String()
+	assert.Contains(t, output, "[ OK ] Config: no issues found\n")
+	assert.Contains(t, output, "[WARN] Config: ActivityPub is enabled, but the public address http://localhost:8080 doesn't use HTTPS")
+	assert.Contains(t, output, "[ OK ] Database: schema up to date with ")
+	assert.Contains(t, output, "[ OK ] ActivityPub key default: valid RSA key with 2048 bits\n")
+	assert.Contains(t, output, "[ OK ] DNS localhost: resolves to ")
+	assert.Contains(t, output, "[ OK ] WebFinger acct:default@localhost: links to http://localhost:8080\n")
+	assert.Contains(t, output, "[ OK ] Outbound connectivity: ")
+	assert.NotContains(t, output, "TLS")
+	assert.True(t, strings.HasSuffix(output, " warnings, 0 failed\n"))
+
+	// Failing WebFinger and connectivity
+	fc.setFakeResponse(http.StatusNotFound, "")
+	out.Reset()
+	assert.Equal(t, 2, app.doctor(&out))
+	assert.Contains(t, out.String(), "[FAIL] WebFinger acct:default@localhost: ")
+	assert.Contains(t, out.String(), "[FAIL] Outbound connectivity: ")
+
+	// Invalid key
+	require.NoError(t, app.db.cachePersistently(apKeyCacheKey("default"), []byte("invalid")))
+	assert.Equal(t, doctorFailed, app.doctorActivityPubKey("default").status)
+}
+
+func Test_doctorCertResult(t *testing.T) {
+	now := time.Now()
+	r := doctorCertResult("TLS", &x509.Certificate{NotAfter: now.Add(60 * 24 * time.Hour)}, now)
+	assert.Equal(t, doctorOK, r.status)
+	r = doctorCertResult("TLS", &x509.Certificate{NotAfter: now.Add(5 * 24 * time.Hour)}, now)
+	assert.Equal(t, doctorWarning, r.status)
+	assert.Contains(t, r.message, "expires in 5 days")
+}
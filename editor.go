@@ -93,11 +93,16 @@ func (a *goBlog) serveEditorPost(w http.ResponseWriter, r *http.Request) {
 			a.serveError(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if !a.canEditPost(r, post) {
+			a.serveError(w, r, "No permission for this post", http.StatusForbidden)
+			return
+		}
 		a.render(w, r, a.renderEditor, &renderData{
 			Data: &editorRenderData{
 				presetParams:      parsePresetPostParamsFromQuery(r),
 				updatePostUrl:     a.fullPostURL(post),
 				updatePostContent: a.postToMfItem(post).Properties.Content[0],
+				updatePostETag:    post.etag(),
 			},
 		})
 	case "createpost", "updatepost":
@@ -110,7 +115,12 @@ func (a *goBlog) serveEditorPost(w http.ResponseWriter, r *http.Request) {
 			reqBody["type"] = []string{"h-entry"}
 			reqBody["properties"] = map[string][]string{"content": {r.FormValue("content")}}
 		}
-		req, _ := requests.URL("").BodyJSON(reqBody).Request(r.Context())
+		rb := requests.URL("").BodyJSON(reqBody)
+		if etag := r.FormValue("etag"); action == "updatepost" && etag != "" {
+			// Don't overwrite changes made since loading the post
+			rb.Header("If-Match", "\""+etag+"\"")
+		}
+		req, _ := rb.Request(r.Context())
 		a.editorMicropubPost(w, req, false)
 	case "upload":
 		a.editorMicropubPost(w, r, true)
@@ -128,11 +138,17 @@ func (a *goBlog) serveEditorPost(w http.ResponseWriter, r *http.Request) {
 			a.serveError(w, r, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if !a.canEditPost(r, post) {
+			a.serveError(w, r, "No permission for this post", http.StatusForbidden)
+			return
+		}
 		if err = a.createPostTTSAudio(post); err != nil {
 			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		http.Redirect(w, r, post.Path, http.StatusFound)
+	case "yearinreview":
+		a.serveEditorYearInReview(w, r)
 	case "helpgpx":
 		file, _, err := r.FormFile("file")
 		if err != nil {
@@ -174,7 +190,20 @@ func (a *goBlog) editorMicropubPost(w http.ResponseWriter, r *http.Request, medi
 	_ = result.Body.Close()
 }
 
-func (*goBlog) editorPostTemplate(blog string, bc *configBlog, presetParams map[string][]string) string {
+func (*goBlog) editorPostTemplate(blog string, bc *configBlog, pt *configPostTemplate, presetParams map[string][]string) string {
+	section := bc.DefaultSection
+	if pt != nil {
+		// Use the parameters of the post template, unless they are given in the query
+		params := map[string][]string{}
+		for key, param := range pt.Parameters {
+			params[key] = param
+		}
+		for key, param := range presetParams {
+			params[key] = param
+		}
+		presetParams = params
+		section = defaultIfEmpty(pt.Section, section)
+	}
 	builder := bufferpool.Get()
 	defer bufferpool.Put(builder)
 	marsh := func(param string, preset bool, i any) {
@@ -187,7 +216,7 @@ func (*goBlog) editorPostTemplate(blog string, bc *configBlog, presetParams map[
 	}
 	builder.WriteString("---\n")
 	marsh("blog", false, blog)
-	marsh("section", false, bc.DefaultSection)
+	marsh("section", false, section)
 	marsh("status", false, statusDraft)
 	marsh("visibility", false, visibilityPublic)
 	marsh("priority", false, 0)
@@ -200,6 +229,9 @@ func (*goBlog) editorPostTemplate(blog string, bc *configBlog, presetParams map[
 		marsh(key, true, param)
 	}
 	builder.WriteString("---\n")
+	if pt != nil {
+		builder.WriteString(pt.Content)
+	}
 	return builder.String()
 }
 
@@ -212,8 +244,10 @@ func (a *goBlog) editorPostDesc(bc *configBlog) string {
 		"updated",
 		"summary",
 		"translationkey",
+		postLangParameter,
 		"original",
 		a.cfg.Micropub.AudioParam,
+		enclosureParameter,
 		a.cfg.Micropub.BookmarkParam,
 		a.cfg.Micropub.LikeParam,
 		a.cfg.Micropub.LikeTitleParam,
@@ -225,6 +259,8 @@ func (a *goBlog) editorPostDesc(bc *configBlog) string {
 		a.cfg.Micropub.ReplyTitleParam,
 		a.cfg.Micropub.ReplyContextParam,
 		gpxParameter,
+		seriesParameter,
+		tocParameter,
 	} {
 		if param == "" {
 			continue
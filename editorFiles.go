@@ -2,43 +2,19 @@ package main
 
 import (
 	"net/http"
-	"sort"
-
-	"github.com/samber/lo"
 )
 
 func (a *goBlog) serveEditorFiles(w http.ResponseWriter, r *http.Request) {
-	// Get files
-	files, err := a.mediaFiles()
-	if err != nil {
-		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	// Check if files at all
-	if len(files) == 0 {
-		a.render(w, r, a.renderEditorFiles, &renderData{
-			Data: &editorFilesRenderData{},
-		})
-		return
-	}
-	// Sort files time desc
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Time.After(files[j].Time)
-	})
-	// Find uses
-	fileNames := lo.Map(files, func(f *mediaFile, _ int) string {
-		return f.Name
-	})
-	uses, err := a.db.usesOfMediaFile(fileNames...)
+	orphans := r.URL.Query().Get(mediaOrphansParam) == "true"
+	files, err := a.mediaLibrary(orphans)
 	if err != nil {
 		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// Serve HTML
 	a.render(w, r, a.renderEditorFiles, &renderData{
 		Data: &editorFilesRenderData{
-			files: files,
-			uses:  uses,
+			files:   files,
+			orphans: orphans,
 		},
 	})
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+type smtpEmail struct {
+	host, user, password string
+	port                 int
+	from, to, replyTo    string
+	subject, body        string
+	html                 string            // optional HTML version of the body
+	headers              map[string]string // additional headers
+}
+
+func (e *smtpEmail) send() error {
+	// Check required config
+	if e.host == "" || e.from == "" || e.to == "" {
+		return errors.New("email not send as config is missing")
+	}
+	// Connect to SMTP
+	smtpServer := mail.NewSMTPClient()
+	smtpServer.Host = e.host
+	smtpServer.Port = e.port
+	if smtpServer.Port == 0 {
+		smtpServer.Port = 587
+	}
+	smtpServer.Username = e.user
+	smtpServer.Password = e.password
+	smtpServer.KeepAlive = false
+	smtpClient, err := smtpServer.Connect()
+	if err != nil {
+		return err
+	}
+	// Build email
+	msg := mail.NewMSG()
+	msg.AddTo(e.to)
+	msg.SetFrom(e.from)
+	if e.replyTo != "" {
+		msg.SetReplyTo(e.replyTo)
+	}
+	msg.SetDate(time.Now().UTC().Format("2006-01-02 15:04:05 MST"))
+	msg.SetSubject(e.subject)
+	msg.SetBody(mail.TextPlain, e.body)
+	if e.html != "" {
+		msg.AddAlternative(mail.TextHTML, e.html)
+	}
+	for k, v := range e.headers {
+		msg.AddHeader(k, v)
+	}
+	// Send mail
+	return msg.Send(smtpClient)
+}
+
+func (em *configNotificationEmail) enabled() bool {
+	return em != nil && em.Enabled && em.SMTPHost != "" && em.EmailFrom != "" && em.EmailTo != ""
+}
+
+type emailNotifier struct {
+	cfg *configNotificationEmail
+}
+
+func (n *emailNotifier) acceptsNotification(typ notificationType) bool {
+	return notificationEventsAllowed(n.cfg.Events, typ)
+}
+
+func (n *emailNotifier) sendNotification(text string) error {
+	return (&smtpEmail{
+		host:     n.cfg.SMTPHost,
+		port:     n.cfg.SMTPPort,
+		user:     n.cfg.SMTPUser,
+		password: n.cfg.SMTPPassword,
+		from:     n.cfg.EmailFrom,
+		to:       n.cfg.EmailTo,
+		subject:  defaultIfEmpty(n.cfg.EmailSubject, "New notification"),
+		body:     text,
+	}).send()
+}
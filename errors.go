@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	ct "github.com/elnormous/contenttype"
 	"go.goblog.app/app/pkgs/contenttype"
@@ -13,6 +15,11 @@ func (a *goBlog) serve404(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *goBlog) serve410(w http.ResponseWriter, r *http.Request) {
+	if asRequest, ok := r.Context().Value(asRequestKey).(bool); ok && asRequest {
+		// ActivityPub servers get a Tombstone, so they can remove their copy of the post
+		a.serveActivityStreamsTombstone(w, r)
+		return
+	}
 	a.serveError(w, r, fmt.Sprintf("%s doesn't exist anymore", r.URL.RequestURI()), http.StatusGone)
 }
 
@@ -21,24 +28,56 @@ func (a *goBlog) serveNotAllowed(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *goBlog) serveError(w http.ResponseWriter, r *http.Request, message string, status int) {
-	// Init the first time
+	// Init the first time, the first media type is used if the request doesn't have an Accept header
 	if len(a.errorCheckMediaTypes) == 0 {
-		a.errorCheckMediaTypes = append(a.errorCheckMediaTypes, ct.NewMediaType(contenttype.HTML))
+		a.errorCheckMediaTypes = []ct.MediaType{
+			ct.NewMediaType(contenttype.HTML),
+			ct.NewMediaType(contenttype.JSON),
+			ct.NewMediaType(contenttype.AS),
+			ct.NewMediaType(contenttype.LDJSON),
+			ct.NewMediaType(contenttype.Text),
+		}
 	}
 	// Check message
 	if message == "" {
 		message = http.StatusText(status)
 	}
-	// Check if request accepts HTML
-	if mt, _, err := ct.GetAcceptableMediaType(r, a.errorCheckMediaTypes); err != nil || mt.String() != a.errorCheckMediaTypes[0].String() {
-		// Request doesn't accept HTML
+	mt, _, err := ct.GetAcceptableMediaType(r, a.errorCheckMediaTypes)
+	if err != nil {
+		// Request doesn't accept any of the types
 		http.Error(w, message, status)
 		return
 	}
-	a.renderWithStatusCode(w, r, status, a.renderError, &renderData{
-		Data: &errorRenderData{
-			Title:   fmt.Sprintf("%d %s", status, http.StatusText(status)),
-			Message: message,
-		},
-	})
+	switch mt.String() {
+	case contenttype.HTML:
+		a.renderWithStatusCode(w, r, status, a.renderError, &renderData{
+			Data: &errorRenderData{
+				Title:   fmt.Sprintf("%d %s", status, http.StatusText(status)),
+				Message: message,
+				Status:  status,
+			},
+		})
+	case contenttype.JSON, contenttype.AS, contenttype.LDJSON:
+		// API, Micropub and ActivityPub clients
+		w.Header().Set(contentType, contenttype.JSONUTF8)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error":             errorCode(status),
+			"error_description": message,
+		})
+	default:
+		http.Error(w, message, status)
+	}
+}
+
+// Error code for JSON errors, like in the Micropub and OAuth specs
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusInternalServerError:
+		return "server_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
 }
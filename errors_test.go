@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.goblog.app/app/pkgs/contenttype"
 )
 
@@ -17,6 +19,7 @@ func Test_errors(t *testing.T) {
 
 	_ = app.initConfig(false)
 	app.initMarkdown()
+	_ = app.initTemplateStrings()
 	app.initSessions()
 
 	t.Run("Test 404, no HTML", func(t *testing.T) {
@@ -35,8 +38,8 @@ func Test_errors(t *testing.T) {
 		resString := string(resBody)
 
 		assert.Equal(t, http.StatusNotFound, res.StatusCode)
-		assert.Contains(t, resString, "not found")
-		assert.Contains(t, res.Header.Get("Content-Type"), "text/plain")
+		assert.JSONEq(t, `{"error":"not_found","error_description":"/abc was not found"}`, resString)
+		assert.Contains(t, res.Header.Get("Content-Type"), contenttype.JSON)
 	})
 
 	t.Run("Test 404, HTML", func(t *testing.T) {
@@ -56,6 +59,7 @@ func Test_errors(t *testing.T) {
 
 		assert.Equal(t, http.StatusNotFound, res.StatusCode)
 		assert.Contains(t, resString, "not found")
+		assert.Contains(t, resString, "Go to the home page")
 		assert.Contains(t, res.Header.Get("Content-Type"), contenttype.HTML)
 	})
 
@@ -63,7 +67,7 @@ func Test_errors(t *testing.T) {
 		h := http.HandlerFunc(app.serveNotAllowed)
 
 		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
-		req.Header.Set("Accept", contenttype.JSON)
+		req.Header.Set("Accept", contenttype.Text)
 
 		rec := httptest.NewRecorder()
 
@@ -99,3 +103,31 @@ func Test_errors(t *testing.T) {
 		assert.Contains(t, res.Header.Get("Content-Type"), contenttype.HTML)
 	})
 }
+
+func Test_gone(t *testing.T) {
+	h := newTestHarness(t, withActivityPub)
+	p := h.createPost(&post{Path: "/deleted", Content: "Deleted"})
+	h.createPost(&post{Path: "/removed", Content: "Removed"})
+	require.NoError(t, h.app.deletePost(p.Path))
+	require.NoError(t, h.app.deletePost("/removed"))
+	require.NoError(t, h.app.deletePost("/removed"))
+
+	for _, path := range []string{"/deleted", "/removed"} {
+		status, _, body := h.get(path)
+		assert.Equal(t, http.StatusGone, status)
+		assert.Contains(t, body, "This page was deleted")
+
+		var tombstone map[string]any
+		err := h.request(path).Accept(contenttype.AS).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			ToJSON(&tombstone).Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusGone, status)
+		assert.Equal(t, "Tombstone", tombstone["type"])
+		assert.Equal(t, "https://example.com"+path, tombstone["id"])
+		assert.Equal(t, "Note", tombstone["formerType"])
+	}
+}
@@ -1,8 +1,20 @@
 package main
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"time"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
 )
 
 func (a *goBlog) exportMarkdownFiles(dir string) error {
@@ -25,3 +37,170 @@ func (a *goBlog) exportMarkdownFiles(dir string) error {
 	}
 	return nil
 }
+
+// Hugo export: a Hugo site with all (not deleted) posts as Markdown files with YAML front matter
+// in the content directory and the media files in the static directory, so the URLs stay the same.
+
+const exportPath = "/api/export"
+
+// Destination of an export, like a directory or an archive
+type exportTarget interface {
+	writeFile(name string, data []byte) error
+	copyFile(name, src string) error
+}
+
+type dirExportTarget string
+
+func (d dirExportTarget) writeFile(name string, data []byte) error {
+	filename := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	//nolint:gosec
+	return os.WriteFile(filename, data, 0666)
+}
+
+func (d dirExportTarget) copyFile(name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return saveToFile(f, filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+type tarExportTarget struct {
+	tw *tar.Writer
+}
+
+func (t *tarExportTarget) writeFile(name string, data []byte) error {
+	if err := t.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := t.tw.Write(data)
+	return err
+}
+
+func (t *tarExportTarget) copyFile(name, src string) error {
+	return addFileToTar(t.tw, src, name)
+}
+
+func (a *goBlog) exportHugo(target exportTarget) error {
+	// Site config
+	bc := a.cfg.Blogs[a.cfg.DefaultBlog]
+	taxonomies := map[string]string{}
+	for _, b := range a.cfg.Blogs {
+		for _, t := range b.Taxonomies {
+			taxonomies[t.Name] = t.Name
+		}
+	}
+	siteConfig, err := yaml.Marshal(map[string]any{
+		"baseURL":      a.getInstanceRootURL(),
+		"title":        bc.Title,
+		"languageCode": bc.Lang,
+		"taxonomies":   taxonomies,
+	})
+	if err != nil {
+		return err
+	}
+	if err = target.writeFile("hugo.yaml", siteConfig); err != nil {
+		return err
+	}
+	// Posts
+	posts, err := a.getPosts(&postsRequestConfig{
+		status:               []postStatus{statusPublished, statusDraft, statusScheduled},
+		withoutRenderedTitle: true,
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		name := path.Join("content", lo.If(p.Path == "/", "_index").Else(p.Path)+".md")
+		if err = target.writeFile(name, a.hugoPost(p)); err != nil {
+			return err
+		}
+	}
+	// Media
+	return filepath.WalkDir(mediaFilePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(mediaFilePath, p)
+		if err != nil {
+			return err
+		}
+		return target.copyFile(path.Join("static", "m", filepath.ToSlash(rel)), p)
+	})
+}
+
+// Markdown file with Hugo front matter
+func (a *goBlog) hugoPost(p *post) []byte {
+	taxonomies := lo.FlatMap(lo.Values(a.cfg.Blogs), func(b *configBlog, _ int) []string {
+		return lo.Map(b.Taxonomies, func(t *configTaxonomy, _ int) string { return t.Name })
+	})
+	fm := map[string]any{}
+	for k, v := range p.Parameters {
+		if len(v) == 0 {
+			continue
+		}
+		if len(v) == 1 && !lo.Contains(taxonomies, k) {
+			fm[k] = v[0]
+		} else {
+			fm[k] = v
+		}
+	}
+	// Keep the URL of the post
+	fm["url"] = p.Path
+	if p.Section != "" {
+		fm["section"] = p.Section
+	}
+	if p.Published != "" {
+		fm["date"] = p.Published
+	}
+	if p.Updated != "" {
+		fm["lastmod"] = p.Updated
+	}
+	if p.Status == statusDraft {
+		fm["draft"] = true
+	}
+	switch p.Visibility {
+	case visibilityUnlisted:
+		fm["_build"] = map[string]string{"list": "never"}
+	case visibilityPrivate:
+		fm["_build"] = map[string]string{"list": "never", "render": "never"}
+	}
+	fmb, _ := yaml.Marshal(fm)
+	return []byte(fmt.Sprintf("---\n%s---\n%s", string(fmb), p.Content))
+}
+
+func (a *goBlog) exportHugoToDir(dir string) error {
+	return a.exportHugo(dirExportTarget(defaultIfEmpty(dir, "export")))
+}
+
+// Authenticated endpoint to download the Hugo export as archive
+func (a *goBlog) serveExport(w http.ResponseWriter, r *http.Request) {
+	a.serveArchiveDownload(w, r, fmt.Sprintf("goblog-hugo-export-%s.tar.gz", time.Now().UTC().Format(backupFileTimeFormat)), a.writeHugoExport)
+}
+
+func (a *goBlog) writeHugoExport(w io.Writer) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	if err := a.exportHugo(&tarExportTarget{tw: tw}); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,3 +53,85 @@ func Test_export(t *testing.T) {
 	assert.Contains(t, fileContent, `ABC`)
 
 }
+
+func Test_exportHugo(t *testing.T) {
+	// Media files are read from the working directory
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	for name, content := range map[string]string{"a.txt": "Top", "sub/a.txt": "Nested"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(mediaFilePath, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(mediaFilePath, name), []byte(content), 0644))
+	}
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+
+	for _, p := range []*post{
+		{
+			Path: "/posts/public", Section: "posts", Content: "Public content", Status: statusPublished, Visibility: visibilityPublic,
+			Published: "2020-01-01T10:00:00Z", Updated: "2020-01-02T10:00:00Z",
+			Parameters: map[string][]string{"title": {"Public"}, "tags": {"One"}},
+		},
+		{Path: "/posts/draft", Section: "posts", Content: "Draft content", Status: statusDraft},
+		{Path: "/posts/private", Section: "posts", Content: "Private content", Status: statusPublished, Visibility: visibilityPrivate},
+		{Path: "/posts/deleted", Section: "posts", Content: "Deleted content", Status: statusPublishedDeleted},
+	} {
+		p.Blog = app.cfg.DefaultBlog
+		require.NoError(t, app.db.savePost(p, &postCreationOptions{new: true}))
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, app.exportHugoToDir(dir))
+
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	config := read("hugo.yaml")
+	assert.Contains(t, config, "baseURL: http://localhost:8080/")
+	assert.Contains(t, config, "tags: tags")
+
+	public := read("content/posts/public.md")
+	assert.Contains(t, public, "url: /posts/public")
+	assert.Contains(t, public, "title: Public")
+	assert.Contains(t, public, "date: \"2020-01-01T10:00:00Z\"")
+	assert.Contains(t, public, "lastmod: \"2020-01-02T10:00:00Z\"")
+	assert.Contains(t, public, "tags:\n    - One")
+	assert.NotContains(t, public, "draft")
+	assert.Contains(t, public, "---\nPublic content")
+
+	assert.Contains(t, read("content/posts/draft.md"), "draft: true")
+	assert.Contains(t, read("content/posts/private.md"), "render: never")
+	assert.NoFileExists(t, filepath.Join(dir, "content/posts/deleted.md"))
+
+	// Media files keep their subdirectories
+	assert.Equal(t, "Top", read("static/m/a.txt"))
+	assert.Equal(t, "Nested", read("static/m/sub/a.txt"))
+
+	// Archive download
+	rec := httptest.NewRecorder()
+	app.serveExport(rec, httptest.NewRequest(http.MethodGet, exportPath, nil))
+	res := rec.Result()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, strconv.Itoa(rec.Body.Len()), res.Header.Get("Content-Length"))
+	gzr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	tr := tar.NewReader(gzr)
+	var names []string
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, h.Name)
+	}
+	assert.ElementsMatch(t, []string{"hugo.yaml", "content/posts/public.md", "content/posts/draft.md", "content/posts/private.md", "static/m/a.txt", "static/m/sub/a.txt"}, names)
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/go-chi/chi/v5"
+	"github.com/mmcdole/gofeed"
+	"github.com/samber/lo"
+	"github.com/vcraescu/go-paginator/v2"
+)
+
+// Feed reader: the configured feeds are polled regularly and their new items are listed for logged-in users,
+// who can turn an item into a draft bookmark or reply post with one click.
+
+const feedReaderPath = "/feedreader"
+
+const (
+	defaultFeedReaderInterval = 60 // minutes
+	feedReaderItemRetention   = 90 * 24 * time.Hour
+)
+
+type feedReaderItem struct {
+	Feed, ID, Title, Link, Summary, Published string
+}
+
+func (a *goBlog) feedReaderEnabled() bool {
+	fr := a.cfg.FeedReader
+	return fr != nil && fr.Enabled && len(fr.Feeds) > 0
+}
+
+func (a *goBlog) initFeedReader() {
+	if !a.feedReaderEnabled() {
+		return
+	}
+	fr := a.cfg.FeedReader
+	ticker := time.NewTicker(time.Duration(lo.If(fr.Interval > 0, fr.Interval).Else(defaultFeedReaderInterval)) * time.Minute)
+	done := make(chan struct{})
+	go func() {
+		a.fetchFeedReaderFeeds()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.fetchFeedReaderFeeds()
+			}
+		}
+	}()
+	a.addShutdownBeforeDatabase(func() {
+		ticker.Stop()
+		close(done)
+		log.Println("Feed reader stopped")
+	})
+}
+
+func (a *goBlog) fetchFeedReaderFeeds() {
+	for _, feedURL := range a.cfg.FeedReader.Feeds {
+		if err := a.fetchFeedReaderFeed(feedURL); err != nil {
+			log.Println("Feed reader: failed to fetch", feedURL+":", err.Error())
+		}
+	}
+	// Remove old items
+	if _, err := a.db.Exec(
+		"delete from feedreader where fetched < @fetched",
+		sql.Named("fetched", time.Now().Add(-feedReaderItemRetention).UTC().Format(time.RFC3339)),
+	); err != nil {
+		log.Println("Feed reader: failed to delete old items:", err.Error())
+	}
+}
+
+func (a *goBlog) fetchFeedReaderFeed(feedURL string) error {
+	var feed *gofeed.Feed
+	err := requests.URL(feedURL).Client(a.httpClient).
+		Handle(func(r *http.Response) (err error) {
+			feed, err = gofeed.NewParser().Parse(r.Body)
+			return err
+		}).
+		Fetch(context.Background())
+	if err != nil {
+		return err
+	}
+	fetched := time.Now().UTC().Format(time.RFC3339)
+	for _, item := range feed.Items {
+		fri := &feedReaderItem{
+			Feed:    feedURL,
+			ID:      defaultIfEmpty(item.GUID, item.Link),
+			Title:   cleanHTMLText(item.Title),
+			Link:    item.Link,
+			Summary: truncateStringWithEllipsis(cleanHTMLText(defaultIfEmpty(item.Description, item.Content)), 300),
+		}
+		if fri.ID == "" || !isHTTPURL(fri.Link) {
+			continue
+		}
+		if item.PublishedParsed != nil {
+			fri.Published = item.PublishedParsed.UTC().Format(time.RFC3339)
+		}
+		// Existing items (also dismissed ones) are kept, only the fetch time is updated, so they aren't removed while still in the feed
+		if _, err = a.db.Exec(
+			"insert into feedreader (feed, id, title, link, summary, published, fetched) values (@feed, @id, @title, @link, @summary, @published, @fetched) on conflict (feed, id) do update set fetched = excluded.fetched",
+			sql.Named("feed", fri.Feed), sql.Named("id", fri.ID), sql.Named("title", fri.Title), sql.Named("link", fri.Link),
+			sql.Named("summary", fri.Summary), sql.Named("published", defaultIfEmpty(fri.Published, fetched)), sql.Named("fetched", fetched),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *database) getFeedReaderItem(feed, id string) (*feedReaderItem, error) {
+	row, err := db.QueryRow(
+		"select feed, id, title, link, summary, published from feedreader where feed = @feed and id = @id and dismissed = 0",
+		sql.Named("feed", feed), sql.Named("id", id),
+	)
+	if err != nil {
+		return nil, err
+	}
+	item := &feedReaderItem{}
+	if err = row.Scan(&item.Feed, &item.ID, &item.Title, &item.Link, &item.Summary, &item.Published); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("feed item not found")
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func (db *database) getFeedReaderItems(offset, limit int) ([]*feedReaderItem, error) {
+	rows, err := db.Query(
+		"select feed, id, title, link, summary, published from feedreader where dismissed = 0 order by published desc limit @limit offset @offset",
+		sql.Named("limit", limit), sql.Named("offset", offset),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*feedReaderItem{}
+	for rows.Next() {
+		item := &feedReaderItem{}
+		if err = rows.Scan(&item.Feed, &item.ID, &item.Title, &item.Link, &item.Summary, &item.Published); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (db *database) countFeedReaderItems() (count int, err error) {
+	row, err := db.QueryRow("select count(*) from feedreader where dismissed = 0")
+	if err != nil {
+		return
+	}
+	err = row.Scan(&count)
+	return
+}
+
+func (db *database) dismissFeedReaderItem(feed, id string) error {
+	_, err := db.Exec("update feedreader set dismissed = 1 where feed = @feed and id = @id", sql.Named("feed", feed), sql.Named("id", id))
+	return err
+}
+
+type feedReaderPaginationAdapter struct {
+	nums int64
+	db   *database
+}
+
+func (p *feedReaderPaginationAdapter) Nums() (int64, error) {
+	if p.nums == 0 {
+		p.nums = int64(noError(p.db.countFeedReaderItems()))
+	}
+	return p.nums, nil
+}
+
+func (p *feedReaderPaginationAdapter) Slice(offset, length int, data any) error {
+	items, err := p.db.getFeedReaderItems(offset, length)
+	reflect.ValueOf(data).Elem().Set(reflect.ValueOf(&items).Elem())
+	return err
+}
+
+func (a *goBlog) serveFeedReader(w http.ResponseWriter, r *http.Request) {
+	p := paginator.New(&feedReaderPaginationAdapter{db: a.db}, 20)
+	p.SetPage(stringToInt(chi.URLParam(r, "page")))
+	var items []*feedReaderItem
+	if err := p.Results(&items); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Navigation
+	var hasPrev, hasNext bool
+	var prevPage, currentPage, nextPage int
+	var prevPath, nextPath string
+	hasPrev, _ = p.HasPrev()
+	if hasPrev {
+		prevPage, _ = p.PrevPage()
+	} else {
+		prevPage, _ = p.Page()
+	}
+	if prevPage < 2 {
+		prevPath = feedReaderPath
+	} else {
+		prevPath = fmt.Sprintf("%s/page/%d", feedReaderPath, prevPage)
+	}
+	currentPage, _ = p.Page()
+	hasNext, _ = p.HasNext()
+	if hasNext {
+		nextPage, _ = p.NextPage()
+	} else {
+		nextPage, _ = p.Page()
+	}
+	nextPath = fmt.Sprintf("%s/page/%d", feedReaderPath, nextPage)
+	// Render
+	a.render(w, r, a.renderFeedReader, &renderData{
+		Data: &feedReaderRenderData{
+			items:   items,
+			hasPrev: hasPrev,
+			hasNext: hasNext,
+			prev:    prevPath,
+			current: fmt.Sprintf("%s/page/%d", feedReaderPath, currentPage),
+			next:    nextPath,
+		},
+	})
+}
+
+func (a *goBlog) feedReaderAction(w http.ResponseWriter, r *http.Request) {
+	feed, id := r.FormValue("feed"), r.FormValue("id")
+	action := chi.URLParam(r, "action")
+	if action == "dismiss" {
+		if err := a.db.dismissFeedReaderItem(feed, id); err != nil {
+			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, defaultIfEmpty(r.FormValue("redir"), feedReaderPath), http.StatusFound)
+		return
+	}
+	item, err := a.db.getFeedReaderItem(feed, id)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, err := a.feedReaderDraft(item, action)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = a.createPost(p); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The item is handled now
+	_ = a.db.dismissFeedReaderItem(feed, id)
+	http.Redirect(w, r, p.Path, http.StatusFound)
+}
+
+// Draft post to bookmark or reply to the feed item
+func (a *goBlog) feedReaderDraft(item *feedReaderItem, action string) (*post, error) {
+	mp := a.cfg.Micropub
+	p := &post{
+		Blog:       a.cfg.FeedReader.Blog,
+		Status:     statusDraft,
+		Parameters: map[string][]string{},
+	}
+	switch action {
+	case "bookmark":
+		p.Parameters[mp.BookmarkParam] = []string{item.Link}
+		if item.Title != "" {
+			p.Parameters["title"] = []string{item.Title}
+		}
+		if item.Summary != "" {
+			p.Content = "> " + strings.ReplaceAll(item.Summary, "\n", "\n> ")
+		}
+	case "reply":
+		p.Parameters[mp.ReplyParam] = []string{item.Link}
+		if item.Title != "" {
+			p.Parameters[mp.ReplyTitleParam] = []string{item.Title}
+		}
+	default:
+		return nil, errors.New("invalid action")
+	}
+	return p, nil
+}
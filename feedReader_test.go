@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_feedReader(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		httpClient: fc.Client,
+		cfg:        createDefaultTestConfig(t),
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+	app.cfg.FeedReader = &configFeedReader{
+		Enabled: true,
+		Feeds:   []string{"https://example.com/feed.xml"},
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	fc.setFakeResponse(http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Example</title><link>https://example.com/</link>
+<item><title>First item</title><link>https://example.com/first</link><guid>first</guid><description>First &lt;b&gt;summary&lt;/b&gt;</description><pubDate>Mon, 02 Jan 2023 10:00:00 +0000</pubDate></item>
+<item><title>Second item</title><link>https://example.com/second</link><guid>second</guid><pubDate>Tue, 03 Jan 2023 10:00:00 +0000</pubDate></item>
+<item><title>Script item</title><link>javascript:alert(1)</link><guid>script</guid></item>
+</channel></rss>`)
+
+	// Fetching again doesn't add duplicates
+	app.fetchFeedReaderFeeds()
+	app.fetchFeedReaderFeeds()
+	count, err := app.db.countFeedReaderItems()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	getList := func() string {
+		var body string
+		err := requests.URL("http://localhost:8080"+feedReaderPath).Client(client).
+			BasicAuth("test", "test").
+			CheckStatus(http.StatusOK).
+			ToString(&body).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		return body
+	}
+
+	doAction := func(action, id string) (location string) {
+		err := requests.URL("http://localhost:8080"+feedReaderPath+"/"+action).Client(client).
+			BasicAuth("test", "test").
+			BodyForm(url.Values{"feed": {"https://example.com/feed.xml"}, "id": {id}}).
+			CheckStatus(http.StatusFound).
+			AddValidator(func(r *http.Response) error {
+				location = r.Header.Get("Location")
+				return nil
+			}).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		return
+	}
+
+	body := getList()
+	assert.Contains(t, body, "First item")
+	assert.Contains(t, body, "First summary")
+	assert.Contains(t, body, "Second item")
+	// Only http(s) links
+	assert.NotContains(t, body, "Script item")
+	assert.NotContains(t, body, "javascript:")
+
+	t.Run("Bookmark", func(t *testing.T) {
+		p, err := app.getPost(doAction("bookmark", "first"))
+		require.NoError(t, err)
+		assert.Equal(t, statusDraft, p.Status)
+		assert.Equal(t, "https://example.com/first", p.firstParameter(app.cfg.Micropub.BookmarkParam))
+		assert.Equal(t, "First item", p.Title())
+		assert.Equal(t, "> First summary", p.Content)
+
+		assert.NotContains(t, getList(), "First item")
+	})
+
+	t.Run("Reply", func(t *testing.T) {
+		p, err := app.getPost(doAction("reply", "second"))
+		require.NoError(t, err)
+		assert.Equal(t, statusDraft, p.Status)
+		assert.Equal(t, "https://example.com/second", p.firstParameter(app.cfg.Micropub.ReplyParam))
+		assert.Equal(t, "Second item", p.firstParameter(app.cfg.Micropub.ReplyTitleParam))
+	})
+
+	t.Run("Dismiss", func(t *testing.T) {
+		fc.setFakeResponse(http.StatusOK, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Example</title>
+<item><title>Third item</title><link>https://example.com/third</link></item>
+</channel></rss>`)
+		app.fetchFeedReaderFeeds()
+		assert.Contains(t, getList(), "Third item")
+
+		assert.Equal(t, feedReaderPath, doAction("dismiss", "https://example.com/third"))
+		assert.NotContains(t, getList(), "Third item")
+
+		// Dismissed items stay dismissed, also when they are still in the feed after the retention time
+		_, err := app.db.Exec("update feedreader set fetched = '2000-01-01T00:00:00Z'")
+		require.NoError(t, err)
+		app.fetchFeedReaderFeeds()
+		assert.NotContains(t, getList(), "Third item")
+		row, err := app.db.QueryRow("select count(*) from feedreader where id = 'https://example.com/third' and dismissed = 1")
+		require.NoError(t, err)
+		var dismissed int
+		require.NoError(t, row.Scan(&dismissed))
+		assert.Equal(t, 1, dismissed)
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -24,15 +25,54 @@ const (
 	minJsonFeed feedType = "min.json"
 )
 
+// Query parameter for feed readers to only get the posts published or updated since their last poll
+const feedUpdatedMinParam = "updated-min"
+
+func feedUpdatedMin(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get(feedUpdatedMinParam)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := dateparse.ParseLocal(value)
+	if err != nil {
+		return time.Time{}, errors.New("invalid " + feedUpdatedMinParam + " parameter")
+	}
+	return t, nil
+}
+
+// Number of posts in the feeds of the blog
+func (a *goBlog) feedItems(bc *configBlog) int {
+	if a.cfg.Feeds != nil && a.cfg.Feeds.Items > 0 {
+		return a.cfg.Feeds.Items
+	}
+	return bc.Pagination
+}
+
+// Time of the newest change in the posts, so unchanged feeds keep the same content and ETag
+func feedLastModified(posts []*post) time.Time {
+	var lastModified time.Time
+	for _, p := range posts {
+		for _, date := range []string{p.Published, p.Updated} {
+			if t, err := dateparse.ParseLocal(date); err == nil && t.After(lastModified) {
+				lastModified = t
+			}
+		}
+	}
+	return lastModified
+}
+
 func (a *goBlog) generateFeed(blog string, f feedType, w http.ResponseWriter, r *http.Request, posts []*post, title, description string) {
-	now := time.Now()
+	lastModified := feedLastModified(posts)
+	if lastModified.IsZero() {
+		lastModified = time.Now()
+	}
 	title = a.renderMdTitle(defaultIfEmpty(title, a.cfg.Blogs[blog].Title))
 	description = defaultIfEmpty(description, a.cfg.Blogs[blog].Description)
 	feed := &feeds.Feed{
 		Title:       title,
 		Description: description,
-		Link:        &feeds.Link{Href: a.getFullAddress(strings.TrimSuffix(r.URL.Path, "."+string(f)))},
-		Created:     now,
+		Link:        &feeds.Link{Href: a.getCanonicalAddress(strings.TrimSuffix(r.URL.Path, "."+string(f)))},
+		Created:     lastModified,
 		Author: &feeds.Author{
 			Name:  a.cfg.User.Name,
 			Email: a.cfg.User.Email,
@@ -57,6 +97,8 @@ func (a *goBlog) generateFeed(blog string, f feedType, w http.ResponseWriter, r
 			Content:     buf.String(),
 			Created:     noError(dateparse.ParseLocal(p.Published)),
 			Updated:     noError(dateparse.ParseLocal(p.Updated)),
+			Enclosure:   a.postEnclosure(p),
+			Author:      a.feedItemAuthor(p),
 		})
 		bufferpool.Put(buf)
 	}
@@ -66,6 +108,11 @@ func (a *goBlog) generateFeed(blog string, f feedType, w http.ResponseWriter, r
 	case rssFeed, minRssFeed:
 		feedMediaType = contenttype.RSS
 		feedWriteFunc = feed.WriteRss
+		if bc := a.cfg.Blogs[blog]; bc.Podcast != nil && bc.Podcast.Enabled {
+			feedWriteFunc = func(w io.Writer) error {
+				return a.writePodcastRss(w, feed, bc)
+			}
+		}
 	case atomFeed, minAtomFeed:
 		feedMediaType = contenttype.ATOM
 		feedWriteFunc = feed.WriteAtom
@@ -81,5 +128,14 @@ func (a *goBlog) generateFeed(blog string, f feedType, w http.ResponseWriter, r
 		_ = pipeWriter.CloseWithError(feedWriteFunc(pipeWriter))
 	}()
 	w.Header().Set(contentType, feedMediaType+contenttype.CharsetUtf8Suffix)
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
 	_ = pipeReader.CloseWithError(a.min.Get().Minify(feedMediaType, w, pipeReader))
 }
+
+// Author of the feed item, only set if the post is attributed to another author than the user
+func (a *goBlog) feedItemAuthor(p *post) *feeds.Author {
+	if author := a.postAuthor(p); author != nil {
+		return &feeds.Author{Name: author.Name}
+	}
+	return nil
+}
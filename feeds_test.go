@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/carlmjohnson/requests"
 	"github.com/mmcdole/gofeed"
@@ -56,3 +60,217 @@ func Test_feeds(t *testing.T) {
 		}
 	}
 }
+
+func Test_feedsUpdatedMin(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	_ = app.initConfig(false)
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+
+	app.d = app.buildRouter()
+	handlerClient := newHandlerClient(app.d)
+
+	for _, p := range []*post{
+		{Path: "/old", Published: "2020-01-01T00:00:00Z"},
+		{Path: "/oldupdated", Published: "2020-01-01T00:00:00Z", Updated: "2023-02-01T00:00:00Z"},
+		{Path: "/new", Published: "2023-03-01T00:00:00Z"},
+	} {
+		p.Section = "posts"
+		p.Status = statusPublished
+		p.Content = "Content"
+		require.NoError(t, app.createPost(p))
+	}
+
+	getFeedItems := func(query string) []string {
+		var feed *gofeed.Feed
+		err := requests.URL("http://localhost:8080/posts.atom" + query).Client(handlerClient).
+			CheckStatus(http.StatusOK).
+			Handle(func(r *http.Response) (err error) {
+				defer r.Body.Close()
+				feed, err = gofeed.NewParser().Parse(r.Body)
+				return
+			}).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		var ids []string
+		for _, item := range feed.Items {
+			ids = append(ids, item.GUID)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []string{"/new", "/oldupdated", "/old"}, getFeedItems(""))
+	assert.Equal(t, []string{"/new", "/oldupdated"}, getFeedItems("?updated-min=2023-01-01T00:00:00Z"))
+	assert.Equal(t, []string{"/new"}, getFeedItems("?updated-min=2023-02-15"))
+	assert.Empty(t, getFeedItems("?updated-min=2024-01-01T00:00:00Z"))
+
+	// Invalid parameter
+	err := requests.URL("http://localhost:8080/posts.atom?updated-min=invalid").Client(handlerClient).
+		CheckStatus(http.StatusBadRequest).
+		Fetch(context.Background())
+	assert.NoError(t, err)
+}
+
+func Test_podcastFeeds(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	_ = app.initConfig(false)
+	app.cfg.User.Name = "Podcaster"
+	app.cfg.Blogs[app.cfg.DefaultBlog].Podcast = &configPodcast{
+		Enabled:     true,
+		Title:       "Test Podcast",
+		Category:    "Technology",
+		Subcategory: "Podcasting",
+	}
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+
+	app.d = app.buildRouter()
+	handlerClient := newHandlerClient(app.d)
+
+	err := app.createPost(&post{
+		Path:      "/episode",
+		Section:   "posts",
+		Status:    "published",
+		Published: "2020-01-01T00:00:00Z",
+		Parameters: map[string][]string{
+			"title":                  {"Episode 1"},
+			enclosureParameter:       {"https://example.com/episode.mp3"},
+			enclosureLengthParameter: {"1234"},
+		},
+		Content: "Test Content",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "audio/mpeg", app.postEnclosure(&post{Parameters: map[string][]string{"audio": {"https://example.com/audio.mp3?v=1"}}}).Type)
+	assert.Nil(t, app.postEnclosure(&post{}))
+
+	getFeed := func(typ feedType) *gofeed.Feed {
+		var feed *gofeed.Feed
+		err := requests.URL("http://localhost:8080/posts." + string(typ)).Client(handlerClient).
+			Handle(func(r *http.Response) (err error) {
+				defer r.Body.Close()
+				feed, err = gofeed.NewParser().Parse(r.Body)
+				return
+			}).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, feed)
+		return feed
+	}
+
+	for _, typ := range []feedType{rssFeed, atomFeed, jsonFeed} {
+		feed := getFeed(typ)
+		if assert.Len(t, feed.Items, 1) && assert.Len(t, feed.Items[0].Enclosures, 1, typ) {
+			enclosure := feed.Items[0].Enclosures[0]
+			assert.Equal(t, "https://example.com/episode.mp3", enclosure.URL)
+			assert.Equal(t, "audio/mpeg", enclosure.Type)
+			if typ != jsonFeed {
+				// gofeed doesn't translate the size of JSON Feed attachments
+				assert.Equal(t, "1234", enclosure.Length, typ)
+			}
+		}
+	}
+
+	var jsonBody string
+	err = requests.URL("http://localhost:8080/posts.json").Client(handlerClient).ToString(&jsonBody).Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, jsonBody, `"size_in_bytes":1234`)
+
+	feed := getFeed(rssFeed)
+	assert.Equal(t, "Test Podcast", feed.Title)
+	if assert.NotNil(t, feed.ITunesExt) {
+		assert.Equal(t, "Podcaster", feed.ITunesExt.Author)
+		assert.Equal(t, "false", feed.ITunesExt.Explicit)
+		assert.NotEmpty(t, feed.ITunesExt.Image)
+		if assert.Len(t, feed.ITunesExt.Categories, 1) {
+			assert.Equal(t, "Technology", feed.ITunesExt.Categories[0].Text)
+			assert.Equal(t, "Podcasting", feed.ITunesExt.Categories[0].Subcategory.Text)
+		}
+	}
+}
+
+func Test_feedCache(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.Feeds = &configFeeds{Items: 2, Expiration: 3600}
+	})
+	h.app.initFeedRegeneration()
+
+	for i, path := range []string{"/a", "/b", "/c"} {
+		h.createPost(&post{Path: path, Content: "Post " + path, Published: fmt.Sprintf("2020-01-0%dT00:00:00Z", i+1)})
+	}
+
+	status, _, body := h.get("/posts.rss")
+	require.Equal(t, http.StatusOK, status)
+	feed, err := gofeed.NewParser().ParseString(body)
+	require.NoError(t, err)
+	assert.Len(t, feed.Items, 2)
+	// Index pages still use the pagination
+	_, _, body = h.get("/posts")
+	assert.Contains(t, body, "Post /a")
+	assert.NotContains(t, feed.Items[1].Description, "Post /a")
+
+	key := cacheKey(httptest.NewRequest(http.MethodGet, "/posts.rss", nil))
+	item, ok := h.app.cache.f.get(key)
+	require.True(t, ok)
+	assert.Equal(t, 3600, item.expiration)
+	_, ok = h.app.cache.c.get(key)
+	assert.False(t, ok)
+	_, ok = h.app.cache.c.get(cacheKey(httptest.NewRequest(http.MethodGet, "/posts", nil)))
+	assert.True(t, ok)
+
+	// New posts purge the feeds and render them again
+	h.createPost(&post{Path: "/d", Content: "Post /d"})
+	require.Eventually(t, func() bool {
+		item, ok := h.app.cache.f.get(cacheKey(httptest.NewRequest(http.MethodGet, "/.json", nil)))
+		return ok && strings.Contains(string(item.body), "Post /d")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"/.rss", "/.atom", "/.json", "/posts.rss", "/posts.atom", "/posts.json"}, h.app.feedRegenerationPaths(&post{Blog: "default", Section: "posts"}))
+}
+
+func Test_yearFeeds(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.Feeds = &configFeeds{Items: 2}
+	})
+	section := h.app.cfg.Blogs[h.app.cfg.DefaultBlog].DefaultSection
+
+	for i := 1; i <= 3; i++ {
+		h.createPost(&post{Path: fmt.Sprintf("/old%d", i), Content: "Old post", Published: fmt.Sprintf("2022-0%d-01T00:00:00Z", i)})
+	}
+	thisYear := time.Now().Year()
+	h.createPost(&post{Path: "/new", Content: "New post", Published: fmt.Sprintf("%d-01-01T12:00:00Z", thisYear)})
+
+	fp := gofeed.NewParser()
+	for _, path := range []string{"/" + section + "/2022.rss", "/2022.atom"} {
+		// The second request is served from the cache
+		for i := 0; i < 2; i++ {
+			status, header, body := h.get(path)
+			require.Equal(t, http.StatusOK, status)
+			assert.Equal(t, frozenFeedCacheControl, header.Get(cacheControl))
+			feed, err := fp.ParseString(body)
+			require.NoError(t, err)
+			assert.Len(t, feed.Items, 3)
+		}
+	}
+
+	// The current year isn't frozen
+	status, header, _ := h.get(fmt.Sprintf("/%d.rss", thisYear))
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, header.Get(cacheControl), "immutable")
+
+	// Other feeds are limited
+	status, _, body := h.get("/.rss")
+	require.Equal(t, http.StatusOK, status)
+	feed, err := fp.ParseString(body)
+	require.NoError(t, err)
+	assert.Len(t, feed.Items, 2)
+}
@@ -5,6 +5,8 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -67,6 +69,25 @@ func (a *goBlog) photonReverse(lat, lon float64, lang string) (*geojson.FeatureC
 	return fc, nil
 }
 
+var geoLatLonRegex = regexp.MustCompile(`^\s*(-?\d{1,2}(?:\.\d+)?)\s*,\s*(-?\d{1,3}(?:\.\d+)?)\s*$`)
+
+// Parse a location, either a geo URI (geo:lat,lon) or plain coordinates (lat,lon)
+func parseGeoLocation(loc string) *gogeouri.Geo {
+	if g, err := gogeouri.Parse(loc); err == nil && g != nil {
+		return g
+	}
+	m := geoLatLonRegex.FindStringSubmatch(loc)
+	if m == nil {
+		return nil
+	}
+	lat, _ := strconv.ParseFloat(m[1], 64)
+	lon, _ := strconv.ParseFloat(m[2], 64)
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil
+	}
+	return &gogeouri.Geo{Latitude: lat, Longitude: lon}
+}
+
 func geoOSMLink(g *gogeouri.Geo) string {
 	return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%v&mlon=%v", g.Latitude, g.Longitude)
 }
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 
+	geojson "github.com/paulmach/go.geojson"
 	"go.goblog.app/app/pkgs/contenttype"
 )
 
@@ -138,3 +139,45 @@ func (a *goBlog) serveGeoMapLocations(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(contentType, contenttype.JSONUTF8)
 	_ = pr.CloseWithError(a.min.Get().Minify(contenttype.JSON, w, pr))
 }
+
+const geoMapGeoJSONSubpath = "/locations.geojson"
+
+// All post locations as GeoJSON feature collection, e.g. for other map applications
+func (a *goBlog) serveGeoMapGeoJSON(w http.ResponseWriter, r *http.Request) {
+	blog, _ := a.getBlog(r)
+
+	allPostsWithLocationRequestConfig := &postsRequestConfig{
+		blog:               blog,
+		parameters:         []string{a.cfg.Micropub.LocationParam},
+		withOnlyParameters: []string{a.cfg.Micropub.LocationParam, "title"},
+	}
+	allPostsWithLocationRequestConfig.status, allPostsWithLocationRequestConfig.visibility = a.getDefaultPostStates(r)
+
+	allPostsWithLocations, err := a.getPosts(allPostsWithLocationRequestConfig)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fc := geojson.NewFeatureCollection()
+	for _, p := range allPostsWithLocations {
+		for _, g := range a.geoURIs(p) {
+			f := geojson.NewPointFeature([]float64{g.Longitude, g.Latitude})
+			f.SetProperty("url", a.fullPostURL(p))
+			if p.RenderedTitle != "" {
+				f.SetProperty("title", p.RenderedTitle)
+			}
+			if p.Published != "" {
+				f.SetProperty("published", p.Published)
+			}
+			fc.AddFeature(f)
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(json.NewEncoder(pw).Encode(fc))
+	}()
+	w.Header().Set(contentType, contenttype.GeoJSON+contenttype.CharsetUtf8Suffix)
+	_ = pr.CloseWithError(a.min.Get().Minify(contenttype.JSON, w, pr))
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
+	"github.com/carlmjohnson/requests"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.goblog.app/app/pkgs/contenttype"
 )
 
 func Test_geo(t *testing.T) {
@@ -57,3 +60,97 @@ func Test_geo(t *testing.T) {
 	assert.Equal(t, "Platz des 18. März, Berlin, Deutschland", gt)
 
 }
+
+func Test_parseGeoLocation(t *testing.T) {
+	for _, tc := range []struct {
+		loc      string
+		valid    bool
+		lat, lon float64
+	}{
+		{"geo:52.51627,13.37737", true, 52.51627, 13.37737},
+		{"52.51627,13.37737", true, 52.51627, 13.37737},
+		{" -33.8568, 151.2153 ", true, -33.8568, 151.2153},
+		{"52,13", true, 52, 13},
+		{"95.0,13.0", false, 0, 0},
+		{"52.5,190.0", false, 0, 0},
+		{"Berlin", false, 0, 0},
+		{"", false, 0, 0},
+	} {
+		g := parseGeoLocation(tc.loc)
+		if !tc.valid {
+			assert.Nil(t, g, tc.loc)
+			continue
+		}
+		if assert.NotNil(t, g, tc.loc) {
+			assert.Equal(t, tc.lat, g.Latitude, tc.loc)
+			assert.Equal(t, tc.lon, g.Longitude, tc.loc)
+		}
+	}
+}
+
+func Test_geoMapAndPostMaps(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		httpClient: fc.Client,
+		cfg:        createDefaultTestConfig(t),
+	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang: "en",
+			Sections: map[string]*configSection{
+				"posts": {Name: "posts"},
+			},
+			Map: &configGeoMap{
+				Enabled:  true,
+				PostMaps: true,
+			},
+		},
+	}
+	app.cfg.DefaultBlog = "en"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{
+		Path: "/located", Blog: "en", Section: "posts", Content: "Hello",
+		Parameters: map[string][]string{"title": {"Located"}, "location": {"52.51627, 13.37737"}},
+	}))
+	require.NoError(t, app.createPost(&post{
+		Path: "/unlocated", Blog: "en", Section: "posts", Content: "Hello",
+	}))
+
+	client := newHandlerClient(app.d)
+
+	t.Run("Post map", func(t *testing.T) {
+		var body string
+		err := requests.URL("http://localhost:8080/located").Client(client).ToString(&body).Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, body, "id=map")
+		assert.Contains(t, body, "13.37737")
+
+		err = requests.URL("http://localhost:8080/unlocated").Client(client).ToString(&body).Fetch(context.Background())
+		require.NoError(t, err)
+		assert.NotContains(t, body, "id=map")
+	})
+
+	t.Run("GeoJSON", func(t *testing.T) {
+		var result map[string]any
+		err := requests.URL("http://localhost:8080" + defaultGeoMapPath + geoMapGeoJSONSubpath).Client(client).
+			CheckContentType(contenttype.GeoJSON).
+			ToJSON(&result).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "FeatureCollection", result["type"])
+		features, _ := result["features"].([]any)
+		require.Len(t, features, 1)
+		feature := features[0].(map[string]any)
+		assert.Equal(t, []any{13.37737, 52.51627}, feature["geometry"].(map[string]any)["coordinates"])
+		assert.Equal(t, "http://localhost:8080/located", feature["properties"].(map[string]any)["url"])
+		assert.Equal(t, "Located", feature["properties"].(map[string]any)["title"])
+	})
+}
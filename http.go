@@ -7,8 +7,10 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"sort"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/dchest/captcha"
@@ -21,6 +23,8 @@ import (
 	"go.goblog.app/app/pkgs/maprouter"
 	"go.goblog.app/app/pkgs/plugintypes"
 	"golang.org/x/net/context"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const (
@@ -36,29 +40,9 @@ func (a *goBlog) startServer() (err error) {
 	log.Println("Start server(s)...")
 	// Load router
 	a.reloadRouter()
-	// Set basic middlewares
-	h := alice.New()
-	h = h.Append(middleware.Heartbeat("/ping"))
-	if a.cfg.Server.Logging {
-		h = h.Append(a.logMiddleware)
-	}
-	h = h.Append(middleware.Recoverer, httpcompress.Compress(flate.BestCompression))
-	if a.cfg.Server.SecurityHeaders {
-		h = h.Append(a.securityHeaders)
-	}
-	// Add plugin middlewares
-	middlewarePlugins := lo.Map(a.getPlugins(pluginMiddlewareType), func(item any, index int) plugintypes.Middleware { return item.(plugintypes.Middleware) })
-	sort.Slice(middlewarePlugins, func(i, j int) bool {
-		// Sort with descending prio
-		return middlewarePlugins[i].Prio() > middlewarePlugins[j].Prio()
-	})
-	for _, plugin := range middlewarePlugins {
-		h = h.Append(plugin.Handler)
-	}
-	// Finally...
-	finalHandler := h.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
-		a.d.ServeHTTP(w, r)
-	})
+	var finalHandler http.Handler = a.dh
+	// Warm cache
+	a.warmCache(finalHandler)
 	// Start Onion service
 	if a.cfg.Server.Tor {
 		go func() {
@@ -76,28 +60,47 @@ func (a *goBlog) startServer() (err error) {
 				h = m.HTTPHandler(h)
 			}
 			httpServer := &http.Server{
-				Addr:              ":80",
+				Addr:              net.JoinHostPort(a.cfg.Server.ListenAddress, "80"),
 				Handler:           h,
 				ReadHeaderTimeout: 1 * time.Minute,
 				ReadTimeout:       5 * time.Minute,
 				WriteTimeout:      5 * time.Minute,
 			}
-			a.shutdown.Add(shutdownServer(httpServer, "http server"))
+			a.addShutdownBeforeDatabase(a.shutdownServer(httpServer, "http server"))
 			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				log.Println("Failed to start HTTP server:", err.Error())
 			}
 		}()
 	}
+	// Allow HTTP/2 without TLS (e.g. behind a reverse proxy), with TLS it's enabled by default
+	if !a.cfg.Server.PublicHTTPS && !a.cfg.Server.manualHttps {
+		finalHandler = h2c.NewHandler(finalHandler, &http2.Server{})
+	}
 	s := &http.Server{
 		Handler:           finalHandler,
 		ReadHeaderTimeout: 1 * time.Minute,
 		ReadTimeout:       5 * time.Minute,
 		WriteTimeout:      5 * time.Minute,
 	}
-	a.shutdown.Add(shutdownServer(s, "main server"))
-	s.Addr = ":" + strconv.Itoa(a.cfg.Server.Port)
-	if a.cfg.Server.PublicHTTPS {
-		err = s.Serve(a.getAutocertManager().Listener())
+	a.addShutdownBeforeDatabase(a.shutdownServer(s, "main server"))
+	s.Addr = net.JoinHostPort(a.cfg.Server.ListenAddress, strconv.Itoa(a.cfg.Server.Port))
+	if socket := a.cfg.Server.UnixSocket; socket != "" {
+		var listener net.Listener
+		if listener, err = listenUnixSocket(socket); err != nil {
+			return err
+		}
+		log.Println("Listening on unix socket", socket)
+		if a.cfg.Server.manualHttps {
+			err = s.ServeTLS(listener, a.cfg.Server.HttpsCert, a.cfg.Server.HttpsKey)
+		} else {
+			err = s.Serve(listener)
+		}
+	} else if a.cfg.Server.PublicHTTPS {
+		var listener net.Listener
+		if listener, err = a.autocertListener(s.Addr); err != nil {
+			return err
+		}
+		err = s.Serve(listener)
 	} else if a.cfg.Server.manualHttps {
 		err = s.ListenAndServeTLS(a.cfg.Server.HttpsCert, a.cfg.Server.HttpsKey)
 	} else {
@@ -109,9 +112,34 @@ func (a *goBlog) startServer() (err error) {
 	return err
 }
 
-func shutdownServer(s *http.Server, name string) func() {
+func listenUnixSocket(path string) (net.Listener, error) {
+	// Remove socket file left over from an unclean shutdown, but never other files
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Allow the reverse proxy (in the same group) to connect
+	if err = os.Chmod(path, 0o660); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Stop accepting new connections and wait for running requests to finish
+func (a *goBlog) shutdownServer(s *http.Server, name string) func() {
 	return func() {
-		toc, c := context.WithTimeout(context.Background(), 5*time.Second)
+		toc, c := context.WithTimeout(context.Background(), time.Duration(a.cfg.Server.ShutdownTimeout)*time.Second)
 		defer c()
 		if err := s.Shutdown(toc); err != nil {
 			log.Printf("Error on server shutdown (%v): %v", name, err)
@@ -136,6 +164,47 @@ const (
 
 func (a *goBlog) reloadRouter() {
 	a.d = a.buildRouter()
+	if a.dh == nil {
+		a.dh = &dynamicHandler{}
+	}
+	a.dh.swap(a.withServerMiddlewares(a.d))
+}
+
+func (a *goBlog) withServerMiddlewares(next http.Handler) http.Handler {
+	// Set basic middlewares
+	h := alice.New()
+	h = h.Append(middleware.Heartbeat("/ping"))
+	if a.cfg.Server.Logging {
+		h = h.Append(a.logMiddleware)
+	}
+	h = h.Append(middleware.Recoverer, httpcompress.Compress(flate.BestCompression))
+	if a.cfg.Server.SecurityHeaders {
+		h = h.Append(a.securityHeaders)
+	}
+	// Add plugin middlewares
+	middlewarePlugins := lo.Map(a.getPlugins(pluginMiddlewareType), func(item any, index int) plugintypes.Middleware { return item.(plugintypes.Middleware) })
+	sort.Slice(middlewarePlugins, func(i, j int) bool {
+		// Sort with descending prio
+		return middlewarePlugins[i].Prio() > middlewarePlugins[j].Prio()
+	})
+	for _, plugin := range middlewarePlugins {
+		h = h.Append(plugin.Handler)
+	}
+	// Finally...
+	return h.Then(next)
+}
+
+// Handler that can be replaced while the server is running
+type dynamicHandler struct {
+	h atomic.Pointer[http.Handler]
+}
+
+func (d *dynamicHandler) swap(h http.Handler) {
+	d.h.Store(&h)
+}
+
+func (d *dynamicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*d.h.Load()).ServeHTTP(w, r)
 }
 
 func (a *goBlog) buildRouter() http.Handler {
@@ -160,9 +229,27 @@ func (a *goBlog) buildRouter() http.Handler {
 	r := chi.NewMux()
 
 	// Basic middleware
+	r.Use(requestIDMiddleware)
+	if a.cfg.Metrics.enabled() {
+		r.Use(a.metricsMiddleware)
+	}
 	r.Use(fixHTTPHandler)
-	r.Use(middleware.RedirectSlashes)
-	r.Use(middleware.CleanPath)
+	r.Use(a.normalizePath)
+
+	// Login brute-force protection, before the rate limiting, so failures are always counted
+	if a.cfg.Server.LoginProtection.enabled() {
+		r.Use(a.loginProtectionMiddleware)
+	}
+
+	// Rate limiting
+	if a.cfg.Server.RateLimit.enabled() {
+		r.Use(a.rateLimitMiddleware)
+	}
+
+	// Analytics
+	if a.analyticsEnabled() {
+		r.Use(a.analyticsMiddleware)
+	}
 
 	// Tor
 	if a.cfg.Server.Tor {
@@ -179,8 +266,12 @@ func (a *goBlog) buildRouter() http.Handler {
 		r.Use(noIndexHeader)
 	}
 
+	// Preview flags
+	r.Use(a.checkPreviewFlags)
+
 	// Login and captcha middleware
 	r.Use(a.checkIsLogin)
+	r.Use(a.checkIsBlogPassphrase)
 	r.Use(a.checkIsCaptcha)
 
 	// Login
@@ -192,15 +283,60 @@ func (a *goBlog) buildRouter() http.Handler {
 	// IndieAuth
 	r.Group(a.indieAuthRouter)
 
+	// Mastodon API
+	r.Group(a.mastodonAPIRouter)
+
 	// ActivityPub and stuff
 	r.Group(a.activityPubRouter)
 
+	// Nostr identifiers (NIP-05)
+	r.Get(nostrPath, a.serveNostrJSON)
+
 	// Webmentions
 	r.Route(webmentionPath, a.webmentionsRouter)
 
 	// Notifications
 	r.Route(notificationsPath, a.notificationsRouter)
 
+	// Timeline
+	r.Route(timelinePath, a.timelineRouter)
+
+	// Feed reader
+	r.Route(feedReaderPath, a.feedReaderRouter)
+
+	// Syndication status
+	r.Route(syndicationPath, a.syndicationRouter)
+
+	// Analytics
+	r.Group(a.analyticsRouter)
+
+	// Preview flags
+	r.With(a.authMiddleware).Get(previewFlagsPath, a.servePreviewFlagsLink)
+
+	// Backup
+	r.With(a.authMiddleware).Get(backupPath, a.serveBackup)
+
+	// Export
+	r.With(a.authMiddleware).Get(exportPath, a.serveExport)
+
+	// Short paths
+	r.With(a.authMiddleware).Get(shortPathsPath, a.serveShortPaths)
+
+	// Media library
+	r.With(a.authMiddleware).Get(mediaLibraryPath, a.serveMediaLibrary)
+	r.With(a.authMiddleware).Delete(mediaLibraryPath+mediaFileRoute, a.serveMediaLibraryDelete)
+
+	// Rate limit counters
+	r.With(a.authMiddleware).Get(rateLimitStatsPath, a.serveRateLimitStats)
+
+	// Metrics
+	if a.cfg.Metrics.enabled() {
+		r.Get(metricsPath, a.serveMetrics)
+	}
+
+	// Cache
+	r.Route(cachePath, a.cacheRouter)
+
 	// Assets
 	r.Group(a.assetsRouter)
 
@@ -247,9 +383,15 @@ func (a *goBlog) buildRouter() http.Handler {
 	r.MethodNotAllowed(a.serveNotAllowed)
 
 	mapRouter.DefaultHandler = r
+	for _, cd := range a.cfg.Server.CustomDomains {
+		mapRouter.Handlers[cd.hostname] = a.customDomainHandler(cd, r)
+	}
 	return alice.New(headAsGetHandler).Then(mapRouter)
 }
 
+// Posts, short paths, aliases and deleted posts aren't registered as routes,
+// they are looked up in the database when no other route matches.
+// This way the router doesn't need to be rebuilt when content changes.
 func (a *goBlog) servePostsAliasesRedirects() http.HandlerFunc {
 	// Private mode
 	alicePrivate := alice.New(a.privateModeHandler)
@@ -262,28 +404,32 @@ func (a *goBlog) servePostsAliasesRedirects() http.HandlerFunc {
 		}
 		// Check if post or alias
 		path := r.URL.Path
+		snapshotPath, snapshotVersion := splitPostSnapshotPath(path)
 		row, err := a.db.QueryRow(`
 		-- normal posts
-		select 'post', status, visibility, 200 from posts where path = @path
+		select 'post', status, visibility, blog, 200 from posts where path = @path
+		union all
+		-- snapshots of public posts
+		select 'snapshot', '', '', p.blog, 200 from post_snapshots s join posts p on s.path = p.path where s.path = @snapshotpath and s.version = @snapshotversion and p.status = 'published' and p.visibility in ('public', 'unlisted')
 		union all
 		-- short paths
-		select 'alias', path, '', 301 from shortpath where printf('/s/%x', id) = @path
+		select 'short', path, '', '', 301 from shortpath where printf('%x', id) = @shortcode or code = @shortcode
 		union all
 		-- post aliases
-		select 'alias', path, '', 302 from post_parameters where parameter = 'aliases' and value = @path
+		select 'alias', path, '', '', 301 from post_parameters where parameter = 'aliases' and value = @path
 		union all
 		-- deleted posts
-		select 'deleted', '', '', 410 from deleted where path = @path
+		select 'deleted', '', '', '', 410 from deleted where path = @path
 		-- just select the first result
 		limit 1
-		`, sql.Named("path", path))
+		`, sql.Named("path", path), sql.Named("snapshotpath", snapshotPath), sql.Named("snapshotversion", snapshotVersion), sql.Named("shortcode", a.shortCodeFromPath(path)))
 		if err != nil {
 			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		var pathType, value1, value2 string
+		var pathType, value1, value2, blog string
 		var status int
-		err = row.Scan(&pathType, &value1, &value2, &status)
+		err = row.Scan(&pathType, &value1, &value2, &blog, &status)
 		if err != nil {
 			if !errors.Is(err, sql.ErrNoRows) {
 				// Error
@@ -295,15 +441,19 @@ func (a *goBlog) servePostsAliasesRedirects() http.HandlerFunc {
 			// Found post or alias
 			switch pathType {
 			case "post":
+				// Set blog (needed for protected blogs)
+				if blog != "" {
+					r = r.WithContext(context.WithValue(r.Context(), blogKey, blog))
+				}
 				// Check status
 				switch postStatus(value1) {
 				case statusPublished:
 					// Check visibility
 					switch postVisibility(value2) {
 					case visibilityPublic, visibilityUnlisted:
-						alicePrivate.Append(a.checkActivityStreamsRequest, a.cacheMiddleware).ThenFunc(a.servePost).ServeHTTP(w, r)
+						alicePrivate.Append(a.checkActivityStreamsRequest, cachePostPage, a.cacheMiddleware).ThenFunc(a.servePost).ServeHTTP(w, r)
 					default: // private, etc.
-						alice.New(a.authMiddleware).ThenFunc(a.servePost).ServeHTTP(w, r)
+						alice.New(a.blogUserMiddleware(a.cfg.Blogs[blog], userRoleAuthor)).ThenFunc(a.servePost).ServeHTTP(w, r)
 					}
 					return
 				case statusPublishedDeleted:
@@ -311,26 +461,47 @@ func (a *goBlog) servePostsAliasesRedirects() http.HandlerFunc {
 						a.servePost(w, r)
 						return
 					}
-					alicePrivate.Append(a.cacheMiddleware).ThenFunc(a.serve410).ServeHTTP(w, r)
+					alicePrivate.Append(a.checkActivityStreamsRequest, a.cacheMiddleware).ThenFunc(a.serve410).ServeHTTP(w, r)
+					return
+				case statusDraft, statusScheduled:
+					if token := r.URL.Query().Get(previewTokenParam); token != "" && !a.isLoggedIn(r) && a.checkPreviewToken(path, token) {
+						// Valid preview link
+						a.servePreview(w, r)
+						return
+					}
+					alice.New(a.blogUserMiddleware(a.cfg.Blogs[blog], userRoleAuthor)).ThenFunc(a.servePost).ServeHTTP(w, r)
 					return
-				default: // draft, scheduled, etc.
-					alice.New(a.authMiddleware).ThenFunc(a.servePost).ServeHTTP(w, r)
+				default: // deleted drafts, etc.
+					alice.New(a.blogUserMiddleware(a.cfg.Blogs[blog], userRoleAuthor)).ThenFunc(a.servePost).ServeHTTP(w, r)
 					return
 				}
+			case "snapshot":
+				alicePrivate.Append(cacheLoggedIn, a.cacheMiddleware).ThenFunc(a.servePostSnapshot).ServeHTTP(w, r)
+				return
 			case "alias":
 				// Is alias, redirect
 				alicePrivate.Append(cacheLoggedIn, a.cacheMiddleware).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
 					http.Redirect(w, r, value1, status)
 				}).ServeHTTP(w, r)
 				return
+			case "short":
+				// Is short path, count the click and redirect (not cached, so every click is counted)
+				alicePrivate.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					if err := a.db.countShortPathClick(value1); err != nil {
+						log.Println("Failed to count short path click:", err.Error())
+					}
+					http.Redirect(w, r, value1, status)
+				}).ServeHTTP(w, r)
+				return
 			case "deleted":
 				// Is deleted, serve 410
-				alicePrivate.Append(a.cacheMiddleware).ThenFunc(a.serve410).ServeHTTP(w, r)
+				alicePrivate.Append(a.checkActivityStreamsRequest, a.cacheMiddleware).ThenFunc(a.serve410).ServeHTTP(w, r)
 				return
 			}
 		}
-		// No post, check template assets (dynamically registered), regex redirects or serve 404 error
-		alice.New(a.cacheMiddleware, a.checkTemplateAssets, a.checkRegexRedirects).ThenFunc(a.serve404).ServeHTTP(w, r)
+		// No post, check template assets (dynamically registered, not cached because they choose the encoding themselves),
+		// regex redirects or serve 404 error
+		alice.New(a.checkTemplateAssets, a.cacheMiddleware, a.checkRegexRedirects).ThenFunc(a.serve404).ServeHTTP(w, r)
 	}
 }
 
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"errors"
+	"net"
 	"net/http"
+	"syscall"
 	"time"
 
 	"github.com/klauspost/compress/gzhttp"
@@ -20,6 +23,42 @@ func newHttpClient() *http.Client {
 	}
 }
 
+// Client for requests to addresses that users can choose (like archived links),
+// it refuses to connect to loopback and private addresses, also after redirects
+func newPublicHttpClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errors.New("refusing to connect to non-public address " + host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: time.Minute,
+		Transport: newAddUserAgentTransport(
+			gzhttp.Transport(
+				&http.Transport{
+					DialContext:       dialer.DialContext,
+					DisableKeepAlives: true,
+				},
+			),
+		),
+	}
+}
+
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
+
 type addUserAgentTransport struct {
 	t http.RoundTripper
 }
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
 	"net/http"
 	"time"
 
@@ -8,6 +12,14 @@ import (
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
 )
 
+const (
+	logIPsRemove   = ""
+	logIPsTruncate = "truncate"
+	logIPsHash     = "hash"
+
+	defaultLogRetention = 30
+)
+
 func (a *goBlog) initHTTPLog() (err error) {
 	if !a.cfg.Server.Logging || a.cfg.Server.LogFile == "" {
 		return nil
@@ -16,17 +28,72 @@ func (a *goBlog) initHTTPLog() (err error) {
 		a.cfg.Server.LogFile+".%Y%m%d",
 		rotatelogs.WithLinkName(a.cfg.Server.LogFile),
 		rotatelogs.WithClock(rotatelogs.UTC),
-		rotatelogs.WithMaxAge(30*24*time.Hour),
+		rotatelogs.WithMaxAge(time.Duration(a.logRetentionDays())*24*time.Hour),
 		rotatelogs.WithRotationTime(24*time.Hour),
 	)
 	return
 }
 
+func (a *goBlog) logRetentionDays() int {
+	if a.cfg.Server.LogRetention > 0 {
+		return a.cfg.Server.LogRetention
+	}
+	return defaultLogRetention
+}
+
 func (a *goBlog) logMiddleware(next http.Handler) http.Handler {
 	h := handlers.CombinedLoggingHandler(a.logf, next)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Remove remote address for privacy
-		r.RemoteAddr = ""
+		// Remove or anonymize remote address for privacy
+		r = a.setClientIP(r)
+		r.RemoteAddr = a.anonymizeIP(r.RemoteAddr)
 		h.ServeHTTP(w, r)
 	})
 }
+
+// anonymizeIP returns the anonymized form of the remote address using the configured mode.
+// By default the address gets removed completely.
+func (a *goBlog) anonymizeIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	switch a.cfg.Server.LogIPs {
+	case logIPsTruncate:
+		return truncateIP(ip)
+	case logIPsHash:
+		return a.hashIP(ip)
+	default:
+		return ""
+	}
+}
+
+// truncateIP removes the last octet of IPv4 addresses and everything after the first 48 bits of IPv6 addresses
+func truncateIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// hashIP hashes the address with a salt that only lives in memory and rotates daily,
+// so it's possible to count unique visitors of a day but not to track them across days
+func (a *goBlog) hashIP(ip net.IP) string {
+	a.logIPSaltMutex.Lock()
+	today := time.Now().UTC().Format("2006-01-02")
+	if a.logIPSaltDay != today || a.logIPSalt == nil {
+		salt := make([]byte, 32)
+		_, _ = rand.Read(salt)
+		a.logIPSalt, a.logIPSaltDay = salt, today
+	}
+	salt := a.logIPSalt
+	a.logIPSaltMutex.Unlock()
+	h := sha256.New()
+	_, _ = h.Write(salt)
+	_, _ = h.Write(ip)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
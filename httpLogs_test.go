@@ -108,3 +108,46 @@ func Benchmark_httpLogs(b *testing.B) {
 	})
 
 }
+
+func Test_anonymizeIP(t *testing.T) {
+	app := &goBlog{
+		cfg: &config{
+			Server: &configServer{},
+		},
+	}
+
+	// Default: remove IP
+	assert.Equal(t, "", app.anonymizeIP("192.168.1.123:1234"))
+
+	// Truncate
+	app.cfg.Server.LogIPs = logIPsTruncate
+	assert.Equal(t, "192.168.1.0", app.anonymizeIP("192.168.1.123:1234"))
+	assert.Equal(t, "192.168.1.0", app.anonymizeIP("192.168.1.123"))
+	assert.Equal(t, "2001:db8:85a3::", app.anonymizeIP("[2001:db8:85a3:8d3:1319:8a2e:370:7348]:443"))
+	assert.Equal(t, "", app.anonymizeIP("invalid"))
+
+	// Hash
+	app.cfg.Server.LogIPs = logIPsHash
+	hash1 := app.anonymizeIP("192.168.1.123:1234")
+	hash2 := app.anonymizeIP("192.168.1.123:4321")
+	hash3 := app.anonymizeIP("192.168.1.124:1234")
+	assert.Len(t, hash1, 16)
+	assert.Equal(t, hash1, hash2)
+	assert.NotEqual(t, hash1, hash3)
+	assert.NotContains(t, hash1, "192.168")
+
+	// Rotated salt results in a different hash
+	app.logIPSaltDay = "2000-01-01"
+	assert.NotEqual(t, hash1, app.anonymizeIP("192.168.1.123:1234"))
+}
+
+func Test_logRetentionDays(t *testing.T) {
+	app := &goBlog{
+		cfg: &config{
+			Server: &configServer{},
+		},
+	}
+	assert.Equal(t, defaultLogRetention, app.logRetentionDays())
+	app.cfg.Server.LogRetention = 7
+	assert.Equal(t, 7, app.logRetentionDays())
+}
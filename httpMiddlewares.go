@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/samber/lo"
 	"go.goblog.app/app/pkgs/builderpool"
 )
@@ -23,6 +29,50 @@ func fixHTTPHandler(next http.Handler) http.Handler {
 	})
 }
 
+// Redirect to the canonical form of the path (without trailing slash, duplicate slashes and dot segments
+// and with the lowercase path configured) before the cache and the other handlers see the request,
+// so the same content isn't served under multiple URLs
+func (a *goBlog) normalizePath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if !strings.HasPrefix(p, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		canonical := path.Clean(p)
+		if a.cfg.Server.LowercasePaths {
+			// Only redirect if the path exists in lowercase, but not as requested
+			if lower := strings.ToLower(canonical); lower != canonical && !a.pathExists(r, canonical) && a.pathExists(r, lower) {
+				canonical = lower
+			}
+		}
+		if canonical != p {
+			u := *r.URL
+			u.Path, u.RawPath = canonical, ""
+			http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check if the path is a route or a post or alias in the database
+func (a *goBlog) pathExists(r *http.Request, p string) bool {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.Routes != nil && rctx.Routes.Match(chi.NewRouteContext(), r.Method, p) {
+		return true
+	}
+	row, err := a.db.QueryRow(
+		"select exists(select 1 from posts where path = @path) or exists(select 1 from post_parameters where parameter = 'aliases' and value = @path)",
+		sql.Named("path", p),
+	)
+	if err != nil {
+		return false
+	}
+	var exists bool
+	_ = row.Scan(&exists)
+	return exists
+}
+
 func headAsGetHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodHead {
@@ -38,7 +88,46 @@ func headAsGetHandler(next http.Handler) http.Handler {
 	})
 }
 
+const (
+	cspNonceKey         contextKey = "cspNonce"
+	cspNoncePlaceholder            = "{nonce}"
+)
+
 func (a *goBlog) securityHeaders(next http.Handler) http.Handler {
+	csp := a.buildContentSecurityPolicy()
+	useNonce := a.cfg.Server.CSP != nil && a.cfg.Server.CSP.Nonce
+	// Return handler
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000;")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("X-Xss-Protection", "1; mode=block")
+		if useNonce {
+			nonce := generateCSPNonce()
+			r = r.WithContext(context.WithValue(r.Context(), cspNonceKey, nonce))
+			w.Header().Set("Content-Security-Policy", strings.ReplaceAll(csp, cspNoncePlaceholder, nonce))
+		} else {
+			w.Header().Set("Content-Security-Policy", csp)
+		}
+		// Per route overrides, empty values remove the header
+		for _, o := range a.cfg.Server.HeaderOverrides {
+			if !strings.HasPrefix(r.URL.Path, o.PathPrefix) {
+				continue
+			}
+			for k, v := range o.Headers {
+				if v == "" {
+					w.Header().Del(k)
+				} else {
+					w.Header().Set(k, v)
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *goBlog) buildContentSecurityPolicy() string {
 	// Build CSP domains list
 	cspBuilder := builderpool.Get()
 	if mp := a.cfg.Micropub.MediaStorage; mp != nil && mp.MediaURL != "" {
@@ -52,18 +141,56 @@ func (a *goBlog) securityHeaders(next http.Handler) http.Handler {
 		cspBuilder.WriteString(strings.Join(a.cfg.Server.CSPDomains, " "))
 	}
 	cspDomains := cspBuilder.String()
-	csp := "default-src 'self' blob:" + cspDomains + "; img-src 'self'" + cspDomains + " data:; frame-ancestors none;"
 	builderpool.Put(cspBuilder)
-	// Return handler
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Strict-Transport-Security", "max-age=31536000;")
-		w.Header().Set("Referrer-Policy", "no-referrer")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-Xss-Protection", "1; mode=block")
-		w.Header().Set("Content-Security-Policy", csp)
-		next.ServeHTTP(w, r)
-	})
+	// Additional sources
+	cspConfig := a.cfg.Server.CSP
+	if cspConfig == nil {
+		cspConfig = &configCSP{}
+	}
+	sources := func(extra []string) string {
+		s := cspDomains
+		if len(extra) > 0 {
+			s += " " + strings.Join(extra, " ")
+		}
+		if cspConfig.Nonce {
+			s += " 'nonce-" + cspNoncePlaceholder + "'"
+		}
+		return s
+	}
+	// Build policy
+	csp := builderpool.Get()
+	defer builderpool.Put(csp)
+	csp.WriteString("default-src 'self' blob:" + cspDomains + "; ")
+	if cspConfig.Nonce || len(cspConfig.ScriptSources) > 0 {
+		csp.WriteString("script-src 'self' blob:" + sources(cspConfig.ScriptSources) + "; ")
+	}
+	if cspConfig.Nonce || len(cspConfig.StyleSources) > 0 {
+		csp.WriteString("style-src 'self' blob:" + sources(cspConfig.StyleSources) + "; ")
+	}
+	csp.WriteString("img-src 'self'" + cspDomains)
+	if len(cspConfig.ImageSources) > 0 {
+		csp.WriteString(" " + strings.Join(cspConfig.ImageSources, " "))
+	}
+	csp.WriteString(" data:; ")
+	// Players of the embed shortcodes
+	csp.WriteString("frame-src 'self'" + cspDomains + " " + youtubeEmbedOrigin + " " + vimeoEmbedOrigin + "; ")
+	csp.WriteString("frame-ancestors 'none';")
+	return csp.String()
+}
+
+func generateCSPNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// Get the CSP nonce of the current request, empty if not enabled
+func cspNonce(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	nonce, _ := r.Context().Value(cspNonceKey).(string)
+	return nonce
 }
 
 func (a *goBlog) addOnionLocation(next http.Handler) http.Handler {
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_noIndexHeader(t *testing.T) {
@@ -60,3 +61,121 @@ func Test_keepSelectedQueryParams(t *testing.T) {
 
 	assert.Equal(t, "/test?size=123", got.URL.RequestURI())
 }
+
+func Test_securityHeaders(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.CSPDomains = []string{"media.example.com"}
+	_ = app.initConfig(false)
+
+	var gotNonce string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		gotNonce = cspNonce(r)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		app.securityHeaders(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.org/", nil))
+
+		assert.Equal(t, "default-src 'self' blob: media.example.com; img-src 'self' media.example.com data:; frame-src 'self' media.example.com https://www.youtube-nocookie.com https://player.vimeo.com; frame-ancestors 'none';", rec.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+		assert.Empty(t, gotNonce)
+	})
+
+	t.Run("Sources and nonce", func(t *testing.T) {
+		app.cfg.Server.CSP = &configCSP{
+			ScriptSources: []string{"scripts.example.com"},
+			ImageSources:  []string{"https:"},
+			Nonce:         true,
+		}
+
+		rec := httptest.NewRecorder()
+		app.securityHeaders(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.org/", nil))
+
+		require.NotEmpty(t, gotNonce)
+		assert.Equal(t,
+			"default-src 'self' blob: media.example.com; "+
+				"script-src 'self' blob: media.example.com scripts.example.com 'nonce-"+gotNonce+"'; "+
+				"style-src 'self' blob: media.example.com 'nonce-"+gotNonce+"'; "+
+				"img-src 'self' media.example.com https: data:; "+
+				"frame-src 'self' media.example.com https://www.youtube-nocookie.com https://player.vimeo.com; frame-ancestors 'none';",
+			rec.Header().Get("Content-Security-Policy"),
+		)
+
+		app.cfg.Server.CSP = nil
+	})
+
+	t.Run("Header overrides", func(t *testing.T) {
+		app.cfg.Server.HeaderOverrides = []*configHeaderOverride{
+			{
+				PathPrefix: "/embed",
+				Headers: map[string]string{
+					"x-frame-options":         "",
+					"content-security-policy": "frame-ancestors https://example.net",
+					"x-custom":                "test",
+				},
+			},
+		}
+
+		rec := httptest.NewRecorder()
+		app.securityHeaders(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.org/embed/test", nil))
+
+		assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "frame-ancestors https://example.net", rec.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "test", rec.Header().Get("X-Custom"))
+
+		rec = httptest.NewRecorder()
+		app.securityHeaders(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.org/other", nil))
+
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+		assert.Empty(t, rec.Header().Get("X-Custom"))
+	})
+}
+
+func Test_normalizePath(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.LowercasePaths = true
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{Path: "/posts/lower", Section: "posts", Content: "Lower"}))
+	require.NoError(t, app.createPost(&post{Path: "/posts/Upper", Section: "posts", Content: "Upper"}))
+
+	for _, tc := range []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/posts/lower", http.StatusOK, ""},
+		{"/posts/lower/", http.StatusMovedPermanently, "/posts/lower"},
+		{"/posts/lower/?a=b", http.StatusMovedPermanently, "/posts/lower?a=b"},
+		{"/posts//lower", http.StatusMovedPermanently, "/posts/lower"},
+		{"/posts/../posts/lower", http.StatusMovedPermanently, "/posts/lower"},
+		{"/Posts/Lower", http.StatusMovedPermanently, "/posts/lower"},
+		{"/POSTS", http.StatusMovedPermanently, "/posts"},
+		// Existing paths with uppercase letters aren't redirected
+		{"/posts/Upper", http.StatusOK, ""},
+		// Unknown in both forms
+		{"/Unknown", http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+tc.path, nil))
+		assert.Equal(t, tc.status, rec.Code, tc.path)
+		assert.Equal(t, tc.location, rec.Header().Get("Location"), tc.path)
+	}
+
+	// Lowercase redirect is optional
+	app.cfg.Server.LowercasePaths = false
+	rec := httptest.NewRecorder()
+	app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/Posts/Lower", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -8,9 +8,12 @@ import (
 
 // Login
 func (a *goBlog) loginRouter(r chi.Router) {
-	r.Use(a.authMiddleware)
-	r.Get("/login", serveLogin)
-	r.Get("/logout", a.serveLogout)
+	r.With(a.anyUserMiddleware).Get("/login", serveLogin)
+	r.With(a.anyUserMiddleware).Get("/logout", a.serveLogout)
+	if a.indieAuthLoginEnabled() {
+		r.Post(indieAuthLoginPath, a.serveIndieAuthLogin)
+		r.Get(indieAuthLoginCallbackPath, a.serveIndieAuthLoginCallback)
+	}
 }
 
 // Micropub
@@ -34,6 +37,30 @@ func (a *goBlog) indieAuthRouter(r chi.Router) {
 	r.With(cacheLoggedIn, a.cacheMiddleware).Get("/.well-known/oauth-authorization-server", a.indieAuthMetadata)
 }
 
+// Mastodon API
+func (a *goBlog) mastodonAPIRouter(r chi.Router) {
+	if !a.mastodonAPIEnabled() {
+		return
+	}
+	r.Get("/api/v1/instance", a.serveMastodonInstance)
+	r.With(bodylimit.BodyLimit(100*bodylimit.KB)).Post(mastodonAppsPath, a.mastodonRegisterApp)
+	r.Group(func(r chi.Router) {
+		r.Use(a.checkIndieAuth)
+		r.Get("/api/v1/accounts/verify_credentials", a.mastodonVerifyCredentials)
+		r.With(bodylimit.BodyLimit(30*bodylimit.MB)).Post("/api/v1/media", a.mastodonUploadMedia)
+		r.With(bodylimit.BodyLimit(30*bodylimit.MB)).Post("/api/v2/media", a.mastodonUploadMedia)
+		r.Get("/api/v1/media/{id}", a.mastodonServeMedia)
+		r.With(bodylimit.BodyLimit(100*bodylimit.KB)).Put("/api/v1/media/{id}", a.mastodonUpdateMedia)
+		r.With(bodylimit.BodyLimit(10*bodylimit.MB)).Post("/api/v1/statuses", a.mastodonCreateStatus)
+	})
+	r.Route(mastodonOAuthPath, func(r chi.Router) {
+		r.Get("/authorize", a.mastodonAuthorize)
+		r.With(a.authMiddleware, bodylimit.BodyLimit(100*bodylimit.KB)).Post("/authorize", a.mastodonAuthorizeAccept)
+		r.With(bodylimit.BodyLimit(100*bodylimit.KB)).Post("/token", a.mastodonToken)
+		r.With(bodylimit.BodyLimit(100*bodylimit.KB)).Post("/revoke", a.mastodonRevoke)
+	})
+}
+
 // ActivityPub
 func (a *goBlog) activityPubRouter(r chi.Router) {
 	if a.isPrivate() {
@@ -46,6 +73,9 @@ func (a *goBlog) activityPubRouter(r chi.Router) {
 			r.With(a.checkActivityStreamsRequest).Get("/followers/{blog}", a.apShowFollowers)
 			r.With(a.cacheMiddleware).Get("/remote_follow/{blog}", a.apRemoteFollow)
 			r.With(bodylimit.BodyLimit(100*bodylimit.KB)).Post("/remote_follow/{blog}", a.apRemoteFollow)
+			r.With(a.authMiddleware).Post(apRotateKeyPath+"/{blog}", a.apRotateKeyHandler)
+			r.With(a.authMiddleware).Post(apBackfillPath+"/{blog}", a.apBackfillHandler)
+			r.With(a.authMiddleware).Get(apInboxLogPath+"/{blog}", a.apShowInboxLog)
 		})
 		r.Group(func(r chi.Router) {
 			r.Use(cacheLoggedIn, a.cacheMiddleware)
@@ -82,6 +112,52 @@ func (a *goBlog) notificationsRouter(r chi.Router) {
 	r.Post("/delete", a.notificationsAdminDelete)
 }
 
+// Timeline
+func (a *goBlog) timelineRouter(r chi.Router) {
+	r.Use(a.authMiddleware)
+	r.Get("/", a.serveTimeline)
+	r.Get(paginationPath, a.serveTimeline)
+	r.Post("/{action:(publish|unlist|delete)}", a.timelineAction)
+}
+
+// Feed reader
+func (a *goBlog) feedReaderRouter(r chi.Router) {
+	if !a.feedReaderEnabled() {
+		return
+	}
+	r.Use(a.authMiddleware)
+	r.Get("/", a.serveFeedReader)
+	r.Get(paginationPath, a.serveFeedReader)
+	r.Post("/{action:(bookmark|reply|dismiss)}", a.feedReaderAction)
+}
+
+// Analytics
+func (a *goBlog) analyticsRouter(r chi.Router) {
+	if !a.analyticsEnabled() {
+		return
+	}
+	r.Use(a.authMiddleware)
+	r.Get(analyticsPath, a.serveAnalytics)
+	r.Get(analyticsPath+".json", a.serveAnalyticsJSON)
+}
+
+// Syndication status
+func (a *goBlog) syndicationRouter(r chi.Router) {
+	if !a.syndicationEnabled() {
+		return
+	}
+	r.Use(a.authMiddleware)
+	r.Get("/", a.serveSyndicationStatus)
+	r.Get(paginationPath, a.serveSyndicationStatus)
+	r.Post("/retry", a.syndicationRetry)
+}
+
+// Cache
+func (a *goBlog) cacheRouter(r chi.Router) {
+	r.Use(a.authMiddleware)
+	r.Post("/purge", a.serveCachePurge)
+}
+
 // Assets
 func (a *goBlog) assetsRouter(r chi.Router) {
 	for _, path := range a.allAssetPaths() {
@@ -105,9 +181,15 @@ func (a *goBlog) mediaFilesRouter(r chi.Router) {
 
 // Profile image
 func (a *goBlog) profileImageRouter(r chi.Router) {
-	r.Use(keepSelectedQueryParams("s", "q"), cacheLoggedIn, a.cacheMiddleware, noIndexHeader)
+	r.Use(keepSelectedQueryParams("s", "q", "size", "d", "default"), cacheLoggedIn, a.cacheMiddleware, noIndexHeader)
 	r.Get(profileImagePathJPEG, a.serveProfileImage(profileImageFormatJPEG))
 	r.Get(profileImagePathPNG, a.serveProfileImage(profileImageFormatPNG))
+	// Stable avatar URL
+	r.Get(wellKnownAvatarPath, a.serveProfileImage(profileImageFormatJPEG))
+	// Libravatar
+	if lc := a.cfg.Libravatar; lc != nil && lc.Enabled {
+		r.Get(libravatarPath+"/{hash:([0-9a-fA-F]{32}|[0-9a-fA-F]{64})}", a.serveLibravatar)
+	}
 }
 
 // Various other routes
@@ -147,9 +229,15 @@ func (a *goBlog) blogRouter(blog string, conf *configBlog) func(r chi.Router) {
 		// Dates
 		r.Group(a.blogDatesRouter(conf))
 
+		// Series
+		r.Group(a.blogSeriesRouter(conf))
+
 		// Photos
 		r.Group(a.blogPhotosRouter(conf))
 
+		// Custom pages
+		r.Group(a.blogPagesRouter(conf))
+
 		// Search
 		r.Group(a.blogSearchRouter(conf))
 
@@ -168,6 +256,9 @@ func (a *goBlog) blogRouter(blog string, conf *configBlog) func(r chi.Router) {
 		// Stats
 		r.Group(a.blogStatsRouter(conf))
 
+		// Archive
+		r.Group(a.blogArchiveRouter(conf))
+
 		// Blogroll
 		r.Group(a.blogBlogrollRouter(conf))
 
@@ -177,6 +268,9 @@ func (a *goBlog) blogRouter(blog string, conf *configBlog) func(r chi.Router) {
 		// Contact
 		r.Group(a.blogContactRouter(conf))
 
+		// Newsletter
+		r.Group(a.blogNewsletterRouter(conf))
+
 		// Sitemap
 		r.Group(a.blogSitemapRouter(conf))
 
@@ -244,6 +338,20 @@ func (a *goBlog) blogTaxonomiesRouter(conf *configBlog) func(r chi.Router) {
 	}
 }
 
+// Blog - Series
+func (a *goBlog) blogSeriesRouter(conf *configBlog) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Use(
+			a.privateModeHandler,
+			a.cacheMiddleware,
+		)
+		seriesValuePath := conf.getRelativePath(seriesPath + "/{series}")
+		r.Get(seriesValuePath, a.serveSeries)
+		r.Get(seriesValuePath+feedPath, a.serveSeries)
+		r.Get(seriesValuePath+paginationPath, a.serveSeries)
+	}
+}
+
 // Blog - Dates
 func (a *goBlog) blogDatesRouter(conf *configBlog) func(r chi.Router) {
 	return func(r chi.Router) {
@@ -272,6 +380,12 @@ func (a *goBlog) dateRoutes(conf *configBlog, pathPrefix string) func(r chi.Rout
 		r.Get(dayPath, a.serveDate)
 		r.Get(dayPath+feedPath, a.serveDate)
 		r.Get(dayPath+paginationPath, a.serveDate)
+
+		// On this day across all years (DD-MM)
+		dayMonthPath := conf.getRelativePath(pathPrefix + `/x/x/{daymonth:(\d{2}-\d{2})}`)
+		r.Get(dayMonthPath, a.serveDate)
+		r.Get(dayMonthPath+feedPath, a.serveDate)
+		r.Get(dayMonthPath+paginationPath, a.serveDate)
 	}
 }
 
@@ -298,6 +412,28 @@ func (a *goBlog) blogPhotosRouter(conf *configBlog) func(r chi.Router) {
 	}
 }
 
+// Blog - Custom pages
+func (a *goBlog) blogPagesRouter(conf *configBlog) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Use(
+			a.privateModeHandler,
+			a.cacheMiddleware,
+		)
+		for _, page := range conf.Pages {
+			if page.Path == "" {
+				continue
+			}
+			r.Group(func(r chi.Router) {
+				pagePath := conf.getRelativePath(page.Path)
+				r.Use(middleware.WithValue(indexConfigKey, a.pageIndexConfig(conf, page)))
+				r.Get(pagePath, a.serveIndex)
+				r.Get(pagePath+feedPath, a.serveIndex)
+				r.Get(pagePath+paginationPath, a.serveIndex)
+			})
+		}
+	}
+}
+
 // Blog - Search
 func (a *goBlog) blogSearchRouter(conf *configBlog) func(r chi.Router) {
 	return func(r chi.Router) {
@@ -346,14 +482,18 @@ func (a *goBlog) blogOnThisDayRouter(conf *configBlog) func(r chi.Router) {
 }
 
 // Blog - Editor
-func (a *goBlog) blogEditorRouter(_ *configBlog) func(r chi.Router) {
+func (a *goBlog) blogEditorRouter(conf *configBlog) func(r chi.Router) {
 	return func(r chi.Router) {
-		r.Use(a.authMiddleware)
+		r.Use(a.blogUserMiddleware(conf, userRoleAuthor))
 		r.Get("/", a.serveEditor)
 		r.Post("/", a.serveEditorPost)
-		r.Get("/files", a.serveEditorFiles)
-		r.Post("/files/view", a.serveEditorFilesView)
-		r.Post("/files/delete", a.serveEditorFilesDelete)
+		r.Group(func(r chi.Router) {
+			// Media files are shared by all posts
+			r.Use(a.blogUserMiddleware(conf, userRoleAdmin))
+			r.Get("/files", a.serveEditorFiles)
+			r.Post("/files/view", a.serveEditorFilesView)
+			r.Post("/files/delete", a.serveEditorFilesDelete)
+		})
 		r.Get("/drafts", a.serveDrafts)
 		r.Get("/drafts"+feedPath, a.serveDrafts)
 		r.Get("/drafts"+paginationPath, a.serveDrafts)
@@ -385,7 +525,12 @@ func (a *goBlog) blogCommentsRouter(conf *configBlog) func(r chi.Router) {
 					middleware.WithValue(pathKey, commentsPath),
 				)
 				r.With(a.cacheMiddleware, noIndexHeader).Get("/{id:[0-9]+}", a.serveComment)
-				r.With(a.captchaMiddleware, bodylimit.BodyLimit(bodylimit.MB)).Post("/", a.createCommentFromRequest)
+				if !commentsConfig.DisableForm {
+					if commentsConfig.mode() == spamProtectionPoW {
+						r.Get(spamPoWPath, a.servePoWChallenge(&commentsConfig.configSpamProtection))
+					}
+					r.With(a.spamCaptchaMiddleware(&commentsConfig.configSpamProtection), bodylimit.BodyLimit(bodylimit.MB)).Post("/", a.createCommentFromRequest)
+				}
 				r.Group(func(r chi.Router) {
 					// Admin
 					r.Use(a.authMiddleware)
@@ -406,12 +551,21 @@ func (a *goBlog) blogStatsRouter(conf *configBlog) func(r chi.Router) {
 		if bsc := conf.BlogStats; bsc != nil && bsc.Enabled {
 			statsPath := conf.getRelativePath(defaultIfEmpty(bsc.Path, defaultBlogStatsPath))
 			r.Use(a.privateModeHandler)
-			r.With(a.cacheMiddleware).Get(statsPath, a.serveBlogStats)
+			r.With(middleware.WithValue(cacheExpirationKey, a.defaultCacheExpiration()), a.cacheMiddleware).Get(statsPath, a.serveBlogStats)
 			r.With(cacheLoggedIn, a.cacheMiddleware).Get(statsPath+blogStatsTablePath, a.serveBlogStatsTable)
 		}
 	}
 }
 
+// Blog - Archive
+func (a *goBlog) blogArchiveRouter(conf *configBlog) func(r chi.Router) {
+	return func(r chi.Router) {
+		if ac := conf.Archive; ac != nil && ac.Enabled {
+			r.With(a.privateModeHandler, a.cacheMiddleware).Get(conf.getRelativePath(defaultIfEmpty(ac.Path, defaultArchivePath)), a.serveArchive)
+		}
+	}
+}
+
 // Blog - Blogroll
 func (a *goBlog) blogBlogrollRouter(conf *configBlog) func(r chi.Router) {
 	return func(r chi.Router) {
@@ -437,6 +591,7 @@ func (a *goBlog) blogGeoMapRouter(conf *configBlog) func(r chi.Router) {
 			r.Get(mapPath, a.serveGeoMap)
 			r.Get(mapPath+geoMapTracksSubpath, a.serveGeoMapTracks)
 			r.Get(mapPath+geoMapLocationsSubpath, a.serveGeoMapLocations)
+			r.Get(mapPath+geoMapGeoJSONSubpath, a.serveGeoMapGeoJSON)
 		}
 	}
 }
@@ -447,9 +602,28 @@ func (a *goBlog) blogContactRouter(conf *configBlog) func(r chi.Router) {
 		if cc := conf.Contact; cc != nil && cc.Enabled {
 			contactPath := conf.getRelativePath(defaultIfEmpty(cc.Path, defaultContactPath))
 			r.Route(contactPath, func(r chi.Router) {
-				r.Use(a.privateModeHandler, a.cacheMiddleware)
-				r.Get("/", a.serveContactForm)
-				r.With(a.captchaMiddleware, bodylimit.BodyLimit(bodylimit.MB)).Post("/", a.sendContactSubmission)
+				r.Use(a.privateModeHandler)
+				r.With(a.cacheMiddleware).Get("/", a.serveContactForm)
+				if cc.mode() == spamProtectionPoW {
+					r.Get(spamPoWPath, a.servePoWChallenge(&cc.configSpamProtection))
+				}
+				r.With(a.spamCaptchaMiddleware(&cc.configSpamProtection), bodylimit.BodyLimit(bodylimit.MB)).Post("/", a.sendContactSubmission)
+			})
+		}
+	}
+}
+
+// Blog - Newsletter
+func (a *goBlog) blogNewsletterRouter(conf *configBlog) func(r chi.Router) {
+	return func(r chi.Router) {
+		if conf.Newsletter.enabled() {
+			r.Route(a.newsletterPath(conf), func(r chi.Router) {
+				r.Use(a.privateModeHandler)
+				r.With(a.cacheMiddleware).Get("/", a.serveNewsletterForm)
+				r.With(a.captchaMiddleware, bodylimit.BodyLimit(bodylimit.KB)).Post(newsletterSubscribeSubpath, a.subscribeNewsletter)
+				r.Get(newsletterConfirmSubpath, a.confirmNewsletter)
+				r.Get(newsletterUnsubscribeSubpath, a.serveNewsletterUnsubscribe)
+				r.With(bodylimit.BodyLimit(bodylimit.KB)).Post(newsletterUnsubscribeSubpath, a.unsubscribeNewsletter)
 			})
 		}
 	}
@@ -462,6 +636,8 @@ func (a *goBlog) blogSitemapRouter(conf *configBlog) func(r chi.Router) {
 		r.Get(conf.getRelativePath(sitemapBlogPath), a.serveSitemapBlog)
 		r.Get(conf.getRelativePath(sitemapBlogFeaturesPath), a.serveSitemapBlogFeatures)
 		r.Get(conf.getRelativePath(sitemapBlogArchivesPath), a.serveSitemapBlogArchives)
+		r.Get(conf.getRelativePath(sitemapBlogTaxonomiesPath), a.serveSitemapBlogTaxonomies)
+		r.Get(conf.getRelativePath(sitemapBlogPhotosPath), a.serveSitemapBlogPhotos)
 		r.Get(conf.getRelativePath(sitemapBlogPostsPath), a.serveSitemapBlogPosts)
 	}
 }
@@ -482,7 +658,14 @@ func (a *goBlog) blogSettingsRouter(_ *configBlog) func(r chi.Router) {
 		r.Post(settingsAddReplyContextPath, a.settingsAddReplyContext())
 		r.Post(settingsAddLikeTitlePath, a.settingsAddLikeTitle())
 		r.Post(settingsAddLikeContextPath, a.settingsAddLikeContext())
+		r.Post(settingsAddRepostTitlePath, a.settingsAddRepostTitle())
+		r.Post(settingsAddRepostContextPath, a.settingsAddRepostContext())
 		r.Post(settingsUpdateUserPath, a.settingsUpdateUser)
+		r.Post(settingsUpdateIdentitiesPath, a.settingsUpdateIdentities)
+		r.Post(settingsVerifyIdentitiesPath, a.settingsVerifyIdentities)
+		r.Post(settingsSetupTOTPPath, a.settingsSetupTOTP)
+		r.Post(settingsEnableTOTPPath, a.settingsEnableTOTP)
+		r.Post(settingsDisableTOTPPath, a.settingsDisableTOTP)
 		r.Post(settingsUpdateProfileImagePath, a.serveUpdateProfileImage)
 		r.Post(settingsDeleteProfileImagePath, a.serveDeleteProfileImage)
 	}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_listenUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "goblog.sock")
+
+	// Stale socket file from an unclean shutdown
+	stale, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	listener, err := listenUnixSocket(socket)
+	require.NoError(t, err)
+
+	fi, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), fi.Mode().Perm())
+
+	s := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "Hello")
+		}),
+	}
+	go func() {
+		_ = s.Serve(listener)
+	}()
+	defer s.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	resp, err := client.Get("http://localhost/")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "Hello", string(body))
+
+	// Other files are never removed
+	file := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(file, []byte("keep"), 0o600))
+	_, err = listenUnixSocket(file)
+	assert.ErrorContains(t, err, "isn't a socket")
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "keep", string(content))
+}
+
+func Test_servePostsAliasesRedirects(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+
+	// Build the router before any content exists, posts are resolved at request time
+	app.d = app.buildRouter()
+
+	err := app.createPost(&post{
+		Path:       "/posts/new",
+		Section:    "posts",
+		Published:  "2020-01-01T00:00:00Z",
+		Parameters: map[string][]string{"title": {"New Post"}, "aliases": {"/old"}},
+		Content:    "Test Content",
+	})
+	require.NoError(t, err)
+	shortPath, err := app.shortenPath("/posts/new")
+	require.NoError(t, err)
+	err = app.createPost(&post{
+		Path:    "/posts/deleted",
+		Section: "posts",
+		Content: "Deleted",
+	})
+	require.NoError(t, err)
+	require.NoError(t, app.deletePost("/posts/deleted"))
+	require.NoError(t, app.deletePost("/posts/deleted"))
+
+	for _, tc := range []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/posts/new", http.StatusOK, ""},
+		{"/old", http.StatusMovedPermanently, "/posts/new"},
+		{shortPath, http.StatusMovedPermanently, "/posts/new"},
+		{"/posts/deleted", http.StatusGone, ""},
+		{"/posts/unknown", http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+tc.path, nil))
+		assert.Equal(t, tc.status, rec.Code, tc.path)
+		assert.Equal(t, tc.location, rec.Header().Get("Location"), tc.path)
+	}
+}
+
+func Test_servePostsChangedPathRedirects(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+
+	app.d = app.buildRouter()
+
+	err := app.createPost(&post{
+		Path:       "/posts/first",
+		Section:    "posts",
+		Published:  "2020-01-01T00:00:00Z",
+		Parameters: map[string][]string{"title": {"Post"}},
+		Content:    "Test Content",
+	})
+	require.NoError(t, err)
+
+	// Rename the post
+	p, err := app.getPost("/posts/first")
+	require.NoError(t, err)
+	p.Path = "/posts/second"
+	require.NoError(t, app.replacePost(p, "/posts/first", p.Status, p.Visibility))
+
+	p, err = app.getPost("/posts/second")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/posts/first"}, p.Parameters[postAliasesParameter])
+
+	rec := httptest.NewRecorder()
+	app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/posts/first", nil))
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/posts/second", rec.Header().Get("Location"))
+
+	// Rename again, both old paths redirect
+	p.Path = "/posts/third"
+	require.NoError(t, app.replacePost(p, "/posts/second", p.Status, p.Visibility))
+
+	for _, old := range []string{"/posts/first", "/posts/second"} {
+		rec = httptest.NewRecorder()
+		app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+old, nil))
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code, old)
+		assert.Equal(t, "/posts/third", rec.Header().Get("Location"), old)
+	}
+
+	// Move back to the first path, it's no alias anymore
+	p, err = app.getPost("/posts/third")
+	require.NoError(t, err)
+	p.Path = "/posts/first"
+	require.NoError(t, app.replacePost(p, "/posts/third", p.Status, p.Visibility))
+
+	p, err = app.getPost("/posts/first")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/posts/second", "/posts/third"}, p.Parameters[postAliasesParameter])
+
+	rec = httptest.NewRecorder()
+	app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/posts/first", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
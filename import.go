@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// Import of Hugo content directories and Jekyll sites. Posts get the paths of their GoBlog
+// section (using the original slug and date) and the old URLs are added as aliases,
+// so they redirect to the new ones. Already existing posts are skipped.
+
+type importFormat string
+
+const (
+	importHugo   importFormat = "hugo"
+	importJekyll importFormat = "jekyll"
+)
+
+type importResult struct {
+	imported, skipped int
+}
+
+var jekyllPostFileRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// Front matter keys that are mapped to post fields or have no meaning for GoBlog
+var importIgnoredKeys = []string{"title", "date", "lastmod", "updated", "publishdate", "draft", "published", "slug", "url", "permalink", "aliases", "layout", "type", "weight", "path"}
+
+func (a *goBlog) importPosts(format importFormat, dir, blog string) (*importResult, error) {
+	blog = defaultIfEmpty(blog, a.cfg.DefaultBlog)
+	if _, ok := a.cfg.Blogs[blog]; !ok {
+		return nil, errors.New("blog doesn't exist")
+	}
+	result := &importResult{}
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			// Jekyll only has posts in the _posts and _drafts directories
+			if format == importJekyll && rel != "." && !strings.HasPrefix(rel, "_posts") && !strings.HasPrefix(rel, "_drafts") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := path.Ext(rel); ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+		if format == importJekyll && !strings.HasPrefix(rel, "_posts/") && !strings.HasPrefix(rel, "_drafts/") {
+			// Jekyll page
+			return nil
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var p *post
+		switch format {
+		case importHugo:
+			p, err = a.importHugoPost(rel, content)
+		case importJekyll:
+			p, err = a.importJekyllPost(rel, content)
+		default:
+			return errors.New("unknown import format")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if p == nil {
+			// Not a post
+			return nil
+		}
+		p.Blog = blog
+		if p.Section != "" {
+			if _, ok := a.cfg.Blogs[blog].Sections[p.Section]; !ok {
+				p.Section = a.cfg.Blogs[blog].DefaultSection
+			}
+		}
+		if err = a.checkPost(p, true); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if _, err = a.getPost(p.Path); err == nil {
+			log.Println("Skip import of", rel+":", "post", p.Path, "already exists")
+			result.skipped++
+			return nil
+		}
+		// Redirect from the old URLs
+		p.Parameters[postAliasesParameter] = lo.Uniq(lo.Filter(p.Parameters[postAliasesParameter], func(alias string, _ int) bool {
+			return alias != "" && alias != p.Path
+		}))
+		if len(p.Parameters[postAliasesParameter]) == 0 {
+			delete(p.Parameters, postAliasesParameter)
+		}
+		// Save without hooks, so imported posts aren't sent out again
+		if err = a.db.savePost(p, &postCreationOptions{new: true}); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		result.imported++
+		return nil
+	})
+	return result, err
+}
+
+func (a *goBlog) importHugoPost(rel string, content []byte) (*post, error) {
+	name := path.Base(rel)
+	if name == "_index.md" || name == "_index.markdown" {
+		// List page
+		return nil, nil
+	}
+	fm, body, err := parseImportFrontMatter(content)
+	if err != nil {
+		return nil, err
+	}
+	// Slug is the file name or the directory name of page bundles
+	dir := path.Dir(rel)
+	slug := strings.TrimSuffix(name, path.Ext(name))
+	if slug == "index" {
+		slug = path.Base(dir)
+		dir = path.Dir(dir)
+	}
+	if s := importString(fm["slug"]); s != "" {
+		slug = s
+	}
+	p := a.importPostFromFrontMatter(fm, body)
+	p.Slug = slug
+	p.Published = importString(lo.If(fm["date"] != nil, fm["date"]).Else(fm["publishdate"]))
+	p.Updated = importString(fm["lastmod"])
+	if importBool(fm["draft"]) {
+		p.Status = statusDraft
+	}
+	oldURL := importString(fm["url"])
+	if oldURL == "" {
+		oldURL = path.Join("/", dir, slug)
+	}
+	oldURL = importCleanURL(oldURL)
+	if dir == "." {
+		// Page without section, keep the URL
+		p.Path = oldURL
+	} else {
+		p.Section = strings.Split(dir, "/")[0]
+		p.Parameters[postAliasesParameter] = append(p.Parameters[postAliasesParameter], oldURL)
+	}
+	return p, nil
+}
+
+func (a *goBlog) importJekyllPost(rel string, content []byte) (*post, error) {
+	fm, body, err := parseImportFrontMatter(content)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(rel)
+	slug := strings.TrimSuffix(name, path.Ext(name))
+	p := a.importPostFromFrontMatter(fm, body)
+	var date string
+	if m := jekyllPostFileRegex.FindStringSubmatch(slug); m != nil {
+		date, slug = m[1], m[2]
+	}
+	if s := importString(fm["slug"]); s != "" {
+		slug = s
+	}
+	p.Slug = slug
+	p.Published = defaultIfEmpty(importString(fm["date"]), date)
+	p.Updated = importString(lo.If(fm["last_modified_at"] != nil, fm["last_modified_at"]).Else(fm["updated"]))
+	delete(p.Parameters, "last_modified_at")
+	if strings.HasPrefix(rel, "_drafts") || (fm["published"] != nil && !importBool(fm["published"])) {
+		p.Status = statusDraft
+	}
+	// Jekyll uses space separated lists
+	for _, key := range []string{"tags", "categories"} {
+		if values, ok := p.Parameters[key]; ok && len(values) == 1 {
+			p.Parameters[key] = strings.Fields(values[0])
+		}
+	}
+	if category := importString(fm["category"]); category != "" {
+		p.Parameters["categories"] = append(p.Parameters["categories"], category)
+		delete(p.Parameters, "category")
+	}
+	// Old URL with the default permalink style: /:categories/:year/:month/:day/:title.html
+	oldURL := importString(fm["permalink"])
+	if oldURL == "" && p.Status != statusDraft {
+		if published, err := time.Parse("2006-01-02", date); err == nil {
+			oldURL = path.Join(append(append([]string{"/"}, p.Parameters["categories"]...), published.Format("2006/01/02"), slug+".html")...)
+		}
+	}
+	if oldURL != "" {
+		p.Parameters[postAliasesParameter] = append(p.Parameters[postAliasesParameter], importCleanURL(oldURL))
+	}
+	// Default section of the blog
+	return p, nil
+}
+
+// Post with the content, the title, aliases and all other front matter values as parameters
+func (*goBlog) importPostFromFrontMatter(fm map[string]any, body string) *post {
+	p := &post{
+		Content:    body,
+		Parameters: map[string][]string{},
+	}
+	if title := importString(fm["title"]); title != "" {
+		p.Parameters["title"] = []string{title}
+	}
+	for _, alias := range importStrings(fm["aliases"]) {
+		p.Parameters[postAliasesParameter] = append(p.Parameters[postAliasesParameter], importCleanURL(alias))
+	}
+	for key, value := range fm {
+		if lo.Contains(importIgnoredKeys, strings.ToLower(key)) {
+			continue
+		}
+		if values := importStrings(value); len(values) > 0 {
+			p.Parameters[key] = values
+		}
+	}
+	return p
+}
+
+// Split YAML (---) or TOML (+++) front matter from the content
+func parseImportFrontMatter(content []byte) (map[string]any, string, error) {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	fm := map[string]any{}
+	for _, delimiter := range []string{"---", "+++"} {
+		if !strings.HasPrefix(text, delimiter+"\n") {
+			continue
+		}
+		raw, body, found := strings.Cut(strings.TrimPrefix(text, delimiter+"\n"), "\n"+delimiter)
+		if !found {
+			return nil, "", errors.New("front matter not closed")
+		}
+		var err error
+		if delimiter == "---" {
+			err = yaml.Unmarshal([]byte(raw), &fm)
+		} else {
+			err = toml.Unmarshal([]byte(raw), &fm)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return fm, strings.TrimPrefix(strings.TrimPrefix(body, "\n"), "\n"), nil
+	}
+	return fm, text, nil
+}
+
+func importString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+func importStrings(value any) []string {
+	switch v := value.(type) {
+	case []any:
+		return lo.Compact(lo.Map(v, func(i any, _ int) string { return importString(i) }))
+	case []string:
+		return lo.Compact(v)
+	case map[string]any:
+		// Nested values aren't supported
+		return nil
+	default:
+		return lo.Compact([]string{importString(v)})
+	}
+}
+
+func importBool(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// Path without trailing slash and index file, like GoBlog uses them
+func importCleanURL(u string) string {
+	u = "/" + strings.Trim(strings.TrimSpace(u), "/")
+	return strings.TrimSuffix(strings.TrimSuffix(u, "/index.html"), "/index.htm")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_import(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+
+	writeFiles := func(files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(file), 0777))
+			require.NoError(t, os.WriteFile(file, []byte(content), 0666))
+		}
+		return dir
+	}
+
+	t.Run("Hugo", func(t *testing.T) {
+		dir := writeFiles(map[string]string{
+			"posts/_index.md":          "---\ntitle: Posts\n---\n",
+			"posts/first-post.md":      "---\ntitle: First post\ndate: 2020-01-02T10:00:00Z\nlastmod: 2020-01-03T10:00:00Z\ntags:\n  - One\n  - Two\naliases:\n  - /old/first/\ncustom: value\n---\n\nFirst content",
+			"posts/bundle/index.md":    "+++\ntitle = \"Bundle\"\ndate = 2021-05-06T07:08:09Z\ndraft = true\ntags = [\"Three\"]\n+++\nBundle content",
+			"posts/bundle/image.jpg":   "image",
+			"about.md":                 "---\ntitle: About\n---\nAbout me",
+			"notes/custom-url.md":      "---\ntitle: Custom\ndate: 2022-01-01T00:00:00Z\nslug: my-slug\nurl: /custom/url/\n---\nCustom",
+			"notes/no-frontmatter.txt": "Ignored",
+		})
+
+		result, err := app.importPosts(importHugo, dir, "")
+		require.NoError(t, err)
+		assert.Equal(t, 4, result.imported)
+		assert.Equal(t, 0, result.skipped)
+
+		// Post in section, with the path of the GoBlog section
+		p, err := app.getPost("/posts/2020/01/first-post")
+		require.NoError(t, err)
+		assert.Equal(t, "First content", p.Content)
+		assert.Equal(t, "First post", p.Title())
+		assert.Equal(t, "posts", p.Section)
+		assert.Equal(t, statusPublished, p.Status)
+		assert.Equal(t, []string{"One", "Two"}, p.Parameters["tags"])
+		assert.Equal(t, []string{"value"}, p.Parameters["custom"])
+		assert.ElementsMatch(t, []string{"/old/first", "/posts/first-post"}, p.Parameters["aliases"])
+		assert.Contains(t, p.Updated, "2020-01-03")
+
+		// Page bundle with TOML front matter
+		p, err = app.getPost("/posts/2021/05/bundle")
+		require.NoError(t, err)
+		assert.Equal(t, statusDraft, p.Status)
+		assert.Equal(t, []string{"Three"}, p.Parameters["tags"])
+
+		// Page without section keeps its path
+		p, err = app.getPost("/about")
+		require.NoError(t, err)
+		assert.Equal(t, "About me", p.Content)
+		assert.Empty(t, p.Parameters["aliases"])
+
+		// Unknown section uses the default section, custom URL becomes alias
+		p, err = app.getPost("/posts/2022/01/my-slug")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/custom/url"}, p.Parameters["aliases"])
+
+		// Importing again skips existing posts
+		result, err = app.importPosts(importHugo, dir, "")
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.imported)
+		assert.Equal(t, 4, result.skipped)
+	})
+
+	t.Run("Jekyll", func(t *testing.T) {
+		dir := writeFiles(map[string]string{
+			"_posts/2019-03-04-hello-world.md": "---\nlayout: post\ntitle: Hello World\ncategories: news updates\ntags: [a, b]\n---\nHello",
+			"_drafts/unfinished.md":            "---\ntitle: Unfinished\n---\nDraft",
+			"_posts/2019-05-06-hidden.md":      "---\ntitle: Hidden\npublished: false\n---\nHidden",
+			"about.md":                         "---\ntitle: About\n---\nNot imported",
+		})
+
+		result, err := app.importPosts(importJekyll, dir, "")
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.imported)
+
+		p, err := app.getPost("/posts/2019/03/hello-world")
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", p.Content)
+		assert.Equal(t, []string{"news", "updates"}, p.Parameters["categories"])
+		assert.Equal(t, []string{"a", "b"}, p.Parameters["tags"])
+		assert.Equal(t, []string{"/news/updates/2019/03/04/hello-world.html"}, p.Parameters["aliases"])
+		assert.Empty(t, p.Parameters["layout"])
+
+		p, err = app.getPost("/posts/2019/05/hidden")
+		require.NoError(t, err)
+		assert.Equal(t, statusDraft, p.Status)
+
+		posts, err := app.getPosts(&postsRequestConfig{status: []postStatus{statusDraft}, search: "Draft"})
+		require.NoError(t, err)
+		if assert.Len(t, posts, 1) {
+			assert.True(t, strings.HasSuffix(posts[0].Path, "/unfinished"))
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := app.importPosts(importHugo, t.TempDir(), "unknown")
+		assert.Error(t, err)
+
+		dir := writeFiles(map[string]string{"posts/broken.md": "---\ntitle: Broken\n"})
+		_, err = app.importPosts(importHugo, dir, "")
+		assert.ErrorContains(t, err, "posts/broken.md")
+	})
+}
@@ -17,8 +17,8 @@ func (a *goBlog) initIndexNow() {
 	}
 	// Add hooks
 	hook := func(p *post) {
-		// Check if post is published
-		if !p.isPublicPublishedSectionPost() {
+		// Check if post is published and not in a protected blog
+		if !p.isPublicPublishedSectionPost() || a.isProtectedBlog(p.Blog) {
 			return
 		}
 		// Send IndexNow request
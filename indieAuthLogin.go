@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/hacdias/indieauth/v3"
+	"github.com/samber/lo"
+)
+
+// Login with IndieAuth: GoBlog acts as IndieAuth client and lets the configured identities (like the own domain)
+// log in using their authorization endpoint, so no password is needed
+
+const (
+	indieAuthLoginPath         = "/login/indieauth"
+	indieAuthLoginCallbackPath = indieAuthLoginPath + "/callback"
+	indieAuthLoginSession      = "ia"
+)
+
+func (a *goBlog) indieAuthLoginEnabled() bool {
+	return len(a.cfg.User.IndieAuthLogin) > 0
+}
+
+func (a *goBlog) indieAuthLoginClient() *indieauth.Client {
+	return indieauth.NewClient(indieauth.CanonicalizeURL(a.getFullAddress("/")), a.getFullAddress(indieAuthLoginCallbackPath), a.httpClient)
+}
+
+// Check if the identity is one of the configured ones
+func (a *goBlog) indieAuthLoginAllowed(me string) bool {
+	me = indieauth.CanonicalizeURL(me)
+	return lo.ContainsBy(a.cfg.User.IndieAuthLogin, func(identity string) bool {
+		return indieauth.CanonicalizeURL(identity) == me
+	})
+}
+
+// Only redirect to local paths after the login
+func indieAuthLoginRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
+
+// Start the login: discover the authorization endpoint of the identity and redirect to it
+func (a *goBlog) serveIndieAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if a.serveLoginLockedOut(w, r) {
+		return
+	}
+	me := r.FormValue("me")
+	if !a.indieAuthLoginAllowed(me) {
+		a.serveError(w, r, "Identity not allowed to log in", http.StatusForbidden)
+		return
+	}
+	authInfo, authURL, err := a.indieAuthLoginClient().Authenticate(me, "")
+	if err != nil {
+		a.serveError(w, r, "Failed to discover the authorization endpoint: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	authInfoJSON, err := json.Marshal(authInfo)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ses, err := a.loginSessions.Get(r, indieAuthLoginSession)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ses.Values["authinfo"] = string(authInfoJSON)
+	ses.Values["redirect"] = indieAuthLoginRedirect(r.FormValue("redirect"))
+	if err = a.loginSessions.Save(r, w, ses); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Finish the login: verify the callback, exchange the code for the profile and create the login session
+func (a *goBlog) serveIndieAuthLoginCallback(w http.ResponseWriter, r *http.Request) {
+	if a.serveLoginLockedOut(w, r) {
+		return
+	}
+	ses, err := a.loginSessions.Get(r, indieAuthLoginSession)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	authInfoJSON, _ := ses.Values["authinfo"].(string)
+	redirect, _ := ses.Values["redirect"].(string)
+	// The state can only be used once
+	_ = a.loginSessions.Delete(r, w, ses)
+	authInfo := &indieauth.AuthInfo{}
+	if err = json.Unmarshal([]byte(authInfoJSON), authInfo); err != nil || authInfo.State == "" {
+		a.serveError(w, r, "No IndieAuth login in progress", http.StatusBadRequest)
+		return
+	}
+	if err = a.checkIndieAuthLoginCallback(r, authInfo); err != nil {
+		a.loginFailed(r, "IndieAuth")
+		a.serveError(w, r, "IndieAuth login failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	a.loginSucceeded(r)
+	loginSes, err := a.loginSessions.Get(r, "l")
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	loginSes.Values["login"] = true
+	if err = a.loginSessions.Save(r, w, loginSes); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, indieAuthLoginRedirect(redirect), http.StatusFound)
+}
+
+func (a *goBlog) checkIndieAuthLoginCallback(r *http.Request, authInfo *indieauth.AuthInfo) error {
+	client := a.indieAuthLoginClient()
+	code, err := client.ValidateCallback(authInfo, r)
+	if err != nil {
+		return err
+	}
+	profile, err := client.FetchProfile(authInfo, code)
+	if err != nil {
+		return err
+	}
+	// The authorization endpoint was discovered for this identity, so it has to confirm exactly this identity
+	if indieauth.CanonicalizeURL(profile.Me) != indieauth.CanonicalizeURL(authInfo.Me) || !a.indieAuthLoginAllowed(profile.Me) {
+		return errors.New("identity not allowed to log in")
+	}
+	return nil
+}
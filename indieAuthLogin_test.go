@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_indieAuthLoginRedirect(t *testing.T) {
+	assert.Equal(t, "/editor?a=b", indieAuthLoginRedirect("/editor?a=b"))
+	assert.Equal(t, "/", indieAuthLoginRedirect(""))
+	assert.Equal(t, "/", indieAuthLoginRedirect("https://example.org/"))
+	assert.Equal(t, "/", indieAuthLoginRedirect("//example.org/"))
+	assert.Equal(t, "/", indieAuthLoginRedirect("/\\example.org/"))
+}
+
+func Test_indieAuthLogin(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.User.IndieAuthLogin = []string{"https://me.example"}
+	})
+
+	// Identity with its own authorization server
+	confirmedMe := "https://me.example/"
+	h.fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host + r.URL.Path {
+		case "me.example/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><head><link rel="indieauth-metadata" href="https://auth.example/metadata"></head></html>`))
+		case "auth.example/metadata":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"issuer":                 "https://auth.example/",
+				"authorization_endpoint": "https://auth.example/auth",
+				"token_endpoint":         "https://auth.example/token",
+			})
+		case "auth.example/auth":
+			_ = r.ParseForm()
+			if r.Form.Get("code") != "abc" || r.Form.Get("code_verifier") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"me": confirmedMe})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	// The login form offers the identity
+	_, _, body := h.get("/editor")
+	assert.Contains(t, body, indieAuthLoginPath)
+	assert.Contains(t, body, "https://me.example")
+
+	startLogin := func(me string) (status int, location, cookie string) {
+		require.NoError(t, h.request(indieAuthLoginPath).BodyForm(url.Values{
+			"me":       {me},
+			"redirect": {"/editor"},
+		}).AddValidator(func(r *http.Response) error {
+			status, location = r.StatusCode, r.Header.Get("Location")
+			cookie = strings.Split(r.Header.Get("Set-Cookie"), ";")[0]
+			return nil
+		}).Fetch(context.Background()))
+		return
+	}
+	callback := func(query url.Values, cookie string) (status int, location, loginCookie string) {
+		require.NoError(t, h.request(indieAuthLoginCallbackPath+"?"+query.Encode()).Header("Cookie", cookie).
+			AddValidator(func(r *http.Response) error {
+				status, location = r.StatusCode, r.Header.Get("Location")
+				for _, c := range r.Cookies() {
+					if c.Name == "l" {
+						loginCookie = c.Name + "=" + c.Value
+					}
+				}
+				return nil
+			}).Fetch(context.Background()))
+		return
+	}
+	editor := func(cookie string) (body string) {
+		require.NoError(t, h.request("/editor").Header("Cookie", cookie).ToString(&body).Fetch(context.Background()))
+		return
+	}
+
+	// Other identities can't log in
+	status, _, _ := startLogin("https://other.example/")
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Successful login
+	status, location, cookie := startLogin("https://me.example/")
+	require.Equal(t, http.StatusFound, status)
+	authURL, err := url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, "auth.example", authURL.Host)
+	assert.Equal(t, "https://example.com/", authURL.Query().Get("client_id"))
+	assert.Equal(t, "https://example.com"+indieAuthLoginCallbackPath, authURL.Query().Get("redirect_uri"))
+	state := authURL.Query().Get("state")
+	require.NotEmpty(t, state)
+
+	status, location, loginCookie := callback(url.Values{"code": {"abc"}, "state": {state}, "iss": {"https://auth.example/"}}, cookie)
+	assert.Equal(t, http.StatusFound, status)
+	assert.Equal(t, "/editor", location)
+	require.NotEmpty(t, loginCookie)
+	assert.NotContains(t, editor(loginCookie), `name="loginaction"`)
+
+	// The state can't be used again
+	status, _, _ = callback(url.Values{"code": {"abc"}, "state": {state}, "iss": {"https://auth.example/"}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Wrong state
+	_, _, cookie = startLogin("https://me.example/")
+	status, _, _ = callback(url.Values{"code": {"abc"}, "state": {"wrong"}, "iss": {"https://auth.example/"}}, cookie)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// The authorization server confirms another identity
+	confirmedMe = "https://other.example/"
+	status, location, cookie = startLogin("https://me.example/")
+	require.Equal(t, http.StatusFound, status)
+	authURL, err = url.Parse(location)
+	require.NoError(t, err)
+	status, _, loginCookie = callback(url.Values{"code": {"abc"}, "state": {authURL.Query().Get("state")}, "iss": {"https://auth.example/"}}, cookie)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Empty(t, loginCookie)
+}
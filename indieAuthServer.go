@@ -62,7 +62,7 @@ func (a *goBlog) indieAuthRequest(w http.ResponseWriter, r *http.Request) {
 	}
 	// Render page that let's the user authorize the app
 	a.render(w, r, a.renderIndieAuth, &renderData{
-		Data: iareq,
+		Data: &indieAuthRenderData{req: iareq},
 	})
 }
 
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Integration tests of the main flows, using the test harness
+
+func Test_integrationMicropub(t *testing.T) {
+	h := newTestHarness(t)
+
+	// Create
+	var location string
+	require.NoError(t, h.tokenRequest("/micropub", "create", "update").
+		BodyForm(url.Values{"h": {"entry"}, "name": {"Micropub post"}, "content": {"Created via Micropub"}, "category": {"test"}}).
+		AddValidator(func(r *http.Response) error {
+			assert.Equal(t, http.StatusAccepted, r.StatusCode)
+			location = r.Header.Get("Location")
+			return nil
+		}).
+		Fetch(context.Background()))
+	require.True(t, strings.HasPrefix(location, testHarnessAddress+"/"))
+	path := strings.TrimPrefix(location, testHarnessAddress)
+
+	status, _, body := h.get(path)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Micropub post")
+	assert.Contains(t, body, "Created via Micropub")
+	p, err := h.app.getPost(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test"}, p.Parameters["tags"])
+
+	// Update
+	require.NoError(t, h.tokenRequest("/micropub", "update").
+		BodyJSON(map[string]any{
+			"action":  "update",
+			"url":     location,
+			"replace": map[string][]string{"content": {"Updated via Micropub"}},
+		}).
+		CheckStatus(http.StatusNoContent).
+		Fetch(context.Background()))
+	_, _, body = h.get(path)
+	assert.Contains(t, body, "Updated via Micropub")
+	assert.NotContains(t, body, "Created via Micropub")
+
+	// Missing scope
+	require.NoError(t, h.tokenRequest("/micropub", "create").
+		BodyJSON(map[string]any{"action": "update", "url": location, "replace": map[string][]string{"content": {"Nope"}}}).
+		CheckStatus(http.StatusForbidden).
+		Fetch(context.Background()))
+}
+
+func Test_integrationActivityPubFollow(t *testing.T) {
+	h := newTestHarness(t, withActivityPub)
+
+	// The remote actor is the blog itself, outgoing activities are recorded
+	rec := httptest.NewRecorder()
+	h.app.serveActivityStreams(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "default")
+	actor := rec.Body.Bytes()
+	var sentMutex sync.Mutex
+	var sent []string
+	h.fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			buf := &bytes.Buffer{}
+			_, _ = buf.ReadFrom(r.Body)
+			sentMutex.Lock()
+			sent = append(sent, buf.String())
+			sentMutex.Unlock()
+			return
+		}
+		_, _ = w.Write(actor)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, testHarnessAddress+"/activitypub/inbox/default", strings.NewReader(
+		`{"type":"Follow","id":"https://example.com/follow/1","actor":"https://example.com","object":"https://example.com"}`,
+	))
+	require.NoError(t, h.app.signRequest(req, testHarnessAddress))
+	assert.Equal(t, http.StatusOK, h.serve(req).Code)
+
+	followers, err := h.app.db.apGetAllFollowers("default")
+	require.NoError(t, err)
+	require.Len(t, followers, 1)
+	assert.Equal(t, "https://example.com", followers[0].follower)
+	assert.Equal(t, "https://example.com/activitypub/inbox/default", followers[0].inbox)
+
+	// The accept is queued or already sent
+	accepted := func() bool {
+		for _, content := range h.dequeueAll("ap") {
+			r := &apRequest{}
+			require.NoError(t, gob.NewDecoder(bytes.NewReader(content)).Decode(r))
+			if strings.Contains(string(r.Activity), `"type":"Accept"`) {
+				return true
+			}
+		}
+		sentMutex.Lock()
+		defer sentMutex.Unlock()
+		for _, s := range sent {
+			if strings.Contains(s, `"type":"Accept"`) {
+				return true
+			}
+		}
+		return false
+	}
+	assert.True(t, accepted())
+
+	// Unfollow
+	req = httptest.NewRequest(http.MethodPost, testHarnessAddress+"/activitypub/inbox/default", strings.NewReader(
+		`{"type":"Undo","actor":"https://example.com","object":{"type":"Follow","actor":"https://example.com","object":"https://example.com"}}`,
+	))
+	require.NoError(t, h.app.signRequest(req, testHarnessAddress))
+	assert.Equal(t, http.StatusOK, h.serve(req).Code)
+	followers, err = h.app.db.apGetAllFollowers("default")
+	require.NoError(t, err)
+	assert.Empty(t, followers)
+}
+
+func Test_integrationWebmention(t *testing.T) {
+	h := newTestHarness(t)
+	h.createPost(&post{Path: "/mentioned", Content: "Mention me"})
+
+	// Source of the mention
+	h.fc.setFakeResponse(http.StatusOK, `<html><body><div class="h-entry"><p class="e-content">I like <a href="https://example.com/mentioned">this post</a></p><a class="p-author h-card" href="https://source.example">Source Author</a></div></body></html>`)
+
+	// Receive
+	require.NoError(t, h.request("/webmention").
+		BodyForm(url.Values{"source": {"https://source.example/reply"}, "target": {"https://example.com/mentioned"}}).
+		CheckStatus(http.StatusAccepted).
+		Fetch(context.Background()))
+	// Unknown targets aren't accepted
+	require.NoError(t, h.request("/webmention").
+		BodyForm(url.Values{"source": {"https://source.example/reply"}, "target": {"https://other.example/"}}).
+		CheckStatus(http.StatusBadRequest).
+		Fetch(context.Background()))
+
+	// Verify the queued mention
+	queued := h.dequeueAll("wm")
+	require.Len(t, queued, 1)
+	m := &mention{}
+	require.NoError(t, gob.NewDecoder(bytes.NewReader(queued[0])).Decode(m))
+	require.NoError(t, h.app.verifyMention(m))
+
+	mentions, err := h.app.db.getWebmentions(&webmentionsRequestConfig{target: "https://example.com/mentioned"})
+	require.NoError(t, err)
+	require.Len(t, mentions, 1)
+	assert.Equal(t, "Source Author", mentions[0].Author)
+	assert.Equal(t, webmentionStatusVerified, mentions[0].Status)
+
+	// Shown on the post after approval
+	require.NoError(t, h.app.db.approveWebmentionId(mentions[0].ID))
+	h.app.cfg.Blogs["default"].Comments = &configComments{Enabled: true}
+	h.app.cache.purge()
+	_, _, body := h.get("/mentioned")
+	assert.Contains(t, body, "https://source.example/reply")
+}
+
+func Test_integrationFeeds(t *testing.T) {
+	h := newTestHarness(t)
+	h.createPost(&post{Path: "/first", Published: "2023-01-01T10:00:00Z", Content: "First content", Parameters: map[string][]string{"title": {"First post"}}})
+	h.createPost(&post{Path: "/second", Published: "2023-01-02T10:00:00Z", Content: "Second content", Parameters: map[string][]string{"title": {"Second post"}}})
+	h.createPost(&post{Path: "/draft", Status: statusDraft, Content: "Draft content", Parameters: map[string][]string{"title": {"Draft post"}}})
+
+	for feed, contentType := range map[string]string{".rss": "application/rss+xml", ".atom": "application/atom+xml", ".json": "application/feed+json"} {
+		status, header, body := h.get("/" + feed)
+		assert.Equal(t, http.StatusOK, status, feed)
+		assert.Contains(t, header.Get("Content-Type"), contentType, feed)
+		assert.Contains(t, body, "First post", feed)
+		assert.Contains(t, body, "https://example.com/second", feed)
+		assert.NotContains(t, body, "Draft post", feed)
+		// Newest first
+		assert.Less(t, strings.Index(body, "Second post"), strings.Index(body, "First post"), feed)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/carlmjohnson/requests"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
+)
+
+// Link archiving: after publishing or updating a post, every external link is archived (with the Wayback Machine
+// or as a local copy in the media storage) and the archive URL is saved in a post parameter. The links are checked
+// daily, links that return 404 or 410 get marked as broken and are annotated with (or replaced by) the archive link.
+
+const (
+	linkArchiveParameter   = "linkarchive" // "<link> <archive>", with " broken" appended when the link is gone
+	linkArchiveQueueName   = "linkarchive"
+	linkArchiveMaxAttempts = 3
+	linkArchiveBrokenFlag  = "broken"
+	linkArchiveCheckKey    = "linkarchive_lastcheck"
+
+	linkArchiveCheckInterval = 24 * time.Hour
+	linkArchiveMaxSize       = 10 * 1024 * 1024
+
+	linkArchiveBrokenAnnotate = "annotate"
+	linkArchiveBrokenRewrite  = "rewrite"
+	linkArchiveBrokenNone     = "none"
+
+	waybackMachineAddress = "https://web.archive.org"
+)
+
+// Types of archived files that can't run scripts, with the extension to save them with
+var linkArchivePassiveTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/avif":      ".avif",
+	"image/gif":       ".gif",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"text/plain":      ".txt",
+}
+
+type linkArchiveEntry struct {
+	link, archive string
+	broken        bool
+}
+
+func (e *linkArchiveEntry) String() string {
+	s := e.link + " " + e.archive
+	if e.broken {
+		s += " " + linkArchiveBrokenFlag
+	}
+	return s
+}
+
+func parseLinkArchiveEntries(values []string) (entries []*linkArchiveEntry) {
+	for _, value := range values {
+		fields := strings.Fields(value)
+		if len(fields) < 2 {
+			continue
+		}
+		entries = append(entries, &linkArchiveEntry{
+			link:    fields[0],
+			archive: fields[1],
+			broken:  len(fields) > 2 && fields[2] == linkArchiveBrokenFlag,
+		})
+	}
+	return entries
+}
+
+func linkArchiveValues(entries []*linkArchiveEntry) []string {
+	return lo.Map(entries, func(e *linkArchiveEntry, _ int) string { return e.String() })
+}
+
+func (a *goBlog) linkArchiveEnabled() bool {
+	return a.cfg.LinkArchive != nil && a.cfg.LinkArchive.Enabled
+}
+
+func (a *goBlog) initLinkArchive() {
+	if !a.linkArchiveEnabled() {
+		return
+	}
+	hook := func(p *post) {
+		if err := a.enqueueLinkArchive(&linkArchiveRequest{Path: p.Path}); err != nil {
+			log.Println("Link archive: failed to enqueue", p.Path+":", err.Error())
+		}
+	}
+	a.pPostHooks = append(a.pPostHooks, hook)
+	a.pUpdateHooks = append(a.pUpdateHooks, hook)
+	a.hourlyHooks = append(a.hourlyHooks, a.checkArchivedLinksOnce)
+	a.listenOnQueue(linkArchiveQueueName, 30*time.Second, a.processLinkArchiveQueueItem)
+}
+
+type linkArchiveRequest struct {
+	Path string
+	Try  int
+}
+
+func (a *goBlog) enqueueLinkArchive(r *linkArchiveRequest) error {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	if err := gob.NewEncoder(buf).Encode(r); err != nil {
+		return err
+	}
+	return a.enqueue(linkArchiveQueueName, buf.Bytes(), time.Now())
+}
+
+func (a *goBlog) processLinkArchiveQueueItem(ctx context.Context, qi *queueItem, dequeue func(), reschedule func(time.Duration)) {
+	var r linkArchiveRequest
+	if err := gob.NewDecoder(bytes.NewReader(qi.content)).Decode(&r); err != nil {
+		log.Println("Link archive: failed to decode queue item:", err.Error())
+		dequeue()
+		return
+	}
+	if err := a.archivePostLinks(ctx, r.Path); err != nil {
+		if ctx.Err() != nil {
+			// Shutting down, keep request in the queue
+			return
+		}
+		if r.Try++; r.Try < linkArchiveMaxAttempts {
+			log.Println("Link archive: failed to archive links of", r.Path+", trying again later:", err.Error())
+			buf := bufferpool.Get()
+			_ = gob.NewEncoder(buf).Encode(&r)
+			qi.content = buf.Bytes()
+			reschedule(time.Duration(r.Try) * time.Hour)
+			bufferpool.Put(buf)
+			return
+		}
+		log.Println("Link archive: failed to archive links of", r.Path+":", err.Error())
+	}
+	dequeue()
+}
+
+// Archive the external links of the post that aren't archived yet, returns the last error
+func (a *goBlog) archivePostLinks(ctx context.Context, path string) error {
+	p, err := a.getPost(path)
+	if errors.Is(err, errPostNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if p.Status != statusPublished || p.Visibility == visibilityPrivate {
+		return nil
+	}
+	pairs, err := a.allLinksToCheck(p)
+	if err != nil {
+		return err
+	}
+	old := parseLinkArchiveEntries(p.Parameters[linkArchiveParameter])
+	// The rendered post can contain the archive links of broken links instead of or next to the original links
+	originals := lo.SliceToMap(old, func(e *linkArchiveEntry) (string, string) { return e.archive, e.link })
+	links := lo.Uniq(lo.FilterMap(pairs, func(sp *stringPair, _ int) (string, bool) {
+		return lo.ValueOr(originals, sp.Second, sp.Second), strings.HasPrefix(sp.Second, "http://") || strings.HasPrefix(sp.Second, "https://")
+	}))
+	// Only keep the entries of links that are still in the post, rewritten local archive links count as internal links
+	entries := lo.Filter(old, func(e *linkArchiveEntry, _ int) bool { return e.broken || lo.Contains(links, e.link) })
+	var lastErr error
+	for _, link := range links {
+		if lo.ContainsBy(entries, func(e *linkArchiveEntry) bool { return e.link == link }) {
+			continue
+		}
+		archive, err := a.archiveLink(ctx, link)
+		if err != nil {
+			log.Println("Link archive: failed to archive", link+":", err.Error())
+			lastErr = err
+			continue
+		}
+		entries = append(entries, &linkArchiveEntry{link: link, archive: archive})
+	}
+	if values := linkArchiveValues(entries); !lo.Every(linkArchiveValues(old), values) || len(values) != len(old) {
+		if err = a.db.replacePostParam(p.Path, linkArchiveParameter, values); err != nil {
+			return err
+		}
+		a.cache.purgePost(p.Path)
+	}
+	return lastErr
+}
+
+// Archive the link and return the URL of the archived version
+func (a *goBlog) archiveLink(ctx context.Context, link string) (string, error) {
+	if a.cfg.LinkArchive.Local {
+		return a.archiveLinkLocally(ctx, link)
+	}
+	return a.archiveLinkWithWaybackMachine(ctx, link)
+}
+
+// Ask the Wayback Machine to save the link, the response redirects to the snapshot or has it as Content-Location
+func (a *goBlog) archiveLinkWithWaybackMachine(ctx context.Context, link string) (archive string, err error) {
+	err = requests.URL(waybackMachineAddress + "/save/" + link).Client(a.httpClient).
+		Handle(func(res *http.Response) error {
+			defer res.Body.Close()
+			_, _ = io.Copy(io.Discard, res.Body)
+			if cl := res.Header.Get("Content-Location"); strings.HasPrefix(cl, "/web/") {
+				archive = waybackMachineAddress + cl
+			} else if u := res.Request.URL; strings.HasPrefix(u.String(), waybackMachineAddress+"/web/") {
+				archive = u.String()
+			}
+			return nil
+		}).
+		Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if archive == "" {
+		// Latest snapshot
+		archive = waybackMachineAddress + "/web/" + link
+	}
+	return archive, nil
+}
+
+// Save a copy of the linked page in the media storage, only from public addresses
+func (a *goBlog) archiveLinkLocally(ctx context.Context, link string) (string, error) {
+	var body []byte
+	var contentType string
+	err := requests.URL(link).Client(a.publicHttpClient).
+		Handle(func(res *http.Response) (err error) {
+			defer res.Body.Close()
+			contentType = res.Header.Get("Content-Type")
+			body, err = io.ReadAll(io.LimitReader(res.Body, linkArchiveMaxSize))
+			return err
+		}).
+		Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Only passive types keep their extension, everything else is saved as HTML,
+	// which is served sandboxed, so archived files can't run scripts on the blog's domain
+	ext := ".html"
+	if mediaType, _, _ := mime.ParseMediaType(contentType); linkArchivePassiveTypes[mediaType] != "" {
+		ext = linkArchivePassiveTypes[mediaType]
+	}
+	return a.saveMediaFile(fmt.Sprintf("%x%s", sha256.Sum256(body), ext), bytes.NewReader(body))
+}
+
+func (a *goBlog) checkArchivedLinksOnce() {
+	last, err := a.db.retrievePersistentCache(linkArchiveCheckKey)
+	if err != nil {
+		log.Println("Link archive: failed to get last check:", err.Error())
+		return
+	}
+	if lastTime, err := time.Parse(time.RFC3339, string(last)); err == nil && time.Since(lastTime) < linkArchiveCheckInterval {
+		return
+	}
+	if err = a.checkArchivedLinks(context.Background()); err != nil {
+		log.Println("Link archive: failed to check links:", err.Error())
+		return
+	}
+	_ = a.db.cachePersistently(linkArchiveCheckKey, []byte(time.Now().UTC().Format(time.RFC3339)))
+}
+
+// Check the archived links of all posts and mark them as broken (or not broken anymore)
+func (a *goBlog) checkArchivedLinks(ctx context.Context) error {
+	posts, err := a.getPosts(&postsRequestConfig{parameter: linkArchiveParameter, withOnlyParameters: []string{linkArchiveParameter}})
+	if err != nil {
+		return err
+	}
+	status := map[string]bool{} // broken by link, links can be in multiple posts
+	for _, p := range posts {
+		entries := parseLinkArchiveEntries(p.Parameters[linkArchiveParameter])
+		changed := false
+		for _, e := range entries {
+			broken, ok := status[e.link]
+			if !ok {
+				broken = a.linkBroken(ctx, e.link)
+				status[e.link] = broken
+			}
+			if broken != e.broken {
+				e.broken, changed = broken, true
+			}
+		}
+		if changed {
+			if err = a.db.replacePostParam(p.Path, linkArchiveParameter, linkArchiveValues(entries)); err != nil {
+				return err
+			}
+			a.cache.purgePost(p.Path)
+		}
+	}
+	return ctx.Err()
+}
+
+// Only links that are definitely gone count as broken, not temporary errors
+func (a *goBlog) linkBroken(ctx context.Context, link string) bool {
+	var code int
+	_ = requests.URL(link).Client(a.httpClient).
+		AddValidator(func(res *http.Response) error {
+			code = res.StatusCode
+			return nil
+		}).
+		Fetch(ctx)
+	return code == http.StatusNotFound || code == http.StatusGone
+}
+
+// Annotate (or rewrite) the broken links in the rendered post content
+func (a *goBlog) linkArchiveFallback(content []byte, p *post) []byte {
+	if !a.linkArchiveEnabled() || a.cfg.LinkArchive.BrokenLinks == linkArchiveBrokenNone {
+		return content
+	}
+	broken := lo.Filter(parseLinkArchiveEntries(p.Parameters[linkArchiveParameter]), func(e *linkArchiveEntry, _ int) bool { return e.broken })
+	if len(broken) == 0 {
+		return content
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return content
+	}
+	for _, e := range broken {
+		e := e
+		doc.Find("a[href]").FilterFunction(func(_ int, s *goquery.Selection) bool {
+			return s.AttrOr("href", "") == e.link
+		}).Each(func(_ int, s *goquery.Selection) {
+			if a.cfg.LinkArchive.BrokenLinks == linkArchiveBrokenRewrite {
+				s.SetAttr("href", e.archive)
+				return
+			}
+			archived := bufferpool.Get()
+			defer bufferpool.Put(archived)
+			_, _ = fmt.Fprintf(archived, ` (<a href="%s" class="archived-link" rel="nofollow noopener noreferrer" target="_blank">%s</a>)`,
+				html.EscapeString(e.archive), html.EscapeString(a.ts.GetTemplateStringVariant(a.postLang(p), "archivedlink")))
+			s.AfterHtml(archived.String())
+		})
+	}
+	result, err := doc.Find("body").Html()
+	if err != nil {
+		return content
+	}
+	return []byte(result)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_linkArchiveEntries(t *testing.T) {
+	entries := parseLinkArchiveEntries([]string{
+		"https://example.org/a https://web.archive.org/web/1/https://example.org/a",
+		"https://example.org/b https://web.archive.org/web/2/https://example.org/b broken",
+		"invalid",
+	})
+	require.Len(t, entries, 2)
+	assert.False(t, entries[0].broken)
+	assert.True(t, entries[1].broken)
+	assert.Equal(t, "https://example.org/b", entries[1].link)
+	assert.Equal(t, []string{
+		"https://example.org/a https://web.archive.org/web/1/https://example.org/a",
+		"https://example.org/b https://web.archive.org/web/2/https://example.org/b broken",
+	}, linkArchiveValues(entries))
+}
+
+func Test_linkArchive(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.LinkArchive = &configLinkArchive{Enabled: true}
+	})
+	a := h.app
+
+	gone := false
+	h.fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Host == "web.archive.org" && strings.HasPrefix(r.URL.Path, "/save/"):
+			w.Header().Set("Content-Location", "/web/20230101000000/"+strings.TrimPrefix(r.URL.Path, "/save/"))
+		case r.Host == "example.org" && gone:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	h.createPost(&post{Path: "/links", Content: "[Link](https://example.org/page) and [internal](/internal)"})
+
+	require.NoError(t, a.archivePostLinks(context.Background(), "/links"))
+	p, err := a.getPost("/links")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.org/page https://web.archive.org/web/20230101000000/https://example.org/page"}, p.Parameters[linkArchiveParameter])
+
+	// Links are fine
+	require.NoError(t, a.checkArchivedLinks(context.Background()))
+	p, err = a.getPost("/links")
+	require.NoError(t, err)
+	assert.NotContains(t, a.postHtml(&postHtmlOptions{p: p}), "archived-link")
+
+	// Link is gone, the archived version is linked
+	gone = true
+	require.NoError(t, a.checkArchivedLinks(context.Background()))
+	p, err = a.getPost("/links")
+	require.NoError(t, err)
+	assert.True(t, parseLinkArchiveEntries(p.Parameters[linkArchiveParameter])[0].broken)
+	html := a.postHtml(&postHtmlOptions{p: p})
+	assert.Contains(t, html, `href="https://example.org/page"`)
+	assert.Contains(t, html, `<a href="https://web.archive.org/web/20230101000000/https://example.org/page" class="archived-link"`)
+
+	// Archiving again keeps the entry and doesn't archive the archive link
+	require.NoError(t, a.archivePostLinks(context.Background(), "/links"))
+	p, err = a.getPost("/links")
+	require.NoError(t, err)
+	assert.Len(t, p.Parameters[linkArchiveParameter], 1)
+
+	// Rewrite the link instead
+	a.cfg.LinkArchive.BrokenLinks = linkArchiveBrokenRewrite
+	html = a.postHtml(&postHtmlOptions{p: p})
+	assert.NotContains(t, html, `href="https://example.org/page"`)
+	assert.Contains(t, html, `href="https://web.archive.org/web/20230101000000/https://example.org/page"`)
+	assert.NotContains(t, html, "archived-link")
+
+	// Disabled annotations
+	a.cfg.LinkArchive.BrokenLinks = linkArchiveBrokenNone
+	assert.Contains(t, a.postHtml(&postHtmlOptions{p: p}), `href="https://example.org/page"`)
+
+	// Link is back
+	gone = false
+	require.NoError(t, a.checkArchivedLinks(context.Background()))
+	p, err = a.getPost("/links")
+	require.NoError(t, err)
+	assert.False(t, parseLinkArchiveEntries(p.Parameters[linkArchiveParameter])[0].broken)
+}
+
+func Test_linkArchiveLocal(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.LinkArchive = &configLinkArchive{Enabled: true, Local: true}
+	})
+	a := h.app
+	dir := t.TempDir()
+	a.mediaStorageInit.Do(func() {
+		a.mediaStorage = &localMediaStorage{path: dir}
+	})
+
+	h.fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte("<html><body>Archived page</body></html>"))
+	}))
+
+	archive, err := a.archiveLink(context.Background(), "https://example.org/page")
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(archive, ".html"))
+
+	content, err := os.ReadFile(filepath.Join(dir, path.Base(archive)))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Archived page")
+
+	// Types that can run scripts are saved as HTML, which is sandboxed
+	for contentType, ext := range map[string]string{"image/svg+xml": ".html", "application/xhtml+xml": ".html", "image/png": ".png"} {
+		h.fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write([]byte(contentType))
+		}))
+		archive, err = a.archiveLink(context.Background(), "https://example.org/file")
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(archive, ext), contentType)
+	}
+}
+
+func Test_publicHttpClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// Loopback addresses are refused
+	_, err := newPublicHttpClient().Get(srv.URL)
+	assert.ErrorContains(t, err, "non-public address")
+
+	assert.True(t, isPublicIP(net.ParseIP("93.184.216.34")))
+	assert.True(t, isPublicIP(net.ParseIP("2606:2800:220:1::")))
+	for _, ip := range []string{"127.0.0.1", "::1", "10.1.2.3", "192.168.1.1", "172.16.0.1", "169.254.169.254", "fe80::1", "fd00::1", "100.64.0.1", "0.0.0.0"} {
+		assert.False(t, isPublicIP(net.ParseIP(ip)), ip)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// Link policies are applied when rendering links to external sites:
+// - newTab: open external links in a new tab (target="_blank" with rel="noopener")
+// - nofollow: add rel="nofollow" to external links in posts
+// - ugcNofollow: add rel="nofollow ugc" to links from comments and webmentions
+// - followDomains: domains (including subdomains) that never get nofollow
+// Links to the blog itself never get nofollow.
+
+// Whether external links should open in a new tab, enabled if not configured
+func (l *configLinks) newTab() bool {
+	return l == nil || l.NewTab
+}
+
+// Whether the link should get nofollow, ugc is for user generated content like comments and webmentions
+func (l *configLinks) nofollow(link string, ugc bool) bool {
+	if l == nil {
+		// Keep nofollow for user generated content if not configured
+		return ugc
+	}
+	if (ugc && !l.UgcNofollow) || (!ugc && !l.Nofollow) {
+		return false
+	}
+	return !l.isFollowed(link)
+}
+
+// Check if the link points to one of the followed domains or their subdomains
+func (l *configLinks) isFollowed(link string) bool {
+	if l == nil {
+		return false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return false
+	}
+	for _, d := range l.FollowDomains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), ".")
+		if d != "" && (host == d || strings.HasSuffix(host, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a link from comments or webmentions should get nofollow
+func (a *goBlog) ugcNofollow(link string) bool {
+	return a.cfg.Links.nofollow(link, true) && !a.isOwnHostLink(link)
+}
+
+// Check if the link points to one of the hostnames of the blog (including the short, media and custom domains)
+func (a *goBlog) isOwnHostLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	return lo.ContainsBy(a.autocertHosts(), func(host string) bool {
+		return strings.EqualFold(host, u.Hostname())
+	})
+}
+
+// Attributes for links from comments and webmentions to use with the HTML builder
+func (a *goBlog) ugcLinkAttributes(link string) []any {
+	rel := "noopener noreferrer"
+	if a.ugcNofollow(link) {
+		rel = "nofollow " + rel + " ugc"
+	}
+	if a.cfg.Links.newTab() {
+		return []any{"target", "_blank", "rel", rel}
+	}
+	return []any{"rel", rel}
+}
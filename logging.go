@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"github.com/samber/lo"
+	"golang.org/x/exp/slog"
+)
+
+// Structured application logging: every module gets its own logger with an optional level,
+// the request ID of the HTTP request is added when logging with the request context
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	defaultAppLogRetention = 7
+
+	logModuleKey    = "module"
+	logRequestIDKey = "request_id"
+)
+
+func (a *goBlog) initLogging() error {
+	lc := a.cfg.Log
+	if lc == nil {
+		lc = &configLog{}
+	}
+	level, err := parseLogLevel(lc.Level, defaultLogLevel(a.cfg.Debug))
+	if err != nil {
+		return err
+	}
+	a.logLevels = map[string]slog.Level{}
+	for module, l := range lc.Levels {
+		if a.logLevels[module], err = parseLogLevel(l, level); err != nil {
+			return err
+		}
+	}
+	a.logLevel = level
+	// Console output
+	handlers := []slog.Handler{slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})}
+	// File output
+	if lc.File != "" {
+		a.logFile, err = rotatelogs.New(
+			lc.File+".%Y%m%d",
+			rotatelogs.WithLinkName(lc.File),
+			rotatelogs.WithClock(rotatelogs.UTC),
+			rotatelogs.WithMaxAge(time.Duration(lo.If(lc.Retention > 0, lc.Retention).Else(defaultAppLogRetention))*24*time.Hour),
+			rotatelogs.WithRotationTime(24*time.Hour),
+		)
+		if err != nil {
+			return err
+		}
+		handler, err := newLogHandler(a.logFile, lc.Format)
+		if err != nil {
+			return err
+		}
+		handlers = append(handlers, handler)
+	}
+	a.logHandler = &logContextHandler{handlers: handlers}
+	a.loggers = nil
+	// Also use it for the standard logger
+	slog.SetDefault(slog.New(&logLevelHandler{level: level, handler: a.logHandler}))
+	return nil
+}
+
+func defaultLogLevel(debug bool) slog.Level {
+	if debug {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+func parseLogLevel(value string, def slog.Level) (slog.Level, error) {
+	if value == "" {
+		return def, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return def, errors.New("invalid log level: " + value)
+	}
+	return level, nil
+}
+
+func newLogHandler(w io.Writer, format string) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch format {
+	case "", logFormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case logFormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, errors.New("invalid log format: " + format)
+	}
+}
+
+// Add a request ID to the context (from the X-Request-Id header if set) and the response
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// Get the logger of a module
+func (a *goBlog) logger(module string) *slog.Logger {
+	a.loggersMutex.Lock()
+	defer a.loggersMutex.Unlock()
+	if logger, ok := a.loggers[module]; ok {
+		return logger
+	}
+	handler := a.logHandler
+	if handler == nil {
+		// Logging not initialized (e.g. in tests)
+		handler = slog.Default().Handler()
+	}
+	level, ok := a.logLevels[module]
+	if !ok {
+		level = defaultLogLevel(a.cfg != nil && a.cfg.Debug)
+		if a.logHandler != nil {
+			level = a.logLevel
+		}
+	}
+	logger := slog.New(&logLevelHandler{level: level, handler: handler})
+	if module != "" {
+		logger = logger.With(logModuleKey, module)
+	}
+	if a.loggers == nil {
+		a.loggers = map[string]*slog.Logger{}
+	}
+	a.loggers[module] = logger
+	return logger
+}
+
+// Handler that filters by the level of the module
+type logLevelHandler struct {
+	level   slog.Level
+	handler slog.Handler
+}
+
+func (h *logLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.handler.Enabled(ctx, level)
+}
+
+func (h *logLevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *logLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logLevelHandler{level: h.level, handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *logLevelHandler) WithGroup(name string) slog.Handler {
+	return &logLevelHandler{level: h.level, handler: h.handler.WithGroup(name)}
+}
+
+// Handler that adds the request ID from the context and writes to multiple handlers
+type logContextHandler struct {
+	handlers []slog.Handler
+}
+
+func (h *logContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *logContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if requestID := middleware.GetReqID(ctx); requestID != "" {
+			r = r.Clone()
+			r.AddAttrs(slog.String(logRequestIDKey, requestID))
+		}
+	}
+	var errs []error
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, r.Level) {
+			errs = append(errs, handler.Handle(ctx, r))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *logContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logContextHandler{handlers: lo.Map(h.handlers, func(handler slog.Handler, _ int) slog.Handler { return handler.WithAttrs(attrs) })}
+}
+
+func (h *logContextHandler) WithGroup(name string) slog.Handler {
+	return &logContextHandler{handlers: lo.Map(h.handlers, func(handler slog.Handler, _ int) slog.Handler { return handler.WithGroup(name) })}
+}
+
+// Format a log message like log.Println, for the debug messages
+func logMessage(msg ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(msg...), "\n")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func Test_logging(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Log = &configLog{
+		Level:  "warn",
+		Levels: map[string]string{"activitypub": "debug"},
+	}
+	require.NoError(t, app.initConfig(false))
+	require.NoError(t, app.initLogging())
+
+	// Write JSON to a buffer instead of the console
+	var buf bytes.Buffer
+	app.logHandler = &logContextHandler{handlers: []slog.Handler{slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})}}
+	app.loggers = nil
+
+	lines := func() (entries []map[string]any) {
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			entry := map[string]any{}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			entries = append(entries, entry)
+		}
+		buf.Reset()
+		return
+	}
+
+	// Module levels
+	app.logger("activitypub").Debug("Received activity", "type", "Follow")
+	app.logger("webmention").Info("Sent webmention")
+	app.logger("webmention").Warn("Sending webmention failed", "target", "https://example.com/")
+	entries := lines()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Received activity", entries[0]["msg"])
+	assert.Equal(t, "activitypub", entries[0][logModuleKey])
+	assert.Equal(t, "Follow", entries[0]["type"])
+	assert.Equal(t, "WARN", entries[1]["level"])
+	assert.Equal(t, "webmention", entries[1][logModuleKey])
+
+	// Request ID
+	var requestID string
+	rec := httptest.NewRecorder()
+	requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = middleware.GetReqID(r.Context())
+		app.logger("activitypub").WarnCtx(r.Context(), "Failed to verify inbox request")
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/activitypub/inbox/default", nil))
+	require.NotEmpty(t, requestID)
+	assert.Equal(t, requestID, rec.Result().Header.Get(middleware.RequestIDHeader))
+	entries = lines()
+	require.Len(t, entries, 1)
+	assert.Equal(t, requestID, entries[0][logRequestIDKey])
+
+	// Request ID from the request header
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc")
+	rec = httptest.NewRecorder()
+	requestIDMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+	assert.Equal(t, "abc", rec.Result().Header.Get(middleware.RequestIDHeader))
+
+	// Without request ID
+	app.logger("activitypub").InfoCtx(context.Background(), "No request")
+	entries = lines()
+	require.Len(t, entries, 1)
+	assert.NotContains(t, entries[0], logRequestIDKey)
+
+	// Invalid config
+	app.cfg.Log = &configLog{Level: "verbose"}
+	assert.Error(t, app.initLogging())
+	app.cfg.Log = &configLog{Levels: map[string]string{"activitypub": "verbose"}}
+	assert.Error(t, app.initLogging())
+	app.cfg.Log = &configLog{Format: "xml", File: t.TempDir() + "/goblog.log"}
+	assert.Error(t, app.initLogging())
+
+	// Reset default logger for other tests
+	app.cfg.Log = nil
+	require.NoError(t, app.initLogging())
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Brute-force protection: failed logins (login form, Basic Authentication and IndieAuth tokens) are counted
+// per client IP address, too many failures lock the address out for a while and send a notification
+
+const (
+	defaultLoginMaxFailures = 5
+	defaultLoginLockout     = 15 // minutes
+)
+
+type loginProtection struct {
+	mutex   sync.Mutex
+	clients map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+func (lpc *configLoginProtection) enabled() bool {
+	return lpc != nil && lpc.Enabled
+}
+
+func (lpc *configLoginProtection) maxFailures() int {
+	if lpc.MaxFailures > 0 {
+		return lpc.MaxFailures
+	}
+	return defaultLoginMaxFailures
+}
+
+// Duration of the lockout and of the window in which failures are counted
+func (lpc *configLoginProtection) lockout() time.Duration {
+	if lpc.Lockout > 0 {
+		return time.Duration(lpc.Lockout) * time.Minute
+	}
+	return defaultLoginLockout * time.Minute
+}
+
+func (a *goBlog) getLoginProtection() *loginProtection {
+	a.loginProtectionInit.Do(func() {
+		a.loginProtection = &loginProtection{clients: map[string]*loginFailures{}}
+	})
+	return a.loginProtection
+}
+
+// Remaining time of the lockout of the client, 0 if it's not locked out
+func (a *goBlog) loginLockedOut(r *http.Request) time.Duration {
+	if !a.cfg.Server.LoginProtection.enabled() {
+		return 0
+	}
+	lp := a.getLoginProtection()
+	lp.mutex.Lock()
+	defer lp.mutex.Unlock()
+	if f, ok := lp.clients[a.clientIP(r)]; ok {
+		if remaining := time.Until(f.lockedUntil); remaining > 0 {
+			return remaining
+		}
+	}
+	return 0
+}
+
+// Serve an error if the client is locked out, returns true if it did
+func (a *goBlog) serveLoginLockedOut(w http.ResponseWriter, r *http.Request) bool {
+	remaining := a.loginLockedOut(r)
+	if remaining <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+	a.serveError(w, r, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+	return true
+}
+
+// Count a failed login of the client, locks it out and sends a notification when there are too many
+func (a *goBlog) loginFailed(r *http.Request, method string) {
+	lpc := a.cfg.Server.LoginProtection
+	if !lpc.enabled() {
+		return
+	}
+	ip, now := a.clientIP(r), time.Now()
+	lp := a.getLoginProtection()
+	lp.mutex.Lock()
+	lp.cleanup(now, lpc.lockout())
+	f, ok := lp.clients[ip]
+	if !ok || now.Sub(f.first) > lpc.lockout() {
+		f = &loginFailures{first: now}
+		lp.clients[ip] = f
+	}
+	f.count++
+	count := f.count
+	locked := count >= lpc.maxFailures()
+	if locked {
+		f.count, f.first, f.lockedUntil = 0, now, now.Add(lpc.lockout())
+	}
+	lp.mutex.Unlock()
+	log.Printf("Login: failed %s login from %s for %s", method, ip, r.URL.Path)
+	if locked {
+		text := fmt.Sprintf("%d failed logins from %s (last with %s for %s), locked out for %s", count, ip, method, r.URL.Path, lpc.lockout())
+		log.Println("Login:", text)
+		a.sendNotification(notificationTypeSecurity, text)
+	}
+}
+
+// Reset the failures after a successful login
+func (a *goBlog) loginSucceeded(r *http.Request) {
+	if !a.cfg.Server.LoginProtection.enabled() {
+		return
+	}
+	lp := a.getLoginProtection()
+	lp.mutex.Lock()
+	delete(lp.clients, a.clientIP(r))
+	lp.mutex.Unlock()
+}
+
+// Remove clients without recent failures and lockouts
+func (lp *loginProtection) cleanup(now time.Time, window time.Duration) {
+	for ip, f := range lp.clients {
+		if now.Sub(f.first) > window && now.After(f.lockedUntil) {
+			delete(lp.clients, ip)
+		}
+	}
+}
+
+// Middleware to check the credentials of requests with an Authorization header once, so failures are counted
+// and locked out clients are rejected before the handlers check the credentials again
+func (a *goBlog) loginProtectionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if a.serveLoginLockedOut(w, r) {
+			return
+		}
+		if username, password, ok := r.BasicAuth(); ok {
+			if a.checkAppPasswords(username, password) {
+				setLoggedIn(r, true)
+				a.loginSucceeded(r)
+			} else if u := a.db.checkUserCredentials(username, password); u != nil {
+				setLoggedInUser(r, u)
+				a.loginSucceeded(r)
+			} else {
+				a.loginFailed(r, "Basic Authentication")
+			}
+		} else if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+			if _, err := a.db.indieAuthVerifyToken(token); err != nil {
+				a.loginFailed(r, "IndieAuth token")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loginProtection(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.Server.LoginProtection = &configLoginProtection{Enabled: true, MaxFailures: 3, Lockout: 1}
+	})
+
+	basicAuth := func(ip, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/editor", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.SetBasicAuth(testHarnessUser, password)
+		return h.serve(req)
+	}
+	countNotifications := func() int {
+		row, err := h.app.db.QueryRow("select count(*) from notifications")
+		require.NoError(t, err)
+		var count int
+		require.NoError(t, row.Scan(&count))
+		return count
+	}
+
+	// Successful logins reset the failures
+	for i := 0; i < 2; i++ {
+		assert.NotEqual(t, http.StatusTooManyRequests, basicAuth("192.0.2.1", "wrong").Code)
+	}
+	assert.Equal(t, http.StatusOK, basicAuth("192.0.2.1", testHarnessPassword).Code)
+	for i := 0; i < 2; i++ {
+		assert.NotEqual(t, http.StatusTooManyRequests, basicAuth("192.0.2.1", "wrong").Code)
+	}
+	assert.Equal(t, 0, countNotifications())
+
+	// The third failure in a row locks the client out, even with the correct password
+	assert.NotEqual(t, http.StatusTooManyRequests, basicAuth("192.0.2.1", "wrong").Code)
+	rec := basicAuth("192.0.2.1", testHarnessPassword)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	assert.Equal(t, 1, countNotifications())
+
+	// Other clients aren't affected
+	assert.Equal(t, http.StatusOK, basicAuth("192.0.2.2", testHarnessPassword).Code)
+
+	// Requests without credentials aren't affected either
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, http.StatusOK, h.serve(req).Code)
+
+	// Login form and IndieAuth tokens count as well
+	for i := 0; i < 3; i++ {
+		req = httptest.NewRequest(http.MethodPost, "/editor", strings.NewReader(url.Values{
+			"loginaction": {"login"},
+			"username":    {"admin"},
+			"password":    {"wrong"},
+		}.Encode()))
+		req.Header.Set(contentType, "application/x-www-form-urlencoded")
+		req.RemoteAddr = "192.0.2.3:1234"
+		assert.Equal(t, http.StatusUnauthorized, h.serve(req).Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/micropub", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	req.RemoteAddr = "192.0.2.3:1234"
+	assert.Equal(t, http.StatusTooManyRequests, h.serve(req).Code)
+
+	for i := 0; i < 3; i++ {
+		req = httptest.NewRequest(http.MethodGet, "/micropub", nil)
+		req.Header.Set("Authorization", "Bearer invalid")
+		req.RemoteAddr = "192.0.2.4:1234"
+		assert.Equal(t, http.StatusUnauthorized, h.serve(req).Code)
+	}
+	assert.Equal(t, time.Minute, h.app.loginLockedOut(req).Round(time.Minute))
+	assert.Equal(t, 3, countNotifications())
+}
+
+func Test_loginProtectionWithRateLimit(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.Server.LoginProtection = &configLoginProtection{Enabled: true, MaxFailures: 5, Lockout: 1}
+		c.Server.RateLimit = &configRateLimit{Enabled: true, Requests: 2}
+	})
+
+	basicAuth := func(password string) int {
+		req := httptest.NewRequest(http.MethodGet, "/editor", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.SetBasicAuth(testHarnessUser, password)
+		return h.serve(req).Code
+	}
+
+	// Failures of rate limited requests count too, so the lockout can't be bypassed
+	for i := 0; i < 5; i++ {
+		basicAuth("wrong")
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.NotZero(t, h.app.loginLockedOut(req))
+	assert.Equal(t, http.StatusTooManyRequests, basicAuth(testHarnessPassword))
+}
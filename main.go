@@ -10,8 +10,6 @@ import (
 	"runtime"
 	"runtime/pprof"
 	"time"
-
-	"github.com/pquerna/otp/totp"
 )
 
 func main() {
@@ -53,8 +51,15 @@ func main() {
 		}()
 	}
 
+	// Help doesn't need a config
+	if flag.Arg(0) == "help" {
+		printCliUsage(os.Stdout)
+		return
+	}
+
 	app := &goBlog{
-		httpClient: newHttpClient(),
+		httpClient:       newHttpClient(),
+		publicHttpClient: newPublicHttpClient(),
 	}
 
 	// Initialize config
@@ -66,6 +71,10 @@ func main() {
 		app.logErrAndQuit("Failed to init config:", err.Error())
 		return
 	}
+	if err = app.initLogging(); err != nil {
+		app.logErrAndQuit("Failed to init logging:", err.Error())
+		return
+	}
 
 	// Initialize plugins
 	if err = app.initPlugins(); err != nil {
@@ -74,7 +83,7 @@ func main() {
 	}
 
 	// Healthcheck tool
-	if len(os.Args) >= 2 && os.Args[1] == "healthcheck" {
+	if flag.Arg(0) == "healthcheck" {
 		// Connect to public address + "/ping" and exit with 0 when successful
 		health := app.healthcheckExitCode()
 		app.shutdown.ShutdownAndWait()
@@ -82,12 +91,69 @@ func main() {
 		return
 	}
 
+	// Config check tool
+	if (flag.Arg(0) == "check" && flag.Arg(1) == "config") || flag.Arg(0) == "check-config" {
+		issues, err := app.checkConfig(os.Stdout)
+		if err != nil {
+			app.logErrAndQuit("Failed to check config:", err.Error())
+			return
+		}
+		app.shutdown.ShutdownAndWait()
+		if issues > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Self-test tool
+	if flag.Arg(0) == "doctor" {
+		failed := app.doctor(os.Stdout)
+		app.shutdown.ShutdownAndWait()
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Backup tool
+	if flag.Arg(0) == "backup" && flag.Arg(1) != "" {
+		f, err := os.Create(flag.Arg(1))
+		if err != nil {
+			app.logErrAndQuit("Failed to create backup file:", err.Error())
+			return
+		}
+		err = app.writeBackup(f)
+		_ = f.Close()
+		if err != nil {
+			app.logErrAndQuit("Failed to create backup:", err.Error())
+			return
+		}
+		log.Println("Created backup", flag.Arg(1))
+		app.shutdown.ShutdownAndWait()
+		return
+	}
+
+	// Restore tool (the server must not be running)
+	if flag.Arg(0) == "restore" && flag.Arg(1) != "" {
+		f, err := os.Open(flag.Arg(1))
+		if err != nil {
+			app.logErrAndQuit("Failed to open backup file:", err.Error())
+			return
+		}
+		defer f.Close()
+		// Close the database before replacing it
+		app.shutdown.ShutdownAndWait()
+		if err = app.restoreBackup(f); err != nil {
+			log.Fatalln("Failed to restore backup:", err.Error())
+			return
+		}
+		log.Println("Restored backup", flag.Arg(1))
+		return
+	}
+
 	// Tool to generate TOTP secret
-	if len(os.Args) >= 2 && os.Args[1] == "totp-secret" {
-		key, err := totp.Generate(totp.GenerateOpts{
-			Issuer:      app.cfg.Server.PublicAddress,
-			AccountName: app.cfg.User.Nick,
-		})
+	if flag.Arg(0) == "totp-secret" {
+		key, err := app.generateTOTPKey()
 		if err != nil {
 			app.logErrAndQuit(err.Error())
 			return
@@ -97,6 +163,38 @@ func main() {
 		return
 	}
 
+	// Tool to rebuild the search index
+	if flag.Arg(0) == "rebuild-search-index" {
+		if err = app.rebuildSearchIndex(os.Stdout); err != nil {
+			app.logErrAndQuit("Failed to rebuild search index:", err.Error())
+			return
+		}
+		app.shutdown.ShutdownAndWait()
+		return
+	}
+
+	// Tool to hash a password for the config
+	if flag.NArg() >= 2 && flag.Arg(0) == "hash-password" {
+		hash, err := hashPassword(flag.Arg(1))
+		if err != nil {
+			app.logErrAndQuit(err.Error())
+			return
+		}
+		log.Println("Password hash:", hash)
+		app.shutdown.ShutdownAndWait()
+		return
+	}
+
+	// Tool to manage the database users
+	if flag.Arg(0) == "user" {
+		if err = app.userTool(os.Stdout, flag.Args()[1:]); err != nil {
+			app.logErrAndQuit("User tool:", err.Error())
+			return
+		}
+		app.shutdown.ShutdownAndWait()
+		return
+	}
+
 	// Start pprof server
 	if pprofCfg := app.cfg.Pprof; pprofCfg != nil && pprofCfg.Enabled {
 		go func() {
@@ -135,7 +233,7 @@ func main() {
 	app.preStartHooks()
 
 	// Link check tool after init of markdown
-	if len(os.Args) >= 2 && os.Args[1] == "check" {
+	if flag.Arg(0) == "check" {
 		app.initMarkdown()
 		err = app.initTemplateStrings()
 		if err != nil {
@@ -149,11 +247,69 @@ func main() {
 		return
 	}
 
+	// Tools that need ActivityPub
+	if flag.Arg(0) == "generate-key" || flag.Arg(0) == "resend-activities" {
+		app.initMarkdown()
+		if err = app.initTemplateStrings(); err != nil {
+			app.logErrAndQuit("Failed to init template translations:", err.Error())
+			return
+		}
+		if err = app.initCache(); err != nil {
+			app.logErrAndQuit("Failed to init HTTP cache:", err.Error())
+			return
+		}
+		if err = app.initActivityPub(); err != nil {
+			app.logErrAndQuit("Failed to init ActivityPub:", err.Error())
+			return
+		}
+		tool := app.generateKeyTool
+		if flag.Arg(0) == "resend-activities" {
+			tool = app.resendActivities
+		}
+		if err = tool(os.Stdout, flag.Args()[1:]); err != nil {
+			app.logErrAndQuit(flag.Arg(0)+":", err.Error())
+			return
+		}
+		app.shutdown.ShutdownAndWait()
+		return
+	}
+
+	// Hugo export
+	if flag.NArg() >= 2 && flag.Arg(0) == "export" && flag.Arg(1) == "hugo" {
+		var dir string
+		if flag.NArg() >= 3 {
+			dir = flag.Arg(2)
+		}
+		err = app.exportHugoToDir(dir)
+		if err != nil {
+			app.logErrAndQuit("Failed to export Hugo site:", err.Error())
+			return
+		}
+		app.shutdown.ShutdownAndWait()
+		return
+	}
+
+	// Hugo and Jekyll import
+	if flag.NArg() >= 3 && flag.Arg(0) == "import" {
+		var blog string
+		if flag.NArg() >= 4 {
+			blog = flag.Arg(3)
+		}
+		result, err := app.importPosts(importFormat(flag.Arg(1)), flag.Arg(2), blog)
+		if err != nil {
+			app.logErrAndQuit("Failed to import posts:", err.Error())
+			return
+		}
+		log.Printf("Imported %d posts, skipped %d existing posts", result.imported, result.skipped)
+		app.shutdown.ShutdownAndWait()
+		return
+	}
+
 	// Markdown export
-	if len(os.Args) >= 2 && os.Args[1] == "export" {
+	if flag.Arg(0) == "export" {
 		var dir string
-		if len(os.Args) >= 3 {
-			dir = os.Args[2]
+		if flag.NArg() >= 2 {
+			dir = flag.Arg(1)
 		}
 		err = app.exportMarkdownFiles(dir)
 		if err != nil {
@@ -170,6 +326,9 @@ func main() {
 	// Start cron hooks
 	app.startHourlyHooks()
 
+	// Reload config on SIGHUP
+	app.initConfigReload()
+
 	// Start the server
 	err = app.startServer()
 	if err != nil {
@@ -211,15 +370,37 @@ func (app *goBlog) initComponents() {
 		app.logErrAndQuit("Failed to init ActivityPub:", err.Error())
 		return
 	}
+	if err = app.initNostr(); err != nil {
+		app.logErrAndQuit("Failed to init Nostr:", err.Error())
+		return
+	}
 	app.initWebmention()
 	app.initTelegram()
 	app.initBlogStats()
 	app.initTTS()
 	app.initSessions()
 	app.initIndieAuth()
+	app.initMastodonAPI()
 	app.startPostsScheduler()
 	app.initPostsDeleter()
+	app.initBlogrollRefresh()
+	app.initRelMeVerification()
+	app.initAnalytics()
+	app.initScheduledBackups()
 	app.initIndexNow()
+	app.initSitemapPing()
+	app.initSyndication()
+	app.initLinkArchive()
+	app.initMonitoring()
+	app.initCertificateNotifications()
+	app.initFeedReader()
+	app.initPageData()
+	app.initNewsletter()
+	app.initWeeknotes()
+	app.initYearInReview()
+	app.initRelatedPosts()
+	app.initFeedRegeneration()
+	app.initThemeReload()
 
 	log.Println("Initialized components")
 }
@@ -3,6 +3,9 @@ package main
 import (
 	"bytes"
 	"io"
+	"net/url"
+	"strings"
+	"unicode"
 
 	marktag "git.jlel.se/jlelse/goldmark-mark"
 	"github.com/yuin/goldmark"
@@ -12,7 +15,9 @@ import (
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
+	"go.goblog.app/app/pkgs/builderpool"
 	"go.goblog.app/app/pkgs/highlighting"
 	"go.goblog.app/app/pkgs/htmlbuilder"
 )
@@ -33,6 +38,7 @@ func (a *goBlog) initMarkdown() {
 			extension.Table,
 			extension.Strikethrough,
 			extension.Footnote,
+			extension.DefinitionList,
 			extension.Typographer,
 			extension.Linkify,
 			marktag.Mark,
@@ -47,10 +53,12 @@ func (a *goBlog) initMarkdown() {
 	a.md = goldmark.New(append(defaultGoldmarkOptions, goldmark.WithExtensions(&customExtension{
 		absoluteLinks: false,
 		publicAddress: publicAddress,
+		links:         a.cfg.Links,
 	}))...)
 	a.absoluteMd = goldmark.New(append(defaultGoldmarkOptions, goldmark.WithExtensions(&customExtension{
 		absoluteLinks: true,
 		publicAddress: publicAddress,
+		links:         a.cfg.Links,
 	}))...)
 	a.titleMd = goldmark.New(
 		goldmark.WithParser(
@@ -75,6 +83,67 @@ func (a *goBlog) renderMarkdownToWriter(w io.Writer, source string, absoluteLink
 	return err
 }
 
+// Word count and headings, computed from the same AST that gets rendered
+type markdownMeta struct {
+	words    int
+	headings []*markdownHeading
+}
+
+type markdownHeading struct {
+	level    int
+	id, text string
+}
+
+// Renders the markdown (if w isn't nil) and returns its meta data
+func (a *goBlog) renderMarkdownWithMeta(w io.Writer, source string, absoluteLinks bool) (*markdownMeta, error) {
+	md := a.md
+	if absoluteLinks {
+		md = a.absoluteMd
+	}
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+	meta := &markdownMeta{}
+	plain := builderpool.Get()
+	defer builderpool.Put(plain)
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n.Type() == ast.TypeBlock {
+			// Words don't continue across blocks
+			plain.WriteByte(' ')
+		}
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Text:
+			plain.Write(node.Segment.Value(src))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				plain.WriteByte(' ')
+			}
+		case *ast.String:
+			plain.Write(node.Value)
+		case *ast.Heading:
+			heading := &markdownHeading{level: node.Level, text: strings.TrimSpace(string(node.Text(src)))}
+			if id, ok := node.AttributeString("id"); ok {
+				if idBytes, ok := id.([]byte); ok {
+					heading.id = string(idBytes)
+				}
+			}
+			meta.headings = append(meta.headings, heading)
+		}
+		return ast.WalkContinue, nil
+	})
+	// Only count words with letters or digits
+	for _, word := range strings.Fields(plain.String()) {
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			meta.words++
+		}
+	}
+	if w == nil {
+		return meta, nil
+	}
+	return meta, md.Renderer().Render(w, src, doc)
+}
+
 func (a *goBlog) renderText(s string) (string, error) {
 	if s == "" {
 		return "", nil
@@ -118,13 +187,18 @@ func (a *goBlog) renderMdTitle(s string) string {
 type customExtension struct {
 	publicAddress string
 	absoluteLinks bool
+	links         *configLinks
 }
 
 func (l *customExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithBlockParsers(
+		util.Prioritized(&embedParser{}, 750),
+	))
 	m.Renderer().AddOptions(renderer.WithNodeRenderers(
 		util.Prioritized(&customRenderer{
 			absoluteLinks: l.absoluteLinks,
 			publicAddress: l.publicAddress,
+			links:         l.links,
 		}, 500),
 	))
 }
@@ -132,11 +206,13 @@ func (l *customExtension) Extend(m goldmark.Markdown) {
 type customRenderer struct {
 	publicAddress string
 	absoluteLinks bool
+	links         *configLinks
 }
 
 func (c *customRenderer) RegisterFuncs(r renderer.NodeRendererFuncRegisterer) {
 	r.Register(ast.KindLink, c.renderLink)
 	r.Register(ast.KindImage, c.renderImage)
+	r.Register(kindEmbed, c.renderEmbed)
 }
 
 func (c *customRenderer) renderLink(w util.BufWriter, _ []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
@@ -150,9 +226,19 @@ func (c *customRenderer) renderLink(w util.BufWriter, _ []byte, node ast.Node, e
 		}
 		_, _ = w.Write(util.EscapeHTML(newDestination))
 		_, _ = w.WriteRune('"')
-		// Open external links (links that start with "http") in new tab
-		if isAbsoluteURL(string(n.Destination)) {
-			_, _ = w.WriteString(` target="_blank" rel="noopener"`)
+		// Apply link policies to external links (links that start with "http")
+		if dest := string(n.Destination); isAbsoluteURL(dest) {
+			var rel []string
+			if c.links.newTab() {
+				_, _ = w.WriteString(` target="_blank"`)
+				rel = append(rel, "noopener")
+			}
+			if !c.isOwnLink(dest) && c.links.nofollow(dest, false) {
+				rel = append(rel, "nofollow")
+			}
+			if len(rel) > 0 {
+				_, _ = w.WriteString(` rel="` + strings.Join(rel, " ") + `"`)
+			}
 		}
 		// Title
 		if n.Title != nil {
@@ -167,6 +253,22 @@ func (c *customRenderer) renderLink(w util.BufWriter, _ []byte, node ast.Node, e
 	return ast.WalkContinue, nil
 }
 
+// Check if the link points to the own public address, those links are never nofollow
+func (c *customRenderer) isOwnLink(link string) bool {
+	if c.publicAddress == "" {
+		return false
+	}
+	pu, err := url.Parse(c.publicAddress)
+	if err != nil {
+		return false
+	}
+	lu, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(pu.Hostname(), lu.Hostname())
+}
+
 func (c *customRenderer) renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	if !entering {
 		return ast.WalkContinue, nil
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"go.goblog.app/app/pkgs/htmlbuilder"
+)
+
+// Embed shortcodes for videos, like {{< embed https://youtu.be/... >}}, {{< youtube ID >}}, {{< vimeo ID >}}
+// or {{< peertube https://instance/w/ID >}}. They are rendered as a link to the video, the player
+// of the provider is only loaded (by js/embed.js) when the visitor clicks on it.
+
+var (
+	markdownEmbedRegex = regexp.MustCompile(`^\{\{<\s*(embed|youtube|vimeo|peertube)\s+(\S+)\s*>\}\}$`)
+	youtubeIDRegex     = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	vimeoIDRegex       = regexp.MustCompile(`^[0-9]+$`)
+	peertubePathRegex  = regexp.MustCompile(`^/(?:w|videos/watch|videos/embed)/([A-Za-z0-9-]+)/?$`)
+)
+
+const (
+	youtubeEmbedOrigin = "https://www.youtube-nocookie.com"
+	vimeoEmbedOrigin   = "https://player.vimeo.com"
+)
+
+type markdownEmbed struct {
+	provider string // Name shown in the placeholder
+	link     string // Page of the video
+	embedURL string // Player to load in an iframe
+}
+
+func youtubeEmbed(id string) *markdownEmbed {
+	if !youtubeIDRegex.MatchString(id) {
+		return nil
+	}
+	return &markdownEmbed{
+		provider: "YouTube",
+		link:     "https://www.youtube.com/watch?v=" + id,
+		embedURL: youtubeEmbedOrigin + "/embed/" + id + "?autoplay=1",
+	}
+}
+
+func vimeoEmbed(id string) *markdownEmbed {
+	if !vimeoIDRegex.MatchString(id) {
+		return nil
+	}
+	return &markdownEmbed{
+		provider: "Vimeo",
+		link:     "https://vimeo.com/" + id,
+		embedURL: vimeoEmbedOrigin + "/video/" + id + "?dnt=1&autoplay=1",
+	}
+}
+
+func peertubeEmbed(u *url.URL) *markdownEmbed {
+	m := peertubePathRegex.FindStringSubmatch(u.Path)
+	if u.Scheme != "https" || m == nil {
+		return nil
+	}
+	origin := "https://" + u.Host
+	return &markdownEmbed{
+		provider: "PeerTube",
+		link:     origin + "/w/" + m[1],
+		embedURL: origin + "/videos/embed/" + m[1] + "?autoplay=1",
+	}
+}
+
+// Returns nil for unsupported shortcodes and links
+func parseMarkdownEmbed(kind, value string) *markdownEmbed {
+	switch kind {
+	case "youtube":
+		return youtubeEmbed(value)
+	case "vimeo":
+		return vimeoEmbed(value)
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" {
+		return nil
+	}
+	if kind == "peertube" {
+		return peertubeEmbed(u)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch host {
+	case "youtube.com", "m.youtube.com", "youtube-nocookie.com":
+		if id := u.Query().Get("v"); id != "" {
+			return youtubeEmbed(id)
+		}
+		if id, ok := strings.CutPrefix(u.Path, "/shorts/"); ok {
+			return youtubeEmbed(id)
+		}
+		if id, ok := strings.CutPrefix(u.Path, "/embed/"); ok {
+			return youtubeEmbed(id)
+		}
+		return nil
+	case "youtu.be":
+		return youtubeEmbed(strings.TrimPrefix(u.Path, "/"))
+	case "vimeo.com":
+		return vimeoEmbed(strings.TrimPrefix(u.Path, "/"))
+	case "player.vimeo.com":
+		if id, ok := strings.CutPrefix(u.Path, "/video/"); ok {
+			return vimeoEmbed(id)
+		}
+		return nil
+	}
+	return peertubeEmbed(u)
+}
+
+var kindEmbed = ast.NewNodeKind("Embed")
+
+type embedNode struct {
+	ast.BaseBlock
+	embed *markdownEmbed
+}
+
+func (n *embedNode) Kind() ast.NodeKind {
+	return kindEmbed
+}
+
+func (n *embedNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Link": n.embed.link}, nil)
+}
+
+// Block parser for shortcodes on their own line, other lines stay paragraphs
+type embedParser struct{}
+
+func (*embedParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (*embedParser) Open(_ ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	m := markdownEmbedRegex.FindSubmatch(util.TrimRightSpace(line[pos:]))
+	if m == nil {
+		return nil, parser.NoChildren
+	}
+	embed := parseMarkdownEmbed(string(m[1]), string(m[2]))
+	if embed == nil {
+		return nil, parser.NoChildren
+	}
+	reader.Advance(segment.Len() - 1)
+	return &embedNode{embed: embed}, parser.NoChildren
+}
+
+func (*embedParser) Continue(ast.Node, text.Reader, parser.Context) parser.State {
+	return parser.Close
+}
+
+func (*embedParser) Close(ast.Node, text.Reader, parser.Context) {}
+
+func (*embedParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (*embedParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+func (c *customRenderer) renderEmbed(w util.BufWriter, _ []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	embed := node.(*embedNode).embed
+	hb := htmlbuilder.NewHtmlBuilder(w)
+	hb.WriteElementOpen("div", "class", "embed p", "data-embed", embed.embedURL)
+	linkAttrs := []any{"href", embed.link}
+	if c.links.newTab() {
+		linkAttrs = append(linkAttrs, "target", "_blank", "rel", "noopener")
+	}
+	hb.WriteElementOpen("a", linkAttrs...)
+	hb.WriteEscaped("▶ " + embed.provider + ": " + embed.link)
+	hb.WriteElementClose("a")
+	hb.WriteElementClose("div")
+	_ = w.WriteByte('\n')
+	return ast.WalkSkipChildren, nil
+}
+
+// Check if the markdown contains embed shortcodes, to only load the script when needed
+func containsMarkdownEmbed(md string) bool {
+	if !strings.Contains(md, "{{<") {
+		return false
+	}
+	for _, line := range strings.Split(md, "\n") {
+		if markdownEmbedRegex.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
@@ -90,6 +90,107 @@ func Test_markdown(t *testing.T) {
 		assert.Equal(t, "😂", app.renderMdTitle(":joy:"))
 		assert.Equal(t, "<b></b>", app.renderMdTitle("<b></b>"))
 	})
+
+	t.Run("Link policies", func(t *testing.T) {
+		app := &goBlog{
+			cfg: &config{
+				Server: &configServer{
+					PublicAddress: "https://example.com",
+				},
+				Links: &configLinks{
+					Nofollow:      true,
+					UgcNofollow:   true,
+					FollowDomains: []string{"friend.example.org"},
+				},
+			},
+		}
+
+		app.initMarkdown()
+
+		rendered, err := app.renderMarkdown("[External](https://example.net)", false)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(rendered), `rel="nofollow"`)
+		assert.NotContains(t, string(rendered), `target="_blank"`)
+
+		rendered, err = app.renderMarkdown("[Followed](https://blog.friend.example.org/post)", false)
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(rendered), `rel=`)
+
+		rendered, err = app.renderMarkdown("[Own](https://example.com/post)", false)
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(rendered), `rel=`)
+
+		app.cfg.Links.NewTab = true
+		app.md, app.absoluteMd, app.titleMd = nil, nil, nil
+		app.initMarkdown()
+
+		rendered, err = app.renderMarkdown("[External](https://example.net)", false)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(rendered), `target="_blank" rel="noopener nofollow"`)
+
+		// Comments and webmentions
+
+		assert.Equal(t, []any{"target", "_blank", "rel", "nofollow noopener noreferrer ugc"}, app.ugcLinkAttributes("https://example.net"))
+		assert.Equal(t, []any{"target", "_blank", "rel", "noopener noreferrer"}, app.ugcLinkAttributes("https://friend.example.org"))
+
+		app.cfg.Links.UgcNofollow = false
+		app.cfg.Links.NewTab = false
+		assert.Equal(t, []any{"rel", "noopener noreferrer"}, app.ugcLinkAttributes("https://example.net"))
+	})
+
+	t.Run("Extensions", func(t *testing.T) {
+		app := &goBlog{
+			cfg: &config{
+				Server: &configServer{
+					PublicAddress: "https://example.com",
+				},
+			},
+		}
+
+		app.initMarkdown()
+
+		// Footnotes and definition lists
+
+		rendered, err := app.renderMarkdown("Text[^1]\n\n[^1]: Note\n\nTerm\n: Definition", false)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(rendered), `<sup id="fnref:1">`)
+		assert.Contains(t, string(rendered), "<dl>\n<dt>Term</dt>\n<dd>Definition</dd>\n</dl>")
+
+		// Syntax highlighting
+
+		rendered, err = app.renderMarkdown("```go\nfunc main() {}\n```", false)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(rendered), `class="c-chroma"`)
+
+		// Embeds
+
+		rendered, err = app.renderMarkdown("Before\n{{< embed https://youtu.be/dQw4w9WgXcQ >}}\n\n{{< vimeo 76979871 >}}", false)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(rendered), `<p>Before</p>`)
+		assert.Contains(t, string(rendered), `<div class="embed p" data-embed="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ?autoplay=1"><a href="https://www.youtube.com/watch?v=dQw4w9WgXcQ" target="_blank" rel="noopener">▶ YouTube: https://www.youtube.com/watch?v=dQw4w9WgXcQ</a></div>`)
+		assert.Contains(t, string(rendered), `data-embed="https://player.vimeo.com/video/76979871?dnt=1&amp;autoplay=1"`)
+		assert.NotContains(t, string(rendered), "<iframe")
+
+		// Unsupported embeds stay text
+
+		rendered, err = app.renderMarkdown("{{< embed https://example.net/video >}}", false)
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(rendered), "embed p")
+
+		assert.Equal(t, "https://tube.example.org/videos/embed/abc-123?autoplay=1", parseMarkdownEmbed("peertube", "https://tube.example.org/videos/watch/abc-123").embedURL)
+		assert.Nil(t, parseMarkdownEmbed("youtube", "invalid"))
+		assert.Nil(t, parseMarkdownEmbed("embed", "http://youtu.be/dQw4w9WgXcQ"))
+		assert.True(t, containsMarkdownEmbed("Text\n  {{< youtube dQw4w9WgXcQ >}}\n"))
+		assert.False(t, containsMarkdownEmbed("{{< highlight go >}}"))
+	})
 }
 
 func Benchmark_markdown(b *testing.B) {
@@ -0,0 +1,612 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/hacdias/indieauth/v3"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+// Mastodon API: a minimal subset of the Mastodon client API (app registration, OAuth, verify_credentials,
+// media uploads and creating statuses), so Mastodon apps can be used to post notes to the blog.
+// Tokens are IndieAuth tokens, the Mastodon scopes are mapped to the Micropub scopes.
+
+const (
+	mastodonOAuthPath         = "/oauth"
+	mastodonAppsPath          = "/api/v1/apps"
+	mastodonAppKeyPrefix      = "mastodonapp_"
+	mastodonNewAppKeyPrefix   = "mastodonnewapp_"
+	mastodonNewAppExpiry      = 24 * time.Hour
+	mastodonMediaKeyPrefix    = "mastodonmedia_"
+	mastodonOOBRedirect       = "urn:ietf:wg:oauth:2.0:oob"
+	mastodonCompatibleVersion = "4.0.0 (compatible; GoBlog)"
+)
+
+type mastodonApp struct {
+	Name         string `json:"name"`
+	Website      string `json:"website"`
+	RedirectURIs string `json:"redirectUris"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+type mastodonMedia struct {
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+func (a *goBlog) mastodonAPIEnabled() bool {
+	return a.cfg.MastodonAPI != nil && a.cfg.MastodonAPI.Enabled
+}
+
+// Blog and config of the blog to post to
+func (a *goBlog) mastodonAPIBlog() (string, *configBlog) {
+	blog := defaultIfEmpty(a.cfg.MastodonAPI.Blog, a.cfg.DefaultBlog)
+	return blog, a.cfg.Blogs[blog]
+}
+
+// Map the Mastodon scopes (like "read write follow") to the Micropub scopes
+func mastodonScopesToMicropub(scope string) []string {
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		switch s {
+		case "write", "write:statuses":
+			scopes = append(scopes, "create")
+		}
+		switch s {
+		case "write", "write:media":
+			scopes = append(scopes, "media")
+		}
+	}
+	return lo.Uniq(scopes)
+}
+
+// Parse form, multipart form or JSON requests into the request form
+func mastodonParseForm(r *http.Request) error {
+	ct := r.Header.Get(contentType)
+	if strings.Contains(ct, contenttype.MultipartForm) {
+		return r.ParseMultipartForm(0)
+	}
+	if !strings.Contains(ct, contenttype.JSON) {
+		return r.ParseForm()
+	}
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return err
+	}
+	values := r.URL.Query()
+	for k, v := range body {
+		switch vv := v.(type) {
+		case []any:
+			for _, item := range vv {
+				values.Add(k+"[]", fmt.Sprint(item))
+			}
+		case nil:
+		default:
+			values.Set(k, fmt.Sprint(vv))
+		}
+	}
+	r.Form, r.PostForm = values, values
+	return nil
+}
+
+func (*goBlog) mastodonRespond(w http.ResponseWriter, status int, v any) {
+	w.Header().Set(contentType, contenttype.JSONUTF8)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (a *goBlog) mastodonError(w http.ResponseWriter, status int, msg string) {
+	a.mastodonRespond(w, status, map[string]any{"error": msg})
+}
+
+func (a *goBlog) mastodonCheckScope(w http.ResponseWriter, r *http.Request, required string) bool {
+	if !strings.Contains(r.Context().Value(indieAuthScope).(string), required) {
+		a.mastodonError(w, http.StatusForbidden, "This action is outside the authorized scopes")
+		return false
+	}
+	return true
+}
+
+// Instance
+
+func (a *goBlog) serveMastodonInstance(w http.ResponseWriter, _ *http.Request) {
+	_, bc := a.mastodonAPIBlog()
+	host := ""
+	if u, err := url.Parse(a.cfg.Server.PublicAddress); err == nil {
+		host = u.Host
+	}
+	a.mastodonRespond(w, http.StatusOK, map[string]any{
+		"uri":               host,
+		"title":             bc.Title,
+		"short_description": bc.Description,
+		"description":       bc.Description,
+		"email":             a.cfg.User.Email,
+		"version":           mastodonCompatibleVersion,
+		"urls":              map[string]any{},
+		"stats":             map[string]any{"user_count": 1, "status_count": 0, "domain_count": 0},
+		"languages":         []string{bc.Lang},
+		"registrations":     false,
+		"approval_required": false,
+		"invites_enabled":   false,
+		"configuration": map[string]any{
+			"statuses": map[string]any{
+				"max_characters":              100000,
+				"max_media_attachments":       10,
+				"characters_reserved_per_url": 23,
+			},
+			"media_attachments": map[string]any{
+				"supported_mime_types": []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+				"image_size_limit":     30 * 1024 * 1024,
+			},
+		},
+	})
+}
+
+// Apps and OAuth
+
+// Registration is unauthenticated, so new apps are saved with a separate prefix
+// and only kept when they get a token within a day
+
+func (a *goBlog) initMastodonAPI() {
+	if !a.mastodonAPIEnabled() {
+		return
+	}
+	a.hourlyHooks = append(a.hourlyHooks, func() {
+		if err := a.db.mastodonDeleteNewApps(time.Now().Add(-mastodonNewAppExpiry)); err != nil {
+			log.Println("Failed to delete unused Mastodon apps:", err.Error())
+		}
+	})
+}
+
+func (db *database) mastodonGetApp(clientID string) (*mastodonApp, error) {
+	data, err := db.retrievePersistentCache(mastodonAppKeyPrefix + clientID)
+	if err == nil && data == nil {
+		data, err = db.retrievePersistentCache(mastodonNewAppKeyPrefix + clientID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errors.New("unknown client")
+	}
+	app := &mastodonApp{}
+	return app, json.Unmarshal(data, app)
+}
+
+// Keep the app after it got the first token
+func (db *database) mastodonKeepApp(clientID string, app *mastodonApp) error {
+	data, _ := json.Marshal(app)
+	if err := db.cachePersistently(mastodonAppKeyPrefix+clientID, data); err != nil {
+		return err
+	}
+	return db.clearPersistentCache(mastodonNewAppKeyPrefix + clientID)
+}
+
+func (db *database) mastodonDeleteNewApps(before time.Time) error {
+	_, err := db.Exec(
+		"delete from persistent_cache where key like @prefix and date < @before",
+		sql.Named("prefix", mastodonNewAppKeyPrefix+"%"), sql.Named("before", before.UTC().Format(time.RFC3339)),
+	)
+	return err
+}
+
+func (app *mastodonApp) allowsRedirect(redirectURI string) bool {
+	return redirectURI != "" && lo.Contains(strings.Fields(app.RedirectURIs), redirectURI)
+}
+
+func (a *goBlog) mastodonRegisterApp(w http.ResponseWriter, r *http.Request) {
+	if err := mastodonParseForm(r); err != nil {
+		a.mastodonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	app := &mastodonApp{
+		Name:         r.Form.Get("client_name"),
+		Website:      r.Form.Get("website"),
+		RedirectURIs: r.Form.Get("redirect_uris"),
+		ClientSecret: uuid.NewString(),
+	}
+	if app.Name == "" || app.RedirectURIs == "" {
+		a.mastodonError(w, http.StatusUnprocessableEntity, "client_name and redirect_uris are required")
+		return
+	}
+	clientID := uuid.NewString()
+	data, _ := json.Marshal(app)
+	if err := a.db.cachePersistently(mastodonNewAppKeyPrefix+clientID, data); err != nil {
+		a.mastodonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.mastodonRespond(w, http.StatusOK, map[string]any{
+		"id":            clientID,
+		"name":          app.Name,
+		"website":       app.Website,
+		"redirect_uri":  app.RedirectURIs,
+		"client_id":     clientID,
+		"client_secret": app.ClientSecret,
+		"vapid_key":     "",
+	})
+}
+
+func (a *goBlog) mastodonAuthorize(w http.ResponseWriter, r *http.Request) {
+	clientID, redirectURI := r.FormValue("client_id"), r.FormValue("redirect_uri")
+	app, err := a.db.mastodonGetApp(clientID)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !app.allowsRedirect(redirectURI) {
+		a.serveError(w, r, "redirect_uri not registered", http.StatusBadRequest)
+		return
+	}
+	a.render(w, r, a.renderIndieAuth, &renderData{
+		Data: &indieAuthRenderData{
+			req: &indieauth.AuthenticationRequest{
+				ClientID:            clientID,
+				RedirectURI:         redirectURI,
+				State:               r.FormValue("state"),
+				Scopes:              mastodonScopesToMicropub(defaultIfEmpty(r.FormValue("scope"), "read")),
+				CodeChallenge:       r.FormValue("code_challenge"),
+				CodeChallengeMethod: r.FormValue("code_challenge_method"),
+			},
+			action:     mastodonOAuthPath + "/authorize",
+			clientName: app.Name,
+		},
+	})
+}
+
+// The user accepted the authorization request
+func (a *goBlog) mastodonAuthorizeAccept(w http.ResponseWriter, r *http.Request) {
+	clientID, redirectURI := r.FormValue("client_id"), r.FormValue("redirect_uri")
+	app, err := a.db.mastodonGetApp(clientID)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !app.allowsRedirect(redirectURI) {
+		a.serveError(w, r, "redirect_uri not registered", http.StatusBadRequest)
+		return
+	}
+	code, err := a.db.indieAuthSaveAuthRequest(&indieauth.AuthenticationRequest{
+		ClientID:            clientID,
+		RedirectURI:         redirectURI,
+		Scopes:              r.Form["scopes"],
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+	})
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if redirectURI == mastodonOOBRedirect {
+		// Show the code to copy it into the app
+		a.render(w, r, a.renderMastodonAuthCode, &renderData{Data: code})
+		return
+	}
+	query := url.Values{}
+	query.Set("code", code)
+	if state := r.FormValue("state"); state != "" {
+		query.Set("state", state)
+	}
+	http.Redirect(w, r, redirectURI+lo.If(strings.Contains(redirectURI, "?"), "&").Else("?")+query.Encode(), http.StatusFound)
+}
+
+func (a *goBlog) mastodonToken(w http.ResponseWriter, r *http.Request) {
+	if err := mastodonParseForm(r); err != nil {
+		a.mastodonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	clientID := r.Form.Get("client_id")
+	app, err := a.db.mastodonGetApp(clientID)
+	if err != nil || app.ClientSecret != r.Form.Get("client_secret") {
+		a.mastodonError(w, http.StatusUnauthorized, "invalid client")
+		return
+	}
+	var data *indieauth.AuthenticationRequest
+	switch r.Form.Get("grant_type") {
+	case "client_credentials":
+		// App token, clients only use it to identify themselves, so it isn't saved and can't be used for the API
+		a.mastodonRespond(w, http.StatusOK, map[string]any{
+			"access_token": uuid.NewString(),
+			"token_type":   "Bearer",
+			"scope":        "",
+			"created_at":   time.Now().Unix(),
+		})
+		return
+	case "authorization_code":
+		data, err = a.db.indieAuthGetAuthRequest(r.Form.Get("code"))
+		if err != nil {
+			a.mastodonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err = a.ias.ValidateTokenExchange(data, r); err != nil {
+			a.mastodonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		a.mastodonError(w, http.StatusBadRequest, "unsupported grant type")
+		return
+	}
+	token, err := a.db.indieAuthSaveToken(data)
+	if err == nil {
+		err = a.db.mastodonKeepApp(clientID, app)
+	}
+	if err != nil {
+		a.mastodonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.mastodonRespond(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"scope":        strings.Join(data.Scopes, " "),
+		"created_at":   time.Now().Unix(),
+	})
+}
+
+func (a *goBlog) mastodonRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := mastodonParseForm(r); err != nil {
+		a.mastodonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.db.indieAuthRevokeToken(r.Form.Get("token"))
+	a.mastodonRespond(w, http.StatusOK, map[string]any{})
+}
+
+// Accounts
+
+func (a *goBlog) mastodonAccount() map[string]any {
+	blog, bc := a.mastodonAPIBlog()
+	avatar := a.getFullAddress(a.profileImagePath(profileImageFormatJPEG, 0, 0))
+	return map[string]any{
+		"id":              "1",
+		"username":        blog,
+		"acct":            blog,
+		"display_name":    defaultIfEmpty(a.cfg.User.Name, bc.Title),
+		"locked":          false,
+		"bot":             false,
+		"discoverable":    true,
+		"group":           false,
+		"created_at":      time.Unix(0, 0).UTC().Format(time.RFC3339),
+		"note":            bc.Description,
+		"url":             a.getFullAddress(bc.getRelativePath("")),
+		"avatar":          avatar,
+		"avatar_static":   avatar,
+		"header":          avatar,
+		"header_static":   avatar,
+		"followers_count": 0,
+		"following_count": 0,
+		"statuses_count":  0,
+		"emojis":          []any{},
+		"fields":          []any{},
+		"source": map[string]any{
+			"privacy":   "public",
+			"sensitive": false,
+			"language":  bc.Lang,
+			"note":      bc.Description,
+			"fields":    []any{},
+		},
+	}
+}
+
+func (a *goBlog) mastodonVerifyCredentials(w http.ResponseWriter, _ *http.Request) {
+	a.mastodonRespond(w, http.StatusOK, a.mastodonAccount())
+}
+
+// Media
+
+func (a *goBlog) mastodonGetMedia(id string) (*mastodonMedia, error) {
+	data, err := a.db.retrievePersistentCache(mastodonMediaKeyPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errors.New("media not found")
+	}
+	m := &mastodonMedia{}
+	return m, json.Unmarshal(data, m)
+}
+
+func (a *goBlog) mastodonSaveMedia(id string, m *mastodonMedia) error {
+	data, _ := json.Marshal(m)
+	return a.db.cachePersistently(mastodonMediaKeyPrefix+id, data)
+}
+
+func mastodonAttachment(id string, m *mastodonMedia) map[string]any {
+	return map[string]any{
+		"id":          id,
+		"type":        "image",
+		"url":         m.URL,
+		"preview_url": m.URL,
+		"remote_url":  nil,
+		"description": lo.If[any](m.Description == "", nil).Else(m.Description),
+		"meta":        map[string]any{},
+		"blurhash":    nil,
+	}
+}
+
+func (a *goBlog) mastodonUploadMedia(w http.ResponseWriter, r *http.Request) {
+	if !a.mastodonCheckScope(w, r, "media") {
+		return
+	}
+	if err := r.ParseMultipartForm(0); err != nil {
+		a.mastodonError(w, http.StatusBadRequest, "failed to parse multipart form")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		a.mastodonError(w, http.StatusUnprocessableEntity, "failed to get multipart file")
+		return
+	}
+	defer file.Close()
+	location, err := a.uploadMediaFile(file, header.Filename, header.Header.Get(contentType))
+	if err != nil {
+		a.mastodonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	id := path.Base(location)
+	m := &mastodonMedia{URL: location, Description: r.FormValue("description")}
+	if err = a.mastodonSaveMedia(id, m); err != nil {
+		a.mastodonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.mastodonRespond(w, http.StatusOK, mastodonAttachment(id, m))
+}
+
+func (a *goBlog) mastodonServeMedia(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	m, err := a.mastodonGetMedia(id)
+	if err != nil {
+		a.mastodonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	a.mastodonRespond(w, http.StatusOK, mastodonAttachment(id, m))
+}
+
+func (a *goBlog) mastodonUpdateMedia(w http.ResponseWriter, r *http.Request) {
+	if !a.mastodonCheckScope(w, r, "media") {
+		return
+	}
+	if err := mastodonParseForm(r); err != nil {
+		a.mastodonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id := chi.URLParam(r, "id")
+	m, err := a.mastodonGetMedia(id)
+	if err != nil {
+		a.mastodonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if r.Form.Has("description") {
+		m.Description = r.Form.Get("description")
+		if err = a.mastodonSaveMedia(id, m); err != nil {
+			a.mastodonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	a.mastodonRespond(w, http.StatusOK, mastodonAttachment(id, m))
+}
+
+// Statuses
+
+var mastodonVisibilities = map[string]postVisibility{
+	"public":   visibilityPublic,
+	"unlisted": visibilityUnlisted,
+	"private":  visibilityPrivate,
+	"direct":   visibilityPrivate,
+}
+
+func (a *goBlog) mastodonCreateStatus(w http.ResponseWriter, r *http.Request) {
+	if !a.mastodonCheckScope(w, r, "create") {
+		return
+	}
+	if err := mastodonParseForm(r); err != nil {
+		a.mastodonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	blog, bc := a.mastodonAPIBlog()
+	p := &post{
+		Blog:       blog,
+		Section:    defaultIfEmpty(a.cfg.MastodonAPI.Section, bc.DefaultSection),
+		Content:    strings.TrimSpace(r.Form.Get("status")),
+		Status:     statusPublished,
+		Visibility: visibilityPublic,
+		Parameters: map[string][]string{},
+	}
+	if v := r.Form.Get("visibility"); v != "" {
+		visibility, ok := mastodonVisibilities[v]
+		if !ok {
+			a.mastodonError(w, http.StatusUnprocessableEntity, "invalid visibility")
+			return
+		}
+		p.Visibility = visibility
+	}
+	if cw := r.Form.Get("spoiler_text"); cw != "" {
+		p.Parameters[contentWarningParameter] = []string{cw}
+	}
+	if lang := r.Form.Get("language"); lang != "" && lang != bc.Lang && langCodeRegex.MatchString(lang) {
+		p.Parameters[postLangParameter] = []string{lang}
+	}
+	// Media
+	var photos, descriptions []string
+	for _, id := range r.Form["media_ids[]"] {
+		m, err := a.mastodonGetMedia(id)
+		if err != nil {
+			a.mastodonError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		photos = append(photos, m.URL)
+		descriptions = append(descriptions, m.Description)
+	}
+	if len(photos) > 0 {
+		p.Parameters[a.cfg.Micropub.PhotoParam] = photos
+		if lo.SomeBy(descriptions, func(d string) bool { return d != "" }) {
+			p.Parameters[a.cfg.Micropub.PhotoDescriptionParam] = descriptions
+		}
+	}
+	if p.Content == "" && len(photos) == 0 {
+		a.mastodonError(w, http.StatusUnprocessableEntity, "Validation failed: Text can't be blank")
+		return
+	}
+	if err := a.createPost(p); err != nil {
+		a.mastodonError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	a.mastodonRespond(w, http.StatusOK, a.mastodonStatus(p))
+}
+
+func (a *goBlog) mastodonStatus(p *post) map[string]any {
+	createdAt := time.Now().UTC()
+	if t, err := dateparse.ParseLocal(p.Published); err == nil {
+		createdAt = t.UTC()
+	}
+	attachments := []any{}
+	descriptions := p.Parameters[a.cfg.Micropub.PhotoDescriptionParam]
+	for i, photo := range p.Parameters[a.cfg.Micropub.PhotoParam] {
+		m := &mastodonMedia{URL: photo}
+		if i < len(descriptions) {
+			m.Description = descriptions[i]
+		}
+		attachments = append(attachments, mastodonAttachment(path.Base(photo), m))
+	}
+	visibility := "public"
+	for v, pv := range mastodonVisibilities {
+		if pv == p.Visibility && v != "direct" {
+			visibility = v
+		}
+	}
+	cw := p.firstParameter(contentWarningParameter)
+	return map[string]any{
+		"id":                     p.Path,
+		"uri":                    a.fullPostURL(p),
+		"url":                    a.fullPostURL(p),
+		"created_at":             createdAt.Format(time.RFC3339),
+		"content":                a.postHtml(&postHtmlOptions{p: p, absolute: true}),
+		"visibility":             visibility,
+		"sensitive":              cw != "",
+		"spoiler_text":           cw,
+		"language":               a.postLang(p),
+		"account":                a.mastodonAccount(),
+		"media_attachments":      attachments,
+		"mentions":               []any{},
+		"tags":                   []any{},
+		"emojis":                 []any{},
+		"replies_count":          0,
+		"reblogs_count":          0,
+		"favourites_count":       0,
+		"in_reply_to_id":         nil,
+		"in_reply_to_account_id": nil,
+		"reblog":                 nil,
+		"application":            nil,
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/hacdias/indieauth/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mastodonAPI(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.MastodonAPI = &configMastodonAPI{Enabled: true}
+
+	require.NoError(t, app.initConfig(false))
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	app.initIndieAuth()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	baseURL := "http://localhost:8080"
+
+	// Instance
+	var instance map[string]any
+	err := requests.URL(baseURL + "/api/v1/instance").Client(client).ToJSON(&instance).Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", instance["uri"])
+
+	// Register app
+	var registeredApp map[string]any
+	err = requests.URL(baseURL + "/api/v1/apps").Client(client).
+		BodyForm(url.Values{"client_name": {"Test App"}, "redirect_uris": {"https://app.example.org/callback"}, "scopes": {"read write"}}).
+		ToJSON(&registeredApp).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	clientID, clientSecret := registeredApp["client_id"].(string), registeredApp["client_secret"].(string)
+	require.NotEmpty(t, clientID)
+	require.NotEmpty(t, clientSecret)
+
+	// Authorization page
+	var body string
+	err = requests.URL(baseURL+"/oauth/authorize").Client(client).
+		Param("client_id", clientID).Param("redirect_uri", "https://app.example.org/callback").
+		Param("response_type", "code").Param("scope", "read write").
+		ToString(&body).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, body, "Test App")
+	assert.Contains(t, body, "action=/oauth/authorize")
+
+	// Unregistered redirect
+	err = requests.URL(baseURL+"/oauth/authorize").Client(client).
+		Param("client_id", clientID).Param("redirect_uri", "https://evil.example.org/callback").
+		Fetch(context.Background())
+	assert.Error(t, err)
+
+	// Accept
+	var location string
+	err = requests.URL(baseURL+"/oauth/authorize").Client(client).
+		BasicAuth("test", "test").
+		BodyForm(url.Values{
+			"client_id":    {clientID},
+			"redirect_uri": {"https://app.example.org/callback"},
+			"state":        {"abc"},
+			"scopes":       {"create", "media"},
+		}).
+		CheckStatus(http.StatusFound).
+		AddValidator(func(r *http.Response) error {
+			location = r.Header.Get("Location")
+			return nil
+		}).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	locationURL, err := url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, "app.example.org", locationURL.Host)
+	assert.Equal(t, "abc", locationURL.Query().Get("state"))
+	code := locationURL.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	// Token with wrong secret
+	err = requests.URL(baseURL + "/oauth/token").Client(client).
+		BodyJSON(map[string]any{"grant_type": "authorization_code", "code": code, "client_id": clientID, "client_secret": "wrong", "redirect_uri": "https://app.example.org/callback"}).
+		Fetch(context.Background())
+	assert.Error(t, err)
+
+	// Token
+	var tokenResponse map[string]any
+	err = requests.URL(baseURL + "/oauth/token").Client(client).
+		BodyJSON(map[string]any{"grant_type": "authorization_code", "code": code, "client_id": clientID, "client_secret": clientSecret, "redirect_uri": "https://app.example.org/callback"}).
+		ToJSON(&tokenResponse).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	token := tokenResponse["access_token"].(string)
+	require.NotEmpty(t, token)
+	assert.Equal(t, "create media", tokenResponse["scope"])
+
+	// Unused apps are deleted
+	var unusedApp map[string]any
+	err = requests.URL(baseURL + "/api/v1/apps").Client(client).
+		BodyForm(url.Values{"client_name": {"Unused App"}, "redirect_uris": {"https://unused.example.org/callback"}}).
+		ToJSON(&unusedApp).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	unusedClientID := unusedApp["client_id"].(string)
+	require.NoError(t, app.db.mastodonDeleteNewApps(time.Now().Add(-time.Hour)))
+	_, err = app.db.mastodonGetApp(unusedClientID)
+	require.NoError(t, err)
+	require.NoError(t, app.db.mastodonDeleteNewApps(time.Now().Add(time.Minute)))
+	_, err = app.db.mastodonGetApp(unusedClientID)
+	assert.Error(t, err)
+
+	// Apps with a token are kept
+	_, err = app.db.mastodonGetApp(clientID)
+	require.NoError(t, err)
+
+	// Verify credentials
+	err = requests.URL(baseURL + "/api/v1/accounts/verify_credentials").Client(client).
+		Fetch(context.Background())
+	assert.Error(t, err)
+
+	var account map[string]any
+	err = requests.URL(baseURL + "/api/v1/accounts/verify_credentials").Client(client).
+		Bearer(token).
+		ToJSON(&account).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, app.cfg.DefaultBlog, account["username"])
+
+	// Post a status with media
+	require.NoError(t, app.mastodonSaveMedia("abc.jpg", &mastodonMedia{URL: "https://example.com/m/abc.jpg", Description: "A photo"}))
+	var status map[string]any
+	err = requests.URL(baseURL + "/api/v1/statuses").Client(client).
+		Bearer(token).
+		BodyJSON(map[string]any{"status": "Hello from a Mastodon app", "visibility": "unlisted", "spoiler_text": "Greeting", "media_ids": []string{"abc.jpg"}}).
+		ToJSON(&status).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "unlisted", status["visibility"])
+	assert.Contains(t, status["content"], "Hello from a Mastodon app")
+	if attachments, ok := status["media_attachments"].([]any); assert.True(t, ok) && assert.Len(t, attachments, 1) {
+		assert.Equal(t, "https://example.com/m/abc.jpg", attachments[0].(map[string]any)["url"])
+	}
+
+	p, err := app.getPost(status["id"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello from a Mastodon app", p.Content)
+	assert.Equal(t, visibilityUnlisted, p.Visibility)
+	assert.Equal(t, "posts", p.Section)
+	assert.Equal(t, "Greeting", p.firstParameter(contentWarningParameter))
+	assert.Equal(t, []string{"https://example.com/m/abc.jpg"}, p.Parameters[app.cfg.Micropub.PhotoParam])
+	assert.Equal(t, []string{"A photo"}, p.Parameters[app.cfg.Micropub.PhotoDescriptionParam])
+
+	// Empty status
+	err = requests.URL(baseURL + "/api/v1/statuses").Client(client).
+		Bearer(token).
+		BodyForm(url.Values{"status": {""}}).
+		CheckStatus(http.StatusUnprocessableEntity).
+		Fetch(context.Background())
+	assert.NoError(t, err)
+
+	// Token without write scope
+	readToken, err := app.db.indieAuthSaveToken(&indieauth.AuthenticationRequest{ClientID: clientID})
+	require.NoError(t, err)
+	err = requests.URL(baseURL + "/api/v1/statuses").Client(client).
+		Bearer(readToken).
+		BodyForm(url.Values{"status": {"Not allowed"}}).
+		CheckStatus(http.StatusForbidden).
+		Fetch(context.Background())
+	assert.NoError(t, err)
+
+	// App tokens can't be used to read drafts or private posts
+	require.NoError(t, app.createPost(&post{Path: "/secret-draft", Status: statusDraft, Visibility: visibilityPrivate, Content: "Secret draft"}))
+	var appTokenResponse map[string]any
+	err = requests.URL(baseURL + "/oauth/token").Client(client).
+		BodyForm(url.Values{"grant_type": {"client_credentials"}, "client_id": {clientID}, "client_secret": {clientSecret}}).
+		ToJSON(&appTokenResponse).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	appToken := appTokenResponse["access_token"].(string)
+	require.NotEmpty(t, appToken)
+	getSource := func(token string) (status int, body string) {
+		_ = requests.URL(baseURL+micropubPath).Client(client).
+			Param("q", "source").Param("url", app.getFullAddress("/secret-draft")).
+			Bearer(token).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			ToString(&body).
+			Fetch(context.Background())
+		return
+	}
+	srcStatus, srcBody := getSource(appToken)
+	assert.Equal(t, http.StatusUnauthorized, srcStatus)
+	assert.NotContains(t, srcBody, "Secret draft")
+	err = requests.URL(baseURL + "/api/v1/accounts/verify_credentials").Client(client).
+		Bearer(appToken).
+		Fetch(context.Background())
+	assert.Error(t, err)
+
+	// The source query needs the update scope
+	srcStatus, srcBody = getSource(readToken)
+	assert.Equal(t, http.StatusForbidden, srcStatus)
+	assert.NotContains(t, srcBody, "Secret draft")
+	updateToken, err := app.db.indieAuthSaveToken(&indieauth.AuthenticationRequest{ClientID: clientID, Scopes: []string{"update"}})
+	require.NoError(t, err)
+	srcStatus, srcBody = getSource(updateToken)
+	assert.Equal(t, http.StatusOK, srcStatus)
+	assert.Contains(t, srcBody, "Secret draft")
+}
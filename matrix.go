@@ -61,3 +61,17 @@ func (a *goBlog) sendMatrix(mtx *configMatrix, message string) (string, error) {
 	}
 	return resp.EventID.String(), nil
 }
+
+type matrixNotifier struct {
+	a   *goBlog
+	cfg *configMatrix
+}
+
+func (n *matrixNotifier) acceptsNotification(typ notificationType) bool {
+	return notificationEventsAllowed(n.cfg.Events, typ)
+}
+
+func (n *matrixNotifier) sendNotification(text string) error {
+	_, err := n.a.sendMatrix(n.cfg, text)
+	return err
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,6 +14,33 @@ const (
 	mediaFileRoute = `/{file:[0-9a-fA-F]+(\.[0-9a-zA-Z]+)?}`
 )
 
+// Content types of audio and video files, because minimal systems (like Docker images) often
+// don't have a mime.types file and players need the right type to play and seek the files
+var mediaContentTypes = map[string]string{
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".mp4":  "video/mp4",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".ogv":  "video/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".webm": "video/webm",
+}
+
+func init() {
+	for ext, typ := range mediaContentTypes {
+		if mime.TypeByExtension(ext) == "" {
+			_ = mime.AddExtensionType(ext, typ)
+		}
+	}
+}
+
+// Media files support range requests (for seeking in audio and video files) and conditional requests,
+// files without a known extension get the content type detected from the content
 func (*goBlog) serveMediaFile(w http.ResponseWriter, r *http.Request) {
 	f := filepath.Join(mediaFilePath, chi.URLParam(r, "file"))
 	_, err := os.Stat(f)
@@ -22,5 +50,10 @@ func (*goBlog) serveMediaFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Add(cacheControl, "public,max-age=31536000,immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if ext := filepath.Ext(f); ext == ".html" || ext == ".htm" {
+		// Archived web pages must not run scripts in the context of the blog
+		w.Header().Set("Content-Security-Policy", "sandbox")
+	}
 	http.ServeFile(w, r, f)
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+// Media library: uploaded files are recorded in the database with their content type and size,
+// the library combines them with the files of the media storage and counts their uses in posts.
+// Files without uses (orphans) can be listed separately, for example to clean up the storage.
+
+const (
+	mediaLibraryPath  = "/api/media"
+	mediaOrphansParam = "orphans"
+
+	// Bytes used to detect the content type
+	mediaSniffLen = 512
+)
+
+// Writer that counts the bytes of the saved file and keeps the beginning to detect the content type
+type mediaFileRecorder struct {
+	size  int64
+	sniff []byte
+}
+
+func (m *mediaFileRecorder) Write(p []byte) (int, error) {
+	if missing := mediaSniffLen - len(m.sniff); missing > 0 {
+		m.sniff = append(m.sniff, p[:lo.Min([]int{missing, len(p)})]...)
+	}
+	m.size += int64(len(p))
+	return len(p), nil
+}
+
+// Content type by the extension of the file, or detected from the content
+func (m *mediaFileRecorder) contentType(filename string) string {
+	if typ := mime.TypeByExtension(filepath.Ext(filename)); typ != "" {
+		return typ
+	}
+	return http.DetectContentType(m.sniff)
+}
+
+func (db *database) saveMediaRecord(name, typ string, size int64) error {
+	_, err := db.Exec(
+		"insert or replace into media_files (name, type, size, created) values (@name, @type, @size, @created)",
+		sql.Named("name", name), sql.Named("type", typ), sql.Named("size", size), sql.Named("created", utcNowString()),
+	)
+	return err
+}
+
+func (db *database) deleteMediaRecord(name string) error {
+	_, err := db.Exec("delete from media_files where name = @name", sql.Named("name", name))
+	return err
+}
+
+// Content types of the recorded files by name
+func (db *database) mediaRecordTypes() (map[string]string, error) {
+	rows, err := db.Query("select name, type from media_files")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types := map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err = rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		types[name] = typ
+	}
+	return types, rows.Err()
+}
+
+// All files of the media storage, newest first, with their content type and number of uses
+func (a *goBlog) mediaLibrary(orphansOnly bool) ([]*mediaFile, error) {
+	files, err := a.mediaFiles()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time.After(files[j].Time)
+	})
+	types, err := a.db.mediaRecordTypes()
+	if err != nil {
+		return nil, err
+	}
+	uses, err := a.db.usesOfMediaFile(lo.Map(files, func(f *mediaFile, _ int) string { return f.Name })...)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		f.Uses = uses[i]
+		// Files uploaded before they were recorded only have the type of the extension
+		f.Type = defaultIfEmpty(types[f.Name], mime.TypeByExtension(filepath.Ext(f.Name)))
+	}
+	if orphansOnly {
+		files = lo.Filter(files, func(f *mediaFile, _ int) bool { return f.Uses == 0 })
+	}
+	return files, nil
+}
+
+func (a *goBlog) serveMediaLibrary(w http.ResponseWriter, r *http.Request) {
+	files, err := a.mediaLibrary(r.URL.Query().Get(mediaOrphansParam) == "true")
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, f := range files {
+		f.Location = a.getFullAddress(f.Location)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(json.NewEncoder(pw).Encode(lo.If(files == nil, []*mediaFile{}).Else(files)))
+	}()
+	w.Header().Set(contentType, contenttype.JSONUTF8)
+	_ = pr.CloseWithError(a.min.Get().Minify(contenttype.JSON, w, pr))
+}
+
+func (a *goBlog) serveMediaLibraryDelete(w http.ResponseWriter, r *http.Request) {
+	if err := a.deleteMediaFile(chi.URLParam(r, "file")); errors.Is(err, os.ErrNotExist) {
+		a.serveError(w, r, "File not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mediaLibrary(t *testing.T) {
+	h := newTestHarness(t)
+	a := h.app
+	a.mediaStorageInit.Do(func() {
+		a.mediaStorage = &localMediaStorage{path: t.TempDir()}
+	})
+
+	_, err := a.saveMediaFile("aaaa.png", strings.NewReader("image"))
+	require.NoError(t, err)
+	_, err = a.saveMediaFile("bbbb", strings.NewReader("%PDF-1.4 document"))
+	require.NoError(t, err)
+	h.createPost(&post{Path: "/media", Content: "![Image](/m/aaaa.png)"})
+
+	list := func(query string) (files []*mediaFile) {
+		require.NoError(t, h.loggedInRequest(mediaLibraryPath+query).ToJSON(&files).Fetch(context.Background()))
+		return files
+	}
+
+	files := list("")
+	require.Len(t, files, 2)
+	byName := map[string]*mediaFile{}
+	for _, f := range files {
+		byName[f.Name] = f
+	}
+	assert.Equal(t, "image/png", byName["aaaa.png"].Type)
+	assert.Equal(t, int64(5), byName["aaaa.png"].Size)
+	assert.Equal(t, 1, byName["aaaa.png"].Uses)
+	assert.Equal(t, "https://example.com/m/aaaa.png", byName["aaaa.png"].Location)
+	assert.Equal(t, "application/pdf", byName["bbbb"].Type)
+	assert.Equal(t, 0, byName["bbbb"].Uses)
+
+	// Orphans
+	files = list("?" + mediaOrphansParam + "=true")
+	require.Len(t, files, 1)
+	assert.Equal(t, "bbbb", files[0].Name)
+
+	// Editor page
+	var page string
+	require.NoError(t, h.loggedInRequest("/editor/files?"+mediaOrphansParam+"=true").ToString(&page).Fetch(context.Background()))
+	assert.Contains(t, page, "bbbb")
+	assert.NotContains(t, page, "aaaa.png")
+
+	// Delete
+	require.NoError(t, h.loggedInRequest(mediaLibraryPath+"/bbbb").Delete().CheckStatus(http.StatusNoContent).Fetch(context.Background()))
+	assert.Len(t, list(""), 1)
+	types, err := a.db.mediaRecordTypes()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"aaaa.png": "image/png"}, types)
+	require.NoError(t, h.loggedInRequest(mediaLibraryPath+"/bbbb").Delete().Header("Accept", "application/json").CheckStatus(http.StatusNotFound).Fetch(context.Background()))
+
+	// Login required
+	_, _, body := h.get(mediaLibraryPath)
+	assert.NotContains(t, body, "aaaa.png")
+}
@@ -38,10 +38,14 @@ func (a *goBlog) saveMediaFile(filename string, f io.Reader) (string, error) {
 	if a.mediaStorage == nil {
 		return "", errNoMediaStorageConfigured
 	}
-	loc, err := a.mediaStorage.save(filename, f)
+	recorder := &mediaFileRecorder{}
+	loc, err := a.mediaStorage.save(filename, io.TeeReader(f, recorder))
 	if err != nil {
 		return "", err
 	}
+	if err = a.db.saveMediaRecord(filename, recorder.contentType(filename), recorder.size); err != nil {
+		return "", err
+	}
 	return a.getFullAddress(loc), nil
 }
 
@@ -50,14 +54,20 @@ func (a *goBlog) deleteMediaFile(filename string) error {
 	if a.mediaStorage == nil {
 		return errNoMediaStorageConfigured
 	}
-	return a.mediaStorage.delete(filepath.Base(filename))
+	filename = filepath.Base(filename)
+	if err := a.mediaStorage.delete(filename); err != nil {
+		return err
+	}
+	return a.db.deleteMediaRecord(filename)
 }
 
 type mediaFile struct {
-	Name     string
-	Location string
-	Time     time.Time
-	Size     int64
+	Name     string    `json:"name"`
+	Location string    `json:"location"`
+	Time     time.Time `json:"time"`
+	Size     int64     `json:"size"`
+	Type     string    `json:"type"`
+	Uses     int       `json:"uses"` // Approximate, from the post contents and parameters
 }
 
 func (a *goBlog) mediaFiles() ([]*mediaFile, error) {
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
+)
+
+// Operational metrics in the Prometheus text format
+
+const metricsPath = "/metrics"
+
+// Upper bounds of the request duration histogram buckets in seconds
+var metricsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Route groups by path prefix, all other requests are in the "page" or "feed" group
+var metricsRouteGroups = []struct{ prefix, group string }{
+	{"/activitypub", "activitypub"},
+	{"/.well-known/webfinger", "activitypub"},
+	{"/.well-known/nodeinfo", "activitypub"},
+	{"/nodeinfo", "activitypub"},
+	{webmentionPath, "webmention"},
+	{micropubPath, "micropub"},
+	{indieAuthPath, "indieauth"},
+	{"/api", "api"},
+	{mastodonOAuthPath, "api"},
+	{"/m/", "media"},
+	{metricsPath, "metrics"},
+}
+
+type metricsCollector struct {
+	mutex    sync.Mutex
+	requests map[metricsRequestKey]uint64
+	duration map[string]*metricsHistogram
+	// ActivityPub deliveries
+	apFailures     atomic.Uint64
+	apRemovedInbox atomic.Uint64
+}
+
+type metricsRequestKey struct {
+	group, status string
+}
+
+type metricsHistogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (a *goBlog) getMetrics() *metricsCollector {
+	a.metricsInit.Do(func() {
+		a.metrics = &metricsCollector{
+			requests: map[metricsRequestKey]uint64{},
+			duration: map[string]*metricsHistogram{},
+		}
+	})
+	return a.metrics
+}
+
+func (mc *configMetrics) enabled() bool {
+	return mc != nil && mc.Enabled
+}
+
+func (a *goBlog) initMetricsConfig() (err error) {
+	if mc := a.cfg.Metrics; mc != nil {
+		if mc.allowedIPs, err = parseIPNets(mc.AllowedIPs); err != nil {
+			return errors.New("invalid metrics allowed IP: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func metricsRouteGroup(path string) string {
+	for _, rg := range metricsRouteGroups {
+		if strings.HasPrefix(path, rg.prefix) {
+			return rg.group
+		}
+	}
+	if ext := strings.TrimPrefix(strings.TrimPrefix(pathExt(path), "."), "min."); lo.Contains([]string{"rss", "atom", "json"}, ext) {
+		return "feed"
+	}
+	return "page"
+}
+
+// Extension of the last path segment, including a "min." prefix for minimal feeds
+func pathExt(path string) string {
+	segment := path[strings.LastIndex(path, "/")+1:]
+	if i := strings.Index(segment, "."); i >= 0 {
+		return segment[i:]
+	}
+	return ""
+}
+
+func (a *goBlog) metricsMiddleware(next http.Handler) http.Handler {
+	m := a.getMetrics()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		m.observeRequest(metricsRouteGroup(r.URL.Path), ww.Status(), time.Since(start))
+	})
+}
+
+func (m *metricsCollector) observeRequest(group string, status int, duration time.Duration) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[metricsRequestKey{group: group, status: fmt.Sprintf("%dxx", status/100)}]++
+	h, ok := m.duration[group]
+	if !ok {
+		h = &metricsHistogram{buckets: make([]uint64, len(metricsDurationBuckets))}
+		m.duration[group] = h
+	}
+	seconds := duration.Seconds()
+	for i, le := range metricsDurationBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Serve the metrics to the logged in user (e.g. with an app password) or allowed IP addresses
+func (a *goBlog) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if ip := net.ParseIP(a.clientIP(r)); ip == nil || !ipInNets(ip, a.cfg.Metrics.allowedIPs) {
+		a.authMiddleware(http.HandlerFunc(a.writeMetrics)).ServeHTTP(w, r)
+		return
+	}
+	a.writeMetrics(w, r)
+}
+
+func (a *goBlog) writeMetrics(w http.ResponseWriter, _ *http.Request) {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	a.getMetrics().writeHTTPMetrics(buf)
+	a.writeCacheMetrics(buf)
+	a.writeQueueMetrics(buf)
+	writeRuntimeMetrics(buf)
+	w.Header().Set(contentType, "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+func writeMetricHeader(w io.Writer, name, typ, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m *metricsCollector) writeHTTPMetrics(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	writeMetricHeader(w, "goblog_http_requests_total", "counter", "Number of HTTP requests by route group and status class.")
+	keys := lo.Keys(m.requests)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].group < keys[j].group || keys[i].group == keys[j].group && keys[i].status < keys[j].status
+	})
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "goblog_http_requests_total{group=%q,status=%q} %d\n", k.group, k.status, m.requests[k])
+	}
+	writeMetricHeader(w, "goblog_http_request_duration_seconds", "histogram", "Duration of HTTP requests by route group.")
+	for _, group := range sortedStrings(lo.Keys(m.duration)) {
+		h := m.duration[group]
+		for i, le := range metricsDurationBuckets {
+			_, _ = fmt.Fprintf(w, "goblog_http_request_duration_seconds_bucket{group=%q,le=\"%g\"} %d\n", group, le, h.buckets[i])
+		}
+		_, _ = fmt.Fprintf(w, "goblog_http_request_duration_seconds_bucket{group=%q,le=\"+Inf\"} %d\n", group, h.count)
+		_, _ = fmt.Fprintf(w, "goblog_http_request_duration_seconds_sum{group=%q} %g\n", group, h.sum)
+		_, _ = fmt.Fprintf(w, "goblog_http_request_duration_seconds_count{group=%q} %d\n", group, h.count)
+	}
+	writeMetricHeader(w, "goblog_activitypub_delivery_failures_total", "counter", "Number of failed ActivityPub deliveries, including retries.")
+	_, _ = fmt.Fprintf(w, "goblog_activitypub_delivery_failures_total %d\n", m.apFailures.Load())
+	writeMetricHeader(w, "goblog_activitypub_removed_inboxes_total", "counter", "Number of inboxes removed after repeatedly failed deliveries.")
+	_, _ = fmt.Fprintf(w, "goblog_activitypub_removed_inboxes_total %d\n", m.apRemovedInbox.Load())
+}
+
+func (a *goBlog) writeCacheMetrics(w io.Writer) {
+	if a.cache == nil {
+		return
+	}
+	writeMetricHeader(w, "goblog_cache_requests_total", "counter", "Number of cacheable requests by result (hit, stale or miss).")
+	_, _ = fmt.Fprintf(w, "goblog_cache_requests_total{result=\"hit\"} %d\n", a.cache.hits.Load())
+	_, _ = fmt.Fprintf(w, "goblog_cache_requests_total{result=\"stale\"} %d\n", a.cache.staleHits.Load())
+	_, _ = fmt.Fprintf(w, "goblog_cache_requests_total{result=\"miss\"} %d\n", a.cache.misses.Load())
+}
+
+// Items in the queues: "ap" for ActivityPub deliveries, "wm" for webmention verifications
+func (a *goBlog) writeQueueMetrics(w io.Writer) {
+	if a.db == nil {
+		return
+	}
+	rows, err := a.db.Query("select name, count(*) from queue group by name order by name")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	counts := map[string]int{"ap": 0, "wm": 0}
+	for rows.Next() {
+		var name string
+		var count int
+		if rows.Scan(&name, &count) == nil {
+			counts[name] = count
+		}
+	}
+	writeMetricHeader(w, "goblog_queue_items", "gauge", "Number of items in the queue (ap: ActivityPub deliveries, wm: webmention verifications).")
+	for _, name := range sortedStrings(lo.Keys(counts)) {
+		_, _ = fmt.Fprintf(w, "goblog_queue_items{queue=%q} %d\n", name, counts[name])
+	}
+}
+
+func writeRuntimeMetrics(w io.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	writeMetricHeader(w, "go_goroutines", "gauge", "Number of goroutines that currently exist.")
+	_, _ = fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+	writeMetricHeader(w, "go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use.")
+	_, _ = fmt.Fprintf(w, "go_memstats_heap_alloc_bytes %d\n", ms.HeapAlloc)
+	writeMetricHeader(w, "go_memstats_heap_objects", "gauge", "Number of allocated objects.")
+	_, _ = fmt.Fprintf(w, "go_memstats_heap_objects %d\n", ms.HeapObjects)
+	writeMetricHeader(w, "go_memstats_sys_bytes", "gauge", "Number of bytes obtained from the system.")
+	_, _ = fmt.Fprintf(w, "go_memstats_sys_bytes %d\n", ms.Sys)
+	writeMetricHeader(w, "go_memstats_next_gc_bytes", "gauge", "Number of heap bytes when the next garbage collection will take place.")
+	_, _ = fmt.Fprintf(w, "go_memstats_next_gc_bytes %d\n", ms.NextGC)
+	writeMetricHeader(w, "go_memstats_last_gc_time_seconds", "gauge", "Number of seconds since 1970 of the last garbage collection.")
+	_, _ = fmt.Fprintf(w, "go_memstats_last_gc_time_seconds %g\n", float64(ms.LastGC)/1e9)
+	writeMetricHeader(w, "go_gc_duration_seconds", "summary", "Pause duration of garbage collection cycles.")
+	_, _ = fmt.Fprintf(w, "go_gc_duration_seconds_sum %g\n", float64(ms.PauseTotalNs)/1e9)
+	_, _ = fmt.Fprintf(w, "go_gc_duration_seconds_count %d\n", ms.NumGC)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_metricsRouteGroup(t *testing.T) {
+	for path, group := range map[string]string{
+		"/":                       "page",
+		"/posts/test":             "page",
+		"/posts.rss":              "feed",
+		"/tags/go.min.atom":       "feed",
+		"/activitypub/inbox/main": "activitypub",
+		"/.well-known/webfinger":  "activitypub",
+		"/webmention":             "webmention",
+		"/micropub/media":         "micropub",
+		"/api/v1/instance":        "api",
+		"/m/image.jpg":            "media",
+		"/metrics":                "metrics",
+	} {
+		assert.Equal(t, group, metricsRouteGroup(path), path)
+	}
+}
+
+func Test_metrics(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Metrics = &configMetrics{
+		Enabled:    true,
+		AllowedIPs: []string{"192.0.2.1"},
+	}
+	app.cfg.User = &configUser{
+		Nick:         "test",
+		Password:     "pass",
+		AppPasswords: []*configAppPassword{{Username: "app", Password: "pass"}},
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	request := func(path, ip string, login bool) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if login {
+			req.SetBasicAuth("app", "pass")
+		}
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		return res.StatusCode, string(body)
+	}
+
+	require.NoError(t, app.enqueue("ap", []byte("test"), time.Now()))
+
+	request("/", "192.0.2.2", false)
+	request("/", "192.0.2.2", false)
+	request("/.rss", "192.0.2.2", false)
+	request("/notfound", "192.0.2.2", false)
+
+	// Allowed IP address
+	status, body := request(metricsPath, "192.0.2.1", false)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "# TYPE goblog_http_requests_total counter\n")
+	assert.Contains(t, body, `goblog_http_requests_total{group="page",status="2xx"} 2`)
+	assert.Contains(t, body, `goblog_http_requests_total{group="page",status="4xx"} 1`)
+	assert.Contains(t, body, `goblog_http_requests_total{group="feed",status="2xx"} 1`)
+	assert.Contains(t, body, `goblog_http_request_duration_seconds_bucket{group="page",le="+Inf"} 3`)
+	assert.Contains(t, body, `goblog_http_request_duration_seconds_count{group="feed"} 1`)
+	assert.Contains(t, body, `goblog_cache_requests_total{result="hit"} 1`)
+	assert.Contains(t, body, `goblog_queue_items{queue="ap"} 1`)
+	assert.Contains(t, body, `goblog_queue_items{queue="wm"} 0`)
+	assert.Contains(t, body, "goblog_activitypub_delivery_failures_total 0\n")
+	assert.Contains(t, body, "# TYPE go_goroutines gauge\n")
+	assert.Contains(t, body, "go_gc_duration_seconds_count ")
+
+	// Other IP addresses need to login
+	_, body = request(metricsPath, "192.0.2.2", false)
+	assert.NotContains(t, body, "goblog_http_requests_total")
+	status, body = request(metricsPath, "192.0.2.2", true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `goblog_http_requests_total{group="metrics",status="2xx"} 2`) // The login form has status 200 too
+}
@@ -28,8 +28,13 @@ func (a *goBlog) serveMicropubQuery(w http.ResponseWriter, r *http.Request) {
 			"channels":       channels,
 			"media-endpoint": a.getFullAddress(micropubPath + micropubMediaSubPath),
 			"visibility":     []postVisibility{visibilityPublic, visibilityUnlisted, visibilityPrivate},
+			"syndicate-to":   a.getMicropubSyndicateToList(),
 		}
 	case "source":
+		// Drafts and private posts are only returned to clients that are allowed to edit posts
+		if !a.micropubCheckScope(w, r, "update") {
+			return
+		}
 		if urlString := query.Get("url"); urlString != "" {
 			u, err := url.Parse(query.Get("url"))
 			if err != nil {
@@ -41,6 +46,7 @@ func (a *goBlog) serveMicropubQuery(w http.ResponseWriter, r *http.Request) {
 				a.serveError(w, r, err.Error(), http.StatusBadRequest)
 				return
 			}
+			setPostVersionHeaders(w, p)
 			result = a.postToMfItem(p)
 		} else {
 			posts, err := a.getPosts(&postsRequestConfig{
@@ -71,6 +77,8 @@ func (a *goBlog) serveMicropubQuery(w http.ResponseWriter, r *http.Request) {
 	case "channel":
 		channels := a.getMicropubChannelsMap()
 		result = map[string]any{"channels": channels}
+	case "syndicate-to":
+		result = map[string]any{"syndicate-to": a.getMicropubSyndicateToList()}
 	default:
 		a.serve404(w, r)
 		return
@@ -206,6 +214,10 @@ func (a *goBlog) micropubParseValuePostParamsValueMap(entry *post, values map[st
 		entry.Parameters[a.cfg.Micropub.LikeParam] = likeOf
 		delete(values, "like-of")
 	}
+	if repostOf, ok := values["repost-of"]; ok {
+		entry.Parameters[a.cfg.Micropub.RepostParam] = repostOf
+		delete(values, "repost-of")
+	}
 	if bookmarkOf, ok := values["bookmark-of"]; ok {
 		entry.Parameters[a.cfg.Micropub.BookmarkParam] = bookmarkOf
 		delete(values, "bookmark-of")
@@ -235,6 +247,13 @@ func (a *goBlog) micropubParseValuePostParamsValueMap(entry *post, values map[st
 		entry.Parameters[a.cfg.Micropub.LocationParam] = location
 		delete(values, "location")
 	}
+	if syndicateTo, ok := values["mp-syndicate-to"]; ok {
+		entry.Parameters[syndicateToParameter] = syndicateTo
+		delete(values, "mp-syndicate-to")
+	} else if syndicateTo, ok := values["mp-syndicate-to[]"]; ok {
+		entry.Parameters[syndicateToParameter] = syndicateTo
+		delete(values, "mp-syndicate-to[]")
+	}
 	for n, p := range values {
 		entry.Parameters[n] = append(entry.Parameters[n], p...)
 	}
@@ -260,21 +279,25 @@ type microformatItem struct {
 }
 
 type microformatProperties struct {
-	Name       []string `json:"name,omitempty"`
-	Published  []string `json:"published,omitempty"`
-	Updated    []string `json:"updated,omitempty"`
-	PostStatus []string `json:"post-status,omitempty"`
-	Visibility []string `json:"visibility,omitempty"`
-	Category   []string `json:"category,omitempty"`
-	Content    []string `json:"content,omitempty"`
-	URL        []string `json:"url,omitempty"`
-	InReplyTo  []string `json:"in-reply-to,omitempty"`
-	LikeOf     []string `json:"like-of,omitempty"`
-	BookmarkOf []string `json:"bookmark-of,omitempty"`
-	MpSlug     []string `json:"mp-slug,omitempty"`
-	Photo      []any    `json:"photo,omitempty"`
-	Audio      []string `json:"audio,omitempty"`
-	MpChannel  []string `json:"mp-channel,omitempty"`
+	Name          []string `json:"name,omitempty"`
+	Published     []string `json:"published,omitempty"`
+	Updated       []string `json:"updated,omitempty"`
+	PostStatus    []string `json:"post-status,omitempty"`
+	Visibility    []string `json:"visibility,omitempty"`
+	Category      []string `json:"category,omitempty"`
+	Content       []string `json:"content,omitempty"`
+	URL           []string `json:"url,omitempty"`
+	InReplyTo     []string `json:"in-reply-to,omitempty"`
+	LikeOf        []string `json:"like-of,omitempty"`
+	RepostOf      []string `json:"repost-of,omitempty"`
+	BookmarkOf    []string `json:"bookmark-of,omitempty"`
+	MpSlug        []string `json:"mp-slug,omitempty"`
+	Photo         []any    `json:"photo,omitempty"`
+	Audio         []string `json:"audio,omitempty"`
+	MpChannel     []string `json:"mp-channel,omitempty"`
+	MpSyndicateTo []string `json:"mp-syndicate-to,omitempty"`
+	MpTemplate    []string `json:"mp-template,omitempty"`
+	Syndication   []string `json:"syndication,omitempty"`
 }
 
 func (a *goBlog) micropubParsePostParamsMfItem(entry *post, mf *microformatItem) error {
@@ -324,12 +347,21 @@ func (a *goBlog) micropubParsePostParamsMfItem(entry *post, mf *microformatItem)
 	if len(mf.Properties.LikeOf) > 0 {
 		entry.Parameters[a.cfg.Micropub.LikeParam] = mf.Properties.LikeOf
 	}
+	if len(mf.Properties.RepostOf) > 0 {
+		entry.Parameters[a.cfg.Micropub.RepostParam] = mf.Properties.RepostOf
+	}
 	if len(mf.Properties.BookmarkOf) > 0 {
 		entry.Parameters[a.cfg.Micropub.BookmarkParam] = mf.Properties.BookmarkOf
 	}
 	if len(mf.Properties.Audio) > 0 {
 		entry.Parameters[a.cfg.Micropub.AudioParam] = mf.Properties.Audio
 	}
+	if len(mf.Properties.MpSyndicateTo) > 0 {
+		entry.Parameters[syndicateToParameter] = mf.Properties.MpSyndicateTo
+	}
+	if len(mf.Properties.MpTemplate) > 0 {
+		entry.Parameters[micropubTemplateParam] = mf.Properties.MpTemplate
+	}
 	if len(mf.Properties.Photo) > 0 {
 		for _, photo := range mf.Properties.Photo {
 			if theString, justString := photo.(string); justString {
@@ -444,7 +476,7 @@ func (a *goBlog) micropubCreatePostFromJson(w http.ResponseWriter, r *http.Reque
 }
 
 func (a *goBlog) micropubCheckScope(w http.ResponseWriter, r *http.Request, required string) bool {
-	if !strings.Contains(r.Context().Value(indieAuthScope).(string), required) {
+	if scope, _ := r.Context().Value(indieAuthScope).(string); !strings.Contains(scope, required) {
 		a.serveError(w, r, required+" scope missing", http.StatusForbidden)
 		return false
 	}
@@ -459,6 +491,20 @@ func (a *goBlog) micropubCreate(w http.ResponseWriter, r *http.Request, p *post)
 		a.serveError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if template := p.firstParameter(micropubTemplateParam); template != "" {
+		delete(p.Parameters, micropubTemplateParam)
+		if err := a.applyPostTemplate(p, template); err != nil {
+			a.serveError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !a.hasBlogRole(r, defaultIfEmpty(p.Blog, a.cfg.DefaultBlog), userRoleAuthor) {
+		a.serveError(w, r, "No permission for this blog", http.StatusForbidden)
+		return
+	}
+	if u := a.currentUser(r); u != nil {
+		p.Owner = u.Username
+	}
 	if err := a.createPost(p); err != nil {
 		a.serveError(w, r, err.Error(), http.StatusBadRequest)
 		return
@@ -475,6 +521,10 @@ func (a *goBlog) micropubDelete(w http.ResponseWriter, r *http.Request, u string
 		a.serveError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !a.canEditPostPath(r, uu.Path) {
+		a.serveError(w, r, "No permission for this post", http.StatusForbidden)
+		return
+	}
 	if err := a.deletePost(uu.Path); err != nil {
 		a.serveError(w, r, err.Error(), http.StatusBadRequest)
 		return
@@ -491,6 +541,10 @@ func (a *goBlog) micropubUndelete(w http.ResponseWriter, r *http.Request, u stri
 		a.serveError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !a.canEditPostPath(r, uu.Path) {
+		a.serveError(w, r, "No permission for this post", http.StatusForbidden)
+		return
+	}
 	if err := a.undeletePost(uu.Path); err != nil {
 		a.serveError(w, r, err.Error(), http.StatusBadRequest)
 		return
@@ -512,16 +566,28 @@ func (a *goBlog) micropubUpdate(w http.ResponseWriter, r *http.Request, u string
 		// Probably homepage "/"
 		ppath = "/"
 	}
+	// Prevent concurrent updates between the precondition check and saving
+	a.db.pum.Lock()
+	defer a.db.pum.Unlock()
 	p, err := a.getPost(ppath)
 	if err != nil {
 		a.serveError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !a.canEditPost(r, p) {
+		a.serveError(w, r, "No permission for this post", http.StatusForbidden)
+		return
+	}
 	// Check if post is marked as deleted
 	if p.Deleted() {
 		a.serveError(w, r, "post is marked as deleted, undelete it first", http.StatusBadRequest)
 		return
 	}
+	// Check that the client updates the current version of the post
+	if !checkPostPreconditions(r, p) {
+		a.serveError(w, r, errPostModified, http.StatusPreconditionFailed)
+		return
+	}
 	// Update post
 	oldPath := p.Path
 	oldStatus := p.Status
@@ -534,11 +600,17 @@ func (a *goBlog) micropubUpdate(w http.ResponseWriter, r *http.Request, u string
 		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// The post can be moved to another blog
+	if !a.hasBlogRole(r, defaultIfEmpty(p.Blog, a.cfg.DefaultBlog), userRoleAuthor) {
+		a.serveError(w, r, "No permission for this blog", http.StatusForbidden)
+		return
+	}
 	err = a.replacePost(p, oldPath, oldStatus, oldVisibility)
 	if err != nil {
 		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	setPostVersionHeaders(w, p)
 	http.Redirect(w, r, a.fullPostURL(p), http.StatusNoContent)
 }
 
@@ -575,6 +647,9 @@ func (a *goBlog) micropubUpdateReplace(p *post, replace map[string][]any) {
 	if like, ok := replace["like-of"]; ok && like != nil {
 		p.Parameters[a.cfg.Micropub.LikeParam] = cast.ToStringSlice(like)
 	}
+	if repost, ok := replace["repost-of"]; ok && repost != nil {
+		p.Parameters[a.cfg.Micropub.RepostParam] = cast.ToStringSlice(repost)
+	}
 	if bookmark, ok := replace["bookmark-of"]; ok && bookmark != nil {
 		p.Parameters[a.cfg.Micropub.BookmarkParam] = cast.ToStringSlice(bookmark)
 	}
@@ -599,6 +674,8 @@ func (a *goBlog) micropubUpdateAdd(p *post, add map[string][]any) {
 			p.Parameters[a.cfg.Micropub.ReplyParam] = cast.ToStringSlice(value)
 		case "like-of":
 			p.Parameters[a.cfg.Micropub.LikeParam] = cast.ToStringSlice(value)
+		case "repost-of":
+			p.Parameters[a.cfg.Micropub.RepostParam] = cast.ToStringSlice(value)
 		case "bookmark-of":
 			p.Parameters[a.cfg.Micropub.BookmarkParam] = cast.ToStringSlice(value)
 		case "audio":
@@ -633,6 +710,10 @@ func (a *goBlog) micropubUpdateDelete(p *post, del any) {
 				delete(p.Parameters, a.cfg.Micropub.LikeParam)
 				delete(p.Parameters, a.cfg.Micropub.LikeTitleParam)
 				delete(p.Parameters, a.cfg.Micropub.LikeContextParam)
+			case "repost-of":
+				delete(p.Parameters, a.cfg.Micropub.RepostParam)
+				delete(p.Parameters, a.cfg.Micropub.RepostTitleParam)
+				delete(p.Parameters, a.cfg.Micropub.RepostContextParam)
 			case "bookmark-of":
 				delete(p.Parameters, a.cfg.Micropub.BookmarkParam)
 			case "audio":
@@ -663,6 +744,9 @@ func (a *goBlog) micropubUpdateDelete(p *post, del any) {
 			case "like-of":
 				delete(p.Parameters, a.cfg.Micropub.LikeParam)
 				delete(p.Parameters, a.cfg.Micropub.LikeTitleParam)
+			case "repost-of":
+				delete(p.Parameters, a.cfg.Micropub.RepostParam)
+				delete(p.Parameters, a.cfg.Micropub.RepostTitleParam)
 			case "bookmark-of":
 				delete(p.Parameters, a.cfg.Micropub.BookmarkParam)
 			// Properties to delete part of
@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -37,49 +38,48 @@ func (a *goBlog) serveMicropubMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
-	// Generate sha256 hash for file
-	hash := sha256.New()
-	_, err = io.Copy(hash, file)
+	location, err := a.uploadMediaFile(file, header.Filename, header.Header.Get(contentType))
 	if err != nil {
-		a.serveError(w, r, "failed to get file hash", http.StatusBadRequest)
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	http.Redirect(w, r, location, http.StatusCreated)
+}
+
+// Save an uploaded file to the media storage (compressed if possible) and return the location
+func (a *goBlog) uploadMediaFile(file io.ReadSeeker, fileName, mimeType string) (string, error) {
+	// Generate sha256 hash for file
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errors.New("failed to get file hash")
+	}
 	// Get file extension
-	fileExtension := filepath.Ext(header.Filename)
-	if fileExtension == "" {
+	fileExtension := filepath.Ext(fileName)
+	if fileExtension == "" && mimeType != "" {
 		// Find correct file extension if original filename does not contain one
-		mimeType := header.Header.Get(contentType)
-		if len(mimeType) > 0 {
-			allExtensions, _ := mime.ExtensionsByType(mimeType)
-			if len(allExtensions) > 0 {
-				fileExtension = allExtensions[0]
-			}
+		allExtensions, _ := mime.ExtensionsByType(mimeType)
+		if len(allExtensions) > 0 {
+			fileExtension = allExtensions[0]
 		}
 	}
-	// Generate the file name
-	fileName := fmt.Sprintf("%x%s", hash.Sum(nil), fileExtension)
 	// Save file
-	_, err = file.Seek(0, io.SeekStart)
-	if err != nil {
-		a.serveError(w, r, "failed to read multipart file", http.StatusInternalServerError)
-		return
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", errors.New("failed to read multipart file")
 	}
-	location, err := a.saveMediaFile(fileName, file)
+	location, err := a.saveMediaFile(fmt.Sprintf("%x%s", hash.Sum(nil), fileExtension), file)
 	if err != nil {
-		a.serveError(w, r, "failed to save original file", http.StatusInternalServerError)
-		return
+		return "", errors.New("failed to save original file")
 	}
 	// Try to compress file (only when not in private mode)
 	if !a.isPrivate() {
 		compressedLocation, compressionErr := a.compressMediaFile(location)
 		if compressionErr != nil {
-			a.serveError(w, r, "failed to compress file: "+compressionErr.Error(), http.StatusInternalServerError)
-			return
+			return "", errors.New("failed to compress file: " + compressionErr.Error())
 		}
 		// Overwrite location
 		if compressedLocation != "" {
 			location = compressedLocation
 		}
 	}
-	http.Redirect(w, r, location, http.StatusCreated)
+	return location, nil
 }
@@ -18,6 +18,7 @@ func Test_micropubQuery(t *testing.T) {
 	_ = app.initConfig(false)
 	_ = app.initCache()
 	app.initMarkdown()
+	_ = app.initTemplateStrings()
 	app.initSessions()
 
 	// Create a test post with tags
@@ -39,7 +40,7 @@ func Test_micropubQuery(t *testing.T) {
 	testCases := []testCase{
 		{
 			query:      "config",
-			want:       "{\"channels\":[{\"name\":\"default: My Blog\",\"uid\":\"default\"},{\"name\":\"default/posts: posts\",\"uid\":\"default/posts\"}],\"media-endpoint\":\"http://localhost:8080/micropub/media\",\"visibility\":[\"public\",\"unlisted\",\"private\"]}",
+			want:       "{\"channels\":[{\"name\":\"default: My Blog\",\"uid\":\"default\"},{\"name\":\"default/posts: posts\",\"uid\":\"default/posts\"}],\"media-endpoint\":\"http://localhost:8080/micropub/media\",\"syndicate-to\":[],\"visibility\":[\"public\",\"unlisted\",\"private\"]}",
 			wantStatus: http.StatusOK,
 		},
 		{
@@ -62,6 +63,11 @@ func Test_micropubQuery(t *testing.T) {
 			want:       "{\"channels\":[{\"name\":\"default: My Blog\",\"uid\":\"default\"},{\"name\":\"default/posts: posts\",\"uid\":\"default/posts\"}]}",
 			wantStatus: http.StatusOK,
 		},
+		{
+			query:      "syndicate-to",
+			want:       "{\"syndicate-to\":[]}",
+			wantStatus: http.StatusOK,
+		},
 		{
 			query:      "somethingelse",
 			wantStatus: http.StatusNotFound,
@@ -72,7 +78,7 @@ func Test_micropubQuery(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "http://localhost:8080/micropub?q="+tc.query, nil)
 		rec := httptest.NewRecorder()
 
-		app.serveMicropubQuery(rec, req)
+		addAllScopes(http.HandlerFunc(app.serveMicropubQuery)).ServeHTTP(rec, req)
 		rec.Flush()
 
 		assert.Equal(t, tc.wantStatus, rec.Code)
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/samber/lo"
+)
+
+// Lightweight self-monitoring: the number of goroutines, the heap size and the number of open files
+// are sampled regularly and a notification is sent when one of them exceeds its threshold,
+// so leaks are noticed before the process runs out of memory or file descriptors.
+
+const (
+	defaultMonitoringInterval      = 60    // seconds
+	defaultMonitoringMaxGoroutines = 10000 // goroutines
+	defaultMonitoringMaxHeap       = 1024  // MB
+	defaultMonitoringMaxOpenFiles  = 1000  // open files
+)
+
+type monitoringSample struct {
+	goroutines int
+	heap       uint64 // bytes
+	openFiles  int    // -1 if unknown
+}
+
+type resourceMonitor struct {
+	maxGoroutines, maxOpenFiles int
+	maxHeap                     uint64 // bytes
+	// Resources that are currently over their threshold, to only notify once per exceedance
+	exceeded map[string]bool
+}
+
+func (a *goBlog) initMonitoring() {
+	mc := a.cfg.Monitoring
+	if mc == nil || !mc.Enabled {
+		return
+	}
+	m := newResourceMonitor(mc)
+	ticker := time.NewTicker(time.Duration(lo.If(mc.Interval > 0, mc.Interval).Else(defaultMonitoringInterval)) * time.Second)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.checkResources(m, takeMonitoringSample())
+			}
+		}
+	}()
+	a.addShutdownBeforeDatabase(func() {
+		ticker.Stop()
+		close(done)
+		log.Println("Monitoring stopped")
+	})
+}
+
+func newResourceMonitor(mc *configMonitoring) *resourceMonitor {
+	return &resourceMonitor{
+		maxGoroutines: lo.If(mc.MaxGoroutines > 0, mc.MaxGoroutines).Else(defaultMonitoringMaxGoroutines),
+		maxHeap:       uint64(lo.If(mc.MaxHeap > 0, mc.MaxHeap).Else(defaultMonitoringMaxHeap)) * uint64(datasize.MB),
+		maxOpenFiles:  lo.If(mc.MaxOpenFiles > 0, mc.MaxOpenFiles).Else(defaultMonitoringMaxOpenFiles),
+		exceeded:      map[string]bool{},
+	}
+}
+
+func takeMonitoringSample() *monitoringSample {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return &monitoringSample{
+		goroutines: runtime.NumGoroutine(),
+		heap:       ms.HeapAlloc,
+		openFiles:  countOpenFiles(),
+	}
+}
+
+// Number of open file descriptors of the process, -1 on systems without /proc
+func countOpenFiles() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// Returns a message for every resource that newly exceeded its threshold
+func (m *resourceMonitor) check(s *monitoringSample) []string {
+	var alerts []string
+	checkResource := func(name string, over bool, msg string) {
+		if over && !m.exceeded[name] {
+			alerts = append(alerts, msg)
+		}
+		m.exceeded[name] = over
+	}
+	checkResource("goroutines", s.goroutines > m.maxGoroutines,
+		fmt.Sprintf("%d goroutines running (threshold %d)", s.goroutines, m.maxGoroutines))
+	checkResource("heap", s.heap > m.maxHeap,
+		fmt.Sprintf("Heap size is %s (threshold %s)", datasize.ByteSize(s.heap).HR(), datasize.ByteSize(m.maxHeap).HR()))
+	checkResource("openfiles", s.openFiles > m.maxOpenFiles,
+		fmt.Sprintf("%d files open (threshold %d)", s.openFiles, m.maxOpenFiles))
+	return alerts
+}
+
+func (a *goBlog) checkResources(m *resourceMonitor, s *monitoringSample) {
+	a.debug("Monitoring:", s.goroutines, "goroutines,", datasize.ByteSize(s.heap).HR(), "heap,", s.openFiles, "open files")
+	alerts := m.check(s)
+	if len(alerts) == 0 {
+		return
+	}
+	text := "Resource usage too high:\n" + strings.Join(alerts, "\n")
+	log.Println(text)
+	a.sendNotification(notificationTypeMonitoring, text)
+}
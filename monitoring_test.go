@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_monitoring(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+
+	m := newResourceMonitor(&configMonitoring{Enabled: true, MaxGoroutines: 100, MaxHeap: 10})
+	assert.Equal(t, defaultMonitoringMaxOpenFiles, m.maxOpenFiles)
+	assert.Equal(t, uint64(10*datasize.MB), m.maxHeap)
+
+	// Below thresholds
+	assert.Empty(t, m.check(&monitoringSample{goroutines: 50, heap: uint64(datasize.MB), openFiles: -1}))
+
+	// Exceeded, notify only once
+	alerts := m.check(&monitoringSample{goroutines: 150, heap: uint64(datasize.MB), openFiles: 10})
+	require.Len(t, alerts, 1)
+	assert.Contains(t, alerts[0], "150 goroutines")
+	assert.Empty(t, m.check(&monitoringSample{goroutines: 200, heap: uint64(datasize.MB), openFiles: 10}))
+
+	// Notify again after recovery
+	assert.Empty(t, m.check(&monitoringSample{goroutines: 50, heap: uint64(datasize.MB), openFiles: 10}))
+	assert.Len(t, m.check(&monitoringSample{goroutines: 150, heap: 20 * uint64(datasize.MB), openFiles: 10}), 2)
+
+	// Alerts are saved as notifications
+	app.checkResources(newResourceMonitor(&configMonitoring{MaxGoroutines: 1}), takeMonitoringSample())
+	notifications, err := app.db.getNotifications(&notificationsRequestConfig{})
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Contains(t, notifications[0].Text, "goroutines running")
+}
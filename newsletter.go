@@ -0,0 +1,368 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.goblog.app/app/pkgs/bufferpool"
+	"go.goblog.app/app/pkgs/htmlbuilder"
+)
+
+// Newsletter: readers can subscribe with their email address (double opt-in) and get an email for each new post
+// or a weekly digest of the new posts. Every email contains a link to unsubscribe.
+
+const (
+	defaultNewsletterPath = "/newsletter"
+
+	newsletterDigestInterval     = 7 * 24 * time.Hour
+	newsletterPendingRetention   = 7 * 24 * time.Hour
+	newsletterLastSentKeyPrefix  = "newsletter_last_"
+	newsletterTokenLength        = 32 // bytes
+	newsletterSubscribeSubpath   = "/subscribe"
+	newsletterConfirmSubpath     = "/confirm"
+	newsletterUnsubscribeSubpath = "/unsubscribe"
+)
+
+type newsletterSubscriber struct {
+	Email, Token string
+	Confirmed    bool
+}
+
+func (nc *configNewsletter) enabled() bool {
+	return nc != nil && nc.Enabled && nc.SMTPHost != "" && nc.EmailFrom != ""
+}
+
+func (*goBlog) newsletterPath(bc *configBlog) string {
+	return bc.getRelativePath(defaultIfEmpty(bc.Newsletter.Path, defaultNewsletterPath))
+}
+
+func (a *goBlog) initNewsletter() {
+	for _, bc := range a.cfg.Blogs {
+		if bc.Newsletter.enabled() {
+			a.hourlyHooks = append(a.hourlyHooks, a.sendNewsletters)
+			return
+		}
+	}
+}
+
+// Database
+
+func newNewsletterToken() string {
+	b := make([]byte, newsletterTokenLength)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Adds a new unconfirmed subscriber, existing subscribers are returned unchanged
+func (db *database) addNewsletterSubscriber(blog, email string) (*newsletterSubscriber, error) {
+	if _, err := db.Exec(
+		"insert or ignore into newsletter_subscribers (blog, email, token, created) values (@blog, @email, @token, @created)",
+		sql.Named("blog", blog), sql.Named("email", email), sql.Named("token", newNewsletterToken()), sql.Named("created", utcNowString()),
+	); err != nil {
+		return nil, err
+	}
+	row, err := db.QueryRow(
+		"select email, token, confirmed from newsletter_subscribers where blog = @blog and email = @email",
+		sql.Named("blog", blog), sql.Named("email", email),
+	)
+	if err != nil {
+		return nil, err
+	}
+	s := &newsletterSubscriber{}
+	if err = row.Scan(&s.Email, &s.Token, &s.Confirmed); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (db *database) confirmNewsletterSubscriber(blog, token string) error {
+	res, err := db.Exec(
+		"update newsletter_subscribers set confirmed = 1 where blog = @blog and token = @token",
+		sql.Named("blog", blog), sql.Named("token", token),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+func (db *database) deleteNewsletterSubscriber(blog, token string) error {
+	_, err := db.Exec(
+		"delete from newsletter_subscribers where blog = @blog and token = @token",
+		sql.Named("blog", blog), sql.Named("token", token),
+	)
+	return err
+}
+
+func (db *database) getNewsletterSubscribers(blog string) ([]*newsletterSubscriber, error) {
+	rows, err := db.Query(
+		"select email, token, confirmed from newsletter_subscribers where blog = @blog and confirmed = 1 order by email",
+		sql.Named("blog", blog),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subscribers := []*newsletterSubscriber{}
+	for rows.Next() {
+		s := &newsletterSubscriber{}
+		if err = rows.Scan(&s.Email, &s.Token, &s.Confirmed); err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, s)
+	}
+	return subscribers, rows.Err()
+}
+
+// Remove subscriptions that were never confirmed
+func (db *database) deletePendingNewsletterSubscribers(before time.Time) error {
+	_, err := db.Exec(
+		"delete from newsletter_subscribers where confirmed = 0 and created < @before",
+		sql.Named("before", before.UTC().Format(time.RFC3339)),
+	)
+	return err
+}
+
+// HTTP handlers
+
+func (a *goBlog) serveNewsletterForm(w http.ResponseWriter, r *http.Request) {
+	_, bc := a.getBlog(r)
+	nc := bc.Newsletter
+	a.render(w, r, a.renderNewsletter, &renderData{
+		Data: &newsletterRenderData{
+			title:       nc.Title,
+			description: nc.Description,
+			privacy:     nc.PrivacyPolicy,
+			action:      a.newsletterPath(bc) + newsletterSubscribeSubpath,
+		},
+	})
+}
+
+func (a *goBlog) subscribeNewsletter(w http.ResponseWriter, r *http.Request) {
+	blog, bc := a.getBlog(r)
+	addr, err := netmail.ParseAddress(r.FormValue("email"))
+	if err != nil {
+		a.serveError(w, r, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+	s, err := a.db.addNewsletterSubscriber(blog, strings.ToLower(addr.Address))
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Confirmed subscribers get the same response, so it isn't revealed who is subscribed
+	if !s.Confirmed {
+		go func() {
+			if err := a.sendNewsletterConfirmation(bc, s); err != nil {
+				log.Println("Newsletter: failed to send confirmation:", err.Error())
+			}
+		}()
+	}
+	a.render(w, r, a.renderNewsletterMessage, &renderData{
+		Data: &newsletterMessageRenderData{message: "newsletterconfirmsent"},
+	})
+}
+
+func (a *goBlog) confirmNewsletter(w http.ResponseWriter, r *http.Request) {
+	blog, _ := a.getBlog(r)
+	if err := a.db.confirmNewsletterSubscriber(blog, r.FormValue("token")); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.render(w, r, a.renderNewsletterMessage, &renderData{
+		Data: &newsletterMessageRenderData{message: "newsletterconfirmed"},
+	})
+}
+
+// Unsubscribing needs a POST request (also used by one-click unsubscribe from email clients),
+// so link scanners opening the link don't unsubscribe
+func (a *goBlog) serveNewsletterUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	a.render(w, r, a.renderNewsletterMessage, &renderData{
+		Data: &newsletterMessageRenderData{message: "newsletterunsubscribeconfirm", unsubscribeToken: r.FormValue("token")},
+	})
+}
+
+func (a *goBlog) unsubscribeNewsletter(w http.ResponseWriter, r *http.Request) {
+	blog, _ := a.getBlog(r)
+	if err := a.db.deleteNewsletterSubscriber(blog, r.FormValue("token")); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.render(w, r, a.renderNewsletterMessage, &renderData{
+		Data: &newsletterMessageRenderData{message: "newsletterunsubscribed"},
+	})
+}
+
+// Emails
+
+func (*goBlog) newsletterEmail(bc *configBlog, to, subject, body, html string) *smtpEmail {
+	nc := bc.Newsletter
+	return &smtpEmail{
+		host:     nc.SMTPHost,
+		port:     nc.SMTPPort,
+		user:     nc.SMTPUser,
+		password: nc.SMTPPassword,
+		from:     nc.EmailFrom,
+		to:       to,
+		subject:  subject,
+		body:     body,
+		html:     html,
+	}
+}
+
+func (a *goBlog) sendNewsletterConfirmation(bc *configBlog, s *newsletterSubscriber) error {
+	confirmURL := a.getFullAddress(a.newsletterPath(bc) + newsletterConfirmSubpath + "?token=" + url.QueryEscape(s.Token))
+	text := a.ts.GetTemplateStringVariant(bc.Lang, "newsletterconfirmmail")
+	return a.newsletterEmail(
+		bc, s.Email,
+		fmt.Sprintf("%s: %s", bc.Title, a.ts.GetTemplateStringVariant(bc.Lang, "newsletter")),
+		text+"\n\n"+confirmURL, "",
+	).send()
+}
+
+// Sends the emails for the new posts since the last run, called hourly
+func (a *goBlog) sendNewsletters() {
+	for blog, bc := range a.cfg.Blogs {
+		if !bc.Newsletter.enabled() || bc.isProtected() {
+			continue
+		}
+		if err := a.sendNewsletter(blog, bc, time.Now()); err != nil {
+			log.Println("Newsletter: failed to send for blog", blog+":", err.Error())
+		}
+	}
+	if err := a.db.deletePendingNewsletterSubscribers(time.Now().Add(-newsletterPendingRetention)); err != nil {
+		log.Println("Newsletter: failed to delete pending subscriptions:", err.Error())
+	}
+}
+
+func (a *goBlog) sendNewsletter(blog string, bc *configBlog, now time.Time) error {
+	key := newsletterLastSentKeyPrefix + blog
+	lastData, err := a.db.retrievePersistentCache(key)
+	if err != nil {
+		return err
+	}
+	last, _ := time.Parse(time.RFC3339, string(lastData))
+	if last.IsZero() {
+		// First run, don't send all the old posts
+		return a.db.cachePersistently(key, []byte(now.UTC().Format(time.RFC3339)))
+	}
+	if bc.Newsletter.Digest && now.Sub(last) < newsletterDigestInterval {
+		return nil
+	}
+	posts, err := a.getPosts(&postsRequestConfig{
+		blog:                   blog,
+		status:                 []postStatus{statusPublished},
+		visibility:             []postVisibility{visibilityPublic},
+		publishedAfter:         last,
+		publishedBefore:        now,
+		withinVisibilityWindow: true,
+	})
+	if err != nil {
+		return err
+	}
+	// Remember the time before sending, so a failing email doesn't cause all subscribers to get the posts again
+	if err = a.db.cachePersistently(key, []byte(now.UTC().Format(time.RFC3339))); err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return nil
+	}
+	subscribers, err := a.db.getNewsletterSubscribers(blog)
+	if err != nil {
+		return err
+	}
+	// Oldest posts first
+	for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+		posts[i], posts[j] = posts[j], posts[i]
+	}
+	var mails [][]*post
+	if bc.Newsletter.Digest {
+		mails = append(mails, posts)
+	} else {
+		for _, p := range posts {
+			mails = append(mails, []*post{p})
+		}
+	}
+	for _, mailPosts := range mails {
+		subject := defaultIfEmpty(bc.Newsletter.EmailSubject, bc.Title+": "+a.ts.GetTemplateStringVariant(bc.Lang, "newsletterdigest"))
+		if len(mailPosts) == 1 && !bc.Newsletter.Digest {
+			subject = defaultIfEmpty(mailPosts[0].RenderedTitle, bc.Title+": "+a.ts.GetTemplateStringVariant(bc.Lang, "newsletternewpost"))
+		}
+		for _, s := range subscribers {
+			if err = a.sendNewsletterPosts(bc, s, subject, mailPosts); err != nil {
+				log.Println("Newsletter: failed to send email to", s.Email+":", err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (a *goBlog) sendNewsletterPosts(bc *configBlog, s *newsletterSubscriber, subject string, posts []*post) error {
+	unsubscribeURL := a.getFullAddress(a.newsletterPath(bc) + newsletterUnsubscribeSubpath + "?token=" + url.QueryEscape(s.Token))
+	unsubscribeText := a.ts.GetTemplateStringVariant(bc.Lang, "newsletterunsubscribeinfo")
+	// Plain text
+	text := bufferpool.Get()
+	defer bufferpool.Put(text)
+	for _, p := range posts {
+		if p.RenderedTitle != "" {
+			_, _ = fmt.Fprintf(text, "%s\n", p.RenderedTitle)
+		}
+		if summary := a.postSummary(p); summary != "" {
+			_, _ = fmt.Fprintf(text, "%s\n", summary)
+		}
+		_, _ = fmt.Fprintf(text, "%s\n\n", a.fullPostURL(p))
+	}
+	_, _ = fmt.Fprintf(text, "--\n%s %s\n", unsubscribeText, unsubscribeURL)
+	// HTML using the feed rendering of the posts
+	html := bufferpool.Get()
+	defer bufferpool.Put(html)
+	hb := htmlbuilder.NewHtmlBuilder(html)
+	hb.WriteUnescaped("<!doctype html>")
+	hb.WriteElementOpen("html", "lang", bc.Lang)
+	hb.WriteElementOpen("head")
+	hb.WriteElementOpen("meta", "charset", "utf-8")
+	hb.WriteElementOpen("title")
+	hb.WriteEscaped(subject)
+	hb.WriteElementsClose("title", "head")
+	hb.WriteElementOpen("body")
+	for _, p := range posts {
+		hb.WriteElementOpen("article")
+		if p.RenderedTitle != "" {
+			hb.WriteElementOpen("h2")
+			hb.WriteElementOpen("a", "href", a.fullPostURL(p))
+			hb.WriteEscaped(p.RenderedTitle)
+			hb.WriteElementsClose("a", "h2")
+		}
+		a.feedHtml(hb, p)
+		hb.WriteElementOpen("p")
+		hb.WriteElementOpen("a", "href", a.fullPostURL(p))
+		hb.WriteEscaped(a.fullPostURL(p))
+		hb.WriteElementsClose("a", "p")
+		hb.WriteElementClose("article")
+		hb.WriteElementOpen("hr")
+	}
+	hb.WriteElementOpen("p")
+	hb.WriteEscaped(unsubscribeText + " ")
+	hb.WriteElementOpen("a", "href", unsubscribeURL)
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(bc.Lang, "newsletterunsubscribe"))
+	hb.WriteElementsClose("a", "p", "body", "html")
+	// Send
+	e := a.newsletterEmail(bc, s.Email, subject, text.String(), html.String())
+	e.headers = map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	return e.send()
+}
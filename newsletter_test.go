@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.goblog.app/app/pkgs/contenttype"
+	"go.goblog.app/app/pkgs/mocksmtp"
+)
+
+func Test_newsletter(t *testing.T) {
+	// Start the SMTP server
+	port, rd, cancel, err := mocksmtp.StartMockSMTPServer()
+	require.NoError(t, err)
+	defer cancel()
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang:  "en",
+			Title: "Test Blog",
+			Sections: map[string]*configSection{
+				"posts": {Name: "posts"},
+			},
+			Newsletter: &configNewsletter{
+				Enabled:   true,
+				SMTPPort:  port,
+				SMTPHost:  "127.0.0.1",
+				EmailFrom: "newsletter@example.org",
+			},
+		},
+	}
+	app.cfg.DefaultBlog = "en"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	bc := app.cfg.Blogs["en"]
+
+	doRequest := func(handler http.HandlerFunc, method, path string, form url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Add(contentType, contenttype.WWWForm)
+		handler(rec, req.WithContext(context.WithValue(req.Context(), blogKey, "en")))
+		return rec
+	}
+
+	// Subscribe
+	rec := doRequest(app.subscribeNewsletter, http.MethodPost, "/newsletter/subscribe", url.Values{"email": {"Reader@Example.net"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "check your inbox")
+
+	rec = doRequest(app.subscribeNewsletter, http.MethodPost, "/newsletter/subscribe", url.Values{"email": {"invalid"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	time.Sleep(500 * time.Millisecond)
+	if assert.Len(t, rd.Datas, 1) {
+		assert.Contains(t, rd.Rcpts, "reader@example.net")
+		assert.Contains(t, string(rd.Datas[0]), "Please confirm your newsletter subscription")
+	}
+
+	// Not confirmed yet
+	subscribers, err := app.db.getNewsletterSubscribers("en")
+	require.NoError(t, err)
+	assert.Len(t, subscribers, 0)
+
+	// Confirm
+	s, err := app.db.addNewsletterSubscriber("en", "reader@example.net")
+	require.NoError(t, err)
+	require.NotEmpty(t, s.Token)
+
+	rec = doRequest(app.confirmNewsletter, http.MethodGet, "/newsletter/confirm?token=invalid", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(app.confirmNewsletter, http.MethodGet, "/newsletter/confirm?token="+s.Token, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	subscribers, err = app.db.getNewsletterSubscribers("en")
+	require.NoError(t, err)
+	require.Len(t, subscribers, 1)
+	assert.True(t, subscribers[0].Confirmed)
+
+	// The first run only remembers the time
+	require.NoError(t, app.sendNewsletter("en", bc, time.Now().Add(-time.Hour)))
+	assert.Len(t, rd.Datas, 1)
+
+	// New posts are sent
+	require.NoError(t, app.createPost(&post{
+		Path:       "/newsletter-post",
+		Blog:       "en",
+		Section:    "posts",
+		Status:     statusPublished,
+		Visibility: visibilityPublic,
+		Parameters: map[string][]string{"title": {"Newsletter post"}},
+		Content:    "Content of the newsletter post",
+	}))
+	require.NoError(t, app.createPost(&post{
+		Path:       "/private-post",
+		Blog:       "en",
+		Section:    "posts",
+		Status:     statusPublished,
+		Visibility: visibilityPrivate,
+		Parameters: map[string][]string{"title": {"Private post"}},
+	}))
+	require.NoError(t, app.sendNewsletter("en", bc, time.Now().Add(time.Minute)))
+	if assert.Len(t, rd.Datas, 2) {
+		mail := string(rd.Datas[1])
+		assert.Contains(t, mail, "Subject: Newsletter post")
+		assert.Contains(t, mail, "text/html")
+		assert.Contains(t, mail, "Content of the newsletter post")
+		assert.Contains(t, mail, "List-Unsubscribe:")
+		assert.Contains(t, mail, "List-Unsubscribe-Post: List-Unsubscribe=One-Click")
+		assert.NotContains(t, mail, "Private post")
+	}
+
+	// Nothing new
+	require.NoError(t, app.sendNewsletter("en", bc, time.Now().Add(2*time.Minute)))
+	assert.Len(t, rd.Datas, 2)
+
+	// Digests are only sent weekly
+	bc.Newsletter.Digest = true
+	require.NoError(t, app.sendNewsletter("en", bc, time.Now().Add(3*time.Minute)))
+	assert.Len(t, rd.Datas, 2)
+
+	// Unsubscribe
+	rec = doRequest(app.serveNewsletterUnsubscribe, http.MethodGet, "/newsletter/unsubscribe?token="+s.Token, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), s.Token)
+
+	rec = doRequest(app.unsubscribeNewsletter, http.MethodPost, "/newsletter/unsubscribe?token="+s.Token, url.Values{"List-Unsubscribe": {"One-Click"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	subscribers, err = app.db.getNewsletterSubscribers("en")
+	require.NoError(t, err)
+	assert.Len(t, subscribers, 0)
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
+	"go.goblog.app/app/pkgs/contenttype"
+	ws "nhooyr.io/websocket"
+)
+
+// Nostr: public posts are published as signed events to the configured relays,
+// notes as short text notes (NIP-01) and posts with a title as long-form content (NIP-23).
+
+const (
+	nostrPath           = "/.well-known/nostr.json"
+	nostrEventParameter = "nostrevent" // IDs of the published events
+
+	nostrKindNote     = 1
+	nostrKindDeletion = 5
+	nostrKindArticle  = 30023
+
+	nostrRelayTimeout = 30 * time.Second
+)
+
+type nostrEvent struct {
+	ID        string     `json:"id"`
+	PubKey    string     `json:"pubkey"`
+	CreatedAt int64      `json:"created_at"`
+	Kind      int        `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+	Sig       string     `json:"sig"`
+}
+
+func (nc *configNostr) enabled() bool {
+	return nc != nil && nc.Enabled && nc.key != nil && len(nc.Relays) > 0
+}
+
+// Hex encoded x-only public key
+func (nc *configNostr) publicKey() string {
+	return hex.EncodeToString(schnorr.SerializePubKey(nc.key.PubKey()))
+}
+
+func (a *goBlog) initNostr() error {
+	enabled := false
+	for blog, bc := range a.cfg.Blogs {
+		nc := bc.Nostr
+		if nc == nil || !nc.Enabled {
+			continue
+		}
+		key, err := nostrParsePrivateKey(nc.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("invalid private key for blog %s: %w", blog, err)
+		}
+		nc.key = key
+		enabled = true
+	}
+	if !enabled {
+		return nil
+	}
+	a.pPostHooks = append(a.pPostHooks, func(p *post) {
+		if p.isPublicPublishedSectionPost() && !a.isProtectedBlog(p.Blog) {
+			a.nostrPublish(p)
+		}
+	})
+	a.pUpdateHooks = append(a.pUpdateHooks, func(p *post) {
+		// Only long-form events are replaceable
+		if p.isPublicPublishedSectionPost() && p.RenderedTitle != "" && !a.isProtectedBlog(p.Blog) {
+			a.nostrPublish(p)
+		}
+	})
+	a.pDeleteHooks = append(a.pDeleteHooks, a.nostrDelete)
+	return nil
+}
+
+// Parse a private key, either hex encoded or bech32 encoded with the "nsec" prefix (NIP-19)
+func nostrParsePrivateKey(s string) (*btcec.PrivateKey, error) {
+	var keyBytes []byte
+	var err error
+	if strings.HasPrefix(s, "nsec1") {
+		var hrp string
+		hrp, keyBytes, err = bech32Decode(s)
+		if err == nil && hrp != "nsec" {
+			err = errors.New("unexpected prefix " + hrp)
+		}
+	} else {
+		keyBytes, err = hex.DecodeString(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keyBytes) != 32 {
+		return nil, errors.New("private key must have 32 bytes")
+	}
+	key, _ := btcec.PrivKeyFromBytes(keyBytes)
+	return key, nil
+}
+
+func (a *goBlog) nostrPublish(p *post) {
+	nc := a.getBlogFromPost(p).Nostr
+	if !nc.enabled() {
+		return
+	}
+	event, err := a.nostrEventForPost(p, nc)
+	if err != nil {
+		a.logger("nostr").Error("Failed to create event", "path", p.Path, "err", err)
+		return
+	}
+	if err = a.nostrSend(context.Background(), nc, event); err != nil {
+		a.logger("nostr").Error("Failed to publish", "path", p.Path, "err", err)
+		return
+	}
+	if err = a.db.replacePostParam(p.Path, nostrEventParameter, append(p.Parameters[nostrEventParameter], event.ID)); err != nil {
+		a.logger("nostr").Error("Failed to save event id", "path", p.Path, "err", err)
+	}
+}
+
+// Request the deletion of the published events (NIP-09)
+func (a *goBlog) nostrDelete(p *post) {
+	nc := a.getBlogFromPost(p).Nostr
+	if !nc.enabled() || a.isProtectedBlog(p.Blog) {
+		return
+	}
+	ids := p.Parameters[nostrEventParameter]
+	if len(ids) == 0 {
+		return
+	}
+	tags := lo.Map(ids, func(id string, _ int) []string { return []string{"e", id} })
+	if p.RenderedTitle != "" {
+		tags = append(tags, []string{"a", fmt.Sprintf("%d:%s:%s", nostrKindArticle, nc.publicKey(), p.Path)})
+	}
+	event := &nostrEvent{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostrKindDeletion,
+		Tags:      tags,
+	}
+	if err := event.sign(nc.key); err != nil {
+		a.logger("nostr").Error("Failed to sign deletion", "path", p.Path, "err", err)
+		return
+	}
+	if err := a.nostrSend(context.Background(), nc, event); err != nil {
+		a.logger("nostr").Error("Failed to publish deletion", "path", p.Path, "err", err)
+	}
+}
+
+func (a *goBlog) nostrEventForPost(p *post, nc *configNostr) (*nostrEvent, error) {
+	fullURL := a.fullPostURL(p)
+	event := &nostrEvent{
+		CreatedAt: time.Now().Unix(),
+		Tags:      [][]string{},
+	}
+	if title := p.RenderedTitle; title != "" {
+		// Long-form content, replaceable by the "d" tag
+		event.Kind = nostrKindArticle
+		event.Content = p.Content
+		event.Tags = append(event.Tags, []string{"d", p.Path}, []string{"title", title})
+		if published := toLocalTime(p.Published); !published.IsZero() {
+			event.Tags = append(event.Tags, []string{"published_at", strconv.FormatInt(published.Unix(), 10)})
+		}
+		if summary := a.postSummary(p); summary != "" {
+			event.Tags = append(event.Tags, []string{"summary", summary})
+		}
+		if photos := a.photoLinks(p); len(photos) > 0 {
+			event.Tags = append(event.Tags, []string{"image", photos[0]})
+		}
+	} else {
+		// Short text note with the photo URLs, clients show them as images
+		event.Kind = nostrKindNote
+		content := []string{a.syndicationText(p, 0, 0)}
+		content = append(content, a.photoLinks(p)...)
+		event.Content = strings.Join(content, "\n\n")
+	}
+	event.Tags = append(event.Tags, []string{"r", fullURL})
+	for _, tag := range a.postHashtags(p) {
+		event.Tags = append(event.Tags, []string{"t", strings.ToLower(tag)})
+	}
+	return event, event.sign(nc.key)
+}
+
+// Set the public key, the ID and the signature of the event
+func (e *nostrEvent) sign(key *btcec.PrivateKey) error {
+	e.PubKey = hex.EncodeToString(schnorr.SerializePubKey(key.PubKey()))
+	if e.Tags == nil {
+		e.Tags = [][]string{}
+	}
+	hash := sha256.Sum256(e.serialize())
+	e.ID = hex.EncodeToString(hash[:])
+	sig, err := schnorr.Sign(key, hash[:])
+	if err != nil {
+		return err
+	}
+	e.Sig = hex.EncodeToString(sig.Serialize())
+	return nil
+}
+
+// Serialization used for the event ID: [0, pubkey, created_at, kind, tags, content] (NIP-01)
+func (e *nostrEvent) serialize() []byte {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	buf.WriteString(`[0,`)
+	nostrWriteString(buf, e.PubKey)
+	buf.WriteString("," + strconv.FormatInt(e.CreatedAt, 10) + "," + strconv.Itoa(e.Kind) + ",[")
+	for i, tag := range e.Tags {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("[")
+		for j, value := range tag {
+			if j > 0 {
+				buf.WriteString(",")
+			}
+			nostrWriteString(buf, value)
+		}
+		buf.WriteString("]")
+	}
+	buf.WriteString("],")
+	nostrWriteString(buf, e.Content)
+	buf.WriteString("]")
+	return []byte(buf.String())
+}
+
+// JSON strings with only the escapes NIP-01 allows, everything else is written as is
+var nostrStringEscaper = strings.NewReplacer(
+	"\\", `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+	"\b", `\b`,
+	"\f", `\f`,
+)
+
+func nostrWriteString(buf *bytes.Buffer, s string) {
+	buf.WriteString(`"`)
+	_, _ = nostrStringEscaper.WriteString(buf, s)
+	buf.WriteString(`"`)
+}
+
+// Send the event to all relays, fails only if no relay accepted it
+func (a *goBlog) nostrSend(ctx context.Context, nc *configNostr, event *nostrEvent) error {
+	var errs []error
+	for _, relay := range nc.Relays {
+		if err := a.nostrSendToRelay(ctx, relay, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", relay, err))
+		}
+	}
+	if len(errs) == len(nc.Relays) {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		a.logger("nostr").Warn("Relay failed", "err", err)
+	}
+	return nil
+}
+
+func (a *goBlog) nostrSendToRelay(ctx context.Context, relay string, event *nostrEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, nostrRelayTimeout)
+	defer cancel()
+	c, _, err := ws.Dial(ctx, relay, &ws.DialOptions{HTTPClient: a.httpClient})
+	if err != nil {
+		return err
+	}
+	defer c.Close(ws.StatusNormalClosure, "")
+	msg, err := json.Marshal([]any{"EVENT", event})
+	if err != nil {
+		return err
+	}
+	if err = c.Write(ctx, ws.MessageText, msg); err != nil {
+		return err
+	}
+	// Wait for the OK message (NIP-20), ignore other messages like notices
+	for {
+		_, data, err := c.Read(ctx)
+		if err != nil {
+			return err
+		}
+		var res []any
+		if json.Unmarshal(data, &res) != nil || len(res) < 3 || res[0] != "OK" || res[1] != event.ID {
+			continue
+		}
+		if accepted, _ := res[2].(bool); !accepted {
+			message := "event rejected"
+			if len(res) > 3 {
+				message, _ = res[3].(string)
+			}
+			return errors.New(message)
+		}
+		return nil
+	}
+}
+
+// NIP-05 identifiers: name@domain maps to the public key of the blog
+func (a *goBlog) serveNostrJSON(w http.ResponseWriter, r *http.Request) {
+	requestedName := r.URL.Query().Get("name")
+	names, relays := map[string]string{}, map[string][]string{}
+	for blog, bc := range a.cfg.Blogs {
+		nc := bc.Nostr
+		if !nc.enabled() {
+			continue
+		}
+		name := defaultIfEmpty(nc.Name, lo.If(blog == a.cfg.DefaultBlog, "_").Else(blog))
+		if requestedName != "" && !strings.EqualFold(requestedName, name) {
+			continue
+		}
+		names[name] = nc.publicKey()
+		relays[nc.publicKey()] = nc.Relays
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set(contentType, contenttype.JSONUTF8)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"names":  names,
+		"relays": relays,
+	})
+}
+
+// Bech32 (BIP-173), only decoding is needed for the keys
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+func bech32Decode(s string) (hrp string, data []byte, err error) {
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errors.New("invalid bech32 string")
+	}
+	hrp = s[:pos]
+	values := make([]byte, 0, len(s)-pos-1)
+	for _, c := range s[pos+1:] {
+		i := strings.IndexRune(bech32Charset, c)
+		if i < 0 {
+			return "", nil, errors.New("invalid bech32 character")
+		}
+		values = append(values, byte(i))
+	}
+	if bech32Polymod(append(bech32HrpExpand(hrp), values...)) != 1 {
+		return "", nil, errors.New("invalid bech32 checksum")
+	}
+	// Convert from 5 to 8 bit groups, without checksum
+	var acc, bits uint
+	for _, v := range values[:len(values)-6] {
+		acc = (acc<<5 | uint(v)) & 0xfff
+		bits += 5
+		for bits >= 8 {
+			bits -= 8
+			data = append(data, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || (acc<<(8-bits))&0xff != 0 {
+		return "", nil, errors.New("invalid bech32 padding")
+	}
+	return hrp, data, nil
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	result := make([]byte, 0, len(hrp)*2+1)
+	for _, c := range []byte(hrp) {
+		result = append(result, c>>5)
+	}
+	result = append(result, 0)
+	for _, c := range []byte(hrp) {
+		result = append(result, c&31)
+	}
+	return result
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ws "nhooyr.io/websocket"
+)
+
+const testNostrPrivateKey = "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa"
+
+func Test_nostrParsePrivateKey(t *testing.T) {
+	key, err := nostrParsePrivateKey("nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe5")
+	require.NoError(t, err)
+	assert.Equal(t, testNostrPrivateKey, hex.EncodeToString(key.Serialize()))
+
+	key, err = nostrParsePrivateKey(testNostrPrivateKey)
+	require.NoError(t, err)
+	assert.Equal(t, testNostrPrivateKey, hex.EncodeToString(key.Serialize()))
+
+	_, err = nostrParsePrivateKey("nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe6")
+	assert.Error(t, err)
+	_, err = nostrParsePrivateKey("abc")
+	assert.Error(t, err)
+}
+
+func Test_nostrEventSign(t *testing.T) {
+	key, err := nostrParsePrivateKey(testNostrPrivateKey)
+	require.NoError(t, err)
+
+	event := &nostrEvent{
+		CreatedAt: 1700000000,
+		Kind:      nostrKindNote,
+		Tags:      [][]string{{"t", "test"}},
+		Content:   "Line \"one\"\nLine <two> é",
+	}
+	require.NoError(t, event.sign(key))
+
+	assert.Equal(t, `[0,"`+event.PubKey+`",1700000000,1,[["t","test"]],"Line \"one\"\nLine <two> é"]`, string(event.serialize()))
+
+	hash := sha256.Sum256(event.serialize())
+	assert.Equal(t, hex.EncodeToString(hash[:]), event.ID)
+
+	pubKeyBytes, _ := hex.DecodeString(event.PubKey)
+	pubKey, err := schnorr.ParsePubKey(pubKeyBytes)
+	require.NoError(t, err)
+	sigBytes, _ := hex.DecodeString(event.Sig)
+	sig, err := schnorr.ParseSignature(sigBytes)
+	require.NoError(t, err)
+	assert.True(t, sig.Verify(hash[:], pubKey))
+}
+
+func Test_nostr(t *testing.T) {
+	// Fake relay
+	var mu sync.Mutex
+	var events []*nostrEvent
+	eventCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events)
+	}
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := ws.Accept(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close(ws.StatusNormalClosure, "")
+		_, data, err := c.Read(r.Context())
+		require.NoError(t, err)
+		var msg []json.RawMessage
+		require.NoError(t, json.Unmarshal(data, &msg))
+		require.Len(t, msg, 2)
+		var event nostrEvent
+		require.NoError(t, json.Unmarshal(msg[1], &event))
+		mu.Lock()
+		events = append(events, &event)
+		mu.Unlock()
+		_ = c.Write(r.Context(), ws.MessageText, []byte(`["NOTICE","hello"]`))
+		_ = c.Write(r.Context(), ws.MessageText, []byte(`["OK","`+event.ID+`",true,""]`))
+		_, _, _ = c.Read(r.Context())
+	}))
+	defer relay.Close()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: newHttpClient(),
+	}
+	require.NoError(t, app.initConfig(false))
+	app.cfg.Blogs["default"].Nostr = &configNostr{
+		Enabled:    true,
+		PrivateKey: testNostrPrivateKey,
+		Relays:     []string{"ws" + strings.TrimPrefix(relay.URL, "http")},
+	}
+	require.NoError(t, app.initNostr())
+	app.initMarkdown()
+	_ = app.initCache()
+	app.d = app.buildRouter()
+
+	// Note
+	require.NoError(t, app.createPost(&post{
+		Path:       "/note",
+		Section:    "posts",
+		Status:     statusPublished,
+		Visibility: visibilityPublic,
+		Content:    "Hello Nostr!",
+		Parameters: map[string][]string{"tags": {"Go Blog"}},
+	}))
+	require.Eventually(t, func() bool { return eventCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	note := events[0]
+	assert.Equal(t, nostrKindNote, note.Kind)
+	assert.Equal(t, app.cfg.Blogs["default"].Nostr.publicKey(), note.PubKey)
+	assert.Equal(t, "Hello Nostr!\n\nhttp://localhost:8080/s/1", note.Content)
+	assert.Contains(t, note.Tags, []string{"r", "http://localhost:8080/note"})
+	assert.Contains(t, note.Tags, []string{"t", "goblog"})
+
+	require.Eventually(t, func() bool {
+		p, err := app.getPost("/note")
+		return err == nil && len(p.Parameters[nostrEventParameter]) == 1 && p.Parameters[nostrEventParameter][0] == note.ID
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Article
+	require.NoError(t, app.createPost(&post{
+		Path:       "/article",
+		Section:    "posts",
+		Status:     statusPublished,
+		Visibility: visibilityPublic,
+		Published:  "2023-11-14T22:13:20Z",
+		Content:    "Some **Markdown** content",
+		Parameters: map[string][]string{"title": {"Article"}},
+	}))
+	require.Eventually(t, func() bool { return eventCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+	article := events[1]
+	assert.Equal(t, nostrKindArticle, article.Kind)
+	assert.Equal(t, "Some **Markdown** content", article.Content)
+	assert.Contains(t, article.Tags, []string{"d", "/article"})
+	assert.Contains(t, article.Tags, []string{"title", "Article"})
+	assert.Contains(t, article.Tags, []string{"published_at", "1700000000"})
+
+	// Deletion
+	require.Eventually(t, func() bool {
+		p, err := app.getPost("/article")
+		return err == nil && len(p.Parameters[nostrEventParameter]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, app.deletePost("/article"))
+	require.Eventually(t, func() bool { return eventCount() == 3 }, 5*time.Second, 10*time.Millisecond)
+	deletion := events[2]
+	assert.Equal(t, nostrKindDeletion, deletion.Kind)
+	assert.Contains(t, deletion.Tags, []string{"e", article.ID})
+	assert.Contains(t, deletion.Tags, []string{"a", "30023:" + article.PubKey + ":/article"})
+
+	// NIP-05
+	var nip05 map[string]map[string]any
+	err := requests.URL("http://localhost:8080" + nostrPath + "?name=_").
+		Client(newHandlerClient(app.d)).
+		ToJSON(&nip05).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, note.PubKey, nip05["names"]["_"])
+	assert.Len(t, nip05["relays"][note.PubKey], 1)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/samber/lo"
 	"github.com/sourcegraph/conc/pool"
 	"github.com/vcraescu/go-paginator/v2"
 	"go.goblog.app/app/pkgs/bufferpool"
@@ -23,7 +24,54 @@ type notification struct {
 	Text string
 }
 
-func (a *goBlog) sendNotification(text string) {
+type notificationType string
+
+const (
+	notificationTypeFollower    notificationType = "follower"
+	notificationTypeWebmention  notificationType = "webmention"
+	notificationTypeComment     notificationType = "comment"
+	notificationTypeInteraction notificationType = "interaction"
+	notificationTypeContact     notificationType = "contact"
+	notificationTypeError       notificationType = "error"
+	notificationTypeMonitoring  notificationType = "monitoring"
+	notificationTypeSecurity    notificationType = "security"
+	notificationTypeCertificate notificationType = "certificate"
+)
+
+// notificationProvider is a channel that can deliver notifications (e.g. Telegram or ntfy)
+type notificationProvider interface {
+	// Check if the provider wants to receive notifications of the given type
+	acceptsNotification(typ notificationType) bool
+	// Send the notification text
+	sendNotification(text string) error
+}
+
+// Checks the configured event types of a channel, no configured types means all types
+func notificationEventsAllowed(events []string, typ notificationType) bool {
+	return len(events) == 0 || lo.Contains(events, string(typ))
+}
+
+func (a *goBlog) notificationProviders() (providers []notificationProvider) {
+	cfg := a.cfg.Notifications
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Ntfy.enabled() {
+		providers = append(providers, &ntfyNotifier{a: a, cfg: cfg.Ntfy})
+	}
+	if cfg.Telegram.enabled() {
+		providers = append(providers, &telegramNotifier{a: a, cfg: cfg.Telegram})
+	}
+	if cfg.Matrix.enabled() {
+		providers = append(providers, &matrixNotifier{a: a, cfg: cfg.Matrix})
+	}
+	if cfg.Email.enabled() {
+		providers = append(providers, &emailNotifier{cfg: cfg.Email})
+	}
+	return providers
+}
+
+func (a *goBlog) sendNotification(typ notificationType, text string) {
 	n := &notification{
 		Time: time.Now().Unix(),
 		Text: text,
@@ -31,22 +79,18 @@ func (a *goBlog) sendNotification(text string) {
 	if err := a.db.saveNotification(n); err != nil {
 		log.Println("Failed to save notification:", err.Error())
 	}
-	if cfg := a.cfg.Notifications; cfg != nil {
-		p := pool.New().WithErrors()
-		p.Go(func() error {
-			return a.sendNtfy(cfg.Ntfy, n.Text)
-		})
-		p.Go(func() error {
-			_, _, err := a.sendTelegram(cfg.Telegram, n.Text, "", false)
-			return err
-		})
+	p := pool.New().WithErrors()
+	for _, provider := range a.notificationProviders() {
+		if !provider.acceptsNotification(typ) {
+			continue
+		}
+		provider := provider
 		p.Go(func() error {
-			_, err := a.sendMatrix(cfg.Matrix, n.Text)
-			return err
+			return provider.sendNotification(n.Text)
 		})
-		if err := p.Wait(); err != nil {
-			log.Println("Failed to send notification:", err.Error())
-		}
+	}
+	if err := p.Wait(); err != nil {
+		log.Println("Failed to send notification:", err.Error())
 	}
 }
 
@@ -35,3 +35,16 @@ func (a *goBlog) sendNtfy(cfg *configNtfy, msg string) error {
 	}
 	return builder.Fetch(context.Background())
 }
+
+type ntfyNotifier struct {
+	a   *goBlog
+	cfg *configNtfy
+}
+
+func (n *ntfyNotifier) acceptsNotification(typ notificationType) bool {
+	return notificationEventsAllowed(n.cfg.Events, typ)
+}
+
+func (n *ntfyNotifier) sendNotification(text string) error {
+	return n.a.sendNtfy(n.cfg, text)
+}
@@ -28,7 +28,7 @@ func Test_ntfySending(t *testing.T) {
 			},
 		}
 
-		app.sendNotification("Test notification")
+		app.sendNotification(notificationTypeWebmention, "Test notification")
 
 		req := fakeClient.req
 
@@ -59,7 +59,7 @@ func Test_ntfySending(t *testing.T) {
 			},
 		}
 
-		app.sendNotification("Test notification")
+		app.sendNotification(notificationTypeWebmention, "Test notification")
 
 		req := fakeClient.req
 
@@ -83,6 +83,28 @@ func Test_ntfySending(t *testing.T) {
 		assert.Equal(t, http.StatusOK, res.StatusCode)
 	})
 
+	t.Run("Filtered event types", func(t *testing.T) {
+		app.cfg.Notifications = &configNotifications{
+			Ntfy: &configNtfy{
+				Enabled: true,
+				Topic:   "topic",
+				Events:  []string{string(notificationTypeError)},
+			},
+		}
+
+		fakeClient.clean()
+		app.sendNotification(notificationTypeWebmention, "Test notification")
+		assert.Nil(t, fakeClient.req)
+
+		app.sendNotification(notificationTypeError, "Test error")
+		require.NotNil(t, fakeClient.req)
+
+		reqBody, _ := fakeClient.req.GetBody()
+		reqBodyByte, _ := io.ReadAll(reqBody)
+
+		assert.Equal(t, "Test error", string(reqBodyByte))
+	})
+
 }
 
 func Test_ntfyConfig(t *testing.T) {
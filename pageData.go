@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bodylimit"
+	"go.goblog.app/app/pkgs/bufferpool"
+	"gopkg.in/yaml.v3"
+)
+
+// Pages (posts) can get data from an external JSON or YAML file or URL, which is refreshed regularly.
+// The content of the page is then used as template, for example "I'm currently in {{ .Data.city }}".
+
+const (
+	defaultPageDataRefresh = 15 // minutes
+	pageDataCheckInterval  = time.Minute
+	pageDataSizeLimit      = 1 * bodylimit.MB
+)
+
+type pageData struct {
+	data    any
+	raw     []byte
+	fetched time.Time
+}
+
+type pageDataStore struct {
+	mutex sync.RWMutex
+	pages map[string]*pageData
+}
+
+func (a *goBlog) initPageData() {
+	if len(a.cfg.PageData) == 0 {
+		return
+	}
+	a.pageData = &pageDataStore{}
+	a.refreshPageData()
+	ticker := time.NewTicker(pageDataCheckInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.refreshPageData()
+			}
+		}
+	}()
+	a.addShutdownBeforeDatabase(func() {
+		ticker.Stop()
+		close(done)
+		log.Println("Page data refresh stopped")
+	})
+}
+
+// Reload the data of all pages whose refresh interval elapsed
+func (a *goBlog) refreshPageData() {
+	for _, pd := range a.cfg.PageData {
+		if current := a.pageData.get(pd.Path); current != nil && time.Since(current.fetched) < pd.refreshInterval() {
+			continue
+		}
+		if err := a.loadPageData(pd); err != nil {
+			log.Printf("Failed to load page data for %s: %v", pd.Path, err)
+		}
+	}
+}
+
+func (pd *configPageData) refreshInterval() time.Duration {
+	return time.Duration(lo.If(pd.Refresh > 0, pd.Refresh).Else(defaultPageDataRefresh)) * time.Minute
+}
+
+func (a *goBlog) loadPageData(pd *configPageData) error {
+	raw, err := a.readPageDataSource(pd.Source)
+	if err != nil {
+		return err
+	}
+	current := a.pageData.get(pd.Path)
+	if current != nil && bytes.Equal(current.raw, raw) {
+		// Unchanged, keep the cached pages
+		a.pageData.set(pd.Path, &pageData{data: current.data, raw: current.raw, fetched: time.Now()})
+		return nil
+	}
+	// YAML is a superset of JSON, so this parses both
+	var data any
+	if err = yaml.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+	a.pageData.set(pd.Path, &pageData{data: data, raw: raw, fetched: time.Now()})
+	// Remove the outdated renderings
+	a.cache.purgePaths(pd.Path)
+	if a.asObjectCache != nil {
+		a.asObjectCache.clear()
+	}
+	return nil
+}
+
+func (a *goBlog) readPageDataSource(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		buf := bufferpool.Get()
+		defer bufferpool.Put(buf)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		err := requests.URL(source).Client(a.httpClient).
+			Handle(func(r *http.Response) error {
+				_, err := io.Copy(buf, io.LimitReader(r.Body, pageDataSizeLimit))
+				return err
+			}).
+			Fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Clone(buf.Bytes()), nil
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, pageDataSizeLimit))
+}
+
+func (s *pageDataStore) get(path string) *pageData {
+	if s == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.pages[path]
+}
+
+func (s *pageDataStore) set(path string, pd *pageData) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pages == nil {
+		s.pages = map[string]*pageData{}
+	}
+	s.pages[path] = pd
+}
+
+// Content of the post, executed as template with the page data if the post has external data
+func (a *goBlog) postContentWithPageData(p *post) string {
+	pd := a.pageData.get(p.Path)
+	if pd == nil {
+		return p.Content
+	}
+	tmpl, err := template.New("page").Option("missingkey=zero").Parse(p.Content)
+	if err != nil {
+		log.Printf("Failed to parse content of %s as template: %v", p.Path, err)
+		return p.Content
+	}
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	if err = tmpl.Execute(buf, map[string]any{"Data": pd.data}); err != nil {
+		log.Printf("Failed to execute content of %s as template: %v", p.Path, err)
+		return p.Content
+	}
+	return buf.String()
+}
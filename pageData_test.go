@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_pageData(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	dataFile := filepath.Join(t.TempDir(), "now.yaml")
+	require.NoError(t, os.WriteFile(dataFile, []byte("city: Berlin\nbooks:\n  - One\n  - Two\n"), 0600))
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.PageData = []*configPageData{
+		{Path: "/now", Source: dataFile, Refresh: 5},
+		{Path: "/status", Source: "https://example.org/status.json"},
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+	app.pageData = &pageDataStore{}
+
+	require.NoError(t, app.createPost(&post{
+		Path:    "/now",
+		Status:  statusPublished,
+		Content: "I'm in {{ .Data.city }}.\n\n{{ range .Data.books }}- {{ . }}\n{{ end }}",
+	}))
+	require.NoError(t, app.createPost(&post{
+		Path:    "/status",
+		Status:  statusPublished,
+		Content: "Status: {{ .Data.status }}{{ .Data.missing }}",
+	}))
+	require.NoError(t, app.createPost(&post{
+		Path:    "/other",
+		Status:  statusPublished,
+		Content: "No {{ .Data.city }} here",
+	}))
+
+	fc.setFakeResponse(http.StatusOK, `{"status": "busy"}`)
+	app.refreshPageData()
+	require.NotNil(t, fc.req)
+	assert.Equal(t, "https://example.org/status.json", fc.req.URL.String())
+
+	client := newHandlerClient(app.d)
+	get := func(path string) string {
+		var body string
+		require.NoError(t, requests.URL("http://localhost:8080"+path).Client(client).ToString(&body).Fetch(context.Background()))
+		return body
+	}
+
+	assert.Contains(t, get("/now"), "m in Berlin.")
+	assert.Contains(t, get("/now"), "<li>Two")
+	assert.Contains(t, get("/status"), "Status: busy")
+	assert.Contains(t, get("/other"), "No {{ .Data.city }} here")
+
+	// Not refreshed before the interval elapsed
+	require.NoError(t, os.WriteFile(dataFile, []byte(`{"city": "Paris"}`), 0600))
+	app.refreshPageData()
+	assert.Contains(t, get("/now"), "m in Berlin.")
+
+	// Refreshed after the interval, the cached page is purged
+	app.pageData.get("/now").fetched = time.Now().Add(-6 * time.Minute)
+	app.refreshPageData()
+	assert.Contains(t, get("/now"), "m in Paris.")
+
+	// Failed refresh keeps the old data
+	require.NoError(t, os.Remove(dataFile))
+	app.pageData.get("/now").fetched = time.Now().Add(-6 * time.Minute)
+	app.refreshPageData()
+	assert.Contains(t, get("/now"), "m in Paris.")
+
+	// Invalid template shows the content as it is
+	p, err := app.getPost("/status")
+	require.NoError(t, err)
+	p.Content = "Status: {{ .Data.status"
+	assert.Equal(t, "Status: {{ .Data.status", app.postContentWithPageData(p))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"go.goblog.app/app/pkgs/bufferpool"
+)
+
+// Path templates of sections are Go templates or use the shorthand syntax with placeholders
+// (like "/:section/:year/:month/:slug"), shorthand templates are relative to the blog path
+
+var pathTemplatePlaceholders = strings.NewReplacer(
+	":section", "{{.Section}}",
+	":year", "{{.Year}}",
+	":month", `{{printf "%02d" .Month}}`,
+	":day", `{{printf "%02d" .Day}}`,
+	":slug", "{{.Slug}}",
+)
+
+func (a *goBlog) defaultPathTemplate(blog string) string {
+	return "{{printf \"" + a.getRelativePath(blog, "/%v/%02d/%02d/%v") + "\" .Section .Year .Month .Slug}}"
+}
+
+// Convert the shorthand syntax to a Go template
+func expandPathTemplate(tmpl string) string {
+	if tmpl == "" || strings.Contains(tmpl, "{{") {
+		return tmpl
+	}
+	return "{{.BlogPrefix}}" + pathTemplatePlaceholders.Replace(tmpl)
+}
+
+// Execute the path template (or the default one) of the section
+func (a *goBlog) executePathTemplate(blog, section, tmpl string, published time.Time, slug string) (string, error) {
+	pathTmpl, err := template.New("location").Parse(defaultIfEmpty(expandPathTemplate(tmpl), a.defaultPathTemplate(blog)))
+	if err != nil {
+		return "", errors.New("failed to parse location template")
+	}
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	err = pathTmpl.Execute(buf, map[string]any{
+		"BlogPath": a.getRelativePath(blog, ""),
+		// Blog path without trailing slash, used by the shorthand syntax
+		"BlogPrefix": strings.TrimSuffix(a.getRelativePath(blog, ""), "/"),
+		"Year":       published.Year(),
+		"Month":      int(published.Month()),
+		"Day":        published.Day(),
+		"Slug":       slug,
+		"Section":    section,
+	})
+	if err != nil {
+		return "", errors.New("failed to execute location template")
+	}
+	return buf.String(), nil
+}
+
+// Execute the path template with sample data, like checkPost does
+func (a *goBlog) checkPathTemplate(blog string, section *configSection) error {
+	sample := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	p1, err := a.executePathTemplate(blog, section.Name, section.PathTemplate, sample, "slug")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(p1, "/") {
+		return fmt.Errorf("template results in a path without leading slash: %q", p1)
+	}
+	// Posts on the same day would collide
+	if p2, _ := a.executePathTemplate(blog, section.Name, section.PathTemplate, sample, "other"); p1 == p2 {
+		return errors.New("template doesn't contain the slug")
+	}
+	return nil
+}
+
+// Generated paths get a number appended if there's already a post at the path
+func (a *goBlog) uniquePostPath(p string) string {
+	unique := p
+	for i := 2; ; i++ {
+		if _, err := a.getPost(unique); errors.Is(err, errPostNotFound) {
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d", p, i)
+	}
+}
+
+// Move the posts of the section that still have the path generated with the old template to the path of
+// the new template, the old paths are kept as aliases and redirect to the new ones
+func (a *goBlog) movePostsToPathTemplate(blog, section, oldTmpl, newTmpl string) (moved int, err error) {
+	posts, err := a.getPosts(&postsRequestConfig{blog: blog, sections: []string{section}})
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range posts {
+		published, err := dateparse.ParseLocal(p.Published)
+		if err != nil {
+			continue
+		}
+		slug := path.Base(p.Path)
+		// Posts with custom paths keep them
+		if oldPath, err := a.executePathTemplate(blog, section, oldTmpl, published, slug); err != nil || oldPath != p.Path {
+			continue
+		}
+		newPath, err := a.executePathTemplate(blog, section, newTmpl, published, slug)
+		if err != nil {
+			return moved, err
+		}
+		if newPath == p.Path {
+			continue
+		}
+		oldPath, oldStatus, oldVisibility := p.Path, p.Status, p.Visibility
+		p.Path = a.uniquePostPath(newPath)
+		if err = a.replacePost(p, oldPath, oldStatus, oldVisibility); err != nil {
+			return moved, err
+		}
+		log.Println("Moved post", oldPath, "to", p.Path)
+		moved++
+	}
+	return moved, nil
+}
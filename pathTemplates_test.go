@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_expandPathTemplate(t *testing.T) {
+	assert.Equal(t, "", expandPathTemplate(""))
+	assert.Equal(t, "{{.BlogPrefix}}/{{.Slug}}", expandPathTemplate("/:slug"))
+	assert.Equal(t, `{{.BlogPrefix}}/{{.Section}}/{{.Year}}/{{printf "%02d" .Month}}/{{.Slug}}`, expandPathTemplate("/:section/:year/:month/:slug"))
+	// Go templates are kept
+	assert.Equal(t, "/{{.Section}}/{{.Slug}}", expandPathTemplate("/{{.Section}}/{{.Slug}}"))
+}
+
+func Test_checkPathTemplate(t *testing.T) {
+	h := newTestHarness(t)
+	blog := h.app.cfg.DefaultBlog
+
+	assert.NoError(t, h.app.checkPathTemplate(blog, &configSection{Name: "posts", PathTemplate: "/:section/:year/:slug"}))
+	assert.NoError(t, h.app.checkPathTemplate(blog, &configSection{Name: "posts"}))
+	assert.ErrorContains(t, h.app.checkPathTemplate(blog, &configSection{Name: "posts", PathTemplate: "/:year/:month"}), "slug")
+	assert.ErrorContains(t, h.app.checkPathTemplate(blog, &configSection{Name: "posts", PathTemplate: "{{.Slug}}"}), "leading slash")
+	assert.Error(t, h.app.checkPathTemplate(blog, &configSection{Name: "posts", PathTemplate: "/{{.Slug"}))
+}
+
+func Test_pathTemplates(t *testing.T) {
+	h := newTestHarness(t)
+	blog := h.app.cfg.DefaultBlog
+	section := h.app.cfg.Blogs[blog].DefaultSection
+	h.app.cfg.Blogs[blog].Sections[section].PathTemplate = "/:slug"
+
+	// Generated paths don't collide
+	p1 := h.createPost(&post{Slug: "hello", Published: "2022-01-02T10:00:00Z", Content: "First"})
+	p2 := h.createPost(&post{Slug: "hello", Published: "2022-01-03T10:00:00Z", Content: "Second"})
+	p3 := h.createPost(&post{Path: "/pages/custom", Published: "2022-01-03T10:00:00Z", Content: "Custom"})
+	assert.Equal(t, "/hello", p1.Path)
+	assert.Equal(t, "/hello-2", p2.Path)
+
+	// Moving the posts to a new template
+	newTmpl := "/:section/:year/:slug"
+	h.app.cfg.Blogs[blog].Sections[section].PathTemplate = newTmpl
+	moved, err := h.app.movePostsToPathTemplate(blog, section, "/:slug", newTmpl)
+	require.NoError(t, err)
+	assert.Equal(t, 2, moved)
+
+	// Posts with generated paths are moved, the old paths redirect
+	moved1, err := h.app.getPost("/" + section + "/2022/hello")
+	require.NoError(t, err)
+	assert.Equal(t, "First", moved1.Content)
+	status, header, _ := h.get("/hello")
+	assert.Equal(t, http.StatusMovedPermanently, status)
+	assert.Equal(t, "/"+section+"/2022/hello", header.Get("Location"))
+
+	_, err = h.app.getPost("/" + section + "/2022/hello-2")
+	assert.NoError(t, err)
+
+	// Posts with custom paths keep them
+	_, err = h.app.getPost(p3.Path)
+	assert.NoError(t, err)
+}
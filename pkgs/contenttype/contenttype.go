@@ -8,6 +8,7 @@ const (
 	AS            = "application/activity+json"
 	ATOM          = "application/atom+xml"
 	CSS           = "text/css"
+	GeoJSON       = "application/geo+json"
 	HTML          = "text/html"
 	JPEG          = "image/jpeg"
 	JS            = "application/javascript"
@@ -6,9 +6,11 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/flate"
 	"github.com/klauspost/compress/gzip"
 	"github.com/samber/lo"
@@ -33,6 +35,39 @@ var defaultCompressibleContentTypes = []string{
 	"application/xrd+xml",
 }
 
+// IsCompressible reports if responses with the content type get compressed by default
+func IsCompressible(contentType string) bool {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	return lo.Contains(defaultCompressibleContentTypes, strings.TrimSpace(contentType))
+}
+
+// AcceptedEncodings returns the encodings of the Accept-Encoding header that aren't disabled with q=0
+func AcceptedEncodings(r *http.Request) []string {
+	var encodings []string
+	for _, part := range strings.Split(strings.ToLower(r.Header.Get("Accept-Encoding")), ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if qv, err := strconv.ParseFloat(q, 64); err == nil && qv == 0 {
+				continue
+			}
+		}
+		encodings = append(encodings, strings.TrimSpace(encoding))
+	}
+	return encodings
+}
+
+// AddVary adds Accept-Encoding to the Vary header, if it isn't there yet
+func AddVary(header http.Header) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	header.Add("Vary", "Accept-Encoding")
+}
+
 // Compress is a middleware that compresses response
 // body of a given content types to a data format based
 // on Accept-Encoding request header. It uses a given
@@ -75,6 +110,7 @@ func NewCompressor(level int, types ...string) *Compressor {
 
 	c.SetEncoder("deflate", encoderDeflate)
 	c.SetEncoder("gzip", encoderGzip)
+	c.SetEncoder("br", encoderBrotli)
 
 	return c
 }
@@ -170,7 +206,7 @@ func (cw *compressResponseWriter) writer() io.Writer {
 // selectEncoder returns the encoder, the name of the encoder, and a closer function.
 func (cw *compressResponseWriter) selectEncoder() (compressWriter, string, func()) {
 	// Parse the names of all accepted algorithms from the header.
-	accepted := strings.Split(strings.ToLower(cw.request.Header.Get("Accept-Encoding")), ",")
+	accepted := AcceptedEncodings(cw.request)
 
 	// Find supported encoder by accepted list by precedence
 	for _, name := range cw.compressor.encodingPrecedence {
@@ -213,6 +249,11 @@ func (cw *compressResponseWriter) WriteHeader(code int) {
 		return
 	}
 
+	if code == http.StatusPartialContent || cw.Header().Get("Content-Range") != "" {
+		// Compressing a part would break the byte ranges.
+		return
+	}
+
 	if !cw.isCompressable() {
 		// Data is not compressable.
 		return
@@ -222,7 +263,7 @@ func (cw *compressResponseWriter) WriteHeader(code int) {
 	cw.encoder, encoding, cw.cleanup = cw.selectEncoder()
 	if encoding != "" {
 		cw.Header().Set("Content-Encoding", encoding)
-		cw.Header().Add("Vary", "Accept-Encoding")
+		AddVary(cw.Header())
 
 		// The content-length after compression is unknown
 		cw.Header().Del("Content-Length")
@@ -266,6 +307,11 @@ func (cw *compressResponseWriter) Close() error {
 	return errors.New("io.WriteCloser is unavailable on the writer")
 }
 
+func encoderBrotli(w io.Writer, _ int) compressWriter {
+	// The flate levels don't match the brotli levels, use the default level of brotli
+	return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+}
+
 func encoderGzip(w io.Writer, level int) compressWriter {
 	gw, err := gzip.NewWriterLevel(w, level)
 	if err != nil {
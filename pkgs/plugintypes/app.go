@@ -77,4 +77,6 @@ type RenderContext interface {
 	GetURL() string
 	// Get the blog name
 	GetBlog() string
+	// Get the Content-Security-Policy nonce for inline scripts and styles (empty if disabled)
+	GetCSPNonce() string
 }
@@ -260,15 +260,19 @@ func (W _go_goblog_app_app_pkgs_plugintypes_PostUpdatedHook) PostUpdated(post pl
 
 // _go_goblog_app_app_pkgs_plugintypes_RenderContext is an interface wrapper for RenderContext type
 type _go_goblog_app_app_pkgs_plugintypes_RenderContext struct {
-	IValue   interface{}
-	WGetBlog func() string
-	WGetPath func() string
-	WGetURL  func() string
+	IValue       interface{}
+	WGetBlog     func() string
+	WGetCSPNonce func() string
+	WGetPath     func() string
+	WGetURL      func() string
 }
 
 func (W _go_goblog_app_app_pkgs_plugintypes_RenderContext) GetBlog() string {
 	return W.WGetBlog()
 }
+func (W _go_goblog_app_app_pkgs_plugintypes_RenderContext) GetCSPNonce() string {
+	return W.WGetCSPNonce()
+}
 func (W _go_goblog_app_app_pkgs_plugintypes_RenderContext) GetPath() string {
 	return W.WGetPath()
 }
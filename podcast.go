@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jlelse/feeds"
+	"github.com/samber/lo"
+)
+
+// Podcasts: posts can have an enclosure (an audio or video file), which is added to the feeds
+// (RSS enclosure, Atom enclosure link and JSON Feed attachment). Blogs with podcast config
+// get the iTunes tags in their RSS feeds, so the feeds can be submitted to podcast directories.
+
+const (
+	enclosureParameter       = "enclosure"
+	enclosureTypeParameter   = "enclosuretype"
+	enclosureLengthParameter = "enclosurelength"
+
+	itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+)
+
+// Enclosure of the post, from the enclosure parameters or the first audio file
+func (a *goBlog) postEnclosure(p *post) *feeds.Enclosure {
+	enclosureURL := p.firstParameter(enclosureParameter)
+	if enclosureURL == "" && a.cfg.Micropub.AudioParam != "" {
+		enclosureURL = p.firstParameter(a.cfg.Micropub.AudioParam)
+	}
+	if enclosureURL == "" {
+		return nil
+	}
+	e := &feeds.Enclosure{
+		Url:    enclosureURL,
+		Type:   p.firstParameter(enclosureTypeParameter),
+		Length: p.firstParameter(enclosureLengthParameter),
+	}
+	if e.Type == "" {
+		if u, err := url.Parse(enclosureURL); err == nil {
+			e.Type = mime.TypeByExtension(path.Ext(u.Path))
+		}
+	}
+	if e.Length == "" {
+		e.Length = a.localMediaFileSize(enclosureURL)
+	}
+	// RSS requires type and length
+	e.Type = defaultIfEmpty(e.Type, "application/octet-stream")
+	e.Length = defaultIfEmpty(e.Length, "0")
+	return e
+}
+
+// Size of a file in the local media storage, empty if the URL isn't a local media file
+func (a *goBlog) localMediaFileSize(fileURL string) string {
+	name := path.Base(fileURL)
+	if name == "" || a.mediaFileLocation(name) != fileURL {
+		return ""
+	}
+	fi, err := os.Stat(filepath.Join(mediaFilePath, name))
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(fi.Size(), 10)
+}
+
+type podcastRss struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	ItunesNamespace  string   `xml:"xmlns:itunes,attr"`
+	Channel          *podcastRssChannel
+}
+
+type podcastRssChannel struct {
+	*feeds.RssFeed
+	ItunesAuthor   string                 `xml:"itunes:author,omitempty"`
+	ItunesOwner    *podcastItunesOwner    `xml:"itunes:owner,omitempty"`
+	ItunesImage    *podcastItunesImage    `xml:"itunes:image,omitempty"`
+	ItunesCategory *podcastItunesCategory `xml:"itunes:category,omitempty"`
+	ItunesExplicit string                 `xml:"itunes:explicit"`
+}
+
+type podcastItunesOwner struct {
+	Name  string `xml:"itunes:name,omitempty"`
+	Email string `xml:"itunes:email,omitempty"`
+}
+
+type podcastItunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type podcastItunesCategory struct {
+	Text        string                 `xml:"text,attr"`
+	Subcategory *podcastItunesCategory `xml:"itunes:category,omitempty"`
+}
+
+// Write the feed as RSS with the iTunes podcast tags
+func (a *goBlog) writePodcastRss(w io.Writer, feed *feeds.Feed, bc *configBlog) error {
+	pc := bc.Podcast
+	channel := &podcastRssChannel{
+		RssFeed:        (&feeds.Rss{Feed: feed}).RssFeed(),
+		ItunesAuthor:   defaultIfEmpty(pc.Author, a.cfg.User.Name),
+		ItunesImage:    &podcastItunesImage{Href: defaultIfEmpty(pc.Image, a.getFullAddress(a.profileImagePath(profileImageFormatJPEG, 0, 0)))},
+		ItunesExplicit: lo.If(pc.Explicit, "true").Else("false"),
+	}
+	if pc.Title != "" {
+		channel.Title = pc.Title
+	}
+	channel.Language = bc.Lang
+	if owner, email := defaultIfEmpty(pc.Author, a.cfg.User.Name), defaultIfEmpty(pc.Email, a.cfg.User.Email); owner != "" || email != "" {
+		channel.ItunesOwner = &podcastItunesOwner{Name: owner, Email: email}
+	}
+	if pc.Category != "" {
+		channel.ItunesCategory = &podcastItunesCategory{Text: pc.Category}
+		if pc.Subcategory != "" {
+			channel.ItunesCategory.Subcategory = &podcastItunesCategory{Text: pc.Subcategory}
+		}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(&podcastRss{
+		Version:          "2.0",
+		ContentNamespace: "http://purl.org/rss/1.0/modules/content/",
+		ItunesNamespace:  itunesNamespace,
+		Channel:          channel,
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// Post templates: named skeletons for new posts (content, default parameters and section), configured per blog.
+// They can be selected in the editor or with the mp-template property when creating a post via Micropub.
+
+const micropubTemplateParam = "mp-template"
+
+func (bc *configBlog) postTemplateNames() []string {
+	names := lo.Keys(bc.PostTemplates)
+	sort.Strings(names)
+	return names
+}
+
+// Apply the post template to a new post, without overwriting the values the post already has
+func (a *goBlog) applyPostTemplate(p *post, name string) error {
+	bc, ok := a.cfg.Blogs[defaultIfEmpty(p.Blog, a.cfg.DefaultBlog)]
+	if !ok {
+		return errors.New("blog doesn't exist")
+	}
+	pt, ok := bc.PostTemplates[name]
+	if !ok || pt == nil {
+		return errors.New("post template doesn't exist")
+	}
+	if p.Path == "" && p.Section == "" {
+		p.Section = pt.Section
+	}
+	if p.Parameters == nil {
+		p.Parameters = map[string][]string{}
+	}
+	for key, param := range pt.Parameters {
+		if _, ok := p.Parameters[key]; !ok {
+			p.Parameters[key] = append([]string{}, param...)
+		}
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		p.Content = pt.Content
+	}
+	return nil
+}
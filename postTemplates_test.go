@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_postTemplates(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	bc := app.cfg.Blogs[app.cfg.DefaultBlog]
+	bc.Sections["notes"] = &configSection{Name: "notes"}
+	bc.PostTemplates = map[string]*configPostTemplate{
+		"weeknotes": {
+			Title:      "Weeknotes",
+			Section:    "notes",
+			Content:    "## What I did\n\n## What I read",
+			Parameters: map[string][]string{"tags": {"weeknotes"}},
+		},
+	}
+
+	t.Run("Editor", func(t *testing.T) {
+		template := app.editorPostTemplate(app.cfg.DefaultBlog, bc, bc.PostTemplates["weeknotes"], map[string][]string{"title": {"Week 1"}})
+		assert.Contains(t, template, "section: notes\n")
+		assert.Contains(t, template, "tags:\n    - weeknotes\n")
+		assert.Contains(t, template, "title:\n    - Week 1\n")
+		assert.True(t, strings.HasSuffix(template, "---\n## What I did\n\n## What I read"))
+
+		template = app.editorPostTemplate(app.cfg.DefaultBlog, bc, nil, nil)
+		assert.Contains(t, template, "section: posts\n")
+		assert.NotContains(t, template, "weeknotes")
+		assert.Equal(t, []string{"weeknotes"}, bc.postTemplateNames())
+	})
+
+	create := func(values url.Values) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, micropubPath, strings.NewReader(values.Encode()))
+		req.Header.Set(contentType, "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), blogKey, app.cfg.DefaultBlog))
+		rec := httptest.NewRecorder()
+		addAllScopes(http.HandlerFunc(app.serveMicropubPost)).ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	t.Run("Micropub", func(t *testing.T) {
+		res := create(url.Values{"mp-template": {"weeknotes"}, "mp-slug": {"week-1"}})
+		require.Equal(t, http.StatusAccepted, res.StatusCode)
+		location, err := url.Parse(res.Header.Get("Location"))
+		require.NoError(t, err)
+		p, err := app.getPost(location.Path)
+		require.NoError(t, err)
+		assert.Equal(t, "notes", p.Section)
+		assert.Equal(t, "## What I did\n\n## What I read", p.Content)
+		assert.Equal(t, []string{"weeknotes"}, p.Parameters["tags"])
+		assert.Empty(t, p.Parameters[micropubTemplateParam])
+
+		// Given values aren't overwritten
+		res = create(url.Values{"mp-template": {"weeknotes"}, "content": {"Own content"}, "tags": {"other"}})
+		require.Equal(t, http.StatusAccepted, res.StatusCode)
+		location, err = url.Parse(res.Header.Get("Location"))
+		require.NoError(t, err)
+		p, err = app.getPost(location.Path)
+		require.NoError(t, err)
+		assert.Equal(t, "Own content", p.Content)
+		assert.Equal(t, []string{"other"}, p.Parameters["tags"])
+
+		// Unknown template
+		res = create(url.Values{"mp-template": {"unknown"}, "content": {"Test"}})
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"reflect"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"github.com/samber/lo"
 	"github.com/vcraescu/go-paginator/v2"
 	"go.goblog.app/app/pkgs/bufferpool"
+	"go.goblog.app/app/pkgs/htmlbuilder"
 )
 
 var errPostNotFound = errors.New("post not found")
@@ -29,9 +31,11 @@ type post struct {
 	Status     postStatus
 	Visibility postVisibility
 	Priority   int
+	Owner      string // Database user that created the post, empty for the config user
 	// Not persisted
 	Slug          string
 	RenderedTitle string
+	renderMeta    *markdownMeta
 }
 
 type postStatus string
@@ -77,7 +81,26 @@ func (a *goBlog) servePost(w http.ResponseWriter, r *http.Request) {
 	if p.Deleted() {
 		status = http.StatusGone
 	}
+	if p.hasVisibilityWindow() && !a.isLoggedIn(r) {
+		// Hide posts outside their visibility windows from visitors
+		if visible, err := a.isInVisibilityWindow(p); err != nil {
+			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		} else if !visible {
+			a.serve404(w, r)
+			return
+		}
+	}
 	if asRequest, ok := r.Context().Value(asRequestKey).(bool); ok && asRequest {
+		if a.isProtectedBlog(p.Blog) {
+			// Protected blogs aren't federated
+			a.serve404(w, r)
+			return
+		}
+		if p.Deleted() {
+			a.serveActivityStreamsTombstone(w, r)
+			return
+		}
 		if r.URL.Path == a.getRelativePath(p.Blog, "") {
 			a.serveActivityStreams(w, r, status, p.Blog)
 			return
@@ -85,6 +108,15 @@ func (a *goBlog) servePost(w http.ResponseWriter, r *http.Request) {
 		a.serveActivityStreamsPost(w, r, status, p)
 		return
 	}
+	if p.Visibility != visibilityPublic {
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+	w.Header().Add("Link", fmt.Sprintf("<%s>; rel=shortlink", a.shortPostURL(p)))
+	renderMethod, rd := a.postRenderMethodAndData(p)
+	a.renderWithStatusCode(w, r, status, renderMethod, rd)
+}
+
+func (a *goBlog) postRenderMethodAndData(p *post) (func(*htmlbuilder.HtmlBuilder, *renderData), *renderData) {
 	canonical := p.firstParameter("original")
 	if canonical == "" {
 		canonical = a.fullPostURL(p)
@@ -93,15 +125,11 @@ func (a *goBlog) servePost(w http.ResponseWriter, r *http.Request) {
 	if p.Path == a.getRelativePath(p.Blog, "") {
 		renderMethod = a.renderStaticHome
 	}
-	if p.Visibility != visibilityPublic {
-		w.Header().Set("X-Robots-Tag", "noindex")
-	}
-	w.Header().Add("Link", fmt.Sprintf("<%s>; rel=shortlink", a.shortPostURL(p)))
-	a.renderWithStatusCode(w, r, status, renderMethod, &renderData{
+	return renderMethod, &renderData{
 		BlogString: p.Blog,
 		Canonical:  canonical,
 		Data:       p,
-	})
+	}
 }
 
 const defaultRandomPath = "/random"
@@ -157,6 +185,10 @@ func (p *postPaginationAdapter) Slice(offset, length int, data any) error {
 func (a *goBlog) serveHome(w http.ResponseWriter, r *http.Request) {
 	blog, bc := a.getBlog(r)
 	if asRequest, ok := r.Context().Value(asRequestKey).(bool); ok && asRequest {
+		if bc.isProtected() {
+			a.serve404(w, r)
+			return
+		}
 		a.serveActivityStreams(w, r, http.StatusOK, blog)
 		return
 	}
@@ -252,6 +284,10 @@ func (a *goBlog) extractDate(r *http.Request) (year, month, day int, title, date
 	if ds := chi.URLParam(r, "day"); ds != "" {
 		day = stringToInt(ds)
 	}
+	if dms := chi.URLParam(r, "daymonth"); len(dms) == 5 {
+		// Day and month in the format DD-MM
+		day, month = stringToInt(dms[:2]), stringToInt(dms[3:])
+	}
 	titleBuf, pathBuf := bufferpool.Get(), bufferpool.Get()
 	defer bufferpool.Put(titleBuf, pathBuf)
 	if year != 0 {
@@ -284,6 +320,7 @@ type indexConfig struct {
 	tax              *configTaxonomy
 	taxValue         string
 	parameter        string
+	parameterValue   string
 	year, month, day int
 	title            string
 	titleSuffix      string
@@ -291,8 +328,21 @@ type indexConfig struct {
 	summaryTemplate  summaryTyp
 	status           []postStatus
 	visibility       []postVisibility
+	sort             postsSort
+}
+
+// Feeds of a year (like /posts/2022.atom) contain all posts of the year, so archive subscribers and tools
+// don't need the paginated index. After the year ended, clients can cache them forever.
+func (ic *indexConfig) yearFeed() bool {
+	return ic.year != 0 && ic.month == 0 && ic.day == 0
 }
 
+func (ic *indexConfig) frozenYear(now time.Time) bool {
+	return ic.yearFeed() && ic.year < now.Local().Year()
+}
+
+const frozenFeedCacheControl = "public,max-age=31536000,immutable"
+
 const defaultPhotosPath = "/photos"
 
 const indexConfigKey contextKey = "indexConfig"
@@ -318,20 +368,57 @@ func (a *goBlog) serveIndex(w http.ResponseWriter, r *http.Request) {
 	if len(visibility) == 0 {
 		visibility = defaultVisibility
 	}
-	p := paginator.New(&postPaginationAdapter{config: &postsRequestConfig{
-		blog:           blog,
-		sections:       sections,
-		taxonomy:       ic.tax,
-		taxonomyValue:  ic.taxValue,
-		parameter:      ic.parameter,
-		search:         search,
-		publishedYear:  ic.year,
-		publishedMonth: ic.month,
-		publishedDay:   ic.day,
-		status:         status,
-		visibility:     visibility,
-		priorityOrder:  true,
-	}, a: a}, bc.Pagination)
+	ft := feedType(chi.URLParam(r, "feed"))
+	var updatedMin time.Time
+	if ft != noFeed {
+		var err error
+		if updatedMin, err = feedUpdatedMin(r); err != nil {
+			a.serveError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// Language filter
+	lang := r.URL.Query().Get(postLangParameter)
+	if !langCodeRegex.MatchString(lang) {
+		lang = ""
+	}
+	pageSize := bc.Pagination
+	if ft != noFeed {
+		pageSize = a.feedItems(bc)
+	}
+	prc := &postsRequestConfig{
+		blog:                   blog,
+		sections:               sections,
+		taxonomy:               ic.tax,
+		taxonomyValue:          ic.taxValue,
+		parameter:              ic.parameter,
+		parameterValue:         ic.parameterValue,
+		search:                 search,
+		publishedYear:          ic.year,
+		publishedMonth:         ic.month,
+		publishedDay:           ic.day,
+		updatedMin:             updatedMin,
+		status:                 status,
+		visibility:             visibility,
+		priorityOrder:          true,
+		sort:                   ic.sort,
+		withinVisibilityWindow: !a.isLoggedIn(r),
+		lang:                   lang,
+		defaultLang:            bc.Lang,
+	}
+	if ft != noFeed && ic.yearFeed() {
+		// Year feeds contain all posts of the year
+		count, err := a.db.countPosts(prc)
+		if err != nil {
+			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		pageSize = lo.Max([]int{count, 1})
+		if ic.frozenYear(time.Now()) && !a.isLoggedIn(r) {
+			w.Header().Set(cacheControl, frozenFeedCacheControl)
+		}
+	}
+	p := paginator.New(&postPaginationAdapter{config: prc, a: a}, pageSize)
 	p.SetPage(stringToInt(chi.URLParam(r, "page")))
 	var posts []*post
 	err := p.Results(&posts)
@@ -359,7 +446,7 @@ func (a *goBlog) serveIndex(w http.ResponseWriter, r *http.Request) {
 		description = ic.section.Description
 	}
 	// Check if feed
-	if ft := feedType(chi.URLParam(r, "feed")); ft != noFeed {
+	if ft != noFeed {
 		a.generateFeed(blog, ft, w, r, posts, title, description)
 		return
 	}
@@ -390,12 +477,17 @@ func (a *goBlog) serveIndex(w http.ResponseWriter, r *http.Request) {
 		nextPage, _ = p.Page()
 	}
 	nextPath = fmt.Sprintf("%s/page/%d", strings.TrimSuffix(path, "/"), nextPage)
+	if lang != "" {
+		langQuery := "?" + postLangParameter + "=" + url.QueryEscape(lang)
+		prevPath += langQuery
+		nextPath += langQuery
+	}
 	summaryTemplate := ic.summaryTemplate
 	if summaryTemplate == "" {
 		summaryTemplate = defaultSummary
 	}
 	a.render(w, r, a.renderIndex, &renderData{
-		Canonical: a.getFullAddress(path),
+		Canonical: a.getCanonicalAddress(path),
 		Data: &indexRenderData{
 			title:           title,
 			description:     description,
@@ -403,6 +495,7 @@ func (a *goBlog) serveIndex(w http.ResponseWriter, r *http.Request) {
 			hasPrev:         hasPrev,
 			hasNext:         hasNext,
 			first:           path,
+			lang:            lang,
 			prev:            prevPath,
 			next:            nextPath,
 			summaryTemplate: summaryTemplate,
@@ -6,12 +6,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/araddon/dateparse"
 	"github.com/samber/lo"
-	"go.goblog.app/app/pkgs/bufferpool"
 	"go.goblog.app/app/pkgs/builderpool"
 )
 
@@ -107,10 +105,15 @@ func (a *goBlog) checkPost(p *post, new bool) (err error) {
 		}
 		p.Parameters[pk] = pvs
 	}
-	// Add context for replies and likes
+	// Check visibility windows
+	if err = checkVisibilityWindowParameters(p); err != nil {
+		return err
+	}
+	// Add context for replies, likes and reposts
 	if new {
 		a.addReplyTitleAndContext(p)
 		a.addLikeTitleAndContext(p)
+		a.addRepostTitleAndContext(p)
 	}
 	// Check path
 	if p.Path != "/" {
@@ -124,28 +127,11 @@ func (a *goBlog) checkPost(p *post, new bool) (err error) {
 		if p.Slug == "" {
 			p.Slug = fmt.Sprintf("%v-%02d-%02d-%v", published.Year(), int(published.Month()), published.Day(), randomString(5))
 		}
-		pathTmplString := defaultIfEmpty(
-			a.getBlogFromPost(p).Sections[p.Section].PathTemplate,
-			"{{printf \""+a.getRelativePath(p.Blog, "/%v/%02d/%02d/%v")+"\" .Section .Year .Month .Slug}}",
-		)
-		pathTmpl, err := template.New("location").Parse(pathTmplString)
+		generated, err := a.executePathTemplate(p.Blog, p.Section, a.getBlogFromPost(p).Sections[p.Section].PathTemplate, published, p.Slug)
 		if err != nil {
-			return errors.New("failed to parse location template")
-		}
-		pathBuffer := bufferpool.Get()
-		defer bufferpool.Put(pathBuffer)
-		err = pathTmpl.Execute(pathBuffer, map[string]any{
-			"BlogPath": a.getRelativePath(p.Blog, ""),
-			"Year":     published.Year(),
-			"Month":    int(published.Month()),
-			"Day":      published.Day(),
-			"Slug":     p.Slug,
-			"Section":  p.Section,
-		})
-		if err != nil {
-			return errors.New("failed to execute location template")
+			return err
 		}
-		p.Path = pathBuffer.String()
+		p.Path = generated
 	}
 	if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
 		return errors.New("wrong path")
@@ -161,6 +147,9 @@ func (a *goBlog) replacePost(p *post, oldPath string, oldStatus postStatus, oldV
 	return a.createOrReplacePost(p, &postCreationOptions{new: false, oldPath: oldPath, oldStatus: oldStatus, oldVisibility: oldVisibility})
 }
 
+// Post parameter with old paths that redirect to the post
+const postAliasesParameter = "aliases"
+
 type postCreationOptions struct {
 	new           bool
 	oldPath       string
@@ -170,13 +159,55 @@ type postCreationOptions struct {
 
 func (a *goBlog) createOrReplacePost(p *post, o *postCreationOptions) error {
 	// Check post
+	generatedPath := p.Path == ""
 	if err := a.checkPost(p, o.new); err != nil {
 		return err
 	}
+	// Generated paths of new posts mustn't collide with existing posts
+	if o.new && generatedPath {
+		p.Path = a.uniquePostPath(p.Path)
+	}
+	// Keep the old path as alias, so it redirects to the new one
+	if !o.new && o.oldPath != "" && o.oldPath != p.Path {
+		p.Parameters[postAliasesParameter] = append(p.Parameters[postAliasesParameter], o.oldPath)
+		a.apKeepIdOnPathChange(p, o.oldPath, o.oldStatus, o.oldVisibility)
+	}
+	if aliases, ok := p.Parameters[postAliasesParameter]; ok {
+		// The post's own path can't be an alias
+		p.Parameters[postAliasesParameter] = lo.Without(lo.Uniq(aliases), p.Path)
+	}
+	if err := a.checkPostShortCode(p, o.oldPath); err != nil {
+		return err
+	}
+	// Series of the previous version, its parts have to be purged from the cache
+	oldSeries := ""
+	if !o.new {
+		oldSeries = a.db.getPostSeries(o.oldPath)
+	}
+	// Render the previous version of a public post as snapshot
+	var snapshot []byte
+	var snapshotDate string
+	if !o.new && a.snapshotsEnabled() && o.oldStatus == statusPublished && (o.oldVisibility == visibilityPublic || o.oldVisibility == visibilityUnlisted) {
+		if op, err := a.getPost(o.oldPath); err == nil {
+			snapshotDate = defaultIfEmpty(op.Updated, op.Published)
+			snapshot, err = a.renderPostSnapshot(op)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	// Save to db
 	if err := a.db.savePost(p, o); err != nil {
 		return err
 	}
+	if snapshot != nil {
+		if err := a.db.savePostSnapshot(p.Path, snapshotDate, snapshot); err != nil {
+			return err
+		}
+	}
+	if err := a.updatePostShortCode(p); err != nil {
+		return err
+	}
 	// Reload post from database
 	p, err := a.getPost(p.Path)
 	if err != nil {
@@ -192,7 +223,7 @@ func (a *goBlog) createOrReplacePost(p *post, o *postCreationOptions) error {
 		}
 	}
 	// Purge cache
-	a.cache.purge()
+	a.cache.purgePost(append([]string{p.Path, o.oldPath}, a.db.getSeriesPostPaths(oldSeries, p.firstParameter(seriesParameter))...)...)
 	a.deleteReactionsCache(p.Path)
 	return nil
 }
@@ -215,13 +246,13 @@ func (db *database) savePost(p *post, o *postCreationOptions) error {
 	// Update or create post
 	if o.new {
 		// New post, create it
-		sqlBuilder.WriteString("insert into posts (path, content, published, updated, blog, section, status, visibility, priority) values (?, ?, ?, ?, ?, ?, ?, ?, ?);")
-		sqlArgs = append(sqlArgs, p.Path, p.Content, toUTCSafe(p.Published), toUTCSafe(p.Updated), p.Blog, p.Section, p.Status, p.Visibility, p.Priority)
+		sqlBuilder.WriteString("insert into posts (path, content, published, updated, blog, section, status, visibility, priority, owner) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);")
+		sqlArgs = append(sqlArgs, p.Path, p.Content, toUTCSafe(p.Published), toUTCSafe(p.Updated), p.Blog, p.Section, p.Status, p.Visibility, p.Priority, p.Owner)
 	} else {
 		// Delete post parameters
 		sqlBuilder.WriteString("delete from post_parameters where path = ?;")
 		sqlArgs = append(sqlArgs, o.oldPath)
-		// Update old post
+		// Update old post, the owner doesn't change
 		sqlBuilder.WriteString("update posts set path = ?, content = ?, published = ?, updated = ?, blog = ?, section = ?, status = ?, visibility = ?, priority = ? where path = ?;")
 		sqlArgs = append(sqlArgs, p.Path, p.Content, toUTCSafe(p.Published), toUTCSafe(p.Updated), p.Blog, p.Section, p.Status, p.Visibility, p.Priority, o.oldPath)
 	}
@@ -242,7 +273,7 @@ func (db *database) savePost(p *post, o *postCreationOptions) error {
 		return err
 	}
 	// Update FTS index
-	db.rebuildFTSIndex()
+	_ = db.rebuildFTSIndex()
 	return nil
 }
 
@@ -268,9 +299,9 @@ func (a *goBlog) deletePost(path string) error {
 			return err
 		}
 		// Rebuild FTS index
-		a.db.rebuildFTSIndex()
+		_ = a.db.rebuildFTSIndex()
 		// Purge cache
-		a.cache.purge()
+		a.cache.purgePost(append([]string{p.Path}, a.db.getSeriesPostPaths(p.firstParameter(seriesParameter))...)...)
 		a.deleteReactionsCache(p.Path)
 	} else {
 		// Update post status
@@ -289,9 +320,9 @@ func (a *goBlog) deletePost(path string) error {
 			return err
 		}
 		// Rebuild FTS index
-		a.db.rebuildFTSIndex()
+		_ = a.db.rebuildFTSIndex()
 		// Purge cache
-		a.cache.purge()
+		a.cache.purgePost(append([]string{p.Path}, a.db.getSeriesPostPaths(p.firstParameter(seriesParameter))...)...)
 		// Trigger hooks
 		a.postDeleteHooks(p)
 	}
@@ -322,9 +353,9 @@ func (a *goBlog) undeletePost(path string) error {
 		return err
 	}
 	// Rebuild FTS index
-	a.db.rebuildFTSIndex()
+	_ = a.db.rebuildFTSIndex()
 	// Purge cache
-	a.cache.purge()
+	a.cache.purgePost(append([]string{p.Path}, a.db.getSeriesPostPaths(p.firstParameter(seriesParameter))...)...)
 	// Trigger hooks
 	a.postUndeleteHooks(p)
 	return nil
@@ -358,10 +389,18 @@ func (db *database) replacePostParam(path, param string, values []string) error
 		return err
 	}
 	// Update FTS index
-	db.rebuildFTSIndex()
+	_ = db.rebuildFTSIndex()
 	return nil
 }
 
+type postsSort string
+
+const (
+	postsSortNewest  postsSort = "newest"
+	postsSortOldest  postsSort = "oldest"
+	postsSortUpdated postsSort = "updated"
+)
+
 type postsRequestConfig struct {
 	search                                      string
 	blog                                        string
@@ -379,12 +418,16 @@ type postsRequestConfig struct {
 	excludeParameter                            string // exclude posts that have a certain parameter (with non-empty value)
 	excludeParameterValue                       string // ... with exactly this value
 	publishedYear, publishedMonth, publishedDay int
-	publishedBefore                             time.Time
+	publishedBefore, publishedAfter             time.Time
+	updatedMin                                  time.Time // only posts published or updated since
 	randomOrder                                 bool
 	priorityOrder                               bool
+	sort                                        postsSort
 	withoutParameters                           bool
 	withOnlyParameters                          []string
 	withoutRenderedTitle                        bool
+	withinVisibilityWindow                      bool   // exclude posts outside their visibility windows
+	lang, defaultLang                           string // only posts in this language, posts without language parameter are in the default language
 }
 
 func buildPostsQuery(c *postsRequestConfig, selection string) (query string, args []any) {
@@ -467,6 +510,14 @@ func buildPostsQuery(c *postsRequestConfig, selection string) (query string, arg
 			args = append(args, sql.Named("param", c.excludeParameter))
 		}
 	}
+	if c.lang != "" {
+		if strings.EqualFold(c.lang, c.defaultLang) {
+			queryBuilder.WriteString(" and path not in (select path from post_parameters where parameter = @langparam and length(coalesce(value, '')) > 0 and lowerx(value) != lowerx(@lang))")
+		} else {
+			queryBuilder.WriteString(" and path in (select path from post_parameters where parameter = @langparam and lowerx(value) = lowerx(@lang))")
+		}
+		args = append(args, sql.Named("langparam", postLangParameter), sql.Named("lang", c.lang))
+	}
 	if c.taxonomy != nil && len(c.taxonomyValue) > 0 {
 		queryBuilder.WriteString(" and path in (select path from post_parameters where parameter = @taxname and lowerx(value) = lowerx(@taxval))")
 		args = append(args, sql.Named("taxname", c.taxonomy.Name), sql.Named("taxval", c.taxonomyValue))
@@ -500,14 +551,34 @@ func buildPostsQuery(c *postsRequestConfig, selection string) (query string, arg
 		queryBuilder.WriteString(" and toutc(published) < @publishedbefore")
 		args = append(args, sql.Named("publishedbefore", c.publishedBefore.UTC().Format(time.RFC3339)))
 	}
+	if !c.publishedAfter.IsZero() {
+		queryBuilder.WriteString(" and toutc(published) >= @publishedafter")
+		args = append(args, sql.Named("publishedafter", c.publishedAfter.UTC().Format(time.RFC3339)))
+	}
+	if !c.updatedMin.IsZero() {
+		queryBuilder.WriteString(" and coalesce(nullif(toutc(updated), ''), toutc(published)) >= @updatedmin")
+		args = append(args, sql.Named("updatedmin", c.updatedMin.UTC().Format(time.RFC3339)))
+	}
+	if c.withinVisibilityWindow {
+		queryBuilder.WriteString(visibilityWindowsQuery)
+		args = append(args, visibilityWindowsQueryArgs(time.Now())...)
+	}
 	// Order
 	queryBuilder.WriteString(" order by ")
 	if c.randomOrder {
 		queryBuilder.WriteString("random()")
-	} else if c.priorityOrder {
-		queryBuilder.WriteString("priority desc, published desc")
 	} else {
-		queryBuilder.WriteString("published desc")
+		if c.priorityOrder {
+			queryBuilder.WriteString("priority desc, ")
+		}
+		switch c.sort {
+		case postsSortOldest:
+			queryBuilder.WriteString("published asc")
+		case postsSortUpdated:
+			queryBuilder.WriteString("coalesce(nullif(toutc(updated), ''), toutc(published)) desc")
+		default:
+			queryBuilder.WriteString("published desc")
+		}
 	}
 	// Limit & Offset
 	if c.limit != 0 || c.offset != 0 {
@@ -582,16 +653,16 @@ func (d *database) loadPostParameters(posts []*post, parameters ...string) (err
 
 func (a *goBlog) getPosts(config *postsRequestConfig) (posts []*post, err error) {
 	// Query posts
-	query, queryParams := buildPostsQuery(config, "path, coalesce(content, ''), coalesce(published, ''), coalesce(updated, ''), blog, coalesce(section, ''), status, visibility, priority")
+	query, queryParams := buildPostsQuery(config, "path, coalesce(content, ''), coalesce(published, ''), coalesce(updated, ''), blog, coalesce(section, ''), status, visibility, priority, owner")
 	rows, err := a.db.Query(query, queryParams...)
 	if err != nil {
 		return nil, err
 	}
 	// Prepare row scanning
-	var path, content, published, updated, blog, section, status, visibility string
+	var path, content, published, updated, blog, section, status, visibility, owner string
 	var priority int
 	for rows.Next() {
-		if err = rows.Scan(&path, &content, &published, &updated, &blog, &section, &status, &visibility, &priority); err != nil {
+		if err = rows.Scan(&path, &content, &published, &updated, &blog, &section, &status, &visibility, &priority, &owner); err != nil {
 			return nil, err
 		}
 		// Create new post, fill and add to list
@@ -605,6 +676,7 @@ func (a *goBlog) getPosts(config *postsRequestConfig) (posts []*post, err error)
 			Status:     postStatus(status),
 			Visibility: postVisibility(visibility),
 			Priority:   priority,
+			Owner:      owner,
 		}
 		posts = append(posts, p)
 	}
@@ -648,12 +720,13 @@ func (d *database) countPosts(config *postsRequestConfig) (count int, err error)
 func (a *goBlog) getRandomPostPath(blog string) (path string, err error) {
 	sections := lo.Keys(a.cfg.Blogs[blog].Sections)
 	query, params := buildPostsQuery(&postsRequestConfig{
-		randomOrder: true,
-		limit:       1,
-		blog:        blog,
-		sections:    sections,
-		visibility:  []postVisibility{visibilityPublic},
-		status:      []postStatus{statusPublished},
+		randomOrder:            true,
+		limit:                  1,
+		blog:                   blog,
+		sections:               sections,
+		visibility:             []postVisibility{visibilityPublic},
+		status:                 []postStatus{statusPublished},
+		withinVisibilityWindow: true,
 	}, "path")
 	row, err := a.db.QueryRow(query, params...)
 	if err != nil {
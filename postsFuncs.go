@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
 	"time"
 
@@ -14,11 +15,14 @@ import (
 )
 
 func (a *goBlog) fullPostURL(p *post) string {
+	if cd := a.customDomainForPost(p); cd != nil {
+		return cd.getFullAddress(p.Path)
+	}
 	return a.getFullAddress(p.Path)
 }
 
 func (a *goBlog) shortPostURL(p *post) string {
-	s, err := a.db.shortenPath(p.Path)
+	s, err := a.shortenPath(p.Path)
 	if err != nil {
 		return ""
 	}
@@ -68,9 +72,20 @@ func (a *goBlog) postHtmlToWriter(w io.Writer, o *postHtmlOptions) {
 		a.renderPostReplyContext(hb, o.p)
 	}
 	a.renderPostLikeContext(hb, o.p)
+	a.renderPostRepostContext(hb, o.p)
 	// Render markdown
 	hb.WriteElementOpen("div", "class", "e-content")
-	_ = a.renderMarkdownToWriter(w, o.p.Content, o.absolute)
+	if a.linkArchiveEnabled() && len(o.p.Parameters[linkArchiveParameter]) > 0 {
+		// Render to a buffer first to add the archived versions of broken links
+		buf := bufferpool.Get()
+		if meta, err := a.renderMarkdownWithMeta(buf, a.postContentWithPageData(o.p), o.absolute); err == nil {
+			o.p.renderMeta = meta
+		}
+		_, _ = w.Write(a.linkArchiveFallback(buf.Bytes(), o.p))
+		bufferpool.Put(buf)
+	} else if meta, err := a.renderMarkdownWithMeta(w, a.postContentWithPageData(o.p), o.absolute); err == nil {
+		o.p.renderMeta = meta
+	}
 	hb.WriteElementClose("div")
 	// Add bookmark links to the bottom
 	for _, l := range o.p.Parameters[a.cfg.Micropub.BookmarkParam] {
@@ -117,7 +132,7 @@ func (a *goBlog) postSummary(p *post) (summary string) {
 	if summary != "" {
 		return
 	}
-	splitted := strings.Split(p.Content, summaryDivider)
+	splitted := strings.Split(a.postContentWithPageData(p), summaryDivider)
 	hasDivider := len(splitted) > 1
 	markdown := splitted[0]
 	summary = a.renderTextSafe(markdown)
@@ -132,14 +147,32 @@ func (a *goBlog) fallbackTitle(p *post) string {
 	return truncateStringWithEllipsis(a.postSummary(p), 30)
 }
 
+// Posts in another language than their blog have the language code as parameter
+const postLangParameter = "lang"
+
+var langCodeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{1,8})*$`)
+
+func (a *goBlog) postLang(p *post) string {
+	if lang := p.firstParameter(postLangParameter); langCodeRegex.MatchString(lang) {
+		return lang
+	}
+	if bc, ok := a.cfg.Blogs[p.Blog]; ok {
+		return bc.Lang
+	}
+	return ""
+}
+
 func (a *goBlog) postTranslations(p *post) []*post {
 	translationkey := p.firstParameter("translationkey")
 	if translationkey == "" {
 		return nil
 	}
+	// Only link translations that visitors can access by URL
 	posts, err := a.getPosts(&postsRequestConfig{
 		parameter:      "translationkey",
 		parameterValue: translationkey,
+		status:         []postStatus{statusPublished},
+		visibility:     []postVisibility{visibilityPublic, visibilityUnlisted},
 	})
 	if err != nil || len(posts) == 0 {
 		return nil
@@ -185,20 +218,22 @@ func (a *goBlog) postToMfItem(p *post) *microformatItem {
 	return &microformatItem{
 		Type: []string{"h-entry"},
 		Properties: &microformatProperties{
-			Name:       p.Parameters["title"],
-			Published:  []string{p.Published},
-			Updated:    []string{p.Updated},
-			PostStatus: []string{mfStatus},
-			Visibility: []string{mfVisibility},
-			Category:   p.Parameters[a.cfg.Micropub.CategoryParam],
-			Content:    []string{p.contentWithParams()},
-			URL:        []string{a.fullPostURL(p)},
-			InReplyTo:  p.Parameters[a.cfg.Micropub.ReplyParam],
-			LikeOf:     p.Parameters[a.cfg.Micropub.LikeParam],
-			BookmarkOf: p.Parameters[a.cfg.Micropub.BookmarkParam],
-			MpSlug:     []string{p.Slug},
-			Audio:      p.Parameters[a.cfg.Micropub.AudioParam],
-			MpChannel:  []string{p.getChannel()},
+			Name:        p.Parameters["title"],
+			Published:   []string{p.Published},
+			Updated:     []string{p.Updated},
+			PostStatus:  []string{mfStatus},
+			Visibility:  []string{mfVisibility},
+			Category:    p.Parameters[a.cfg.Micropub.CategoryParam],
+			Content:     []string{p.contentWithParams()},
+			URL:         []string{a.fullPostURL(p)},
+			InReplyTo:   p.Parameters[a.cfg.Micropub.ReplyParam],
+			LikeOf:      p.Parameters[a.cfg.Micropub.LikeParam],
+			RepostOf:    p.Parameters[a.cfg.Micropub.RepostParam],
+			BookmarkOf:  p.Parameters[a.cfg.Micropub.BookmarkParam],
+			MpSlug:      []string{p.Slug},
+			Audio:       p.Parameters[a.cfg.Micropub.AudioParam],
+			MpChannel:   []string{p.getChannel()},
+			Syndication: a.syndicationLinks(p),
 			// TODO: Photos
 		},
 	}
@@ -218,8 +253,7 @@ func (a *goBlog) geoURIs(p *post) []*gogeouri.Geo {
 		if loc == "" {
 			continue
 		}
-		g, _ := gogeouri.Parse(loc)
-		if g != nil {
+		if g := parseGeoLocation(loc); g != nil {
 			res = append(res, g)
 		}
 	}
@@ -250,6 +284,18 @@ func (a *goBlog) likeContext(p *post) string {
 	return p.firstParameter(a.cfg.Micropub.LikeContextParam)
 }
 
+func (a *goBlog) repostLink(p *post) string {
+	return p.firstParameter(a.cfg.Micropub.RepostParam)
+}
+
+func (a *goBlog) repostTitle(p *post) string {
+	return p.firstParameter(a.cfg.Micropub.RepostTitleParam)
+}
+
+func (a *goBlog) repostContext(p *post) string {
+	return p.firstParameter(a.cfg.Micropub.RepostContextParam)
+}
+
 func (a *goBlog) photoLinks(p *post) []string {
 	return p.Parameters[a.cfg.Micropub.PhotoParam]
 }
@@ -329,6 +375,24 @@ func (a *goBlog) addLikeTitleAndContext(p *post) {
 	}
 }
 
+func (a *goBlog) addRepostTitleAndContext(p *post) {
+	if repostLink := p.firstParameter(a.cfg.Micropub.RepostParam); repostLink != "" {
+		addTitle := p.firstParameter(a.cfg.Micropub.RepostTitleParam) == "" && a.getBlogFromPost(p).addRepostTitle
+		addContext := p.firstParameter(a.cfg.Micropub.RepostContextParam) == "" && a.getBlogFromPost(p).addRepostContext
+		if !addTitle && !addContext {
+			return
+		}
+		if mf, err := a.parseMicroformats(repostLink, true); err == nil {
+			if addTitle && mf.Title != "" {
+				p.addParameter(a.cfg.Micropub.RepostTitleParam, mf.Title)
+			}
+			if addContext && mf.Content != "" {
+				p.addParameter(a.cfg.Micropub.RepostContextParam, mf.Content)
+			}
+		}
+	}
+}
+
 // Public because of rendering
 
 func (p *post) Title() string {
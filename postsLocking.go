@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+)
+
+// Optimistic concurrency for post updates: clients get the version of a post (ETag) with the
+// Micropub source query or in the editor and send it back with If-Match (or the time of their
+// copy with If-Unmodified-Since). If the post was changed in the meantime, the update fails
+// instead of overwriting the other changes.
+
+const errPostModified = "the post was changed in the meantime, reload it and apply your changes again"
+
+// Version of the post, changes with every modification of the content, parameters or metadata
+func (p *post) etag() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(p.contentWithParams())))
+}
+
+// Time of the last modification, zero if unknown
+func (p *post) lastModified() time.Time {
+	for _, d := range []string{p.Updated, p.Published} {
+		if d == "" {
+			continue
+		}
+		if t, err := dateparse.ParseLocal(d); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func setPostVersionHeaders(w http.ResponseWriter, p *post) {
+	w.Header().Set("ETag", "\""+p.etag()+"\"")
+	if lm := p.lastModified(); !lm.IsZero() {
+		w.Header().Set("Last-Modified", lm.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Check the If-Match and If-Unmodified-Since headers of an update request against the current post
+func checkPostPreconditions(r *http.Request, p *post) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		current := p.etag()
+		for _, tag := range strings.Split(ifMatch, ",") {
+			tag = strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "W/"), `"`)
+			if tag == "*" || tag == current {
+				return true
+			}
+		}
+		return false
+	}
+	if ifUnmodifiedSince := r.Header.Get("If-Unmodified-Since"); ifUnmodifiedSince != "" {
+		since, err := http.ParseTime(ifUnmodifiedSince)
+		lm := p.lastModified()
+		if err != nil || lm.IsZero() {
+			// Can't compare, ignore the header
+			return true
+		}
+		return !lm.Truncate(time.Second).After(since)
+	}
+	return true
+}
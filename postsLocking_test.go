@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_postLocking(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	require.NoError(t, app.createPost(&post{
+		Path:      "/lockpost",
+		Section:   "posts",
+		Status:    statusPublished,
+		Published: "2020-10-15T10:00:00Z",
+		Content:   "Original",
+	}))
+
+	postURL := app.getFullAddress("/lockpost")
+
+	update := func(content string, headers map[string]string) *http.Response {
+		body := `{"action":"update","url":"` + postURL + `","replace":{"content":["` + content + `"]}}`
+		req := httptest.NewRequest(http.MethodPost, micropubPath, strings.NewReader(body))
+		req.Header.Set(contentType, "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), blogKey, app.cfg.DefaultBlog))
+		rec := httptest.NewRecorder()
+		addAllScopes(http.HandlerFunc(app.serveMicropubPost)).ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	content := func() string {
+		p, err := app.getPost("/lockpost")
+		require.NoError(t, err)
+		return p.Content
+	}
+
+	// Get the current version with the source query
+	req := httptest.NewRequest(http.MethodGet, micropubPath+"?q=source&url="+url.QueryEscape(postURL), nil)
+	rec := httptest.NewRecorder()
+	addAllScopes(http.HandlerFunc(app.serveMicropubQuery)).ServeHTTP(rec, req)
+	res := rec.Result()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.True(t, strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`), "ETag must be a quoted string")
+	require.NotEmpty(t, res.Header.Get("Last-Modified"))
+
+	// Update with the current version
+	res = update("First update", map[string]string{"If-Match": etag})
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "First update", content())
+	newETag := res.Header.Get("ETag")
+	assert.NotEqual(t, etag, newETag)
+
+	// Update with an old version fails
+	res = update("Second update", map[string]string{"If-Match": strings.Trim(etag, `"`)})
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+	assert.Equal(t, "First update", content())
+
+	// Update with a time before the last modification fails
+	res = update("Second update", map[string]string{"If-Unmodified-Since": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+	assert.Equal(t, "First update", content())
+
+	// Update with a time after the last modification
+	res = update("Second update", map[string]string{"If-Unmodified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "Second update", content())
+
+	// Update without preconditions
+	res = update("Third update", nil)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "Third update", content())
+
+	// Editor with an old version fails
+	form := url.Values{
+		"editoraction": {"updatepost"},
+		"url":          {postURL},
+		"content":      {"Editor update"},
+		"etag":         {newETag},
+	}
+	req = httptest.NewRequest(http.MethodPost, editorPath, strings.NewReader(form.Encode()))
+	req.Header.Set(contentType, "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), blogKey, app.cfg.DefaultBlog))
+	rec = httptest.NewRecorder()
+	app.serveEditorPost(rec, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, "Third update", content())
+
+	// Editor with the current version
+	p, err := app.getPost("/lockpost")
+	require.NoError(t, err)
+	form.Set("etag", p.etag())
+	form.Set("content", "---\npath: /lockpost\nsection: posts\n---\nEditor update")
+	req = httptest.NewRequest(http.MethodPost, editorPath, strings.NewReader(form.Encode()))
+	req.Header.Set(contentType, "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), blogKey, app.cfg.DefaultBlog))
+	rec = httptest.NewRecorder()
+	app.serveEditorPost(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "Editor update", content())
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"time"
 )
@@ -18,7 +19,7 @@ func (a *goBlog) startPostsScheduler() {
 			}
 		}
 	}()
-	a.shutdown.Add(func() {
+	a.addShutdownBeforeDatabase(func() {
 		ticker.Stop()
 		done <- struct{}{}
 		log.Println("Posts scheduler stopped")
@@ -39,6 +40,7 @@ func (a *goBlog) checkScheduledPosts() {
 		err := a.replacePost(post, post.Path, statusScheduled, post.Visibility)
 		if err != nil {
 			log.Println("Error publishing scheduled post:", err)
+			a.sendNotification(notificationTypeError, fmt.Sprintf("Failed to publish scheduled post %s: %s", post.Path, err.Error()))
 			continue
 		}
 		log.Println("Published scheduled post:", post.Path)
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Visibility windows hide published posts from visitors depending on the current time:
+// - expires: date after which the post is hidden
+// - season: recurring yearly window in the format "MM-DD/MM-DD" (e.g. "12-01/02-28"), the post is hidden outside of it
+// - latestonly: only show the latest post with this parameter in the same section (e.g. for a "now" page)
+// They are evaluated at query time, so nothing has to be changed when a window opens or closes.
+
+const (
+	expiresParameter    = "expires"
+	seasonParameter     = "season"
+	latestOnlyParameter = "latestonly"
+)
+
+var seasonRegex = regexp.MustCompile(`^\d{2}-\d{2}/\d{2}-\d{2}$`)
+
+const visibilityWindowsQuery = `
+ and path not in (
+	select path from post_parameters where parameter = @vwexpires and toutc(coalesce(value, '')) != '' and toutc(value) <= @vwnow
+ ) and path not in (
+	select path from post_parameters where parameter = @vwseason and length(coalesce(value, '')) = 11 and not (
+		(substr(value, 1, 5) <= substr(value, 7, 5) and @vwtoday between substr(value, 1, 5) and substr(value, 7, 5))
+		or (substr(value, 1, 5) > substr(value, 7, 5) and (@vwtoday >= substr(value, 1, 5) or @vwtoday <= substr(value, 7, 5)))
+	)
+ ) and path not in (
+	select p1.path from posts p1, post_parameters pp1 where p1.path = pp1.path and pp1.parameter = @vwlatestonly and pp1.value = 'true' and exists (
+		select 1 from posts p2, post_parameters pp2 where p2.path = pp2.path and pp2.parameter = @vwlatestonly and pp2.value = 'true'
+		and p2.blog = p1.blog and p2.section = p1.section and p2.status = @vwstatus and p2.visibility = @vwvisibility
+		and toutc(p2.published) > toutc(p1.published)
+	)
+ )`
+
+func visibilityWindowsQueryArgs(now time.Time) []any {
+	return []any{
+		sql.Named("vwexpires", expiresParameter),
+		sql.Named("vwnow", now.UTC().Format(time.RFC3339)),
+		sql.Named("vwseason", seasonParameter),
+		sql.Named("vwtoday", now.Local().Format("01-02")),
+		sql.Named("vwlatestonly", latestOnlyParameter),
+		sql.Named("vwstatus", statusPublished),
+		sql.Named("vwvisibility", visibilityPublic),
+	}
+}
+
+func checkVisibilityWindowParameters(p *post) error {
+	if season := p.firstParameter(seasonParameter); season != "" && !seasonRegex.MatchString(season) {
+		return errors.New("invalid season, use the format MM-DD/MM-DD")
+	}
+	if expires := p.firstParameter(expiresParameter); expires != "" {
+		if _, err := toUTC(expires); err != nil {
+			return errors.New("invalid expiration date: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (p *post) hasVisibilityWindow() bool {
+	return p.firstParameter(expiresParameter) != "" || p.firstParameter(seasonParameter) != "" || p.firstParameter(latestOnlyParameter) == "true"
+}
+
+func (a *goBlog) isInVisibilityWindow(p *post) (bool, error) {
+	count, err := a.db.countPosts(&postsRequestConfig{
+		path:                   p.Path,
+		withinVisibilityWindow: true,
+	})
+	return count > 0, err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_postsVisibilityWindows(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	_ = app.initCache()
+	app.initMarkdown()
+
+	now := time.Now()
+	day := func(d int) string { return now.AddDate(0, 0, d).Format("01-02") }
+
+	create := func(path, published string, params map[string][]string) {
+		err := app.createPost(&post{
+			Path:       path,
+			Section:    "posts",
+			Published:  published,
+			Content:    "Test",
+			Parameters: params,
+		})
+		require.NoError(t, err)
+	}
+	create("/normal", "2023-01-01", nil)
+	create("/expired", "2023-01-01", map[string][]string{expiresParameter: {now.Add(-time.Hour).Format(time.RFC3339)}})
+	create("/notexpired", "2023-01-01", map[string][]string{expiresParameter: {now.Add(time.Hour).Format(time.RFC3339)}})
+	create("/inseason", "2023-01-01", map[string][]string{seasonParameter: {day(-1) + "/" + day(1)}})
+	create("/offseason", "2023-01-01", map[string][]string{seasonParameter: {day(2) + "/" + day(3)}})
+	create("/now1", "2023-01-01", map[string][]string{latestOnlyParameter: {"true"}})
+	create("/now2", "2023-02-01", map[string][]string{latestOnlyParameter: {"true"}})
+
+	posts, err := app.getPosts(&postsRequestConfig{withinVisibilityWindow: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t,
+		[]string{"/normal", "/notexpired", "/inseason", "/now2"},
+		lo.Map(posts, func(p *post, _ int) string { return p.Path }),
+	)
+
+	// Without the filter all posts are returned
+	count, err := app.db.countPosts(&postsRequestConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 7, count)
+
+	p, err := app.getPost("/offseason")
+	require.NoError(t, err)
+	assert.True(t, p.hasVisibilityWindow())
+	visible, err := app.isInVisibilityWindow(p)
+	require.NoError(t, err)
+	assert.False(t, visible)
+
+	p, err = app.getPost("/normal")
+	require.NoError(t, err)
+	assert.False(t, p.hasVisibilityWindow())
+
+	// Invalid parameters
+	err = app.createPost(&post{Path: "/invalid", Content: "Test", Parameters: map[string][]string{seasonParameter: {"December"}}})
+	assert.Error(t, err)
+	err = app.createPost(&post{Path: "/invalid", Content: "Test", Parameters: map[string][]string{expiresParameter: {"tomorrow"}}})
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/carlmjohnson/requests"
+	ap "github.com/go-ap/activitypub"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -90,6 +91,36 @@ func Test_serveDate(t *testing.T) {
 	assert.Contains(t, resString, "Test Post")
 	assert.Contains(t, resString, "<h1 class=p-name>XXXX-XX-15</h1>")
 
+	err = requests.
+		URL("http://localhost:8080/x/10/15").
+		CheckStatus(http.StatusOK).
+		ToString(&resString).
+		Client(client).Fetch(context.Background())
+	require.NoError(t, err)
+
+	assert.Contains(t, resString, "Test Post")
+	assert.Contains(t, resString, "<h1 class=p-name>XXXX-10-15</h1>")
+
+	err = requests.
+		URL("http://localhost:8080/x/x/15-10").
+		CheckStatus(http.StatusOK).
+		ToString(&resString).
+		Client(client).Fetch(context.Background())
+	require.NoError(t, err)
+
+	assert.Contains(t, resString, "Test Post")
+	assert.Contains(t, resString, "<h1 class=p-name>XXXX-10-15</h1>")
+	assert.Contains(t, resString, "<link rel=canonical href=http://localhost:8080/x/10/15>")
+
+	err = requests.
+		URL("http://localhost:8080/x/x/16-10").
+		CheckStatus(http.StatusOK).
+		ToString(&resString).
+		Client(client).Fetch(context.Background())
+	require.NoError(t, err)
+
+	assert.NotContains(t, resString, "Test Post")
+
 	err = requests.
 		URL("http://localhost:8080/x").
 		CheckStatus(http.StatusNotFound).
@@ -199,3 +230,144 @@ func Test_servePost(t *testing.T) {
 	assert.NotContains(t, resString, "<h1 class=p-name>Test Post</h1>")
 
 }
+
+func Test_postVisibility(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.cfg.Blogs[app.cfg.DefaultBlog].Search = &configSearch{Enabled: true}
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/public", Visibility: visibilityPublic, Parameters: map[string][]string{"title": {"Public Post"}, "translationkey": {"key"}}},
+		{Path: "/unlisted", Visibility: visibilityUnlisted, Parameters: map[string][]string{"title": {"Unlisted Post"}, "translationkey": {"key"}}},
+		{Path: "/private", Visibility: visibilityPrivate, Parameters: map[string][]string{"title": {"Private Post"}, "translationkey": {"key"}}},
+	} {
+		p.Section = "posts"
+		p.Status = statusPublished
+		p.Published = "2020-10-15T10:00:00Z"
+		p.Content = "Test Content"
+		require.NoError(t, app.createPost(p))
+	}
+
+	client := newHandlerClient(app.d)
+
+	get := func(path string, loggedIn bool) (string, http.Header, int) {
+		var body string
+		var status int
+		header := http.Header{}
+		rb := requests.URL("http://localhost:8080" + path).
+			ToString(&body).
+			CopyHeaders(header).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			Client(client)
+		if loggedIn {
+			rb.BasicAuth("test", "test")
+		}
+		require.NoError(t, rb.Fetch(context.Background()))
+		return body, header, status
+	}
+
+	// Unlisted posts are accessible by URL, but not indexed
+	body, header, status := get("/unlisted", false)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Unlisted Post")
+	assert.Equal(t, "noindex", header.Get("X-Robots-Tag"))
+
+	// Private posts require login
+	body, _, _ = get("/private", false)
+	assert.NotContains(t, body, "Private Post")
+	body, _, status = get("/private", true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Private Post")
+
+	// Only public posts are listed for visitors
+	for _, path := range []string{"/", "/.rss", "/sitemap-blog-posts.xml", "/search/" + searchEncode("Post")} {
+		body, _, _ = get(path, false)
+		assert.Contains(t, body, "/public", path)
+		assert.NotContains(t, body, "/unlisted", path)
+		assert.NotContains(t, body, "/private", path)
+	}
+
+	// Translations only link to posts accessible by URL
+	body, _, _ = get("/public", false)
+	assert.Contains(t, body, "/unlisted")
+	assert.NotContains(t, body, "/private")
+
+	// Unlisted posts aren't addressed to the public collection
+	p, err := app.getPost("/unlisted")
+	require.NoError(t, err)
+	note := app.toAPNote(p)
+	assert.False(t, note.To.Contains(ap.PublicNS))
+}
+
+func Test_postTranslations(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/english", Section: "posts", Parameters: map[string][]string{"title": {"English post"}, "translationkey": {"test"}}, Content: "Test"},
+		{Path: "/french", Section: "posts", Parameters: map[string][]string{"title": {"French post"}, "translationkey": {"test"}, postLangParameter: {"fr"}}, Content: "Test"},
+	} {
+		require.NoError(t, app.createPost(p))
+	}
+
+	client := newHandlerClient(app.d)
+	get := func(path string) string {
+		var body string
+		err := requests.URL("http://localhost:8080" + path).Client(client).
+			CheckStatus(http.StatusOK).
+			ToString(&body).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		return body
+	}
+
+	t.Run("Hreflang", func(t *testing.T) {
+		body := get("/english")
+		assert.Contains(t, body, "<link rel=alternate hreflang=en href=http://localhost:8080/english>")
+		assert.Contains(t, body, "<link rel=alternate hreflang=fr href=http://localhost:8080/french>")
+		assert.Contains(t, body, "<article>")
+
+		body = get("/french")
+		assert.Contains(t, body, "<article lang=fr>")
+	})
+
+	t.Run("Language filter", func(t *testing.T) {
+		body := get("/?lang=fr")
+		assert.Contains(t, body, "French post")
+		assert.NotContains(t, body, "English post")
+		assert.Contains(t, body, "/.rss?lang=fr")
+
+		body = get("/.rss?lang=en")
+		assert.Contains(t, body, "English post")
+		assert.NotContains(t, body, "French post")
+
+		body = get("/")
+		assert.Contains(t, body, "French post")
+		assert.Contains(t, body, "English post")
+	})
+}
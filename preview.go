@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Drafts and scheduled posts can be shared with signed, expiring preview links, which work without login
+
+const (
+	previewTokenParam   = "preview"
+	previewKeyCacheKey  = "preview_key"
+	previewLinkValidity = 7 * 24 * time.Hour
+)
+
+// Key to sign the preview tokens, persisted so the links survive restarts
+func (a *goBlog) getPreviewKey() ([]byte, error) {
+	a.previewKeyMutex.Lock()
+	defer a.previewKeyMutex.Unlock()
+	if a.previewKey != nil {
+		return a.previewKey, nil
+	}
+	key, err := a.db.retrievePersistentCache(previewKeyCacheKey)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		key = make([]byte, 32)
+		if _, err = rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err = a.db.cachePersistently(previewKeyCacheKey, key); err != nil {
+			return nil, err
+		}
+	}
+	a.previewKey = key
+	return key, nil
+}
+
+// Signature of the payload (a path or preview flags) and the expiry
+func (a *goBlog) previewSignature(payload string, expires int64) (string, error) {
+	key, err := a.getPreviewKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = fmt.Fprintf(mac, "%s\n%d", payload, expires)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Create a token with the format "expires.signature"
+func (a *goBlog) createPreviewToken(payload string, expires time.Time) (string, error) {
+	signature, err := a.previewSignature(payload, expires.Unix())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%s", expires.Unix(), signature), nil
+}
+
+// Check if the token is valid for the payload and not expired
+func (a *goBlog) checkPreviewToken(payload, token string) bool {
+	expiresString, signature, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresString, 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return false
+	}
+	expected, err := a.previewSignature(payload, expires)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func (a *goBlog) previewLink(p *post) (string, error) {
+	token, err := a.createPreviewToken(p.Path, time.Now().Add(previewLinkValidity))
+	if err != nil {
+		return "", err
+	}
+	return a.getFullAddress(p.Path) + "?" + previewTokenParam + "=" + url.QueryEscape(token), nil
+}
+
+// Serve a draft or scheduled post to a visitor with a valid preview link, not cached
+func (a *goBlog) servePreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(cacheControl, "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	a.servePost(w, r)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Signed query parameters to view pages as they would render with a preview theme or with drafts,
+// for example "?previewflags=drafts,theme:new&previewflagstoken=...". Such requests are never cached.
+
+const (
+	previewFlagsParam      = "previewflags"
+	previewFlagsTokenParam = "previewflagstoken"
+	previewFlagsPath       = "/previewflags"
+	previewFlagsValidity   = 24 * time.Hour
+
+	previewFlagsKey contextKey = "previewFlags"
+)
+
+type previewFlags struct {
+	theme  string
+	drafts bool
+}
+
+func parsePreviewFlags(s string) *previewFlags {
+	pf := &previewFlags{}
+	for _, flag := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(flag), ":")
+		switch name {
+		case "drafts":
+			pf.drafts = true
+		case "theme":
+			pf.theme = value
+		}
+	}
+	return pf
+}
+
+func (pf *previewFlags) String() string {
+	var flags []string
+	if pf.drafts {
+		flags = append(flags, "drafts")
+	}
+	if pf.theme != "" {
+		flags = append(flags, "theme:"+pf.theme)
+	}
+	return strings.Join(flags, ",")
+}
+
+func previewFlagsPayload(flags string) string {
+	return "flags:" + flags
+}
+
+// Add the preview flags to the context if they are signed
+func (a *goBlog) checkPreviewFlags(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flags := r.URL.Query().Get(previewFlagsParam)
+		if flags == "" || !a.checkPreviewToken(previewFlagsPayload(flags), r.URL.Query().Get(previewFlagsTokenParam)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(cacheControl, "no-store")
+		w.Header().Set("X-Robots-Tag", "noindex")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), previewFlagsKey, parsePreviewFlags(flags))))
+	})
+}
+
+func getPreviewFlags(r *http.Request) *previewFlags {
+	if r == nil {
+		return nil
+	}
+	pf, _ := r.Context().Value(previewFlagsKey).(*previewFlags)
+	return pf
+}
+
+// Create a signed link to the path with the preview flags from the query (logged in only)
+func (a *goBlog) servePreviewFlagsLink(w http.ResponseWriter, r *http.Request) {
+	pf := &previewFlags{
+		theme:  r.URL.Query().Get("theme"),
+		drafts: r.URL.Query().Get("drafts") == "1" || r.URL.Query().Get("drafts") == "true",
+	}
+	if pf.theme != "" {
+		if _, ok := a.cfg.PreviewThemes[pf.theme]; !ok {
+			a.serveError(w, r, "Unknown preview theme", http.StatusBadRequest)
+			return
+		}
+	}
+	path := r.URL.Query().Get("path")
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		path = "/"
+	}
+	flags := pf.String()
+	token, err := a.createPreviewToken(previewFlagsPayload(flags), time.Now().Add(previewFlagsValidity))
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, path+"?"+url.Values{previewFlagsParam: {flags}, previewFlagsTokenParam: {token}}.Encode(), http.StatusFound)
+}
+
+func previewThemeAssetName(theme, fileName string) string {
+	return "preview/" + theme + "/" + fileName
+}
+
+// Compile the files of the preview themes, they are only used with the theme preview flag
+func (a *goBlog) initPreviewThemeAssets() error {
+	for theme, dir := range a.cfg.PreviewThemes {
+		if err := a.compileAssetsFromFS(os.DirFS(dir), ".", previewThemeAssetName(theme, "")); err != nil {
+			return fmt.Errorf("failed to read preview theme %s: %w", theme, err)
+		}
+	}
+	return nil
+}
+
+// Replaces the paths of the assets with the ones of the preview theme in the rendered HTML,
+// returns nil if there is no preview theme
+func (a *goBlog) previewThemeReplacer(r *http.Request, bc *configBlog) *strings.Replacer {
+	pf := getPreviewFlags(r)
+	if pf == nil || pf.theme == "" {
+		return nil
+	}
+	prefix := previewThemeAssetName(pf.theme, "")
+	var oldnew []string
+	for name, compiled := range a.loadedAssets().fileNames {
+		fileName, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if current := a.blogAssetFileName(bc, fileName); current != "/" {
+			// Only files that replace an existing asset are referenced in the HTML
+			oldnew = append(oldnew, current, "/"+compiled)
+			if integrity := a.assetIntegrity(current); integrity != "" {
+				oldnew = append(oldnew, integrity, a.assetIntegrity("/"+compiled))
+			}
+		}
+	}
+	if len(oldnew) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(oldnew...)
+}
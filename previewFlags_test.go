@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_previewFlags(t *testing.T) {
+	themeDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(themeDir, "css"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(themeDir, "css", "styles.css"), []byte("body { color: green; }"), 0644))
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.PreviewThemes = map[string]string{"new": themeDir}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	require.NoError(t, app.initTemplateAssets())
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{Path: "/published", Section: "posts", Status: statusPublished, Content: "Published post"}))
+	require.NoError(t, app.createPost(&post{Path: "/draft", Section: "posts", Status: statusDraft, Content: "Draft post"}))
+
+	liveStyles := app.blogAssetFileName(app.cfg.Blogs["default"], "css/styles.css")
+	previewStyles := app.assetFileName(previewThemeAssetName("new", "css/styles.css"))
+	require.NotEqual(t, liveStyles, previewStyles)
+
+	client := newHandlerClient(app.d)
+	get := func(path string) (string, http.Header) {
+		var body string
+		var header http.Header
+		require.NoError(t, requests.URL("http://localhost:8080"+path).Client(client).
+			AddValidator(func(r *http.Response) error {
+				header = r.Header
+				return nil
+			}).
+			ToString(&body).Fetch(context.Background()))
+		return body, header
+	}
+
+	// Fill the cache with the public page
+	body, _ := get("/")
+	assert.Contains(t, body, liveStyles)
+	assert.NotContains(t, body, "Draft post")
+
+	// Create a signed link
+	noRedirectClient := newHandlerClient(app.d)
+	noRedirectClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	var location string
+	require.NoError(t, requests.URL("http://localhost:8080"+previewFlagsPath+"?theme=new&drafts=1&path=/").Client(noRedirectClient).
+		BasicAuth("test", "test").
+		AddValidator(func(r *http.Response) error {
+			assert.Equal(t, http.StatusFound, r.StatusCode)
+			location = r.Header.Get("Location")
+			return nil
+		}).
+		Fetch(context.Background()))
+	locationUrl, err := url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, "/", locationUrl.Path)
+	assert.Equal(t, "drafts,theme:new", locationUrl.Query().Get(previewFlagsParam))
+
+	// The preview uses the theme and shows drafts
+	body, header := get(location)
+	assert.Contains(t, body, previewStyles)
+	assert.NotContains(t, body, liveStyles)
+	assert.Contains(t, body, "Draft post")
+	assert.Equal(t, "no-store", header.Get("Cache-Control"))
+
+	// The cached public page is unchanged
+	body, _ = get("/")
+	assert.Contains(t, body, liveStyles)
+	assert.NotContains(t, body, "Draft post")
+
+	// Modified flags aren't accepted
+	query := locationUrl.Query()
+	query.Set(previewFlagsParam, "drafts")
+	body, header = get("/?" + query.Encode())
+	assert.Contains(t, body, liveStyles)
+	assert.NotContains(t, body, "Draft post")
+
+	// Unsigned flags don't bypass the cache
+	assert.Equal(t, "public,no-cache", header.Get("Cache-Control"))
+	assert.NotEmpty(t, header.Get("ETag"))
+
+	// Unknown preview themes can't be signed
+	require.NoError(t, requests.URL("http://localhost:8080"+previewFlagsPath+"?theme=unknown").Client(noRedirectClient).
+		BasicAuth("test", "test").
+		CheckStatus(http.StatusBadRequest).
+		Fetch(context.Background()))
+}
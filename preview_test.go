@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_previewLinks(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{
+		Path:    "/draft",
+		Section: "posts",
+		Status:  statusDraft,
+		Content: "Draft content",
+	}))
+	require.NoError(t, app.createPost(&post{
+		Path:    "/other",
+		Section: "posts",
+		Status:  statusDraft,
+		Content: "Other content",
+	}))
+
+	client := newHandlerClient(app.d)
+	get := func(path string, login bool) (int, string, http.Header) {
+		var body string
+		var header http.Header
+		var status int
+		rb := requests.URL("http://localhost:8080" + path).Client(client).
+			AddValidator(func(r *http.Response) error {
+				status, header = r.StatusCode, r.Header
+				return nil
+			}).
+			ToString(&body)
+		if login {
+			rb.BasicAuth("test", "test")
+		}
+		_ = rb.Fetch(context.Background())
+		return status, body, header
+	}
+
+	// Without token, the login form is shown
+	_, body, _ := get("/draft", false)
+	assert.NotContains(t, body, "Draft content")
+
+	// The logged in author sees the preview link
+	_, body, _ = get("/draft", true)
+	assert.Contains(t, body, "Draft content")
+	assert.Contains(t, body, "?preview=")
+
+	p, err := app.getPost("/draft")
+	require.NoError(t, err)
+	link, err := app.previewLink(p)
+	require.NoError(t, err)
+	linkUrl, err := url.Parse(link)
+	require.NoError(t, err)
+	token := linkUrl.Query().Get(previewTokenParam)
+
+	// The preview link works without login and isn't cached
+	status, body, header := get("/draft?preview="+url.QueryEscape(token), false)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Draft content")
+	assert.NotContains(t, body, "?preview=")
+	assert.Equal(t, "no-store", header.Get("Cache-Control"))
+	assert.Equal(t, "noindex", header.Get("X-Robots-Tag"))
+
+	// The token only works for this path
+	_, body, _ = get("/other?preview="+url.QueryEscape(token), false)
+	assert.NotContains(t, body, "Other content")
+
+	// Manipulated and expired tokens don't work
+	expires, _, _ := strings.Cut(token, ".")
+	_, body, _ = get("/draft?preview="+expires+".abc", false)
+	assert.NotContains(t, body, "Draft content")
+	expiredToken, err := app.createPreviewToken("/draft", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, body, _ = get("/draft?preview="+url.QueryEscape(expiredToken), false)
+	assert.NotContains(t, body, "Draft content")
+
+	// Tokens survive a restart
+	app.previewKey = nil
+	assert.True(t, app.checkPreviewToken("/draft", token))
+
+	// Not usable anymore after the draft got deleted
+	require.NoError(t, app.deletePost("/draft"))
+	_, body, _ = get("/draft?preview="+url.QueryEscape(token), false)
+	assert.NotContains(t, body, "Draft content")
+}
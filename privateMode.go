@@ -1,9 +1,19 @@
 package main
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/justinas/alice"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+const (
+	blogProtectionSession = "bp"
+	feedTokenQueryParam   = "token"
 )
 
 func (a *goBlog) isPrivate() bool {
@@ -17,6 +27,122 @@ func (a *goBlog) privateModeHandler(next http.Handler) http.Handler {
 			private.ServeHTTP(w, r)
 			return
 		}
+		if blog, bc, protected := a.getProtectedBlog(r); protected {
+			// Protected blogs shouldn't be indexed
+			w.Header().Set("X-Robots-Tag", "noindex")
+			if a.hasBlogAccess(r, blog, bc) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if bc.Protection.Passphrase == "" {
+				// No passphrase configured, only the logged in user has access
+				private.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set(cacheControl, "no-store,max-age=0")
+			a.renderWithStatusCode(w, r, http.StatusUnauthorized, a.renderBlogPassphrase, &renderData{
+				BlogString: blog,
+			})
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (bc *configBlog) isProtected() bool {
+	return bc != nil && bc.Protection != nil && bc.Protection.Enabled
+}
+
+// Protected blogs aren't shared with other services (like ActivityPub, IndexNow, Webmention or Nostr)
+func (a *goBlog) isProtectedBlog(blog string) bool {
+	return a.cfg.Blogs[blog].isProtected()
+}
+
+// Only check protection if the route explicitly belongs to a blog
+func (a *goBlog) getProtectedBlog(r *http.Request) (string, *configBlog, bool) {
+	blog, ok := r.Context().Value(blogKey).(string)
+	if !ok {
+		return "", nil, false
+	}
+	bc := a.cfg.Blogs[blog]
+	return blog, bc, bc.isProtected()
+}
+
+func (a *goBlog) hasBlogAccess(r *http.Request, blog string, bc *configBlog) bool {
+	// Logged in user has access to all blogs
+	if a.isLoggedIn(r) {
+		return true
+	}
+	// Database users have access to the blogs they have a role for
+	if u := a.currentUser(r); u != nil && u.role(blog) != userRoleNone {
+		return true
+	}
+	// Feeds are accessible with the feed token
+	if token := bc.Protection.FeedToken; token != "" && feedType(chi.URLParam(r, "feed")) != noFeed {
+		if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get(feedTokenQueryParam)), []byte(token)) == 1 {
+			return true
+		}
+	}
+	// Check passphrase session
+	if ses, err := a.loginSessions.Get(r, blogProtectionSession); err == nil && ses != nil {
+		if access, ok := ses.Values[blog].(bool); ok && access {
+			return true
+		}
+	}
+	return false
+}
+
+// Query string to append to feed URLs of protected blogs
+func (bc *configBlog) feedTokenQuery() string {
+	if !bc.isProtected() || bc.Protection.FeedToken == "" {
+		return ""
+	}
+	return "?" + feedTokenQueryParam + "=" + url.QueryEscape(bc.Protection.FeedToken)
+}
+
+// Middleware to check if the request is a passphrase submission for a protected blog
+func (a *goBlog) checkIsBlogPassphrase(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !a.checkBlogPassphrase(rw, r) {
+			next.ServeHTTP(rw, r)
+		}
+	})
+}
+
+// Checks the passphrase and returns true if it already served a response
+func (a *goBlog) checkBlogPassphrase(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	if !strings.Contains(r.Header.Get(contentType), contenttype.WWWForm) {
+		return false
+	}
+	if r.FormValue("loginaction") != "blogpassphrase" {
+		return false
+	}
+	blog := r.FormValue("blog")
+	bc, ok := a.cfg.Blogs[blog]
+	if !ok || !bc.isProtected() || bc.Protection.Passphrase == "" {
+		a.serveError(w, r, "Blog is not protected by a passphrase", http.StatusBadRequest)
+		return true
+	}
+	// Check passphrase
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("passphrase")), []byte(bc.Protection.Passphrase)) != 1 {
+		a.serveError(w, r, "Incorrect passphrase", http.StatusUnauthorized)
+		return true
+	}
+	// Cookie
+	ses, err := a.loginSessions.Get(r, blogProtectionSession)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	ses.Values[blog] = true
+	if err = a.loginSessions.Save(r, w, ses); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	// Redirect to the original page
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusFound)
+	return true
+}
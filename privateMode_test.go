@@ -3,11 +3,16 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/justinas/alice"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.goblog.app/app/pkgs/contenttype"
 )
 
 func Test_privateMode(t *testing.T) {
@@ -84,3 +89,178 @@ func Test_privateMode(t *testing.T) {
 	assert.Equal(t, "Awesome", rec.Body.String())
 
 }
+
+func Test_protectedBlog(t *testing.T) {
+
+	// Init
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User = &configUser{
+		Nick:     "test",
+		Password: "testpw",
+		AppPasswords: []*configAppPassword{
+			{
+				Username: "testapp",
+				Password: "pw",
+			},
+		},
+	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang: "en",
+			Protection: &configBlogProtection{
+				Enabled:    true,
+				Passphrase: "secret",
+				FeedToken:  "feedtoken",
+			},
+		},
+	}
+
+	_ = app.initConfig(false)
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	r := chi.NewRouter()
+	r.Use(app.checkIsBlogPassphrase)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.WithValue(blogKey, "en"), app.privateModeHandler)
+		r.Get("/test", func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte("Awesome"))
+		})
+		r.Get("/test"+feedPath, func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte("Feed"))
+		})
+	})
+
+	assert.Equal(t, "?token=feedtoken", app.cfg.Blogs["en"].feedTokenQuery())
+
+	t.Run("Passphrase required", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "Awesome")
+		assert.Contains(t, rec.Body.String(), "blogpassphrase")
+		assert.Equal(t, "noindex", rec.Header().Get("X-Robots-Tag"))
+	})
+
+	t.Run("Logged in", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.SetBasicAuth("testapp", "pw")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Awesome", rec.Body.String())
+	})
+
+	t.Run("Wrong passphrase", func(t *testing.T) {
+		data := url.Values{"loginaction": {"blogpassphrase"}, "blog": {"en"}, "passphrase": {"wrong"}}
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(data.Encode()))
+		req.Header.Set(contentType, contenttype.WWWForm)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Correct passphrase", func(t *testing.T) {
+		data := url.Values{"loginaction": {"blogpassphrase"}, "blog": {"en"}, "passphrase": {"secret"}}
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(data.Encode()))
+		req.Header.Set(contentType, contenttype.WWWForm)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "/test", rec.Header().Get("Location"))
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+
+		req = httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.AddCookie(cookies[0])
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Awesome", rec.Body.String())
+	})
+
+	t.Run("Feed token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test.rss?token=feedtoken", nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Feed", rec.Body.String())
+
+		// Token only works for feeds
+		req = httptest.NewRequest(http.MethodGet, "/test?token=feedtoken", nil)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		// Wrong token
+		req = httptest.NewRequest(http.MethodGet, "/test.rss?token=wrong", nil)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+}
+
+func Test_protectedBlogNotShared(t *testing.T) {
+	h := newTestHarness(t, withActivityPub, func(c *config) {
+		bc := createDefaultBlog()
+		bc.Protection = &configBlogProtection{Enabled: true, Passphrase: "secret"}
+		c.Blogs = map[string]*configBlog{"default": bc}
+		c.Syndication = &configSyndication{Targets: []*configSyndicationTarget{
+			{UID: "mastodon", Type: syndicationTypeMastodon, Instance: "https://mastodon.example", Token: "token", Default: true},
+		}}
+	})
+	blog := h.app.cfg.DefaultBlog
+	require.NoError(t, h.app.db.apAddFollower(blog, "https://a.example/users/1", "https://a.example/inbox", "@1@a.example"))
+	p := h.createPost(&post{Path: "/protected", Content: "Protected post with [link](https://other.example/)"})
+	h.dequeueAll("ap")
+	h.fc.clean()
+
+	t.Run("ActivityPub", func(t *testing.T) {
+		h.app.apPost(p)
+		h.app.apSendProfileUpdates()
+		assert.Empty(t, h.dequeueAll("ap"))
+		_, err := h.app.apQueueToAllFollowers(blog, h.app.apProfileUpdate(blog))
+		assert.Error(t, err)
+
+		for _, path := range []string{"/activitypub/followers/" + blog, "/activitypub/remote_follow/" + blog, "/.well-known/webfinger?resource=acct:" + blog + "@example.com"} {
+			status, _, _ := h.get(path)
+			assert.Equal(t, http.StatusNotFound, status, path)
+		}
+		rec := h.serve(httptest.NewRequest(http.MethodPost, testHarnessAddress+"/activitypub/inbox/"+blog, strings.NewReader("{}")))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		// No actor, even for the logged in user
+		req := httptest.NewRequest(http.MethodGet, testHarnessAddress+"/", nil)
+		req.Header.Set("Accept", contenttype.AS)
+		req.SetBasicAuth(testHarnessUser, testHarnessPassword)
+		assert.Equal(t, http.StatusNotFound, h.serve(req).Code)
+	})
+
+	t.Run("Webmentions and syndication", func(t *testing.T) {
+		require.NoError(t, h.app.sendWebmentions(p))
+		assert.Nil(t, h.fc.req)
+		h.app.syndicatePost(p)
+		assert.Empty(t, h.dequeueAll(syndicationQueueName))
+	})
+
+	t.Run("Robots and sitemap", func(t *testing.T) {
+		_, _, robots := h.get("/robots.txt")
+		assert.Contains(t, robots, "Disallow: /\n")
+		assert.NotContains(t, robots, sitemapBlogPath)
+		_, _, sitemap := h.get(sitemapPath)
+		assert.NotContains(t, sitemap, sitemapBlogPath)
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"sync"
 	"time"
 
@@ -75,7 +74,8 @@ func (a *goBlog) peekQueue(ctx context.Context, name string) (*queueItem, error)
 	return qi, nil
 }
 
-type queueProcessFunc func(qi *queueItem, dequeue func(), reschedule func(time.Duration))
+// The context gets canceled on shutdown, processing should stop and keep the item in the queue
+type queueProcessFunc func(ctx context.Context, qi *queueItem, dequeue func(), reschedule func(time.Duration))
 
 func (a *goBlog) listenOnQueue(queueName string, wait time.Duration, process queueProcessFunc) {
 	if process == nil {
@@ -86,7 +86,7 @@ func (a *goBlog) listenOnQueue(queueName string, wait time.Duration, process que
 	queueContext, cancelQueueContext := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
 
-	a.shutdown.Add(func() {
+	a.addShutdownBeforeDatabase(func() {
 		endQueue = true
 		cancelQueueContext()
 		wg.Wait()
@@ -101,7 +101,7 @@ func (a *goBlog) listenOnQueue(queueName string, wait time.Duration, process que
 			}
 			qi, err := a.peekQueue(queueContext, queueName)
 			if err != nil {
-				log.Println("queue peek error:", err.Error())
+				a.logger("queue").Error("Failed to peek queue", "queue", queueName, "err", err)
 				continue queueLoop
 			}
 			if qi == nil {
@@ -114,20 +114,21 @@ func (a *goBlog) listenOnQueue(queueName string, wait time.Duration, process que
 				}
 			}
 			process(
+				queueContext,
 				qi,
 				func() {
 					if err := a.dequeue(qi); err != nil {
-						log.Println("queue dequeue error:", err.Error())
+						a.logger("queue").Error("Failed to dequeue", "queue", queueName, "err", err)
 					}
 				},
 				func(dur time.Duration) {
 					if err := a.reschedule(qi, dur); err != nil {
-						log.Println("queue reschedule error:", err.Error())
+						a.logger("queue").Error("Failed to reschedule", "queue", queueName, "err", err)
 					}
 				},
 			)
 		}
-		log.Println("stopped queue:", queueName)
+		a.logger("queue").Info("Stopped queue", "queue", queueName)
 		wg.Done()
 	}()
 }
@@ -59,3 +59,36 @@ func Test_queue(t *testing.T) {
 	require.Equal(t, []byte("1"), qi.content)
 
 }
+
+func Test_queueShutdown(t *testing.T) {
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	_ = app.initConfig(false)
+
+	err := app.enqueue("test", []byte("1"), time.Now())
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	app.listenOnQueue("test", time.Second, func(ctx context.Context, qi *queueItem, dequeue func(), reschedule func(time.Duration)) {
+		close(started)
+		// Simulate a long running request that gets canceled on shutdown
+		<-ctx.Done()
+	})
+	<-started
+
+	// Shutdown waits for the queue before closing the database
+	app.shutdown.ShutdownAndWait()
+
+	// Item is still in the queue
+	db, err := app.openDatabase(app.cfg.Db.File, false)
+	require.NoError(t, err)
+	defer db.close()
+	app.db = db
+	qi, err := app.peekQueue(context.Background(), "test")
+	require.NoError(t, err)
+	require.NotNil(t, qi)
+	require.Equal(t, []byte("1"), qi.content)
+
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+// Token bucket rate limiting per client IP address for all requests,
+// with stricter limits for public endpoints that are expensive to handle
+
+const (
+	rateLimitStatsPath = "/api/ratelimits"
+
+	rateLimitAll = "*"
+
+	defaultRateLimitRequests      = 300 // per minute
+	defaultRateLimitRouteRequests = 30  // per minute
+	defaultRateLimitInboxRequests = 120 // per minute
+
+	rateLimitCleanupInterval = 10 * time.Minute
+
+	clientIPKey contextKey = "clientIP"
+)
+
+type rateLimiter struct {
+	mutex       sync.Mutex
+	buckets     map[string]*rateLimitBucket
+	stats       map[string]*rateLimitStats
+	lastCleanup time.Time
+}
+
+type rateLimitBucket struct {
+	tokens  float64
+	updated time.Time
+	rule    *rateLimitRule
+}
+
+type rateLimitStats struct {
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"`
+}
+
+type rateLimitRule struct {
+	pathPrefix string  // rateLimitAll for all paths
+	rate       float64 // tokens per second
+	burst      float64
+}
+
+func (a *goBlog) getRateLimiter() *rateLimiter {
+	a.rateLimiterInit.Do(func() {
+		a.rateLimiter = &rateLimiter{
+			buckets:     map[string]*rateLimitBucket{},
+			stats:       map[string]*rateLimitStats{},
+			lastCleanup: time.Now(),
+		}
+	})
+	return a.rateLimiter
+}
+
+// Parse the exemptions and trusted proxies, called on config init
+func (a *goBlog) initRateLimitConfig() (err error) {
+	if a.cfg.Server.trustedProxies, err = parseIPNets(a.cfg.Server.TrustedProxies); err != nil {
+		return errors.New("invalid trusted proxy: " + err.Error())
+	}
+	if rlc := a.cfg.Server.RateLimit; rlc != nil {
+		if rlc.exemptIPs, err = parseIPNets(rlc.ExemptIPs); err != nil {
+			return errors.New("invalid rate limit exemption: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Parse IP addresses and CIDR ranges
+func parseIPNets(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.New(value)
+			}
+			bits := lo.If(ip.To4() != nil, 32).Else(128)
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.New(value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Remember the address of the client before the log middleware removes it for privacy
+func (a *goBlog) setClientIP(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(clientIPKey).(string); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), clientIPKey, a.requestClientIP(r)))
+}
+
+// Get the IP address of the client, using X-Forwarded-For when the request comes from a trusted proxy
+func (a *goBlog) clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return a.requestClientIP(r)
+}
+
+func (a *goBlog) requestClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ipInNets(ip, a.cfg.Server.trustedProxies) {
+		return host
+	}
+	// Go backwards through the proxies until the first untrusted address
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		fip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if fip == nil {
+			break
+		}
+		host = fip.String()
+		if !ipInNets(fip, a.cfg.Server.trustedProxies) {
+			break
+		}
+	}
+	return host
+}
+
+func (rlc *configRateLimit) enabled() bool {
+	return rlc != nil && rlc.Enabled
+}
+
+// Rules for all requests and the public endpoints, configured routes override the defaults with the same path prefix
+func (a *goBlog) rateLimitRules() []*rateLimitRule {
+	rlc := a.cfg.Server.RateLimit
+	routes := []*configRateLimitRoute{
+		{PathPrefix: rateLimitAll, Requests: rlc.Requests, Burst: rlc.Burst},
+		{PathPrefix: "/activitypub/inbox", Requests: defaultRateLimitInboxRequests},
+		{PathPrefix: webmentionPath},
+		{PathPrefix: indieAuthPath + indieAuthTokenSubpath},
+		{PathPrefix: mastodonOAuthPath + "/token"},
+		{PathPrefix: mastodonAppsPath},
+	}
+	if routes[0].Requests == 0 {
+		routes[0].Requests = defaultRateLimitRequests
+	}
+	for _, blog := range sortedStrings(lo.Keys(a.cfg.Blogs)) {
+		if bsc := a.cfg.Blogs[blog].Search; bsc != nil && bsc.Enabled {
+			routes = append(routes, &configRateLimitRoute{PathPrefix: a.cfg.Blogs[blog].getRelativePath(defaultIfEmpty(bsc.Path, defaultSearchPath))})
+		}
+	}
+	for _, cr := range rlc.Routes {
+		if i := lo.IndexOf(lo.Map(routes, func(r *configRateLimitRoute, _ int) string { return r.PathPrefix }), cr.PathPrefix); i >= 0 {
+			routes[i] = cr
+		} else {
+			routes = append(routes, cr)
+		}
+	}
+	var rules []*rateLimitRule
+	for _, route := range routes {
+		requests := route.Requests
+		if requests == 0 {
+			requests = defaultRateLimitRouteRequests
+		}
+		if requests < 0 || route.PathPrefix == "" {
+			// Disabled
+			continue
+		}
+		burst := route.Burst
+		if burst <= 0 {
+			burst = requests
+		}
+		rules = append(rules, &rateLimitRule{pathPrefix: route.PathPrefix, rate: float64(requests) / 60, burst: float64(burst)})
+	}
+	return rules
+}
+
+func (rule *rateLimitRule) matches(path string) bool {
+	return rule.pathPrefix == rateLimitAll || path == rule.pathPrefix || strings.HasPrefix(path, strings.TrimSuffix(rule.pathPrefix, "/")+"/")
+}
+
+func (a *goBlog) rateLimitMiddleware(next http.Handler) http.Handler {
+	rlc := a.cfg.Server.RateLimit
+	if !rlc.enabled() {
+		return next
+	}
+	rules := a.rateLimitRules()
+	rl := a.getRateLimiter()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.rateLimitExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := a.clientIP(r)
+		now := time.Now()
+		for _, rule := range rules {
+			if !rule.matches(r.URL.Path) {
+				continue
+			}
+			if retryAfter := rl.take(rule, ip, now); retryAfter > 0 {
+				a.debug("Rate limited", r.URL.Path, "for", ip)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				a.serveError(w, r, "Too many requests, try again later", http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *goBlog) rateLimitExempt(r *http.Request) bool {
+	rlc := a.cfg.Server.RateLimit
+	for _, prefix := range rlc.ExemptPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	if ip := net.ParseIP(a.clientIP(r)); ip != nil && ipInNets(ip, rlc.exemptIPs) {
+		return true
+	}
+	// Only the session cookie, limited clients shouldn't be able to check credentials
+	return a.checkLoginCookie(r)
+}
+
+// Take a token from the bucket of the client for the rule, returns the time to wait if there is none left
+func (rl *rateLimiter) take(rule *rateLimitRule, ip string, now time.Time) time.Duration {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.cleanup(now)
+	stats, ok := rl.stats[rule.pathPrefix]
+	if !ok {
+		stats = &rateLimitStats{}
+		rl.stats[rule.pathPrefix] = stats
+	}
+	key := rule.pathPrefix + " " + ip
+	bucket, ok := rl.buckets[key]
+	if !ok || bucket.rule != rule {
+		// New client or the rules changed
+		bucket = &rateLimitBucket{tokens: rule.burst, updated: now, rule: rule}
+		rl.buckets[key] = bucket
+	}
+	bucket.refill(now)
+	if bucket.tokens < 1 {
+		stats.Limited++
+		return time.Duration((1 - bucket.tokens) / rule.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	stats.Allowed++
+	return 0
+}
+
+func (b *rateLimitBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.rule.burst, b.tokens+now.Sub(b.updated).Seconds()*b.rule.rate)
+	b.updated = now
+}
+
+// Remove full buckets, they are the same as new ones
+func (rl *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(rl.lastCleanup) < rateLimitCleanupInterval {
+		return
+	}
+	rl.lastCleanup = now
+	for key, bucket := range rl.buckets {
+		if bucket.refill(now); bucket.tokens >= bucket.rule.burst {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+type rateLimitStatsResponse struct {
+	Buckets int                        `json:"buckets"`
+	Routes  map[string]*rateLimitStats `json:"routes"`
+}
+
+// Counters of allowed and limited requests per route since the start, for monitoring
+func (a *goBlog) serveRateLimitStats(w http.ResponseWriter, _ *http.Request) {
+	rl := a.getRateLimiter()
+	rl.mutex.Lock()
+	res := &rateLimitStatsResponse{
+		Buckets: len(rl.buckets),
+		Routes:  map[string]*rateLimitStats{},
+	}
+	for route, stats := range rl.stats {
+		s := *stats
+		res.Routes[route] = &s
+	}
+	rl.mutex.Unlock()
+	w.Header().Set(contentType, contenttype.JSONUTF8)
+	_ = json.NewEncoder(w).Encode(res)
+}
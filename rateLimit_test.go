@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseIPNets(t *testing.T) {
+	nets, err := parseIPNets([]string{"192.168.1.1", "10.0.0.0/8", "2001:db8::/32", "::1"})
+	require.NoError(t, err)
+	require.Len(t, nets, 4)
+	assert.True(t, ipInNets([]byte{192, 168, 1, 1}, nets))
+	assert.False(t, ipInNets([]byte{192, 168, 1, 2}, nets))
+	assert.True(t, ipInNets([]byte{10, 1, 2, 3}, nets))
+
+	_, err = parseIPNets([]string{"abc"})
+	assert.Error(t, err)
+	_, err = parseIPNets([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func Test_clientIP(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.TrustedProxies = []string{"127.0.0.1", "10.0.0.0/8"}
+	require.NoError(t, app.initConfig(false))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	assert.Equal(t, "192.0.2.1", app.clientIP(req), "untrusted proxy")
+
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.1, 10.1.1.1")
+	assert.Equal(t, "198.51.100.1", app.clientIP(req), "first untrusted address")
+
+	req.Header.Del("X-Forwarded-For")
+	assert.Equal(t, "127.0.0.1", app.clientIP(req))
+
+	// The address survives the log middleware
+	req.RemoteAddr = "192.0.2.1:1234"
+	req = app.setClientIP(req)
+	req.RemoteAddr = ""
+	assert.Equal(t, "192.0.2.1", app.clientIP(req))
+}
+
+func Test_rateLimit(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.RateLimit = &configRateLimit{
+		Enabled:     true,
+		Requests:    3,
+		Routes:      []*configRateLimitRoute{{PathPrefix: webmentionPath, Requests: 1}},
+		ExemptIPs:   []string{"192.0.2.100"},
+		ExemptPaths: []string{"/ping"},
+	}
+	app.cfg.User = &configUser{
+		Nick:         "test",
+		Password:     "pass",
+		AppPasswords: []*configAppPassword{{Username: "app", Password: "pass"}},
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	// Session cookie of the logged in user
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
+	ses, err := app.loginSessions.Get(req, "l")
+	require.NoError(t, err)
+	ses.Values["login"] = true
+	require.NoError(t, app.loginSessions.Save(req, rec, ses))
+	sessionCookies := rec.Result().Cookies()
+	require.NotEmpty(t, sessionCookies)
+
+	request := func(method, path, ip string, login bool) *http.Response {
+		req := httptest.NewRequest(method, "http://localhost:8080"+path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if login {
+			for _, c := range sessionCookies {
+				req.AddCookie(c)
+			}
+		}
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	// Route limit
+	assert.NotEqual(t, http.StatusTooManyRequests, request(http.MethodPost, webmentionPath, "192.0.2.1", false).StatusCode)
+	res := request(http.MethodPost, webmentionPath, "192.0.2.1", false)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "60", res.Header.Get("Retry-After"))
+
+	// Global limit, the webmention requests count too
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/", "192.0.2.1", false).StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, request(http.MethodGet, "/", "192.0.2.1", false).StatusCode)
+
+	// Other clients aren't affected
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/", "192.0.2.2", false).StatusCode)
+
+	// Exemptions
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/", "192.0.2.100", false).StatusCode)
+		assert.NotEqual(t, http.StatusTooManyRequests, request(http.MethodGet, "/ping", "192.0.2.1", false).StatusCode)
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/", "192.0.2.1", true).StatusCode)
+	}
+
+	// Credentials don't exempt from the limit, so limited clients can't check passwords
+	req = httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.SetBasicAuth("app", "pass")
+	rec = httptest.NewRecorder()
+	app.d.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// Counters
+	res = request(http.MethodGet, rateLimitStatsPath, "192.0.2.1", true)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var stats rateLimitStatsResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&stats))
+	_ = res.Body.Close()
+	assert.Equal(t, 3, stats.Buckets)
+	assert.Equal(t, &rateLimitStats{Allowed: 4, Limited: 2}, stats.Routes[rateLimitAll])
+	assert.Equal(t, &rateLimitStats{Allowed: 1, Limited: 1}, stats.Routes[webmentionPath])
+
+	// Tokens are refilled over time
+	rl := app.getRateLimiter()
+	rule := &rateLimitRule{pathPrefix: "/test", rate: 1, burst: 2}
+	now := time.Now()
+	assert.Zero(t, rl.take(rule, "a", now))
+	assert.Zero(t, rl.take(rule, "a", now))
+	assert.Equal(t, time.Second, rl.take(rule, "a", now))
+	assert.Equal(t, 500*time.Millisecond, rl.take(rule, "a", now.Add(500*time.Millisecond)))
+	assert.Zero(t, rl.take(rule, "a", now.Add(time.Second)))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+
+	"go.goblog.app/app/pkgs/htmlbuilder"
+)
+
+// Word count, reading time and table of contents of posts, computed when the content is rendered
+
+const (
+	readingWordsPerMinute = 200
+	tocParameter          = "toc" // "true" or "false" to override the blog config
+	defaultTOCMinHeadings = 3
+)
+
+// Computes the meta data of the content if the post wasn't rendered yet
+func (a *goBlog) loadPostRenderMeta(p *post) {
+	if p.renderMeta != nil {
+		return
+	}
+	if meta, err := a.renderMarkdownWithMeta(nil, a.postContentWithPageData(p), false); err == nil {
+		p.renderMeta = meta
+	}
+}
+
+// Words of the rendered content
+func (p *post) WordCount() int {
+	if p.renderMeta == nil {
+		return 0
+	}
+	return p.renderMeta.words
+}
+
+// Estimated reading time in minutes, at least one minute for posts with content
+func (p *post) ReadingTime() int {
+	words := p.WordCount()
+	if words == 0 {
+		return 0
+	}
+	return (words + readingWordsPerMinute - 1) / readingWordsPerMinute
+}
+
+// Headings of the rendered content with their anchor IDs
+func (p *post) TableOfContents() []*markdownHeading {
+	if p.renderMeta == nil {
+		return nil
+	}
+	return p.renderMeta.headings
+}
+
+func (a *goBlog) renderPostReadingTime(hb *htmlbuilder.HtmlBuilder, p *post, b *configBlog) {
+	if !b.ReadingTime {
+		return
+	}
+	a.loadPostRenderMeta(p)
+	if p.ReadingTime() == 0 {
+		return
+	}
+	hb.WriteElementOpen("div")
+	hb.WriteEscaped(fmt.Sprintf(a.ts.GetTemplateStringVariant(b.Lang, "readingtime"), p.ReadingTime(), p.WordCount()))
+	hb.WriteElementClose("div")
+}
+
+func (a *goBlog) showTOC(p *post, b *configBlog) bool {
+	switch p.firstParameter(tocParameter) {
+	case "true":
+		return len(p.TableOfContents()) > 0
+	case "false":
+		return false
+	}
+	if b.TOC == nil || !b.TOC.Enabled {
+		return false
+	}
+	minHeadings := b.TOC.MinHeadings
+	if minHeadings <= 0 {
+		minHeadings = defaultTOCMinHeadings
+	}
+	return len(p.TableOfContents()) >= minHeadings
+}
+
+// Nested list of links to the headings
+func (a *goBlog) renderPostTOC(hb *htmlbuilder.HtmlBuilder, p *post, b *configBlog) {
+	a.loadPostRenderMeta(p)
+	if !a.showTOC(p, b) {
+		return
+	}
+	headings := p.TableOfContents()
+	minLevel := headings[0].level
+	for _, h := range headings {
+		if h.level < minLevel {
+			minLevel = h.level
+		}
+	}
+	hb.WriteElementOpen("nav", "class", "p toc")
+	hb.WriteElementOpen("strong")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "tableofcontents"))
+	hb.WriteElementClose("strong")
+	depth := 0
+	for _, h := range headings {
+		level := h.level - minLevel + 1
+		if level > depth {
+			for depth < level {
+				hb.WriteElementOpen("ul")
+				depth++
+				if depth < level {
+					// Skipped heading level
+					hb.WriteElementOpen("li")
+				}
+			}
+		} else {
+			hb.WriteElementClose("li")
+			for depth > level {
+				hb.WriteElementClose("ul")
+				hb.WriteElementClose("li")
+				depth--
+			}
+		}
+		hb.WriteElementOpen("li")
+		hb.WriteElementOpen("a", "href", "#"+h.id)
+		hb.WriteEscaped(h.text)
+		hb.WriteElementClose("a")
+	}
+	for depth > 0 {
+		hb.WriteElementClose("li")
+		hb.WriteElementClose("ul")
+		depth--
+	}
+	hb.WriteElementClose("nav")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renderMarkdownWithMeta(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+
+	var sb strings.Builder
+	meta, err := app.renderMarkdownWithMeta(&sb, "## First *heading*\n\nSome text with a [link](/test).\n\n```\ncode isn't counted\n```\n\n### Second \"heading\"\n\nMore text.", false)
+	require.NoError(t, err)
+	assert.Contains(t, sb.String(), `<h2 id="first-heading">`)
+	assert.Equal(t, 11, meta.words)
+	require.Len(t, meta.headings, 2)
+	assert.Equal(t, &markdownHeading{level: 2, id: "first-heading", text: "First heading"}, meta.headings[0])
+	assert.Equal(t, 3, meta.headings[1].level)
+	assert.Equal(t, "second-heading", meta.headings[1].id)
+
+	p := &post{renderMeta: &markdownMeta{words: 401}}
+	assert.Equal(t, 401, p.WordCount())
+	assert.Equal(t, 3, p.ReadingTime())
+	assert.Zero(t, (&post{}).ReadingTime())
+}
+
+func Test_readingTimeAndTOC(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"default": {
+			Path:        "/",
+			Lang:        "en",
+			Sections:    map[string]*configSection{"posts": {Name: "posts"}},
+			ReadingTime: true,
+			TOC:         &configTOC{Enabled: true, MinHeadings: 2},
+		},
+	}
+	app.cfg.DefaultBlog = "default"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	long := strings.Repeat("word ", 450)
+	for _, p := range []*post{
+		{Path: "/toc", Content: "## Intro\n\n" + long + "\n\n### Details\n\nText\n\n## End\n\nText"},
+		{Path: "/short", Content: "## Only one heading\n\nShort"},
+		{Path: "/forced", Content: "## Only one heading\n\nShort", Parameters: map[string][]string{"toc": {"true"}}},
+		{Path: "/disabled", Content: "## One\n\n## Two", Parameters: map[string][]string{"toc": {"false"}}},
+	} {
+		p.Section, p.Status, p.Visibility = "posts", statusPublished, visibilityPublic
+		require.NoError(t, app.createPost(p))
+	}
+
+	client := newHandlerClient(app.d)
+	get := func(path string) string {
+		var body string
+		require.NoError(t, requests.URL("http://localhost:8080"+path).Client(client).ToString(&body).Fetch(context.Background()))
+		return body
+	}
+
+	body := get("/toc")
+	assert.Contains(t, body, "3 min read (455 words)")
+	assert.Contains(t, body, "<nav class=\"p toc\"><strong>Contents</strong><ul><li><a href=#intro>Intro</a><ul><li><a href=#details>Details</a></ul><li><a href=#end>End</a></ul></nav>")
+	assert.Less(t, strings.Index(body, "toc"), strings.Index(body, "e-content"))
+
+	body = get("/short")
+	assert.Contains(t, body, "1 min read (4 words)")
+	assert.NotContains(t, body, "Contents")
+
+	assert.Contains(t, get("/forced"), "<a href=#only-one-heading>")
+	assert.NotContains(t, get("/disabled"), "Contents")
+}
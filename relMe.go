@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/carlmjohnson/requests"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
+	"go.goblog.app/app/pkgs/contenttype"
+	"willnorris.com/go/microformats"
+)
+
+// Identities are the profiles on other sites (like GitHub or Mastodon) that are linked with rel=me.
+// They are stored in the database and periodically checked for a link back to the blog,
+// so it's possible to sign in to other sites with RelMeAuth or IndieAuth using the blog URL.
+
+const (
+	identitiesSetting = "identities"
+
+	relMeCheckInterval = 24 * time.Hour
+)
+
+type relMeStatus struct {
+	identity string
+	verified bool
+	checked  time.Time
+	message  string
+}
+
+func (a *goBlog) initRelMeVerification() {
+	a.hourlyHooks = append(a.hourlyHooks, func() {
+		a.verifyIdentities(false)
+	})
+}
+
+// Load the identities from the database or migrate the configured ones
+func (a *goBlog) loadIdentities() error {
+	value, err := a.getSettingValue(identitiesSetting)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		// Migrate to database
+		return a.saveIdentities(a.cfg.User.Identities)
+	}
+	var identities []string
+	if err = json.Unmarshal([]byte(value), &identities); err != nil {
+		return err
+	}
+	a.cfg.User.Identities = identities
+	return nil
+}
+
+// Save the identities to the database and remove the status of removed identities
+func (a *goBlog) saveIdentities(identities []string) error {
+	identities = lo.Uniq(lo.Compact(lo.Map(identities, func(i string, _ int) string { return strings.TrimSpace(i) })))
+	value, err := json.Marshal(lo.If(identities == nil, []string{}).Else(identities))
+	if err != nil {
+		return err
+	}
+	if err = a.saveSettingValue(identitiesSetting, string(value)); err != nil {
+		return err
+	}
+	a.cfg.User.Identities = identities
+	statuses, err := a.db.getRelMeStatuses()
+	if err != nil {
+		return err
+	}
+	for identity := range statuses {
+		if !lo.Contains(identities, identity) {
+			if err = a.db.deleteRelMeStatus(identity); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Check all identities for a link back to the blog, if force is false only the ones not checked recently
+func (a *goBlog) verifyIdentities(force bool) {
+	if a.isPrivate() {
+		// Private mode, don't make external requests
+		return
+	}
+	statuses, err := a.db.getRelMeStatuses()
+	if err != nil {
+		log.Println("Failed to get rel=me status:", err.Error())
+		return
+	}
+	for _, identity := range a.cfg.User.Identities {
+		if s, ok := statuses[identity]; ok && !force && time.Since(s.checked) < relMeCheckInterval {
+			continue
+		}
+		status := &relMeStatus{identity: identity, checked: time.Now()}
+		status.verified, err = a.verifyRelMe(identity)
+		if err != nil {
+			status.message = err.Error()
+		} else if !status.verified {
+			status.message = "no link back to the blog found"
+		}
+		if err = a.db.saveRelMeStatus(status); err != nil {
+			log.Println("Failed to save rel=me status:", err.Error())
+		}
+	}
+}
+
+// URLs that count as a link back to the blog
+func (a *goBlog) relMeTargets() []string {
+	targets := []string{a.getInstanceRootURL()}
+	for _, bc := range a.cfg.Blogs {
+		targets = append(targets, a.getFullAddress(bc.getRelativePath("")))
+	}
+	return lo.Uniq(lo.Map(targets, func(t string, _ int) string { return normalizeRelMeURL(t) }))
+}
+
+func normalizeRelMeURL(u string) string {
+	pu, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return u
+	}
+	return strings.ToLower(pu.Host) + strings.TrimSuffix(pu.EscapedPath(), "/")
+}
+
+// Short name for an identity, like "github.com"
+func identityName(identity string) string {
+	pu, err := url.Parse(identity)
+	if err != nil || pu.Host == "" {
+		return identity
+	}
+	return strings.TrimPrefix(strings.ToLower(pu.Hostname()), "www.")
+}
+
+// Check if the identity links back to the blog. HTML pages (like GitHub profiles) need a rel=me link,
+// ActivityPub actors (like Mastodon profiles) need the link in the profile metadata or the URL.
+func (a *goBlog) verifyRelMe(identity string) (bool, error) {
+	if !isAbsoluteURL(identity) {
+		return false, errors.New("identity is not an absolute URL")
+	}
+	targets := a.relMeTargets()
+	isTarget := func(link string) bool {
+		return lo.Contains(targets, normalizeRelMeURL(link))
+	}
+	// HTML
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	var finalURL *url.URL
+	err := requests.URL(identity).Client(a.httpClient).Accept(contenttype.HTMLUTF8).
+		AddValidator(func(r *http.Response) error {
+			if r.StatusCode < 200 || 300 <= r.StatusCode {
+				return fmt.Errorf("HTTP %d", r.StatusCode)
+			}
+			finalURL = r.Request.URL
+			return nil
+		}).
+		ToBytesBuffer(buf).
+		Fetch(context.Background())
+	if err != nil {
+		return false, err
+	}
+	if finalURL == nil {
+		finalURL, _ = url.Parse(identity)
+	}
+	mfd := microformats.Parse(buf, finalURL)
+	if lo.ContainsBy(mfd.Rels["me"], isTarget) {
+		return true, nil
+	}
+	// ActivityPub
+	actor := map[string]any{}
+	err = requests.URL(identity).Client(a.httpClient).Accept(contenttype.AS).
+		ToJSON(&actor).
+		Fetch(context.Background())
+	if err != nil {
+		// No ActivityPub actor
+		return false, nil
+	}
+	if u, ok := actor["url"].(string); ok && isTarget(u) {
+		return true, nil
+	}
+	attachments, _ := actor["attachment"].([]any)
+	for _, attachment := range attachments {
+		am, ok := attachment.(map[string]any)
+		if !ok {
+			continue
+		}
+		value, _ := am["value"].(string)
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(value))
+		if err != nil {
+			continue
+		}
+		found := false
+		doc.Find("a[href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			found = isTarget(s.AttrOr("href", ""))
+			return !found
+		})
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (db *database) saveRelMeStatus(s *relMeStatus) error {
+	_, err := db.Exec(
+		"insert or replace into relme (identity, verified, checked, message) values (@identity, @verified, @checked, @message)",
+		sql.Named("identity", s.identity),
+		sql.Named("verified", s.verified),
+		sql.Named("checked", s.checked.UTC().Format(time.RFC3339)),
+		sql.Named("message", s.message),
+	)
+	return err
+}
+
+func (db *database) deleteRelMeStatus(identity string) error {
+	_, err := db.Exec("delete from relme where identity = @identity", sql.Named("identity", identity))
+	return err
+}
+
+func (db *database) getRelMeStatuses() (map[string]*relMeStatus, error) {
+	rows, err := db.Query("select identity, verified, checked, message from relme")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	statuses := map[string]*relMeStatus{}
+	var checked string
+	for rows.Next() {
+		s := &relMeStatus{}
+		if err = rows.Scan(&s.identity, &s.verified, &checked, &s.message); err != nil {
+			return nil, err
+		}
+		s.checked, _ = time.Parse(time.RFC3339, checked)
+		statuses[s.identity] = s
+	}
+	return statuses, rows.Err()
+}
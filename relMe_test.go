@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+func Test_relMe(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.Server.PublicAddress = "https://example.com"
+	app.cfg.User.Identities = []string{"https://github.com/example", "https://social.example/@example"}
+
+	require.NoError(t, app.initConfig(false))
+
+	// Configured identities are migrated to the database
+	value, err := app.getSettingValue(identitiesSetting)
+	require.NoError(t, err)
+	assert.Equal(t, `["https://github.com/example","https://social.example/@example"]`, value)
+
+	fc.setHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Host == "github.com":
+			w.Header().Set(contentType, contenttype.HTMLUTF8)
+			_, _ = w.Write([]byte(`<html><body><a href="https://example.com/" rel="nofollow me">Website</a></body></html>`))
+		case strings.Contains(r.Header.Get("Accept"), contenttype.AS):
+			w.Header().Set(contentType, contenttype.ASUTF8)
+			_, _ = w.Write([]byte(`{"type":"Person","url":"https://social.example/@example","attachment":[{"type":"PropertyValue","name":"Blog","value":"<a href=\"https://example.com\" rel=\"me\">example.com</a>"}]}`))
+		default:
+			w.Header().Set(contentType, contenttype.HTMLUTF8)
+			_, _ = w.Write([]byte(`<html><body><div id="mastodon"></div></body></html>`))
+		}
+	}))
+
+	verified, err := app.verifyRelMe("https://github.com/example")
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	verified, err = app.verifyRelMe("https://social.example/@example")
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	app.verifyIdentities(false)
+	statuses, err := app.db.getRelMeStatuses()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses["https://github.com/example"].verified)
+
+	// No link back
+	fc.setFakeResponse(http.StatusOK, `<html><body><a href="https://other.example/" rel="me">Other</a></body></html>`)
+	verified, err = app.verifyRelMe("https://github.com/example")
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	// Recently checked identities are skipped unless forced
+	app.verifyIdentities(false)
+	statuses, err = app.db.getRelMeStatuses()
+	require.NoError(t, err)
+	assert.True(t, statuses["https://github.com/example"].verified)
+
+	app.verifyIdentities(true)
+	statuses, err = app.db.getRelMeStatuses()
+	require.NoError(t, err)
+	assert.False(t, statuses["https://github.com/example"].verified)
+	assert.NotEmpty(t, statuses["https://github.com/example"].message)
+
+	// Removing an identity removes its status
+	require.NoError(t, app.saveIdentities([]string{" https://github.com/example ", ""}))
+	assert.Equal(t, []string{"https://github.com/example"}, app.cfg.User.Identities)
+	statuses, err = app.db.getRelMeStatuses()
+	require.NoError(t, err)
+	assert.Len(t, statuses, 1)
+
+	// Settings show the status
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	app.initSessions()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+	req = req.WithContext(context.WithValue(req.Context(), blogKey, app.cfg.DefaultBlog))
+	app.serveSettings(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Not verified (no link back to the blog found)")
+	assert.Contains(t, rec.Body.String(), "<link rel=me href=https://github.com/example>")
+	assert.NotContains(t, rec.Body.String(), "<link rel=me href=https://example.com>")
+
+	// With ActivityPub the blog links to the actor
+	app.cfg.ActivityPub.Enabled = true
+	rec = httptest.NewRecorder()
+	app.serveSettings(rec, req)
+	assert.Contains(t, rec.Body.String(), "<link rel=me href=https://example.com>")
+	app.cfg.ActivityPub.Enabled = false
+
+	// Empty list stays empty after reload
+	require.NoError(t, app.saveIdentities(nil))
+	app.cfg.User.Identities = []string{"https://github.com/example"}
+	require.NoError(t, app.loadIdentities())
+	assert.Empty(t, app.cfg.User.Identities)
+}
+
+func Test_identityName(t *testing.T) {
+	assert.Equal(t, "github.com", identityName("https://github.com/example"))
+	assert.Equal(t, "example.org", identityName("https://www.Example.org/"))
+	assert.Equal(t, "example", identityName("example"))
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/builderpool"
+	"go.goblog.app/app/pkgs/htmlbuilder"
+)
+
+// Related posts: the similarity of the public posts of a blog is computed from shared taxonomy values
+// and the TF-IDF vectors of the content, the best matches are stored per post and shown below the post
+
+const (
+	defaultRelatedPostsCount     = 3
+	relatedPostsMethodTaxonomies = "taxonomies"
+	relatedPostsMethodContent    = "content"
+	relatedPostsMinTokenLength   = 3
+)
+
+func (rc *configRelatedPosts) enabled() bool {
+	return rc != nil && rc.Enabled
+}
+
+func (rc *configRelatedPosts) count() int {
+	if rc == nil || rc.Count <= 0 {
+		return defaultRelatedPostsCount
+	}
+	return rc.Count
+}
+
+func (a *goBlog) initRelatedPosts() {
+	var enabled bool
+	for _, bc := range a.cfg.Blogs {
+		enabled = enabled || bc.RelatedPosts.enabled()
+	}
+	if !enabled {
+		return
+	}
+	refresh := func(p *post) {
+		if bc, ok := a.cfg.Blogs[p.Blog]; !ok || !bc.RelatedPosts.enabled() {
+			return
+		}
+		// Drafts that never were public aren't related to anything
+		if !p.isPublicPublishedSectionPost() && !a.db.hasRelatedPosts(p.Path) {
+			return
+		}
+		a.refreshRelatedPosts(p.Blog)
+	}
+	a.pPostHooks = append(a.pPostHooks, refresh)
+	a.pUpdateHooks = append(a.pUpdateHooks, refresh)
+	a.pDeleteHooks = append(a.pDeleteHooks, refresh)
+	a.pUndeleteHooks = append(a.pUndeleteHooks, refresh)
+	// Initial computation, e.g. after enabling it in the config
+	go func() {
+		for blog, bc := range a.cfg.Blogs {
+			if bc.RelatedPosts.enabled() {
+				a.refreshRelatedPosts(blog)
+			}
+		}
+	}()
+}
+
+// Recompute and store the related posts of all posts of the blog
+func (a *goBlog) refreshRelatedPosts(blog string) {
+	bc, ok := a.cfg.Blogs[blog]
+	if !ok || !bc.RelatedPosts.enabled() {
+		return
+	}
+	a.relatedPostsMutex.Lock()
+	defer a.relatedPostsMutex.Unlock()
+	posts, err := a.getPosts(&postsRequestConfig{
+		blog:                 blog,
+		sections:             lo.Keys(bc.Sections),
+		status:               []postStatus{statusPublished},
+		visibility:           []postVisibility{visibilityPublic},
+		withoutRenderedTitle: true,
+	})
+	if err != nil {
+		a.logger("related").Error("Failed to get posts", "blog", blog, "err", err)
+		return
+	}
+	related := a.computeRelatedPosts(bc, posts)
+	changed, err := a.db.saveRelatedPosts(blog, related)
+	if err != nil {
+		a.logger("related").Error("Failed to save related posts", "blog", blog, "err", err)
+		return
+	}
+	// Cached posts show the old list
+	a.cache.purgePost(changed...)
+}
+
+type relatedPost struct {
+	path  string
+	score float64
+}
+
+// Returns the best matches for each post, ordered by score
+func (a *goBlog) computeRelatedPosts(bc *configBlog, posts []*post) map[string][]*relatedPost {
+	method := bc.RelatedPosts.Method
+	useTaxonomies, useContent := method != relatedPostsMethodContent, method != relatedPostsMethodTaxonomies
+	var taxVectors, contentVectors []map[string]float64
+	if useTaxonomies {
+		taxVectors = lo.Map(posts, func(p *post, _ int) map[string]float64 { return relatedTaxonomyVector(bc, p) })
+	}
+	if useContent {
+		contentVectors = tfidfVectors(lo.Map(posts, func(p *post, _ int) []string {
+			return relatedTokens(p.Title() + " " + a.renderTextSafe(p.Content))
+		}))
+	}
+	candidates := make([][]*relatedPost, len(posts))
+	for i := range posts {
+		for j := i + 1; j < len(posts); j++ {
+			var score float64
+			if useTaxonomies {
+				score += cosineSimilarity(taxVectors[i], taxVectors[j])
+			}
+			if useContent {
+				score += cosineSimilarity(contentVectors[i], contentVectors[j])
+			}
+			if useTaxonomies && useContent {
+				score /= 2
+			}
+			if score <= 0 {
+				continue
+			}
+			candidates[i] = append(candidates[i], &relatedPost{path: posts[j].Path, score: score})
+			candidates[j] = append(candidates[j], &relatedPost{path: posts[i].Path, score: score})
+		}
+	}
+	count := bc.RelatedPosts.count()
+	result := map[string][]*relatedPost{}
+	for i, p := range posts {
+		c := candidates[i]
+		sort.SliceStable(c, func(x, y int) bool { return c[x].score > c[y].score })
+		if len(c) > count {
+			c = c[:count]
+		}
+		result[p.Path] = c
+	}
+	return result
+}
+
+// Binary vector of the taxonomy values of the post, like "tags:go"
+func relatedTaxonomyVector(bc *configBlog, p *post) map[string]float64 {
+	v := map[string]float64{}
+	for _, t := range bc.Taxonomies {
+		for _, value := range p.Parameters[t.Name] {
+			if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+				v[t.Name+":"+value] = 1
+			}
+		}
+	}
+	return v
+}
+
+// Lowercase words with at least three letters or digits
+func relatedTokens(text string) []string {
+	return lo.Filter(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), func(token string, _ int) bool {
+		return len([]rune(token)) >= relatedPostsMinTokenLength
+	})
+}
+
+// Term frequency weighted by the inverse document frequency for each document
+func tfidfVectors(documents [][]string) []map[string]float64 {
+	df := map[string]int{}
+	for _, tokens := range documents {
+		for _, token := range lo.Uniq(tokens) {
+			df[token]++
+		}
+	}
+	n := float64(len(documents))
+	vectors := make([]map[string]float64, len(documents))
+	for i, tokens := range documents {
+		v := map[string]float64{}
+		for _, token := range tokens {
+			v[token]++
+		}
+		for token, count := range v {
+			// Words in every document don't help
+			v[token] = count / float64(len(tokens)) * math.Log(n/float64(df[token]))
+			if v[token] == 0 {
+				delete(v, token)
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+func cosineSimilarity(x, y map[string]float64) float64 {
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+	if len(y) < len(x) {
+		x, y = y, x
+	}
+	var dot, normX, normY float64
+	for key, value := range x {
+		dot += value * y[key]
+		normX += value * value
+	}
+	if dot == 0 {
+		return 0
+	}
+	for _, value := range y {
+		normY += value * value
+	}
+	return dot / math.Sqrt(normX*normY)
+}
+
+// Replaces the stored related posts of the blog, returns the paths of the posts whose list changed
+func (db *database) saveRelatedPosts(blog string, related map[string][]*relatedPost) ([]string, error) {
+	old := map[string]string{}
+	rows, err := db.Query(
+		"select path, group_concat(related, ' ') from (select r.path, r.related from related_posts r join posts p on r.path = p.path where p.blog = @blog order by r.path, r.score desc) group by path",
+		sql.Named("blog", blog),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var path, list string
+		if err = rows.Scan(&path, &list); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		old[path] = list
+	}
+	_ = rows.Close()
+	var changed []string
+	sqlBuilder := builderpool.Get()
+	defer builderpool.Put(sqlBuilder)
+	sqlArgs := []any{dbNoCache, blog}
+	sqlBuilder.WriteString("begin;delete from related_posts where path in (select path from posts where blog = ?);")
+	for path, list := range related {
+		for _, r := range list {
+			sqlBuilder.WriteString("insert into related_posts (path, related, score) values (?, ?, ?);")
+			sqlArgs = append(sqlArgs, path, r.path, r.score)
+		}
+		if strings.Join(lo.Map(list, func(r *relatedPost, _ int) string { return r.path }), " ") != old[path] {
+			changed = append(changed, path)
+		}
+		delete(old, path)
+	}
+	// Posts that aren't public anymore
+	changed = append(changed, lo.Keys(old)...)
+	sqlBuilder.WriteString("commit;")
+	if _, err = db.Exec(sqlBuilder.String(), sqlArgs...); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+func (db *database) hasRelatedPosts(path string) bool {
+	row, err := db.QueryRow("select exists(select 1 from related_posts where path = @path or related = @path)", sql.Named("path", path))
+	if err != nil {
+		return false
+	}
+	var exists bool
+	_ = row.Scan(&exists)
+	return exists
+}
+
+func (db *database) getRelatedPostPaths(path string) []string {
+	rows, err := db.Query("select related from related_posts where path = @path order by score desc", sql.Named("path", path))
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var related string
+		if rows.Scan(&related) == nil {
+			paths = append(paths, related)
+		}
+	}
+	return paths
+}
+
+// "You might also like" block below the post
+func (a *goBlog) renderRelatedPosts(hb *htmlbuilder.HtmlBuilder, p *post, b *configBlog) {
+	if b == nil || p == nil || !b.RelatedPosts.enabled() {
+		return
+	}
+	var related []*post
+	for _, path := range a.db.getRelatedPostPaths(p.Path) {
+		posts, err := a.getPosts(&postsRequestConfig{
+			path:                   path,
+			status:                 []postStatus{statusPublished},
+			visibility:             []postVisibility{visibilityPublic},
+			withinVisibilityWindow: true,
+			withOnlyParameters:     []string{"title"},
+			limit:                  1,
+		})
+		if err == nil && len(posts) == 1 {
+			related = append(related, posts[0])
+		}
+	}
+	if len(related) == 0 {
+		return
+	}
+	hb.WriteElementOpen("div", "class", "p border-top", "id", "related-posts")
+	hb.WriteElementOpen("p")
+	hb.WriteElementOpen("strong")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "relatedposts"))
+	hb.WriteElementClose("strong")
+	hb.WriteElementClose("p")
+	hb.WriteElementOpen("ul")
+	for _, rp := range related {
+		hb.WriteElementOpen("li")
+		hb.WriteElementOpen("a", "href", rp.Path)
+		hb.WriteEscaped(defaultIfEmpty(rp.RenderedTitle, a.fallbackTitle(rp)))
+		hb.WriteElementClose("a")
+		hb.WriteElementClose("li")
+	}
+	hb.WriteElementClose("ul")
+	hb.WriteElementClose("div")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_relatedTokens(t *testing.T) {
+	assert.Equal(t, []string{"hello", "wörld", "2023"}, relatedTokens("Hello, wörld! It's 2023."))
+}
+
+func Test_cosineSimilarity(t *testing.T) {
+	assert.InDelta(t, 1, cosineSimilarity(map[string]float64{"a": 1, "b": 2}, map[string]float64{"a": 2, "b": 4}), 0.0001)
+	assert.Zero(t, cosineSimilarity(map[string]float64{"a": 1}, map[string]float64{"b": 1}))
+	assert.Zero(t, cosineSimilarity(nil, map[string]float64{"b": 1}))
+}
+
+func Test_relatedPosts(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"default": {
+			Path:         "/",
+			Lang:         "en",
+			Sections:     map[string]*configSection{"posts": {Name: "posts"}},
+			Taxonomies:   []*configTaxonomy{{Name: "tags", Title: "Tags"}},
+			RelatedPosts: &configRelatedPosts{Enabled: true, Count: 2},
+		},
+	}
+	app.cfg.DefaultBlog = "default"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/sourdough", Content: "Baking sourdough bread with a starter and flour.", Parameters: map[string][]string{"title": {"Sourdough"}, "tags": {"baking"}}},
+		{Path: "/rye", Content: "My rye bread recipe uses a sourdough starter.", Parameters: map[string][]string{"title": {"Rye bread"}, "tags": {"baking"}}},
+		{Path: "/pizza", Content: "Pizza dough with flour and yeast.", Parameters: map[string][]string{"title": {"Pizza"}, "tags": {"baking"}}},
+		{Path: "/golang", Content: "Writing a web server in Go.", Parameters: map[string][]string{"title": {"Go server"}, "tags": {"programming"}}},
+		{Path: "/draft", Content: "A sourdough bread draft.", Status: statusDraft, Parameters: map[string][]string{"title": {"Draft"}, "tags": {"baking"}}},
+	} {
+		p.Section, p.Visibility = "posts", visibilityPublic
+		if p.Status == "" {
+			p.Status = statusPublished
+		}
+		require.NoError(t, app.createPost(p))
+	}
+	app.refreshRelatedPosts("default")
+
+	assert.Equal(t, []string{"/rye", "/pizza"}, app.db.getRelatedPostPaths("/sourdough"))
+	assert.Empty(t, app.db.getRelatedPostPaths("/golang"))
+	assert.Empty(t, app.db.getRelatedPostPaths("/draft"))
+	assert.True(t, app.db.hasRelatedPosts("/pizza"))
+	assert.False(t, app.db.hasRelatedPosts("/draft"))
+
+	client := newHandlerClient(app.d)
+	var body string
+	require.NoError(t, requests.URL("http://localhost:8080/sourdough").Client(client).ToString(&body).Fetch(context.Background()))
+	assert.Contains(t, body, "You might also like")
+	assert.Contains(t, body, "href=/rye>Rye bread</a>")
+	assert.NotContains(t, body, "href=/draft>")
+	require.NoError(t, requests.URL("http://localhost:8080/golang").Client(client).ToString(&body).Fetch(context.Background()))
+	assert.NotContains(t, body, "You might also like")
+
+	// Only the content
+	app.cfg.Blogs["default"].RelatedPosts.Method = relatedPostsMethodContent
+	app.refreshRelatedPosts("default")
+	assert.Equal(t, []string{"/rye", "/pizza"}, app.db.getRelatedPostPaths("/sourdough"))
+
+	// Deleted posts are removed from the lists and the cached pages are updated
+	require.NoError(t, app.deletePost("/rye"))
+	app.refreshRelatedPosts("default")
+	assert.NotContains(t, app.db.getRelatedPostPaths("/sourdough"), "/rye")
+	require.NoError(t, requests.URL("http://localhost:8080/sourdough").Client(client).ToString(&body).Fetch(context.Background()))
+	assert.NotContains(t, body, "href=/rye>")
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/PuerkitoBio/goquery"
+	"go.goblog.app/app/pkgs/bufferpool"
 	"go.goblog.app/app/pkgs/contenttype"
 	"go.goblog.app/app/pkgs/htmlbuilder"
 	"go.goblog.app/app/pkgs/plugintypes"
@@ -63,6 +64,14 @@ func (a *goBlog) renderWithStatusCode(w http.ResponseWriter, r *http.Request, st
 		_ = pluginPipeWriter.Close()
 	}()
 	// Return minified HTML
+	if replacer := a.previewThemeReplacer(r, data.Blog); replacer != nil {
+		// Use the assets of the preview theme
+		buf := bufferpool.Get()
+		defer bufferpool.Put(buf)
+		_ = pluginPipeReader.CloseWithError(a.min.Get().Minify(contenttype.HTML, buf, pluginPipeReader))
+		_, _ = replacer.WriteString(w, buf.String())
+		return
+	}
 	_ = pluginPipeReader.CloseWithError(a.min.Get().Minify(contenttype.HTML, w, pluginPipeReader))
 }
 
@@ -120,9 +129,10 @@ func (a *goBlog) checkRenderData(r *http.Request, data *renderData) {
 	// Plugins
 	if data.prc == nil {
 		data.prc = &pluginRenderContext{
-			blog: data.BlogString,
-			path: r.URL.Path,
-			url:  a.getFullAddress(r.URL.Path),
+			blog:  data.BlogString,
+			path:  r.URL.Path,
+			url:   a.getFullAddress(r.URL.Path),
+			nonce: cspNonce(r),
 		}
 	}
 	// Data
@@ -134,9 +144,10 @@ func (a *goBlog) checkRenderData(r *http.Request, data *renderData) {
 // Plugins
 
 type pluginRenderContext struct {
-	blog string
-	path string
-	url  string
+	blog  string
+	path  string
+	url   string
+	nonce string
 }
 
 func (d *pluginRenderContext) GetBlog() string {
@@ -150,3 +161,7 @@ func (d *pluginRenderContext) GetPath() string {
 func (d *pluginRenderContext) GetURL() string {
 	return d.url
 }
+
+func (d *pluginRenderContext) GetCSPNonce() string {
+	return d.nonce
+}
@@ -2,20 +2,129 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+
+	"github.com/samber/lo"
 )
 
 const robotsTXTPath = "/robots.txt"
 
+// User agents of known crawlers collecting content to train AI models
+var robotsAICrawlers = []string{
+	"AI2Bot",
+	"Amazonbot",
+	"anthropic-ai",
+	"Applebot-Extended",
+	"Bytespider",
+	"CCBot",
+	"ChatGPT-User",
+	"Claude-Web",
+	"ClaudeBot",
+	"cohere-ai",
+	"Diffbot",
+	"FacebookBot",
+	"Google-Extended",
+	"GPTBot",
+	"ImagesiftBot",
+	"Meta-ExternalAgent",
+	"Omgilibot",
+	"PerplexityBot",
+	"Timpibot",
+	"YouBot",
+}
+
 func (a *goBlog) serveRobotsTXT(w http.ResponseWriter, _ *http.Request) {
-	_, _ = fmt.Fprint(w, "User-agent: *\n")
 	if a.isPrivate() {
+		_, _ = fmt.Fprint(w, "User-agent: *\n")
 		_, _ = fmt.Fprint(w, "Disallow: /\n")
 		return
 	}
-	_, _ = fmt.Fprint(w, "Allow: /\n\n")
+	privatePaths := a.robotsPrivatePaths()
+	rc := a.cfg.Robots
+	if rc == nil {
+		rc = &configRobots{}
+	}
+	// Configured rules, rules for all user agents are added to the default group
+	var defaultRules []*configRobotsRule
+	for _, rule := range rc.Rules {
+		if lo.Contains(rule.UserAgents, "*") {
+			defaultRules = append(defaultRules, rule)
+		}
+		if userAgents := lo.Without(rule.UserAgents, "*"); len(userAgents) > 0 {
+			writeRobotsGroup(w, userAgents, privatePaths, []*configRobotsRule{rule}, false)
+		}
+	}
+	// AI crawlers
+	if rc.BlockAI {
+		writeRobotsGroup(w, robotsAICrawlers, nil, []*configRobotsRule{{Disallow: []string{"/"}}}, false)
+	}
+	// Default group
+	writeRobotsGroup(w, []string{"*"}, privatePaths, defaultRules, true)
+	// Sitemaps
 	_, _ = fmt.Fprintf(w, "Sitemap: %s\n", a.getFullAddress(sitemapPath))
-	for _, bc := range a.cfg.Blogs {
-		_, _ = fmt.Fprintf(w, "Sitemap: %s\n", a.getFullAddress(bc.getRelativePath(sitemapBlogPath)))
+	for _, blog := range sortedStrings(lo.Keys(a.cfg.Blogs)) {
+		if a.isProtectedBlog(blog) {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "Sitemap: %s\n", a.getFullAddress(a.cfg.Blogs[blog].getRelativePath(sitemapBlogPath)))
+	}
+}
+
+func writeRobotsGroup(w io.Writer, userAgents, privatePaths []string, rules []*configRobotsRule, allowAll bool) {
+	for _, userAgent := range userAgents {
+		_, _ = fmt.Fprintf(w, "User-agent: %s\n", userAgent)
+	}
+	for _, p := range privatePaths {
+		if p == "/" {
+			// Protected blog on the root path
+			_, _ = fmt.Fprint(w, "Disallow: /\n")
+			continue
+		}
+		// Only the path itself and its subpaths, not posts with the same prefix
+		_, _ = fmt.Fprintf(w, "Disallow: %s$\nDisallow: %s/\n", p, p)
+	}
+	for _, rule := range rules {
+		for _, p := range rule.Disallow {
+			_, _ = fmt.Fprintf(w, "Disallow: %s\n", p)
+		}
+		for _, p := range rule.Allow {
+			_, _ = fmt.Fprintf(w, "Allow: %s\n", p)
+		}
+	}
+	if allowAll && !lo.Contains(privatePaths, "/") {
+		_, _ = fmt.Fprint(w, "Allow: /\n")
+	}
+	_, _ = fmt.Fprint(w, "\n")
+}
+
+// Paths that are only useful for the logged in user or for clients
+func (a *goBlog) robotsPrivatePaths() []string {
+	paths := []string{
+		"/login",
+		"/logout",
+		"/api",
+		micropubPath,
+		indieAuthPath,
+		mastodonOAuthPath,
+		notificationsPath,
+		webmentionPath,
+		timelinePath,
+		feedReaderPath,
+		syndicationPath,
+		previewFlagsPath,
+		analyticsPath,
+		cachePath,
+		metricsPath,
+	}
+	for _, blog := range sortedStrings(lo.Keys(a.cfg.Blogs)) {
+		bc := a.cfg.Blogs[blog]
+		if bc.isProtected() {
+			// Protected blogs shouldn't be indexed at all
+			paths = append(paths, bc.getRelativePath(""))
+			continue
+		}
+		paths = append(paths, bc.getRelativePath(editorPath), bc.getRelativePath(settingsPath))
 	}
+	return lo.Uniq(paths)
 }
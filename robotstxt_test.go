@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,7 +23,33 @@ func Test_robotsTXT(t *testing.T) {
 	req := httptest.NewRequest("GET", "/robots.txt", nil)
 	app.serveRobotsTXT(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.Equal(t, "User-agent: *\nAllow: /\n\nSitemap: https://example.com/sitemap.xml\n", rec.Body.String())
+	body := rec.Body.String()
+	assert.True(t, strings.HasPrefix(body, "User-agent: *\nDisallow: /login$\nDisallow: /login/\n"))
+	assert.Contains(t, body, "Disallow: /micropub$\nDisallow: /micropub/\n")
+	assert.True(t, strings.HasSuffix(body, "Allow: /\n\nSitemap: https://example.com/sitemap.xml\n"))
+	assert.NotContains(t, body, "GPTBot")
+
+	// Custom rules and AI crawlers
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {Path: "/en"},
+	}
+	app.cfg.Robots = &configRobots{
+		BlockAI: true,
+		Rules: []*configRobotsRule{
+			{UserAgents: []string{"*"}, Disallow: []string{"/drafts/"}},
+			{UserAgents: []string{"ExampleBot", "OtherBot"}, Disallow: []string{"/"}, Allow: []string{"/public/"}},
+		},
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/robots.txt", nil)
+	app.serveRobotsTXT(rec, req)
+	body = rec.Body.String()
+	assert.True(t, strings.HasPrefix(body, "User-agent: ExampleBot\nUser-agent: OtherBot\nDisallow: /login$\n"))
+	assert.Contains(t, body, "Disallow: /en/editor$\nDisallow: /en/editor/\nDisallow: /en/settings$\nDisallow: /en/settings/\nDisallow: /\nAllow: /public/\n\n")
+	assert.Contains(t, body, "User-agent: GPTBot\nUser-agent: ImagesiftBot\n")
+	assert.Contains(t, body, "User-agent: YouBot\nDisallow: /\n\nUser-agent: *\n")
+	assert.True(t, strings.HasSuffix(body, "Disallow: /drafts/\nAllow: /\n\nSitemap: https://example.com/sitemap.xml\nSitemap: https://example.com/en/sitemap-blog.xml\n"))
 
 	app.cfg.PrivateMode = &configPrivateMode{
 		Enabled: true,
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// Policy for HTML from remote sources that gets rendered (like ActivityPub replies stored as comments).
+// Only basic formatting and links are allowed, code blocks and images need to be enabled in the config.
+// Nofollow is added per link afterwards, because it depends on the link policy.
+func remoteContentPolicy(sc *configSanitization, lc *configLinks) *bluemonday.Policy {
+	p := bluemonday.StrictPolicy()
+	p.AllowElements("p", "br", "blockquote", "ul", "ol", "li", "em", "strong", "b", "i", "del", "s")
+	// Links
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https")
+	p.RequireParseableURLs(true)
+	if lc.newTab() {
+		p.AddTargetBlankToFullyQualifiedLinks(true)
+	}
+	if sc == nil {
+		return p
+	}
+	// Code blocks
+	if sc.AllowCode {
+		p.AllowElements("pre", "code")
+	}
+	// Images
+	if sc.AllowImages {
+		p.AllowAttrs("src", "alt", "title").OnElements("img")
+	}
+	return p
+}
+
+// Sanitize HTML from remote sources with the configured policy
+func (a *goBlog) sanitizeRemoteHTML(s string) string {
+	sc := a.cfg.Sanitization
+	var policy *bluemonday.Policy
+	if sc == nil || sc.policy == nil {
+		policy = remoteContentPolicy(sc, a.cfg.Links)
+	} else {
+		policy = sc.policy
+	}
+	return a.addUgcNofollow(policy.Sanitize(s))
+}
+
+// Add nofollow to the links of sanitized HTML that aren't followed according to the link policy
+func (a *goBlog) addUgcNofollow(s string) string {
+	if !strings.Contains(s, "<a ") {
+		return s
+	}
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		if z.Next() == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return b.String()
+			}
+			// Shouldn't happen with sanitized HTML, keep it unchanged
+			return s
+		}
+		// Always write the token again, because it's unescaped when reading it
+		t := z.Token()
+		if t.Type == html.StartTagToken && t.Data == "a" {
+			href, relIndex := "", -1
+			for i, attr := range t.Attr {
+				switch attr.Key {
+				case "href":
+					href = attr.Val
+				case "rel":
+					relIndex = i
+				}
+			}
+			if href != "" && a.ugcNofollow(href) {
+				if relIndex >= 0 {
+					t.Attr[relIndex].Val = "nofollow " + t.Attr[relIndex].Val
+				} else {
+					t.Attr = append(t.Attr, html.Attribute{Key: "rel", Val: "nofollow"})
+				}
+			}
+		}
+		_, _ = b.WriteString(t.String())
+	}
+}
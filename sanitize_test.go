@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sanitizeRemoteHTML(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+
+	remote := `<p>Hello <strong>world</strong> <a href="https://example.net">link</a></p><script>alert(1)</script><pre><code>x := 1</code></pre><img src="https://example.net/a.png" alt="A"><a href="javascript:alert(1)">bad</a>`
+
+	// Strict by default
+	sanitized := app.sanitizeRemoteHTML(remote)
+	assert.Contains(t, sanitized, "<p>Hello <strong>world</strong>")
+	assert.Contains(t, sanitized, `<a href="https://example.net" target="_blank" rel="nofollow noopener">link</a>`)
+	assert.NotContains(t, sanitized, "<script")
+	assert.NotContains(t, sanitized, "<pre>")
+	assert.NotContains(t, sanitized, "<img")
+	assert.NotContains(t, sanitized, "javascript:")
+
+	// Decoded text that looks like HTML is escaped
+	assert.Equal(t, "&lt;b&gt;", app.sanitizeRemoteHTML("&lt;b&gt;"))
+
+	// Allow code blocks and images
+	app.cfg.Sanitization.policy = remoteContentPolicy(&configSanitization{AllowCode: true, AllowImages: true}, app.cfg.Links)
+	sanitized = app.sanitizeRemoteHTML(remote)
+	assert.Contains(t, sanitized, "<pre><code>x := 1</code></pre>")
+	assert.Contains(t, sanitized, `<img src="https://example.net/a.png" alt="A">`)
+	assert.NotContains(t, sanitized, "<script")
+
+	// Nofollow per link with the link policy
+	app.cfg.Links = &configLinks{UgcNofollow: true, FollowDomains: []string{"example.org"}}
+	app.cfg.Sanitization.policy = remoteContentPolicy(app.cfg.Sanitization, app.cfg.Links)
+	sanitized = app.sanitizeRemoteHTML(`<a href="https://example.net/a">a</a> <a href="https://blog.example.org/b" rel="me">b</a>`)
+	assert.Equal(t, `<a href="https://example.net/a" rel="nofollow">a</a> <a href="https://blog.example.org/b">b</a>`, sanitized)
+
+	// Links to the blog itself are followed
+	sanitized = app.sanitizeRemoteHTML(`<a href="` + app.getFullAddress("/a") + `">a</a>`)
+	assert.Equal(t, `<a href="`+app.getFullAddress("/a")+`">a</a>`, sanitized)
+
+	// Escaped text stays escaped when adding nofollow
+	sanitized = app.sanitizeRemoteHTML(`<p>&lt;script&gt;alert(1)&lt;/script&gt; <a href="https://example.net/a" title="&quot;&gt;">a</a></p>`)
+	assert.Equal(t, `<p>&lt;script&gt;alert(1)&lt;/script&gt; <a href="https://example.net/a" rel="nofollow">a</a></p>`, sanitized)
+	assert.NotContains(t, sanitized, "<script")
+}
+
+func Test_commentSanitization(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	require.NoError(t, app.initConfig(false))
+	bc := app.cfg.Blogs[app.cfg.DefaultBlog]
+
+	_, _, err := app.createComment(bc, "http://localhost:8080/abc", `<p>Reply with <code>code</code></p><script>alert(1)</script>`, "Name", "", "https://example.org/1")
+	require.NoError(t, err)
+
+	_, _, err = app.createComment(bc, "http://localhost:8080/abc", `<script>alert(1)</script>`, "Name", "", "https://example.org/2")
+	assert.Error(t, err)
+
+	comments, err := app.db.getComments(&commentsRequestConfig{})
+	require.NoError(t, err)
+	if assert.Len(t, comments, 1) {
+		assert.Equal(t, "<p>Reply with code</p>", comments[0].Comment)
+	}
+}
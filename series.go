@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/builderpool"
+	"go.goblog.app/app/pkgs/htmlbuilder"
+)
+
+// Posts with the same "series" parameter are parts of a series, ordered by their published date
+
+const (
+	seriesParameter = "series"
+	seriesPath      = "/series"
+)
+
+func (blog *configBlog) seriesPath(series string) string {
+	return blog.getRelativePath(seriesPath + "/" + urlize(series))
+}
+
+// Landing page of a series, lists the parts in order (the feed has the newest parts first)
+func (a *goBlog) serveSeries(w http.ResponseWriter, r *http.Request) {
+	blog, bc := a.getBlog(r)
+	seriesParam := chi.URLParam(r, "series")
+	if seriesParam == "" {
+		a.serve404(w, r)
+		return
+	}
+	// Get the name from the DB
+	row, err := a.db.QueryRow(
+		"select value from post_parameters where parameter = @param and urlize(value) = @series and path in (select path from posts where blog = @blog) limit 1",
+		sql.Named("param", seriesParameter), sql.Named("series", seriesParam), sql.Named("blog", blog),
+	)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var series string
+	if err = row.Scan(&series); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			a.serve404(w, r)
+			return
+		}
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.serveIndex(w, r.WithContext(context.WithValue(r.Context(), indexConfigKey, &indexConfig{
+		path:           bc.seriesPath(series),
+		title:          fmt.Sprintf("%s: %s", a.ts.GetTemplateStringVariant(bc.Lang, "series"), series),
+		parameter:      seriesParameter,
+		parameterValue: series,
+		sort:           lo.If(chi.URLParam(r, "feed") == "", postsSortOldest).Else(postsSortNewest),
+	})))
+}
+
+// Navigation to the series and the previous and next part
+func (a *goBlog) renderPostSeries(hb *htmlbuilder.HtmlBuilder, p *post, b *configBlog) {
+	if b == nil || p == nil {
+		return
+	}
+	series := p.firstParameter(seriesParameter)
+	if series == "" {
+		return
+	}
+	parts, err := a.getPosts(&postsRequestConfig{
+		blog:                   p.Blog,
+		parameter:              seriesParameter,
+		parameterValue:         series,
+		status:                 []postStatus{statusPublished},
+		visibility:             []postVisibility{visibilityPublic},
+		withinVisibilityWindow: true,
+		sort:                   postsSortOldest,
+		withOnlyParameters:     []string{"title"},
+	})
+	if err != nil {
+		return
+	}
+	_, index, _ := lo.FindIndexOf(parts, func(part *post) bool { return part.Path == p.Path })
+	hb.WriteElementOpen("div", "class", "p border-top border-bottom")
+	hb.WriteElementOpen("p")
+	hb.WriteElementOpen("strong")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "series"))
+	hb.WriteEscaped(": ")
+	hb.WriteElementOpen("a", "href", b.seriesPath(series))
+	hb.WriteEscaped(series)
+	hb.WriteElementClose("a")
+	hb.WriteElementClose("strong")
+	if index >= 0 {
+		hb.WriteEscaped(" (")
+		hb.WriteEscaped(fmt.Sprintf(a.ts.GetTemplateStringVariant(b.Lang, "seriespart"), index+1, len(parts)))
+		hb.WriteEscaped(")")
+	}
+	hb.WriteElementClose("p")
+	if index >= 0 && len(parts) > 1 {
+		hb.WriteElementOpen("p")
+		if index > 0 {
+			a.renderSeriesPartLink(hb, b, parts[index-1], index, true)
+		}
+		if index > 0 && index < len(parts)-1 {
+			hb.WriteEscaped(" · ")
+		}
+		if index < len(parts)-1 {
+			a.renderSeriesPartLink(hb, b, parts[index+1], index+2, false)
+		}
+		hb.WriteElementClose("p")
+	}
+	hb.WriteElementClose("div")
+}
+
+func (a *goBlog) renderSeriesPartLink(hb *htmlbuilder.HtmlBuilder, b *configBlog, part *post, number int, previous bool) {
+	title := defaultIfEmpty(part.RenderedTitle, fmt.Sprintf(a.ts.GetTemplateStringVariant(b.Lang, "seriespartnumber"), number))
+	if previous {
+		hb.WriteElementOpen("a", "href", part.Path, "rel", "prev")
+		hb.WriteEscaped("← " + a.ts.GetTemplateStringVariant(b.Lang, "previouspart") + ": " + title)
+	} else {
+		hb.WriteElementOpen("a", "href", part.Path, "rel", "next")
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "nextpart") + ": " + title + " →")
+	}
+	hb.WriteElementClose("a")
+}
+
+func (db *database) getPostSeries(path string) string {
+	if path == "" {
+		return ""
+	}
+	row, err := db.QueryRow("select value from post_parameters where path = @path and parameter = @param limit 1", sql.Named("path", path), sql.Named("param", seriesParameter))
+	if err != nil {
+		return ""
+	}
+	var series string
+	_ = row.Scan(&series)
+	return series
+}
+
+// Paths of all parts of the series, their navigation changes when a part changes
+func (db *database) getSeriesPostPaths(series ...string) []string {
+	series = lo.Uniq(lo.Compact(series))
+	if len(series) == 0 {
+		return nil
+	}
+	query := builderpool.Get()
+	defer builderpool.Put(query)
+	query.WriteString("select distinct path from post_parameters where parameter = @param and value in (")
+	args := []any{sql.Named("param", seriesParameter)}
+	for i, s := range series {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		named := "series" + strconv.Itoa(i)
+		query.WriteString("@" + named)
+		args = append(args, sql.Named(named, s))
+	}
+	query.WriteString(")")
+	rows, err := db.Query(query.String(), args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var path string
+		if rows.Scan(&path) == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_series(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/part-2", Published: "2023-01-02T10:00:00Z", Content: "Second", Parameters: map[string][]string{"title": {"Second part"}, "series": {"Go Basics"}}},
+		{Path: "/part-1", Published: "2023-01-01T10:00:00Z", Content: "First", Parameters: map[string][]string{"title": {"First part"}, "series": {"Go Basics"}}},
+		{Path: "/other", Published: "2023-01-03T10:00:00Z", Content: "Other"},
+	} {
+		p.Section, p.Status, p.Visibility = "posts", statusPublished, visibilityPublic
+		require.NoError(t, app.createPost(p))
+	}
+
+	client := newHandlerClient(app.d)
+	get := func(path string) (int, string) {
+		var body string
+		var status int
+		_ = requests.URL("http://localhost:8080" + path).Client(client).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			ToString(&body).
+			Fetch(context.Background())
+		return status, body
+	}
+
+	// Navigation on the parts
+	_, body := get("/part-1")
+	assert.Contains(t, body, "href=/series/go-basics")
+	assert.Contains(t, body, "part 1 of 2")
+	assert.Contains(t, body, "rel=next")
+	assert.Contains(t, body, "Next part: Second part →")
+	assert.NotContains(t, body, "rel=prev")
+	_, body = get("/part-2")
+	assert.Contains(t, body, "part 2 of 2")
+	assert.Contains(t, body, "href=/part-1 rel=prev")
+	assert.NotContains(t, body, "rel=next")
+	_, body = get("/other")
+	assert.NotContains(t, body, "/series/")
+
+	// Landing page lists the parts in order
+	status, body := get("/series/go-basics")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Series: Go Basics")
+	assert.Less(t, strings.Index(body, "First part"), strings.Index(body, "Second part"))
+	assert.NotContains(t, body, "Other")
+
+	// Feed has the newest part first
+	status, body = get("/series/go-basics.rss")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Less(t, strings.Index(body, "Second part"), strings.Index(body, "First part"))
+
+	status, _ = get("/series/unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// A new part updates the cached navigation of the previous parts
+	require.NoError(t, app.createPost(&post{
+		Path: "/part-3", Section: "posts", Status: statusPublished, Visibility: visibilityPublic,
+		Published: "2023-01-04T10:00:00Z", Content: "Third",
+		Parameters: map[string][]string{"title": {"Third part"}, "series": {"Go Basics"}},
+	}))
+	_, body = get("/part-2")
+	assert.Contains(t, body, "part 2 of 3")
+	assert.Contains(t, body, "href=/part-3 rel=next")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"sort"
+	"strings"
 
 	"github.com/samber/lo"
 )
@@ -15,6 +16,22 @@ func (a *goBlog) serveSettings(w http.ResponseWriter, r *http.Request) {
 	sections := lo.Values(bc.Sections)
 	sort.Slice(sections, func(i, j int) bool { return sections[i].Name < sections[j].Name })
 
+	identityStatuses, err := a.db.getRelMeStatuses()
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Started two-factor authentication setup
+	var totpURL, totpQRCode string
+	if pending := a.pendingTOTPSecret(r); pending != "" && a.cfg.User.TOTP == "" {
+		totpURL, totpQRCode, err = a.totpEnrollmentData(pending)
+		if err != nil {
+			a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	a.render(w, r, a.renderSettings, &renderData{
 		Data: &settingsRenderData{
 			blog:                  blog,
@@ -27,8 +44,15 @@ func (a *goBlog) serveSettings(w http.ResponseWriter, r *http.Request) {
 			addReplyContext:       bc.addReplyContext,
 			addLikeTitle:          bc.addLikeTitle,
 			addLikeContext:        bc.addLikeContext,
+			addRepostTitle:        bc.addRepostTitle,
+			addRepostContext:      bc.addRepostContext,
 			userNick:              a.cfg.User.Nick,
 			userName:              a.cfg.User.Name,
+			identities:            a.cfg.User.Identities,
+			identityStatuses:      identityStatuses,
+			totpEnabled:           a.cfg.User.TOTP != "",
+			totpURL:               totpURL,
+			totpQRCode:            totpQRCode,
 		},
 	})
 }
@@ -106,14 +130,26 @@ func (a *goBlog) settingsUpdateSection(w http.ResponseWriter, r *http.Request) {
 	sectionPathTemplate := r.FormValue("sectionpathtemplate")
 	sectionShowFull := r.FormValue("sectionshowfull") == "on"
 	sectionHideOnStart := r.FormValue("sectionhideonstart") == "on"
+	sectionContentWarning := strings.TrimSpace(r.FormValue("sectioncontentwarning"))
 	// Create section
 	section := &configSection{
-		Name:         sectionName,
-		Title:        sectionTitle,
-		Description:  sectionDescription,
-		PathTemplate: sectionPathTemplate,
-		ShowFull:     sectionShowFull,
-		HideOnStart:  sectionHideOnStart,
+		Name:           sectionName,
+		Title:          sectionTitle,
+		Description:    sectionDescription,
+		PathTemplate:   sectionPathTemplate,
+		ShowFull:       sectionShowFull,
+		HideOnStart:    sectionHideOnStart,
+		ContentWarning: sectionContentWarning,
+	}
+	if section.PathTemplate != "" {
+		if err := a.checkPathTemplate(blog, section); err != nil {
+			a.serveError(w, r, "Invalid path template: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	oldPathTemplate := ""
+	if old, ok := bc.Sections[sectionName]; ok {
+		oldPathTemplate = old.PathTemplate
 	}
 	err := a.saveSection(blog, section)
 	if err != nil {
@@ -126,8 +162,19 @@ func (a *goBlog) settingsUpdateSection(w http.ResponseWriter, r *http.Request) {
 		a.serveError(w, r, "Failed to reload section configuration from the database", http.StatusInternalServerError)
 		return
 	}
+	// Move existing posts to the new path template, the old paths redirect
+	if r.FormValue("sectionmoveposts") == "on" && oldPathTemplate != section.PathTemplate {
+		if _, err = a.movePostsToPathTemplate(blog, sectionName, oldPathTemplate, section.PathTemplate); err != nil {
+			a.serveError(w, r, "Failed to move posts to the new path template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 	a.reloadRouter()
 	a.cache.purge()
+	if a.asObjectCache != nil {
+		// Content warning might have changed
+		a.asObjectCache.clear()
+	}
 	http.Redirect(w, r, bc.getRelativePath(settingsPath), http.StatusFound)
 }
 
@@ -211,6 +258,22 @@ func (a *goBlog) settingsAddLikeContext() http.HandlerFunc {
 	})
 }
 
+const settingsAddRepostTitlePath = "/reposttitle"
+
+func (a *goBlog) settingsAddRepostTitle() http.HandlerFunc {
+	return a.booleanBlogSettingHandler(addRepostTitleSetting, func(cb *configBlog, b bool) {
+		cb.addRepostTitle = b
+	})
+}
+
+const settingsAddRepostContextPath = "/repostcontext"
+
+func (a *goBlog) settingsAddRepostContext() http.HandlerFunc {
+	return a.booleanBlogSettingHandler(addRepostContextSetting, func(cb *configBlog, b bool) {
+		cb.addRepostContext = b
+	})
+}
+
 const settingsUpdateUserPath = "/user"
 
 func (a *goBlog) settingsUpdateUser(w http.ResponseWriter, r *http.Request) {
@@ -238,3 +301,35 @@ func (a *goBlog) settingsUpdateUser(w http.ResponseWriter, r *http.Request) {
 	a.cache.purge()
 	http.Redirect(w, r, bc.getRelativePath(settingsPath), http.StatusFound)
 }
+
+const (
+	settingsUpdateIdentitiesPath = "/identities"
+	settingsVerifyIdentitiesPath = "/verifyidentities"
+)
+
+func (a *goBlog) settingsUpdateIdentities(w http.ResponseWriter, r *http.Request) {
+	_, bc := a.getBlog(r)
+	// Read values, one identity per line
+	identities := strings.Split(strings.ReplaceAll(r.FormValue(identitiesSetting), "\r\n", "\n"), "\n")
+	for _, identity := range identities {
+		if identity = strings.TrimSpace(identity); identity != "" && !isAbsoluteURL(identity) {
+			a.serveError(w, r, "Identities must be absolute URLs", http.StatusBadRequest)
+			return
+		}
+	}
+	// Update
+	if err := a.saveIdentities(identities); err != nil {
+		a.serveError(w, r, "Failed to update identities in database", http.StatusInternalServerError)
+		return
+	}
+	a.cache.purge()
+	// Check new identities in the background
+	go a.verifyIdentities(false)
+	http.Redirect(w, r, bc.getRelativePath(settingsPath), http.StatusFound)
+}
+
+func (a *goBlog) settingsVerifyIdentities(w http.ResponseWriter, r *http.Request) {
+	_, bc := a.getBlog(r)
+	a.verifyIdentities(true)
+	http.Redirect(w, r, bc.getRelativePath(settingsPath), http.StatusFound)
+}
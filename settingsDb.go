@@ -23,6 +23,8 @@ const (
 	addReplyContextSetting       = "addreplycontext"
 	addLikeTitleSetting          = "addliketitle"
 	addLikeContextSetting        = "addlikecontext"
+	addRepostTitleSetting        = "addreposttitle"
+	addRepostContextSetting      = "addrepostcontext"
 )
 
 func (a *goBlog) getSettingValue(name string) (string, error) {
@@ -41,6 +43,16 @@ func (a *goBlog) getSettingValue(name string) (string, error) {
 	return value, nil
 }
 
+func (a *goBlog) settingExists(name string) (bool, error) {
+	row, err := a.db.QueryRow("select exists(select 1 from settings where name = @name)", sql.Named("name", name))
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = row.Scan(&exists)
+	return exists, err
+}
+
 func (a *goBlog) getBooleanSettingValue(name string, defaultValue bool) (bool, error) {
 	stringValue, err := a.getSettingValue(name)
 	if err != nil {
@@ -78,14 +90,14 @@ func (a *goBlog) loadSections() error {
 }
 
 func (a *goBlog) getSections(blog string) (map[string]*configSection, error) {
-	rows, err := a.db.Query("select name, title, description, pathtemplate, showfull, hideonstart from sections where blog = @blog", sql.Named("blog", blog))
+	rows, err := a.db.Query("select name, title, description, pathtemplate, showfull, hideonstart, contentwarning from sections where blog = @blog", sql.Named("blog", blog))
 	if err != nil {
 		return nil, err
 	}
 	sections := map[string]*configSection{}
 	for rows.Next() {
 		section := &configSection{}
-		err = rows.Scan(&section.Name, &section.Title, &section.Description, &section.PathTemplate, &section.ShowFull, &section.HideOnStart)
+		err = rows.Scan(&section.Name, &section.Title, &section.Description, &section.PathTemplate, &section.ShowFull, &section.HideOnStart, &section.ContentWarning)
 		if err != nil {
 			return nil, err
 		}
@@ -109,8 +121,8 @@ func (a *goBlog) saveAllSections() error {
 func (a *goBlog) saveSection(blog string, section *configSection) error {
 	_, err := a.db.Exec(
 		`
-		insert into sections (blog, name, title, description, pathtemplate, showfull, hideonstart) values (@blog, @name, @title, @description, @pathtemplate, @showfull, @hideonstart)
-		on conflict (blog, name) do update set title = @title2, description = @description2, pathtemplate = @pathtemplate2, showfull = @showfull2, hideonstart = @hideonstart2
+		insert into sections (blog, name, title, description, pathtemplate, showfull, hideonstart, contentwarning) values (@blog, @name, @title, @description, @pathtemplate, @showfull, @hideonstart, @contentwarning)
+		on conflict (blog, name) do update set title = @title2, description = @description2, pathtemplate = @pathtemplate2, showfull = @showfull2, hideonstart = @hideonstart2, contentwarning = @contentwarning2
 		`,
 		sql.Named("blog", blog),
 		sql.Named("name", section.Name),
@@ -119,11 +131,13 @@ func (a *goBlog) saveSection(blog string, section *configSection) error {
 		sql.Named("pathtemplate", section.PathTemplate),
 		sql.Named("showfull", section.ShowFull),
 		sql.Named("hideonstart", section.HideOnStart),
+		sql.Named("contentwarning", section.ContentWarning),
 		sql.Named("title2", section.Title),
 		sql.Named("description2", section.Description),
 		sql.Named("pathtemplate2", section.PathTemplate),
 		sql.Named("showfull2", section.ShowFull),
 		sql.Named("hideonstart2", section.HideOnStart),
+		sql.Named("contentwarning2", section.ContentWarning),
 	)
 	return err
 }
@@ -34,8 +34,9 @@ func Test_settingsDb_sections(t *testing.T) {
 
 	// New section
 	section = &configSection{
-		Name:  "new",
-		Title: "New section",
+		Name:           "new",
+		Title:          "New section",
+		ContentWarning: "Politics",
 	}
 	err = app.saveSection(app.cfg.DefaultBlog, section)
 	require.NoError(t, err)
@@ -44,6 +45,7 @@ func Test_settingsDb_sections(t *testing.T) {
 	sections, err = app.getSections(app.cfg.DefaultBlog)
 	require.NoError(t, err)
 	require.Len(t, lo.Values(sections), 2)
+	require.Equal(t, "Politics", sections["new"].ContentWarning)
 
 	// Delete section
 	err = app.deleteSection(app.cfg.DefaultBlog, "new")
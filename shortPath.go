@@ -1,10 +1,33 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/mattn/go-sqlite3"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+// Short paths: every post gets a short code (the hexadecimal ID in the shortpath table or a custom code
+// set with the post parameter), the short path is the code with the configured prefix.
+
+const (
+	defaultShortPath = "/s"
+	shortPathsPath   = "/api/shortpaths"
+)
+
+// Post parameter with a custom code for the short URL
+const shortCodeParameter = "shortcode"
+
+var (
+	shortCodeRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	shortIdRegex   = regexp.MustCompile(`^[0-9a-f]+$`)
 )
 
 func (db *database) shortenPath(p string) (string, error) {
@@ -30,8 +53,8 @@ func (db *database) shortenPath(p string) (string, error) {
 				return nil, err
 			}
 		}
-		// Query short path
-		row, err := db.QueryRow("select printf('/s/%x', id) from shortpath where path = @path", sql.Named("path", p))
+		// Query short code
+		row, err := db.QueryRow("select coalesce(code, printf('%x', id)) from shortpath where path = @path", sql.Named("path", p))
 		if err != nil {
 			return nil, err
 		}
@@ -49,3 +72,158 @@ func (db *database) shortenPath(p string) (string, error) {
 	}
 	return spi.(string), nil
 }
+
+// Short path with the configured prefix
+func (a *goBlog) shortenPath(p string) (string, error) {
+	code, err := a.db.shortenPath(p)
+	if err != nil {
+		return "", err
+	}
+	return a.shortPathPrefix() + "/" + code, nil
+}
+
+func (a *goBlog) shortPathPrefix() string {
+	return defaultIfEmpty(a.cfg.Server.ShortPath, defaultShortPath)
+}
+
+// Code of the short path, empty if it's no short path
+func (a *goBlog) shortCodeFromPath(p string) string {
+	code, found := strings.CutPrefix(p, a.shortPathPrefix()+"/")
+	if !found || !shortCodeRegex.MatchString(code) {
+		return ""
+	}
+	return code
+}
+
+// Custom codes can't look like the generated codes
+func checkShortCode(code string) error {
+	if code == "" {
+		return nil
+	}
+	if !shortCodeRegex.MatchString(code) {
+		return errors.New("short code can only contain letters, numbers, - and _")
+	}
+	if shortIdRegex.MatchString(code) {
+		return errors.New("short code must contain other characters than 0-9 and a-f")
+	}
+	return nil
+}
+
+// Check that the custom code isn't used for other paths than the current and old one of the post
+func (db *database) shortCodeAvailable(code, p, oldPath string) (bool, error) {
+	row, err := db.QueryRow(
+		"select exists(select 1 from shortpath where code = @code and path not in (@path, @oldpath))",
+		sql.Named("code", code), sql.Named("path", p), sql.Named("oldpath", oldPath),
+	)
+	if err != nil {
+		return false, err
+	}
+	var used bool
+	if err = row.Scan(&used); err != nil {
+		return false, err
+	}
+	return !used, nil
+}
+
+// Set the custom code for the (already shortened) path, a path with the same code loses it
+func (db *database) setShortCode(p, code string) error {
+	err := db.transaction(context.Background(), func(tx *sql.Tx) error {
+		if _, err := tx.Exec("update shortpath set code = null where code = ?", code); err != nil {
+			return err
+		}
+		_, err := tx.Exec("update shortpath set code = ? where path = ?", code, p)
+		return err
+	})
+	// Codes of other paths could have changed
+	db.spc.Clear()
+	return err
+}
+
+// Check the custom short code of a post, the old path of the post may use it
+func (a *goBlog) checkPostShortCode(p *post, oldPath string) error {
+	code := p.firstParameter(shortCodeParameter)
+	if err := checkShortCode(code); err != nil || code == "" {
+		return err
+	}
+	available, err := a.db.shortCodeAvailable(code, p.Path, oldPath)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return errors.New("short code is already used")
+	}
+	return nil
+}
+
+// Apply the custom short code of a saved post, if it changed
+func (a *goBlog) updatePostShortCode(p *post) error {
+	code := p.firstParameter(shortCodeParameter)
+	if code == "" {
+		return a.db.removeShortCode(p.Path)
+	}
+	current, err := a.db.shortenPath(p.Path)
+	if err != nil || current == code {
+		return err
+	}
+	return a.db.setShortCode(p.Path, code)
+}
+
+func (db *database) removeShortCode(p string) error {
+	res, err := db.Exec("update shortpath set code = null where path = @path and code is not null", sql.Named("path", p))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		db.spc.Del(p)
+	}
+	return nil
+}
+
+func (db *database) countShortPathClick(p string) error {
+	_, err := db.Exec("update shortpath set clicks = clicks + 1 where path = @path", sql.Named("path", p))
+	return err
+}
+
+type shortPathStats struct {
+	Path      string `json:"path"`
+	Code      string `json:"code"`
+	ShortPath string `json:"shortPath"`
+	Clicks    int    `json:"clicks"`
+}
+
+// Short path mappings, most clicked first
+func (db *database) getShortPathStats() ([]*shortPathStats, error) {
+	rows, err := db.Query("select path, coalesce(code, printf('%x', id)), clicks from shortpath order by clicks desc, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var stats []*shortPathStats
+	for rows.Next() {
+		s := &shortPathStats{}
+		if err = rows.Scan(&s.Path, &s.Code, &s.Clicks); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// Authenticated endpoint with the short paths and their clicks
+func (a *goBlog) serveShortPaths(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.db.getShortPathStats()
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	prefix := a.shortPathPrefix()
+	for _, s := range stats {
+		s.ShortPath = prefix + "/" + s.Code
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(json.NewEncoder(pw).Encode(stats))
+	}()
+	w.Header().Set(contentType, contenttype.JSONUTF8)
+	_ = pr.CloseWithError(a.min.Get().Minify(contenttype.JSON, w, pr))
+}
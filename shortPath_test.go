@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/carlmjohnson/requests"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -15,18 +20,18 @@ func Test_shortenPath(t *testing.T) {
 
 	db := app.db
 
-	res1, err := db.shortenPath("/a")
+	res1, err := app.shortenPath("/a")
 	require.NoError(t, err)
 
 	db.spc.Del("/a")
 
-	res2, err := db.shortenPath("/a")
+	res2, err := app.shortenPath("/a")
 	require.NoError(t, err)
 
-	res3, err := db.shortenPath("/b")
+	res3, err := app.shortenPath("/b")
 	require.NoError(t, err)
 
-	res4, err := db.shortenPath("/a")
+	res4, err := app.shortenPath("/a")
 	require.NoError(t, err)
 
 	assert.Equal(t, res1, res2)
@@ -41,7 +46,101 @@ func Test_shortenPath(t *testing.T) {
 	db.spc.Del("/a")
 	_, _ = db.Exec("delete from shortpath where id = 1")
 
-	res5, err := db.shortenPath("/c")
+	res5, err := app.shortenPath("/c")
 	require.NoError(t, err)
 	assert.Equal(t, "/s/1", res5)
 }
+
+func Test_shortCodes(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Server.ShortPath = "/x/"
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+	app.d = app.buildRouter()
+
+	assert.Equal(t, "/x", app.shortPathPrefix())
+
+	require.NoError(t, app.createPost(&post{
+		Path:       "/posts/one",
+		Section:    "posts",
+		Parameters: map[string][]string{shortCodeParameter: {"my-post"}},
+		Content:    "One",
+	}))
+	require.NoError(t, app.createPost(&post{
+		Path:    "/posts/two",
+		Section: "posts",
+		Content: "Two",
+	}))
+
+	sp, err := app.shortenPath("/posts/one")
+	require.NoError(t, err)
+	assert.Equal(t, "/x/my-post", sp)
+	sp, err = app.shortenPath("/posts/two")
+	require.NoError(t, err)
+	assert.Equal(t, "/x/2", sp)
+
+	// Invalid and used codes
+	assert.Error(t, app.createPost(&post{Path: "/posts/three", Section: "posts", Parameters: map[string][]string{shortCodeParameter: {"cafe"}}}))
+	assert.Error(t, app.createPost(&post{Path: "/posts/three", Section: "posts", Parameters: map[string][]string{shortCodeParameter: {"no spaces"}}}))
+	assert.Error(t, app.createPost(&post{Path: "/posts/three", Section: "posts", Parameters: map[string][]string{shortCodeParameter: {"my-post"}}}))
+
+	// Redirects and clicks
+	for _, tc := range []struct {
+		path, location string
+	}{
+		{"/x/my-post", "/posts/one"},
+		{"/x/1", "/posts/one"},
+		{"/x/2", "/posts/two"},
+		{"/x/my-post", "/posts/one"},
+	} {
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+tc.path, nil))
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code, tc.path)
+		assert.Equal(t, tc.location, rec.Header().Get("Location"), tc.path)
+	}
+	rec := httptest.NewRecorder()
+	app.d.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/x/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var stats []*shortPathStats
+	err = requests.URL("http://localhost:8080"+shortPathsPath).Client(newHandlerClient(app.d)).
+		BasicAuth("test", "test").
+		ToJSON(&stats).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, &shortPathStats{Path: "/posts/one", Code: "my-post", ShortPath: "/x/my-post", Clicks: 3}, stats[0])
+	assert.Equal(t, &shortPathStats{Path: "/posts/two", Code: "2", ShortPath: "/x/2", Clicks: 1}, stats[1])
+
+	// Change the path and the code
+	p, err := app.getPost("/posts/one")
+	require.NoError(t, err)
+	p.Path = "/posts/first"
+	p.Parameters[shortCodeParameter] = []string{"first"}
+	require.NoError(t, app.replacePost(p, "/posts/one", p.Status, p.Visibility))
+	sp, err = app.shortenPath("/posts/first")
+	require.NoError(t, err)
+	assert.Equal(t, "/x/first", sp)
+	// The old code still redirects to the old path (and that to the new one)
+	sp, err = app.shortenPath("/posts/one")
+	require.NoError(t, err)
+	assert.Equal(t, "/x/my-post", sp)
+
+	// Remove the code
+	p, err = app.getPost("/posts/first")
+	require.NoError(t, err)
+	delete(p.Parameters, shortCodeParameter)
+	require.NoError(t, app.replacePost(p, p.Path, p.Status, p.Visibility))
+	sp, err = app.shortenPath("/posts/first")
+	require.NoError(t, err)
+	assert.Regexp(t, `^/x/[0-9a-f]+$`, sp)
+}
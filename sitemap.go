@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -8,235 +9,431 @@ import (
 	"path"
 	"time"
 
-	"github.com/araddon/dateparse"
-	"github.com/snabb/sitemap"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
 	"go.goblog.app/app/pkgs/contenttype"
 )
 
 const (
-	sitemapPath             = "/sitemap.xml"
-	sitemapBlogPath         = "/sitemap-blog.xml"
-	sitemapBlogFeaturesPath = "/sitemap-blog-features.xml"
-	sitemapBlogArchivesPath = "/sitemap-blog-archives.xml"
-	sitemapBlogPostsPath    = "/sitemap-blog-posts.xml"
+	sitemapPath               = "/sitemap.xml"
+	sitemapBlogPath           = "/sitemap-blog.xml"
+	sitemapBlogFeaturesPath   = "/sitemap-blog-features.xml"
+	sitemapBlogArchivesPath   = "/sitemap-blog-archives.xml"
+	sitemapBlogTaxonomiesPath = "/sitemap-blog-taxonomies.xml"
+	sitemapBlogPhotosPath     = "/sitemap-blog-photos.xml"
+	sitemapBlogPostsPath      = "/sitemap-blog-posts.xml"
+
+	sitemapNamespace      = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapImageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
 )
 
+// Last modification of the posts, as stored in the database (UTC)
+const sitemapLastModSelection = "coalesce(nullif(toutc(updated), ''), toutc(published)) as lastmod"
+
+// Function to add an entry to a sitemap that is currently written
+type sitemapAddFunc func(loc string, lastMod time.Time, images ...string) error
+
+// Sitemap index with the sitemaps of all blogs
 func (a *goBlog) serveSitemap(w http.ResponseWriter, r *http.Request) {
-	// Create sitemap
-	sm := sitemap.NewSitemapIndex()
-	// Add blog sitemap indices
-	now := time.Now().UTC()
-	for _, bc := range a.cfg.Blogs {
-		sm.Add(&sitemap.URL{
-			Loc:     a.getFullAddress(bc.getRelativePath(sitemapBlogPath)),
-			LastMod: &now,
-		})
-	}
-	// Write sitemap
-	a.writeSitemapXML(w, r, sm)
+	a.writeSitemapXML(w, r, true, func(add sitemapAddFunc) error {
+		for _, blog := range sortedStrings(lo.Keys(a.cfg.Blogs)) {
+			if a.isProtectedBlog(blog) {
+				// Protected blogs shouldn't be indexed
+				continue
+			}
+			if err := a.addBlogSitemaps(blog, a.cfg.Blogs[blog], add); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
+// Sitemap index with the sitemaps of one blog
 func (a *goBlog) serveSitemapBlog(w http.ResponseWriter, r *http.Request) {
-	// Create sitemap
-	sm := sitemap.NewSitemapIndex()
-	// Add blog sitemaps
-	_, bc := a.getBlog(r)
-	now := time.Now().UTC()
-	sm.Add(&sitemap.URL{
-		Loc:     a.getFullAddress(bc.getRelativePath(sitemapBlogFeaturesPath)),
-		LastMod: &now,
-	})
-	sm.Add(&sitemap.URL{
-		Loc:     a.getFullAddress(bc.getRelativePath(sitemapBlogArchivesPath)),
-		LastMod: &now,
-	})
-	sm.Add(&sitemap.URL{
-		Loc:     a.getFullAddress(bc.getRelativePath(sitemapBlogPostsPath)),
-		LastMod: &now,
+	blog, bc := a.getBlog(r)
+	a.writeSitemapXML(w, r, true, func(add sitemapAddFunc) error {
+		return a.addBlogSitemaps(blog, bc, add)
 	})
-	// Write sitemap
-	a.writeSitemapXML(w, r, sm)
 }
 
-func (a *goBlog) serveSitemapBlogFeatures(w http.ResponseWriter, r *http.Request) {
-	// Create sitemap
-	sm := sitemap.New()
-	// Add features to sitemap
-	_, bc := a.getBlog(r)
-	// Home
-	sm.Add(&sitemap.URL{
-		Loc: a.getFullAddress(bc.getRelativePath("")),
-	})
-	// Photos
-	if pc := bc.Photos; pc != nil && pc.Enabled {
-		sm.Add(&sitemap.URL{
-			Loc: a.getFullAddress(bc.getRelativePath(defaultIfEmpty(pc.Path, defaultPhotosPath))),
-		})
-	}
-	// Search
-	if bsc := bc.Search; bsc != nil && bsc.Enabled {
-		sm.Add(&sitemap.URL{
-			Loc: a.getFullAddress(bc.getRelativePath(defaultIfEmpty(bsc.Path, defaultSearchPath))),
-		})
-	}
-	// Stats
-	if bsc := bc.BlogStats; bsc != nil && bsc.Enabled {
-		sm.Add(&sitemap.URL{
-			Loc: a.getFullAddress(bc.getRelativePath(defaultIfEmpty(bsc.Path, defaultBlogStatsPath))),
-		})
+func (a *goBlog) addBlogSitemaps(blog string, bc *configBlog, add sitemapAddFunc) error {
+	lastMod, err := a.db.postsLastMod(a.sitemapPostsConfig(blog))
+	if err != nil {
+		return err
 	}
-	// Blogroll
-	if brc := bc.Blogroll; brc != nil && brc.Enabled {
-		sm.Add(&sitemap.URL{
-			Loc: a.getFullAddress(bc.getRelativePath(defaultIfEmpty(brc.Path, defaultBlogrollPath))),
-		})
+	taxonomiesConfig := a.sitemapPostsConfig(blog)
+	taxonomiesConfig.parameters = lo.Map(bc.Taxonomies, func(t *configTaxonomy, _ int) string { return t.Name })
+	taxonomiesLastMod, err := a.db.postsLastMod(taxonomiesConfig)
+	if err != nil {
+		return err
 	}
-	// Geo map
-	if mc := bc.Map; mc != nil && mc.Enabled {
-		sm.Add(&sitemap.URL{
-			Loc: a.getFullAddress(bc.getRelativePath(defaultIfEmpty(mc.Path, defaultGeoMapPath))),
-		})
+	photosConfig := a.sitemapPostsConfig(blog)
+	photosConfig.parameter = a.cfg.Micropub.PhotoParam
+	photosLastMod, err := a.db.postsLastMod(photosConfig)
+	if err != nil {
+		return err
 	}
-	// Contact
-	if cc := bc.Contact; cc != nil && cc.Enabled {
-		sm.Add(&sitemap.URL{
-			Loc: a.getFullAddress(bc.getRelativePath(defaultIfEmpty(cc.Path, defaultContactPath))),
-		})
+	for _, sm := range []struct {
+		path    string
+		lastMod time.Time
+	}{
+		{sitemapBlogFeaturesPath, lastMod},
+		{sitemapBlogPostsPath, lastMod},
+		{sitemapBlogArchivesPath, lastMod},
+		{sitemapBlogTaxonomiesPath, taxonomiesLastMod},
+		{sitemapBlogPhotosPath, photosLastMod},
+	} {
+		if err := add(a.getFullAddress(bc.getRelativePath(sm.path)), sm.lastMod); err != nil {
+			return err
+		}
 	}
-	// Write sitemap
-	a.writeSitemapXML(w, r, sm)
+	return nil
 }
 
+func (a *goBlog) serveSitemapBlogFeatures(w http.ResponseWriter, r *http.Request) {
+	blog, bc := a.getBlog(r)
+	a.writeSitemapXML(w, r, false, func(add sitemapAddFunc) error {
+		// Home
+		lastMod, err := a.db.postsLastMod(a.sitemapPostsConfig(blog))
+		if err != nil {
+			return err
+		}
+		if err := add(a.getFullAddress(bc.getRelativePath("")), lastMod); err != nil {
+			return err
+		}
+		var features []string
+		// Photos
+		if pc := bc.Photos; pc != nil && pc.Enabled {
+			photosConfig := a.sitemapPostsConfig(blog)
+			photosConfig.parameter = a.cfg.Micropub.PhotoParam
+			photosLastMod, err := a.db.postsLastMod(photosConfig)
+			if err != nil {
+				return err
+			}
+			if err := add(a.getFullAddress(bc.getRelativePath(defaultIfEmpty(pc.Path, defaultPhotosPath))), photosLastMod); err != nil {
+				return err
+			}
+		}
+		// Search
+		if bsc := bc.Search; bsc != nil && bsc.Enabled {
+			features = append(features, defaultIfEmpty(bsc.Path, defaultSearchPath))
+		}
+		// Stats
+		if bsc := bc.BlogStats; bsc != nil && bsc.Enabled {
+			features = append(features, defaultIfEmpty(bsc.Path, defaultBlogStatsPath))
+		}
+		// Blogroll
+		if brc := bc.Blogroll; brc != nil && brc.Enabled {
+			features = append(features, defaultIfEmpty(brc.Path, defaultBlogrollPath))
+		}
+		// Geo map
+		if mc := bc.Map; mc != nil && mc.Enabled {
+			features = append(features, defaultIfEmpty(mc.Path, defaultGeoMapPath))
+		}
+		// Contact
+		if cc := bc.Contact; cc != nil && cc.Enabled {
+			features = append(features, defaultIfEmpty(cc.Path, defaultContactPath))
+		}
+		for _, f := range features {
+			if err := add(a.getFullAddress(bc.getRelativePath(f)), time.Time{}); err != nil {
+				return err
+			}
+		}
+		// Custom pages, with the last modification of their posts
+		for _, page := range bc.Pages {
+			lastMod, err := a.db.postsLastMod(a.sitemapPageConfig(blog, bc, page))
+			if err != nil {
+				return err
+			}
+			if err := add(a.getFullAddress(bc.getRelativePath(page.Path)), lastMod); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Config to request the posts listed on a custom page
+func (a *goBlog) sitemapPageConfig(blog string, bc *configBlog, page *configPage) *postsRequestConfig {
+	ic := a.pageIndexConfig(bc, page)
+	config := a.sitemapPostsConfig(blog)
+	config.sections = lo.Map(ic.sections, func(s *configSection, _ int) string { return s.Name })
+	config.taxonomy, config.taxonomyValue = ic.tax, ic.taxValue
+	config.parameter, config.parameterValue = ic.parameter, ic.parameterValue
+	return config
+}
+
+// Serve sitemap with the blog's sections and date based archives
 func (a *goBlog) serveSitemapBlogArchives(w http.ResponseWriter, r *http.Request) {
-	// Create sitemap
-	sm := sitemap.New()
-	// Add archives to sitemap
-	b, bc := a.getBlog(r)
-	// Sections
-	for _, section := range bc.Sections {
-		if section.Name != "" {
-			sm.Add(&sitemap.URL{
-				Loc: a.getFullAddress(bc.getRelativePath(section.Name)),
-			})
-			datePaths, _ := a.sitemapDatePaths(b, []string{section.Name})
-			for _, p := range datePaths {
-				sm.Add(&sitemap.URL{
-					Loc: a.getFullAddress(bc.getRelativePath(path.Join(section.Name, p))),
-				})
+	blog, bc := a.getBlog(r)
+	a.writeSitemapXML(w, r, false, func(add sitemapAddFunc) error {
+		// Sections
+		for _, section := range sortedStrings(lo.Keys(bc.Sections)) {
+			if section == "" {
+				continue
+			}
+			sectionConfig := a.sitemapPostsConfig(blog)
+			sectionConfig.sections = []string{section}
+			lastMod, err := a.db.postsLastMod(sectionConfig)
+			if err != nil {
+				return err
+			}
+			if err := add(a.getFullAddress(bc.getRelativePath(section)), lastMod); err != nil {
+				return err
+			}
+			if err := a.addSitemapDatePaths(blog, []string{section}, func(p string, lastMod time.Time) error {
+				return add(a.getFullAddress(bc.getRelativePath(path.Join(section, p))), lastMod)
+			}); err != nil {
+				return err
 			}
 		}
-	}
-	// Taxonomies
-	for _, taxonomy := range bc.Taxonomies {
-		if taxonomy.Name != "" {
+		// Date based archives
+		return a.addSitemapDatePaths(blog, nil, func(p string, lastMod time.Time) error {
+			return add(a.getFullAddress(bc.getRelativePath(p)), lastMod)
+		})
+	})
+}
+
+const sitemapTaxonomyValuesSql = `
+select urlize(pp.value) as value, max(p.lastmod)
+from post_parameters pp, ( %s ) p
+where pp.path = p.path and pp.parameter = @taxparam and length(coalesce(pp.value, '')) > 0
+group by value
+order by value;
+`
+
+// Serve sitemap with the blog's taxonomies and their values
+func (a *goBlog) serveSitemapBlogTaxonomies(w http.ResponseWriter, r *http.Request) {
+	blog, bc := a.getBlog(r)
+	a.writeSitemapXML(w, r, false, func(add sitemapAddFunc) error {
+		for _, taxonomy := range bc.Taxonomies {
+			if taxonomy.Name == "" {
+				continue
+			}
 			// Taxonomy
+			taxConfig := a.sitemapPostsConfig(blog)
+			taxConfig.parameter = taxonomy.Name
+			lastMod, err := a.db.postsLastMod(taxConfig)
+			if err != nil {
+				return err
+			}
 			taxPath := bc.getRelativePath("/" + taxonomy.Name)
-			sm.Add(&sitemap.URL{
-				Loc: a.getFullAddress(taxPath),
-			})
+			if err := add(a.getFullAddress(taxPath), lastMod); err != nil {
+				return err
+			}
 			// Values
-			if taxValues, err := a.db.allTaxonomyValues(b, taxonomy.Name); err == nil {
-				for _, tv := range taxValues {
-					sm.Add(&sitemap.URL{
-						Loc: a.getFullAddress(taxPath + "/" + urlize(tv)),
-					})
+			query, args := buildPostsQuery(a.sitemapPostsConfig(blog), "path, "+sitemapLastModSelection)
+			rows, err := a.db.Query(fmt.Sprintf(sitemapTaxonomyValuesSql, query), append(args, sql.Named("taxparam", taxonomy.Name))...)
+			if err != nil {
+				return err
+			}
+			if err := scanSitemapRows(rows, func(value string, lastMod time.Time) error {
+				return add(a.getFullAddress(taxPath+"/"+value), lastMod)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+const sitemapPhotosSql = `
+select p.path, p.blog, p.section, p.lastmod, pp.value
+from ( %s ) p, post_parameters pp
+where pp.path = p.path and pp.parameter = @photoparam and length(coalesce(pp.value, '')) > 0
+order by p.published desc, p.path, pp.id;
+`
+
+// Serve sitemap with the blog's posts that have photos, including the photos
+func (a *goBlog) serveSitemapBlogPhotos(w http.ResponseWriter, r *http.Request) {
+	blog, _ := a.getBlog(r)
+	a.writeSitemapXML(w, r, false, func(add sitemapAddFunc) error {
+		query, args := buildPostsQuery(a.sitemapPostsConfig(blog), "path, blog, coalesce(section, '') as section, published, "+sitemapLastModSelection)
+		rows, err := a.db.Query(fmt.Sprintf(sitemapPhotosSql, query), append(args, sql.Named("photoparam", a.cfg.Micropub.PhotoParam))...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		// The rows are ordered by post, so collect the photos of a post and add it when the next post starts
+		var current *post
+		var currentLastMod string
+		var photos []string
+		flush := func() error {
+			if current == nil {
+				return nil
+			}
+			return add(a.fullPostURL(current), sitemapTime(currentLastMod), photos...)
+		}
+		var p post
+		var lastMod, photo string
+		for rows.Next() {
+			if err = rows.Scan(&p.Path, &p.Blog, &p.Section, &lastMod, &photo); err != nil {
+				return err
+			}
+			if current == nil || current.Path != p.Path {
+				if err = flush(); err != nil {
+					return err
 				}
+				current, currentLastMod, photos = &post{Path: p.Path, Blog: p.Blog, Section: p.Section}, lastMod, nil
 			}
+			photos = append(photos, photo)
 		}
-	}
-	// Date based archives
-	datePaths, _ := a.sitemapDatePaths(b, nil)
-	for _, p := range datePaths {
-		sm.Add(&sitemap.URL{
-			Loc: a.getFullAddress(bc.getRelativePath(p)),
-		})
-	}
-	// Write sitemap
-	a.writeSitemapXML(w, r, sm)
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return flush()
+	})
 }
 
 // Serve sitemap with all the blog's posts
 func (a *goBlog) serveSitemapBlogPosts(w http.ResponseWriter, r *http.Request) {
-	// Create sitemap
-	sm := sitemap.New()
-	// Request posts
 	blog, _ := a.getBlog(r)
-	posts, _ := a.getPosts(&postsRequestConfig{
-		status:            []postStatus{statusPublished},
-		visibility:        []postVisibility{visibilityPublic},
-		blog:              blog,
-		withoutParameters: true,
+	a.writeSitemapXML(w, r, false, func(add sitemapAddFunc) error {
+		query, args := buildPostsQuery(a.sitemapPostsConfig(blog), "path, blog, coalesce(section, ''), "+sitemapLastModSelection)
+		rows, err := a.db.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		// Stream the posts, so big blogs don't need to load all posts at once
+		var p post
+		var lastMod string
+		for rows.Next() {
+			if err = rows.Scan(&p.Path, &p.Blog, &p.Section, &lastMod); err != nil {
+				return err
+			}
+			if err = add(a.fullPostURL(&p), sitemapTime(lastMod)); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
 	})
-	// Add posts to sitemap
-	for _, p := range posts {
-		item := &sitemap.URL{Loc: a.fullPostURL(p)}
-		lastMod := noError(dateparse.ParseLocal(defaultIfEmpty(p.Updated, p.Published)))
+}
+
+// Config to request the posts listed in the sitemaps
+func (*goBlog) sitemapPostsConfig(blog string) *postsRequestConfig {
+	return &postsRequestConfig{
+		blog:                   blog,
+		status:                 []postStatus{statusPublished},
+		visibility:             []postVisibility{visibilityPublic},
+		withinVisibilityWindow: true,
+	}
+}
+
+// Write a sitemap (or sitemap index if index is true), the entries are streamed while they are generated
+func (a *goBlog) writeSitemapXML(w http.ResponseWriter, r *http.Request, index bool, generate func(add sitemapAddFunc) error) {
+	_, bc := a.getBlog(r)
+	// Generate the complete sitemap first, so errors aren't sent as a truncated sitemap
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	_, _ = io.WriteString(buf, xml.Header)
+	_, _ = io.WriteString(buf, `<?xml-stylesheet type="text/xsl" href="`)
+	_, _ = io.WriteString(buf, a.blogAssetFileName(bc, "sitemap.xsl"))
+	_, _ = io.WriteString(buf, `" ?>`)
+	rootElement, entryElement := "urlset", "url"
+	if index {
+		rootElement, entryElement = "sitemapindex", "sitemap"
+	}
+	_, _ = io.WriteString(buf, "<"+rootElement+` xmlns="`+sitemapNamespace+`"`)
+	if !index {
+		_, _ = io.WriteString(buf, ` xmlns:image="`+sitemapImageNamespace+`"`)
+	}
+	_, _ = io.WriteString(buf, ">")
+	if err := generate(func(loc string, lastMod time.Time, images ...string) error {
+		_, _ = io.WriteString(buf, "<"+entryElement+"><loc>")
+		_ = xml.EscapeText(buf, []byte(loc))
+		_, _ = io.WriteString(buf, "</loc>")
 		if !lastMod.IsZero() {
-			item.LastMod = &lastMod
+			_, _ = io.WriteString(buf, "<lastmod>"+lastMod.UTC().Format(time.RFC3339)+"</lastmod>")
 		}
-		sm.Add(item)
+		for _, image := range images {
+			_, _ = io.WriteString(buf, "<image:image><image:loc>")
+			_ = xml.EscapeText(buf, []byte(image))
+			_, _ = io.WriteString(buf, "</image:loc></image:image>")
+		}
+		_, _ = io.WriteString(buf, "</"+entryElement+">")
+		return nil
+	}); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	// Write sitemap
-	a.writeSitemapXML(w, r, sm)
+	_, _ = io.WriteString(buf, "</"+rootElement+">")
+	w.Header().Set(contentType, contenttype.XMLUTF8)
+	_ = a.min.Get().Minify(contenttype.XML, w, buf)
 }
 
-func (a *goBlog) writeSitemapXML(w http.ResponseWriter, _ *http.Request, sm any) {
-	pr, pw := io.Pipe()
-	go func() {
-		_, _ = io.WriteString(pw, xml.Header)
-		_, _ = io.WriteString(pw, `<?xml-stylesheet type="text/xsl" href="`)
-		_, _ = io.WriteString(pw, a.assetFileName("sitemap.xsl"))
-		_, _ = io.WriteString(pw, `" ?>`)
-		_ = pw.CloseWithError(xml.NewEncoder(pw).Encode(sm))
-	}()
-	w.Header().Set(contentType, contenttype.XMLUTF8)
-	_ = pr.CloseWithError(a.min.Get().Minify(contenttype.XML, w, pr))
+func sitemapTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	return noError(time.Parse(time.RFC3339, s))
+}
+
+// Scan rows with a string and a last modification date
+func scanSitemapRows(rows *sql.Rows, f func(string, time.Time) error) error {
+	defer rows.Close()
+	var value, lastMod string
+	for rows.Next() {
+		if err := rows.Scan(&value, &lastMod); err != nil {
+			return err
+		}
+		if err := f(value, sitemapTime(lastMod)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Last modification of the requested posts
+func (db *database) postsLastMod(config *postsRequestConfig) (time.Time, error) {
+	query, args := buildPostsQuery(config, sitemapLastModSelection)
+	row, err := db.QueryRow("select coalesce(max(lastmod), '') from ("+query+")", args...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var lastMod string
+	if err = row.Scan(&lastMod); err != nil {
+		return time.Time{}, err
+	}
+	return sitemapTime(lastMod), nil
 }
 
 const sitemapDatePathsSql = `
 with filteredposts as ( %s ),
 alldates as (
-    select distinct 
+    select
         substr(published, 1, 4) as year,
         substr(published, 6, 2) as month,
-        substr(published, 9, 2) as day
+        substr(published, 9, 2) as day,
+        lastmod
     from (
-            select tolocal(published) as published
+            select tolocal(published) as published, lastmod
             from filteredposts
 			where coalesce(published, '') != ''
         )
 )
-select distinct '/' || year from alldates
+select '/' || year as path, max(lastmod) from alldates group by path
 union
-select distinct '/' || year || '/' || month from alldates
+select '/' || year || '/' || month as path, max(lastmod) from alldates group by path
 union
-select distinct '/' || year || '/' || month || '/' || day from alldates
+select '/' || year || '/' || month || '/' || day as path, max(lastmod) from alldates group by path
 union
-select distinct '/x/' || month from alldates
+select '/x/' || month as path, max(lastmod) from alldates group by path
 union
-select distinct '/x/' || month || '/' || day from alldates
+select '/x/' || month || '/' || day as path, max(lastmod) from alldates group by path
 union
-select distinct '/x/x/' || day from alldates;
+select '/x/x/' || day as path, max(lastmod) from alldates group by path;
 `
 
-func (a *goBlog) sitemapDatePaths(blog string, sections []string) (paths []string, err error) {
-	query, args := buildPostsQuery(&postsRequestConfig{
-		blog:       blog,
-		sections:   sections,
-		status:     []postStatus{statusPublished},
-		visibility: []postVisibility{visibilityPublic},
-	}, "published")
+// Add the date based archive paths with their last modification
+func (a *goBlog) addSitemapDatePaths(blog string, sections []string, add func(path string, lastMod time.Time) error) error {
+	config := a.sitemapPostsConfig(blog)
+	config.sections = sections
+	query, args := buildPostsQuery(config, "published, "+sitemapLastModSelection)
 	rows, err := a.db.Query(fmt.Sprintf(sitemapDatePathsSql, query), args...)
 	if err != nil {
-		return nil, err
-	}
-	var path string
-	for rows.Next() {
-		err = rows.Scan(&path)
-		if err != nil {
-			return nil, err
-		}
-		paths = append(paths, path)
+		return err
 	}
-	return
+	return scanSitemapRows(rows, add)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/samber/lo"
+)
+
+// Notify search engines (or other services) about changes of the sitemap. The state of the sitemaps is
+// saved after each ping, so changes that don't affect the sitemaps (like drafts) don't cause a ping.
+
+const sitemapPingStateKey = "sitemapping_state"
+
+func (a *goBlog) sitemapPingEnabled() bool {
+	if a.isPrivate() {
+		return false
+	}
+	spc := a.cfg.SitemapPing
+	return spc != nil && spc.Enabled && len(spc.Endpoints) > 0
+}
+
+func (a *goBlog) initSitemapPing() {
+	if !a.sitemapPingEnabled() {
+		return
+	}
+	hook := func(*post) { a.pingSitemapIfChanged() }
+	a.pPostHooks = append(a.pPostHooks, hook)
+	a.pUpdateHooks = append(a.pUpdateHooks, hook)
+	a.pDeleteHooks = append(a.pDeleteHooks, hook)
+	a.pUndeleteHooks = append(a.pUndeleteHooks, hook)
+	// Scheduled posts and visibility windows change the sitemaps without a hook
+	a.hourlyHooks = append(a.hourlyHooks, a.pingSitemapIfChanged)
+}
+
+// Hash of the listed sitemaps with their last modification and the number of posts of all blogs
+func (a *goBlog) sitemapState() (string, error) {
+	h := sha256.New()
+	for _, blog := range sortedStrings(lo.Keys(a.cfg.Blogs)) {
+		if a.isProtectedBlog(blog) {
+			// Not listed in the sitemap index
+			continue
+		}
+		count, err := a.db.countPosts(a.sitemapPostsConfig(blog))
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "%s %d\n", blog, count)
+		if err = a.addBlogSitemaps(blog, a.cfg.Blogs[blog], func(loc string, lastMod time.Time, _ ...string) error {
+			_, err := fmt.Fprintf(h, "%s %s\n", loc, lastMod.UTC().Format(time.RFC3339))
+			return err
+		}); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (a *goBlog) pingSitemapIfChanged() {
+	if !a.sitemapPingEnabled() {
+		return
+	}
+	a.sitemapPingMutex.Lock()
+	defer a.sitemapPingMutex.Unlock()
+	state, err := a.sitemapState()
+	if err != nil {
+		log.Println("Sitemap ping: failed to get sitemap state:", err.Error())
+		return
+	}
+	last, err := a.db.retrievePersistentCache(sitemapPingStateKey)
+	if err != nil {
+		log.Println("Sitemap ping: failed to retrieve last state:", err.Error())
+		return
+	}
+	if string(last) == state {
+		// Nothing changed
+		return
+	}
+	sitemapURL := a.getFullAddress(sitemapPath)
+	for _, endpoint := range a.cfg.SitemapPing.Endpoints {
+		if err := requests.URL(endpoint).Client(a.httpClient).Param("sitemap", sitemapURL).Fetch(context.Background()); err != nil {
+			log.Println("Sitemap ping: request to", endpoint, "failed:", err.Error())
+			continue
+		}
+		log.Println("Sitemap ping: sent to", endpoint)
+	}
+	if err = a.db.cachePersistently(sitemapPingStateKey, []byte(state)); err != nil {
+		log.Println("Sitemap ping: failed to save state:", err.Error())
+	}
+}
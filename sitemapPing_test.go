@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sitemapPing(t *testing.T) {
+	fc := newFakeHttpClient()
+	fc.setFakeResponse(http.StatusOK, "OK")
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.SitemapPing = &configSitemapPing{Enabled: true, Endpoints: []string{"https://search.example/ping"}}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+
+	pinged := func() bool {
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		req := fc.req
+		fc.req = nil
+		if req == nil {
+			return false
+		}
+		assert.Equal(t, "https://search.example/ping?sitemap=http%3A%2F%2Flocalhost%3A8080%2Fsitemap.xml", req.URL.String())
+		return true
+	}
+
+	p := &post{Blog: "default", Path: "/test", Section: "posts", Status: statusPublished, Visibility: visibilityPublic, Published: "2023-01-01T00:00:00Z", Content: "Test"}
+	require.NoError(t, app.db.savePost(p, &postCreationOptions{new: true}))
+	app.pingSitemapIfChanged()
+	assert.True(t, pinged())
+
+	// Nothing changed
+	app.pingSitemapIfChanged()
+	assert.False(t, pinged())
+
+	// Drafts aren't in the sitemap
+	require.NoError(t, app.db.savePost(&post{Blog: "default", Path: "/draft", Section: "posts", Status: statusDraft, Content: "Draft"}, &postCreationOptions{new: true}))
+	app.pingSitemapIfChanged()
+	assert.False(t, pinged())
+
+	// Updated posts
+	p.Updated = "2023-01-02T00:00:00Z"
+	require.NoError(t, app.db.savePost(p, &postCreationOptions{oldPath: p.Path}))
+	app.pingSitemapIfChanged()
+	assert.True(t, pinged())
+
+	// Deleted posts, even if they aren't the newest
+	require.NoError(t, app.db.savePost(&post{Blog: "default", Path: "/old", Section: "posts", Status: statusPublished, Visibility: visibilityPublic, Published: "2022-01-01T00:00:00Z", Content: "Old"}, &postCreationOptions{new: true}))
+	app.pingSitemapIfChanged()
+	assert.True(t, pinged())
+	_, err := app.db.Exec("delete from posts where path = '/old'")
+	require.NoError(t, err)
+	app.pingSitemapIfChanged()
+	assert.True(t, pinged())
+}
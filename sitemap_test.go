@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/carlmjohnson/requests"
 	"github.com/stretchr/testify/assert"
@@ -20,6 +23,12 @@ func Test_sitemap(t *testing.T) {
 	_ = app.initConfig(false)
 	app.initMarkdown()
 	_ = app.initCache()
+	_ = app.initTemplateStrings()
+	app.initSessions()
+
+	bc := app.cfg.Blogs["default"]
+	bc.Photos = &configPhotos{Enabled: true}
+	bc.Pages = []*configPage{{Path: "/tested", Taxonomy: "tags", TaxonomyValue: "test"}}
 
 	app.d = app.buildRouter()
 
@@ -28,6 +37,7 @@ func Test_sitemap(t *testing.T) {
 		Section:   "posts",
 		Status:    "published",
 		Published: "2020-10-15T10:00:00Z",
+		Updated:   "2020-10-20T10:00:00Z",
 		Parameters: map[string][]string{
 			"title": {"Test Post"},
 			"tags":  {"Test"},
@@ -36,6 +46,17 @@ func Test_sitemap(t *testing.T) {
 	})
 	require.NoError(t, err)
 
+	err = app.createPost(&post{
+		Path:      "/photopost",
+		Section:   "posts",
+		Status:    "published",
+		Published: "2020-09-01T10:00:00Z",
+		Parameters: map[string][]string{
+			"images": {"https://example.com/1.jpg", "https://example.com/2.jpg"},
+		},
+	})
+	require.NoError(t, err)
+
 	client := newHandlerClient(app.d)
 
 	var resString string
@@ -47,7 +68,10 @@ func Test_sitemap(t *testing.T) {
 		Client(client).Fetch(context.Background())
 	require.NoError(t, err)
 
-	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog.xml")
+	assert.Contains(t, resString, "<sitemapindex")
+	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-posts.xml</loc><lastmod>2020-10-20T10:00:00Z</lastmod>")
+	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-taxonomies.xml</loc><lastmod>2020-10-20T10:00:00Z</lastmod>")
+	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-photos.xml</loc><lastmod>2020-09-01T10:00:00Z</lastmod>")
 
 	err = requests.
 		URL("http://localhost:8080/sitemap-blog.xml").
@@ -59,6 +83,8 @@ func Test_sitemap(t *testing.T) {
 	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-posts.xml")
 	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-features.xml")
 	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-archives.xml")
+	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-taxonomies.xml")
+	assert.Contains(t, resString, "http://localhost:8080/sitemap-blog-photos.xml")
 
 	err = requests.
 		URL("http://localhost:8080/sitemap-blog-posts.xml").
@@ -67,7 +93,8 @@ func Test_sitemap(t *testing.T) {
 		Client(client).Fetch(context.Background())
 	require.NoError(t, err)
 
-	assert.Contains(t, resString, "http://localhost:8080/testpost")
+	assert.Contains(t, resString, "<url><loc>http://localhost:8080/testpost</loc><lastmod>2020-10-20T10:00:00Z</lastmod></url>")
+	assert.Contains(t, resString, "<url><loc>http://localhost:8080/photopost</loc><lastmod>2020-09-01T10:00:00Z</lastmod></url>")
 
 	err = requests.
 		URL("http://localhost:8080/sitemap-blog-archives.xml").
@@ -76,12 +103,35 @@ func Test_sitemap(t *testing.T) {
 		Client(client).Fetch(context.Background())
 	require.NoError(t, err)
 
-	assert.Contains(t, resString, "http://localhost:8080/2020/10/15</loc>")
+	assert.Contains(t, resString, "http://localhost:8080/2020/10/15</loc><lastmod>2020-10-20T10:00:00Z</lastmod>")
+	assert.Contains(t, resString, "http://localhost:8080/2020/09</loc><lastmod>2020-09-01T10:00:00Z</lastmod>")
+	assert.Contains(t, resString, "http://localhost:8080/posts</loc>")
+	assert.Contains(t, resString, "http://localhost:8080/posts/2020/10</loc>")
 	assert.Contains(t, resString, "http://localhost:8080/2020/10</loc>")
 	assert.Contains(t, resString, "http://localhost:8080/2020</loc>")
 	assert.Contains(t, resString, "http://localhost:8080/x/10/15</loc>")
 	assert.Contains(t, resString, "http://localhost:8080/x/x/15</loc>")
-	assert.Contains(t, resString, "http://localhost:8080/tags/test</loc>")
+	assert.NotContains(t, resString, "http://localhost:8080/tags/test</loc>")
+
+	err = requests.
+		URL("http://localhost:8080/sitemap-blog-taxonomies.xml").
+		CheckStatus(http.StatusOK).
+		ToString(&resString).
+		Client(client).Fetch(context.Background())
+	require.NoError(t, err)
+
+	assert.Contains(t, resString, "http://localhost:8080/tags</loc><lastmod>2020-10-20T10:00:00Z</lastmod>")
+	assert.Contains(t, resString, "http://localhost:8080/tags/test</loc><lastmod>2020-10-20T10:00:00Z</lastmod>")
+
+	err = requests.
+		URL("http://localhost:8080/sitemap-blog-photos.xml").
+		CheckStatus(http.StatusOK).
+		ToString(&resString).
+		Client(client).Fetch(context.Background())
+	require.NoError(t, err)
+
+	assert.Contains(t, resString, "<url><loc>http://localhost:8080/photopost</loc><lastmod>2020-09-01T10:00:00Z</lastmod><image:image><image:loc>https://example.com/1.jpg</image:loc></image:image><image:image><image:loc>https://example.com/2.jpg</image:loc></image:image></url>")
+	assert.NotContains(t, resString, "testpost")
 
 	err = requests.
 		URL("http://localhost:8080/sitemap-blog-features.xml").
@@ -90,5 +140,16 @@ func Test_sitemap(t *testing.T) {
 		Client(client).Fetch(context.Background())
 	require.NoError(t, err)
 
-	assert.Contains(t, resString, "http://localhost:8080</loc>")
+	assert.Contains(t, resString, "http://localhost:8080</loc><lastmod>2020-10-20T10:00:00Z</lastmod>")
+	assert.Contains(t, resString, "http://localhost:8080/photos</loc><lastmod>2020-09-01T10:00:00Z</lastmod>")
+	assert.Contains(t, resString, "http://localhost:8080/tested</loc><lastmod>2020-10-20T10:00:00Z</lastmod>")
+
+	// Errors aren't sent as truncated sitemap
+	rec := httptest.NewRecorder()
+	app.writeSitemapXML(rec, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil), false, func(add sitemapAddFunc) error {
+		_ = add("http://localhost:8080/first", time.Time{})
+		return errors.New("database error")
+	})
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "/first")
 }
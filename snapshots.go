@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.goblog.app/app/pkgs/contenttype"
+	"go.goblog.app/app/pkgs/htmlbuilder"
+)
+
+// When a public post is updated, the previously rendered page is kept as an immutable snapshot at /{path}/v/{n}
+
+var postSnapshotPathRegex = regexp.MustCompile(`^(/.*)/v/([1-9][0-9]*)$`)
+
+type postSnapshot struct {
+	Version int
+	Date    string // Published or updated date of the snapshotted version
+}
+
+func (a *goBlog) snapshotsEnabled() bool {
+	return a.cfg.Snapshots != nil && a.cfg.Snapshots.Enabled
+}
+
+func (p *post) snapshotPath(version int) string {
+	return fmt.Sprintf("%s/v/%d", p.Path, version)
+}
+
+// Split a snapshot path into the post path and the version, returns an empty path if it's no snapshot path
+func splitPostSnapshotPath(path string) (string, int) {
+	m := postSnapshotPathRegex.FindStringSubmatch(path)
+	if m == nil {
+		return "", 0
+	}
+	version, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0
+	}
+	return m[1], version
+}
+
+// Render the post page like a visitor sees it
+func (a *goBlog) renderPostSnapshot(p *post) ([]byte, error) {
+	ctx := context.WithValue(context.Background(), loggedInKey, false)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.getFullAddress(p.Path), nil)
+	if err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	renderMethod, rd := a.postRenderMethodAndData(p)
+	a.render(rec, req, renderMethod, rd)
+	return rec.Body.Bytes(), nil
+}
+
+func (db *database) savePostSnapshot(path, date string, html []byte) error {
+	_, err := db.Exec(
+		"insert into post_snapshots (path, version, date, html) select @path, coalesce(max(version), 0) + 1, @date, @html from post_snapshots where path = @path",
+		sql.Named("path", path), sql.Named("date", date), sql.Named("html", html),
+	)
+	return err
+}
+
+func (db *database) getPostSnapshots(path string) ([]*postSnapshot, error) {
+	rows, err := db.Query("select version, date from post_snapshots where path = @path order by version desc", sql.Named("path", path))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	snapshots := []*postSnapshot{}
+	for rows.Next() {
+		s := &postSnapshot{}
+		if err = rows.Scan(&s.Version, &s.Date); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+func (db *database) getPostSnapshotHtml(path string, version int) ([]byte, error) {
+	row, err := db.QueryRow("select html from post_snapshots where path = @path and version = @version", sql.Named("path", path), sql.Named("version", version))
+	if err != nil {
+		return nil, err
+	}
+	var html []byte
+	err = row.Scan(&html)
+	return html, err
+}
+
+func (a *goBlog) servePostSnapshot(w http.ResponseWriter, r *http.Request) {
+	path, version := splitPostSnapshotPath(r.URL.Path)
+	html, err := a.db.getPostSnapshotHtml(path, version)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(contentType, contenttype.HTMLUTF8)
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Add("Link", fmt.Sprintf("<%s>; rel=latest-version", a.getFullAddress(path)))
+	_, _ = w.Write(html)
+}
+
+// List of the previous versions of a post
+func (a *goBlog) renderPostSnapshots(hb *htmlbuilder.HtmlBuilder, p *post, b *configBlog) {
+	if b == nil || p == nil || !a.snapshotsEnabled() {
+		return
+	}
+	snapshots, err := a.db.getPostSnapshots(p.Path)
+	if err != nil || len(snapshots) == 0 {
+		return
+	}
+	hb.WriteElementOpen("details", "class", "p")
+	hb.WriteElementOpen("summary")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "previousversions"))
+	hb.WriteElementClose("summary")
+	hb.WriteElementOpen("ul")
+	for _, s := range snapshots {
+		hb.WriteElementOpen("li")
+		hb.WriteElementOpen("a", "href", p.snapshotPath(s.Version), "rel", "predecessor-version")
+		hb.WriteEscaped(fmt.Sprintf("%s %d", a.ts.GetTemplateStringVariant(b.Lang, "version"), s.Version))
+		hb.WriteElementClose("a")
+		if date := toLocalTime(s.Date); !date.IsZero() {
+			hb.WriteUnescaped(" (")
+			hb.WriteElementOpen("time", "datetime", date.Format(time.RFC3339))
+			hb.WriteEscaped(date.Format(isoDateFormat))
+			hb.WriteElementClose("time")
+			hb.WriteUnescaped(")")
+		}
+		hb.WriteElementClose("li")
+	}
+	hb.WriteElementClose("ul")
+	hb.WriteElementClose("details")
+}
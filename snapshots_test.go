@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_postSnapshots(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Snapshots = &configSnapshots{Enabled: true}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	p := &post{
+		Path:       "/testpost",
+		Section:    "posts",
+		Status:     statusPublished,
+		Visibility: visibilityPublic,
+		Published:  "2023-01-01T10:00:00Z",
+		Content:    "First version",
+	}
+	require.NoError(t, app.createPost(p))
+
+	update := func(content string) {
+		p, err := app.getPost("/testpost")
+		require.NoError(t, err)
+		p.Content = content
+		require.NoError(t, app.replacePost(p, p.Path, p.Status, p.Visibility))
+	}
+	update("Second version")
+	update("Third version")
+
+	snapshots, err := app.db.getPostSnapshots("/testpost")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, 2, snapshots[0].Version)
+	assert.Equal(t, 1, snapshots[1].Version)
+
+	client := newHandlerClient(app.d)
+
+	get := func(path string) (int, string, http.Header) {
+		var body string
+		var header http.Header
+		var status int
+		_ = requests.URL("http://localhost:8080" + path).Client(client).
+			AddValidator(func(r *http.Response) error {
+				status, header = r.StatusCode, r.Header
+				return nil
+			}).
+			ToString(&body).
+			Fetch(context.Background())
+		return status, body, header
+	}
+
+	status, body, _ := get("/testpost")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Third version")
+	assert.Contains(t, body, "href=/testpost/v/1")
+	assert.Contains(t, body, "href=/testpost/v/2")
+
+	status, body, header := get("/testpost/v/1")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "First version")
+	assert.NotContains(t, body, "Second version")
+	assert.Equal(t, "noindex", header.Get("X-Robots-Tag"))
+	assert.Contains(t, header.Get("Link"), "<http://localhost:8080/testpost>; rel=latest-version")
+
+	status, body, _ = get("/testpost/v/2")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Second version")
+	// Snapshot contains the version list at that time
+	assert.Contains(t, body, "href=/testpost/v/1")
+
+	status, _, _ = get("/testpost/v/3")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Snapshots of private posts aren't served and no new snapshot is created
+	p, err = app.getPost("/testpost")
+	require.NoError(t, err)
+	p.Visibility = visibilityPrivate
+	require.NoError(t, app.replacePost(p, p.Path, statusPublished, visibilityPublic))
+	p.Content = "Private version"
+	require.NoError(t, app.replacePost(p, p.Path, statusPublished, visibilityPrivate))
+	status, _, _ = get("/testpost/v/1")
+	assert.Equal(t, http.StatusNotFound, status)
+	snapshots, err = app.db.getPostSnapshots("/testpost")
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 3)
+
+	// Snapshots move with the post
+	p.Visibility = visibilityPublic
+	oldPath := p.Path
+	p.Path = "/newpath"
+	require.NoError(t, app.replacePost(p, oldPath, statusPublished, visibilityPrivate))
+	status, body, _ = get("/newpath/v/1")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "First version")
+
+	// Deleting the post deletes the snapshots
+	require.NoError(t, app.deletePost("/newpath"))
+	require.NoError(t, app.deletePost("/newpath"))
+	snapshots, err = app.db.getPostSnapshots("/newpath")
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func Test_splitPostSnapshotPath(t *testing.T) {
+	path, version := splitPostSnapshotPath("/posts/test/v/12")
+	assert.Equal(t, "/posts/test", path)
+	assert.Equal(t, 12, version)
+
+	for _, p := range []string{"/posts/test", "/v/1", "/posts/test/v/0", "/posts/test/v/abc", "/posts/test/v/1/"} {
+		path, _ = splitPostSnapshotPath(p)
+		assert.Empty(t, path, p)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/contenttype"
+)
+
+// Spam protection for public forms like the contact and the comment form
+
+const (
+	spamProtectionCaptcha = "captcha"
+	spamProtectionPoW     = "pow"
+	spamProtectionNone    = "none"
+
+	spamHoneypotField = "homepage"
+	spamPoWPath       = "/pow"
+
+	defaultSpamPoWDifficulty = 16
+	defaultSpamRateLimit     = 5
+
+	spamPoWValidity  = time.Hour
+	spamRateLimitWin = time.Hour
+)
+
+type formSpamProtection struct {
+	mutex      sync.Mutex
+	powKey     []byte
+	powUsed    map[string]time.Time
+	rateLimits map[string]*formRateLimit
+}
+
+type formRateLimit struct {
+	count int
+	reset time.Time
+}
+
+func (a *goBlog) getFormSpamProtection() *formSpamProtection {
+	a.formSpamInit.Do(func() {
+		// Random key per process, challenges just have to survive until the form is submitted
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		a.formSpam = &formSpamProtection{
+			powKey:     key,
+			powUsed:    map[string]time.Time{},
+			rateLimits: map[string]*formRateLimit{},
+		}
+	})
+	return a.formSpam
+}
+
+func (sp *configSpamProtection) mode() string {
+	if sp == nil {
+		return spamProtectionCaptcha
+	}
+	switch sp.SpamProtection {
+	case spamProtectionPoW, spamProtectionNone:
+		return sp.SpamProtection
+	default:
+		return spamProtectionCaptcha
+	}
+}
+
+func (sp *configSpamProtection) powDifficulty() int {
+	if sp == nil || sp.PoWDifficulty <= 0 {
+		return defaultSpamPoWDifficulty
+	}
+	return lo.Min([]int{sp.PoWDifficulty, 32})
+}
+
+func (sp *configSpamProtection) rateLimit() int {
+	if sp == nil || sp.RateLimit == 0 {
+		return defaultSpamRateLimit
+	}
+	return sp.RateLimit
+}
+
+// Only apply the captcha middleware if configured
+func (a *goBlog) spamCaptchaMiddleware(sp *configSpamProtection) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if sp.mode() == spamProtectionCaptcha {
+			return a.captchaMiddleware(next)
+		}
+		return next
+	}
+}
+
+// Honeypot field, only bots fill it
+func spamHoneypotFilled(r *http.Request) bool {
+	return r.FormValue(spamHoneypotField) != ""
+}
+
+// Check the proof of work and the rate limit of a form submission, the key identifies the form
+func (a *goBlog) checkFormSpam(r *http.Request, sp *configSpamProtection, key string) (int, error) {
+	spam := a.getFormSpamProtection()
+	if sp.mode() == spamProtectionPoW &&
+		!spam.verifyPoW(r.FormValue("powchallenge"), r.FormValue("pownonce"), sp.powDifficulty()) {
+		return http.StatusBadRequest, errors.New("proof of work missing or invalid")
+	}
+	if !spam.allowSubmission(key+" "+a.clientIP(r), sp.rateLimit()) {
+		return http.StatusTooManyRequests, errors.New("too many submissions, try again later")
+	}
+	return 0, nil
+}
+
+// Proof of work
+
+func (s *formSpamProtection) powSignature(payload string) string {
+	mac := hmac.New(sha256.New, s.powKey)
+	_, _ = mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Create a new challenge with the format "timestamp.random.signature"
+func (s *formSpamProtection) newPoWChallenge() string {
+	payload := fmt.Sprintf("%d.%s", time.Now().Unix(), randomString(16))
+	return payload + "." + s.powSignature(payload)
+}
+
+// Check if the challenge is valid, not used yet and solved by the nonce
+func (s *formSpamProtection) verifyPoW(challenge, nonce string, difficulty int) bool {
+	if nonce == "" || len(nonce) > 64 {
+		return false
+	}
+	sepIndex := strings.LastIndex(challenge, ".")
+	if sepIndex < 0 {
+		return false
+	}
+	payload, signature := challenge[:sepIndex], challenge[sepIndex+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.powSignature(payload))) {
+		return false
+	}
+	timestampString, _, _ := strings.Cut(payload, ".")
+	timestamp, err := strconv.ParseInt(timestampString, 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > spamPoWValidity {
+		return false
+	}
+	if !checkPoWSolution(challenge, nonce, difficulty) {
+		return false
+	}
+	// Prevent replays
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for c, expires := range s.powUsed {
+		if now.After(expires) {
+			delete(s.powUsed, c)
+		}
+	}
+	if _, used := s.powUsed[challenge]; used {
+		return false
+	}
+	s.powUsed[challenge] = time.Unix(timestamp, 0).Add(spamPoWValidity)
+	return true
+}
+
+// Check if the SHA-256 hash of "challenge:nonce" starts with enough zero bits
+func checkPoWSolution(challenge, nonce string, difficulty int) bool {
+	hash := sha256.Sum256([]byte(challenge + ":" + nonce))
+	zeroBits := 0
+	for _, b := range hash {
+		if b == 0 {
+			zeroBits += 8
+			continue
+		}
+		zeroBits += bits.LeadingZeros8(b)
+		break
+	}
+	return zeroBits >= difficulty
+}
+
+func (a *goBlog) servePoWChallenge(sp *configSpamProtection) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(cacheControl, "no-store,max-age=0")
+		w.Header().Set(contentType, contenttype.JSONUTF8)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"challenge":  a.getFormSpamProtection().newPoWChallenge(),
+			"difficulty": sp.powDifficulty(),
+		})
+	}
+}
+
+// Rate limiting
+
+// Check if the client is still allowed to send a submission and count it
+func (s *formSpamProtection) allowSubmission(key string, limit int) bool {
+	if limit < 0 {
+		// Rate limiting disabled
+		return true
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for k, rl := range s.rateLimits {
+		if now.After(rl.reset) {
+			delete(s.rateLimits, k)
+		}
+	}
+	rl, ok := s.rateLimits[key]
+	if !ok {
+		rl = &formRateLimit{reset: now.Add(spamRateLimitWin)}
+		s.rateLimits[key] = rl
+	}
+	if rl.count >= limit {
+		return false
+	}
+	rl.count++
+	return true
+}
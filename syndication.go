@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
+)
+
+const (
+	syndicationParameter   = "syndication"
+	syndicateToParameter   = "syndicateto"
+	syndicationQueueName   = "syndication"
+	syndicationMaxAttempts = 5
+
+	syndicationTypeMastodon = "mastodon"
+	syndicationTypeBluesky  = "bluesky"
+	syndicationTypeTwitter  = "twitter"
+)
+
+type syndicationRequest struct {
+	Path, Target string
+	Try          int
+}
+
+func (r *syndicationRequest) encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(r)
+}
+
+func (a *goBlog) initSyndication() {
+	if !a.syndicationEnabled() {
+		return
+	}
+	a.pPostHooks = append(a.pPostHooks, a.syndicatePost)
+	a.listenOnQueue(syndicationQueueName, 30*time.Second, a.processSyndicationQueueItem)
+}
+
+// Process a queued syndication request and record the status
+func (a *goBlog) processSyndicationQueueItem(ctx context.Context, qi *queueItem, dequeue func(), reschedule func(time.Duration)) {
+	var r syndicationRequest
+	if err := gob.NewDecoder(bytes.NewReader(qi.content)).Decode(&r); err != nil {
+		a.logger("syndication").Error("Failed to decode queue item", "err", err)
+		dequeue()
+		return
+	}
+	syndicationURL, err := a.processSyndicationRequest(ctx, &r)
+	switch {
+	case errors.Is(err, errSyndicationSkipped):
+		_ = a.db.deleteSyndicationStatus(r.Path, r.Target)
+	case err != nil:
+		if ctx.Err() != nil {
+			// Shutting down, keep request in the queue
+			return
+		}
+		status := &syndicationStatus{Path: r.Path, Target: r.Target, Status: syndicationStatusPending, Error: err.Error(), Attempts: r.Try + 1}
+		if r.Try++; r.Try < syndicationMaxAttempts {
+			a.logger("syndication").Warn("Syndication failed, trying again later", "path", r.Path, "target", r.Target, "err", err)
+			_ = a.db.setSyndicationStatus(status)
+			buf := bufferpool.Get()
+			_ = r.encode(buf)
+			qi.content = buf.Bytes()
+			reschedule(time.Duration(r.Try) * 10 * time.Minute)
+			bufferpool.Put(buf)
+			return
+		}
+		a.logger("syndication").Error("Syndication failed", "path", r.Path, "target", r.Target, "err", err)
+		status.Status = syndicationStatusFailed
+		_ = a.db.setSyndicationStatus(status)
+		a.sendNotification(notificationTypeError, fmt.Sprintf("Failed to syndicate %s to %s: %s", a.getFullAddress(r.Path), r.Target, err.Error()))
+	default:
+		_ = a.db.setSyndicationStatus(&syndicationStatus{Path: r.Path, Target: r.Target, Status: syndicationStatusSent, URL: syndicationURL, Attempts: r.Try + 1})
+	}
+	dequeue()
+}
+
+func (a *goBlog) syndicationEnabled() bool {
+	return a.cfg.Syndication != nil && len(a.syndicationTargets()) > 0
+}
+
+func (t *configSyndicationTarget) enabled() bool {
+	if t == nil || t.UID == "" {
+		return false
+	}
+	switch t.Type {
+	case syndicationTypeMastodon:
+		return t.Instance != "" && t.Token != ""
+	case syndicationTypeBluesky:
+		return t.Handle != "" && t.Password != ""
+	case syndicationTypeTwitter:
+		return t.Token != ""
+	default:
+		return false
+	}
+}
+
+func (a *goBlog) syndicationTargets() []*configSyndicationTarget {
+	if a.cfg.Syndication == nil {
+		return nil
+	}
+	return lo.Filter(a.cfg.Syndication.Targets, func(t *configSyndicationTarget, _ int) bool {
+		return t.enabled()
+	})
+}
+
+func (a *goBlog) syndicationTarget(uid string) *configSyndicationTarget {
+	t, _ := lo.Find(a.syndicationTargets(), func(t *configSyndicationTarget) bool {
+		return t.UID == uid
+	})
+	return t
+}
+
+// Targets for the Micropub "syndicate-to" query
+func (a *goBlog) getMicropubSyndicateToList() []map[string]any {
+	list := []map[string]any{}
+	for _, t := range a.syndicationTargets() {
+		list = append(list, map[string]any{
+			"uid":  t.UID,
+			"name": defaultIfEmpty(t.Name, t.UID),
+		})
+	}
+	return list
+}
+
+// Post hook that queues the syndication of a new post to the requested and the default targets
+func (a *goBlog) syndicatePost(p *post) {
+	if !p.isPublicPublishedSectionPost() || a.isProtectedBlog(p.Blog) {
+		return
+	}
+	requested := p.Parameters[syndicateToParameter]
+	for _, t := range a.syndicationTargets() {
+		if !t.Default && !lo.Contains(requested, t.UID) {
+			continue
+		}
+		if err := a.queueSyndication(p.Path, t.UID); err != nil {
+			a.logger("syndication").Error("Failed to queue syndication", "err", err)
+		}
+	}
+}
+
+// Returned when the post or target doesn't exist anymore or the post isn't public (or in a protected blog)
+var errSyndicationSkipped = errors.New("syndication skipped")
+
+// Syndicate the post and return the URL of the syndicated copy
+func (a *goBlog) processSyndicationRequest(ctx context.Context, r *syndicationRequest) (string, error) {
+	t := a.syndicationTarget(r.Target)
+	if t == nil {
+		// Target not configured anymore
+		return "", errSyndicationSkipped
+	}
+	p, err := a.getPost(r.Path)
+	if err != nil {
+		if errors.Is(err, errPostNotFound) {
+			return "", errSyndicationSkipped
+		}
+		return "", err
+	}
+	if !p.isPublicPublishedSectionPost() || a.isProtectedBlog(p.Blog) {
+		return "", errSyndicationSkipped
+	}
+	var syndicationURL string
+	switch t.Type {
+	case syndicationTypeMastodon:
+		syndicationURL, err = a.syndicateToMastodon(ctx, t, p)
+	case syndicationTypeBluesky:
+		syndicationURL, err = a.syndicateToBluesky(ctx, t, p)
+	case syndicationTypeTwitter:
+		syndicationURL, err = a.syndicateToTwitter(ctx, t, p)
+	}
+	if err != nil {
+		return "", err
+	}
+	if syndicationURL == "" {
+		return "", nil
+	}
+	// Save syndication link to post
+	if err = a.db.replacePostParam(p.Path, syndicationParameter, append(p.Parameters[syndicationParameter], syndicationURL)); err != nil {
+		return "", err
+	}
+	a.cache.purgePost(p.Path)
+	return syndicationURL, nil
+}
+
+// Build the text to syndicate: title and link for articles, full text and link for notes
+func (a *goBlog) syndicationText(p *post, limit int, reservedForLink int) string {
+	link := a.shortPostURL(p)
+	var text string
+	if title := p.RenderedTitle; title != "" {
+		text = title
+	} else {
+		text = a.renderTextSafe(p.Content)
+	}
+	text = strings.TrimSpace(text)
+	if limit > 0 {
+		text = truncateStringWithEllipsis(text, limit-reservedForLink-2)
+	}
+	if text == "" {
+		return link
+	}
+	return text + "\n\n" + link
+}
+
+// Mastodon
+
+func (a *goBlog) syndicateToMastodon(ctx context.Context, t *configSyndicationTarget, p *post) (string, error) {
+	var res struct {
+		URL string `json:"url"`
+	}
+	err := requests.
+		URL(strings.TrimSuffix(t.Instance, "/") + "/api/v1/statuses").
+		Client(a.httpClient).
+		Bearer(t.Token).
+		BodyForm(url.Values{
+			"status":     []string{a.syndicationText(p, 500, 23)},
+			"visibility": []string{"public"},
+			"language":   []string{a.getBlogFromPost(p).Lang},
+		}).
+		ToJSON(&res).
+		Fetch(ctx)
+	return res.URL, err
+}
+
+// Twitter
+
+func (a *goBlog) syndicateToTwitter(ctx context.Context, t *configSyndicationTarget, p *post) (string, error) {
+	var res struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	err := requests.
+		URL("https://api.twitter.com/2/tweets").
+		Client(a.httpClient).
+		Bearer(t.Token).
+		BodyJSON(map[string]any{
+			"text": a.syndicationText(p, 280, 23),
+		}).
+		ToJSON(&res).
+		Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if res.Data.ID == "" {
+		return "", errors.New("no tweet id returned")
+	}
+	return "https://twitter.com/i/web/status/" + res.Data.ID, nil
+}
+
+// Tags of the post (from the ActivityPub tags taxonomies) usable as hashtags, without the "#"
+func (a *goBlog) postHashtags(p *post) (hashtags []string) {
+	if a.cfg.ActivityPub == nil {
+		return nil
+	}
+	for _, tagTax := range a.cfg.ActivityPub.TagsTaxonomies {
+		for _, tag := range p.Parameters[tagTax] {
+			// Hashtags can't contain spaces or punctuation
+			tag = strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' {
+					return r
+				}
+				return -1
+			}, tag)
+			if tag != "" {
+				hashtags = append(hashtags, tag)
+			}
+		}
+	}
+	return lo.Uniq(hashtags)
+}
+
+// Syndication links ("u-syndication")
+func (a *goBlog) syndicationLinks(p *post) []string {
+	return p.Parameters[syndicationParameter]
+}
+
+func hostnameOrURL(u string) string {
+	if pu, err := url.Parse(u); err == nil && pu.Hostname() != "" {
+		return pu.Hostname()
+	}
+	return u
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/bufferpool"
+)
+
+const (
+	defaultBlueskyPDS = "https://bsky.social"
+
+	blueskyURIParameter = "blueskyuri" // AT URIs (at://did/app.bsky.feed.post/rkey) of the syndicated posts
+
+	blueskyMaxLength    = 300
+	blueskyMaxImages    = 4
+	blueskyMaxImageSize = 1000000
+)
+
+var (
+	blueskyLinkRegex = regexp.MustCompile(`https?://\S*[^\s.,;:!?)"'…]`)
+	blueskyTagRegex  = regexp.MustCompile(`(?:^|\s)(#([\p{L}\p{N}_]+))`)
+)
+
+func (a *goBlog) syndicateToBluesky(ctx context.Context, t *configSyndicationTarget, p *post) (string, error) {
+	pds := strings.TrimSuffix(defaultIfEmpty(t.Instance, defaultBlueskyPDS), "/")
+	// Create session
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		Did       string `json:"did"`
+	}
+	err := requests.
+		URL(pds + "/xrpc/com.atproto.server.createSession").
+		Client(a.httpClient).
+		BodyJSON(map[string]string{
+			"identifier": t.Handle,
+			"password":   t.Password,
+		}).
+		ToJSON(&session).
+		Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Create post record, with facets to make links and hashtags clickable
+	text := a.blueskyText(p)
+	record := map[string]any{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339),
+		"langs":     []string{a.getBlogFromPost(p).Lang},
+	}
+	if facets := blueskyFacets(text); len(facets) > 0 {
+		record["facets"] = facets
+	}
+	images, err := a.blueskyUploadImages(ctx, pds, session.AccessJwt, p)
+	if err != nil {
+		return "", err
+	}
+	if len(images) > 0 {
+		record["embed"] = map[string]any{
+			"$type":  "app.bsky.embed.images",
+			"images": images,
+		}
+	}
+	var res struct {
+		URI string `json:"uri"`
+	}
+	err = requests.
+		URL(pds + "/xrpc/com.atproto.repo.createRecord").
+		Client(a.httpClient).
+		Bearer(session.AccessJwt).
+		BodyJSON(map[string]any{
+			"repo":       session.Did,
+			"collection": "app.bsky.feed.post",
+			"record":     record,
+		}).
+		ToJSON(&res).
+		Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Convert AT URI (at://did/app.bsky.feed.post/rkey) to web URL
+	rkey := res.URI[strings.LastIndex(res.URI, "/")+1:]
+	if rkey == "" {
+		return "", errors.New("no post uri returned")
+	}
+	// Save the AT URI, it's needed to reference the post using the AT Protocol
+	if err = a.db.replacePostParam(p.Path, blueskyURIParameter, append(p.Parameters[blueskyURIParameter], res.URI)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://bsky.app/profile/%s/post/%s", t.Handle, rkey), nil
+}
+
+// Text for the Bluesky post: the syndication text with the post's tags as hashtags before the link
+func (a *goBlog) blueskyText(p *post) string {
+	link := a.shortPostURL(p)
+	hashtags := strings.Join(a.blueskyHashtags(p), " ")
+	reserved := utf8.RuneCountInString(link)
+	if hashtags != "" {
+		reserved += utf8.RuneCountInString(hashtags) + 2
+	}
+	text := a.syndicationText(p, blueskyMaxLength, reserved)
+	if hashtags == "" {
+		return text
+	}
+	return strings.TrimSuffix(text, link) + hashtags + "\n\n" + link
+}
+
+func (a *goBlog) blueskyHashtags(p *post) []string {
+	return lo.Map(a.postHashtags(p), func(tag string, _ int) string { return "#" + tag })
+}
+
+// Rich text facets for the links and hashtags in the text, the indexes are UTF-8 byte offsets
+func blueskyFacets(text string) []map[string]any {
+	facets := []map[string]any{}
+	for _, m := range blueskyLinkRegex.FindAllStringIndex(text, -1) {
+		facets = append(facets, blueskyFacet(m[0], m[1], map[string]string{
+			"$type": "app.bsky.richtext.facet#link",
+			"uri":   text[m[0]:m[1]],
+		}))
+	}
+	for _, m := range blueskyTagRegex.FindAllStringSubmatchIndex(text, -1) {
+		tag := text[m[4]:m[5]]
+		if strings.IndexFunc(tag, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+			// Numbers only, not a hashtag
+			continue
+		}
+		facets = append(facets, blueskyFacet(m[2], m[3], map[string]string{
+			"$type": "app.bsky.richtext.facet#tag",
+			"tag":   tag,
+		}))
+	}
+	return facets
+}
+
+func blueskyFacet(start, end int, feature map[string]string) map[string]any {
+	return map[string]any{
+		"index": map[string]int{
+			"byteStart": start,
+			"byteEnd":   end,
+		},
+		"features": []map[string]string{feature},
+	}
+}
+
+// Upload the post's photos as blobs and return the images for the embed
+func (a *goBlog) blueskyUploadImages(ctx context.Context, pds, token string, p *post) ([]map[string]any, error) {
+	alts := p.Parameters[a.cfg.Micropub.PhotoDescriptionParam]
+	images := []map[string]any{}
+	for i, photo := range a.photoLinks(p) {
+		if len(images) >= blueskyMaxImages {
+			break
+		}
+		blob, err := a.blueskyUploadImage(ctx, pds, token, photo)
+		if err != nil {
+			return nil, err
+		}
+		if blob == nil {
+			continue
+		}
+		alt := ""
+		if i < len(alts) {
+			alt = alts[i]
+		}
+		images = append(images, map[string]any{
+			"image": blob,
+			"alt":   alt,
+		})
+	}
+	return images, nil
+}
+
+// Download an image and upload it as blob, returns nil if the image can't be used on Bluesky
+func (a *goBlog) blueskyUploadImage(ctx context.Context, pds, token, imageURL string) (json.RawMessage, error) {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	err := requests.
+		URL(imageURL).
+		Client(a.httpClient).
+		Handle(func(r *http.Response) error {
+			_, err := io.Copy(buf, io.LimitReader(r.Body, blueskyMaxImageSize+1))
+			return err
+		}).
+		Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if buf.Len() > blueskyMaxImageSize {
+		a.logger("syndication").Warn("Bluesky: image too large, skipping", "image", imageURL)
+		return nil, nil
+	}
+	mimeType := http.DetectContentType(buf.Bytes())
+	if !strings.HasPrefix(mimeType, "image/") {
+		a.logger("syndication").Warn("Bluesky: not an image, skipping", "image", imageURL)
+		return nil, nil
+	}
+	var res struct {
+		Blob json.RawMessage `json:"blob"`
+	}
+	err = requests.
+		URL(pds + "/xrpc/com.atproto.repo.uploadBlob").
+		Client(a.httpClient).
+		Bearer(token).
+		ContentType(mimeType).
+		BodyReader(buf).
+		ToJSON(&res).
+		Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Blob) == 0 {
+		return nil, errors.New("no blob returned")
+	}
+	return res.Blob, nil
+}
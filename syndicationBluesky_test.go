@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_blueskyFacets(t *testing.T) {
+	text := "Über #GoBlog and #2024: https://example.com/a. #tag_1\n\nhttp://localhost:8080/s/1"
+	facets := blueskyFacets(text)
+	require.Len(t, facets, 4)
+
+	link := facets[0]
+	assert.Equal(t, map[string]int{"byteStart": 25, "byteEnd": 46}, link["index"])
+	assert.Equal(t, "https://example.com/a", text[25:46])
+	assert.Equal(t, "https://example.com/a", link["features"].([]map[string]string)[0]["uri"])
+
+	assert.Equal(t, "http://localhost:8080/s/1", facets[1]["features"].([]map[string]string)[0]["uri"])
+
+	tag := facets[2]
+	assert.Equal(t, map[string]int{"byteStart": 6, "byteEnd": 13}, tag["index"])
+	assert.Equal(t, "#GoBlog", text[6:13])
+	assert.Equal(t, map[string]string{"$type": "app.bsky.richtext.facet#tag", "tag": "GoBlog"}, tag["features"].([]map[string]string)[0])
+
+	assert.Equal(t, "tag_1", facets[3]["features"].([]map[string]string)[0]["tag"])
+}
+
+func Test_syndicationBluesky(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.Syndication = &configSyndication{
+		Targets: []*configSyndicationTarget{
+			{UID: "bluesky", Type: syndicationTypeBluesky, Handle: "user.bsky.social", Password: "app-password"},
+		},
+	}
+
+	_ = app.initConfig(false)
+	_ = app.initCache()
+	app.initMarkdown()
+
+	err := app.createPost(&post{
+		Section:   "posts",
+		Path:      "/testpost",
+		Published: "2023-01-01",
+		Content:   "Hello Bluesky!",
+		Parameters: map[string][]string{
+			"tags":                                 {"Go Blog", "test"},
+			app.cfg.Micropub.PhotoParam:            {"https://example.com/image.png", "https://example.com/large.png"},
+			app.cfg.Micropub.PhotoDescriptionParam: {"An image", "A large image"},
+		},
+	})
+	require.NoError(t, err)
+
+	var record map[string]any
+	var uploads int
+	fc.setHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "https://bsky.social/xrpc/com.atproto.server.createSession":
+			_, _ = io.WriteString(rw, `{"accessJwt":"jwt","did":"did:plc:abc"}`)
+		case "https://example.com/image.png":
+			_, _ = io.WriteString(rw, "\x89PNG\r\n\x1a\nimage")
+		case "https://example.com/large.png":
+			_, _ = rw.Write(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, blueskyMaxImageSize)...))
+		case "https://bsky.social/xrpc/com.atproto.repo.uploadBlob":
+			uploads++
+			assert.Equal(t, "Bearer jwt", r.Header.Get("Authorization"))
+			assert.Equal(t, "image/png", r.Header.Get("Content-Type"))
+			_, _ = io.WriteString(rw, `{"blob":{"$type":"blob","ref":{"$link":"bafk"},"mimeType":"image/png","size":13}}`)
+		case "https://bsky.social/xrpc/com.atproto.repo.createRecord":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, "did:plc:abc", body["repo"])
+			record, _ = body["record"].(map[string]any)
+			_, _ = io.WriteString(rw, `{"uri":"at://did:plc:abc/app.bsky.feed.post/3k2","cid":"bafy"}`)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	_, err = app.processSyndicationRequest(context.Background(), &syndicationRequest{Path: "/testpost", Target: "bluesky"})
+	require.NoError(t, err)
+
+	require.NotNil(t, record)
+	assert.Equal(t, "Hello Bluesky!\n\n#GoBlog #test\n\nhttp://localhost:8080/s/1", record["text"])
+	assert.Len(t, record["facets"], 3)
+	assert.Equal(t, 1, uploads)
+	if embed, ok := record["embed"].(map[string]any); assert.True(t, ok) {
+		assert.Equal(t, "app.bsky.embed.images", embed["$type"])
+		if images, ok := embed["images"].([]any); assert.True(t, ok) && assert.Len(t, images, 1) {
+			image := images[0].(map[string]any)
+			assert.Equal(t, "An image", image["alt"])
+			assert.Equal(t, "blob", image["image"].(map[string]any)["$type"])
+		}
+	}
+
+	p, err := app.getPost("/testpost")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://bsky.app/profile/user.bsky.social/post/3k2"}, p.Parameters[syndicationParameter])
+	assert.Equal(t, []string{"at://did:plc:abc/app.bsky.feed.post/3k2"}, p.Parameters[blueskyURIParameter])
+}
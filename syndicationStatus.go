@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vcraescu/go-paginator/v2"
+	"go.goblog.app/app/pkgs/bufferpool"
+)
+
+// Status of the syndication of each post to each target, listed for logged-in users,
+// who can retry failed syndications
+
+const syndicationPath = "/syndication"
+
+const (
+	syndicationStatusPending = "pending"
+	syndicationStatusSent    = "sent"
+	syndicationStatusFailed  = "failed"
+)
+
+type syndicationStatus struct {
+	Path, Target, Status, Error, URL, Updated string
+	Attempts                                  int
+}
+
+func (db *database) setSyndicationStatus(s *syndicationStatus) error {
+	_, err := db.Exec(
+		"insert or replace into syndication_status (path, target, status, error, url, attempts, updated) values (@path, @target, @status, @error, @url, @attempts, @updated)",
+		sql.Named("path", s.Path), sql.Named("target", s.Target), sql.Named("status", s.Status), sql.Named("error", s.Error),
+		sql.Named("url", s.URL), sql.Named("attempts", s.Attempts), sql.Named("updated", utcNowString()),
+	)
+	return err
+}
+
+func (db *database) deleteSyndicationStatus(path, target string) error {
+	_, err := db.Exec("delete from syndication_status where path = @path and target = @target", sql.Named("path", path), sql.Named("target", target))
+	return err
+}
+
+func (db *database) getSyndicationStatuses(offset, limit int) ([]*syndicationStatus, error) {
+	rows, err := db.Query(
+		"select path, target, status, error, url, attempts, updated from syndication_status order by updated desc, path, target limit @limit offset @offset",
+		sql.Named("limit", limit), sql.Named("offset", offset),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	statuses := []*syndicationStatus{}
+	for rows.Next() {
+		s := &syndicationStatus{}
+		if err = rows.Scan(&s.Path, &s.Target, &s.Status, &s.Error, &s.URL, &s.Attempts, &s.Updated); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, rows.Err()
+}
+
+func (db *database) countSyndicationStatuses() (count int, err error) {
+	row, err := db.QueryRow("select count(*) from syndication_status")
+	if err != nil {
+		return
+	}
+	err = row.Scan(&count)
+	return
+}
+
+// Queue the syndication of the post to the target and mark it as pending
+func (a *goBlog) queueSyndication(path, target string) error {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	if err := (&syndicationRequest{Path: path, Target: target}).encode(buf); err != nil {
+		return err
+	}
+	if err := a.enqueue(syndicationQueueName, bytes.Clone(buf.Bytes()), time.Now()); err != nil {
+		return err
+	}
+	return a.db.setSyndicationStatus(&syndicationStatus{Path: path, Target: target, Status: syndicationStatusPending})
+}
+
+type syndicationStatusPaginationAdapter struct {
+	nums int64
+	db   *database
+}
+
+func (p *syndicationStatusPaginationAdapter) Nums() (int64, error) {
+	if p.nums == 0 {
+		p.nums = int64(noError(p.db.countSyndicationStatuses()))
+	}
+	return p.nums, nil
+}
+
+func (p *syndicationStatusPaginationAdapter) Slice(offset, length int, data any) error {
+	statuses, err := p.db.getSyndicationStatuses(offset, length)
+	reflect.ValueOf(data).Elem().Set(reflect.ValueOf(&statuses).Elem())
+	return err
+}
+
+func (a *goBlog) serveSyndicationStatus(w http.ResponseWriter, r *http.Request) {
+	p := paginator.New(&syndicationStatusPaginationAdapter{db: a.db}, 20)
+	p.SetPage(stringToInt(chi.URLParam(r, "page")))
+	var statuses []*syndicationStatus
+	if err := p.Results(&statuses); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Navigation
+	var hasPrev, hasNext bool
+	var prevPage, currentPage, nextPage int
+	var prevPath, nextPath string
+	hasPrev, _ = p.HasPrev()
+	if hasPrev {
+		prevPage, _ = p.PrevPage()
+	} else {
+		prevPage, _ = p.Page()
+	}
+	if prevPage < 2 {
+		prevPath = syndicationPath
+	} else {
+		prevPath = fmt.Sprintf("%s/page/%d", syndicationPath, prevPage)
+	}
+	currentPage, _ = p.Page()
+	hasNext, _ = p.HasNext()
+	if hasNext {
+		nextPage, _ = p.NextPage()
+	} else {
+		nextPage, _ = p.Page()
+	}
+	nextPath = fmt.Sprintf("%s/page/%d", syndicationPath, nextPage)
+	// Render
+	a.render(w, r, a.renderSyndicationStatus, &renderData{
+		Data: &syndicationStatusRenderData{
+			statuses: statuses,
+			hasPrev:  hasPrev,
+			hasNext:  hasNext,
+			prev:     prevPath,
+			current:  fmt.Sprintf("%s/page/%d", syndicationPath, currentPage),
+			next:     nextPath,
+		},
+	})
+}
+
+func (a *goBlog) syndicationRetry(w http.ResponseWriter, r *http.Request) {
+	path, target := r.FormValue("path"), r.FormValue("target")
+	if a.syndicationTarget(target) == nil {
+		a.serveError(w, r, "unknown syndication target", http.StatusBadRequest)
+		return
+	}
+	if _, err := a.getPost(path); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.queueSyndication(path, target); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, defaultIfEmpty(r.FormValue("redir"), syndicationPath), http.StatusFound)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_syndication(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.Syndication = &configSyndication{
+		Targets: []*configSyndicationTarget{
+			{UID: "mastodon", Name: "Mastodon", Type: syndicationTypeMastodon, Instance: "https://example.social", Token: "abc"},
+			{UID: "twitter", Type: syndicationTypeTwitter, Token: "def", Default: true},
+			{UID: "invalid", Type: syndicationTypeBluesky},
+		},
+	}
+
+	_ = app.initConfig(false)
+	_ = app.initCache()
+	app.initMarkdown()
+
+	assert.True(t, app.syndicationEnabled())
+	assert.Len(t, app.syndicationTargets(), 2)
+	assert.Equal(t, []map[string]any{
+		{"uid": "mastodon", "name": "Mastodon"},
+		{"uid": "twitter", "name": "twitter"},
+	}, app.getMicropubSyndicateToList())
+
+	err := app.createPost(&post{
+		Section:   "posts",
+		Path:      "/testpost",
+		Published: "2023-01-01",
+		Content:   "Hello World!",
+		Parameters: map[string][]string{
+			syndicateToParameter: {"mastodon"},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("Mastodon", func(t *testing.T) {
+		var status string
+		fc.setHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			status = r.FormValue("status")
+			_, _ = io.WriteString(rw, `{"url":"https://example.social/@user/1"}`)
+		}))
+
+		syndicationURL, err := app.processSyndicationRequest(context.Background(), &syndicationRequest{Path: "/testpost", Target: "mastodon"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.social/@user/1", syndicationURL)
+
+		require.NotNil(t, fc.req)
+		assert.Equal(t, "https://example.social/api/v1/statuses", fc.req.URL.String())
+		assert.Equal(t, "Bearer abc", fc.req.Header.Get("Authorization"))
+		assert.Equal(t, "Hello World!\n\nhttp://localhost:8080/s/1", status)
+
+		p, err := app.getPost("/testpost")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://example.social/@user/1"}, p.Parameters[syndicationParameter])
+	})
+
+	t.Run("Twitter", func(t *testing.T) {
+		var body map[string]any
+		fc.setHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			_, _ = io.WriteString(rw, `{"data":{"id":"123"}}`)
+		}))
+
+		syndicationURL, err := app.processSyndicationRequest(context.Background(), &syndicationRequest{Path: "/testpost", Target: "twitter"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://twitter.com/i/web/status/123", syndicationURL)
+
+		assert.Equal(t, "https://api.twitter.com/2/tweets", fc.req.URL.String())
+		assert.Equal(t, "Hello World!\n\nhttp://localhost:8080/s/1", body["text"])
+
+		p, err := app.getPost("/testpost")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://example.social/@user/1", "https://twitter.com/i/web/status/123"}, p.Parameters[syndicationParameter])
+	})
+
+	t.Run("Unknown target", func(t *testing.T) {
+		fc.clean()
+
+		_, err := app.processSyndicationRequest(context.Background(), &syndicationRequest{Path: "/testpost", Target: "invalid"})
+		assert.ErrorIs(t, err, errSyndicationSkipped)
+		assert.Nil(t, fc.req)
+	})
+}
+
+func Test_syndicationStatus(t *testing.T) {
+	fc := newFakeHttpClient()
+
+	app := &goBlog{
+		cfg:        createDefaultTestConfig(t),
+		httpClient: fc.Client,
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+	app.cfg.Syndication = &configSyndication{
+		Targets: []*configSyndicationTarget{
+			{UID: "mastodon", Name: "Mastodon", Type: syndicationTypeMastodon, Instance: "https://example.social", Token: "abc"},
+		},
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+	_ = app.initCache()
+	app.initSessions()
+	app.d = app.buildRouter()
+
+	require.NoError(t, app.createPost(&post{
+		Section:   "posts",
+		Path:      "/testpost",
+		Published: "2023-01-01",
+		Content:   "Hello World!",
+	}))
+
+	getStatus := func() *syndicationStatus {
+		statuses, err := app.db.getSyndicationStatuses(0, 10)
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		return statuses[0]
+	}
+
+	// Process the next queued request and return whether it was dequeued
+	process := func() (dequeued bool) {
+		qi, err := app.peekQueue(context.Background(), syndicationQueueName)
+		require.NoError(t, err)
+		require.NotNil(t, qi)
+		app.processSyndicationQueueItem(context.Background(), qi, func() {
+			dequeued = true
+			_ = app.dequeue(qi)
+		}, func(time.Duration) {
+			// Schedule immediately again
+			_ = app.reschedule(qi, 0)
+		})
+		return
+	}
+
+	require.NoError(t, app.queueSyndication("/testpost", "mastodon"))
+	assert.Equal(t, syndicationStatusPending, getStatus().Status)
+
+	// Failing attempts
+	fc.setFakeResponse(http.StatusInternalServerError, "error")
+	for i := 1; i < syndicationMaxAttempts; i++ {
+		assert.False(t, process())
+		status := getStatus()
+		assert.Equal(t, syndicationStatusPending, status.Status)
+		assert.Equal(t, i, status.Attempts)
+		assert.NotEmpty(t, status.Error)
+	}
+	assert.True(t, process())
+	status := getStatus()
+	assert.Equal(t, syndicationStatusFailed, status.Status)
+	assert.Equal(t, syndicationMaxAttempts, status.Attempts)
+
+	client := newHandlerClient(app.d)
+
+	// Dashboard
+	var page string
+	err := requests.URL("http://localhost:8080/syndication").Client(client).
+		BasicAuth("test", "test").
+		CheckStatus(http.StatusOK).
+		ToString(&page).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, page, "/testpost")
+	assert.Contains(t, page, "syndication-failed")
+	assert.Contains(t, page, "action=/syndication/retry")
+
+	err = requests.URL("http://localhost:8080/syndication").Client(client).
+		CheckStatus(http.StatusOK).
+		ToString(&page).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, page, "syndication-failed")
+
+	// Retry
+	err = requests.URL("http://localhost:8080/syndication/retry").Client(client).
+		BasicAuth("test", "test").
+		BodyForm(url.Values{"path": {"/testpost"}, "target": {"mastodon"}}).
+		CheckStatus(http.StatusOK).
+		Fetch(context.Background())
+	require.NoError(t, err)
+	status = getStatus()
+	assert.Equal(t, syndicationStatusPending, status.Status)
+	assert.Equal(t, 0, status.Attempts)
+
+	// Successful attempt
+	fc.setHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(rw, `{"url":"https://example.social/@user/1"}`)
+	}))
+	assert.True(t, process())
+	status = getStatus()
+	assert.Equal(t, syndicationStatusSent, status.Status)
+	assert.Equal(t, "https://example.social/@user/1", status.URL)
+	assert.Empty(t, status.Error)
+	assert.True(t, strings.HasPrefix(status.Updated, "20"))
+}
+
+func Test_hostnameOrURL(t *testing.T) {
+	assert.Equal(t, "example.social", hostnameOrURL("https://example.social/@user/1"))
+	assert.Equal(t, "test", hostnameOrURL("test"))
+}
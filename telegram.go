@@ -2,7 +2,6 @@ package main
 
 import (
 	"errors"
-	"log"
 	"net/url"
 	"strconv"
 
@@ -24,9 +23,23 @@ func (tg *configTelegram) enabled() bool {
 	return true
 }
 
+type telegramNotifier struct {
+	a   *goBlog
+	cfg *configTelegram
+}
+
+func (n *telegramNotifier) acceptsNotification(typ notificationType) bool {
+	return notificationEventsAllowed(n.cfg.Events, typ)
+}
+
+func (n *telegramNotifier) sendNotification(text string) error {
+	_, _, err := n.a.sendTelegram(n.cfg, text, "", false)
+	return err
+}
+
 func (a *goBlog) tgPost(silent bool) func(*post) {
 	return func(p *post) {
-		if tg := a.getBlogFromPost(p).Telegram; tg.enabled() && p.isPublicPublishedSectionPost() {
+		if tg := a.getBlogFromPost(p).Telegram; tg.enabled() && p.isPublicPublishedSectionPost() && !a.isProtectedBlog(p.Blog) {
 			tgChat := p.firstParameter("telegramchat")
 			tgMsg := p.firstParameter("telegrammsg")
 			if tgChat != "" && tgMsg != "" {
@@ -41,7 +54,7 @@ func (a *goBlog) tgPost(silent bool) func(*post) {
 			// Send message
 			chatId, msgId, err := a.sendTelegram(tg, html, tgbotapi.ModeHTML, silent)
 			if err != nil {
-				log.Printf("Failed to send post to Telegram: %v", err)
+				a.logger("telegram").Error("Failed to send post to Telegram", "err", err)
 				return
 			}
 			if chatId == 0 || msgId == 0 {
@@ -51,18 +64,18 @@ func (a *goBlog) tgPost(silent bool) func(*post) {
 			// Save chat and message id to post
 			err = a.db.replacePostParam(p.Path, "telegramchat", []string{strconv.FormatInt(chatId, 10)})
 			if err != nil {
-				log.Printf("Failed to save Telegram chat id: %v", err)
+				a.logger("telegram").Error("Failed to save Telegram chat id", "err", err)
 			}
 			err = a.db.replacePostParam(p.Path, "telegrammsg", []string{strconv.Itoa(msgId)})
 			if err != nil {
-				log.Printf("Failed to save Telegram message id: %v", err)
+				a.logger("telegram").Error("Failed to save Telegram message id", "err", err)
 			}
 		}
 	}
 }
 
 func (a *goBlog) tgUpdate(p *post) {
-	if tg := a.getBlogFromPost(p).Telegram; tg.enabled() {
+	if tg := a.getBlogFromPost(p).Telegram; tg.enabled() && !a.isProtectedBlog(p.Blog) {
 		tgChat := p.firstParameter("telegramchat")
 		tgMsg := p.firstParameter("telegrammsg")
 		if tgChat == "" || tgMsg == "" {
@@ -72,13 +85,13 @@ func (a *goBlog) tgUpdate(p *post) {
 		// Parse tgChat to int64
 		chatId, err := strconv.ParseInt(tgChat, 10, 64)
 		if err != nil {
-			log.Printf("Failed to parse Telegram chat ID: %v", err)
+			a.logger("telegram").Error("Failed to parse Telegram chat ID", "err", err)
 			return
 		}
 		// Parse tgMsg to int
 		messageId, err := strconv.Atoi(tgMsg)
 		if err != nil {
-			log.Printf("Failed to parse Telegram message ID: %v", err)
+			a.logger("telegram").Error("Failed to parse Telegram message ID", "err", err)
 			return
 		}
 		// Generate HTML
@@ -89,13 +102,13 @@ func (a *goBlog) tgUpdate(p *post) {
 		// Send update
 		err = a.updateTelegram(tg, chatId, messageId, html, "HTML")
 		if err != nil {
-			log.Printf("Failed to send update to Telegram: %v", err)
+			a.logger("telegram").Error("Failed to send update to Telegram", "err", err)
 		}
 	}
 }
 
 func (a *goBlog) tgDelete(p *post) {
-	if tg := a.getBlogFromPost(p).Telegram; tg.enabled() {
+	if tg := a.getBlogFromPost(p).Telegram; tg.enabled() && !a.isProtectedBlog(p.Blog) {
 		tgChat := p.firstParameter("telegramchat")
 		tgMsg := p.firstParameter("telegrammsg")
 		if tgChat == "" || tgMsg == "" {
@@ -105,28 +118,28 @@ func (a *goBlog) tgDelete(p *post) {
 		// Parse tgChat to int64
 		chatId, err := strconv.ParseInt(tgChat, 10, 64)
 		if err != nil {
-			log.Printf("Failed to parse Telegram chat ID: %v", err)
+			a.logger("telegram").Error("Failed to parse Telegram chat ID", "err", err)
 			return
 		}
 		// Parse tgMsg to int
 		messageId, err := strconv.Atoi(tgMsg)
 		if err != nil {
-			log.Printf("Failed to parse Telegram message ID: %v", err)
+			a.logger("telegram").Error("Failed to parse Telegram message ID", "err", err)
 			return
 		}
 		// Delete message
 		err = a.deleteTelegram(tg, chatId, messageId)
 		if err != nil {
-			log.Printf("Failed to delete Telegram message: %v", err)
+			a.logger("telegram").Error("Failed to delete Telegram message", "err", err)
 		}
 		// Delete chat and message id from post
 		err = a.db.replacePostParam(p.Path, "telegramchat", []string{})
 		if err != nil {
-			log.Printf("Failed to remove Telegram chat id: %v", err)
+			a.logger("telegram").Error("Failed to remove Telegram chat id", "err", err)
 		}
 		err = a.db.replacePostParam(p.Path, "telegrammsg", []string{})
 		if err != nil {
-			log.Printf("Failed to remove Telegram message id: %v", err)
+			a.logger("telegram").Error("Failed to remove Telegram message id", "err", err)
 		}
 	}
 }
@@ -1,51 +1,117 @@
 package main
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"crypto/sha512"
+	"embed"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"io/fs"
+	"log"
 	"mime"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/samber/lo"
 	"go.goblog.app/app/pkgs/contenttype"
 	"go.goblog.app/app/pkgs/highlighting"
+	"go.goblog.app/app/pkgs/htmlbuilder"
+	"go.goblog.app/app/pkgs/httpcompress"
 )
 
 const assetsFolder = "templates/assets"
 
+// The default assets are embedded, so the binary works without the templates directory.
+// Files on disk override them with this precedence (highest first):
+// 1. the theme directory of the blog
+// 2. the global theme directory
+// 3. the embedded default assets
+//
+//go:embed templates/assets
+var defaultAssetFiles embed.FS
+
 type assetFile struct {
 	contentType string
 	body        []byte
+	// Validators for conditional requests
+	eTag     string
+	modified time.Time
+	// Subresource Integrity hash of the body
+	integrity string
+	// Pre-compressed variants, nil if compression doesn't make the file smaller
+	brotli, gzip []byte
+}
+
+// Compiled assets, replaced as a whole when they are reloaded
+type assetStore struct {
+	fileNames map[string]string     // Original file name to compiled file name
+	files     map[string]*assetFile // Compiled file name to file
+}
+
+// Current assets, empty if they aren't initialized
+func (a *goBlog) loadedAssets() *assetStore {
+	if as := a.assets.Load(); as != nil {
+		return as
+	}
+	return &assetStore{}
 }
 
 func (a *goBlog) initTemplateAssets() error {
-	a.assetFileNames = map[string]string{}
-	a.assetFiles = map[string]*assetFile{}
-	if err := filepath.Walk(assetsFolder, func(path string, info os.FileInfo, err error) error {
-		if info.Mode().IsRegular() {
-			// Open file
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			// Compile asset and close file
-			err = a.compileAsset(strings.TrimPrefix(path, assetsFolder+"/"), file)
-			_ = file.Close()
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	}); err != nil {
+	a.assets.Store(&assetStore{fileNames: map[string]string{}, files: map[string]*assetFile{}})
+	if err := a.compileAssetsFromFS(defaultAssetFiles, assetsFolder, ""); err != nil {
 		return err
 	}
 	// Add syntax highlighting CSS
-	return a.initChromaCSS()
+	if err := a.initChromaCSS(); err != nil {
+		return err
+	}
+	// Add global theme overrides
+	if theme := a.cfg.Theme; theme != "" {
+		if err := a.compileAssetsFromFS(os.DirFS(theme), ".", ""); err != nil {
+			return fmt.Errorf("failed to read global theme: %w", err)
+		}
+	}
+	// Add theme overrides of the blogs
+	if err := a.initThemeAssets(); err != nil {
+		return err
+	}
+	// Add preview themes
+	if err := a.initPreviewThemeAssets(); err != nil {
+		return err
+	}
+	// Add custom CSS and JS of the blogs
+	return a.initCustomAssets()
+}
+
+// Compile all files of the directory, the names are relative to the directory and get the prefix
+func (a *goBlog) compileAssetsFromFS(fsys fs.FS, dir, prefix string) error {
+	return fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		// Open file
+		file, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		// Compile asset and close file
+		err = a.compileAsset(prefix+strings.TrimPrefix(path, dir+"/"), file)
+		_ = file.Close()
+		return err
+	})
 }
 
 func (a *goBlog) compileAsset(name string, read io.Reader) error {
@@ -67,23 +133,77 @@ func (a *goBlog) compileAsset(name string, read io.Reader) error {
 	// File name
 	compiledFileName := fmt.Sprintf("%x%s", hash.Sum(nil), ext)
 	// Save file
-	a.assetFiles[compiledFileName] = &assetFile{
+	af := &assetFile{
 		contentType: mime.TypeByExtension(ext),
 		body:        body,
+		eTag:        contentETag(body),
+		modified:    time.Now(),
+		integrity:   subresourceIntegrity(body),
+	}
+	if err = af.compress(); err != nil {
+		return err
 	}
+	as := a.assets.Load()
+	as.files[compiledFileName] = af
 	// Save mapping of original file name to compiled file name
-	a.assetFileNames[name] = compiledFileName
+	as.fileNames[name] = compiledFileName
 	return err
 }
 
 // Function for templates
 func (a *goBlog) assetFileName(fileName string) string {
-	return "/" + a.assetFileNames[fileName]
+	return "/" + a.loadedAssets().fileNames[fileName]
+}
+
+// Path of the asset, overridden by the theme of the blog if it has the file
+func (a *goBlog) blogAssetFileName(bc *configBlog, fileName string) string {
+	if bc != nil {
+		if name, ok := a.loadedAssets().fileNames[themeAssetName(bc.name, fileName)]; ok {
+			return "/" + name
+		}
+	}
+	return a.assetFileName(fileName)
+}
+
+// Subresource Integrity (https://www.w3.org/TR/SRI/) hash for the integrity attribute
+func subresourceIntegrity(body []byte) string {
+	hash := sha512.Sum384(body)
+	return "sha384-" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Integrity of the asset at the path, empty if disabled or the path isn't an asset
+func (a *goBlog) assetIntegrity(path string) string {
+	if !a.cfg.Server.AssetIntegrity {
+		return ""
+	}
+	if af, ok := a.loadedAssets().files[strings.TrimPrefix(path, "/")]; ok {
+		return af.integrity
+	}
+	return ""
+}
+
+// Script element for the asset at the path, attrs are added before the src and integrity attributes
+func (a *goBlog) writeAssetScript(hb *htmlbuilder.HtmlBuilder, path string, attrs ...any) {
+	attrs = append(attrs, "src", path)
+	if integrity := a.assetIntegrity(path); integrity != "" {
+		attrs = append(attrs, "integrity", integrity)
+	}
+	hb.WriteElementOpen("script", attrs...)
+	hb.WriteElementClose("script")
+}
+
+// Stylesheet link for the asset at the path
+func (a *goBlog) writeAssetStylesheet(hb *htmlbuilder.HtmlBuilder, path string) {
+	attrs := []any{"rel", "stylesheet", "href", path}
+	if integrity := a.assetIntegrity(path); integrity != "" {
+		attrs = append(attrs, "integrity", integrity)
+	}
+	hb.WriteElementOpen("link", attrs...)
 }
 
 func (a *goBlog) allAssetPaths() []string {
 	paths := make([]string, 0)
-	for _, name := range a.assetFileNames {
+	for _, name := range a.loadedAssets().fileNames {
 		paths = append(paths, "/"+name)
 	}
 	return paths
@@ -91,35 +211,90 @@ func (a *goBlog) allAssetPaths() []string {
 
 func (a *goBlog) checkTemplateAssets(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		af, ok := a.assetFiles[strings.TrimPrefix(r.URL.Path, "/")]
+		af, ok := a.loadedAssets().files[strings.TrimPrefix(r.URL.Path, "/")]
 		if !ok {
 			next.ServeHTTP(w, r)
 			return
 		}
-		a.serveAssetFile(w, af)
+		a.serveAssetFile(w, r, af)
 	})
 }
 
 // Gets only called by registered paths
 func (a *goBlog) serveAsset(w http.ResponseWriter, r *http.Request) {
-	af, ok := a.assetFiles[strings.TrimPrefix(r.URL.Path, "/")]
+	af, ok := a.loadedAssets().files[strings.TrimPrefix(r.URL.Path, "/")]
 	if !ok {
 		a.serve404(w, r)
 		return
 	}
-	a.serveAssetFile(w, af)
+	a.serveAssetFile(w, r, af)
 }
 
-func (*goBlog) serveAssetFile(w http.ResponseWriter, af *assetFile) {
+func (*goBlog) serveAssetFile(w http.ResponseWriter, r *http.Request, af *assetFile) {
 	w.Header().Set(cacheControl, "public,max-age=31536000,immutable")
 	w.Header().Set(contentType, af.contentType+contenttype.CharsetUtf8Suffix)
-	_, _ = w.Write(af.body)
+	body, eTag := af.body, af.eTag
+	if af.brotli != nil || af.gzip != nil {
+		httpcompress.AddVary(w.Header())
+		accepted := httpcompress.AcceptedEncodings(r)
+		// Each encoding is a different representation with its own ETag
+		if af.brotli != nil && lo.Contains(accepted, "br") {
+			w.Header().Set("Content-Encoding", "br")
+			body, eTag = af.brotli, strings.TrimSuffix(eTag, `"`)+`-br"`
+		} else if af.gzip != nil && lo.Contains(accepted, "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			body, eTag = af.gzip, strings.TrimSuffix(eTag, `"`)+`-gzip"`
+		}
+	}
+	if writeNotModified(w, r, eTag, af.modified) {
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	_, _ = w.Write(body)
+}
+
+// Compress text assets once with brotli and gzip, so they don't need to be compressed with every request
+func (af *assetFile) compress() (err error) {
+	if !strings.HasPrefix(af.contentType, "text/") && !lo.Contains([]string{contenttype.JS, contenttype.JSON, "image/svg+xml"}, af.contentType) {
+		return nil
+	}
+	af.brotli, af.gzip, err = precompress(af.body, brotli.BestCompression)
+	return err
+}
+
+// Compress the body with brotli and gzip, compressed versions that aren't smaller are nil
+func precompress(body []byte, brotliLevel int) (br, gz []byte, err error) {
+	var bb, gb bytes.Buffer
+	bw := brotli.NewWriterLevel(&bb, brotliLevel)
+	if _, err = bw.Write(body); err != nil {
+		return nil, nil, err
+	}
+	if err = bw.Close(); err != nil {
+		return nil, nil, err
+	}
+	gw, err := gzip.NewWriterLevel(&gb, gzip.BestCompression)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = gw.Write(body); err != nil {
+		return nil, nil, err
+	}
+	if err = gw.Close(); err != nil {
+		return nil, nil, err
+	}
+	if bb.Len() < len(body) {
+		br = bb.Bytes()
+	}
+	if gb.Len() < len(body) {
+		gz = gb.Bytes()
+	}
+	return br, gz, nil
 }
 
 func (a *goBlog) initChromaCSS() error {
 	chromaPath := "css/chroma.css"
 	// Check if file already exists
-	if _, ok := a.assetFiles[chromaPath]; ok {
+	if _, ok := a.loadedAssets().files[chromaPath]; ok {
 		return nil
 	}
 	// Initialize the style
@@ -136,3 +311,124 @@ func (a *goBlog) initChromaCSS() error {
 	_ = pr.CloseWithError(err)
 	return err
 }
+
+func themeAssetName(blog, fileName string) string {
+	return "theme/" + blog + "/" + fileName
+}
+
+// Compile the files of the theme directories, they override the default assets with the same name
+func (a *goBlog) initThemeAssets() error {
+	for blog, bc := range a.cfg.Blogs {
+		if bc.Theme == "" {
+			continue
+		}
+		if err := a.compileAssetsFromFS(os.DirFS(bc.Theme), ".", themeAssetName(blog, "")); err != nil {
+			return fmt.Errorf("failed to read theme of blog %s: %w", blog, err)
+		}
+	}
+	return nil
+}
+
+// In debug mode, recompile the assets when a file of a theme directory changes,
+// so themes can be developed without restarting GoBlog
+func (a *goBlog) initThemeReload() {
+	if !a.cfg.Debug {
+		return
+	}
+	themes := lo.Uniq(lo.Compact(append(lo.Map(lo.Values(a.cfg.Blogs), func(bc *configBlog, _ int) string { return bc.Theme }), a.cfg.Theme)))
+	if len(themes) == 0 {
+		return
+	}
+	lastChange := themesLastChange(themes)
+	ticker := time.NewTicker(time.Second)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if change := themesLastChange(themes); change.After(lastChange) {
+					lastChange = change
+					if err := a.reloadAssets(); err != nil {
+						log.Println("Failed to reload assets:", err.Error())
+						continue
+					}
+					log.Println("Reloaded assets because of theme changes")
+				}
+			}
+		}
+	}()
+	a.addShutdownBeforeDatabase(func() {
+		ticker.Stop()
+		close(done)
+	})
+}
+
+// Time of the last modification of a file in the theme directories
+func themesLastChange(themes []string) (last time.Time) {
+	for _, theme := range themes {
+		_ = filepath.WalkDir(theme, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.ModTime().After(last) {
+				last = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return last
+}
+
+// Compile the assets on a separate instance and switch to the new ones
+func (a *goBlog) reloadAssets() error {
+	na := &goBlog{cfg: a.cfg}
+	if err := na.initTemplateAssets(); err != nil {
+		return err
+	}
+	a.assets.Store(na.assets.Load())
+	a.cache.purge()
+	return nil
+}
+
+func customAssetName(blog, ext string) string {
+	return "custom/" + blog + ext
+}
+
+// Compile the custom CSS and JS of each blog (from the file and the config) into fingerprinted assets
+func (a *goBlog) initCustomAssets() error {
+	for blog, bc := range a.cfg.Blogs {
+		ca := bc.CustomAssets
+		if ca == nil {
+			continue
+		}
+		for ext, sources := range map[string][2]string{".css": {ca.CSSFile, ca.CSS}, ".js": {ca.JSFile, ca.JS}} {
+			var content strings.Builder
+			if file := sources[0]; file != "" {
+				fileContent, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("failed to read custom asset of blog %s: %w", blog, err)
+				}
+				content.Write(fileContent)
+				content.WriteString("\n")
+			}
+			content.WriteString(sources[1])
+			if strings.TrimSpace(content.String()) == "" {
+				continue
+			}
+			if err := a.compileAsset(customAssetName(blog, ext), strings.NewReader(content.String())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Path of the custom asset of the blog or an empty string if there is none
+func (a *goBlog) customAssetPath(blog, ext string) string {
+	if name, ok := a.loadedAssets().fileNames[customAssetName(blog, ext)]; ok {
+		return "/" + name
+	}
+	return ""
+}
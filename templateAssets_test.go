@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/carlmjohnson/requests"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.goblog.app/app/pkgs/httpcompress"
+)
+
+func Test_customAssets(t *testing.T) {
+	jsFile := filepath.Join(t.TempDir(), "custom.js")
+	require.NoError(t, os.WriteFile(jsFile, []byte("console.log( 'custom' );\n"), 0644))
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.cfg.Blogs[app.cfg.DefaultBlog].CustomAssets = &configCustomAssets{
+		CSS:    "body {\n  color: red;\n}",
+		JSFile: jsFile,
+	}
+
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+	require.NoError(t, app.initTemplateAssets())
+
+	app.d = app.buildRouter()
+
+	cssPath := app.customAssetPath(app.cfg.DefaultBlog, ".css")
+	jsPath := app.customAssetPath(app.cfg.DefaultBlog, ".js")
+	require.NotEmpty(t, cssPath)
+	require.NotEmpty(t, jsPath)
+	assert.Empty(t, app.customAssetPath("other", ".css"))
+
+	client := newHandlerClient(app.d)
+
+	// Minified and fingerprinted
+	var css string
+	require.NoError(t, requests.URL("http://localhost:8080"+cssPath).Client(client).ToString(&css).Fetch(context.Background()))
+	assert.Equal(t, "body{color:red}", strings.TrimSpace(css))
+	var js string
+	require.NoError(t, requests.URL("http://localhost:8080"+jsPath).Client(client).ToString(&js).Fetch(context.Background()))
+	assert.Contains(t, js, `console.log("custom")`)
+
+	// Linked on the pages of the blog
+	var page string
+	require.NoError(t, requests.URL("http://localhost:8080/").Client(client).ToString(&page).Fetch(context.Background()))
+	assert.Contains(t, page, "href="+cssPath)
+	assert.Contains(t, page, "src="+jsPath)
+
+	// Missing file
+	app.cfg.Blogs[app.cfg.DefaultBlog].CustomAssets.CSSFile = filepath.Join(t.TempDir(), "missing.css")
+	assert.Error(t, app.initTemplateAssets())
+}
+
+func Test_precompressedAssets(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+	require.NoError(t, app.initTemplateAssets())
+
+	app.d = app.buildRouter()
+
+	cssPath := app.assetFileName("css/styles.css")
+	af := app.loadedAssets().files[strings.TrimPrefix(cssPath, "/")]
+	require.NotNil(t, af)
+	require.NotNil(t, af.brotli)
+	require.NotNil(t, af.gzip)
+
+	get := func(acceptEncoding string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, cssPath, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		app.d.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	// Brotli preferred
+	res := get("gzip, deflate, br")
+	assert.Equal(t, "br", res.Header.Get("Content-Encoding"))
+	body, err := io.ReadAll(brotli.NewReader(res.Body))
+	_ = res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, af.body, body)
+
+	// Gzip
+	res = get("gzip, br;q=0")
+	assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+	gr, err := gzip.NewReader(res.Body)
+	require.NoError(t, err)
+	body, err = io.ReadAll(gr)
+	_ = res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, af.body, body)
+
+	// Uncompressed
+	res = get("")
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+	body, err = io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, af.body, body)
+
+	// Assets without registered route (like after reloading them) don't cache the encoding of the first client
+	cssPath = "/css/unregistered.css"
+	app.loadedAssets().files[strings.TrimPrefix(cssPath, "/")] = af
+	assert.Equal(t, "br", get("br").Header.Get("Content-Encoding"))
+	res = get("")
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+	body, err = io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, af.body, body)
+}
+
+func Test_mediaRangeRequests(t *testing.T) {
+	// Audio types are known even without a mime.types file
+	assert.Equal(t, "audio/mpeg", mime.TypeByExtension(".mp3"))
+	assert.Equal(t, "audio/mp4", mime.TypeByExtension(".m4a"))
+
+	// Byte ranges aren't compressed
+	content := strings.Repeat("GoBlog ", 1000)
+	handler := httpcompress.Compress(flate.BestCompression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(content))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=7-12")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "GoBlog", rec.Body.String())
+}
+
+func Test_themeAssets(t *testing.T) {
+	themeDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(themeDir, "css"), 0777))
+	require.NoError(t, os.WriteFile(filepath.Join(themeDir, "css", "styles.css"), []byte("body { color: green; }"), 0644))
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+
+	require.NoError(t, app.initConfig(false))
+	bc := app.cfg.Blogs[app.cfg.DefaultBlog]
+	bc.Theme = themeDir
+
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+	require.NoError(t, app.initTemplateAssets())
+
+	app.d = app.buildRouter()
+
+	// Overridden file
+	themeCSSPath := app.blogAssetFileName(bc, "css/styles.css")
+	assert.NotEqual(t, app.assetFileName("css/styles.css"), themeCSSPath)
+	// Not overridden files use the default assets
+	assert.Equal(t, app.assetFileName("js/editor.js"), app.blogAssetFileName(bc, "js/editor.js"))
+	assert.Equal(t, app.assetFileName("css/styles.css"), app.blogAssetFileName(nil, "css/styles.css"))
+
+	client := newHandlerClient(app.d)
+
+	var css string
+	require.NoError(t, requests.URL("http://localhost:8080"+themeCSSPath).Client(client).ToString(&css).Fetch(context.Background()))
+	assert.Equal(t, "body{color:green}", strings.TrimSpace(css))
+
+	var page string
+	require.NoError(t, requests.URL("http://localhost:8080/").Client(client).ToString(&page).Fetch(context.Background()))
+	assert.Contains(t, page, "href="+themeCSSPath)
+
+	// Reload after changes, while requests are served
+	require.NoError(t, os.WriteFile(filepath.Join(themeDir, "css", "styles.css"), []byte("body { color: blue; }"), 0644))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			_ = requests.URL("http://localhost:8080" + themeCSSPath).Client(client).Fetch(context.Background())
+		}
+	}()
+	require.NoError(t, app.reloadAssets())
+	wg.Wait()
+	newThemeCSSPath := app.blogAssetFileName(bc, "css/styles.css")
+	assert.NotEqual(t, themeCSSPath, newThemeCSSPath)
+	require.NoError(t, requests.URL("http://localhost:8080"+newThemeCSSPath).Client(client).ToString(&css).Fetch(context.Background()))
+	assert.Equal(t, "body{color:blue}", strings.TrimSpace(css))
+}
+
+func Test_assetOverridePrecedence(t *testing.T) {
+	writeFiles := func(dir string, files map[string]string) {
+		for name, content := range files {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0777))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		}
+	}
+	globalTheme, blogTheme := t.TempDir(), t.TempDir()
+	writeFiles(globalTheme, map[string]string{
+		"css/styles.css": "body { color: red; }",
+		"js/editor.js":   "console.log('global')",
+	})
+	writeFiles(blogTheme, map[string]string{
+		"css/styles.css": "body { color: green; }",
+	})
+
+	// The embedded assets don't need the templates directory
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Theme = globalTheme
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {Path: "/", Lang: "en", Theme: blogTheme},
+		"de": {Path: "/de", Lang: "de"},
+	}
+	app.cfg.DefaultBlog = "en"
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	require.NoError(t, app.initTemplateAssets())
+
+	content := func(bc *configBlog, name string) string {
+		af, ok := app.loadedAssets().files[strings.TrimPrefix(app.blogAssetFileName(bc, name), "/")]
+		require.True(t, ok, name)
+		return string(af.body)
+	}
+	embedded := func(name string) string {
+		body, err := defaultAssetFiles.ReadFile(assetsFolder + "/" + name)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	en, de := app.cfg.Blogs["en"], app.cfg.Blogs["de"]
+	// 1. Blog theme
+	assert.Equal(t, "body{color:green}", content(en, "css/styles.css"))
+	// 2. Global theme
+	assert.Equal(t, "body{color:red}", content(de, "css/styles.css"))
+	assert.Equal(t, "body{color:red}", content(nil, "css/styles.css"))
+	assert.Equal(t, "console.log(\"global\")", content(en, "js/editor.js"))
+	// 3. Embedded
+	assert.NotEmpty(t, embedded("js/pow.js"))
+	assert.NotEmpty(t, content(en, "js/pow.js"))
+	assert.NotContains(t, content(en, "js/pow.js"), "global")
+
+	// Invalid global theme
+	app.cfg.Theme = filepath.Join(globalTheme, "missing")
+	assert.Error(t, app.initTemplateAssets())
+}
+
+func Test_assetIntegrity(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.Server.AssetIntegrity = true
+	})
+	require.NoError(t, h.app.initTemplateAssets())
+	h.app.d = h.app.buildRouter()
+
+	cssPath := h.app.assetFileName("css/styles.css")
+	af := h.app.loadedAssets().files[strings.TrimPrefix(cssPath, "/")]
+	require.NotNil(t, af)
+	hash := sha512.Sum384(af.body)
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(hash[:])
+	assert.Equal(t, integrity, h.app.assetIntegrity(cssPath))
+	assert.Empty(t, h.app.assetIntegrity("/unknown.css"))
+
+	_, _, page := h.get("/")
+	assert.Contains(t, page, "href="+cssPath+" integrity="+integrity)
+
+	// Disabled
+	h.app.cfg.Server.AssetIntegrity = false
+	assert.Empty(t, h.app.assetIntegrity(cssPath))
+}
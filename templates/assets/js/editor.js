@@ -116,9 +116,11 @@
         }
     })
 
-    // Template button
-    document.querySelector('#templatebtn').addEventListener('click', function () {
-        let area = document.querySelector('#editor-create')
-        area.value = area.dataset.template;
+    // Template buttons (default template and named post templates)
+    document.querySelectorAll('.templatebtn').forEach(function (btn) {
+        btn.addEventListener('click', function () {
+            let area = document.querySelector('#editor-create')
+            area.value = btn.dataset.template || area.dataset.template;
+        })
     })
 })()
\ No newline at end of file
@@ -0,0 +1,17 @@
+(function () {
+    // Replace the embed links with the player of the provider, only after a click
+    document.querySelectorAll('.embed[data-embed]').forEach(function (embedEl) {
+        let linkEl = embedEl.querySelector('a')
+        if (!linkEl) return
+        linkEl.addEventListener('click', function (event) {
+            event.preventDefault()
+            let iframeEl = document.createElement('iframe')
+            iframeEl.src = embedEl.dataset.embed
+            iframeEl.classList.add('fw')
+            iframeEl.allow = 'autoplay; fullscreen; picture-in-picture'
+            iframeEl.allowFullscreen = true
+            iframeEl.title = linkEl.textContent
+            embedEl.replaceChildren(iframeEl)
+        })
+    })
+})()
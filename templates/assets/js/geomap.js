@@ -50,7 +50,7 @@
         let features = []
         function fitFeatures() {
             // Make the map fit the features
-            map.fitBounds(L.featureGroup(features).getBounds(), { padding: [5, 5] })
+            map.fitBounds(L.featureGroup(features).getBounds(), { padding: [5, 5], maxZoom: 15 })
         }
 
         // Map page
@@ -0,0 +1,37 @@
+(function () {
+    if (!window.crypto || !window.crypto.subtle) {
+        return
+    }
+    const encoder = new TextEncoder()
+
+    const leadingZeroBits = (hash) => {
+        let bits = 0
+        for (const byte of new Uint8Array(hash)) {
+            if (byte === 0) {
+                bits += 8
+                continue
+            }
+            bits += Math.clz32(byte) - 24
+            break
+        }
+        return bits
+    }
+
+    const solve = async (form) => {
+        const response = await fetch(form.dataset.pow)
+        const { challenge, difficulty } = await response.json()
+        for (let nonce = 0; ; nonce++) {
+            const hash = await crypto.subtle.digest('SHA-256', encoder.encode(challenge + ':' + nonce))
+            if (leadingZeroBits(hash) >= difficulty) {
+                form.querySelector('input[name=powchallenge]').value = challenge
+                form.querySelector('input[name=pownonce]').value = nonce.toString()
+                form.querySelector('input[type=submit]').disabled = false
+                return
+            }
+        }
+    }
+
+    Array.from(document.querySelectorAll('form[data-pow]')).forEach(form => {
+        solve(form).catch(error => console.error(error))
+    })
+})()
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/hacdias/indieauth/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// Test harness: a fully initialized app with a temporary database, a fake client for outgoing requests
+// and helpers to send requests to the router, like a running instance would receive them
+
+const (
+	testHarnessAddress  = "https://example.com"
+	testHarnessUser     = "test"
+	testHarnessPassword = "test"
+)
+
+type testHarness struct {
+	t   *testing.T
+	app *goBlog
+	// Outgoing requests of the app
+	fc *fakeHttpClient
+	// Client for requests to the app, doesn't follow redirects
+	client *http.Client
+}
+
+// Creates the harness, the options can change the default test config before the app is initialized
+func newTestHarness(t *testing.T, options ...func(c *config)) *testHarness {
+	t.Helper()
+	fc := newFakeHttpClient()
+	app := &goBlog{
+		cfg:              createDefaultTestConfig(t),
+		httpClient:       fc.Client,
+		publicHttpClient: fc.Client,
+	}
+	app.cfg.Server.PublicAddress = testHarnessAddress
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: testHarnessUser,
+		Password: testHarnessPassword,
+	})
+	for _, option := range options {
+		option(app.cfg)
+	}
+	require.NoError(t, app.initConfig(false))
+	// Stops the queues and closes the database
+	t.Cleanup(app.shutdown.ShutdownAndWait)
+	app.initMarkdown()
+	require.NoError(t, app.initTemplateStrings())
+	require.NoError(t, app.initCache())
+	app.initSessions()
+	require.NoError(t, app.initActivityPub())
+	app.d = app.buildRouter()
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &testHarness{t: t, app: app, fc: fc, client: client}
+}
+
+// Option to enable ActivityPub
+func withActivityPub(c *config) {
+	c.ActivityPub.Enabled = true
+}
+
+// Request to the app, the path is relative to the public address
+func (h *testHarness) request(path string) *requests.Builder {
+	return requests.URL(testHarnessAddress + path).Client(h.client)
+}
+
+// Request to the app as the logged in user
+func (h *testHarness) loggedInRequest(path string) *requests.Builder {
+	return h.request(path).BasicAuth(testHarnessUser, testHarnessPassword)
+}
+
+// Request to the app with an IndieAuth token with the given scopes
+func (h *testHarness) tokenRequest(path string, scopes ...string) *requests.Builder {
+	h.t.Helper()
+	token, err := h.app.db.indieAuthSaveToken(&indieauth.AuthenticationRequest{
+		ClientID: "https://client.example/",
+		Scopes:   scopes,
+	})
+	require.NoError(h.t, err)
+	return h.request(path).Bearer(token)
+}
+
+// Fetches the path and returns the status code, the headers and the body
+func (h *testHarness) get(path string) (int, http.Header, string) {
+	h.t.Helper()
+	var status int
+	var header http.Header
+	var body string
+	require.NoError(h.t, h.request(path).
+		AddValidator(func(r *http.Response) error {
+			status, header = r.StatusCode, r.Header
+			return nil
+		}).
+		ToString(&body).
+		Fetch(context.Background()))
+	return status, header, body
+}
+
+// Sends the request directly to the router, e.g. for requests that need to be signed first
+func (h *testHarness) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.app.d.ServeHTTP(rec, req)
+	return rec
+}
+
+// Creates a published public post in the default section, fields that are already set are kept
+func (h *testHarness) createPost(p *post) *post {
+	h.t.Helper()
+	if p.Section == "" {
+		p.Section = h.app.cfg.Blogs[h.app.cfg.DefaultBlog].DefaultSection
+	}
+	if p.Status == "" {
+		p.Status = statusPublished
+	}
+	if p.Visibility == "" {
+		p.Visibility = visibilityPublic
+	}
+	require.NoError(h.t, h.app.createPost(p))
+	return p
+}
+
+// Removes all items of the queue and returns their content
+func (h *testHarness) dequeueAll(name string) (contents [][]byte) {
+	h.t.Helper()
+	rows, err := h.app.db.Query("select content from queue where name = @name order by id", sql.Named("name", name))
+	require.NoError(h.t, err)
+	for rows.Next() {
+		var content []byte
+		require.NoError(h.t, rows.Scan(&content))
+		contents = append(contents, content)
+	}
+	require.NoError(h.t, rows.Close())
+	_, err = h.app.db.Exec("delete from queue where name = @name", sql.Named("name", name))
+	require.NoError(h.t, err)
+	return contents
+}
+
+// Starts a real HTTP server with the router of the app, e.g. for clients that can't use the handler client
+func (h *testHarness) startServer() *httptest.Server {
+	server := httptest.NewServer(h.app.d)
+	h.t.Cleanup(server.Close)
+	return server
+}
+
+func Test_testHarness(t *testing.T) {
+	h := newTestHarness(t)
+	h.createPost(&post{Path: "/harness", Content: "Harness post"})
+
+	status, _, body := h.get("/harness")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, "Harness post")
+
+	// Real server
+	server := h.startServer()
+	res, err := http.Get(server.URL + "/harness")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Login
+	var editor string
+	require.NoError(t, h.loggedInRequest("/editor").CheckStatus(http.StatusOK).ToString(&editor).Fetch(context.Background()))
+	require.Contains(t, editor, "<form")
+}
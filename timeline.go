@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/go-chi/chi/v5"
+	"github.com/samber/lo"
+	"github.com/vcraescu/go-paginator/v2"
+)
+
+// Timeline: all posts of all blogs (drafts, scheduled and published) in one list for logged-in users,
+// with filters and actions to publish, unlist or delete posts without opening the editor.
+
+const timelinePath = "/timeline"
+
+const (
+	timelineStatusAll     = ""
+	timelineStatusDeleted = "deleted"
+)
+
+var timelineStatuses = map[string][]postStatus{
+	timelineStatusAll:       {statusPublished, statusDraft, statusScheduled},
+	string(statusPublished): {statusPublished},
+	string(statusDraft):     {statusDraft},
+	string(statusScheduled): {statusScheduled},
+	timelineStatusDeleted:   {statusPublishedDeleted, statusDraftDeleted, statusScheduledDeleted},
+}
+
+type timelineFilter struct {
+	blog       string
+	status     string
+	visibility postVisibility
+}
+
+func (a *goBlog) timelineFilterFromQuery(r *http.Request) *timelineFilter {
+	f := &timelineFilter{}
+	q := r.URL.Query()
+	if blog := q.Get("blog"); blog != "" {
+		if _, ok := a.cfg.Blogs[blog]; ok {
+			f.blog = blog
+		}
+	}
+	if status := q.Get("status"); status != "" {
+		if _, ok := timelineStatuses[status]; ok {
+			f.status = status
+		}
+	}
+	if visibility := postVisibility(q.Get("visibility")); validPostVisibility(visibility) {
+		f.visibility = visibility
+	}
+	return f
+}
+
+func (f *timelineFilter) query() string {
+	params := url.Values{}
+	if f.blog != "" {
+		params.Add("blog", f.blog)
+	}
+	if f.status != "" {
+		params.Add("status", f.status)
+	}
+	if f.visibility != visibilityNil {
+		params.Add("visibility", string(f.visibility))
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
+
+func (f *timelineFilter) postsRequestConfig() *postsRequestConfig {
+	c := &postsRequestConfig{
+		blog:   f.blog,
+		status: timelineStatuses[f.status],
+	}
+	if f.visibility != visibilityNil {
+		c.visibility = []postVisibility{f.visibility}
+	}
+	return c
+}
+
+func (a *goBlog) serveTimeline(w http.ResponseWriter, r *http.Request) {
+	filter := a.timelineFilterFromQuery(r)
+	p := paginator.New(&postPaginationAdapter{config: filter.postsRequestConfig(), a: a}, 20)
+	p.SetPage(stringToInt(chi.URLParam(r, "page")))
+	var posts []*post
+	err := p.Results(&posts)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Navigation
+	var hasPrev, hasNext bool
+	var prevPage, currentPage, nextPage int
+	var prevPath, currentPath, nextPath string
+	hasPrev, _ = p.HasPrev()
+	if hasPrev {
+		prevPage, _ = p.PrevPage()
+	} else {
+		prevPage, _ = p.Page()
+	}
+	if prevPage < 2 {
+		prevPath = timelinePath
+	} else {
+		prevPath = fmt.Sprintf("%s/page/%d", timelinePath, prevPage)
+	}
+	currentPage, _ = p.Page()
+	currentPath = fmt.Sprintf("%s/page/%d", timelinePath, currentPage)
+	hasNext, _ = p.HasNext()
+	if hasNext {
+		nextPage, _ = p.NextPage()
+	} else {
+		nextPage, _ = p.Page()
+	}
+	nextPath = fmt.Sprintf("%s/page/%d", timelinePath, nextPage)
+	// Render
+	query := filter.query()
+	a.render(w, r, a.renderTimeline, &renderData{
+		Data: &timelineRenderData{
+			posts:   posts,
+			filter:  filter,
+			hasPrev: hasPrev,
+			hasNext: hasNext,
+			prev:    prevPath + query,
+			current: currentPath + query,
+			next:    nextPath + query,
+		},
+	})
+}
+
+func (a *goBlog) timelineAction(w http.ResponseWriter, r *http.Request) {
+	action := chi.URLParam(r, "action")
+	path := r.FormValue("path")
+	var err error
+	switch action {
+	case "publish", "unlist":
+		err = a.timelineUpdatePost(path, action)
+	case "delete":
+		err = a.deletePost(path)
+	default:
+		a.serveError(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, defaultIfEmpty(r.FormValue("redir"), timelinePath), http.StatusFound)
+}
+
+// Publish a draft or scheduled post now or make a post unlisted
+func (a *goBlog) timelineUpdatePost(path, action string) error {
+	a.db.pum.Lock()
+	defer a.db.pum.Unlock()
+	p, err := a.getPost(path)
+	if err != nil {
+		return err
+	}
+	if p.Deleted() {
+		return errors.New("post is marked as deleted, undelete it first")
+	}
+	oldStatus, oldVisibility := p.Status, p.Visibility
+	switch action {
+	case "publish":
+		if p.Status == statusPublished {
+			return nil
+		}
+		p.Status = statusPublished
+		if published, err := dateparse.ParseLocal(p.Published); (err != nil && p.Section != "") || (err == nil && published.After(time.Now())) {
+			p.Published = time.Now().Local().Format(time.RFC3339)
+		}
+	case "unlist":
+		if p.Visibility == visibilityUnlisted {
+			return nil
+		}
+		p.Visibility = visibilityUnlisted
+	}
+	return a.replacePost(p, p.Path, oldStatus, oldVisibility)
+}
+
+// Available values for the status filter
+func timelineStatusFilters() []string {
+	return []string{timelineStatusAll, string(statusPublished), string(statusDraft), string(statusScheduled), timelineStatusDeleted}
+}
+
+// Available values for the visibility filter
+func timelineVisibilityFilters() []postVisibility {
+	return []postVisibility{visibilityNil, visibilityPublic, visibilityUnlisted, visibilityPrivate}
+}
+
+func (a *goBlog) timelineBlogs() []string {
+	blogs := lo.Keys(a.cfg.Blogs)
+	sort.Strings(blogs)
+	return blogs
+}
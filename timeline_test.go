@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_timeline(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang:  "en",
+			Title: "English",
+			Sections: map[string]*configSection{
+				"posts": {Name: "posts"},
+			},
+		},
+		"de": {
+			Lang:  "de",
+			Path:  "/de",
+			Title: "Deutsch",
+			Sections: map[string]*configSection{
+				"posts": {Name: "posts"},
+			},
+		},
+	}
+	app.cfg.DefaultBlog = "en"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	for _, p := range []*post{
+		{Path: "/en/published", Blog: "en", Section: "posts", Status: statusPublished, Parameters: map[string][]string{"title": {"English published"}}},
+		{Path: "/en/draft", Blog: "en", Section: "posts", Status: statusDraft, Parameters: map[string][]string{"title": {"English draft"}}},
+		{Path: "/de/scheduled", Blog: "de", Section: "posts", Published: "2099-01-01T00:00:00Z", Parameters: map[string][]string{"title": {"German scheduled"}}},
+	} {
+		require.NoError(t, app.createPost(p))
+	}
+
+	client := newHandlerClient(app.d)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	getTimeline := func(query string) string {
+		var body string
+		err := requests.URL("http://localhost:8080"+timelinePath+query).Client(client).
+			BasicAuth("test", "test").
+			ToString(&body).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		return body
+	}
+
+	t.Run("Authentication", func(t *testing.T) {
+		var body string
+		err := requests.URL("http://localhost:8080" + timelinePath).Client(client).
+			ToString(&body).
+			Fetch(context.Background())
+		require.NoError(t, err)
+		assert.NotContains(t, body, "English published")
+	})
+
+	t.Run("All blogs and statuses", func(t *testing.T) {
+		body := getTimeline("")
+		assert.Contains(t, body, "English published")
+		assert.Contains(t, body, "English draft")
+		assert.Contains(t, body, "German scheduled")
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		body := getTimeline("?blog=de")
+		assert.NotContains(t, body, "English published")
+		assert.Contains(t, body, "German scheduled")
+
+		body = getTimeline("?status=draft")
+		assert.NotContains(t, body, "English published")
+		assert.Contains(t, body, "English draft")
+		assert.NotContains(t, body, "German scheduled")
+
+		body = getTimeline("?visibility=unlisted")
+		assert.NotContains(t, body, "English published")
+		assert.NotContains(t, body, "English draft")
+		assert.NotContains(t, body, "German scheduled")
+	})
+
+	doAction := func(action, path string) {
+		err := requests.URL("http://localhost:8080"+timelinePath+"/"+action).Client(client).
+			BasicAuth("test", "test").
+			BodyForm(url.Values{"path": {path}, "redir": {timelinePath + "?status=draft"}}).
+			CheckStatus(http.StatusFound).
+			AddValidator(func(r *http.Response) error {
+				assert.Equal(t, timelinePath+"?status=draft", r.Header.Get("Location"))
+				return nil
+			}).
+			Fetch(context.Background())
+		require.NoError(t, err)
+	}
+
+	t.Run("Publish", func(t *testing.T) {
+		doAction("publish", "/en/draft")
+		p, err := app.getPost("/en/draft")
+		require.NoError(t, err)
+		assert.Equal(t, statusPublished, p.Status)
+		assert.NotEmpty(t, p.Published)
+
+		doAction("publish", "/de/scheduled")
+		p, err = app.getPost("/de/scheduled")
+		require.NoError(t, err)
+		assert.Equal(t, statusPublished, p.Status)
+		assert.False(t, strings.HasPrefix(p.Published, "2099"))
+	})
+
+	t.Run("Unlist", func(t *testing.T) {
+		doAction("unlist", "/en/published")
+		p, err := app.getPost("/en/published")
+		require.NoError(t, err)
+		assert.Equal(t, visibilityUnlisted, p.Visibility)
+
+		body := getTimeline("?visibility=unlisted")
+		assert.Contains(t, body, "English published")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		doAction("delete", "/en/published")
+		p, err := app.getPost("/en/published")
+		require.NoError(t, err)
+		assert.True(t, p.Deleted())
+
+		assert.NotContains(t, getTimeline(""), "English published")
+		assert.Contains(t, getTimeline("?status=deleted"), "English published")
+	})
+
+	t.Run("Invalid action", func(t *testing.T) {
+		err := requests.URL("http://localhost:8080"+timelinePath+"/unknown").Client(client).
+			BasicAuth("test", "test").
+			BodyForm(url.Values{"path": {"/en/draft"}}).
+			CheckStatus(http.StatusFound).
+			Fetch(context.Background())
+		assert.Error(t, err)
+	})
+}
@@ -74,7 +74,7 @@ func (a *goBlog) startOnionService(h http.Handler) error {
 		ReadTimeout:       5 * time.Minute,
 		WriteTimeout:      5 * time.Minute,
 	}
-	a.shutdown.Add(shutdownServer(s, "tor"))
+	a.addShutdownBeforeDatabase(a.shutdownServer(s, "tor"))
 	if err = s.Serve(onion); err != nil && err != http.ErrServerClosed {
 		return err
 	}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"image/png"
+	"log"
+	"net/http"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"go.goblog.app/app/pkgs/bufferpool"
+)
+
+const (
+	totpSetting = "totp"
+
+	// Session value for the secret during enrollment
+	totpPendingSessionKey = "totppending"
+
+	settingsSetupTOTPPath   = "/totp/setup"
+	settingsEnableTOTPPath  = "/totp/enable"
+	settingsDisableTOTPPath = "/totp/disable"
+)
+
+// Load the TOTP secret from the database or migrate the configured one
+func (a *goBlog) loadTOTP() error {
+	exists, err := a.settingExists(totpSetting)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if a.cfg.User.TOTP == "" {
+			// Nothing to migrate, the secret gets saved when enabling TOTP
+			return nil
+		}
+		// Migrate to database
+		return a.saveSettingValue(totpSetting, a.cfg.User.TOTP)
+	}
+	secret, err := a.getSettingValue(totpSetting)
+	if err != nil {
+		return err
+	}
+	if a.cfg.User.TOTP != "" && a.cfg.User.TOTP != secret {
+		log.Println("TOTP secret in the config differs from the one in the database, using the database value")
+	}
+	a.cfg.User.TOTP = secret
+	return nil
+}
+
+func (a *goBlog) generateTOTPKey() (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      a.cfg.Server.PublicAddress,
+		AccountName: a.cfg.User.Nick,
+	})
+}
+
+// Get the secret of a started, but not yet finished enrollment
+func (a *goBlog) pendingTOTPSecret(r *http.Request) string {
+	ses, err := a.loginSessions.Get(r, "l")
+	if err != nil || ses == nil {
+		return ""
+	}
+	secret, _ := ses.Values[totpPendingSessionKey].(string)
+	return secret
+}
+
+func (a *goBlog) setPendingTOTPSecret(w http.ResponseWriter, r *http.Request, secret string) error {
+	ses, err := a.loginSessions.Get(r, "l")
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		delete(ses.Values, totpPendingSessionKey)
+	} else {
+		ses.Values[totpPendingSessionKey] = secret
+	}
+	return a.loginSessions.Save(r, w, ses)
+}
+
+// Create the otpauth URL and a QR code (as data URI) to scan with an authenticator app
+func (a *goBlog) totpEnrollmentData(secret string) (url, qrCode string, err error) {
+	rawSecret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      a.cfg.Server.PublicAddress,
+		AccountName: a.cfg.User.Nick,
+		Secret:      rawSecret,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", "", err
+	}
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	if err = png.Encode(buf, img); err != nil {
+		return "", "", err
+	}
+	return key.URL(), "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (a *goBlog) settingsSetupTOTP(w http.ResponseWriter, r *http.Request) {
+	_, bc := a.getBlog(r)
+	if a.cfg.User.TOTP != "" {
+		a.serveError(w, r, "Two-factor authentication is already enabled", http.StatusBadRequest)
+		return
+	}
+	key, err := a.generateTOTPKey()
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err = a.setPendingTOTPSecret(w, r, key.Secret()); err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, bc.getRelativePath(settingsPath), http.StatusFound)
+}
+
+func (a *goBlog) settingsEnableTOTP(w http.ResponseWriter, r *http.Request) {
+	_, bc := a.getBlog(r)
+	secret := a.pendingTOTPSecret(r)
+	if secret == "" {
+		a.serveError(w, r, "No two-factor authentication setup started", http.StatusBadRequest)
+		return
+	}
+	// Check that the authenticator app works before enabling
+	if !totp.Validate(r.FormValue("token"), secret) {
+		a.serveError(w, r, "Invalid code", http.StatusBadRequest)
+		return
+	}
+	if err := a.saveSettingValue(totpSetting, secret); err != nil {
+		a.serveError(w, r, "Failed to update setting in database", http.StatusInternalServerError)
+		return
+	}
+	a.cfg.User.TOTP = secret
+	_ = a.setPendingTOTPSecret(w, r, "")
+	http.Redirect(w, r, bc.getRelativePath(settingsPath), http.StatusFound)
+}
+
+func (a *goBlog) settingsDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	_, bc := a.getBlog(r)
+	if a.cfg.User.TOTP != "" && !totp.Validate(r.FormValue("token"), a.cfg.User.TOTP) {
+		a.serveError(w, r, "Invalid code", http.StatusBadRequest)
+		return
+	}
+	if err := a.saveSettingValue(totpSetting, ""); err != nil {
+		a.serveError(w, r, "Failed to update setting in database", http.StatusInternalServerError)
+		return
+	}
+	a.cfg.User.TOTP = ""
+	_ = a.setPendingTOTPSecret(w, r, "")
+	http.Redirect(w, r, bc.getRelativePath(settingsPath), http.StatusFound)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_totpSettings(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.AppPasswords = append(app.cfg.User.AppPasswords, &configAppPassword{
+		Username: "test",
+		Password: "test",
+	})
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initCache()
+	app.initSessions()
+	_ = app.initTemplateStrings()
+
+	app.d = app.buildRouter()
+
+	// Not configured, so disabled and nothing saved
+	exists, err := app.settingExists(totpSetting)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Empty(t, app.cfg.User.TOTP)
+
+	jar, _ := cookiejar.New(nil)
+	client := newHandlerClient(app.d)
+	client.Jar = jar
+
+	post := func(path string, form url.Values) int {
+		status := 0
+		_ = requests.URL("http://localhost:8080"+path).Client(client).
+			BasicAuth("test", "test").
+			BodyForm(form).
+			AddValidator(func(r *http.Response) error {
+				status = r.StatusCode
+				return nil
+			}).
+			Fetch(context.Background())
+		return status
+	}
+
+	// Start setup
+	assert.Equal(t, http.StatusOK, post("/settings"+settingsSetupTOTPPath, url.Values{}))
+
+	var settings string
+	require.NoError(t, requests.URL("http://localhost:8080/settings").Client(client).
+		BasicAuth("test", "test").ToString(&settings).Fetch(context.Background()))
+	assert.Contains(t, settings, "data:image/png;base64,")
+	assert.Contains(t, settings, "otpauth://totp/")
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/settings", nil)
+	for _, c := range jar.Cookies(req.URL) {
+		req.AddCookie(c)
+	}
+	secret := app.pendingTOTPSecret(req)
+	require.NotEmpty(t, secret)
+
+	// Wrong code doesn't enable it
+	assert.Equal(t, http.StatusBadRequest, post("/settings"+settingsEnableTOTPPath, url.Values{"token": {"000000x"}}))
+	assert.Empty(t, app.cfg.User.TOTP)
+
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, post("/settings"+settingsEnableTOTPPath, url.Values{"token": {code}}))
+	assert.Equal(t, secret, app.cfg.User.TOTP)
+	saved, err := app.getSettingValue(totpSetting)
+	require.NoError(t, err)
+	assert.Equal(t, secret, saved)
+
+	// Login now requires the code
+	assert.False(t, app.checkCredentials(app.cfg.User.Nick, app.cfg.User.Password, ""))
+	assert.True(t, app.checkCredentials(app.cfg.User.Nick, app.cfg.User.Password, code))
+
+	// Disable
+	assert.Equal(t, http.StatusBadRequest, post("/settings"+settingsDisableTOTPPath, url.Values{"token": {"000000x"}}))
+	assert.Equal(t, secret, app.cfg.User.TOTP)
+	assert.Equal(t, http.StatusOK, post("/settings"+settingsDisableTOTPPath, url.Values{"token": {code}}))
+	assert.Empty(t, app.cfg.User.TOTP)
+
+	// The configured secret is only used initially
+	app.cfg.User.TOTP = "HHUCH2SBOFXKKVCRJPVRS3W5MHX4FHXP"
+	require.NoError(t, app.loadTOTP())
+	assert.Empty(t, app.cfg.User.TOTP)
+}
+
+func Test_loadTOTPMigration(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.User.TOTP = "HHUCH2SBOFXKKVCRJPVRS3W5MHX4FHXP"
+	require.NoError(t, app.initConfig(false))
+
+	// The configured secret is migrated to the database
+	saved, err := app.getSettingValue(totpSetting)
+	require.NoError(t, err)
+	assert.Equal(t, "HHUCH2SBOFXKKVCRJPVRS3W5MHX4FHXP", saved)
+
+	// Removing it from the config doesn't disable TOTP
+	app.cfg.User.TOTP = ""
+	require.NoError(t, app.loadTOTP())
+	assert.Equal(t, "HHUCH2SBOFXKKVCRJPVRS3W5MHX4FHXP", app.cfg.User.TOTP)
+}
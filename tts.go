@@ -142,7 +142,7 @@ func (a *goBlog) createPostTTSAudio(p *post) error {
 	}
 
 	// Purge cache
-	a.cache.purge()
+	a.cache.purgePost(p.Path)
 
 	return nil
 }
@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -9,6 +12,7 @@ import (
 	"github.com/kaorimatz/go-opml"
 	"github.com/mergestat/timediff"
 	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/builderpool"
 	"go.goblog.app/app/pkgs/contenttype"
 	"go.goblog.app/app/pkgs/htmlbuilder"
 	"go.goblog.app/app/pkgs/plugintypes"
@@ -29,7 +33,14 @@ func (a *goBlog) renderBase(hb *htmlbuilder.HtmlBuilder, rd *renderData, title,
 	hb.WriteElementOpen("meta", "charset", "utf-8")
 	hb.WriteElementOpen("meta", "name", "viewport", "content", "width=device-width,initial-scale=1")
 	// CSS
-	hb.WriteElementOpen("link", "rel", "stylesheet", "href", a.assetFileName("css/styles.css"))
+	a.writeAssetStylesheet(hb, a.blogAssetFileName(rd.Blog, "css/styles.css"))
+	// Custom CSS and JS
+	if customCSS := a.customAssetPath(rd.Blog.name, ".css"); customCSS != "" {
+		a.writeAssetStylesheet(hb, customCSS)
+	}
+	if customJS := a.customAssetPath(rd.Blog.name, ".js"); customJS != "" {
+		a.writeAssetScript(hb, customJS, "defer", "")
+	}
 	// Canonical URL
 	if rd.Canonical != "" {
 		hb.WriteElementOpen("link", "rel", "canonical", "href", rd.Canonical)
@@ -42,9 +53,9 @@ func (a *goBlog) renderBase(hb *htmlbuilder.HtmlBuilder, rd *renderData, title,
 	}
 	renderedBlogTitle := a.renderMdTitle(rd.Blog.Title)
 	// Feeds
-	hb.WriteElementOpen("link", "rel", "alternate", "type", "application/rss+xml", "title", fmt.Sprintf("RSS (%s)", renderedBlogTitle), "href", a.getFullAddress(rd.Blog.Path+".rss"))
-	hb.WriteElementOpen("link", "rel", "alternate", "type", "application/atom+xml", "title", fmt.Sprintf("ATOM (%s)", renderedBlogTitle), "href", a.getFullAddress(rd.Blog.Path+".atom"))
-	hb.WriteElementOpen("link", "rel", "alternate", "type", "application/feed+json", "title", fmt.Sprintf("JSON Feed (%s)", renderedBlogTitle), "href", a.getFullAddress(rd.Blog.Path+".json"))
+	hb.WriteElementOpen("link", "rel", "alternate", "type", "application/rss+xml", "title", fmt.Sprintf("RSS (%s)", renderedBlogTitle), "href", a.getFullAddress(rd.Blog.Path+".rss"+rd.Blog.feedTokenQuery()))
+	hb.WriteElementOpen("link", "rel", "alternate", "type", "application/atom+xml", "title", fmt.Sprintf("ATOM (%s)", renderedBlogTitle), "href", a.getFullAddress(rd.Blog.Path+".atom"+rd.Blog.feedTokenQuery()))
+	hb.WriteElementOpen("link", "rel", "alternate", "type", "application/feed+json", "title", fmt.Sprintf("JSON Feed (%s)", renderedBlogTitle), "href", a.getFullAddress(rd.Blog.Path+".json"+rd.Blog.feedTokenQuery()))
 	// Webmentions
 	hb.WriteElementOpen("link", "rel", "webmention", "href", a.getFullAddress("/webmention"))
 	// Micropub
@@ -60,6 +71,10 @@ func (a *goBlog) renderBase(hb *htmlbuilder.HtmlBuilder, rd *renderData, title,
 			hb.WriteElementOpen("link", "rel", "me", "href", i)
 		}
 	}
+	if a.apEnabled() {
+		// Link back from the blog to the ActivityPub actor, so Mastodon can verify the blog URL
+		hb.WriteElementOpen("link", "rel", "me", "href", a.apIri(rd.Blog))
+	}
 	// Opensearch
 	if os := openSearchUrl(rd.Blog); os != "" {
 		hb.WriteElementOpen("link", "rel", "search", "type", "application/opensearchdescription+xml", "href", os, "title", renderedBlogTitle)
@@ -114,6 +129,28 @@ func (a *goBlog) renderBase(hb *htmlbuilder.HtmlBuilder, rd *renderData, title,
 		hb.WriteElementOpen("a", "href", "/notifications")
 		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "notifications"))
 		hb.WriteElementClose("a")
+		hb.WriteUnescaped(" &bull; ")
+		hb.WriteElementOpen("a", "href", timelinePath)
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "timeline"))
+		hb.WriteElementClose("a")
+		if a.feedReaderEnabled() {
+			hb.WriteUnescaped(" &bull; ")
+			hb.WriteElementOpen("a", "href", feedReaderPath)
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "feedreader"))
+			hb.WriteElementClose("a")
+		}
+		if a.syndicationEnabled() {
+			hb.WriteUnescaped(" &bull; ")
+			hb.WriteElementOpen("a", "href", syndicationPath)
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "syndicationstatus"))
+			hb.WriteElementClose("a")
+		}
+		if a.analyticsEnabled() {
+			hb.WriteUnescaped(" &bull; ")
+			hb.WriteElementOpen("a", "href", analyticsPath)
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "analytics"))
+			hb.WriteElementClose("a")
+		}
 		if rd.WebmentionReceivingEnabled {
 			hb.WriteUnescaped(" &bull; ")
 			hb.WriteElementOpen("a", "href", "/webmention")
@@ -145,8 +182,7 @@ func (a *goBlog) renderBase(hb *htmlbuilder.HtmlBuilder, rd *renderData, title,
 	a.renderFooter(hb, rd)
 	// Easter egg
 	if rd.EasterEgg {
-		hb.WriteElementOpen("script", "src", a.assetFileName("js/easteregg.js"), "defer", "")
-		hb.WriteElementClose("script")
+		a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/easteregg.js"), "defer", "")
 	}
 	hb.WriteElementClose("html")
 }
@@ -154,6 +190,7 @@ func (a *goBlog) renderBase(hb *htmlbuilder.HtmlBuilder, rd *renderData, title,
 type errorRenderData struct {
 	Title   string
 	Message string
+	Status  int
 }
 
 func (a *goBlog) renderError(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
@@ -177,12 +214,34 @@ func (a *goBlog) renderError(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 				hb.WriteEscaped(ed.Message)
 				hb.WriteElementClose("p")
 			}
+			if ed.Status == http.StatusNotFound || ed.Status == http.StatusGone {
+				a.renderErrorHelp(hb, rd, ed.Status)
+			}
 		},
 	)
 }
 
+// Explanation and ways to find other content on 404 and 410 error pages
+func (a *goBlog) renderErrorHelp(hb *htmlbuilder.HtmlBuilder, rd *renderData, status int) {
+	hb.WriteElementOpen("p")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, lo.If(status == http.StatusGone, "errorgone").Else("errornotfound")))
+	hb.WriteElementClose("p")
+	if sc := rd.Blog.Search; sc != nil && sc.Enabled {
+		hb.WriteElementOpen("form", "class", "fw p", "method", "post", "action", rd.Blog.getRelativePath(sc.Path))
+		hb.WriteElementOpen("input", "type", "text", "name", "q", "required", "")
+		hb.WriteElementOpen("input", "type", "submit", "value", "🔍 "+a.ts.GetTemplateStringVariant(rd.Blog.Lang, "search"))
+		hb.WriteElementClose("form")
+	}
+	hb.WriteElementOpen("p")
+	hb.WriteElementOpen("a", "href", rd.Blog.getRelativePath(""))
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "errorhome"))
+	hb.WriteElementClose("a")
+	hb.WriteElementClose("p")
+}
+
 type loginRenderData struct {
 	loginMethod, loginHeaders, loginBody string
+	loginRedirect                        string
 	totp                                 bool
 }
 
@@ -220,6 +279,19 @@ func (a *goBlog) renderLogin(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			// Submit
 			hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "login"))
 			hb.WriteElementClose("form")
+			// IndieAuth login with the configured identities
+			if a.indieAuthLoginEnabled() {
+				hb.WriteElementOpen("h2")
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "indieauthlogin"))
+				hb.WriteElementClose("h2")
+				for _, identity := range a.cfg.User.IndieAuthLogin {
+					hb.WriteElementOpen("form", "class", "fw p", "method", "post", "action", indieAuthLoginPath)
+					hb.WriteElementOpen("input", "type", "hidden", "name", "me", "value", identity)
+					hb.WriteElementOpen("input", "type", "hidden", "name", "redirect", "value", data.loginRedirect)
+					hb.WriteElementOpen("input", "type", "submit", "value", identity)
+					hb.WriteElementClose("form")
+				}
+			}
 			// Author (required for some IndieWeb apps)
 			a.renderAuthor(hb)
 			hb.WriteElementClose("main")
@@ -227,6 +299,36 @@ func (a *goBlog) renderLogin(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	)
 }
 
+func (a *goBlog) renderBlogPassphrase(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "protectedblog"))
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+			// Title
+			hb.WriteElementOpen("h1")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "protectedblog"))
+			hb.WriteElementClose("h1")
+			hb.WriteElementOpen("p")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "protectedblogdesc"))
+			hb.WriteElementClose("p")
+			// Form
+			hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+			// Hidden fields
+			hb.WriteElementOpen("input", "type", "hidden", "name", "loginaction", "value", "blogpassphrase")
+			hb.WriteElementOpen("input", "type", "hidden", "name", "blog", "value", rd.BlogString)
+			// Passphrase
+			hb.WriteElementOpen("input", "type", "password", "name", "passphrase", "autocomplete", "current-password", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "passphrase"), "required", "")
+			// Submit
+			hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "submit"))
+			hb.WriteElementClose("form")
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
 func (a *goBlog) renderSearch(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	sc := rd.Blog.Search
 	renderedSearchTitle := a.renderMdTitle(sc.Title)
@@ -296,7 +398,7 @@ func (a *goBlog) renderComment(h *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "acommentby"))
 			hb.WriteUnescaped(" ")
 			if c.Website != "" {
-				hb.WriteElementOpen("a", "class", "p-name u-url", "target", "_blank", "rel", "nofollow noopener noreferrer ugc", "href", c.Website)
+				hb.WriteElementOpen("a", append([]any{"class", "p-name u-url", "href", c.Website}, a.ugcLinkAttributes(c.Website)...)...)
 				hb.WriteEscaped(c.Name)
 				hb.WriteElementClose("a")
 			} else {
@@ -307,13 +409,13 @@ func (a *goBlog) renderComment(h *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteEscaped(":")
 			hb.WriteElementClose("p")
 			// Content
-			hb.WriteElementOpen("p", "class", "e-content")
-			hb.WriteUnescaped(c.Comment) // Already escaped
-			hb.WriteElementClose("p")
+			hb.WriteElementOpen("div", "class", "e-content")
+			hb.WriteUnescaped(a.sanitizeRemoteHTML(c.Comment))
+			hb.WriteElementClose("div")
 			// Original
 			if c.Original != "" {
 				hb.WriteElementOpen("p", "class", "")
-				hb.WriteElementOpen("a", "class", "u-url", "target", "_blank", "rel", "nofollow noopener noreferrer ugc", "href", c.Original)
+				hb.WriteElementOpen("a", append([]any{"class", "u-url", "href", c.Original}, a.ugcLinkAttributes(c.Original)...)...)
 				hb.WriteEscaped(c.Original)
 				hb.WriteElementClose("a")
 				hb.WriteElementClose("p")
@@ -340,9 +442,19 @@ type indexRenderData struct {
 	posts              []*post
 	hasPrev, hasNext   bool
 	first, prev, next  string
+	lang               string // language filter
 	summaryTemplate    summaryTyp
 }
 
+// Query for the feeds of the index, with the feed token of protected blogs and the language filter
+func (id *indexRenderData) feedQuery(bc *configBlog) string {
+	query := bc.feedTokenQuery()
+	if id.lang != "" {
+		query += lo.If(query == "", "?").Else("&") + postLangParameter + "=" + url.QueryEscape(id.lang)
+	}
+	return query
+}
+
 func (a *goBlog) renderIndex(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	id, ok := rd.Data.(*indexRenderData)
 	if !ok {
@@ -359,9 +471,9 @@ func (a *goBlog) renderIndex(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			if renderedIndexTitle != "" {
 				feedTitle = " (" + renderedIndexTitle + ")"
 			}
-			hb.WriteElementOpen("link", "rel", "alternate", "type", "application/rss+xml", "title", "RSS"+feedTitle, "href", a.getFullAddress(id.first+".rss"))
-			hb.WriteElementOpen("link", "rel", "alternate", "type", "application/atom+xml", "title", "ATOM"+feedTitle, "href", a.getFullAddress(id.first+".atom"))
-			hb.WriteElementOpen("link", "rel", "alternate", "type", "application/feed+json", "title", "JSON Feed"+feedTitle, "href", a.getFullAddress(id.first+".json"))
+			hb.WriteElementOpen("link", "rel", "alternate", "type", "application/rss+xml", "title", "RSS"+feedTitle, "href", a.getFullAddress(id.first+".rss"+id.feedQuery(rd.Blog)))
+			hb.WriteElementOpen("link", "rel", "alternate", "type", "application/atom+xml", "title", "ATOM"+feedTitle, "href", a.getFullAddress(id.first+".atom"+id.feedQuery(rd.Blog)))
+			hb.WriteElementOpen("link", "rel", "alternate", "type", "application/feed+json", "title", "JSON Feed"+feedTitle, "href", a.getFullAddress(id.first+".json"+id.feedQuery(rd.Blog)))
 		},
 		func(hb *htmlbuilder.HtmlBuilder) {
 			hb.WriteElementOpen("main", "class", "h-feed")
@@ -394,6 +506,12 @@ func (a *goBlog) renderIndex(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			}
 			// Navigation
 			a.renderPagination(hb, rd.Blog, id.hasPrev, id.hasNext, id.prev, id.next)
+			// Follow button
+			if a.apEnabled() {
+				hb.WriteElementOpen("p")
+				a.renderFollowButton(hb, rd.Blog)
+				hb.WriteElementClose("p")
+			}
 			// Author
 			a.renderAuthor(hb)
 			hb.WriteElementClose("main")
@@ -402,7 +520,9 @@ func (a *goBlog) renderIndex(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 }
 
 type blogStatsRenderData struct {
-	tableUrl string
+	tableUrl           string
+	followersHistory   []*apFollowersMonth
+	followersInstances []*apFollowersInstance
 }
 
 func (a *goBlog) renderBlogStats(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
@@ -433,8 +553,11 @@ func (a *goBlog) renderBlogStats(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteElementOpen("p", "id", "loading", "data-table", bsd.tableUrl)
 			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "loading"))
 			hb.WriteElementClose("p")
-			hb.WriteElementOpen("script", "src", a.assetFileName("js/blogstats.js"), "defer", "")
-			hb.WriteElementClose("script")
+			a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/blogstats.js"), "defer", "")
+			// ActivityPub followers
+			if len(bsd.followersHistory) > 0 {
+				a.renderBlogStatsFollowers(hb, rd, bsd)
+			}
 			hb.WriteElementClose("main")
 			// Interactions
 			if rd.Blog.commentsEnabled() {
@@ -444,6 +567,83 @@ func (a *goBlog) renderBlogStats(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	)
 }
 
+func (a *goBlog) renderBlogStatsFollowers(hb *htmlbuilder.HtmlBuilder, rd *renderData, bsd *blogStatsRenderData) {
+	hb.WriteElementOpen("h2")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apfollowers"))
+	hb.WriteElementClose("h2")
+	// Chart with the number of followers at the end of each month
+	maxTotal := lo.Max(append(lo.Map(bsd.followersHistory, func(m *apFollowersMonth, _ int) int { return m.total }), 1))
+	points := builderpool.Get()
+	for i, m := range bsd.followersHistory {
+		if i > 0 {
+			points.WriteByte(' ')
+		}
+		_, _ = fmt.Fprintf(points, "%d,%d", i*20, 100-m.total*100/maxTotal)
+	}
+	hb.WriteElementOpen(
+		"svg", "class", "followerschart", "viewBox", fmt.Sprintf("-2 -2 %d 104", lo.Max([]int{len(bsd.followersHistory) - 1, 1})*20+4),
+		"preserveAspectRatio", "none", "role", "img", "aria-label", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apfollowers"),
+	)
+	hb.WriteElementOpen("polyline", "points", points.String(), "fill", "none", "stroke", "currentColor", "stroke-width", "2", "vector-effect", "non-scaling-stroke")
+	hb.WriteElementClose("polyline")
+	hb.WriteElementClose("svg")
+	builderpool.Put(points)
+	// Table with the changes per month (newest first)
+	hb.WriteElementOpen("table")
+	hb.WriteElementOpen("thead")
+	for i, s := range []string{"month", "apfollowersadded", "apfollowersremoved", "total"} {
+		hb.WriteElementOpen("th", "class", lo.If(i == 0, "tal").Else("tar"))
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, s))
+		hb.WriteElementClose("th")
+	}
+	hb.WriteElementClose("thead")
+	hb.WriteElementOpen("tbody")
+	for i := len(bsd.followersHistory) - 1; i >= 0; i-- {
+		m := bsd.followersHistory[i]
+		hb.WriteElementOpen("tr")
+		hb.WriteElementOpen("td", "class", "tal")
+		hb.WriteEscaped(m.month)
+		hb.WriteElementClose("td")
+		for _, v := range []int{m.added, m.removed, m.total} {
+			hb.WriteElementOpen("td", "class", "tar")
+			hb.WriteEscaped(fmt.Sprint(v))
+			hb.WriteElementClose("td")
+		}
+		hb.WriteElementClose("tr")
+	}
+	hb.WriteElementClose("tbody")
+	hb.WriteElementClose("table")
+	// Followers by instance
+	if len(bsd.followersInstances) == 0 {
+		return
+	}
+	hb.WriteElementOpen("h3")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apfollowersinstances"))
+	hb.WriteElementClose("h3")
+	hb.WriteElementOpen("table")
+	hb.WriteElementOpen("thead")
+	hb.WriteElementOpen("th", "class", "tal")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apinstance"))
+	hb.WriteElementClose("th")
+	hb.WriteElementOpen("th", "class", "tar")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apfollowers"))
+	hb.WriteElementClose("th")
+	hb.WriteElementClose("thead")
+	hb.WriteElementOpen("tbody")
+	for _, instance := range bsd.followersInstances {
+		hb.WriteElementOpen("tr")
+		hb.WriteElementOpen("td", "class", "tal")
+		hb.WriteEscaped(instance.domain)
+		hb.WriteElementClose("td")
+		hb.WriteElementOpen("td", "class", "tar")
+		hb.WriteEscaped(fmt.Sprint(instance.count))
+		hb.WriteElementClose("td")
+		hb.WriteElementClose("tr")
+	}
+	hb.WriteElementClose("tbody")
+	hb.WriteElementClose("table")
+}
+
 func (a *goBlog) renderBlogStatsTable(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	bsd, ok := rd.Data.(*blogStatsData)
 	if !ok {
@@ -556,6 +756,74 @@ func (a *goBlog) renderBlogStatsTable(hb *htmlbuilder.HtmlBuilder, rd *renderDat
 	hb.WriteElementClose("table")
 }
 
+func (a *goBlog) renderArchive(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	ard, ok := rd.Data.(*archiveRenderData)
+	if !ok {
+		return
+	}
+	ac := rd.Blog.Archive
+	renderedTitle := a.renderMdTitle(ac.Title)
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, renderedTitle)
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+			// Title
+			if renderedTitle != "" {
+				hb.WriteElementOpen("h1")
+				hb.WriteEscaped(renderedTitle)
+				hb.WriteElementClose("h1")
+			}
+			// Description
+			if ac.Description != "" {
+				_ = a.renderMarkdownToWriter(hb, ac.Description, false)
+			}
+			// Calendar with the number of posts per month
+			hb.WriteElementOpen("table", "class", "archive")
+			hb.WriteElementOpen("thead")
+			hb.WriteElementOpen("th", "class", "tal")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "year"))
+			hb.WriteElementClose("th")
+			for m := 1; m <= 12; m++ {
+				hb.WriteElementOpen("th", "class", "tar")
+				hb.WriteEscaped(fmt.Sprintf("%02d", m))
+				hb.WriteElementClose("th")
+			}
+			hb.WriteElementOpen("th", "class", "tar")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "total"))
+			hb.WriteElementClose("th")
+			hb.WriteElementClose("thead")
+			hb.WriteElementOpen("tbody")
+			for _, y := range ard.years {
+				hb.WriteElementOpen("tr")
+				hb.WriteElementOpen("td", "class", "tal")
+				hb.WriteElementOpen("a", "href", rd.Blog.getRelativePath("/"+y.year))
+				hb.WriteEscaped(y.year)
+				hb.WriteElementClose("a")
+				hb.WriteElementClose("td")
+				for i, count := range y.months {
+					hb.WriteElementOpen("td", "class", "tar")
+					if count > 0 {
+						hb.WriteElementOpen("a", "href", rd.Blog.getRelativePath(fmt.Sprintf("/%s/%02d", y.year, i+1)))
+						hb.WriteEscaped(fmt.Sprint(count))
+						hb.WriteElementClose("a")
+					}
+					hb.WriteElementClose("td")
+				}
+				hb.WriteElementOpen("td", "class", "tar")
+				hb.WriteEscaped(fmt.Sprint(y.total))
+				hb.WriteElementClose("td")
+				hb.WriteElementClose("tr")
+			}
+			hb.WriteElementClose("tbody")
+			hb.WriteElementClose("table")
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
 type geoMapRenderData struct {
 	noLocations bool
 	locations   string
@@ -591,8 +859,7 @@ func (a *goBlog) renderGeoMap(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 					"data-attribution", gmd.attribution,
 				)
 				hb.WriteElementClose("div")
-				hb.WriteElementOpen("script", "src", a.assetFileName("js/geomap.js"))
-				hb.WriteElementClose("script")
+				a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/geomap.js"))
 			}
 			hb.WriteElementClose("main")
 			if rd.Blog.commentsEnabled() {
@@ -681,6 +948,7 @@ type contactRenderData struct {
 	title       string
 	description string
 	privacy     string
+	powPath     string // path to get a proof of work challenge, empty if disabled
 }
 
 func (a *goBlog) renderContact(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
@@ -707,7 +975,11 @@ func (a *goBlog) renderContact(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 				_ = a.renderMarkdownToWriter(hb, cd.description, false)
 			}
 			// Form
-			hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+			if cd.powPath != "" {
+				hb.WriteElementOpen("form", "class", "fw p", "method", "post", "data-pow", cd.powPath)
+			} else {
+				hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+			}
 			// Name (optional)
 			hb.WriteElementOpen("input", "type", "text", "name", "name", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "nameopt"))
 			// Website (optional)
@@ -717,14 +989,19 @@ func (a *goBlog) renderContact(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			// Message (required)
 			hb.WriteElementOpen("textarea", "name", "message", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "message"), "required", "")
 			hb.WriteElementClose("textarea")
+			// Spam protection
+			a.renderSpamProtectionFields(hb, rd.Blog, cd.powPath != "")
 			// Send
+			submitText := a.ts.GetTemplateStringVariant(rd.Blog.Lang, "contactsend")
 			if cd.privacy != "" {
 				_ = a.renderMarkdownToWriter(hb, cd.privacy, false)
-				hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "contactagreesend"))
-			} else {
-				hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "contactsend"))
+				submitText = a.ts.GetTemplateStringVariant(rd.Blog.Lang, "contactagreesend")
 			}
+			a.renderSpamProtectionSubmit(hb, submitText, cd.powPath != "")
 			hb.WriteElementsClose("form", "main")
+			if cd.powPath != "" {
+				a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/pow.js"), "defer", "")
+			}
 		},
 	)
 }
@@ -740,6 +1017,73 @@ func (a *goBlog) renderContactSent(hb *htmlbuilder.HtmlBuilder, rd *renderData)
 	)
 }
 
+type newsletterRenderData struct {
+	title       string
+	description string
+	privacy     string
+	action      string
+}
+
+func (a *goBlog) renderNewsletter(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	nd, ok := rd.Data.(*newsletterRenderData)
+	if !ok {
+		return
+	}
+	renderedTitle := a.renderMdTitle(defaultIfEmpty(nd.title, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "newsletter")))
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, renderedTitle)
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+			// Title
+			hb.WriteElementOpen("h1")
+			hb.WriteEscaped(renderedTitle)
+			hb.WriteElementClose("h1")
+			// Description
+			if nd.description != "" {
+				_ = a.renderMarkdownToWriter(hb, nd.description, false)
+			}
+			// Form
+			hb.WriteElementOpen("form", "class", "fw p", "method", "post", "action", nd.action)
+			hb.WriteElementOpen("input", "type", "email", "name", "email", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "newsletteremail"), "required", "")
+			if nd.privacy != "" {
+				_ = a.renderMarkdownToWriter(hb, nd.privacy, false)
+			}
+			hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "newslettersubscribe"))
+			hb.WriteElementsClose("form", "main")
+		},
+	)
+}
+
+type newsletterMessageRenderData struct {
+	message          string // template string
+	unsubscribeToken string // show unsubscribe button
+}
+
+func (a *goBlog) renderNewsletterMessage(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	nd, ok := rd.Data.(*newsletterMessageRenderData)
+	if !ok {
+		return
+	}
+	a.renderBase(
+		hb, rd, nil,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementsOpen("main", "p")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, nd.message))
+			hb.WriteElementClose("p")
+			if nd.unsubscribeToken != "" {
+				hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+				hb.WriteElementOpen("input", "type", "hidden", "name", "token", "value", nd.unsubscribeToken)
+				hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "newsletterunsubscribe"))
+				hb.WriteElementClose("form")
+			}
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
 type captchaRenderData struct {
 	captchaMethod  string
 	captchaHeaders string
@@ -843,8 +1187,9 @@ func (a *goBlog) renderPost(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			} else {
 				a.renderTitleTag(hb, rd.Blog, a.fallbackTitle(p))
 			}
-			hb.WriteElementOpen("link", "rel", "stylesheet", "href", a.assetFileName("css/chroma.css"))
+			a.writeAssetStylesheet(hb, a.blogAssetFileName(rd.Blog, "css/chroma.css"))
 			a.renderPostHeadMeta(hb, p)
+			a.renderPostHreflang(hb, p)
 			if su := a.shortPostURL(p); su != "" {
 				hb.WriteElementOpen("link", "rel", "shortlink", "href", su)
 			}
@@ -861,7 +1206,11 @@ func (a *goBlog) renderPost(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteElementOpen("data", "value", a.getFullAddress(p.Path), "class", "u-url hide")
 			hb.WriteElementClose("data")
 			// Start article
-			hb.WriteElementOpen("article")
+			if lang := a.postLang(p); lang != rd.Blog.Lang {
+				hb.WriteElementOpen("article", "lang", lang)
+			} else {
+				hb.WriteElementOpen("article")
+			}
 			// Title
 			a.renderPostTitle(hb, p)
 			// Post meta
@@ -870,13 +1219,14 @@ func (a *goBlog) renderPost(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteElementOpen("div", "class", "actions")
 			// Share button
 			a.renderShareButton(hb, p, rd.Blog)
+			// Follow button
+			a.renderFollowButton(hb, rd.Blog)
 			// Translate button
 			a.renderTranslateButton(hb, p, rd.Blog)
 			// Speak button
 			hb.WriteElementOpen("button", "id", "speakBtn", "class", "hide", "data-speak", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "speak"), "data-stopspeak", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "stopspeak"))
 			hb.WriteElementClose("button")
-			hb.WriteElementOpen("script", "defer", "", "src", lo.If(p.TTS() != "", a.assetFileName("js/tts.js")).Else(a.assetFileName("js/speak.js")))
-			hb.WriteElementClose("script")
+			a.writeAssetScript(hb, lo.If(p.TTS() != "", a.blogAssetFileName(rd.Blog, "js/tts.js")).Else(a.blogAssetFileName(rd.Blog, "js/speak.js")), "defer", "")
 			// Close post actions
 			hb.WriteElementClose("div")
 			// TTS
@@ -890,17 +1240,32 @@ func (a *goBlog) renderPost(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			}
 			// Old content warning
 			a.renderOldContentWarning(hb, p, rd.Blog)
+			// Table of contents
+			a.renderPostTOC(hb, p, rd.Blog)
 			// Content
 			a.postHtmlToWriter(hb, &postHtmlOptions{p: p})
+			if containsMarkdownEmbed(p.Content) {
+				a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/embed.js"), "defer", "")
+			}
 			// External Videp
 			a.renderPostVideo(hb, p)
 			// GPS Track
 			a.renderPostGPX(hb, p, rd.Blog)
+			// Location map
+			a.renderPostLocationMap(hb, p, rd.Blog)
+			// Series
+			a.renderPostSeries(hb, p, rd.Blog)
 			// Taxonomies
 			a.renderPostTax(hb, p, rd.Blog)
+			// Previous versions
+			a.renderPostSnapshots(hb, p, rd.Blog)
 			hb.WriteElementClose("article")
-			// Author
-			a.renderAuthor(hb)
+			// Related posts
+			a.renderRelatedPosts(hb, p, rd.Blog)
+			// Author, if not attributed to another author
+			if a.postAuthor(p) == nil {
+				a.renderAuthor(hb)
+			}
 			hb.WriteElementClose("main")
 			// Reactions
 			a.renderPostReactions(hb, p)
@@ -935,9 +1300,20 @@ func (a *goBlog) renderPost(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 					hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "gentts"))
 					hb.WriteElementClose("form")
 				}
-				hb.WriteElementOpen("script", "defer", "", "src", a.assetFileName("js/formconfirm.js"))
-				hb.WriteElementClose("script")
+				a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/formconfirm.js"), "defer", "")
 				hb.WriteElementClose("div")
+				// Preview link
+				if p.Status == statusDraft || p.Status == statusScheduled {
+					if link, err := a.previewLink(p); err == nil {
+						hb.WriteElementOpen("p")
+						hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "previewlink"))
+						hb.WriteEscaped(" ")
+						hb.WriteElementOpen("a", "href", link, "rel", "nofollow")
+						hb.WriteEscaped(link)
+						hb.WriteElementClose("a")
+						hb.WriteElementClose("p")
+					}
+				}
 			}
 			// Comments
 			if a.commentsEnabledForPost(p) {
@@ -987,11 +1363,18 @@ func (a *goBlog) renderStaticHome(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	)
 }
 
+type indieAuthRenderData struct {
+	req        *indieauth.AuthenticationRequest
+	action     string // form action, default is the IndieAuth accept endpoint
+	clientName string // name of a registered app
+}
+
 func (a *goBlog) renderIndieAuth(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
-	indieAuthRequest, ok := rd.Data.(*indieauth.AuthenticationRequest)
+	iard, ok := rd.Data.(*indieAuthRenderData)
 	if !ok {
 		return
 	}
+	indieAuthRequest := iard.req
 	a.renderBase(
 		hb, rd,
 		func(hb *htmlbuilder.HtmlBuilder) {
@@ -1005,7 +1388,7 @@ func (a *goBlog) renderIndieAuth(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteElementClose("h1")
 			hb.WriteElementClose("main")
 			// Form
-			hb.WriteElementOpen("form", "method", "post", "action", "/indieauth/accept", "class", "p")
+			hb.WriteElementOpen("form", "method", "post", "action", defaultIfEmpty(iard.action, indieAuthPath+"/accept"), "class", "p")
 			// Scopes
 			if scopes := indieAuthRequest.Scopes; len(scopes) > 0 {
 				hb.WriteElementOpen("h3")
@@ -1022,6 +1405,14 @@ func (a *goBlog) renderIndieAuth(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 				}
 				hb.WriteElementClose("ul")
 			}
+			// Client name
+			if iard.clientName != "" {
+				hb.WriteElementOpen("p")
+				hb.WriteElementOpen("strong")
+				hb.WriteEscaped(iard.clientName)
+				hb.WriteElementClose("strong")
+				hb.WriteElementClose("p")
+			}
 			// Client ID
 			hb.WriteElementOpen("p")
 			hb.WriteElementOpen("strong")
@@ -1051,9 +1442,26 @@ func (a *goBlog) renderIndieAuth(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	)
 }
 
+func (a *goBlog) renderMastodonAuthCode(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	code, ok := rd.Data.(string)
+	if !ok {
+		return
+	}
+	a.renderBase(
+		hb, rd, nil,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementsOpen("main", "p")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "authcode"))
+			hb.WriteElementClose("p")
+			hb.WriteElementOpen("input", "type", "text", "class", "fw", "readonly", "", "value", code)
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
 type editorFilesRenderData struct {
-	files []*mediaFile
-	uses  []int
+	files   []*mediaFile
+	orphans bool
 }
 
 func (a *goBlog) renderEditorFiles(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
@@ -1061,6 +1469,7 @@ func (a *goBlog) renderEditorFiles(hb *htmlbuilder.HtmlBuilder, rd *renderData)
 	if !ok {
 		return
 	}
+	filesPath := rd.Blog.getRelativePath("/editor/files")
 	a.renderBase(
 		hb, rd,
 		func(hb *htmlbuilder.HtmlBuilder) {
@@ -1072,33 +1481,63 @@ func (a *goBlog) renderEditorFiles(hb *htmlbuilder.HtmlBuilder, rd *renderData)
 			hb.WriteElementOpen("h1")
 			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "mediafiles"))
 			hb.WriteElementClose("h1")
+			// Filter
+			hb.WriteElementOpen("p")
+			if ef.orphans {
+				hb.WriteElementOpen("a", "href", filesPath)
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "allfiles"))
+			} else {
+				hb.WriteElementOpen("a", "href", filesPath+"?"+mediaOrphansParam+"=true")
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "unusedfiles"))
+			}
+			hb.WriteElementClose("a")
+			hb.WriteElementClose("p")
 			// Files
 			if len(ef.files) > 0 {
-				// Form
-				hb.WriteElementOpen("form", "method", "post", "class", "fw p")
-				// Select with number of uses
-				hb.WriteElementOpen("select", "name", "filename")
-				usesString := a.ts.GetTemplateStringVariant(rd.Blog.Lang, "fileuses")
-				for i, f := range ef.files {
-					hb.WriteElementOpen("option", "value", f.Name)
-					hb.WriteEscaped(fmt.Sprintf("%s (%s), %s, ~%d %s", f.Name, f.Time.Local().Format(isoDateFormat), mBytesString(f.Size), ef.uses[i], usesString))
-					hb.WriteElementClose("option")
+				hb.WriteElementOpen("table")
+				hb.WriteElementOpen("thead")
+				for i, s := range []string{"file", "type", "size", "date", "fileuses", ""} {
+					hb.WriteElementOpen("th", "class", lo.If(i == 0 || i == 1, "tal").Else("tar"))
+					if s != "" {
+						hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, s))
+					}
+					hb.WriteElementClose("th")
 				}
-				hb.WriteElementClose("select")
-				// View button
-				hb.WriteElementOpen(
-					"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "view"),
-					"formaction", rd.Blog.getRelativePath("/editor/files/view"),
-				)
-				// Delete button
-				hb.WriteElementOpen(
-					"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "delete"),
-					"formaction", rd.Blog.getRelativePath("/editor/files/delete"),
-					"class", "confirm", "data-confirmmessage", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "confirmdelete"),
-				)
-				hb.WriteElementOpen("script", "src", a.assetFileName("js/formconfirm.js"), "defer", "")
-				hb.WriteElementClose("script")
-				hb.WriteElementClose("form")
+				hb.WriteElementClose("thead")
+				hb.WriteElementOpen("tbody")
+				for _, f := range ef.files {
+					hb.WriteElementOpen("tr")
+					hb.WriteElementOpen("td", "class", "tal")
+					hb.WriteElementOpen("a", "href", f.Location, "target", "_blank")
+					hb.WriteEscaped(f.Name)
+					hb.WriteElementClose("a")
+					hb.WriteElementClose("td")
+					hb.WriteElementOpen("td", "class", "tal")
+					hb.WriteEscaped(f.Type)
+					hb.WriteElementClose("td")
+					hb.WriteElementOpen("td", "class", "tar")
+					hb.WriteEscaped(mBytesString(f.Size))
+					hb.WriteElementClose("td")
+					hb.WriteElementOpen("td", "class", "tar")
+					hb.WriteEscaped(f.Time.Local().Format(isoDateFormat))
+					hb.WriteElementClose("td")
+					hb.WriteElementOpen("td", "class", "tar")
+					hb.WriteEscaped(fmt.Sprintf("~%d", f.Uses))
+					hb.WriteElementClose("td")
+					hb.WriteElementOpen("td", "class", "tar")
+					hb.WriteElementOpen("form", "method", "post", "action", filesPath+"/delete")
+					hb.WriteElementOpen("input", "type", "hidden", "name", "filename", "value", f.Name)
+					hb.WriteElementOpen(
+						"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "delete"),
+						"class", "confirm", "data-confirmmessage", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "confirmdelete"),
+					)
+					hb.WriteElementClose("form")
+					hb.WriteElementClose("td")
+					hb.WriteElementClose("tr")
+				}
+				hb.WriteElementClose("tbody")
+				hb.WriteElementClose("table")
+				a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/formconfirm.js"), "defer", "")
 			} else {
 				hb.WriteElementOpen("p")
 				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "nofiles"))
@@ -1115,6 +1554,70 @@ type notificationsRenderData struct {
 	prev, next       string
 }
 
+func (a *goBlog) renderAnalytics(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	ad, ok := rd.Data.(*analyticsData)
+	if !ok {
+		return
+	}
+	renderTable := func(title, keyTitle string, counts []*analyticsCount, link bool) {
+		hb.WriteElementOpen("h2")
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, title))
+		hb.WriteElementClose("h2")
+		if len(counts) == 0 {
+			hb.WriteElementOpen("p")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "nodata"))
+			hb.WriteElementClose("p")
+			return
+		}
+		hb.WriteElementOpen("table")
+		hb.WriteElementOpen("thead")
+		hb.WriteElementOpen("th", "class", "tal")
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, keyTitle))
+		hb.WriteElementClose("th")
+		hb.WriteElementOpen("th", "class", "tar")
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "pageviews"))
+		hb.WriteElementClose("th")
+		hb.WriteElementClose("thead")
+		hb.WriteElementOpen("tbody")
+		for _, c := range counts {
+			hb.WriteElementOpen("tr")
+			hb.WriteElementOpen("td", "class", "tal")
+			if link {
+				hb.WriteElementOpen("a", "href", c.Key)
+				hb.WriteEscaped(c.Key)
+				hb.WriteElementClose("a")
+			} else {
+				hb.WriteEscaped(c.Key)
+			}
+			hb.WriteElementClose("td")
+			hb.WriteElementOpen("td", "class", "tar")
+			hb.WriteEscaped(fmt.Sprint(c.Hits))
+			hb.WriteElementClose("td")
+			hb.WriteElementClose("tr")
+		}
+		hb.WriteElementClose("tbody")
+		hb.WriteElementClose("table")
+	}
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "analytics"))
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+			// Title
+			hb.WriteElementOpen("h1")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "analytics"))
+			hb.WriteElementClose("h1")
+			// Tables
+			renderTable("toppages", "path", ad.Paths, true)
+			renderTable("topreferrers", "referrer", ad.Referrers, false)
+			renderTable("dailyviews", "day", ad.Days, false)
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
 func (a *goBlog) renderNotificationsAdmin(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	nrd, ok := rd.Data.(*notificationsRenderData)
 	if !ok {
@@ -1200,7 +1703,7 @@ func (a *goBlog) renderCommentsAdmin(hb *htmlbuilder.HtmlBuilder, rd *renderData
 				hb.WriteElementOpen("br")
 				hb.WriteEscaped("Name: ")
 				if c.Website != "" {
-					hb.WriteElementOpen("a", "href", c.Website, "target", "_blank", "rel", "nofollow noopener noreferrer ugc")
+					hb.WriteElementOpen("a", append([]any{"href", c.Website}, a.ugcLinkAttributes(c.Website)...)...)
 				}
 				hb.WriteEscaped(c.Name)
 				if c.Website != "" {
@@ -1209,15 +1712,15 @@ func (a *goBlog) renderCommentsAdmin(hb *htmlbuilder.HtmlBuilder, rd *renderData
 				if c.Original != "" {
 					hb.WriteElementOpen("br")
 					hb.WriteEscaped("Original: ")
-					hb.WriteElementOpen("a", "href", c.Original, "target", "_blank", "rel", "nofollow noopener noreferrer ugc")
+					hb.WriteElementOpen("a", append([]any{"href", c.Original}, a.ugcLinkAttributes(c.Original)...)...)
 					hb.WriteEscaped(c.Original)
 					hb.WriteElementClose("a")
 				}
 				hb.WriteElementClose("p")
 				// Comment
-				hb.WriteElementOpen("p")
-				hb.WriteUnescaped(c.Comment)
-				hb.WriteElementClose("p")
+				hb.WriteElementOpen("div")
+				hb.WriteUnescaped(a.sanitizeRemoteHTML(c.Comment))
+				hb.WriteElementClose("div")
 				// Delete form
 				hb.WriteElementOpen("form", "class", "actions", "method", "post", "action", rd.Blog.getRelativePath(commentPath+commentDeleteSubPath))
 				hb.WriteElementOpen("input", "type", "hidden", "name", "commentid", "value", c.ID)
@@ -1279,6 +1782,10 @@ func (a *goBlog) renderWebmentionAdmin(hb *htmlbuilder.HtmlBuilder, rd *renderDa
 				hb.WriteEscaped(m.Target)
 				hb.WriteElementClose("a")
 				hb.WriteElementOpen("br")
+				// Type
+				hb.WriteEscaped("Type: ")
+				hb.WriteEscaped(string(m.Type))
+				hb.WriteElementOpen("br")
 				// Date
 				hb.WriteEscaped("Created: ")
 				hb.WriteEscaped(timediff.TimeDiff(time.Unix(m.Created, 0), timediff.WithLocale(tdLocale)))
@@ -1308,8 +1815,8 @@ func (a *goBlog) renderWebmentionAdmin(hb *htmlbuilder.HtmlBuilder, rd *renderDa
 				hb.WriteElementOpen("form", "method", "post", "class", "actions")
 				hb.WriteElementOpen("input", "type", "hidden", "name", "mentionid", "value", m.ID)
 				hb.WriteElementOpen("input", "type", "hidden", "name", "redir", "value", fmt.Sprintf("%s#mention-%d", wrd.current, m.ID))
-				if m.Status == webmentionStatusVerified {
-					// Approve verified mention
+				if m.Status != webmentionStatusApproved {
+					// Approve mention
 					hb.WriteElementOpen("input", "type", "submit", "formaction", "/webmention/approve", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "approve"))
 				}
 				// Delete mention
@@ -1325,9 +1832,257 @@ func (a *goBlog) renderWebmentionAdmin(hb *htmlbuilder.HtmlBuilder, rd *renderDa
 	)
 }
 
+type timelineRenderData struct {
+	posts               []*post
+	filter              *timelineFilter
+	hasPrev, hasNext    bool
+	prev, current, next string
+}
+
+func (a *goBlog) renderTimeline(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	trd, ok := rd.Data.(*timelineRenderData)
+	if !ok {
+		return
+	}
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "timeline"))
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+			// Title
+			hb.WriteElementOpen("h1")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "timeline"))
+			hb.WriteElementClose("h1")
+			_ = a.renderMarkdownToWriter(hb, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "timelinedesc"), false)
+			// Filters
+			allString := a.ts.GetTemplateStringVariant(rd.Blog.Lang, "all")
+			filterSelect := func(name, title string, values []string, selected string) {
+				hb.WriteElementOpen("label")
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, title) + " ")
+				hb.WriteElementOpen("select", "name", name)
+				for _, v := range values {
+					hb.WriteElementOpen("option", "value", v, lo.If(v == selected, "selected").Else(""), "")
+					hb.WriteEscaped(defaultIfEmpty(v, allString))
+					hb.WriteElementClose("option")
+				}
+				hb.WriteElementClose("select")
+				hb.WriteElementClose("label")
+				hb.WriteEscaped(" ")
+			}
+			hb.WriteElementOpen("form", "class", "p", "method", "get", "action", timelinePath)
+			filterSelect("blog", "blog", append([]string{""}, a.timelineBlogs()...), trd.filter.blog)
+			filterSelect("status", "status", timelineStatusFilters(), trd.filter.status)
+			filterSelect("visibility", "visibility", lo.Map(timelineVisibilityFilters(), func(v postVisibility, _ int) string { return string(v) }), string(trd.filter.visibility))
+			hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "filter"))
+			hb.WriteElementClose("form")
+			// Posts
+			for i, p := range trd.posts {
+				id := fmt.Sprintf("post-%d", i)
+				hb.WriteElementOpen("div", "id", id, "class", "p")
+				hb.WriteElementOpen("p")
+				// Title
+				hb.WriteElementOpen("a", "href", p.Path)
+				hb.WriteEscaped(defaultIfEmpty(p.RenderedTitle, defaultIfEmpty(a.fallbackTitle(p), p.Path)))
+				hb.WriteElementClose("a")
+				hb.WriteElementOpen("br")
+				// Meta
+				meta := []string{defaultIfEmpty(a.getBlogFromPost(p).Title, p.Blog), string(p.Status), string(p.Visibility)}
+				if p.Published != "" {
+					meta = append(meta, toLocalSafe(p.Published))
+				}
+				hb.WriteElementOpen("small")
+				hb.WriteEscaped(strings.Join(lo.Compact(meta), ", "))
+				hb.WriteElementClose("small")
+				hb.WriteElementClose("p")
+				// Actions
+				hb.WriteElementOpen("form", "method", "post", "class", "actions")
+				hb.WriteElementOpen("input", "type", "hidden", "name", "path", "value", p.Path)
+				hb.WriteElementOpen("input", "type", "hidden", "name", "redir", "value", trd.current+"#"+id)
+				if !p.Deleted() {
+					if p.Status != statusPublished {
+						hb.WriteElementOpen("input", "type", "submit", "formaction", timelinePath+"/publish", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "publish"))
+					}
+					if p.Visibility == visibilityPublic {
+						hb.WriteElementOpen("input", "type", "submit", "formaction", timelinePath+"/unlist", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "unlist"))
+					}
+				}
+				hb.WriteElementOpen(
+					"input", "type", "submit", "formaction", timelinePath+"/delete", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "delete"),
+					"class", "confirm", "data-confirmmessage", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "confirmdelete"),
+				)
+				hb.WriteElementClose("form")
+				hb.WriteElementClose("div")
+			}
+			// Pagination
+			a.renderPagination(hb, rd.Blog, trd.hasPrev, trd.hasNext, trd.prev, trd.next)
+			hb.WriteElementClose("main")
+			// Script
+			a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/formconfirm.js"), "defer", "")
+		},
+	)
+}
+
+type feedReaderRenderData struct {
+	items               []*feedReaderItem
+	hasPrev, hasNext    bool
+	prev, current, next string
+}
+
+func (a *goBlog) renderFeedReader(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	frd, ok := rd.Data.(*feedReaderRenderData)
+	if !ok {
+		return
+	}
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "feedreader"))
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+			// Title
+			hb.WriteElementOpen("h1")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "feedreader"))
+			hb.WriteElementClose("h1")
+			_ = a.renderMarkdownToWriter(hb, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "feedreaderdesc"), false)
+			// Items
+			for i, item := range frd.items {
+				id := fmt.Sprintf("item-%d", i)
+				hb.WriteElementOpen("div", "id", id, "class", "p")
+				hb.WriteElementOpen("p")
+				// Title
+				// Only http(s) links from the feeds
+				if isHTTPURL(item.Link) {
+					hb.WriteElementOpen("a", "href", item.Link, "target", "_blank", "rel", "noopener noreferrer")
+					hb.WriteEscaped(defaultIfEmpty(item.Title, item.Link))
+					hb.WriteElementClose("a")
+				} else {
+					hb.WriteEscaped(defaultIfEmpty(item.Title, item.Link))
+				}
+				hb.WriteElementOpen("br")
+				// Meta
+				hb.WriteElementOpen("small")
+				hb.WriteEscaped(strings.Join(lo.Compact([]string{item.Feed, toLocalSafe(item.Published)}), ", "))
+				hb.WriteElementClose("small")
+				hb.WriteElementClose("p")
+				// Summary
+				if item.Summary != "" {
+					hb.WriteElementOpen("p")
+					hb.WriteEscaped(item.Summary)
+					hb.WriteElementClose("p")
+				}
+				// Actions
+				hb.WriteElementOpen("form", "method", "post", "class", "actions")
+				hb.WriteElementOpen("input", "type", "hidden", "name", "feed", "value", item.Feed)
+				hb.WriteElementOpen("input", "type", "hidden", "name", "id", "value", item.ID)
+				hb.WriteElementOpen("input", "type", "hidden", "name", "redir", "value", frd.current+"#"+id)
+				hb.WriteElementOpen("input", "type", "submit", "formaction", feedReaderPath+"/bookmark", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "bookmark"))
+				hb.WriteElementOpen("input", "type", "submit", "formaction", feedReaderPath+"/reply", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "reply"))
+				hb.WriteElementOpen("input", "type", "submit", "formaction", feedReaderPath+"/dismiss", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "dismiss"))
+				hb.WriteElementClose("form")
+				hb.WriteElementClose("div")
+			}
+			// Pagination
+			a.renderPagination(hb, rd.Blog, frd.hasPrev, frd.hasNext, frd.prev, frd.next)
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
+type syndicationStatusRenderData struct {
+	statuses            []*syndicationStatus
+	hasPrev, hasNext    bool
+	prev, current, next string
+}
+
+func (a *goBlog) renderSyndicationStatus(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	srd, ok := rd.Data.(*syndicationStatusRenderData)
+	if !ok {
+		return
+	}
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "syndicationstatus"))
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+			// Title
+			hb.WriteElementOpen("h1")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "syndicationstatus"))
+			hb.WriteElementClose("h1")
+			_ = a.renderMarkdownToWriter(hb, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "syndicationstatusdesc"), false)
+			if len(srd.statuses) == 0 {
+				hb.WriteElementOpen("p")
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "nodata"))
+				hb.WriteElementClose("p")
+			}
+			// Statuses
+			for i, s := range srd.statuses {
+				id := fmt.Sprintf("status-%d", i)
+				hb.WriteElementOpen("div", "id", id, "class", "p")
+				hb.WriteElementOpen("p")
+				// Post
+				hb.WriteElementOpen("a", "href", s.Path)
+				hb.WriteEscaped(s.Path)
+				hb.WriteElementClose("a")
+				hb.WriteUnescaped(" &rarr; ")
+				target := s.Target
+				if t := a.syndicationTarget(s.Target); t != nil {
+					target = defaultIfEmpty(t.Name, t.UID)
+				}
+				hb.WriteEscaped(target)
+				hb.WriteElementOpen("br")
+				// Meta
+				hb.WriteElementOpen("small")
+				hb.WriteElementOpen("b", "class", "syndication-"+s.Status)
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "syndication"+s.Status))
+				hb.WriteElementClose("b")
+				hb.WriteEscaped(", " + a.ts.GetTemplateStringVariant(rd.Blog.Lang, "attempts") + ": " + fmt.Sprint(s.Attempts))
+				if s.Updated != "" {
+					hb.WriteEscaped(", " + toLocalSafe(s.Updated))
+				}
+				hb.WriteElementClose("small")
+				hb.WriteElementClose("p")
+				// Result
+				if s.URL != "" {
+					hb.WriteElementOpen("p")
+					hb.WriteElementOpen("a", "href", s.URL, "target", "_blank", "rel", "noopener noreferrer")
+					hb.WriteEscaped(s.URL)
+					hb.WriteElementClose("a")
+					hb.WriteElementClose("p")
+				}
+				if s.Error != "" {
+					hb.WriteElementOpen("p")
+					hb.WriteElementOpen("code")
+					hb.WriteEscaped(s.Error)
+					hb.WriteElementClose("code")
+					hb.WriteElementClose("p")
+				}
+				// Retry
+				if s.Status != syndicationStatusPending && a.syndicationTarget(s.Target) != nil {
+					hb.WriteElementOpen("form", "method", "post", "class", "actions", "action", syndicationPath+"/retry")
+					hb.WriteElementOpen("input", "type", "hidden", "name", "path", "value", s.Path)
+					hb.WriteElementOpen("input", "type", "hidden", "name", "target", "value", s.Target)
+					hb.WriteElementOpen("input", "type", "hidden", "name", "redir", "value", srd.current+"#"+id)
+					hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "retry"))
+					hb.WriteElementClose("form")
+				}
+				hb.WriteElementClose("div")
+			}
+			// Pagination
+			a.renderPagination(hb, rd.Blog, srd.hasPrev, srd.hasNext, srd.prev, srd.next)
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
 type editorRenderData struct {
 	updatePostUrl     string
 	updatePostContent string
+	updatePostETag    string
 	presetParams      map[string][]string
 }
 
@@ -1341,7 +2096,7 @@ func (a *goBlog) renderEditor(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 		func(hb *htmlbuilder.HtmlBuilder) {
 			a.renderTitleTag(hb, rd.Blog, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "editor"))
 			// Chroma CSS
-			hb.WriteElementOpen("link", "rel", "stylesheet", "href", a.assetFileName("css/chroma.css"))
+			a.writeAssetStylesheet(hb, a.blogAssetFileName(rd.Blog, "css/chroma.css"))
 		},
 		func(hb *htmlbuilder.HtmlBuilder) {
 			hb.WriteElementOpen("main")
@@ -1358,9 +2113,18 @@ func (a *goBlog) renderEditor(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteElementOpen("form", "method", "post", "class", "fw p")
 			hb.WriteElementOpen("input", "type", "hidden", "name", "editoraction", "value", "createpost")
 			hb.WriteElementOpen(
-				"input", "id", "templatebtn", "type", "button",
+				"input", "id", "templatebtn", "class", "templatebtn", "type", "button",
 				"value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "editorusetemplate"),
 			)
+			for _, name := range rd.Blog.postTemplateNames() {
+				pt := rd.Blog.PostTemplates[name]
+				hb.WriteEscaped(" ")
+				hb.WriteElementOpen(
+					"input", "class", "templatebtn", "type", "button",
+					"value", defaultIfEmpty(pt.Title, name),
+					"data-template", a.editorPostTemplate(rd.BlogString, rd.Blog, pt, edrd.presetParams),
+				)
+			}
 			hb.WriteElementOpen(
 				"textarea",
 				"id", "editor-create",
@@ -1370,7 +2134,7 @@ func (a *goBlog) renderEditor(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 				"data-preview", "post-preview",
 				"data-previewws", rd.Blog.getRelativePath("/editor/preview"),
 				"data-syncws", rd.Blog.getRelativePath("/editor/sync"),
-				"data-template", a.editorPostTemplate(rd.BlogString, rd.Blog, edrd.presetParams),
+				"data-template", a.editorPostTemplate(rd.BlogString, rd.Blog, nil, edrd.presetParams),
 			)
 			hb.WriteElementClose("textarea")
 			hb.WriteElementOpen("div", "id", "post-preview", "class", "hide")
@@ -1386,6 +2150,7 @@ func (a *goBlog) renderEditor(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 				hb.WriteElementOpen("form", "method", "post", "class", "fw p", "action", "#update")
 				hb.WriteElementOpen("input", "type", "hidden", "name", "editoraction", "value", "updatepost")
 				hb.WriteElementOpen("input", "type", "hidden", "name", "url", "value", edrd.updatePostUrl)
+				hb.WriteElementOpen("input", "type", "hidden", "name", "etag", "value", edrd.updatePostETag)
 				hb.WriteElementOpen(
 					"textarea",
 					"id", "editor-update",
@@ -1468,11 +2233,23 @@ func (a *goBlog) renderEditor(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "upload"))
 			hb.WriteElementClose("form")
 
+			// Year in review
+			hb.WriteElementOpen("h2")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "yearinreview"))
+			hb.WriteElementClose("h2")
+			hb.WriteElementOpen("p")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "yearinreviewdesc"))
+			hb.WriteElementClose("p")
+			hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+			hb.WriteElementOpen("input", "type", "hidden", "name", "editoraction", "value", "yearinreview")
+			hb.WriteElementOpen("input", "type", "number", "name", "year", "min", "1", "required", "", "value", time.Now().Year()-1)
+			hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "yearinreviewcreate"))
+			hb.WriteElementClose("form")
+
 			hb.WriteElementClose("main")
 
 			// Script
-			hb.WriteElementOpen("script", "src", a.assetFileName("js/editor.js"), "defer", "")
-			hb.WriteElementClose("script")
+			a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/editor.js"), "defer", "")
 		},
 	)
 }
@@ -1488,8 +2265,14 @@ type settingsRenderData struct {
 	addReplyContext       bool
 	addLikeTitle          bool
 	addLikeContext        bool
+	addRepostTitle        bool
+	addRepostContext      bool
 	userNick              string
 	userName              string
+	identities            []string
+	identityStatuses      map[string]*relMeStatus
+	totpEnabled           bool
+	totpURL, totpQRCode   string
 }
 
 func (a *goBlog) renderSettings(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
@@ -1564,6 +2347,20 @@ func (a *goBlog) renderSettings(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 				addLikeContextSetting,
 				srd.addLikeContext,
 			)
+			// Add repost title
+			a.renderBooleanSetting(hb, rd,
+				rd.Blog.getRelativePath(settingsPath+settingsAddRepostTitlePath),
+				a.ts.GetTemplateStringVariant(rd.Blog.Lang, "addreposttitledesc"),
+				addRepostTitleSetting,
+				srd.addRepostTitle,
+			)
+			// Add repost context
+			a.renderBooleanSetting(hb, rd,
+				rd.Blog.getRelativePath(settingsPath+settingsAddRepostContextPath),
+				a.ts.GetTemplateStringVariant(rd.Blog.Lang, "addrepostcontextdesc"),
+				addRepostContextSetting,
+				srd.addRepostContext,
+			)
 
 			// User settings
 			a.renderUserSettings(hb, rd, srd)
@@ -1572,10 +2369,8 @@ func (a *goBlog) renderSettings(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 			a.renderPostSectionSettings(hb, rd, srd)
 
 			// Scripts
-			hb.WriteElementOpen("script", "src", a.assetFileName("js/settings.js"), "defer", "")
-			hb.WriteElementClose("script")
-			hb.WriteElementOpen("script", "src", a.assetFileName("js/formconfirm.js"), "defer", "")
-			hb.WriteElementClose("script")
+			a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/settings.js"), "defer", "")
+			a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/formconfirm.js"), "defer", "")
 
 			hb.WriteElementClose("main")
 		},
@@ -1607,6 +2402,26 @@ func (a *goBlog) renderActivityPubFollowers(hb *htmlbuilder.HtmlBuilder, rd *ren
 			hb.WriteEscaped(aprd.apUser)
 			hb.WriteElementClose("h1")
 
+			loggedIn := rd.LoggedIn()
+			backfillPath := "/activitypub" + apBackfillPath + "/" + rd.BlogString
+
+			// Received activities
+			if loggedIn {
+				hb.WriteElementOpen("p")
+				hb.WriteElementOpen("a", "href", "/activitypub"+apInboxLogPath+"/"+rd.BlogString)
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apinboxlog"))
+				hb.WriteElementClose("a")
+				hb.WriteElementClose("p")
+			}
+
+			// Send recent posts to all followers
+			if loggedIn && len(aprd.followers) > 0 {
+				hb.WriteElementOpen("form", "class", "fw p", "method", "post", "action", backfillPath)
+				hb.WriteElementOpen("input", "type", "number", "name", "count", "min", "1", "max", maxApBackfillCount, "value", defaultApBackfillCount, "title", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apbackfillcount"))
+				hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apbackfillall"))
+				hb.WriteElementClose("form")
+			}
+
 			// List followers
 			hb.WriteElementOpen("ul")
 			for _, follower := range aprd.followers {
@@ -1614,6 +2429,13 @@ func (a *goBlog) renderActivityPubFollowers(hb *htmlbuilder.HtmlBuilder, rd *ren
 				hb.WriteElementOpen("a", "href", follower.follower, "target", "_blank")
 				hb.WriteEscaped(follower.username)
 				hb.WriteElementClose("a")
+				if loggedIn {
+					// Send recent posts to this follower
+					hb.WriteElementOpen("form", "class", "in", "method", "post", "action", backfillPath)
+					hb.WriteElementOpen("input", "type", "hidden", "name", "follower", "value", follower.follower)
+					hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apbackfill"))
+					hb.WriteElementClose("form")
+				}
 				hb.WriteElementClose("li")
 			}
 			hb.WriteElementClose("ul")
@@ -1623,6 +2445,80 @@ func (a *goBlog) renderActivityPubFollowers(hb *htmlbuilder.HtmlBuilder, rd *ren
 	)
 }
 
+func (a *goBlog) renderActivityPubInboxLog(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
+	ilrd, ok := rd.Data.(*activityPubInboxLogRenderData)
+	if !ok {
+		return
+	}
+	a.renderBase(
+		hb, rd,
+		func(hb *htmlbuilder.HtmlBuilder) {
+			a.renderTitleTag(hb, rd.Blog, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apinboxlog"))
+		},
+		func(hb *htmlbuilder.HtmlBuilder) {
+			hb.WriteElementOpen("main")
+
+			// Title
+			hb.WriteElementOpen("h1")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apinboxlog"))
+			hb.WriteElementClose("h1")
+
+			// Filter by actor or object
+			hb.WriteElementOpen("form", "class", "fw p", "method", "get")
+			hb.WriteElementOpen("input", "type", "text", "name", "filter", "value", ilrd.filter, "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "apinboxlogfilter"))
+			hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "filter"))
+			hb.WriteElementClose("form")
+
+			if len(ilrd.entries) == 0 {
+				hb.WriteElementOpen("p")
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "nodata"))
+				hb.WriteElementClose("p")
+				hb.WriteElementClose("main")
+				return
+			}
+
+			// Entries, newest first
+			hb.WriteElementOpen("table")
+			hb.WriteElementOpen("thead")
+			for _, column := range []string{"apinboxlogtime", "apinboxlogtype", "apinboxlogactor", "apinboxlogobject", "apinboxlogoutcome"} {
+				hb.WriteElementOpen("th", "class", "tal")
+				hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, column))
+				hb.WriteElementClose("th")
+			}
+			hb.WriteElementClose("thead")
+			hb.WriteElementOpen("tbody")
+			for _, e := range ilrd.entries {
+				hb.WriteElementOpen("tr")
+				hb.WriteElementOpen("td", "class", "tal")
+				hb.WriteEscaped(e.localTime())
+				hb.WriteElementClose("td")
+				hb.WriteElementOpen("td", "class", "tal")
+				hb.WriteEscaped(e.activityType)
+				hb.WriteElementClose("td")
+				for _, link := range []string{e.actor, e.object} {
+					hb.WriteElementOpen("td", "class", "tal")
+					if isAbsoluteURL(link) {
+						hb.WriteElementOpen("a", "href", link, "target", "_blank", "rel", "nofollow noopener noreferrer")
+						hb.WriteEscaped(link)
+						hb.WriteElementClose("a")
+					} else {
+						hb.WriteEscaped(link)
+					}
+					hb.WriteElementClose("td")
+				}
+				hb.WriteElementOpen("td", "class", "tal")
+				hb.WriteEscaped(e.outcome)
+				hb.WriteElementClose("td")
+				hb.WriteElementClose("tr")
+			}
+			hb.WriteElementClose("tbody")
+			hb.WriteElementClose("table")
+
+			hb.WriteElementClose("main")
+		},
+	)
+}
+
 func (a *goBlog) renderActivityPubRemoteFollow(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	a.renderBase(
 		hb, rd,
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
+	gogeouri "git.jlel.se/jlelse/go-geouri"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/samber/lo"
 	"go.goblog.app/app/pkgs/htmlbuilder"
@@ -170,6 +172,10 @@ func (a *goBlog) renderPostMeta(hb *htmlbuilder.HtmlBuilder, p *post, b *configB
 		hb.WriteElementClose("time")
 		hb.WriteElementClose("div")
 	}
+	// Author
+	if author := a.postAuthor(p); author != nil {
+		a.renderPostAuthor(hb, author, b)
+	}
 	// Geo
 	if geoURIs := a.geoURIs(p); len(geoURIs) != 0 {
 		hb.WriteElementOpen("div")
@@ -201,12 +207,15 @@ func (a *goBlog) renderPostMeta(hb *htmlbuilder.HtmlBuilder, p *post, b *configB
 				if i > 0 {
 					hb.WriteEscaped(", ")
 				}
-				hb.WriteElementOpen("a", "translate", "no", "href", translation.Path)
+				tl := a.postLang(translation)
+				hb.WriteElementOpen("a", "translate", "no", "href", translation.Path, "lang", tl, "hreflang", tl)
 				hb.WriteEscaped(translation.RenderedTitle)
 				hb.WriteElementClose("a")
 			}
 			hb.WriteElementClose("div")
 		}
+		// Reading time
+		a.renderPostReadingTime(hb, p, b)
 		// Short link
 		if shortLink := a.shortPostURL(p); shortLink != "" {
 			hb.WriteElementOpen("div")
@@ -217,6 +226,21 @@ func (a *goBlog) renderPostMeta(hb *htmlbuilder.HtmlBuilder, p *post, b *configB
 			hb.WriteElementClose("a")
 			hb.WriteElementClose("div")
 		}
+		// Syndication links
+		if syndicationLinks := a.syndicationLinks(p); len(syndicationLinks) > 0 {
+			hb.WriteElementOpen("div")
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "syndication"))
+			hb.WriteEscaped(" ")
+			for i, link := range syndicationLinks {
+				if i > 0 {
+					hb.WriteEscaped(", ")
+				}
+				hb.WriteElementOpen("a", "class", "u-syndication", "rel", "syndication", "target", "_blank", "href", link)
+				hb.WriteEscaped(hostnameOrURL(link))
+				hb.WriteElementClose("a")
+			}
+			hb.WriteElementClose("div")
+		}
 		// Status
 		if p.Status != statusPublished {
 			hb.WriteElementOpen("div")
@@ -249,6 +273,11 @@ func (a *goBlog) renderPostLikeContext(hb *htmlbuilder.HtmlBuilder, p *post) {
 	a.renderPostLikeReplyContext(hb, "u-like-of", a.ts.GetTemplateStringVariant(a.getBlogFromPost(p).Lang, "likeof"), a.likeLink(p), a.likeTitle(p), a.likeContext(p))
 }
 
+// Repost ("u-repost-of")
+func (a *goBlog) renderPostRepostContext(hb *htmlbuilder.HtmlBuilder, p *post) {
+	a.renderPostLikeReplyContext(hb, "u-repost-of", a.ts.GetTemplateStringVariant(a.getBlogFromPost(p).Lang, "repostof"), a.repostLink(p), a.repostTitle(p), a.repostContext(p))
+}
+
 func (a *goBlog) renderPostLikeReplyContext(hb *htmlbuilder.HtmlBuilder, class, pretext, link, title, content string) {
 	if link == "" {
 		return
@@ -296,6 +325,16 @@ func (a *goBlog) renderShareButton(hb *htmlbuilder.HtmlBuilder, p *post, b *conf
 	hb.WriteElementClose("a")
 }
 
+// Button to follow the blog from a Fediverse account
+func (a *goBlog) renderFollowButton(hb *htmlbuilder.HtmlBuilder, b *configBlog) {
+	if b == nil || !a.apEnabled() {
+		return
+	}
+	hb.WriteElementOpen("a", "class", "button", "href", apRemoteFollowPath+"/"+b.name, "rel", "nofollow")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(b.Lang, "followonfediverse"))
+	hb.WriteElementClose("a")
+}
+
 func (a *goBlog) renderTranslateButton(hb *htmlbuilder.HtmlBuilder, p *post, b *configBlog) {
 	if b == nil || b.hideTranslateButton {
 		return
@@ -310,8 +349,7 @@ func (a *goBlog) renderTranslateButton(hb *htmlbuilder.HtmlBuilder, p *post, b *
 	)
 	hb.WriteEscaped("A ⇄ 文")
 	hb.WriteElementClose("a")
-	hb.WriteElementOpen("script", "defer", "", "src", a.assetFileName("js/translate.js"))
-	hb.WriteElementClose("script")
+	a.writeAssetScript(hb, a.blogAssetFileName(b, "js/translate.js"), "defer", "")
 }
 
 func (a *goBlog) renderInteractions(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
@@ -331,7 +369,7 @@ func (a *goBlog) renderInteractions(hb *htmlbuilder.HtmlBuilder, rd *renderData)
 		hb.WriteElementOpen("ul")
 		for _, mention := range m {
 			hb.WriteElementOpen("li")
-			hb.WriteElementOpen("a", "href", mention.Url, "target", "_blank", "rel", "nofollow noopener noreferrer ugc")
+			hb.WriteElementOpen("a", append([]any{"href", mention.Url}, a.ugcLinkAttributes(mention.Url)...)...)
 			hb.WriteEscaped(defaultIfEmpty(mention.Author, mention.Url))
 			hb.WriteElementClose("a")
 			if mention.Title != "" {
@@ -364,18 +402,56 @@ func (a *goBlog) renderInteractions(hb *htmlbuilder.HtmlBuilder, rd *renderData)
 	hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "send"))
 	hb.WriteElementClose("form")
 	// Show form to create a new comment
-	hb.WriteElementOpen("form", "class", "fw p", "method", "post", "action", rd.Blog.getRelativePath(commentPath))
-	hb.WriteElementOpen("input", "type", "hidden", "name", "target", "value", rd.Canonical)
-	hb.WriteElementOpen("input", "type", "text", "name", "name", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "nameopt"))
-	hb.WriteElementOpen("input", "type", "url", "name", "website", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "websiteopt"))
-	hb.WriteElementOpen("textarea", "name", "comment", "required", "", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "comment"))
-	hb.WriteElementClose("textarea")
-	hb.WriteElementOpen("input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "docomment"))
-	hb.WriteElementClose("form")
+	if cc := rd.Blog.Comments; cc == nil || !cc.DisableForm {
+		pow := cc != nil && cc.mode() == spamProtectionPoW
+		commentsPath := rd.Blog.getRelativePath(commentPath)
+		formAttrs := []any{"class", "fw p", "method", "post", "action", commentsPath}
+		if pow {
+			formAttrs = append(formAttrs, "data-pow", commentsPath+spamPoWPath)
+		}
+		hb.WriteElementOpen("form", formAttrs...)
+		hb.WriteElementOpen("input", "type", "hidden", "name", "target", "value", rd.Canonical)
+		hb.WriteElementOpen("input", "type", "text", "name", "name", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "nameopt"))
+		hb.WriteElementOpen("input", "type", "url", "name", "website", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "websiteopt"))
+		hb.WriteElementOpen("textarea", "name", "comment", "required", "", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "comment"))
+		hb.WriteElementClose("textarea")
+		a.renderSpamProtectionFields(hb, rd.Blog, pow)
+		a.renderSpamProtectionSubmit(hb, a.ts.GetTemplateStringVariant(rd.Blog.Lang, "docomment"), pow)
+		hb.WriteElementClose("form")
+		if pow {
+			a.writeAssetScript(hb, a.blogAssetFileName(rd.Blog, "js/pow.js"), "defer", "")
+		}
+	}
 	// Finish accordion
 	hb.WriteElementClose("details")
 }
 
+// Submit button, disabled until the proof of work is solved
+func (*goBlog) renderSpamProtectionSubmit(hb *htmlbuilder.HtmlBuilder, value string, pow bool) {
+	if pow {
+		hb.WriteElementOpen("input", "type", "submit", "value", value, "disabled", "")
+	} else {
+		hb.WriteElementOpen("input", "type", "submit", "value", value)
+	}
+}
+
+// Honeypot and proof of work fields for public forms
+func (a *goBlog) renderSpamProtectionFields(hb *htmlbuilder.HtmlBuilder, bc *configBlog, pow bool) {
+	// Honeypot, hidden for humans
+	hb.WriteElementOpen("div", "class", "hide", "aria-hidden", "true")
+	hb.WriteElementOpen("input", "type", "text", "name", spamHoneypotField, "tabindex", "-1", "autocomplete", "off")
+	hb.WriteElementClose("div")
+	// Proof of work, solved by JavaScript
+	if pow {
+		hb.WriteElementOpen("input", "type", "hidden", "name", "powchallenge")
+		hb.WriteElementOpen("input", "type", "hidden", "name", "pownonce")
+		hb.WriteElementOpen("noscript")
+		hb.WriteElementOpen("p")
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(bc.Lang, "powjs"))
+		hb.WriteElementsClose("p", "noscript")
+	}
+}
+
 // author h-card
 func (a *goBlog) renderAuthor(hb *htmlbuilder.HtmlBuilder) {
 	user := a.cfg.User
@@ -414,6 +490,17 @@ func (a *goBlog) renderPostHeadMeta(hb *htmlbuilder.HtmlBuilder, p *post) {
 	}
 }
 
+// Alternate links to the translations of the post, including the post itself
+func (a *goBlog) renderPostHreflang(hb *htmlbuilder.HtmlBuilder, p *post) {
+	translations := a.postTranslations(p)
+	if len(translations) == 0 {
+		return
+	}
+	for _, t := range append([]*post{p}, translations...) {
+		hb.WriteElementOpen("link", "rel", "alternate", "hreflang", a.postLang(t), "href", a.fullPostURL(t))
+	}
+}
+
 // TOR notice in the footer
 func (a *goBlog) renderTorNotice(hb *htmlbuilder.HtmlBuilder, rd *renderData) {
 	if !a.cfg.Server.Tor || (!rd.TorUsed && rd.TorAddress == "") {
@@ -513,9 +600,34 @@ func (a *goBlog) renderPostGPX(hb *htmlbuilder.HtmlBuilder, p *post, b *configBl
 			"data-attribution", track.MapAttribution,
 		)
 		hb.WriteElementClose("div")
-		hb.WriteElementOpen("script", "defer", "", "src", a.assetFileName("js/geomap.js"))
-		hb.WriteElementClose("script")
+		a.writeAssetScript(hb, a.blogAssetFileName(b, "js/geomap.js"), "defer", "")
+	}
+}
+
+func (a *goBlog) renderPostLocationMap(hb *htmlbuilder.HtmlBuilder, p *post, b *configBlog) {
+	if p == nil || b.Map == nil || !b.Map.PostMaps || p.hasTrack() {
+		// Posts with a track already have a map
+		return
+	}
+	geoURIs := a.geoURIs(p)
+	if len(geoURIs) == 0 {
+		return
 	}
+	points := lo.Map(geoURIs, func(g *gogeouri.Geo, _ int) *trackPoint {
+		return &trackPoint{Lat: g.Latitude, Lon: g.Longitude}
+	})
+	pointsJSON, err := json.Marshal(points)
+	if err != nil {
+		return
+	}
+	hb.WriteElementOpen(
+		"div", "id", "map", "class", "p small",
+		"data-points", string(pointsJSON),
+		"data-minzoom", a.getMinZoom(), "data-maxzoom", a.getMaxZoom(),
+		"data-attribution", a.getMapAttribution(),
+	)
+	hb.WriteElementClose("div")
+	a.writeAssetScript(hb, a.blogAssetFileName(b, "js/geomap.js"), "defer", "")
 }
 
 func (a *goBlog) renderPostReactions(hb *htmlbuilder.HtmlBuilder, p *post) {
@@ -524,8 +636,7 @@ func (a *goBlog) renderPostReactions(hb *htmlbuilder.HtmlBuilder, p *post) {
 	}
 	hb.WriteElementOpen("div", "id", "reactions", "class", "actions", "data-path", p.Path, "data-allowed", strings.Join(allowedReactions, ","))
 	hb.WriteElementClose("div")
-	hb.WriteElementOpen("script", "defer", "", "src", a.assetFileName("js/reactions.js"))
-	hb.WriteElementClose("script")
+	a.writeAssetScript(hb, a.blogAssetFileName(a.getBlogFromPost(p), "js/reactions.js"), "defer", "")
 }
 
 func (a *goBlog) renderPostVideo(hb *htmlbuilder.HtmlBuilder, p *post) {
@@ -534,8 +645,7 @@ func (a *goBlog) renderPostVideo(hb *htmlbuilder.HtmlBuilder, p *post) {
 	}
 	hb.WriteElementOpen("div", "id", "video", "data-url", p.firstParameter(videoPlaylistParam))
 	hb.WriteElementClose("div")
-	hb.WriteElementOpen("script", "defer", "", "src", a.assetFileName("js/video.js"))
-	hb.WriteElementClose("script")
+	a.writeAssetScript(hb, a.blogAssetFileName(a.getBlogFromPost(p), "js/video.js"), "defer", "")
 }
 
 func (a *goBlog) renderPostSectionSettings(hb *htmlbuilder.HtmlBuilder, rd *renderData, srd *settingsRenderData) {
@@ -588,6 +698,12 @@ func (a *goBlog) renderPostSectionSettings(hb *htmlbuilder.HtmlBuilder, rd *rend
 		hb.WriteElementClose("textarea")
 		// Path template
 		hb.WriteElementOpen("input", "type", "text", "name", "sectionpathtemplate", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "sectionpathtemplate"), "value", section.PathTemplate)
+		// Move posts to the new path template
+		hb.WriteElementOpen("input", "type", "checkbox", "name", "sectionmoveposts", "id", "moveposts-"+section.Name)
+		hb.WriteElementOpen("label", "for", "moveposts-"+section.Name)
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "sectionmoveposts"))
+		hb.WriteElementClose("label")
+		hb.WriteElementsClose("br")
 		// Show full
 		hb.WriteElementOpen("input", "type", "checkbox", "name", "sectionshowfull", "id", "showfull-"+section.Name, lo.If(section.ShowFull, "checked").Else(""), "")
 		hb.WriteElementOpen("label", "for", "showfull-"+section.Name)
@@ -599,6 +715,9 @@ func (a *goBlog) renderPostSectionSettings(hb *htmlbuilder.HtmlBuilder, rd *rend
 		hb.WriteElementOpen("label", "for", "hideonstart-"+section.Name)
 		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "sectionhideonstart"))
 		hb.WriteElementClose("label")
+		hb.WriteElementsClose("br")
+		// Content warning
+		hb.WriteElementOpen("input", "type", "text", "name", "sectioncontentwarning", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "sectioncontentwarning"), "value", section.ContentWarning)
 
 		// Actions
 		hb.WriteElementOpen("div", "class", "p")
@@ -686,6 +805,132 @@ func (a *goBlog) renderUserSettings(hb *htmlbuilder.HtmlBuilder, rd *renderData,
 		"formaction", rd.Blog.getRelativePath(settingsPath+settingsDeleteProfileImagePath),
 	)
 	hb.WriteElementClose("form")
+
+	a.renderTOTPSettings(hb, rd, srd)
+
+	a.renderIdentitiesSettings(hb, rd, srd)
+}
+
+func (a *goBlog) renderTOTPSettings(hb *htmlbuilder.HtmlBuilder, rd *renderData, srd *settingsRenderData) {
+	hb.WriteElementOpen("h3")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "twofactor"))
+	hb.WriteElementClose("h3")
+
+	tokenInput := func() {
+		hb.WriteElementOpen("input", "type", "text", "inputmode", "numeric", "pattern", "[0-9]*", "name", "token", "autocomplete", "one-time-code", "placeholder", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "totp"), "required", "")
+	}
+
+	switch {
+	case srd.totpEnabled:
+		// Disable
+		hb.WriteElementOpen("p")
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "twofactorenabled"))
+		hb.WriteElementClose("p")
+		hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+		tokenInput()
+		hb.WriteElementOpen(
+			"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "disable"),
+			"formaction", rd.Blog.getRelativePath(settingsPath+settingsDisableTOTPPath),
+		)
+		hb.WriteElementClose("form")
+	case srd.totpURL != "":
+		// Finish setup
+		hb.WriteElementOpen("p")
+		hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "twofactorsetupdesc"))
+		hb.WriteElementClose("p")
+		hb.WriteElementOpen("p")
+		hb.WriteElementOpen("a", "href", srd.totpURL)
+		hb.WriteElementOpen("img", "src", srd.totpQRCode, "alt", srd.totpURL, "width", "200", "height", "200")
+		hb.WriteElementClose("a")
+		hb.WriteElementClose("p")
+		hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+		tokenInput()
+		hb.WriteElementOpen(
+			"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "enable"),
+			"formaction", rd.Blog.getRelativePath(settingsPath+settingsEnableTOTPPath),
+		)
+		hb.WriteElementClose("form")
+	default:
+		// Start setup
+		hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+		hb.WriteElementOpen(
+			"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "twofactorsetup"),
+			"formaction", rd.Blog.getRelativePath(settingsPath+settingsSetupTOTPPath),
+		)
+		hb.WriteElementClose("form")
+	}
+}
+
+func (a *goBlog) renderIdentitiesSettings(hb *htmlbuilder.HtmlBuilder, rd *renderData, srd *settingsRenderData) {
+	hb.WriteElementOpen("h3")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "identities"))
+	hb.WriteElementClose("h3")
+
+	hb.WriteElementOpen("p")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "identitiesdesc"))
+	hb.WriteElementClose("p")
+
+	hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+	hb.WriteElementOpen("textarea", "name", identitiesSetting, "placeholder", "https://github.com/username")
+	hb.WriteEscaped(strings.Join(srd.identities, "\n"))
+	hb.WriteElementClose("textarea")
+	hb.WriteElementOpen(
+		"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "update"),
+		"formaction", rd.Blog.getRelativePath(settingsPath+settingsUpdateIdentitiesPath),
+	)
+	hb.WriteElementClose("form")
+
+	if len(srd.identities) == 0 {
+		return
+	}
+
+	// Status of the reciprocal links
+	hb.WriteElementOpen("table")
+	hb.WriteElementOpen("thead")
+	hb.WriteElementOpen("th", "class", "tal")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "identities"))
+	hb.WriteElementClose("th")
+	hb.WriteElementOpen("th", "class", "tal")
+	hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "status"))
+	hb.WriteElementClose("th")
+	hb.WriteElementClose("thead")
+	hb.WriteElementOpen("tbody")
+	for _, identity := range srd.identities {
+		hb.WriteElementOpen("tr")
+		hb.WriteElementOpen("td", "class", "tal")
+		hb.WriteElementOpen("a", "href", identity, "target", "_blank", "rel", "me noopener noreferrer")
+		hb.WriteEscaped(identity)
+		hb.WriteElementClose("a")
+		hb.WriteElementClose("td")
+		hb.WriteElementOpen("td", "class", "tal")
+		if status, ok := srd.identityStatuses[identity]; !ok {
+			hb.WriteEscaped(a.ts.GetTemplateStringVariant(rd.Blog.Lang, "notchecked"))
+		} else {
+			if status.verified {
+				hb.WriteEscaped("✅ " + a.ts.GetTemplateStringVariant(rd.Blog.Lang, "verified"))
+			} else {
+				hb.WriteEscaped("❌ " + a.ts.GetTemplateStringVariant(rd.Blog.Lang, "notverified"))
+				if status.message != "" {
+					hb.WriteEscaped(" (" + status.message + ")")
+				}
+			}
+			hb.WriteElementOpen("br")
+			hb.WriteElementOpen("small")
+			hb.WriteEscaped(status.checked.Local().Format(time.DateTime))
+			hb.WriteElementClose("small")
+		}
+		hb.WriteElementClose("td")
+		hb.WriteElementClose("tr")
+	}
+	hb.WriteElementClose("tbody")
+	hb.WriteElementClose("table")
+
+	hb.WriteElementOpen("form", "class", "fw p", "method", "post")
+	hb.WriteElementOpen(
+		"input", "type", "submit", "value", a.ts.GetTemplateStringVariant(rd.Blog.Lang, "verify"),
+		"formaction", rd.Blog.getRelativePath(settingsPath+settingsVerifyIdentitiesPath),
+	)
+	hb.WriteElementClose("form")
 }
 
 func (a *goBlog) renderFooter(origHb *htmlbuilder.HtmlBuilder, rd *renderData) {
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Additional users besides the user from the config: they are stored in the database and have a role
+// per blog. Admins can edit all posts of the blog, authors only their own posts. The config user
+// (and IndieAuth clients authorized by it) keeps full access to everything.
+
+type userRole string
+
+const (
+	userRoleNone   userRole = ""
+	userRoleAuthor userRole = "author"
+	userRoleAdmin  userRole = "admin"
+
+	loggedInUserKey contextKey = "loggedInUser"
+
+	sessionUserKey = "user"
+)
+
+type appUser struct {
+	Username string
+	Name     string
+	roles    map[string]userRole // by blog
+}
+
+func (u *appUser) role(blog string) userRole {
+	if u == nil {
+		return userRoleNone
+	}
+	return u.roles[blog]
+}
+
+// Check if the role includes the permissions of the other role
+func (r userRole) includes(other userRole) bool {
+	switch r {
+	case userRoleAdmin:
+		return true
+	case userRoleAuthor:
+		return other == userRoleAuthor || other == userRoleNone
+	}
+	return other == userRoleNone
+}
+
+func parseUserRole(role string) (userRole, error) {
+	switch r := userRole(strings.ToLower(role)); r {
+	case userRoleAdmin, userRoleAuthor:
+		return r, nil
+	case "none":
+		return userRoleNone, nil
+	}
+	return userRoleNone, fmt.Errorf("invalid role %q, use admin, author or none", role)
+}
+
+// Passwords in the config can be bcrypt hashes (like "$2a$10$...") or plain text
+func checkPassword(stored, password string) bool {
+	if stored == "" {
+		return false
+	}
+	if strings.HasPrefix(stored, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func (db *database) createUser(username, password, name string) error {
+	if username == "" {
+		return errors.New("empty username")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		"insert into users (username, password, name, created) values (@username, @password, @name, @created)",
+		sql.Named("username", username), sql.Named("password", hash), sql.Named("name", name), sql.Named("created", utcNowString()),
+	)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return errors.New("user already exists")
+	}
+	return err
+}
+
+func (db *database) setUserPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return db.updateUser("update users set password = @password where username = @username", sql.Named("username", username), sql.Named("password", hash))
+}
+
+func (db *database) deleteUser(username string) error {
+	return db.updateUser("delete from users where username = @username", sql.Named("username", username))
+}
+
+// Set the role of the user for the blog, userRoleNone removes it
+func (db *database) setUserRole(username, blog string, role userRole) error {
+	if _, err := db.getUser(username); err != nil {
+		return err
+	}
+	var err error
+	if role == userRoleNone {
+		_, err = db.Exec("delete from user_blogs where username = @username and blog = @blog", sql.Named("username", username), sql.Named("blog", blog))
+	} else {
+		_, err = db.Exec(
+			"insert or replace into user_blogs (username, blog, role) values (@username, @blog, @role)",
+			sql.Named("username", username), sql.Named("blog", blog), sql.Named("role", role),
+		)
+	}
+	return err
+}
+
+// Execute the statement and return an error if no user was affected
+func (db *database) updateUser(query string, args ...any) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func (db *database) getUser(username string) (*appUser, error) {
+	users, err := db.getUsers(username)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errors.New("user not found")
+	}
+	return users[0], nil
+}
+
+// All users or the user with the username, sorted by username
+func (db *database) getUsers(username ...string) ([]*appUser, error) {
+	query := "select u.username, u.name, coalesce(b.blog, ''), coalesce(b.role, '') from users u left join user_blogs b on u.username = b.username"
+	var args []any
+	if len(username) > 0 {
+		query += " where u.username = @username"
+		args = append(args, sql.Named("username", username[0]))
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := map[string]*appUser{}
+	for rows.Next() {
+		var name, fullName, blog, role string
+		if err = rows.Scan(&name, &fullName, &blog, &role); err != nil {
+			return nil, err
+		}
+		u, ok := users[name]
+		if !ok {
+			u = &appUser{Username: name, Name: fullName, roles: map[string]userRole{}}
+			users[name] = u
+		}
+		if blog != "" {
+			u.roles[blog] = userRole(role)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	result := lo.Values(users)
+	sort.Slice(result, func(i, j int) bool { return result[i].Username < result[j].Username })
+	return result, nil
+}
+
+// Returns the user if the credentials are correct
+func (db *database) checkUserCredentials(username, password string) *appUser {
+	row, err := db.QueryRow("select password from users where username = @username", sql.Named("username", username))
+	if err != nil {
+		return nil
+	}
+	var hash string
+	if err = row.Scan(&hash); err != nil || !checkPassword(hash, password) {
+		return nil
+	}
+	u, err := db.getUser(username)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// The database user of the request, nil for the config user and requests without login
+func (a *goBlog) currentUser(r *http.Request) *appUser {
+	if u, ok := r.Context().Value(loggedInUserKey).(*appUser); ok {
+		return u
+	}
+	if _, ok := r.Context().Value(indieAuthScope).(string); ok {
+		// IndieAuth tokens are issued by the config user
+		return nil
+	}
+	if a.isLoggedIn(r) {
+		return nil
+	}
+	var u *appUser
+	if username, password, ok := r.BasicAuth(); ok {
+		u = a.db.checkUserCredentials(username, password)
+	} else if ses, err := a.loginSessions.Get(r, "l"); err == nil && ses != nil {
+		if username, ok := ses.Values[sessionUserKey].(string); ok && username != "" {
+			u, _ = a.db.getUser(username)
+		}
+	}
+	if u != nil {
+		setLoggedInUser(r, u)
+	}
+	return u
+}
+
+func setLoggedInUser(r *http.Request, u *appUser) {
+	(*r) = *(r.WithContext(context.WithValue(r.Context(), loggedInUserKey, u)))
+}
+
+// Check if the authenticated request is allowed to do something in the blog with at least the role
+func (a *goBlog) hasBlogRole(r *http.Request, blog string, role userRole) bool {
+	if u := a.currentUser(r); u != nil {
+		return u.role(blog) != userRoleNone && u.role(blog).includes(role)
+	}
+	// Config user or IndieAuth client
+	return true
+}
+
+// Check if the authenticated request is allowed to change the post, authors can only change their own posts
+func (a *goBlog) canEditPost(r *http.Request, p *post) bool {
+	u := a.currentUser(r)
+	if u == nil {
+		return true
+	}
+	switch u.role(p.Blog) {
+	case userRoleAdmin:
+		return true
+	case userRoleAuthor:
+		return p.Owner == u.Username
+	}
+	return false
+}
+
+// Like canEditPost, but loads the post (including deleted posts) first
+func (a *goBlog) canEditPostPath(r *http.Request, path string) bool {
+	if a.currentUser(r) == nil {
+		return true
+	}
+	p, err := a.getPost(path)
+	return err == nil && a.canEditPost(r, p)
+}
+
+// Middleware for pages of the blog that database users with at least the role can use too
+func (a *goBlog) blogUserMiddleware(bc *configBlog, role userRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.isLoggedIn(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if u := a.currentUser(r); u != nil {
+				if bc == nil || !a.hasBlogRole(r, bc.name, role) {
+					a.serveError(w, r, "No permission for this blog", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			a.authMiddleware(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// Middleware for pages that all logged in users can use, like the login and logout
+func (a *goBlog) anyUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.isLoggedIn(r) || a.currentUser(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.authMiddleware(next).ServeHTTP(w, r)
+	})
+}
+
+// Command line tool to manage the database users
+func (a *goBlog) userTool(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing command, use list, add, password, role or delete")
+	}
+	command, args := args[0], args[1:]
+	need := func(n int, usage string) error {
+		if len(args) < n {
+			return errors.New("usage: user " + command + " " + usage)
+		}
+		return nil
+	}
+	switch command {
+	case "list":
+		users, err := a.db.getUsers()
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			roles := lo.MapToSlice(u.roles, func(blog string, role userRole) string { return blog + ":" + string(role) })
+			sort.Strings(roles)
+			_, _ = fmt.Fprintln(w, u.Username, strings.Join(roles, " "))
+		}
+		return nil
+	case "add":
+		if err := need(2, "<username> <password> [name]"); err != nil {
+			return err
+		}
+		name := ""
+		if len(args) > 2 {
+			name = args[2]
+		}
+		return a.db.createUser(args[0], args[1], name)
+	case "password":
+		if err := need(2, "<username> <password>"); err != nil {
+			return err
+		}
+		return a.db.setUserPassword(args[0], args[1])
+	case "role":
+		if err := need(3, "<username> <blog> <admin|author|none>"); err != nil {
+			return err
+		}
+		if _, ok := a.cfg.Blogs[args[1]]; !ok {
+			return fmt.Errorf("unknown blog %q", args[1])
+		}
+		role, err := parseUserRole(args[2])
+		if err != nil {
+			return err
+		}
+		return a.db.setUserRole(args[0], args[1], role)
+	case "delete":
+		if err := need(1, "<username>"); err != nil {
+			return err
+		}
+		return a.db.deleteUser(args[0])
+	}
+	return fmt.Errorf("unknown command %q, use list, add, password, role or delete", command)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_passwords(t *testing.T) {
+	hash, err := hashPassword("secret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$2"))
+	assert.True(t, checkPassword(hash, "secret"))
+	assert.False(t, checkPassword(hash, "wrong"))
+
+	// Plain text passwords from the config
+	assert.True(t, checkPassword("secret", "secret"))
+	assert.False(t, checkPassword("secret", "wrong"))
+	assert.False(t, checkPassword("", ""))
+
+	_, err = hashPassword("")
+	assert.Error(t, err)
+}
+
+func Test_userRoles(t *testing.T) {
+	assert.True(t, userRoleAdmin.includes(userRoleAuthor))
+	assert.True(t, userRoleAuthor.includes(userRoleAuthor))
+	assert.False(t, userRoleAuthor.includes(userRoleAdmin))
+	assert.False(t, userRoleNone.includes(userRoleAuthor))
+
+	role, err := parseUserRole("Admin")
+	require.NoError(t, err)
+	assert.Equal(t, userRoleAdmin, role)
+	role, err = parseUserRole("none")
+	require.NoError(t, err)
+	assert.Equal(t, userRoleNone, role)
+	_, err = parseUserRole("owner")
+	assert.Error(t, err)
+}
+
+func Test_userTool(t *testing.T) {
+	h := newTestHarness(t)
+	a := h.app
+
+	var out strings.Builder
+	require.NoError(t, a.userTool(&out, []string{"add", "alice", "pw1", "Alice"}))
+	assert.Error(t, a.userTool(&out, []string{"add", "alice", "pw2"}))
+	require.NoError(t, a.userTool(&out, []string{"role", "alice", "default", "author"}))
+	assert.Error(t, a.userTool(&out, []string{"role", "alice", "unknown", "author"}))
+	assert.Error(t, a.userTool(&out, []string{"role", "bob", "default", "author"}))
+	require.NoError(t, a.userTool(&out, []string{"list"}))
+	assert.Equal(t, "alice default:author\n", out.String())
+
+	u := a.db.checkUserCredentials("alice", "pw1")
+	require.NotNil(t, u)
+	assert.Equal(t, "Alice", u.Name)
+	assert.Equal(t, userRoleAuthor, u.role("default"))
+	assert.Nil(t, a.db.checkUserCredentials("alice", "wrong"))
+
+	require.NoError(t, a.userTool(&out, []string{"password", "alice", "pw2"}))
+	assert.Nil(t, a.db.checkUserCredentials("alice", "pw1"))
+	assert.NotNil(t, a.db.checkUserCredentials("alice", "pw2"))
+
+	require.NoError(t, a.userTool(&out, []string{"role", "alice", "default", "none"}))
+	u, err := a.db.getUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, userRoleNone, u.role("default"))
+
+	require.NoError(t, a.userTool(&out, []string{"delete", "alice"}))
+	assert.Error(t, a.userTool(&out, []string{"delete", "alice"}))
+	assert.Error(t, a.userTool(&out, []string{"unknown"}))
+	assert.Error(t, a.userTool(&out, nil))
+}
+
+func Test_userPermissions(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.Blogs = map[string]*configBlog{
+			"default": {Path: "/", Lang: "en"},
+			"other":   {Path: "/other", Lang: "en"},
+		}
+		c.DefaultBlog = "default"
+	})
+	a := h.app
+
+	require.NoError(t, a.db.createUser("alice", "pw", ""))
+	require.NoError(t, a.db.setUserRole("alice", "default", userRoleAuthor))
+
+	aliceRequest := func(path string) *requests.Builder {
+		return h.request(path).BasicAuth("alice", "pw")
+	}
+	status := func(rb *requests.Builder) int {
+		var code int
+		require.NoError(t, rb.AddValidator(func(r *http.Response) error {
+			code = r.StatusCode
+			return nil
+		}).Fetch(context.Background()))
+		return code
+	}
+	editorAction := func(rb *requests.Builder, values url.Values) int {
+		return status(rb.BodyForm(values))
+	}
+
+	// Editor of the own blog, but not of other blogs or the settings
+	assert.Equal(t, http.StatusOK, status(aliceRequest("/editor")))
+	assert.Equal(t, http.StatusForbidden, status(aliceRequest("/other/editor")))
+	assert.Equal(t, http.StatusForbidden, status(aliceRequest("/editor/files")))
+	assert.Equal(t, http.StatusForbidden, status(aliceRequest("/settings")))
+
+	// Create a post, it's owned by alice
+	assert.Equal(t, http.StatusFound, editorAction(aliceRequest("/editor"), url.Values{
+		"editoraction": {"createpost"},
+		"content":      {"---\npath: /alice\nstatus: published\n---\nAlice post"},
+	}))
+	p, err := a.getPost("/alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", p.Owner)
+
+	// Not in other blogs
+	assert.Equal(t, http.StatusForbidden, editorAction(aliceRequest("/editor"), url.Values{
+		"editoraction": {"createpost"},
+		"content":      {"---\nblog: other\npath: /other/alice\n---\nOther post"},
+	}))
+
+	// Update the own post, but not posts of others
+	h.createPost(&post{Path: "/config-user", Content: "Config user post"})
+	assert.Equal(t, http.StatusFound, editorAction(aliceRequest("/editor"), url.Values{
+		"editoraction": {"updatepost"},
+		"url":          {a.getFullAddress("/alice")},
+		"content":      {"Updated alice post"},
+	}))
+	assert.Equal(t, http.StatusForbidden, editorAction(aliceRequest("/editor"), url.Values{
+		"editoraction": {"updatepost"},
+		"url":          {a.getFullAddress("/config-user")},
+		"content":      {"Changed"},
+	}))
+	assert.Equal(t, http.StatusForbidden, editorAction(aliceRequest("/editor"), url.Values{
+		"editoraction": {"delete"},
+		"url":          {a.getFullAddress("/config-user")},
+	}))
+
+	// Admins can change all posts of the blog
+	require.NoError(t, a.db.setUserRole("alice", "default", userRoleAdmin))
+	assert.Equal(t, http.StatusFound, editorAction(aliceRequest("/editor"), url.Values{
+		"editoraction": {"updatepost"},
+		"url":          {a.getFullAddress("/config-user")},
+		"content":      {"Changed by admin"},
+	}))
+	p, err = a.getPost("/config-user")
+	require.NoError(t, err)
+	assert.Equal(t, "Changed by admin", p.Content)
+	assert.Equal(t, "", p.Owner)
+
+	// The config user still has access to everything
+	assert.Equal(t, http.StatusOK, status(h.loggedInRequest("/other/editor")))
+
+	// Login form with a database user
+	var cookie string
+	assert.Equal(t, http.StatusOK, status(h.request("/editor").BodyForm(url.Values{
+		"loginaction": {"login"},
+		"loginmethod": {http.MethodGet},
+		"username":    {"alice"},
+		"password":    {"pw"},
+	}).AddValidator(func(r *http.Response) error {
+		cookie = r.Header.Get("Set-Cookie")
+		return nil
+	})))
+	require.NotEmpty(t, cookie)
+	assert.Equal(t, http.StatusForbidden, status(h.request("/settings").Header("Cookie", strings.Split(cookie, ";")[0])))
+	assert.Equal(t, http.StatusUnauthorized, status(h.request("/editor").BodyForm(url.Values{
+		"loginaction": {"login"},
+		"loginmethod": {http.MethodGet},
+		"username":    {"alice"},
+		"password":    {"wrong"},
+	})))
+}
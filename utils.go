@@ -72,6 +72,12 @@ func isAbsoluteURL(s string) bool {
 	return true
 }
 
+// Absolute URL with the http or https scheme, like links from third-party content that are rendered
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 func allLinksFromHTMLString(html, baseURL string) ([]string, error) {
 	return allLinksFromHTML(strings.NewReader(html), baseURL)
 }
@@ -346,6 +352,10 @@ type handlerRoundTripper struct {
 func (rt *handlerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	if rt.handler != nil {
 		// Fake request with handler
+		if req.Body == nil {
+			// Server requests always have a body
+			req.Body = http.NoBody
+		}
 		rec := httptest.NewRecorder()
 		rt.handler.ServeHTTP(rec, req)
 		resp := rec.Result()
@@ -15,6 +15,7 @@ import (
 type webmentionStatus string
 
 const (
+	webmentionStatusNew      webmentionStatus = "new"
 	webmentionStatusVerified webmentionStatus = "verified"
 	webmentionStatusApproved webmentionStatus = "approved"
 
@@ -23,6 +24,7 @@ const (
 
 type mention struct {
 	ID          int
+	Type        commentType
 	Source      string
 	NewSource   string
 	Target      string
@@ -106,7 +108,7 @@ func (db *database) webmentionExists(m *mention) bool {
 		`
 		select exists(
 			select 1
-			from webmentions
+			from comments
 			where
 				lowerunescaped(source) in (lowerunescaped(@source), lowerunescaped(@newsource))
 				and lowerunescaped(target) in (lowerunescaped(@target), lowerunescaped(@newtarget))
@@ -135,9 +137,10 @@ func (a *goBlog) createWebmention(source, target string) (err error) {
 func (db *database) insertWebmention(m *mention, status webmentionStatus) error {
 	_, err := db.Exec(
 		`
-		insert into webmentions (source, target, url, created, status, title, content, author) 
-		values (@source, lowerunescaped(@target), @url, @created, @status, @title, @content, @author)
+		insert into comments (type, source, target, url, created, status, title, content, author, name, website, comment)
+		values (@type, @source, @target, @url, @created, @status, @title, @content, @author, '', '', '')
 		`,
+		sql.Named("type", commentTypeWebmention),
 		sql.Named("source", m.Source),
 		sql.Named("target", m.Target),
 		sql.Named("url", m.Url),
@@ -152,10 +155,10 @@ func (db *database) insertWebmention(m *mention, status webmentionStatus) error
 
 func (db *database) updateWebmention(m *mention, newStatus webmentionStatus) error {
 	_, err := db.Exec(`
-			update webmentions
-			set 
+			update comments
+			set
 				source = @newsource,
-				target = lowerunescaped(@newtarget),
+				target = @newtarget,
 				url = @url,
 				status = @status,
 				title = @title,
@@ -180,29 +183,32 @@ func (db *database) updateWebmention(m *mention, newStatus webmentionStatus) err
 	return err
 }
 
+// Deletes the webmention or comment with the given ID
 func (db *database) deleteWebmentionId(id int) error {
-	_, err := db.Exec("delete from webmentions where id = @id", sql.Named("id", id))
+	_, err := db.Exec("delete from comments where id = @id", sql.Named("id", id))
 	return err
 }
 
 func (db *database) deleteWebmentionUUrl(uUrl string) error {
-	_, err := db.Exec("delete from webmentions where url = @url", sql.Named("url", uUrl))
+	_, err := db.Exec("delete from comments where type = @type and url = @url", sql.Named("type", commentTypeWebmention), sql.Named("url", uUrl))
 	return err
 }
 
+// Only deletes received webmentions, comments created on the blog itself are kept
 func (db *database) deleteWebmention(m *mention) error {
 	_, err := db.Exec(
-		"delete from webmentions where lowerunescaped(source) in (lowerunescaped(@source), lowerunescaped(@newsource)) and lowerunescaped(target) in (lowerunescaped(@target), lowerunescaped(@newtarget))",
+		"delete from comments where type = @type and lowerunescaped(source) in (lowerunescaped(@source), lowerunescaped(@newsource)) and lowerunescaped(target) in (lowerunescaped(@target), lowerunescaped(@newtarget))",
 		sql.Named("source", m.Source),
 		sql.Named("newsource", defaultIfEmpty(m.NewSource, m.Source)),
 		sql.Named("target", m.Target),
 		sql.Named("newtarget", defaultIfEmpty(m.NewTarget, m.Target)),
+		sql.Named("type", commentTypeWebmention),
 	)
 	return err
 }
 
 func (db *database) approveWebmentionId(id int) error {
-	_, err := db.Exec("update webmentions set status = ? where id = ?", webmentionStatusApproved, id)
+	_, err := db.Exec("update comments set status = ? where id = ?", webmentionStatusApproved, id)
 	return err
 }
 
@@ -224,7 +230,7 @@ type webmentionsRequestConfig struct {
 	target        string
 	status        webmentionStatus
 	sourcelike    string
-	id            int
+	id, parent    int
 	asc           bool
 	offset, limit int
 	submentions   bool
@@ -233,7 +239,7 @@ type webmentionsRequestConfig struct {
 func buildWebmentionsQuery(config *webmentionsRequestConfig) (query string, args []any) {
 	queryBuilder := builderpool.Get()
 	defer builderpool.Put(queryBuilder)
-	queryBuilder.WriteString("select id, source, target, url, created, title, content, author, status from webmentions ")
+	queryBuilder.WriteString("select id, type, source, target, url, created, title, content, author, status from comments ")
 	if config != nil {
 		queryBuilder.WriteString("where 1")
 		if config.target != "" {
@@ -252,6 +258,10 @@ func buildWebmentionsQuery(config *webmentionsRequestConfig) (query string, args
 			queryBuilder.WriteString(" and id = @id")
 			args = append(args, sql.Named("id", config.id))
 		}
+		if config.parent != 0 {
+			queryBuilder.WriteString(" and parent = @parent")
+			args = append(args, sql.Named("parent", config.parent))
+		}
 	}
 	queryBuilder.WriteString(" order by created ")
 	if config.asc {
@@ -275,7 +285,7 @@ func (db *database) getWebmentions(config *webmentionsRequestConfig) ([]*mention
 	}
 	for rows.Next() {
 		m := &mention{}
-		err = rows.Scan(&m.ID, &m.Source, &m.Target, &m.Url, &m.Created, &m.Title, &m.Content, &m.Author, &m.Status)
+		err = rows.Scan(&m.ID, &m.Type, &m.Source, &m.Target, &m.Url, &m.Created, &m.Title, &m.Content, &m.Author, &m.Status)
 		if err != nil {
 			return nil, err
 		}
@@ -284,7 +294,7 @@ func (db *database) getWebmentions(config *webmentionsRequestConfig) ([]*mention
 		}
 		if config.submentions {
 			m.Submentions, err = db.getWebmentions(&webmentionsRequestConfig{
-				target:      m.Source,
+				parent:      m.ID,
 				submentions: false, // prevent infinite recursion
 				asc:         config.asc,
 				status:      config.status,
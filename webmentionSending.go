@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -49,13 +48,13 @@ func (a *goBlog) sendWebmentions(p *post) error {
 		if strings.HasPrefix(link, a.cfg.Server.PublicAddress) {
 			// Save mention directly
 			if err := a.createWebmention(a.fullPostURL(p), link); err != nil {
-				log.Println("Failed to create webmention:", err.Error())
+				a.logger("webmention").Error("Failed to create webmention", "err", err)
 			}
 			continue
 		}
 		// External mention
-		if a.isPrivate() {
-			// Private mode, don't send external mentions
+		if a.isPrivate() || a.isProtectedBlog(p.Blog) {
+			// Private mode or protected blog, don't send external mentions
 			continue
 		}
 		// Send webmention
@@ -64,10 +63,10 @@ func (a *goBlog) sendWebmentions(p *post) error {
 			continue
 		}
 		if err = a.sendWebmention(endpoint, a.fullPostURL(p), link); err != nil {
-			log.Println("Sending webmention to " + link + " failed")
+			a.logger("webmention").Warn("Sending webmention failed", "target", link)
 			continue
 		}
-		log.Println("Sent webmention to " + link)
+		a.logger("webmention").Info("Sent webmention", "target", link)
 	}
 	return nil
 }
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -18,15 +17,15 @@ import (
 )
 
 func (a *goBlog) initWebmentionQueue() {
-	a.listenOnQueue("wm", 30*time.Second, func(qi *queueItem, dequeue func(), reschedule func(time.Duration)) {
+	a.listenOnQueue("wm", 30*time.Second, func(_ context.Context, qi *queueItem, dequeue func(), reschedule func(time.Duration)) {
 		var m mention
 		if err := gob.NewDecoder(bytes.NewReader(qi.content)).Decode(&m); err != nil {
-			log.Println("webmention queue:", err.Error())
+			a.logger("webmention").Error("Failed to decode queue item", "err", err)
 			dequeue()
 			return
 		}
 		if err := a.verifyMention(&m); err != nil {
-			log.Printf("Failed to verify webmention from %s to %s: %s", m.Source, m.Target, err.Error())
+			a.logger("webmention").Warn("Failed to verify webmention", "source", m.Source, "target", m.Target, "err", err)
 		}
 		dequeue()
 	})
@@ -77,8 +76,9 @@ func (a *goBlog) verifyMention(m *mention) error {
 	}
 	sourceReq.Header.Set("Accept", contenttype.HTMLUTF8)
 	var sourceResp *http.Response
-	if strings.HasPrefix(m.Source, a.cfg.Server.PublicAddress) ||
-		(a.cfg.Server.ShortPublicAddress != "" && strings.HasPrefix(m.Source, a.cfg.Server.ShortPublicAddress)) {
+	localSource := strings.HasPrefix(m.Source, a.cfg.Server.PublicAddress) ||
+		(a.cfg.Server.ShortPublicAddress != "" && strings.HasPrefix(m.Source, a.cfg.Server.ShortPublicAddress))
+	if localSource {
 		setLoggedIn(sourceReq, true)
 		sourceResp, err = doHandlerRequest(sourceReq, a.getAppRouter())
 		if err != nil {
@@ -135,7 +135,12 @@ func (a *goBlog) verifyMention(m *mention) error {
 		if err != nil {
 			return err
 		}
-		a.sendNotification(fmt.Sprintf("New webmention from %s to %s", defaultIfEmpty(m.NewSource, m.Source), defaultIfEmpty(m.NewTarget, m.Target)))
+		// Local sources are comments
+		notificationType := notificationTypeWebmention
+		if localSource {
+			notificationType = notificationTypeComment
+		}
+		a.sendNotification(notificationType, fmt.Sprintf("New webmention from %s to %s", defaultIfEmpty(m.NewSource, m.Source), defaultIfEmpty(m.NewTarget, m.Target)))
 	}
 	return err
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.goblog.app/app/pkgs/builderpool"
+)
+
+// Weeknotes: after the end of each week a draft post is created that lists everything published in that week,
+// grouped by section, so it can be edited and published as a weekly digest.
+
+const (
+	weeknotesParameter     = "weeknotes" // week of the generated draft, like 2024-W05
+	weeknotesLastKeyPrefix = "weeknotes_last_"
+)
+
+func (wc *configWeeknotes) enabled() bool {
+	return wc != nil && wc.Enabled
+}
+
+func (a *goBlog) initWeeknotes() {
+	for _, bc := range a.cfg.Blogs {
+		if bc.Weeknotes.enabled() {
+			a.hourlyHooks = append(a.hourlyHooks, a.createWeeknotesDrafts)
+			return
+		}
+	}
+}
+
+func (a *goBlog) createWeeknotesDrafts() {
+	for blog, bc := range a.cfg.Blogs {
+		if !bc.Weeknotes.enabled() {
+			continue
+		}
+		if err := a.createWeeknotesDraft(blog, bc, time.Now()); err != nil {
+			log.Println("Weeknotes: failed to create draft for blog", blog+":", err.Error())
+		}
+	}
+}
+
+// Start of the week (Monday 00:00 local time) that contains t
+func weekStart(t time.Time) time.Time {
+	t = t.Local()
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, time.Local)
+}
+
+// Creates the draft for the last completed week before now, if not done already
+func (a *goBlog) createWeeknotesDraft(blog string, bc *configBlog, now time.Time) error {
+	end := weekStart(now)
+	start := end.AddDate(0, 0, -7)
+	year, week := start.ISOWeek()
+	weekID := fmt.Sprintf("%d-W%02d", year, week)
+	key := weeknotesLastKeyPrefix + blog
+	last, err := a.db.retrievePersistentCache(key)
+	if err != nil {
+		return err
+	}
+	if string(last) == weekID {
+		return nil
+	}
+	p, err := a.weeknotesDraft(blog, bc, start, end, weekID)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		if err = a.createPost(p); err != nil {
+			return err
+		}
+		log.Println("Weeknotes: created draft", p.Path)
+	}
+	return a.db.cachePersistently(key, []byte(weekID))
+}
+
+// Draft post listing the posts published between start and end, nil if there are none
+func (a *goBlog) weeknotesDraft(blog string, bc *configBlog, start, end time.Time, weekID string) (*post, error) {
+	posts, err := a.getPosts(&postsRequestConfig{
+		blog:             blog,
+		status:           []postStatus{statusPublished},
+		visibility:       []postVisibility{visibilityPublic},
+		publishedAfter:   start,
+		publishedBefore:  end,
+		excludeParameter: weeknotesParameter,
+	})
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+	// Oldest posts first, grouped by section in the order of their first post
+	posts = lo.Reverse(posts)
+	var sections []string
+	bySection := map[string][]*post{}
+	for _, p := range posts {
+		if _, ok := bySection[p.Section]; !ok {
+			sections = append(sections, p.Section)
+		}
+		bySection[p.Section] = append(bySection[p.Section], p)
+	}
+	content := builderpool.Get()
+	defer builderpool.Put(content)
+	for _, section := range sections {
+		sectionTitle := section
+		if sc, ok := bc.Sections[section]; ok && sc.Title != "" {
+			sectionTitle = sc.Title
+		}
+		if content.Len() > 0 {
+			content.WriteString("\n")
+		}
+		content.WriteString("## " + sectionTitle + "\n\n")
+		for _, p := range bySection[section] {
+			linkText := defaultIfEmpty(p.RenderedTitle, defaultIfEmpty(a.fallbackTitle(p), p.Path))
+			content.WriteString("- [" + escapeMarkdownLinkText(linkText) + "](" + a.fullPostURL(p) + ")")
+			if summary := a.postSummary(p); summary != "" && p.RenderedTitle != "" {
+				content.WriteString(": " + strings.ReplaceAll(summary, "\n", " "))
+			}
+			content.WriteString("\n")
+		}
+	}
+	wc := bc.Weeknotes
+	return &post{
+		Blog:    blog,
+		Section: defaultIfEmpty(wc.Section, bc.DefaultSection),
+		Status:  statusDraft,
+		Content: content.String(),
+		Parameters: map[string][]string{
+			"title":            {defaultIfEmpty(wc.Title, a.ts.GetTemplateStringVariant(bc.Lang, "weeknotes")) + " " + weekID},
+			weeknotesParameter: {weekID},
+		},
+	}, nil
+}
+
+func escapeMarkdownLinkText(s string) string {
+	return strings.NewReplacer("[", "\\[", "]", "\\]").Replace(s)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_weekStart(t *testing.T) {
+	assert.Equal(t, time.Date(2024, 1, 29, 0, 0, 0, 0, time.Local), weekStart(time.Date(2024, 2, 4, 23, 0, 0, 0, time.Local)))
+	assert.Equal(t, time.Date(2024, 2, 5, 0, 0, 0, 0, time.Local), weekStart(time.Date(2024, 2, 5, 0, 0, 0, 0, time.Local)))
+	assert.Equal(t, time.Date(2024, 2, 5, 0, 0, 0, 0, time.Local), weekStart(time.Date(2024, 2, 7, 12, 0, 0, 0, time.Local)))
+}
+
+func Test_weeknotes(t *testing.T) {
+	app := &goBlog{
+		cfg: createDefaultTestConfig(t),
+	}
+	app.cfg.Blogs = map[string]*configBlog{
+		"en": {
+			Lang:           "en",
+			DefaultSection: "posts",
+			Sections: map[string]*configSection{
+				"posts": {Name: "posts", Title: "Posts"},
+				"notes": {Name: "notes", Title: "Notes"},
+			},
+			Weeknotes: &configWeeknotes{
+				Enabled: true,
+			},
+		},
+	}
+	app.cfg.DefaultBlog = "en"
+
+	require.NoError(t, app.initConfig(false))
+	app.initMarkdown()
+	_ = app.initTemplateStrings()
+
+	bc := app.cfg.Blogs["en"]
+
+	for _, p := range []*post{
+		{Path: "/first", Section: "posts", Published: "2024-01-30T10:00:00+01:00", Parameters: map[string][]string{"title": {"First post"}, "summary": {"About the first post"}}},
+		{Path: "/note", Section: "notes", Published: "2024-01-31T10:00:00+01:00", Content: "Just a short note"},
+		{Path: "/second", Section: "posts", Published: "2024-02-01T10:00:00+01:00", Parameters: map[string][]string{"title": {"Second post"}}},
+		{Path: "/private", Section: "posts", Published: "2024-02-01T10:00:00+01:00", Visibility: visibilityPrivate, Parameters: map[string][]string{"title": {"Private post"}}},
+		{Path: "/next-week", Section: "posts", Published: "2024-02-06T10:00:00+01:00", Parameters: map[string][]string{"title": {"Next week"}}},
+	} {
+		p.Blog = "en"
+		p.Status = statusPublished
+		require.NoError(t, app.createPost(p))
+	}
+
+	now := time.Date(2024, 2, 7, 12, 0, 0, 0, time.Local)
+	require.NoError(t, app.createWeeknotesDraft("en", bc, now))
+
+	drafts, err := app.getPosts(&postsRequestConfig{status: []postStatus{statusDraft}, parameter: weeknotesParameter})
+	require.NoError(t, err)
+	require.Len(t, drafts, 1)
+	draft := drafts[0]
+
+	assert.Equal(t, "Weeknotes 2024-W05", draft.Title())
+	assert.Equal(t, "posts", draft.Section)
+	assert.Equal(t, "2024-W05", draft.firstParameter(weeknotesParameter))
+	assert.Equal(t, "## Posts\n\n"+
+		"- [First post](http://localhost:8080/first): About the first post\n"+
+		"- [Second post](http://localhost:8080/second)\n"+
+		"\n## Notes\n\n"+
+		"- [Just a short note](http://localhost:8080/note)", draft.Content)
+
+	// Only once per week
+	require.NoError(t, app.createWeeknotesDraft("en", bc, now.Add(time.Hour)))
+	count, err := app.db.countPosts(&postsRequestConfig{status: []postStatus{statusDraft}, parameter: weeknotesParameter})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Published weeknotes aren't listed in the next weeknotes
+	draft.Status = statusPublished
+	draft.Published = "2024-02-06T12:00:00+01:00"
+	require.NoError(t, app.replacePost(draft, draft.Path, statusDraft, draft.Visibility))
+	require.NoError(t, app.createWeeknotesDraft("en", bc, now.AddDate(0, 0, 7)))
+	drafts, err = app.getPosts(&postsRequestConfig{status: []postStatus{statusDraft}, parameter: weeknotesParameter})
+	require.NoError(t, err)
+	require.Len(t, drafts, 1)
+	assert.Equal(t, "Weeknotes 2024-W06", drafts[0].Title())
+	assert.Contains(t, drafts[0].Content, "Next week")
+	assert.NotContains(t, drafts[0].Content, "Weeknotes")
+}
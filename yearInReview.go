@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.goblog.app/app/pkgs/builderpool"
+)
+
+// Year in review: after the end of each year a draft post is created with statistics about the posts
+// published in that year, so it can be edited and published as an annual review.
+// Drafts for other years can be created in the editor.
+
+const (
+	yearInReviewParameter     = "yearinreview" // year of the generated draft
+	yearInReviewLastKeyPrefix = "yearinreview_last_"
+	yearInReviewTopCount      = 10
+)
+
+func (yc *configYearInReview) enabled() bool {
+	return yc != nil && yc.Enabled
+}
+
+func (a *goBlog) initYearInReview() {
+	for _, bc := range a.cfg.Blogs {
+		if bc.YearInReview.enabled() {
+			a.hourlyHooks = append(a.hourlyHooks, a.createYearInReviewDrafts)
+			return
+		}
+	}
+}
+
+func (a *goBlog) createYearInReviewDrafts() {
+	for blog, bc := range a.cfg.Blogs {
+		if !bc.YearInReview.enabled() {
+			continue
+		}
+		if err := a.createYearInReviewDraftOnce(blog, bc, time.Now()); err != nil {
+			log.Println("Year in review: failed to create draft for blog", blog+":", err.Error())
+		}
+	}
+}
+
+// Creates the draft for the year before now, if not done already
+func (a *goBlog) createYearInReviewDraftOnce(blog string, bc *configBlog, now time.Time) error {
+	year := now.Local().Year() - 1
+	key := yearInReviewLastKeyPrefix + blog
+	last, err := a.db.retrievePersistentCache(key)
+	if err != nil {
+		return err
+	}
+	if string(last) == strconv.Itoa(year) {
+		return nil
+	}
+	if _, err = a.createYearInReviewDraft(blog, bc, year); err != nil {
+		return err
+	}
+	return a.db.cachePersistently(key, []byte(strconv.Itoa(year)))
+}
+
+// Creates the draft for the year, returns nil if there were no posts in that year
+func (a *goBlog) createYearInReviewDraft(blog string, bc *configBlog, year int) (*post, error) {
+	p, err := a.yearInReviewDraft(blog, bc, year)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	if err = a.createPost(p); err != nil {
+		return nil, err
+	}
+	log.Println("Year in review: created draft", p.Path)
+	return p, nil
+}
+
+type yearInReviewCount struct {
+	name, link string
+	count      int
+}
+
+// Sorted by count (descending) and name, limited to max entries (if > 0)
+func sortedYearInReviewCounts(counts map[string]*yearInReviewCount, max int) []*yearInReviewCount {
+	sorted := make([]*yearInReviewCount, 0, len(counts))
+	for _, c := range counts {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	if max > 0 && len(sorted) > max {
+		sorted = sorted[:max]
+	}
+	return sorted
+}
+
+// Draft post with the statistics of the public posts published in the year, nil if there are none
+func (a *goBlog) yearInReviewDraft(blog string, bc *configBlog, year int) (*post, error) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	posts, err := a.getPosts(&postsRequestConfig{
+		blog:             blog,
+		status:           []postStatus{statusPublished},
+		visibility:       []postVisibility{visibilityPublic},
+		publishedAfter:   start,
+		publishedBefore:  start.AddDate(1, 0, 0),
+		excludeParameter: yearInReviewParameter,
+	})
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+	// Interactions (approved webmentions, comments and replies) per post
+	mentions, err := a.db.approvedInteractionCounts()
+	if err != nil {
+		return nil, err
+	}
+	var tax *configTaxonomy
+	if len(bc.Taxonomies) > 0 {
+		tax = bc.Taxonomies[0]
+	}
+	totalWords := 0
+	var monthPosts, monthWords [12]int
+	sections := map[string]*yearInReviewCount{}
+	taxValues := map[string]*yearInReviewCount{}
+	mentioned := map[string]*yearInReviewCount{}
+	for _, p := range posts {
+		a.loadPostRenderMeta(p)
+		totalWords += p.WordCount()
+		month := toLocalTime(p.Published).Month() - 1
+		monthPosts[month]++
+		monthWords[month] += p.WordCount()
+		if _, ok := sections[p.Section]; !ok {
+			sectionTitle := p.Section
+			if sc, ok := bc.Sections[p.Section]; ok && sc.Title != "" {
+				sectionTitle = sc.Title
+			}
+			sections[p.Section] = &yearInReviewCount{name: sectionTitle, link: a.getFullAddress(bc.getRelativePath(p.Section))}
+		}
+		sections[p.Section].count++
+		if tax != nil {
+			for _, value := range p.Parameters[tax.Name] {
+				key := urlize(value)
+				if _, ok := taxValues[key]; !ok {
+					taxValues[key] = &yearInReviewCount{name: value, link: a.getFullAddress(bc.getRelativePath(fmt.Sprintf("/%s/%s", tax.Name, key)))}
+				}
+				taxValues[key].count++
+			}
+		}
+		postURL := a.fullPostURL(p)
+		if count := mentions[postURL]; count > 0 {
+			mentioned[p.Path] = &yearInReviewCount{name: defaultIfEmpty(p.RenderedTitle, defaultIfEmpty(a.fallbackTitle(p), p.Path)), link: postURL, count: count}
+		}
+	}
+	// Content
+	content := builderpool.Get()
+	defer builderpool.Put(content)
+	content.WriteString(fmt.Sprintf(a.ts.GetTemplateStringVariant(bc.Lang, "yearinreviewsummary"), year, len(posts), totalWords))
+	content.WriteString("\n\n## " + a.ts.GetTemplateStringVariant(bc.Lang, "yearinreviewmonths") + "\n\n")
+	content.WriteString("| " + a.ts.GetTemplateStringVariant(bc.Lang, "month") + " | " + a.ts.GetTemplateStringVariant(bc.Lang, "posts") + " | " + a.ts.GetTemplateStringVariant(bc.Lang, "words") + " |\n")
+	content.WriteString("|---|---:|---:|\n")
+	for m := 0; m < 12; m++ {
+		if monthPosts[m] == 0 {
+			continue
+		}
+		monthID := fmt.Sprintf("%d-%02d", year, m+1)
+		monthLink := a.getFullAddress(bc.getRelativePath(fmt.Sprintf("/%d/%02d", year, m+1)))
+		content.WriteString(fmt.Sprintf("| [%s](%s) | %d | %d |\n", monthID, monthLink, monthPosts[m], monthWords[m]))
+	}
+	writeList := func(title string, counts []*yearInReviewCount) {
+		if len(counts) == 0 {
+			return
+		}
+		content.WriteString("\n## " + title + "\n\n")
+		for _, c := range counts {
+			content.WriteString(fmt.Sprintf("- [%s](%s) (%d)\n", escapeMarkdownLinkText(c.name), c.link, c.count))
+		}
+	}
+	writeList(a.ts.GetTemplateStringVariant(bc.Lang, "postsections"), sortedYearInReviewCounts(sections, 0))
+	if tax != nil {
+		writeList(defaultIfEmpty(tax.Title, tax.Name), sortedYearInReviewCounts(taxValues, yearInReviewTopCount))
+	}
+	writeList(a.ts.GetTemplateStringVariant(bc.Lang, "yearinreviewmentioned"), sortedYearInReviewCounts(mentioned, yearInReviewTopCount))
+	yc := bc.YearInReview
+	if yc == nil {
+		yc = &configYearInReview{}
+	}
+	return &post{
+		Blog:    blog,
+		Section: defaultIfEmpty(yc.Section, bc.DefaultSection),
+		Status:  statusDraft,
+		Content: content.String(),
+		Parameters: map[string][]string{
+			"title":               {defaultIfEmpty(yc.Title, a.ts.GetTemplateStringVariant(bc.Lang, "yearinreview")) + " " + strconv.Itoa(year)},
+			yearInReviewParameter: {strconv.Itoa(year)},
+		},
+	}, nil
+}
+
+// Number of approved interactions per target URL
+func (db *database) approvedInteractionCounts() (map[string]int, error) {
+	rows, err := db.Query("select target, count(*) from comments where status = @approved group by target", sql.Named("approved", webmentionStatusApproved))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var target string
+		var count int
+		if err = rows.Scan(&target, &count); err != nil {
+			return nil, err
+		}
+		counts[target] = count
+	}
+	return counts, rows.Err()
+}
+
+// Editor action to create the draft for a specific year
+func (a *goBlog) serveEditorYearInReview(w http.ResponseWriter, r *http.Request) {
+	blog, bc := a.getBlog(r)
+	if !a.hasBlogRole(r, blog, userRoleAdmin) {
+		a.serveError(w, r, "No permission for this blog", http.StatusForbidden)
+		return
+	}
+	year, err := strconv.Atoi(r.FormValue("year"))
+	if err != nil || year < 1 {
+		a.serveError(w, r, "invalid year", http.StatusBadRequest)
+		return
+	}
+	p, err := a.createYearInReviewDraft(blog, bc, year)
+	if err != nil {
+		a.serveError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		a.serveError(w, r, fmt.Sprintf("no posts published in %d", year), http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, p.Path, http.StatusFound)
+}
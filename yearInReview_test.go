@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_yearInReview(t *testing.T) {
+	h := newTestHarness(t, func(c *config) {
+		c.Blogs = map[string]*configBlog{
+			"en": {
+				Lang:           "en",
+				DefaultSection: "posts",
+				Sections: map[string]*configSection{
+					"posts": {Name: "posts", Title: "Posts"},
+					"notes": {Name: "notes", Title: "Notes"},
+				},
+				Taxonomies:   []*configTaxonomy{{Name: "tags", Title: "Tags"}},
+				YearInReview: &configYearInReview{Enabled: true},
+			},
+		}
+		c.DefaultBlog = "en"
+	})
+	app := h.app
+	bc := app.cfg.Blogs["en"]
+
+	for _, p := range []*post{
+		{Path: "/first", Section: "posts", Published: "2023-01-10T10:00:00Z", Content: "One two three", Parameters: map[string][]string{"title": {"First post"}, "tags": {"Go", "Blogging"}}},
+		{Path: "/note", Section: "notes", Published: "2023-01-20T10:00:00Z", Content: "Just a note", Parameters: map[string][]string{"tags": {"Go"}}},
+		{Path: "/second", Section: "posts", Published: "2023-03-01T10:00:00Z", Content: "Four five", Parameters: map[string][]string{"title": {"Second post"}}},
+		{Path: "/private", Section: "posts", Published: "2023-03-01T10:00:00Z", Content: "Secret", Visibility: visibilityPrivate},
+		{Path: "/other-year", Section: "posts", Published: "2024-01-01T10:00:00Z", Content: "Later"},
+	} {
+		h.createPost(p)
+	}
+	for _, target := range []string{"/second", "/second", "/first", "/private"} {
+		_, err := app.db.Exec("insert into comments (type, target, name, website, comment, created, status) values ('webmention', ?, '', '', '', 0, 'approved')", app.getFullAddress(target))
+		require.NoError(t, err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	require.NoError(t, app.createYearInReviewDraftOnce("en", bc, now))
+
+	drafts, err := app.getPosts(&postsRequestConfig{status: []postStatus{statusDraft}, parameter: yearInReviewParameter})
+	require.NoError(t, err)
+	require.Len(t, drafts, 1)
+	draft := drafts[0]
+
+	assert.Equal(t, "Year in review 2023", draft.Title())
+	assert.Equal(t, "2023", draft.firstParameter(yearInReviewParameter))
+	assert.Equal(t, "In 2023, I published 3 posts with 8 words.\n\n"+
+		"## Posts per month\n\n"+
+		"| Month | Posts | Words |\n|---|---:|---:|\n"+
+		"| [2023-01](https://example.com/2023/01) | 2 | 6 |\n"+
+		"| [2023-03](https://example.com/2023/03) | 1 | 2 |\n"+
+		"\n## Post sections\n\n"+
+		"- [Posts](https://example.com/posts) (2)\n"+
+		"- [Notes](https://example.com/notes) (1)\n"+
+		"\n## Tags\n\n"+
+		"- [Go](https://example.com/tags/go) (2)\n"+
+		"- [Blogging](https://example.com/tags/blogging) (1)\n"+
+		"\n## Most mentioned posts\n\n"+
+		"- [Second post](https://example.com/second) (2)\n"+
+		"- [First post](https://example.com/first) (1)", draft.Content)
+
+	// Only once per year
+	require.NoError(t, app.createYearInReviewDraftOnce("en", bc, now.AddDate(0, 1, 0)))
+	count, err := app.db.countPosts(&postsRequestConfig{status: []postStatus{statusDraft}, parameter: yearInReviewParameter})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Other years in the editor
+	var location string
+	require.NoError(t, h.loggedInRequest("/editor").
+		BodyForm(map[string][]string{"editoraction": {"yearinreview"}, "year": {"2024"}}).
+		AddValidator(func(r *http.Response) error {
+			location = r.Header.Get("Location")
+			return nil
+		}).
+		CheckStatus(http.StatusFound).Fetch(context.Background()))
+	p, err := app.getPost(location)
+	require.NoError(t, err)
+	assert.Equal(t, "Year in review 2024", p.Title())
+	assert.Contains(t, p.Content, "In 2024, I published 1 posts with 1 words.")
+
+	// No posts in that year
+	var status int
+	_ = h.loggedInRequest("/editor").
+		BodyForm(map[string][]string{"editoraction": {"yearinreview"}, "year": {"2020"}}).
+		AddValidator(func(r *http.Response) error {
+			status = r.StatusCode
+			return nil
+		}).Fetch(context.Background())
+	assert.Equal(t, http.StatusNotFound, status)
+}